@@ -1,6 +1,9 @@
 package main
 
-import "crypsis-backend/internal/config"
+import (
+	"crypsis-backend/internal/config"
+	"os"
+)
 
 func main() {
 	// Load environment
@@ -25,6 +28,14 @@ func main() {
 		DB:         db.Connection,
 	}
 
+	// `verify-audit` checks the audit chain and exits non-zero when it is broken
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		if !config.VerifyAuditLog(appConfig) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Bootstrap the application
 	config.BootstrapApp(appConfig)
 }
@@ -8,6 +8,8 @@ import (
 	"crypsis-backend/internal/model"
 	"crypsis-backend/internal/repository"
 	"crypsis-backend/internal/services"
+	"encoding/json"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
@@ -49,6 +51,20 @@ func BootstrapApp(config *AppConfig) {
 	// initialize repositories
 	repos := initRepositories(config.DB)
 
+	// Count the usage of apps that stored files before usage was tracked
+	if counted, err := repos.applicationRepository.BackfillUsage(context.Background()); err != nil {
+		log.Printf("⚠️  Failed to backfill app usage: %v", err)
+	} else if counted > 0 {
+		log.Printf("✅ Usage counted for %d apps", counted)
+	}
+
+	// Record the hash algorithm of files hashed before it was kept per file
+	if updated, err := repos.fileRepository.BackfillHashAlgo(context.Background(), config.Properties.HashMethod); err != nil {
+		log.Printf("⚠️  Failed to backfill hash algorithms: %v", err)
+	} else if updated > 0 {
+		log.Printf("✅ Hash algorithm recorded for %d files, versions and uploads", updated)
+	}
+
 	// initialize services
 	services := initServices(config.Properties, repos, config.DB)
 
@@ -59,6 +75,18 @@ func BootstrapApp(config *AppConfig) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Start background workers
+	go services.uploadJobService.Run(ctx)
+	go services.auditService.Run(ctx)
+	go services.lifecycleService.Run(ctx)
+	go services.bucketMigration.Run(ctx)
+	if services.storageReplication != nil {
+		go services.storageReplication.Run(ctx)
+	}
+	if services.logForwarder != nil {
+		go services.logForwarder.Run(ctx)
+	}
+
 	// Ensure OpenTelemetry shutdown on exit
 	defer func() {
 		if otelShutdown != nil {
@@ -76,6 +104,32 @@ func BootstrapApp(config *AppConfig) {
 
 }
 
+// VerifyAuditLog walks the audit chain from the command line, printing the result as JSON.
+// It returns false when the chain is broken or could not be read.
+func VerifyAuditLog(config *AppConfig) bool {
+	keyConfig, _ := loadKeyConfig(config.Properties, services.NewCryptographicService())
+	auditService := services.NewAuditService(services.AuditServiceParams{
+		FileLogsRepository: repository.NewFileLogRepository(config.DB),
+		KeyConfig:          keyConfig,
+		Interval:           config.Properties.AuditCheckpointInterval,
+	})
+
+	result, err := auditService.VerifyChain(context.Background())
+	if err != nil {
+		log.Printf("❌ Failed to verify audit chain: %v", err)
+		return false
+	}
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+
+	if !result.Valid {
+		log.Printf("❌ Audit chain is broken at log %d: %s", result.FirstBreak.LogID, result.FirstBreak.Reason)
+		return false
+	}
+	log.Printf("✅ Audit chain verified: %d entries, %d checkpoints", result.EntriesChecked, result.CheckpointsChecked)
+	return true
+}
+
 // startHTTPServer starts the HTTP server with graceful shutdown
 func startHTTPServer(ctx context.Context, server *http.Server) {
 	go func() {
@@ -139,7 +193,7 @@ func initHttpServer(services Services, config *Properties, adminRepo repository.
 	routerConfig := delivery.RouterConfig{
 		Router:          router,
 		ClientHandler:   delivery.NewClientHandler(services.fileService),
-		AdminHandler:    delivery.NewAdminHandler(services.applicationService, services.adminService, services.fileService),
+		AdminHandler:    delivery.NewAdminHandler(services.applicationService, services.adminService, services.fileService, services.auditService, services.lifecycleService),
 		HydraAdminURL:   config.HydraAdminURL,
 		TokenMiddlewere: tokenMiddlewereConfig,
 		Tracer:          otel.Tracer("crypsis-backend"),
@@ -159,16 +213,232 @@ func initServices(config *Properties, repos Repositories, db *gorm.DB) Services
 		slog.Error("Failed to load admin IDs", slog.Any("error", err))
 	}
 
-	minIOService := services.NewMinioService(model.MinIOConfig{
-		Endpoint:        config.StorageEndpoint,
-		AccessKeyID:     config.StrorageAccessID,
-		SecretAccessKey: config.StrorageSecretKey,
-		BucketName:      config.BucketName,
-		UseSSL:          config.StorageSSL,
-	})
+	// Audit events are forwarded to syslog as they are written
+	var logForwarder services.LogForwarderInterface
+	if config.SyslogAddress != "" {
+		forwarder, err := services.NewSyslogForwarder(services.SyslogForwarderParams{
+			Address:   config.SyslogAddress,
+			UseTLS:    config.SyslogTLS,
+			CAPath:    config.SyslogCAPath,
+			CertPath:  config.SyslogCertPath,
+			KeyPath:   config.SyslogKeyPath,
+			AppName:   config.ServiceName,
+			SpoolPath: config.SyslogSpoolPath,
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up syslog forwarding: %v", err)
+		}
+		logForwarder = forwarder
+		repos.fileLogRepository = repository.NewObservedFileLogRepository(repos.fileLogRepository, logForwarder.Publish)
+		slog.Info("Forwarding audit events to syslog", slog.String("address", config.SyslogAddress), slog.Bool("tls", config.SyslogTLS))
+	}
+
+	storageService, storageReplication := initStorage(config, repos)
 
 	cryptographicService := services.NewCryptographicService()
 
+	keyConfig, kmsService := loadKeyConfig(config, cryptographicService)
+
+	// Apps holding their own keys are reached over mTLS with the configured client certificate
+	var externalKMSFactory services.ExternalKMSFactory
+	if config.HYOKCertPath != "" && config.HYOKKeyPath != "" {
+		externalKMSFactory = services.NewExternalKMSFactory(config.HYOKCertPath, config.HYOKKeyPath)
+	} else {
+		slog.Warn("No client certificate for external key managers, apps cannot hold their own keys")
+	}
+
+	oauth2Service := services.NewHydraService(config.HydraAdminURL, config.HydraPublicURL)
+	adminService := services.NewAdminService(oauth2Service, repos.adminRepository, repos.fileLogRepository, cryptographicService)
+	applicationService := services.NewApplicationService(services.ApplicationServiceParams{
+		OAuth2Service:         oauth2Service,
+		ApplicationRepository: repos.applicationRepository,
+		StorageService:        storageService,
+		FileLogsRepository:    repos.fileLogRepository,
+		AppBucketPrefix:       config.AppBucketPrefix,
+	})
+
+	fileServiceParams := services.FileServiceParams{
+		CryptoService:           cryptographicService,
+		StorageService:          storageService,
+		KMSService:              kmsService,
+		FileRepository:          repos.fileRepository,
+		FileLogsRepository:      repos.fileLogRepository,
+		ApplicationRepository:   repos.applicationRepository,
+		AdminRepository:         repos.adminRepository,
+		UploadJobRepository:     repos.uploadJobRepository,
+		UploadSessionRepository: repos.uploadSessionRepository,
+		FileVersionRepository:   repos.fileVersionRepository,
+		ShareLinkRepository:     repos.shareLinkRepository,
+		UploadTokenRepository:   repos.uploadTokenRepository,
+		FolderRepository:        repos.folderRepository,
+		DB:                      db,
+		KeyConfig:               keyConfig,
+		BucketName:              config.BucketName,
+		HashMethod:              config.HashMethod,
+		HashEncryptedFile:       config.HashEncryptedFile,
+		EncryptionMethod:        config.EncMethod,
+		UploadStaleAfter:        config.UploadStaleAfter,
+		UploadSessionTTL:        config.UploadSessionTTL,
+		ShareLinkSecret:         config.ShareLinkSecret,
+		ShareLinkTTL:            config.ShareLinkTTL,
+		ShareLinkMaxTTL:         config.ShareLinkMaxTTL,
+		UploadTokenTTL:          config.UploadTokenTTL,
+		UploadTokenMaxTTL:       config.UploadTokenMaxTTL,
+		PublicBaseURL:           config.PublicBaseURL,
+		ExternalKMSFactory:      externalKMSFactory,
+	}
+
+	fileService := services.NewFileService(fileServiceParams)
+
+	uploadJobService := services.NewUploadJobService(services.UploadJobServiceParams{
+		StorageService:          storageService,
+		UploadJobRepository:     repos.uploadJobRepository,
+		UploadSessionRepository: repos.uploadSessionRepository,
+		Interval:                config.UploadJobInterval,
+		MaxAttempts:             config.UploadJobMaxAttempts,
+	})
+
+	auditService := services.NewAuditService(services.AuditServiceParams{
+		FileLogsRepository: repos.fileLogRepository,
+		KeyConfig:          keyConfig,
+		Interval:           config.AuditCheckpointInterval,
+		ProductVersion:     config.ServiceVersion,
+	})
+
+	lifecycleService := services.NewLifecycleService(services.LifecycleServiceParams{
+		FileService:           fileService,
+		AuditService:          auditService,
+		FileRepository:        repos.fileRepository,
+		ApplicationRepository: repos.applicationRepository,
+		FileLogsRepository:    repos.fileLogRepository,
+		Interval:              config.LifecycleInterval,
+		BatchSize:             config.LifecycleBatchSize,
+		LogRetentionDays:      config.AuditLogRetentionDays,
+		DryRun:                config.LifecycleDryRun,
+	})
+
+	bucketMigrationService := services.NewBucketMigrationService(services.BucketMigrationServiceParams{
+		StorageService:        storageService,
+		FileRepository:        repos.fileRepository,
+		FileVersionRepository: repos.fileVersionRepository,
+		ApplicationRepository: repos.applicationRepository,
+		FileLogsRepository:    repos.fileLogRepository,
+		DefaultBucket:         config.BucketName,
+		Interval:              config.MigrationInterval,
+		BatchSize:             config.MigrationBatchSize,
+	})
+
+	return Services{
+		adminService:         adminService,
+		applicationService:   applicationService,
+		cryptographicService: cryptographicService,
+		fileService:          fileService,
+		uploadJobService:     uploadJobService,
+		auditService:         auditService,
+		lifecycleService:     lifecycleService,
+		bucketMigration:      bucketMigrationService,
+		storageReplication:   storageReplication,
+		logForwarder:         logForwarder,
+		oauth2Service:        oauth2Service,
+		storageService:       storageService,
+		kmsService:           kmsService,
+	}
+
+}
+
+func initRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		applicationRepository:   repository.NewAppsRepository(db),
+		adminRepository:         repository.NewAdminRepository(db),
+		fileRepository:          repository.NewFileRepository(db),
+		fileLogRepository:       repository.NewFileLogRepository(db),
+		uploadJobRepository:     repository.NewUploadJobRepository(db),
+		uploadSessionRepository: repository.NewUploadSessionRepository(db),
+		fileVersionRepository:   repository.NewFileVersionRepository(db),
+		shareLinkRepository:     repository.NewShareLinkRepository(db),
+		uploadTokenRepository:   repository.NewUploadTokenRepository(db),
+		folderRepository:        repository.NewFolderRepository(db),
+		objectReplicaRepository: repository.NewObjectReplicaRepository(db),
+	}
+
+}
+
+// initStorage connects to the storage backend selected with STORAGE_BACKEND. When replicas are configured every
+// object is copied to them as well and the replication is returned so its repair worker can be started.
+func initStorage(config *Properties, repos Repositories) (services.StorageInterface, services.ReplicatedStorageInterface) {
+	primary := openStorage(StorageReplicaProperties{
+		Name:      "primary",
+		Backend:   config.StorageBackend,
+		Path:      config.StoragePath,
+		Endpoint:  config.StorageEndpoint,
+		AccessID:  config.StrorageAccessID,
+		SecretKey: config.StrorageSecretKey,
+		SSL:       config.StorageSSL,
+	}, config.BucketName)
+	if len(config.StorageReplicas) == 0 {
+		return primary, nil
+	}
+
+	replicas := make([]services.StorageReplica, 0, len(config.StorageReplicas))
+	for _, properties := range config.StorageReplicas {
+		storage := openStorage(properties, config.BucketName)
+		// A MinIO primary brings its default bucket along, but a replica in a new site may not have it yet
+		if properties.Backend == services.StorageBackendMinIO {
+			if err := storage.CreateBucket(context.Background(), config.BucketName); err != nil {
+				slog.Warn("Failed to create bucket on storage replica", slog.String("replica", properties.Name),
+					slog.String("bucket", config.BucketName), slog.Any("error", err))
+			}
+		}
+		replicas = append(replicas, services.StorageReplica{Name: properties.Name, Storage: storage})
+		slog.Info("Replicating files to storage replica", slog.String("replica", properties.Name), slog.String("backend", properties.Backend))
+	}
+
+	replicated := services.NewReplicatedStorageService(services.ReplicatedStorageServiceParams{
+		Primary:                 primary,
+		Replicas:                replicas,
+		ObjectReplicaRepository: repos.objectReplicaRepository,
+		Policy:                  services.AppReplicationPolicy(repos.applicationRepository, config.ReplicateByDefault),
+		Interval:                config.ReplicaRepairInterval,
+		BatchSize:               config.ReplicaRepairBatchSize,
+	})
+	return replicated, replicated
+}
+
+// openStorage connects to a storage backend. The filesystem and in-memory backends start without buckets, so the
+// default bucket is created for them.
+func openStorage(properties StorageReplicaProperties, bucketName string) services.StorageInterface {
+	var storageService services.StorageInterface
+	switch properties.Backend {
+	case services.StorageBackendMinIO:
+		return services.NewMinioService(model.MinIOConfig{
+			Endpoint:        properties.Endpoint,
+			AccessKeyID:     properties.AccessID,
+			SecretAccessKey: properties.SecretKey,
+			BucketName:      bucketName,
+			UseSSL:          properties.SSL,
+		})
+	case services.StorageBackendFilesystem:
+		filesystemService, err := services.NewFilesystemStorageService(properties.Path)
+		if err != nil {
+			log.Fatalf("Failed to set up filesystem storage for %s: %v", properties.Name, err)
+		}
+		storageService = filesystemService
+	case services.StorageBackendMemory:
+		slog.Warn("Files are kept in memory and lost when the server stops", slog.String("storage", properties.Name))
+		storageService = services.NewMemoryStorageService()
+	default:
+		log.Fatalf("Unsupported storage backend %q for %s: use minio, filesystem or memory", properties.Backend, properties.Name)
+	}
+
+	if err := storageService.CreateBucket(context.Background(), bucketName); err != nil {
+		log.Fatalf("Failed to create bucket %s for %s: %v", bucketName, properties.Name, err)
+	}
+	slog.Info("Storing files locally", slog.String("storage", properties.Name), slog.String("backend", properties.Backend), slog.String("bucket", bucketName))
+	return storageService
+}
+
+// loadKeyConfig loads the KEK from the KMS or from the key file, together with the KMS client when it is enabled
+func loadKeyConfig(config *Properties, cryptographicService services.CryptographicInterface) (*model.KeyConfig, services.KMSInterface) {
 	keyConfig := &model.KeyConfig{
 		KMSEnable: config.KMSEnable,
 	}
@@ -216,48 +486,7 @@ func initServices(config *Properties, repos Repositories, db *gorm.DB) Services
 		keyConfig.KEK = key
 	}
 
-	oauth2Service := services.NewHydraService(config.HydraAdminURL, config.HydraPublicURL)
-	adminService := services.NewAdminService(oauth2Service, repos.adminRepository, repos.fileLogRepository, cryptographicService)
-	applicationService := services.NewApplicationService(oauth2Service, repos.applicationRepository, repos.fileLogRepository)
-
-	fileServiceParams := services.FileServiceParams{
-		CryptoService:         cryptographicService,
-		StorageService:        minIOService,
-		KMSService:            kmsService,
-		FileRepository:        repos.fileRepository,
-		FileLogsRepository:    repos.fileLogRepository,
-		ApplicationRepository: repos.applicationRepository,
-		AdminRepository:       repos.adminRepository,
-		DB:                    db,
-		KeyConfig:             keyConfig,
-		BucketName:            config.BucketName,
-		HashMethod:            config.HashMethod,
-		HashEncryptedFile:     config.HashEncryptedFile,
-		EncryptionMethod:      config.EncMethod,
-	}
-
-	fileService := services.NewFileService(fileServiceParams)
-
-	return Services{
-		adminService:         adminService,
-		applicationService:   applicationService,
-		cryptographicService: cryptographicService,
-		fileService:          fileService,
-		oauth2Service:        oauth2Service,
-		storageService:       minIOService,
-		kmsService:           kmsService,
-	}
-
-}
-
-func initRepositories(db *gorm.DB) Repositories {
-	return Repositories{
-		applicationRepository: repository.NewAppsRepository(db),
-		adminRepository:       repository.NewAdminRepository(db),
-		fileRepository:        repository.NewFileRepository(db),
-		fileLogRepository:     repository.NewFileLogRepository(db),
-	}
-
+	return keyConfig, kmsService
 }
 
 type Services struct {
@@ -265,14 +494,27 @@ type Services struct {
 	applicationService   services.ApplicationInterface
 	cryptographicService services.CryptographicInterface
 	fileService          services.FileInterface
+	uploadJobService     services.UploadJobInterface
+	auditService         services.AuditInterface
+	lifecycleService     services.LifecycleInterface
+	bucketMigration      services.BucketMigrationInterface
+	storageReplication   services.ReplicatedStorageInterface
+	logForwarder         services.LogForwarderInterface
 	oauth2Service        services.OAuth2Interface
 	storageService       services.StorageInterface
 	kmsService           services.KMSInterface
 }
 
 type Repositories struct {
-	applicationRepository repository.ApplicationRepository
-	adminRepository       repository.AdminRepository
-	fileRepository        repository.FileRepository
-	fileLogRepository     repository.FileLogsRepository
+	applicationRepository   repository.ApplicationRepository
+	adminRepository         repository.AdminRepository
+	fileRepository          repository.FileRepository
+	fileLogRepository       repository.FileLogsRepository
+	uploadJobRepository     repository.UploadJobRepository
+	uploadSessionRepository repository.UploadSessionRepository
+	fileVersionRepository   repository.FileVersionRepository
+	shareLinkRepository     repository.ShareLinkRepository
+	uploadTokenRepository   repository.UploadTokenRepository
+	folderRepository        repository.FolderRepository
+	objectReplicaRepository repository.ObjectReplicaRepository
 }
@@ -3,10 +3,24 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// StorageReplicaProperties configures one storage replica, read from the STORAGE_REPLICA_<NAME>_* variables
+type StorageReplicaProperties struct {
+	Name      string
+	Backend   string // minio, filesystem or memory
+	Path      string // root directory of the filesystem backend
+	Endpoint  string
+	AccessID  string
+	SecretKey string
+	SSL       bool
+}
+
 type Properties struct {
 	DBHost     string
 	DBPort     string
@@ -16,16 +30,62 @@ type Properties struct {
 	DBSSLMode  string
 
 	MKeyPath          string
+	StorageBackend    string // minio, filesystem or memory
+	StoragePath       string // root directory of the filesystem backend
 	StorageEndpoint   string
 	StrorageAccessID  string
 	StrorageSecretKey string
 	StorageSSL        bool
 	BucketName        string
+	AppBucketPrefix   string // every app gets a bucket of its own named with this prefix; empty shares BucketName
+
+	// Storage replicas every object is copied to, in another site
+	StorageReplicas        []StorageReplicaProperties
+	ReplicateByDefault     bool // whether the objects of apps without a replication setting are replicated
+	ReplicaRepairInterval  time.Duration
+	ReplicaRepairBatchSize int
 
 	HashMethod        string
 	EncMethod         string
 	HashEncryptedFile bool
 
+	// Upload outbox worker
+	UploadJobInterval    time.Duration
+	UploadJobMaxAttempts int
+	UploadStaleAfter     time.Duration
+	UploadSessionTTL     time.Duration
+
+	// Share links
+	ShareLinkSecret string
+	ShareLinkTTL    time.Duration
+	ShareLinkMaxTTL time.Duration
+	PublicBaseURL   string
+
+	// Upload tokens
+	UploadTokenTTL    time.Duration
+	UploadTokenMaxTTL time.Duration
+
+	// Audit chain
+	AuditCheckpointInterval time.Duration
+	AuditLogRetentionDays   int
+
+	// Lifecycle scheduler
+	LifecycleInterval  time.Duration
+	LifecycleBatchSize int
+	LifecycleDryRun    bool
+
+	// Bucket migration worker
+	MigrationInterval  time.Duration
+	MigrationBatchSize int
+
+	// Syslog forwarding of audit events
+	SyslogAddress   string
+	SyslogTLS       bool
+	SyslogCAPath    string
+	SyslogCertPath  string
+	SyslogKeyPath   string
+	SyslogSpoolPath string
+
 	HydraPublicURL string
 	HydraAdminURL  string
 
@@ -36,6 +96,10 @@ type Properties struct {
 	CertPath  string
 	CAPath    string
 
+	// Client certificate presented to the key managers of apps holding their own keys
+	HYOKCertPath string
+	HYOKKeyPath  string
+
 	// OpenTelemetry
 	OTELEnable     bool
 	OTELEndpoint   string
@@ -56,42 +120,122 @@ func LoadProperties() *Properties {
 	}
 
 	properties := &Properties{
-		DBHost:            os.Getenv("DB_HOST"),
-		DBPort:            os.Getenv("DB_PORT"),
-		DBUser:            os.Getenv("DB_USER"),
-		DBPassword:        os.Getenv("DB_PASSWORD"),
-		DBName:            os.Getenv("DB_NAME"),
-		DBSSLMode:         getEnvWithDefault("DB_SSLMODE", "disable"),
-		MKeyPath:          os.Getenv("MKEY_PATH"),
-		StorageEndpoint:   os.Getenv("STORAGE_ENDPOINT"),
-		StrorageAccessID:  os.Getenv("STORAGE_ACCESS_KEY"),
-		StrorageSecretKey: os.Getenv("STORAGE_SECRET_KEY"),
-		StorageSSL:        os.Getenv("STRORAGE_SSL") == "true",
-		BucketName:        os.Getenv("BUCKET_NAME"),
-		HashMethod:        os.Getenv("HASH_METHOD"),
-		HydraPublicURL:    os.Getenv("HYDRA_PUBLIC_URL"),
-		HydraAdminURL:     os.Getenv("HYDRA_ADMIN_URL"),
-		KMSEnable:         os.Getenv("KMS_ENABLE") == "true",
-		KMSKeyUID:         os.Getenv("KMS_KEY_UID"),
-		KMSUrl:            os.Getenv("KMS_URL"),
-		KeyPath:           os.Getenv("KEY_PATH"),
-		CertPath:          os.Getenv("CERT_PATH"),
-		CAPath:            os.Getenv("CA_PATH"),
-		EncMethod:         os.Getenv("ENC_METHOD"),
-		HashEncryptedFile: os.Getenv("HASH_ENCRYPTED_FILE") == "true",
-		OTELEnable:        getEnvWithDefault("OTEL_ENABLE", "false") == "true",
-		OTELEndpoint:      getEnvWithDefault("OTEL_ENDPOINT", "localhost:4318"),
-		ServiceName:       getEnvWithDefault("SERVICE_NAME", "crypsis-backend"),
-		ServiceVersion:    getEnvWithDefault("SERVICE_VERSION", "1.0.0"),
-		Environment:       getEnvWithDefault("ENVIRONMENT", "development"),
+		DBHost:                  os.Getenv("DB_HOST"),
+		DBPort:                  os.Getenv("DB_PORT"),
+		DBUser:                  os.Getenv("DB_USER"),
+		DBPassword:              os.Getenv("DB_PASSWORD"),
+		DBName:                  os.Getenv("DB_NAME"),
+		DBSSLMode:               getEnvWithDefault("DB_SSLMODE", "disable"),
+		MKeyPath:                os.Getenv("MKEY_PATH"),
+		StorageBackend:          getEnvWithDefault("STORAGE_BACKEND", "minio"),
+		StoragePath:             getEnvWithDefault("STORAGE_PATH", "./data/storage"),
+		StorageEndpoint:         os.Getenv("STORAGE_ENDPOINT"),
+		StrorageAccessID:        os.Getenv("STORAGE_ACCESS_KEY"),
+		StrorageSecretKey:       os.Getenv("STORAGE_SECRET_KEY"),
+		StorageSSL:              os.Getenv("STRORAGE_SSL") == "true",
+		BucketName:              os.Getenv("BUCKET_NAME"),
+		AppBucketPrefix:         os.Getenv("APP_BUCKET_PREFIX"),
+		StorageReplicas:         loadStorageReplicas(),
+		ReplicateByDefault:      getEnvWithDefault("STORAGE_REPLICATE_DEFAULT", "true") == "true",
+		ReplicaRepairInterval:   getDurationWithDefault("REPLICA_REPAIR_INTERVAL", time.Minute),
+		ReplicaRepairBatchSize:  getIntWithDefault("REPLICA_REPAIR_BATCH_SIZE", 100),
+		HashMethod:              os.Getenv("HASH_METHOD"),
+		HydraPublicURL:          os.Getenv("HYDRA_PUBLIC_URL"),
+		HydraAdminURL:           os.Getenv("HYDRA_ADMIN_URL"),
+		KMSEnable:               os.Getenv("KMS_ENABLE") == "true",
+		KMSKeyUID:               os.Getenv("KMS_KEY_UID"),
+		KMSUrl:                  os.Getenv("KMS_URL"),
+		KeyPath:                 os.Getenv("KEY_PATH"),
+		CertPath:                os.Getenv("CERT_PATH"),
+		CAPath:                  os.Getenv("CA_PATH"),
+		HYOKCertPath:            getEnvWithDefault("HYOK_CERT_PATH", os.Getenv("CERT_PATH")),
+		HYOKKeyPath:             getEnvWithDefault("HYOK_KEY_PATH", os.Getenv("KEY_PATH")),
+		EncMethod:               os.Getenv("ENC_METHOD"),
+		HashEncryptedFile:       os.Getenv("HASH_ENCRYPTED_FILE") == "true",
+		UploadJobInterval:       getDurationWithDefault("UPLOAD_JOB_INTERVAL", 30*time.Second),
+		UploadJobMaxAttempts:    getIntWithDefault("UPLOAD_JOB_MAX_ATTEMPTS", 8),
+		UploadStaleAfter:        getDurationWithDefault("UPLOAD_STALE_AFTER", 6*time.Hour),
+		UploadSessionTTL:        getDurationWithDefault("UPLOAD_SESSION_TTL", 24*time.Hour),
+		ShareLinkSecret:         os.Getenv("SHARE_LINK_SECRET"),
+		ShareLinkTTL:            getDurationWithDefault("SHARE_LINK_TTL", 24*time.Hour),
+		ShareLinkMaxTTL:         getDurationWithDefault("SHARE_LINK_MAX_TTL", 7*24*time.Hour),
+		PublicBaseURL:           os.Getenv("PUBLIC_BASE_URL"),
+		UploadTokenTTL:          getDurationWithDefault("UPLOAD_TOKEN_TTL", time.Hour),
+		UploadTokenMaxTTL:       getDurationWithDefault("UPLOAD_TOKEN_MAX_TTL", 7*24*time.Hour),
+		AuditCheckpointInterval: getDurationWithDefault("AUDIT_CHECKPOINT_INTERVAL", time.Hour),
+		AuditLogRetentionDays:   getIntWithDefault("AUDIT_LOG_RETENTION_DAYS", 0),
+		LifecycleInterval:       getDurationWithDefault("LIFECYCLE_INTERVAL", time.Hour),
+		LifecycleBatchSize:      getIntWithDefault("LIFECYCLE_BATCH_SIZE", 100),
+		LifecycleDryRun:         os.Getenv("LIFECYCLE_DRY_RUN") == "true",
+		MigrationInterval:       getDurationWithDefault("BUCKET_MIGRATION_INTERVAL", 5*time.Minute),
+		MigrationBatchSize:      getIntWithDefault("BUCKET_MIGRATION_BATCH_SIZE", 100),
+		SyslogAddress:           os.Getenv("SYSLOG_ADDRESS"),
+		SyslogTLS:               os.Getenv("SYSLOG_TLS") == "true",
+		SyslogCAPath:            os.Getenv("SYSLOG_CA_PATH"),
+		SyslogCertPath:          os.Getenv("SYSLOG_CERT_PATH"),
+		SyslogKeyPath:           os.Getenv("SYSLOG_KEY_PATH"),
+		SyslogSpoolPath:         getEnvWithDefault("SYSLOG_SPOOL_PATH", "./spool/syslog.spool"),
+		OTELEnable:              getEnvWithDefault("OTEL_ENABLE", "false") == "true",
+		OTELEndpoint:            getEnvWithDefault("OTEL_ENDPOINT", "localhost:4318"),
+		ServiceName:             getEnvWithDefault("SERVICE_NAME", "crypsis-backend"),
+		ServiceVersion:          getEnvWithDefault("SERVICE_VERSION", "1.0.0"),
+		Environment:             getEnvWithDefault("ENVIRONMENT", "development"),
 	}
 
 	return properties
 }
 
+// loadStorageReplicas reads the replicas named in the comma separated STORAGE_REPLICAS list
+func loadStorageReplicas() []StorageReplicaProperties {
+	var replicas []StorageReplicaProperties
+	for _, name := range strings.Split(os.Getenv("STORAGE_REPLICAS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "STORAGE_REPLICA_" + strings.ToUpper(name) + "_"
+		replicas = append(replicas, StorageReplicaProperties{
+			Name:      name,
+			Backend:   getEnvWithDefault(prefix+"BACKEND", "minio"),
+			Path:      os.Getenv(prefix + "PATH"),
+			Endpoint:  os.Getenv(prefix + "ENDPOINT"),
+			AccessID:  os.Getenv(prefix + "ACCESS_KEY"),
+			SecretKey: os.Getenv(prefix + "SECRET_KEY"),
+			SSL:       os.Getenv(prefix+"SSL") == "true",
+		})
+	}
+	return replicas
+}
+
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getDurationWithDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s, using default %s", key, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+func getIntWithDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number for %s, using default %d", key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
@@ -74,6 +74,17 @@ func NewDatabase(config Config) (*Databse, error) {
 }
 
 func (d *Databse) RunMigrations() error {
+	// AutoMigrate never updates an existing check constraint, so the action check is dropped to be recreated
+	// with the actions of the unified audit trail. Databases created by scripts/init_schema.sql before it named
+	// the check carry the name Postgres gave it.
+	for _, name := range []string{"chk_file_logs_action", "file_logs_action_check"} {
+		if d.Connection.Migrator().HasConstraint(&entity.FileLogs{}, name) {
+			if err := d.Connection.Migrator().DropConstraint(&entity.FileLogs{}, name); err != nil {
+				return fmt.Errorf("failed to drop file log action constraint %s: %w", name, err)
+			}
+		}
+	}
+
 	// Step 1: Migrate Role, Permission , and Category first
 	if err := d.Connection.AutoMigrate(
 		&entity.Apps{},
@@ -87,6 +98,17 @@ func (d *Databse) RunMigrations() error {
 	// Step 2: Migrate remaining tables
 	if err := d.Connection.AutoMigrate(
 		&entity.Metadata{},
+		&entity.FileVersions{},
+		&entity.UploadJobs{},
+		&entity.UploadSessions{},
+		&entity.UploadParts{},
+		&entity.ShareLinks{},
+		&entity.UploadTokens{},
+		&entity.Folders{},
+		&entity.FileTags{},
+		&entity.AuditCheckpoints{},
+		&entity.AppUsage{},
+		&entity.ObjectReplicas{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate remaining tables: %w", err)
 	}
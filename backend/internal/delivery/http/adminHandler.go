@@ -4,28 +4,36 @@ import (
 	"crypsis-backend/internal/delivery/middlewere"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/services"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 )
 
 type AdminHandler struct {
-	appService   services.ApplicationInterface
-	fileService  services.FileInterface
-	adminService services.AdminInterface
-	validator    *validator.Validate
+	appService       services.ApplicationInterface
+	fileService      services.FileInterface
+	adminService     services.AdminInterface
+	auditService     services.AuditInterface
+	lifecycleService services.LifecycleInterface
+	validator        *validator.Validate
 }
 
-func NewAdminHandler(appService services.ApplicationInterface, adminService services.AdminInterface, fileService services.FileInterface) *AdminHandler {
+func NewAdminHandler(appService services.ApplicationInterface, adminService services.AdminInterface, fileService services.FileInterface, auditService services.AuditInterface, lifecycleService services.LifecycleInterface) *AdminHandler {
 	return &AdminHandler{
-		appService:   appService,
-		adminService: adminService,
-		fileService:  fileService,
-		validator:    validator.New(),
+		appService:       appService,
+		adminService:     adminService,
+		fileService:      fileService,
+		auditService:     auditService,
+		lifecycleService: lifecycleService,
+		validator:        validator.New(),
 	}
 }
 
@@ -40,7 +48,7 @@ func (a *AdminHandler) Login(c *gin.Context) {
 	}
 
 	// DOLOGIN
-	result, err := a.adminService.Login(c, request.Username, request.Password)
+	result, err := a.adminService.Login(c.Request.Context(), request.Username, request.Password)
 	if err != nil {
 		switch {
 		case errors.Is(err, model.AdminErrWrongCredentials):
@@ -68,7 +76,7 @@ func (a *AdminHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	result, err := a.adminService.RevokeToken(c, adminID, accessToken)
+	result, err := a.adminService.RevokeToken(c.Request.Context(), adminID, accessToken)
 	if err != nil {
 		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		return
@@ -89,7 +97,7 @@ func (a *AdminHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	result, err := a.adminService.RefreshToken(c, adminID, accessToken)
+	result, err := a.adminService.RefreshToken(c.Request.Context(), adminID, accessToken)
 	if err != nil {
 		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		return
@@ -113,7 +121,7 @@ func (a *AdminHandler) UpdateAdminUsername(c *gin.Context) {
 		return
 	}
 
-	err = a.adminService.UpdateUsername(c, adminID, request.Username)
+	err = a.adminService.UpdateUsername(c.Request.Context(), adminID, request.Username)
 	if err != nil {
 		switch {
 		case errors.Is(err, model.AdminErrWrongCredentials):
@@ -143,7 +151,7 @@ func (a *AdminHandler) UpdateAdminPassword(c *gin.Context) {
 		return
 	}
 
-	err = a.adminService.UpdatePassword(c, adminID, request.Password)
+	err = a.adminService.UpdatePassword(c.Request.Context(), adminID, request.Password)
 	if err != nil {
 		switch {
 		case errors.Is(err, model.AdminErrWrongCredentials):
@@ -301,6 +309,45 @@ func (a *AdminHandler) AddApp(c *gin.Context) {
 
 }
 
+// UpdateApp renames an app and replaces its OAuth2 URIs
+func (a *AdminHandler) UpdateApp(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	var request model.AddAppRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := a.validator.Struct(request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", model.WrapValidationError(err))
+		return
+	}
+
+	result, err := a.appService.UpdateApp(c.Request.Context(), appID, request.Name, request.Uri, request.RedirectUri)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to update app", err.Error())
+		case errors.Is(err, model.ErrAppAlreadyExists):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to update app", err.Error())
+		case errors.Is(err, model.ErrInvalidInput):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update app", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "App updated successfully", result)
+}
+
 func (a *AdminHandler) GetApp(c *gin.Context) {
 	_, isAllowed := middlewere.GetUserIDFromToken(c)
 	if !isAllowed {
@@ -448,6 +495,257 @@ func (a *AdminHandler) RotateSecret(c *gin.Context) {
 
 }
 
+func (a *AdminHandler) SetKeyProvider(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	var request model.KeyProviderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := a.appService.SetKeyProvider(c.Request.Context(), appID, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to set key provider", err.Error())
+		case errors.Is(err, model.ErrInvalidInput), errors.Is(err, model.ErrInvalidKeyProvider):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to set key provider", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Key provider updated successfully", result)
+}
+
+// SetLifecyclePolicy sets how long the files of an app live and how long they are kept once soft deleted
+func (a *AdminHandler) SetLifecyclePolicy(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	var request model.LifecyclePolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := a.appService.SetLifecyclePolicy(c.Request.Context(), appID, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to set lifecycle policy", err.Error())
+		case errors.Is(err, model.ErrInvalidLifecyclePolicy):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to set lifecycle policy", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Lifecycle policy updated successfully", result)
+}
+
+// SetQuota sets how many bytes and files an app may store and how large one file may be
+func (a *AdminHandler) SetQuota(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	var request model.AppQuotaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := a.appService.SetQuota(c.Request.Context(), appID, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to set quota", err.Error())
+		case errors.Is(err, model.ErrInvalidQuota):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to set quota", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Quota updated successfully", result)
+}
+
+// GetUsage reports the bytes and files an app stores against its quota
+func (a *AdminHandler) GetUsage(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	result, err := a.appService.GetUsage(c.Request.Context(), appID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to get usage", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Usage retrieved successfully", result)
+}
+
+// SetClientConfig sets the bucket, hash method and encryption method of an app's new files
+func (a *AdminHandler) SetClientConfig(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	var request model.ClientConfigRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := a.appService.SetClientConfig(c.Request.Context(), appID, request)
+	if err != nil {
+		clientConfigErrorResponse(c, "Failed to set client config", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Client config updated successfully", result)
+}
+
+// GetClientConfig reports the bucket, hash method and encryption method of an app
+func (a *AdminHandler) GetClientConfig(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	result, err := a.appService.GetClientConfig(c.Request.Context(), appID)
+	if err != nil {
+		clientConfigErrorResponse(c, "Failed to get client config", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Client config retrieved successfully", result)
+}
+
+func clientConfigErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrInvalidBucketName), errors.Is(err, model.ErrUnsupportedHashMethod),
+		errors.Is(err, model.ErrUnsupportedEncryptionMethod):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrAppBucketNotSet):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
+// MigrateAppBucket asks for the files an app keeps in other buckets to be moved into its own bucket in the background
+func (a *AdminHandler) MigrateAppBucket(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	result, err := a.appService.MigrateAppBucket(c.Request.Context(), appID)
+	if err != nil {
+		clientConfigErrorResponse(c, "Failed to start bucket migration", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusAccepted, "Bucket migration started", result)
+}
+
+// GetAppBucket reports the bucket of an app and how many of its files are still kept elsewhere
+func (a *AdminHandler) GetAppBucket(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+	appID := c.Param("id")
+	if appID == "" {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid app id", "App id is required")
+		return
+	}
+
+	result, err := a.appService.GetAppBucket(c.Request.Context(), appID)
+	if err != nil {
+		clientConfigErrorResponse(c, "Failed to get app bucket", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "App bucket retrieved successfully", result)
+}
+
+// LifecycleReport reports what a pass of the lifecycle rules would do right now, without changing anything
+func (a *AdminHandler) LifecycleReport(c *gin.Context) {
+	a.applyLifecycle(c, true)
+}
+
+// RunLifecycle applies the lifecycle rules right now, or only reports them with dry_run=true
+func (a *AdminHandler) RunLifecycle(c *gin.Context) {
+	a.applyLifecycle(c, c.Query("dry_run") == "true")
+}
+
+func (a *AdminHandler) applyLifecycle(c *gin.Context, dryRun bool) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	report, err := a.lifecycleService.Apply(c.Request.Context(), dryRun)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		return
+	}
+	if dryRun {
+		model.JSONSuccessResponse(c, http.StatusOK, "Lifecycle dry run completed", report)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Lifecycle rules applied", report)
+}
+
 func (a *AdminHandler) ListFiles(c *gin.Context) {
 	adminID, isAllowed := middlewere.GetUserIDFromToken(c)
 	if !isAllowed {
@@ -472,17 +770,27 @@ func (a *AdminHandler) ListFiles(c *gin.Context) {
 	// Validate sort parameters using centralized helper
 	sortBy, order = helper.ValidateSortParams(sortBy, order, helper.AllowedFileSortFields)
 
-	count, result, err := a.fileService.ListFilesForAdmin(c.Request.Context(), adminID, "", limit, offset, sortBy, order)
+	filter, err := fileFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
+		return
+	}
+	filter.Deleted = c.Query("deleted")
+
+	count, result, nextCursor, err := a.fileService.ListFilesForAdmin(c.Request.Context(), adminID, "", filter, limit, offset, sortBy, order)
 	if err != nil {
 		switch {
-		case errors.Is(err, model.ErrInvalidInput):
+		case errors.Is(err, model.ErrInvalidInput),
+			errors.Is(err, model.ErrInvalidTag),
+			errors.Is(err, model.ErrInvalidFilter),
+			errors.Is(err, model.ErrInvalidCursor):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
-	model.JSONSuccessResponseWithCount(c, http.StatusOK, "Files fetched successfully", count, result)
+	model.JSONSuccessResponseWithCursor(c, http.StatusOK, "Files fetched successfully", count, nextCursor, result)
 
 }
 
@@ -516,17 +824,27 @@ func (a *AdminHandler) ListFilesByAppId(c *gin.Context) {
 		return
 	}
 
-	count, result, err := a.fileService.ListFilesForAdmin(c.Request.Context(), adminID, appID, limit, offset, sortBy, order)
+	filter, err := fileFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
+		return
+	}
+	filter.Deleted = c.Query("deleted")
+
+	count, result, nextCursor, err := a.fileService.ListFilesForAdmin(c.Request.Context(), adminID, appID, filter, limit, offset, sortBy, order)
 	if err != nil {
 		switch {
-		case errors.Is(err, model.ErrInvalidInput):
+		case errors.Is(err, model.ErrInvalidInput),
+			errors.Is(err, model.ErrInvalidTag),
+			errors.Is(err, model.ErrInvalidFilter),
+			errors.Is(err, model.ErrInvalidCursor):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
-	model.JSONSuccessResponseWithCount(c, http.StatusOK, "Files fetched successfully", count, result)
+	model.JSONSuccessResponseWithCursor(c, http.StatusOK, "Files fetched successfully", count, nextCursor, result)
 
 }
 
@@ -554,17 +872,209 @@ func (a *AdminHandler) ListLogs(c *gin.Context) {
 	// Validate sort parameters using centralized helper
 	sortBy, order = helper.ValidateSortParams(sortBy, order, helper.AllowedLogSortFields)
 
-	count, result, err := a.fileService.ListLogs(c.Request.Context(), limit, offset, sortBy, order)
+	filter, err := logFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list logs", err.Error())
+		return
+	}
+	filter.AppID = c.Query("app_id")
+	filter.FileID = c.Query("file_id")
+
+	count, result, nextCursor, err := a.fileService.ListLogs(c.Request.Context(), filter, limit, offset, sortBy, order)
 	if err != nil {
 		switch {
-		case errors.Is(err, model.ErrInvalidInput):
+		case errors.Is(err, model.ErrInvalidInput),
+			errors.Is(err, model.ErrInvalidFilter),
+			errors.Is(err, model.ErrInvalidCursor):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list logs", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
-	model.JSONSuccessResponseWithCount(c, http.StatusOK, "Logs fetched successfully", count, result)
+	model.JSONSuccessResponseWithCursor(c, http.StatusOK, "Logs fetched successfully", count, nextCursor, result)
+}
+
+// exportContentTypes maps each audit export format to the content type and file extension it is served with
+var exportContentTypes = map[string][2]string{
+	constant.ExportFormatJSONL: {"application/x-ndjson", "jsonl"},
+	constant.ExportFormatCSV:   {"text/csv; charset=utf-8", "csv"},
+	constant.ExportFormatCEF:   {"text/plain; charset=utf-8", "cef"},
+}
+
+// ExportLogs streams the audit logs matching the listing filters as JSON Lines, CSV or CEF
+func (a *AdminHandler) ExportLogs(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	format := c.DefaultQuery("format", constant.ExportFormatJSONL)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to export logs", model.ErrInvalidExportFormat.Error())
+		return
+	}
+	filter, err := logFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to export logs", err.Error())
+		return
+	}
+	filter.AppID = c.Query("app_id")
+	filter.FileID = c.Query("file_id")
+
+	c.Header("Content-Type", contentType[0])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="crypsis-audit-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), contentType[1]))
+	c.Status(http.StatusOK)
+
+	err = a.auditService.ExportLogs(c.Request.Context(), filter, format, c.Writer)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		// The response is already under way, so the export can only be cut short
+		slog.Error("Failed to export logs", slog.Any("error", err))
+		c.Abort()
+		return
+	}
+	c.Writer.Header().Del("Content-Disposition")
+	c.Writer.Header().Del("Content-Type")
+	switch {
+	case errors.Is(err, model.ErrInvalidFilter), errors.Is(err, model.ErrInvalidExportFormat):
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to export logs", err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
+// VerifyLogs walks the audit chain and reports the first entry that was altered or removed
+func (a *AdminHandler) VerifyLogs(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	result, err := a.auditService.VerifyChain(c.Request.Context())
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		return
+	}
+	if !result.Valid {
+		model.JSONSuccessResponse(c, http.StatusOK, "Audit chain is broken", result)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Audit chain verified", result)
+}
+
+// VerifyErasureReceipt checks a receipt returned by a file purge against its signature and the audit trail
+func (a *AdminHandler) VerifyErasureReceipt(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var receipt model.ErasureReceipt
+	if err := c.ShouldBindJSON(&receipt); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to verify receipt", err.Error())
+		return
+	}
+
+	result, err := a.auditService.VerifyErasureReceipt(c.Request.Context(), receipt)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidInput):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to verify receipt", err.Error())
+		case errors.Is(err, model.ErrAuditSigningKeyMissing):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to verify receipt", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	if !result.Valid {
+		model.JSONSuccessResponse(c, http.StatusOK, "Erasure receipt is not valid", result)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Erasure receipt verified", result)
+}
+
+// PlaceLegalHold keeps a file from being deleted or overwritten until the hold is released
+func (a *AdminHandler) PlaceLegalHold(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.LegalHoldRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := a.validator.Struct(request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", model.WrapValidationError(err))
+		return
+	}
+
+	result, err := a.fileService.PlaceLegalHold(c.Request.Context(), c.Param("id"), request.Reason)
+	if err != nil {
+		fileHoldErrorResponse(c, "Failed to place legal hold", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Legal hold placed successfully", result)
+}
+
+// ReleaseLegalHold releases the legal hold on a file; the optional reason query is kept in the audit trail
+func (a *AdminHandler) ReleaseLegalHold(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	result, err := a.fileService.ReleaseLegalHold(c.Request.Context(), c.Param("id"), c.Query("reason"))
+	if err != nil {
+		fileHoldErrorResponse(c, "Failed to release legal hold", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Legal hold released successfully", result)
+}
+
+// SetFileRetention keeps a file from being deleted or overwritten before a date, which can only be extended
+func (a *AdminHandler) SetFileRetention(c *gin.Context) {
+	_, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.FileRetentionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := a.validator.Struct(request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", model.WrapValidationError(err))
+		return
+	}
+
+	result, err := a.fileService.SetFileRetention(c.Request.Context(), c.Param("id"), request.RetainUntil)
+	if err != nil {
+		fileHoldErrorResponse(c, "Failed to set file retention", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File retention set successfully", result)
+}
+
+// fileHoldErrorResponse maps legal hold and retention errors to HTTP responses
+func fileHoldErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrFileNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrInvalidInput), errors.Is(err, model.ErrInvalidRetention):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrRetentionShortened):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
 }
 
 func (a *AdminHandler) Rekey(c *gin.Context) {
@@ -5,13 +5,18 @@ import (
 	"crypsis-backend/internal/delivery/middlewere"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/services"
+	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,6 +26,16 @@ type contextKey string
 
 const requestContextKey contextKey = "request"
 
+// Headers carrying a customer-supplied key, modelled on S3 SSE-C
+const (
+	customerKeyAlgorithmHeader = "X-Crypsis-Customer-Algorithm"
+	customerKeyHeader          = "X-Crypsis-Customer-Key"
+	customerKeyMD5Header       = "X-Crypsis-Customer-Key-MD5"
+)
+
+// tagHeaderPrefix marks request headers carrying file tags, e.g. X-Crypsis-Meta-Project: apollo
+const tagHeaderPrefix = "X-Crypsis-Meta-"
+
 type ClientHandler struct {
 	clientService services.FileInterface
 }
@@ -31,21 +46,173 @@ func NewClientHandler(clietService services.FileInterface) *ClientHandler {
 	}
 }
 
+// customerKeyFromRequest reads a customer-supplied AES-256 key from the request headers.
+// The key is sent base64 encoded, optionally with the base64 MD5 of the key to catch transmission errors.
+// It returns nil when the request carries no key.
+func customerKeyFromRequest(c *gin.Context) ([]byte, error) {
+	encoded := c.GetHeader(customerKeyHeader)
+	if encoded == "" {
+		if c.GetHeader(customerKeyAlgorithmHeader) != "" || c.GetHeader(customerKeyMD5Header) != "" {
+			return nil, model.ErrInvalidCustomerKey
+		}
+		return nil, nil
+	}
+	if algorithm := c.GetHeader(customerKeyAlgorithmHeader); algorithm != "" && algorithm != "AES256" {
+		return nil, model.ErrInvalidCustomerKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, model.ErrInvalidCustomerKey
+	}
+	if keyMD5 := c.GetHeader(customerKeyMD5Header); keyMD5 != "" {
+		sum := md5.Sum(key)
+		if base64.StdEncoding.EncodeToString(sum[:]) != keyMD5 {
+			return nil, model.ErrInvalidCustomerKey
+		}
+	}
+	return key, nil
+}
+
+// tagsFromRequest reads file tags from X-Crypsis-Meta-* headers, lowercasing the key after the prefix.
+// It returns nil when the request carries no tag headers.
+func tagsFromRequest(c *gin.Context) (map[string]string, error) {
+	var tags map[string]string
+	for name, values := range c.Request.Header {
+		if !strings.HasPrefix(name, tagHeaderPrefix) {
+			continue
+		}
+		if len(values) != 1 {
+			return nil, model.ErrInvalidTag
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[strings.ToLower(strings.TrimPrefix(name, tagHeaderPrefix))] = values[0]
+	}
+	return tags, nil
+}
+
+// tagFiltersFromQuery parses repeated tag query parameters: "key" matches files having the tag,
+// "key:value" matches files whose tag has exactly that value.
+func tagFiltersFromQuery(c *gin.Context) []model.TagFilter {
+	var filters []model.TagFilter
+	for _, raw := range c.QueryArray("tag") {
+		key, value, hasValue := strings.Cut(raw, ":")
+		filter := model.TagFilter{Key: key}
+		if hasValue {
+			filter.Value = &value
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// fileFilterFromQuery reads the filters shared by the client and admin file listings.
+// Dates are RFC 3339 timestamps or plain dates (midnight UTC); sizes are in bytes.
+func fileFilterFromQuery(c *gin.Context) (model.FileFilter, error) {
+	filter := model.FileFilter{
+		NamePrefix: c.Query("name_prefix"),
+		MimeType:   c.Query("mime_type"),
+		Tags:       tagFiltersFromQuery(c),
+		Cursor:     c.Query("cursor"),
+	}
+
+	var err error
+	if filter.MinSize, err = int64Query(c, "min_size"); err != nil {
+		return filter, err
+	}
+	if filter.MaxSize, err = int64Query(c, "max_size"); err != nil {
+		return filter, err
+	}
+	for name, target := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+		"updated_after":  &filter.UpdatedAfter,
+		"updated_before": &filter.UpdatedBefore,
+	} {
+		if *target, err = timeQuery(c, name); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// logFilterFromQuery reads the filters shared by the admin log listing and the file history.
+func logFilterFromQuery(c *gin.Context) (model.LogFilter, error) {
+	filter := model.LogFilter{
+		ActorID:      c.Query("actor_id"),
+		ActorType:    c.Query("actor_type"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		Action:       c.Query("action"),
+		Outcome:      c.Query("outcome"),
+		TraceID:      c.Query("trace_id"),
+		IP:           c.Query("ip"),
+		Cursor:       c.Query("cursor"),
+	}
+
+	var err error
+	if filter.From, err = timeQuery(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = timeQuery(c, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func int64Query(c *gin.Context, name string) (*int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a whole number", model.ErrInvalidFilter, name)
+	}
+	return &value, nil
+}
+
+func timeQuery(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if value, err := time.Parse(layout, raw); err == nil {
+			return &value, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s must be an RFC 3339 timestamp or a date", model.ErrInvalidFilter, name)
+}
+
 func (ch *ClientHandler) UploadFile(c *gin.Context) {
 	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
 	if !isAllowed {
 		return
 	}
 
+	customerKey, err := customerKeyFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		return
+	}
+	tags, err := tagsFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		return
+	}
+
 	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
-	file, header, err := c.Request.FormFile("file")
+	file, err := helper.GetMultipartFilePart(c.Request, "file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file upload"})
 		return
 	}
 	defer file.Close()
 
-	result, err := ch.clientService.UploadFile(ctx, clientID, header.Filename, file)
+	result, err := ch.clientService.UploadFile(ctx, clientID, file.FileName(), file, model.UploadOptions{CustomerKey: customerKey, Folder: c.Query("folder"), Tags: tags})
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrAppNotFound):
@@ -59,13 +226,29 @@ func (ch *ClientHandler) UploadFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
 		case errors.Is(err, model.ErrHashCalculationFailed):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrInvalidCustomerKey):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrInvalidFolderPath):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrInvalidTag), errors.Is(err, model.ErrTooManyTags):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrPathConflict):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrFileTooLarge):
+			model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrStorageQuotaExceeded), errors.Is(err, model.ErrFileCountQuotaExceeded):
+			model.JSONErrorResponse(c, http.StatusTooManyRequests, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrFileUploadFailed):
+			model.JSONErrorResponse(c, http.StatusBadGateway, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrExternalKeyUnavailable):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to upload file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
 	model.JSONSuccessResponse(c, http.StatusOK, "File uploaded successfully", model.UploadFileResponse{
-		FileName: header.Filename,
+		FileName: file.FileName(),
 		FileID:   result,
 	})
 }
@@ -76,9 +259,19 @@ func (ch *ClientHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
+	customerKey, err := customerKeyFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
+		return
+	}
+
 	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
 	fileID := c.Param("id")
-	result, fileName, err := ch.clientService.DownloadFile(ctx, clientID, fileID)
+	result, err := ch.clientService.DownloadFile(ctx, clientID, fileID, model.DownloadOptions{
+		Range:       c.GetHeader("Range"),
+		IfRange:     c.GetHeader("If-Range"),
+		CustomerKey: customerKey,
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrAppNotFound):
@@ -98,15 +291,35 @@ func (ch *ClientHandler) DownloadFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
 		case errors.Is(err, model.ErrHashCalculationFailed):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
+		case errors.Is(err, model.ErrRangeNotSatisfiable):
+			model.JSONErrorResponse(c, http.StatusRequestedRangeNotSatisfiable, "Failed to download file", err.Error())
+		case errors.Is(err, model.ErrInvalidCustomerKey), errors.Is(err, model.ErrCustomerKeyRequired):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
+		case errors.Is(err, model.ErrCustomerKeyMismatch):
+			model.JSONErrorResponse(c, http.StatusForbidden, "Failed to download file", err.Error())
+		case errors.Is(err, model.ErrExternalKeyUnavailable):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to download file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
 
-	mimeType := http.DetectContentType(result)
-	c.Header("Content-Disposition", "attachment; filename="+fileName)
-	c.Data(http.StatusOK, mimeType, result)
+	defer result.Content.Close()
+
+	c.Header("Content-Disposition", "attachment; filename="+result.FileName)
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", result.ETag)
+	if !result.LastModified.IsZero() {
+		c.Header("Last-Modified", result.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	status := http.StatusOK
+	if result.Partial {
+		status = http.StatusPartialContent
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", result.RangeStart, result.RangeEnd, result.TotalSize))
+	}
+	c.DataFromReader(status, result.Size, result.MimeType, result.Content, nil)
 }
 
 func (ch *ClientHandler) EncryptFile(c *gin.Context) {
@@ -139,6 +352,8 @@ func (ch *ClientHandler) EncryptFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
 		case errors.Is(err, model.ErrHashCalculationFailed):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload file", err.Error())
+		case errors.Is(err, model.ErrExternalKeyUnavailable):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to upload file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
@@ -192,6 +407,8 @@ func (ch *ClientHandler) DecryptFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
 		case errors.Is(err, model.ErrHashCalculationFailed):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file", err.Error())
+		case errors.Is(err, model.ErrExternalKeyUnavailable):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to download file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
@@ -209,15 +426,26 @@ func (ch *ClientHandler) UpdateFile(c *gin.Context) {
 		return
 	}
 
+	customerKey, err := customerKeyFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
+		return
+	}
+	tags, err := tagsFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
+		return
+	}
+
 	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
-	file, header, err := c.Request.FormFile("file")
+	file, err := helper.GetMultipartFilePart(c.Request, "file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file upload"})
 		return
 	}
 	defer file.Close()
 	fileID := c.Param("id")
-	result, err := ch.clientService.UpdateFile(ctx, clientID, fileID, header.Filename, file)
+	result, err := ch.clientService.UpdateFile(ctx, clientID, fileID, file.FileName(), file, model.UploadOptions{CustomerKey: customerKey, Tags: tags})
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrAppNotFound):
@@ -227,6 +455,12 @@ func (ch *ClientHandler) UpdateFile(c *gin.Context) {
 
 		case errors.Is(err, model.ErrFileNotFound):
 			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrFileOnLegalHold), errors.Is(err, model.ErrFileUnderRetention):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrFileTooLarge):
+			model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrStorageQuotaExceeded):
+			model.JSONErrorResponse(c, http.StatusTooManyRequests, "Failed to update file", err.Error())
 		case errors.Is(err, model.ErrUnauthorizedFileAccess):
 			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to update file", err.Error())
 		case errors.Is(err, model.ErrInvalidInput):
@@ -237,13 +471,21 @@ func (ch *ClientHandler) UpdateFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
 		case errors.Is(err, model.ErrHashCalculationFailed):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrInvalidCustomerKey), errors.Is(err, model.ErrCustomerKeyRequired):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrInvalidTag), errors.Is(err, model.ErrTooManyTags):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrCustomerKeyMismatch):
+			model.JSONErrorResponse(c, http.StatusForbidden, "Failed to update file", err.Error())
+		case errors.Is(err, model.ErrExternalKeyUnavailable):
+			model.JSONErrorResponse(c, http.StatusServiceUnavailable, "Failed to update file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
 		return
 	}
 	model.JSONSuccessResponse(c, http.StatusOK, "File updated successfully", model.UploadFileResponse{
-		FileName: header.Filename,
+		FileName: file.FileName(),
 		FileID:   result,
 	})
 }
@@ -255,6 +497,16 @@ func (ch *ClientHandler) DeleteFile(c *gin.Context) {
 	}
 
 	fileID := c.Param("id")
+	mode := c.DefaultQuery("mode", constant.DeleteModeSoft)
+	if mode == constant.DeleteModePurge {
+		ch.purgeFile(c, clientID, fileID)
+		return
+	}
+	if mode != constant.DeleteModeSoft {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to delete file", model.ErrInvalidDeleteMode.Error())
+		return
+	}
+
 	err := ch.clientService.DeleteFile(c.Request.Context(), clientID, fileID)
 	if err != nil {
 		switch {
@@ -265,6 +517,8 @@ func (ch *ClientHandler) DeleteFile(c *gin.Context) {
 
 		case errors.Is(err, model.ErrFileNotFound):
 			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to delete file", err.Error())
+		case errors.Is(err, model.ErrFileOnLegalHold), errors.Is(err, model.ErrFileUnderRetention):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to delete file", err.Error())
 		case errors.Is(err, model.ErrUnauthorizedFileAccess):
 			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to delete file", err.Error())
 		case errors.Is(err, model.ErrInvalidInput):
@@ -277,6 +531,29 @@ func (ch *ClientHandler) DeleteFile(c *gin.Context) {
 	model.JSONSuccessResponse(c, http.StatusOK, "File deleted successfully", nil)
 }
 
+// purgeFile erases a file for good and answers with the signed erasure receipt
+func (ch *ClientHandler) purgeFile(c *gin.Context, clientID, fileID string) {
+	receipt, err := ch.clientService.PurgeFile(c.Request.Context(), clientID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound), errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to purge file", err.Error())
+		case errors.Is(err, model.ErrFileNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to purge file", err.Error())
+		case errors.Is(err, model.ErrFileOnLegalHold), errors.Is(err, model.ErrFileUnderRetention):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to purge file", err.Error())
+		case errors.Is(err, model.ErrInvalidInput):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to purge file", err.Error())
+		case errors.Is(err, model.ErrKeyDeletionFailed), errors.Is(err, model.ErrFileErasureIncomplete):
+			model.JSONErrorResponse(c, http.StatusBadGateway, "Failed to purge file", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File purged successfully", receipt)
+}
+
 func (ch *ClientHandler) RecoverFile(c *gin.Context) {
 	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
 	if !isAllowed {
@@ -300,6 +577,12 @@ func (ch *ClientHandler) RecoverFile(c *gin.Context) {
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to recover file", err.Error())
 		case errors.Is(err, model.ErrFileAlreadyExists):
 			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to recover file", err.Error())
+		case errors.Is(err, model.ErrPathConflict):
+			model.JSONErrorResponse(c, http.StatusConflict, "Failed to recover file", err.Error())
+		case errors.Is(err, model.ErrFileTooLarge):
+			model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, "Failed to recover file", err.Error())
+		case errors.Is(err, model.ErrStorageQuotaExceeded), errors.Is(err, model.ErrFileCountQuotaExceeded):
+			model.JSONErrorResponse(c, http.StatusTooManyRequests, "Failed to recover file", err.Error())
 		default:
 			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
 		}
@@ -341,6 +624,589 @@ func (ch *ClientHandler) MetaDataFile(c *gin.Context) {
 	model.JSONSuccessResponse(c, http.StatusOK, "Fetch file metadata successfully", result)
 }
 
+func (ch *ClientHandler) FileHistory(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	filter, err := logFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to get file history", err.Error())
+		return
+	}
+
+	count, result, nextCursor, err := ch.clientService.GetFileHistory(c.Request.Context(), clientID, c.Param("id"), filter, limit, offset, c.DefaultQuery("order", "desc"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound), errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to get file history", err.Error())
+		case errors.Is(err, model.ErrFileNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to get file history", err.Error())
+		case errors.Is(err, model.ErrInvalidInput),
+			errors.Is(err, model.ErrInvalidFilter),
+			errors.Is(err, model.ErrInvalidCursor):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to get file history", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponseWithCursor(c, http.StatusOK, "File history fetched successfully", count, nextCursor, result)
+}
+
+func (ch *ClientHandler) SetFileTags(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.FileTagsRequest
+	if err := c.BindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := ch.clientService.SetFileTags(c.Request.Context(), clientID, c.Param("id"), request.Tags)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound), errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to update file tags", err.Error())
+		case errors.Is(err, model.ErrFileNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to update file tags", err.Error())
+		case errors.Is(err, model.ErrInvalidInput),
+			errors.Is(err, model.ErrInvalidTag),
+			errors.Is(err, model.ErrTooManyTags):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to update file tags", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File tags updated successfully", model.FileTagsRequest{Tags: result})
+}
+
+// SetFileExpiry sets when a file expires and is soft deleted by the lifecycle scheduler; null clears the expiry
+func (ch *ClientHandler) SetFileExpiry(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.FileExpiryRequest
+	if err := c.BindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	result, err := ch.clientService.SetFileExpiry(c.Request.Context(), clientID, c.Param("id"), request.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound), errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to set file expiry", err.Error())
+		case errors.Is(err, model.ErrFileNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to set file expiry", err.Error())
+		case errors.Is(err, model.ErrInvalidInput), errors.Is(err, model.ErrInvalidExpiry):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to set file expiry", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File expiry updated successfully", result)
+}
+
+// GetUsage reports the bytes and files the calling app stores against its quota
+func (ch *ClientHandler) GetUsage(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	result, err := ch.clientService.GetUsage(c.Request.Context(), clientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound), errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to get usage", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Usage retrieved successfully", result)
+}
+
+func (ch *ClientHandler) FileStatus(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	fileID := c.Param("id")
+	result, err := ch.clientService.GetFileStatus(c.Request.Context(), clientID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrAppNotFound):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to get file status", err.Error())
+		case errors.Is(err, model.ErrAppNotActive):
+			model.JSONErrorResponse(c, http.StatusUnauthorized, "Failed to get file status", err.Error())
+
+		case errors.Is(err, model.ErrFileNotFound):
+			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to get file status", err.Error())
+		case errors.Is(err, model.ErrInvalidInput):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to get file status", err.Error())
+		default:
+			model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+		}
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Fetch file status successfully", result)
+}
+
+func (ch *ClientHandler) ListFileVersions(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	fileID := c.Param("id")
+	result, err := ch.clientService.ListFileVersions(c.Request.Context(), clientID, fileID)
+	if err != nil {
+		fileVersionErrorResponse(c, "Failed to list file versions", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Fetch file versions successfully", result)
+}
+
+func (ch *ClientHandler) DownloadFileVersion(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	fileID := c.Param("id")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file version", model.ErrInvalidInput.Error())
+		return
+	}
+
+	customerKey, err := customerKeyFromRequest(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to download file version", err.Error())
+		return
+	}
+
+	result, err := ch.clientService.DownloadFileVersion(ctx, clientID, fileID, version, customerKey)
+	if err != nil {
+		fileVersionErrorResponse(c, "Failed to download file version", err)
+		return
+	}
+
+	defer result.Content.Close()
+
+	c.Header("Content-Disposition", "attachment; filename="+result.FileName)
+	c.Header("ETag", result.ETag)
+	c.DataFromReader(http.StatusOK, result.Size, result.MimeType, result.Content, nil)
+}
+
+func (ch *ClientHandler) PromoteFileVersion(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	fileID := c.Param("id")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to promote file version", model.ErrInvalidInput.Error())
+		return
+	}
+
+	result, err := ch.clientService.PromoteFileVersion(ctx, clientID, fileID, version)
+	if err != nil {
+		fileVersionErrorResponse(c, "Failed to promote file version", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File version promoted successfully", result)
+}
+
+// fileVersionErrorResponse maps version history errors to HTTP responses
+func fileVersionErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrAppNotActive):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+
+	case errors.Is(err, model.ErrFileNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrFileVersionNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrFileVersionIsCurrent):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, model.ErrFileOnLegalHold), errors.Is(err, model.ErrFileUnderRetention):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, model.ErrFileTooLarge):
+		model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, message, err.Error())
+	case errors.Is(err, model.ErrStorageQuotaExceeded):
+		model.JSONErrorResponse(c, http.StatusTooManyRequests, message, err.Error())
+	case errors.Is(err, model.ErrInvalidInput):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrInvalidCustomerKey),
+		errors.Is(err, model.ErrCustomerKeyRequired):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrCustomerKeyMismatch):
+		model.JSONErrorResponse(c, http.StatusForbidden, message, err.Error())
+	case errors.Is(err, model.ErrHashNotMatch):
+		model.JSONErrorResponse(c, http.StatusUnprocessableEntity, message, err.Error())
+	case errors.Is(err, model.ErrFileUploadFailed):
+		model.JSONErrorResponse(c, http.StatusBadGateway, message, err.Error())
+	case errors.Is(err, model.ErrExternalKeyUnavailable):
+		model.JSONErrorResponse(c, http.StatusServiceUnavailable, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
+func (ch *ClientHandler) CreateShareLink(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.CreateShareLink(ctx, clientID, c.Param("id"), request)
+	if err != nil {
+		shareLinkErrorResponse(c, "Failed to create share link", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusCreated, "Share link created successfully", result)
+}
+
+func (ch *ClientHandler) ListShareLinks(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.ListShareLinks(ctx, clientID, c.Param("id"))
+	if err != nil {
+		shareLinkErrorResponse(c, "Failed to list share links", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Share links retrieved successfully", result)
+}
+
+func (ch *ClientHandler) RevokeShareLink(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	if err := ch.clientService.RevokeShareLink(ctx, clientID, c.Param("id"), c.Param("linkId")); err != nil {
+		shareLinkErrorResponse(c, "Failed to revoke share link", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Share link revoked successfully", nil)
+}
+
+// DownloadSharedFile serves the public /s/:token route. The passphrase, when the link has one, is sent in the
+// X-Share-Passphrase header or as a "passphrase" form field so it never appears in the URL.
+func (ch *ClientHandler) DownloadSharedFile(c *gin.Context) {
+	passphrase := c.GetHeader("X-Share-Passphrase")
+	if passphrase == "" {
+		passphrase = c.PostForm("passphrase")
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.DownloadSharedFile(ctx, c.Param("token"), passphrase, c.ClientIP())
+	if err != nil {
+		shareLinkErrorResponse(c, "Failed to download shared file", err)
+		return
+	}
+
+	defer result.Content.Close()
+
+	c.Header("Content-Disposition", "attachment; filename="+result.FileName)
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, result.Size, result.MimeType, result.Content, nil)
+}
+
+// shareLinkErrorResponse maps share link errors to HTTP responses
+func shareLinkErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrAppNotActive):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+
+	case errors.Is(err, model.ErrFileNotFound),
+		errors.Is(err, model.ErrShareLinkNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrShareLinkExpired),
+		errors.Is(err, model.ErrShareLinkRevoked),
+		errors.Is(err, model.ErrShareLinkExhausted):
+		model.JSONErrorResponse(c, http.StatusGone, message, err.Error())
+	case errors.Is(err, model.ErrShareLinkPassphrase):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrShareLinkIPNotAllowed):
+		model.JSONErrorResponse(c, http.StatusForbidden, message, err.Error())
+	case errors.Is(err, model.ErrShareLinkTooManyAttempts):
+		model.JSONErrorResponse(c, http.StatusTooManyRequests, message, err.Error())
+	case errors.Is(err, model.ErrInvalidInput),
+		errors.Is(err, model.ErrInvalidShareLinkDuration),
+		errors.Is(err, model.ErrInvalidShareLinkCIDR),
+		errors.Is(err, model.ErrCustomerKeyRequired):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrHashNotMatch):
+		model.JSONErrorResponse(c, http.StatusUnprocessableEntity, message, err.Error())
+	case errors.Is(err, model.ErrExternalKeyUnavailable):
+		model.JSONErrorResponse(c, http.StatusServiceUnavailable, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
+func (ch *ClientHandler) CreateUploadToken(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.CreateUploadTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.CreateUploadToken(ctx, clientID, request)
+	if err != nil {
+		uploadTokenErrorResponse(c, "Failed to create upload token", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusCreated, "Upload token created successfully", result)
+}
+
+func (ch *ClientHandler) ListUploadTokens(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.ListUploadTokens(ctx, clientID)
+	if err != nil {
+		uploadTokenErrorResponse(c, "Failed to list upload tokens", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Upload tokens retrieved successfully", result)
+}
+
+func (ch *ClientHandler) RevokeUploadToken(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	if err := ch.clientService.RevokeUploadToken(ctx, clientID, c.Param("id")); err != nil {
+		uploadTokenErrorResponse(c, "Failed to revoke upload token", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Upload token revoked successfully", nil)
+}
+
+// UploadWithToken serves the public /u/:token route, accepting a multipart "file" field like the regular upload.
+func (ch *ClientHandler) UploadWithToken(c *gin.Context) {
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	file, err := helper.GetMultipartFilePart(c.Request, "file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file upload"})
+		return
+	}
+	defer file.Close()
+
+	result, err := ch.clientService.UploadWithToken(ctx, c.Param("token"), file.FileName(), c.ClientIP(), file)
+	if err != nil {
+		uploadTokenErrorResponse(c, "Failed to upload file", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File uploaded successfully", result)
+}
+
+// uploadTokenErrorResponse maps upload token errors to HTTP responses
+func uploadTokenErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrAppNotActive):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+
+	case errors.Is(err, model.ErrUploadTokenNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrUploadTokenExpired),
+		errors.Is(err, model.ErrUploadTokenRevoked):
+		model.JSONErrorResponse(c, http.StatusGone, message, err.Error())
+	case errors.Is(err, model.ErrUploadTooLarge),
+		errors.Is(err, model.ErrFileTooLarge):
+		model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, message, err.Error())
+	case errors.Is(err, model.ErrStorageQuotaExceeded),
+		errors.Is(err, model.ErrFileCountQuotaExceeded):
+		model.JSONErrorResponse(c, http.StatusTooManyRequests, message, err.Error())
+	case errors.Is(err, model.ErrMimeTypeNotAllowed):
+		model.JSONErrorResponse(c, http.StatusUnsupportedMediaType, message, err.Error())
+	case errors.Is(err, model.ErrPathConflict):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, model.ErrInvalidInput),
+		errors.Is(err, model.ErrInvalidUploadTokenDuration),
+		errors.Is(err, model.ErrInvalidFolderPath),
+		errors.Is(err, model.ErrFailedToReadFile),
+		errors.Is(err, model.ErrFileIsEmpty),
+		errors.Is(err, model.ErrHashCalculationFailed):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrFileUploadFailed):
+		model.JSONErrorResponse(c, http.StatusBadGateway, message, err.Error())
+	case errors.Is(err, model.ErrExternalKeyUnavailable):
+		model.JSONErrorResponse(c, http.StatusServiceUnavailable, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
+func (ch *ClientHandler) GetPath(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.GetPath(ctx, clientID, c.Param("path"))
+	if err != nil {
+		folderErrorResponse(c, "Failed to get path", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Path retrieved successfully", result)
+}
+
+func (ch *ClientHandler) CreateFolder(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.CreateFolder(ctx, clientID, c.Param("path"))
+	if err != nil {
+		folderErrorResponse(c, "Failed to create folder", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusCreated, "Folder created successfully", result)
+}
+
+func (ch *ClientHandler) MovePath(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.MovePathRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.MovePath(ctx, clientID, c.Param("path"), request.Destination)
+	if err != nil {
+		folderErrorResponse(c, "Failed to move path", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Path moved successfully", result)
+}
+
+// DeletePath deletes a file or an empty folder; pass recursive=true to delete a folder with everything below it.
+func (ch *ClientHandler) DeletePath(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	if err := ch.clientService.DeletePath(ctx, clientID, c.Param("path"), c.Query("recursive") == "true"); err != nil {
+		folderErrorResponse(c, "Failed to delete path", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Path deleted successfully", nil)
+}
+
+func (ch *ClientHandler) PlaceFile(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.PlaceFileRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.PlaceFile(ctx, clientID, c.Param("id"), request.Path)
+	if err != nil {
+		folderErrorResponse(c, "Failed to place file", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File placed successfully", result)
+}
+
+// folderErrorResponse maps folder tree errors to HTTP responses
+func folderErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrAppNotActive):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+
+	case errors.Is(err, model.ErrPathNotFound),
+		errors.Is(err, model.ErrFolderNotFound),
+		errors.Is(err, model.ErrFileNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrPathConflict),
+		errors.Is(err, model.ErrFolderNotEmpty),
+		errors.Is(err, model.ErrFileOnLegalHold),
+		errors.Is(err, model.ErrFileUnderRetention):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, model.ErrInvalidInput),
+		errors.Is(err, model.ErrInvalidFolderPath),
+		errors.Is(err, model.ErrInvalidMove):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
+
 func (ch *ClientHandler) ListFiles(c *gin.Context) {
 	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
 	if !isAllowed {
@@ -365,9 +1231,19 @@ func (ch *ClientHandler) ListFiles(c *gin.Context) {
 	// Validate sort parameters using centralized helper
 	sortBy, order = helper.ValidateSortParams(sortBy, order, helper.AllowedFileSortFields)
 
-	count, result, err := ch.clientService.ListFiles(c.Request.Context(), clientID, limit, offset, sortBy, order)
+	filter, err := fileFilterFromQuery(c)
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
+		return
+	}
+
+	count, result, nextCursor, err := ch.clientService.ListFiles(c.Request.Context(), clientID, filter, limit, offset, sortBy, order)
 	if err != nil {
 		switch {
+		case errors.Is(err, model.ErrInvalidTag),
+			errors.Is(err, model.ErrInvalidFilter),
+			errors.Is(err, model.ErrInvalidCursor):
+			model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to list files", err.Error())
 		case errors.Is(err, model.ErrFileNotFound):
 			model.JSONErrorResponse(c, http.StatusNotFound, "Failed to get file metadata", err.Error())
 		case errors.Is(err, model.ErrUnauthorizedFileAccess):
@@ -382,7 +1258,128 @@ func (ch *ClientHandler) ListFiles(c *gin.Context) {
 		return
 	}
 	model.JSONSuccessResponse(c, http.StatusOK, "Fetch files metadata successfull", &model.ListFilesResponse{
-		Count: count,
-		Files: *result,
+		Count:      count,
+		Files:      *result,
+		NextCursor: nextCursor,
 	})
 }
+
+func (ch *ClientHandler) InitiateUpload(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	var request model.InitiateUploadRequest
+	if err := c.BindJSON(&request); err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.InitiateUpload(ctx, clientID, filepath.Base(request.FileName))
+	if err != nil {
+		uploadSessionErrorResponse(c, "Failed to start upload", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusCreated, "Upload started successfully", result)
+}
+
+func (ch *ClientHandler) UploadPart(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	partNumber, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		model.JSONErrorResponse(c, http.StatusBadRequest, "Failed to upload part", model.ErrInvalidPartNumber.Error())
+		return
+	}
+	defer c.Request.Body.Close()
+
+	result, err := ch.clientService.UploadPart(c.Request.Context(), clientID, c.Param("id"), partNumber, c.Request.Body)
+	if err != nil {
+		uploadSessionErrorResponse(c, "Failed to upload part", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Part uploaded successfully", result)
+}
+
+func (ch *ClientHandler) CompleteUpload(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), requestContextKey, c.Request)
+	result, err := ch.clientService.CompleteUpload(ctx, clientID, c.Param("id"))
+	if err != nil {
+		uploadSessionErrorResponse(c, "Failed to complete upload", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "File uploaded successfully", result)
+}
+
+func (ch *ClientHandler) AbortUpload(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	if err := ch.clientService.AbortUpload(c.Request.Context(), clientID, c.Param("id")); err != nil {
+		uploadSessionErrorResponse(c, "Failed to abort upload", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Upload aborted successfully", nil)
+}
+
+func (ch *ClientHandler) GetUploadSession(c *gin.Context) {
+	clientID, isAllowed := middlewere.GetUserIDFromToken(c)
+	if !isAllowed {
+		return
+	}
+
+	result, err := ch.clientService.GetUploadSession(c.Request.Context(), clientID, c.Param("id"))
+	if err != nil {
+		uploadSessionErrorResponse(c, "Failed to get upload", err)
+		return
+	}
+	model.JSONSuccessResponse(c, http.StatusOK, "Fetch upload successfully", result)
+}
+
+// uploadSessionErrorResponse maps the errors shared by the resumable upload endpoints to HTTP responses
+func uploadSessionErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrAppNotFound):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, model.ErrAppNotActive):
+		model.JSONErrorResponse(c, http.StatusUnauthorized, message, err.Error())
+
+	case errors.Is(err, model.ErrUploadSessionNotFound):
+		model.JSONErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, model.ErrUploadSessionClosed):
+		model.JSONErrorResponse(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, model.ErrUploadSessionExpired):
+		model.JSONErrorResponse(c, http.StatusGone, message, err.Error())
+	case errors.Is(err, model.ErrUploadPartTooLarge),
+		errors.Is(err, model.ErrFileTooLarge):
+		model.JSONErrorResponse(c, http.StatusRequestEntityTooLarge, message, err.Error())
+	case errors.Is(err, model.ErrStorageQuotaExceeded),
+		errors.Is(err, model.ErrFileCountQuotaExceeded):
+		model.JSONErrorResponse(c, http.StatusTooManyRequests, message, err.Error())
+	case errors.Is(err, model.ErrInvalidPartNumber),
+		errors.Is(err, model.ErrUploadPartTooSmall),
+		errors.Is(err, model.ErrUploadPartsMissing),
+		errors.Is(err, model.ErrInvalidInput),
+		errors.Is(err, model.ErrFailedToReadFile),
+		errors.Is(err, model.ErrFileIsEmpty):
+		model.JSONErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, model.ErrFileUploadFailed):
+		model.JSONErrorResponse(c, http.StatusBadGateway, message, err.Error())
+	case errors.Is(err, model.ErrExternalKeyUnavailable):
+		model.JSONErrorResponse(c, http.StatusServiceUnavailable, message, err.Error())
+	default:
+		model.JSONErrorResponse(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+}
@@ -59,6 +59,9 @@ func (c *RouterConfig) Setup() {
 	// - Error tracking
 	c.Router.Use(middlewere.OpenTelemetryMiddleware(c.Tracer, c.Meter))
 
+	// Carry the client address, user agent and trace ID of each request to the audit trail
+	c.Router.Use(middlewere.AuditContextMiddleware())
+
 	// Set up route groups
 	c.setupPublic()
 	c.setupClient()
@@ -69,6 +72,11 @@ func (c *RouterConfig) Setup() {
 func (c *RouterConfig) setupPublic() {
 	group := c.Router.Group("/api")
 	group.POST("/admin/login", c.AdminHandler.Login)
+
+	// Share links and upload tokens carry their own signed token instead of an OAuth2 token
+	c.Router.GET("/s/:token", c.ClientHandler.DownloadSharedFile)
+	c.Router.POST("/s/:token", c.ClientHandler.DownloadSharedFile)
+	c.Router.POST("/u/:token", c.ClientHandler.UploadWithToken)
 }
 
 func (c *RouterConfig) setupClient() {
@@ -81,9 +89,40 @@ func (c *RouterConfig) setupClient() {
 	group.GET("/files/:id/download", c.ClientHandler.DownloadFile)
 	group.PUT("/files/:id/update", c.ClientHandler.UpdateFile)
 	group.DELETE("/files/:id/delete", c.ClientHandler.DeleteFile)
+	group.DELETE("/files/:id", c.ClientHandler.DeleteFile)
 
 	group.GET("/files/list", c.ClientHandler.ListFiles)
 	group.GET("/files/:id/metadata", c.ClientHandler.MetaDataFile)
+	group.PUT("/files/:id/tags", c.ClientHandler.SetFileTags)
+	group.GET("/files/:id/status", c.ClientHandler.FileStatus)
+	group.GET("/files/:id/history", c.ClientHandler.FileHistory)
+	group.GET("/files/:id/versions", c.ClientHandler.ListFileVersions)
+	group.GET("/files/:id/versions/:version/download", c.ClientHandler.DownloadFileVersion)
+	group.POST("/files/:id/versions/:version/promote", c.ClientHandler.PromoteFileVersion)
+	group.POST("/files/:id/share", c.ClientHandler.CreateShareLink)
+	group.GET("/files/:id/shares", c.ClientHandler.ListShareLinks)
+	group.DELETE("/files/:id/shares/:linkId", c.ClientHandler.RevokeShareLink)
+	group.PUT("/files/:id/path", c.ClientHandler.PlaceFile)
+	group.PUT("/files/:id/expiry", c.ClientHandler.SetFileExpiry)
+	group.GET("/usage", c.ClientHandler.GetUsage)
+
+	// Folder tree, addressed by path
+	group.GET("/fs/*path", c.ClientHandler.GetPath)
+	group.POST("/fs/*path", c.ClientHandler.CreateFolder)
+	group.PATCH("/fs/*path", c.ClientHandler.MovePath)
+	group.DELETE("/fs/*path", c.ClientHandler.DeletePath)
+
+	// Pre-signed upload tokens
+	group.POST("/upload-tokens", c.ClientHandler.CreateUploadToken)
+	group.GET("/upload-tokens", c.ClientHandler.ListUploadTokens)
+	group.DELETE("/upload-tokens/:id", c.ClientHandler.RevokeUploadToken)
+
+	// Resumable uploads
+	group.POST("/uploads", c.ClientHandler.InitiateUpload)
+	group.GET("/uploads/:id", c.ClientHandler.GetUploadSession)
+	group.PUT("/uploads/:id/parts/:n", c.ClientHandler.UploadPart)
+	group.POST("/uploads/:id/complete", c.ClientHandler.CompleteUpload)
+	group.DELETE("/uploads/:id", c.ClientHandler.AbortUpload)
 
 	group.POST("/files/encrypt", c.ClientHandler.EncryptFile)
 	group.POST("/files/decrypt", c.ClientHandler.DecryptFile)
@@ -109,15 +148,34 @@ func (c *RouterConfig) setupAdmin() {
 	group.POST("/admin/apps", c.AdminHandler.AddApp)
 	group.GET("/admin/apps", c.AdminHandler.ListApps)
 	group.GET("/admin/apps/:id", c.AdminHandler.GetApp)
+	group.PUT("/admin/apps/:id", c.AdminHandler.UpdateApp)
 	group.DELETE("/admin/apps/:id", c.AdminHandler.DeleteApp)
 	group.POST("/admin/apps/:id/recover", c.AdminHandler.RecoverApp)
 	group.PUT("/admin/apps/:id/rotate-secret", c.AdminHandler.RotateSecret)
+	group.PUT("/admin/apps/:id/key-provider", c.AdminHandler.SetKeyProvider)
+	group.PUT("/admin/apps/:id/lifecycle", c.AdminHandler.SetLifecyclePolicy)
+	group.PUT("/admin/apps/:id/quota", c.AdminHandler.SetQuota)
+	group.GET("/admin/apps/:id/usage", c.AdminHandler.GetUsage)
+	group.PUT("/admin/apps/:id/config", c.AdminHandler.SetClientConfig)
+	group.GET("/admin/apps/:id/config", c.AdminHandler.GetClientConfig)
+	group.GET("/admin/apps/:id/bucket", c.AdminHandler.GetAppBucket)
+	group.POST("/admin/apps/:id/bucket/migrate", c.AdminHandler.MigrateAppBucket)
 
 	// File Management
 	group.GET("/admin/files", c.AdminHandler.ListFiles)
 	group.GET("/admin/apps/:id/files", c.AdminHandler.ListFilesByAppId)
 	group.GET("/admin/logs", c.AdminHandler.ListLogs)
+	group.GET("/admin/logs/verify", c.AdminHandler.VerifyLogs)
+	group.GET("/admin/logs/export", c.AdminHandler.ExportLogs)
+	group.POST("/admin/erasure-receipts/verify", c.AdminHandler.VerifyErasureReceipt)
 	group.POST("/admin/files/re-key", c.AdminHandler.Rekey)
+	group.PUT("/admin/files/:id/legal-hold", c.AdminHandler.PlaceLegalHold)
+	group.DELETE("/admin/files/:id/legal-hold", c.AdminHandler.ReleaseLegalHold)
+	group.PUT("/admin/files/:id/retention", c.AdminHandler.SetFileRetention)
+
+	// Retention and lifecycle rules
+	group.GET("/admin/lifecycle/report", c.AdminHandler.LifecycleReport)
+	group.POST("/admin/lifecycle/run", c.AdminHandler.RunLifecycle)
 }
 
 // setupDebug sets up pprof debugging endpoints
@@ -0,0 +1,32 @@
+package middlewere
+
+import (
+	"crypsis-backend/internal/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditContextMiddleware stores the client address, user agent and trace ID of each request in its context,
+// where services read them when they write audit entries. It must run after OpenTelemetryMiddleware so the
+// request already carries its span.
+//
+// The token middlewares fill in the caller once the token is validated.
+func AuditContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := &helper.RequestInfo{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			TraceID:   GetTraceID(c),
+		}
+		c.Request = c.Request.WithContext(helper.WithRequestInfo(c.Request.Context(), info))
+		c.Next()
+	}
+}
+
+// setAuditActor records the authenticated caller on the request info set by AuditContextMiddleware
+func setAuditActor(c *gin.Context, actorID, actorType string) {
+	if info := helper.RequestInfoFromContext(c.Request.Context()); info != nil {
+		info.ActorID = actorID
+		info.ActorType = actorType
+	}
+}
@@ -2,6 +2,7 @@ package middlewere
 
 import (
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
 	"encoding/json"
 	"fmt"
@@ -30,6 +31,7 @@ func TokenMiddleware(config TokenMiddlewareConfig) gin.HandlerFunc {
 		if !ok {
 			return
 		}
+		setAuditActor(c, tokenInfo.Sub, constant.ActorTypeClient)
 		c.Set("tokenInfo", tokenInfo)
 		c.Next()
 	}
@@ -47,6 +49,7 @@ func AdminTokenMiddleware(config TokenMiddlewareConfig) gin.HandlerFunc {
 			return
 		}
 
+		setAuditActor(c, tokenInfo.Sub, constant.ActorTypeAdmin)
 		c.Set("tokenInfo", tokenInfo)
 		c.Next()
 	}
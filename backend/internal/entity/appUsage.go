@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// AppUsage holds the running totals of what an app stores, kept in the same transactions that add, delete or
+// restore its files. Only committed, live files count: pending uploads and soft-deleted files do not.
+type AppUsage struct {
+	AppID       string    `gorm:"type:varchar(36);primaryKey"`
+	StoredBytes int64     `gorm:"not null;default:0"` // plaintext size of the current version of every file
+	FileCount   int64     `gorm:"not null;default:0"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (AppUsage) TableName() string {
+	return "app_usage"
+}
@@ -7,16 +7,31 @@ import (
 )
 
 type Apps struct {
-	ID           string         `gorm:"type:varchar(36);not null;primaryKey"`
-	Name         string         `gorm:"index;not null"`
-	ClientID     string         `gorm:"type:varchar(255);not null;index"`
-	ClientSecret string         `gorm:"type:varchar(255);not null"`
-	IsActive     bool           `gorm:"not null"`
-	Uri          string         `gorm:"type:text; null"`
-	RedirectUri  string         `gorm:"type:text; null"`
-	CreatedAt    time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt    time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ID                   string         `gorm:"type:varchar(36);not null;primaryKey"`
+	Name                 string         `gorm:"index;not null"`
+	ClientID             string         `gorm:"type:varchar(255);not null;index"`
+	ClientSecret         string         `gorm:"type:varchar(255);not null"`
+	IsActive             bool           `gorm:"not null"`
+	Uri                  string         `gorm:"type:text; null"`
+	RedirectUri          string         `gorm:"type:text; null"`
+	KeyProvider          string         `gorm:"type:varchar(32);not null;default:'internal'"` // who holds the keys wrapping this app's file keys
+	KeyEndpoint          string         `gorm:"type:text;null"`                               // base URL of the app's external key manager
+	KeyID                string         `gorm:"type:varchar(255);null"`                       // key the external key manager wraps file keys with
+	KeyCACert            string         `gorm:"type:text;null"`                               // PEM CA certificate trusted for the external key manager
+	FileTTLDays          int            `gorm:"not null;default:0"`                           // files older than this expire unless they carry their own expiry; 0 keeps them
+	DeletedRetentionDays int            `gorm:"not null;default:0"`                           // soft-deleted files older than this are purged; 0 keeps them
+	MaxStorageBytes      int64          `gorm:"not null;default:0"`                           // total bytes the app may store; 0 is unlimited
+	MaxFiles             int64          `gorm:"not null;default:0"`                           // files the app may store; 0 is unlimited
+	MaxFileSize          int64          `gorm:"not null;default:0"`                           // largest single file the app may store; 0 is unlimited
+	BucketName           string         `gorm:"type:varchar(63);not null;default:''"`         // bucket new files are stored in; empty for the server default
+	HashMethod           string         `gorm:"type:varchar(32);not null;default:''"`         // checksum algorithm for new files; empty for the server default
+	EncryptionMethod     string         `gorm:"type:varchar(32);not null;default:''"`         // encryption algorithm for new files; empty for the server default
+	HashEncryptedFile    *bool          `gorm:"null"`                                         // whether ciphertexts are hashed too; nil for the server default
+	Replicate            *bool          `gorm:"null"`                                         // whether new objects are copied to the storage replicas; nil for the server default
+	BucketMigrationAt    *time.Time     `gorm:"index"`                                        // when moving the app's files into its bucket was asked for; nil when no move is pending
+	CreatedAt            time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt            time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt            gorm.DeletedAt `gorm:"index"`
 }
 
 func (Apps) TableName() string {
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// AuditCheckpoints are signed snapshots of the head of the audit chain.
+// A retention checkpoint anchors the chain where old entries were cut off: the first remaining entry must
+// carry LogHash as its previous hash.
+// Checkpoints are numbered in the order they are signed and each one signs the signature of the one before it,
+// so removing a checkpoint leaves a gap in the sequence. A retention checkpoint records the first sequence number
+// that survived its cut.
+type AuditCheckpoints struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	Sequence      uint      `gorm:"not null;default:0;index"`              // position of the checkpoint in signing order
+	PrevSignature string    `gorm:"type:varchar(128);not null;default:''"` // signature of the checkpoint signed before it
+	FirstSequence uint      `gorm:"not null;default:0"`                    // retention only: oldest checkpoint kept by the cut
+	LogID         uint      `gorm:"not null;index"`                        // last entry covered by the checkpoint
+	LogHash       string    `gorm:"type:varchar(64);not null"`             // hash of that entry
+	Kind          string    `gorm:"type:varchar(16);not null;index"`       // periodic or retention
+	KeyID         string    `gorm:"type:varchar(64);not null"`             // identifies the key the checkpoint was signed with
+	Signature     string    `gorm:"type:varchar(128);not null"`            // base64 HMAC-SHA256 over the fields above and CreatedAt
+	CreatedAt     time.Time `gorm:"not null"`
+}
+
+func (AuditCheckpoints) TableName() string {
+	return "audit_checkpoints"
+}
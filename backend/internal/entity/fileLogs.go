@@ -1,25 +1,86 @@
 package entity
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/netip"
 	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 )
 
+// FileLogs is one entry of the audit trail. Despite the name it records actions on every kind of resource:
+// files, apps, admin accounts and keys, each identified by ResourceType and ResourceID.
 type FileLogs struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement"` // Auto-incrementing ID
-	ActorID   string    `gorm:"type:text;not null"`
-	ActorType string    `gorm:"type:text;not null;check:actor_type IN ('user', 'client', 'system','admin')"`
-	FileID    string    `gorm:"not null;index"` // Removed type:uuid to support SQLite
-	Action    string    `gorm:"type:text;not null;check:action IN ('upload', 'download', 'update', 'delete', 'recover','encrypt', 'decrypt','re-key')"`
-	Timestamp time.Time `gorm:"autoCreateTime"` // Changed to autoCreateTime for SQLite compatibility
-	IP        string    `gorm:"type:text"`      // Changed from inet to text for SQLite
-	UserAgent string    `gorm:"type:text"`      // Client info
-	Metadata  JSONB     `gorm:"type:text"`      // Changed from jsonb to text for SQLite, will serialize to JSON
+	ID           uint      `gorm:"primaryKey;autoIncrement"` // Auto-incrementing ID
+	ActorID      string    `gorm:"type:text;not null"`
+	ActorType    string    `gorm:"type:text;not null;check:actor_type IN ('user', 'client', 'system','admin')"`
+	FileID       string    `gorm:"not null;index"` // Removed type:uuid to support SQLite
+	ResourceType string    `gorm:"type:text;not null;default:'file';index;check:resource_type IN ('file', 'app', 'admin', 'key')"`
+	ResourceID   string    `gorm:"type:text;not null;default:'';index"` // Equals FileID for file resources
+	Action       string    `gorm:"type:text;not null;check:action IN ('upload', 'download', 'update', 'delete', 'recover','encrypt', 'decrypt','re-key', 'create', 'login', 'logout', 'rotate-secret', 'password-change', 'legal-hold', 'legal-hold-release', 'retention', 'migrate')"`
+	Outcome      string    `gorm:"type:text;not null;default:'success';check:outcome IN ('success', 'failure')"`
+	TraceID      string    `gorm:"type:varchar(32);not null;default:'';index"` // OpenTelemetry trace of the request that acted
+	Timestamp    time.Time `gorm:"autoCreateTime"`                             // Changed to autoCreateTime for SQLite compatibility
+	IP           string    `gorm:"type:text"`                                  // Changed from inet to text for SQLite
+	UserAgent    string    `gorm:"type:text"`                                  // Client info
+	Metadata     JSONB     `gorm:"type:text"`                                  // Changed from jsonb to text for SQLite, will serialize to JSON
+	PrevHash     string    `gorm:"type:varchar(64);not null;default:''"`       // Hash of the entry before this one in the audit chain
+	Hash         string    `gorm:"type:varchar(64);not null;default:'';index"` // Hash over PrevHash and the content of this entry
+}
+
+// fileLogChainContent is the canonical form of an entry hashed into the audit chain.
+// Timestamps are hashed in UTC at microsecond precision, which every supported database keeps.
+type fileLogChainContent struct {
+	PrevHash     string `json:"prev_hash"`
+	ActorID      string `json:"actor_id"`
+	ActorType    string `json:"actor_type"`
+	FileID       string `json:"file_id"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	Action       string `json:"action"`
+	Outcome      string `json:"outcome"`
+	TraceID      string `json:"trace_id"`
+	Timestamp    string `json:"timestamp"`
+	IP           string `json:"ip"`
+	UserAgent    string `json:"user_agent"`
+	Metadata     any    `json:"metadata"`
+}
+
+// ComputeHash returns the audit chain hash of the entry from PrevHash and its content.
+// IP and metadata are hashed in the form they read back from the database, so an entry hashes the same
+// when it is written and when it is verified.
+func (f *FileLogs) ComputeHash() string {
+	ip := f.IP
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		ip = addr.String()
+	}
+	var metadata any
+	if raw, err := json.Marshal(f.Metadata); err == nil {
+		_ = json.Unmarshal(raw, &metadata)
+	}
+
+	content, _ := json.Marshal(fileLogChainContent{
+		PrevHash:     f.PrevHash,
+		ActorID:      f.ActorID,
+		ActorType:    f.ActorType,
+		FileID:       f.FileID,
+		ResourceType: f.ResourceType,
+		ResourceID:   f.ResourceID,
+		Action:       f.Action,
+		Outcome:      f.Outcome,
+		TraceID:      f.TraceID,
+		Timestamp:    f.Timestamp.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		IP:           ip,
+		UserAgent:    f.UserAgent,
+		Metadata:     metadata,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
 
 // BeforeCreate hook to set timestamp if not already set
@@ -46,11 +107,13 @@ func (f FileLogs) GormDBDataType(db *gorm.DB, field *schema.Field) string {
 	case "postgres":
 		switch field.Name {
 		case "FileID":
-			return "uuid"
+			// Entries about apps, admins and keys carry no file
+			return "text"
 		case "Timestamp":
 			return "timestamptz"
 		case "IP":
-			return "inet"
+			// Entries written outside a request carry no client address
+			return "text"
 		case "Metadata":
 			return "jsonb"
 		}
@@ -73,11 +136,18 @@ type JSONB map[string]interface{}
 
 // Scan allows JSONB to read from a PostgreSQL jsonb column
 func (j *JSONB) Scan(value interface{}) error {
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, j)
+	case string:
+		// SQLite hands text columns back as strings
+		return json.Unmarshal([]byte(v), j)
+	default:
 		return fmt.Errorf("failed to scan JSONB: expected []byte, got %T", value)
 	}
-	return json.Unmarshal(bytes, j)
 }
 
 // Value allows JSONB to write into a PostgreSQL jsonb column
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// FileTags holds the custom key/value metadata of a file, one row per key, so files can be filtered by tag.
+type FileTags struct {
+	FileID    string    `gorm:"type:varchar(36);primaryKey"`
+	Key       string    `gorm:"type:varchar(64);primaryKey;index:idx_file_tags_key_value,priority:1"`
+	Value     string    `gorm:"type:varchar(256);not null;index:idx_file_tags_key_value,priority:2"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (FileTags) TableName() string {
+	return "file_tags"
+}
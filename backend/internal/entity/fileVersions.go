@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// FileVersions keeps everything needed to decrypt each committed version of a file.
+// The ciphertexts themselves are the versions of the object in storage, addressed by VersionID.
+type FileVersions struct {
+	ID             string    `gorm:"type:varchar(36);not null;primaryKey"`
+	FileID         string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_file_versions_file_version"`
+	Version        int       `gorm:"not null;uniqueIndex:idx_file_versions_file_version"`
+	VersionID      string    `gorm:"type:varchar(64);null"` // storage version holding this ciphertext
+	Name           string    `gorm:"not null"`
+	MimeType       string    `gorm:"type:varchar(255);not null"`
+	Size           int64     `gorm:"not null"`
+	Hash           string    `gorm:"type:varchar(256);not null"`
+	EncHash        string    `gorm:"type:varchar(256);null"`
+	KeyUID         string    `gorm:"type:varchar(256);index;null"`
+	EncKey         string    `gorm:"type:text;not null"`
+	KeyFingerprint string    `gorm:"type:varchar(128);null"`
+	KeyAlgo        string    `gorm:"type:varchar(64);not null"`
+	HashAlgo       string    `gorm:"type:varchar(32);not null;default:''"`
+	Format         string    `gorm:"type:varchar(32);not null;default:'aead'"`
+	HeaderVersion  int       `gorm:"not null;default:0"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+func (FileVersions) TableName() string {
+	return "file_versions"
+}
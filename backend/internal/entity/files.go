@@ -7,17 +7,23 @@ import (
 )
 
 type Files struct {
-	ID         string         `gorm:"type:varchar(36);not null;primaryKey"`
-	Name       string         `gorm:"index;not null"`
-	AppID      string         `gorm:"type:varchar(36);index"`
-	UserID     string         `gorm:"type:varchar(36);index"`
-	MimeType   string         `gorm:"type:varchar(255);not null"`
-	Size       int64          `gorm:"not null"`
-	BucketName string         `gorm:"type:varchar(255)"`
-	Location   string         `gorm:"type:text; null"`
-	CreatedAt  time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	ID          string         `gorm:"type:varchar(36);not null;primaryKey"`
+	Name        string         `gorm:"index;not null"`
+	AppID       string         `gorm:"type:varchar(36);index;uniqueIndex:idx_files_app_path,priority:1"`
+	UserID      string         `gorm:"type:varchar(36);index"`
+	MimeType    string         `gorm:"type:varchar(255);not null"`
+	Size        int64          `gorm:"not null"`
+	BucketName  string         `gorm:"type:varchar(255)"`
+	Location    string         `gorm:"type:text; null"`
+	Status      string         `gorm:"type:varchar(16);not null;default:'stored';index"`
+	FolderID    *string        `gorm:"type:varchar(36);index"`
+	Path        string         `gorm:"type:varchar(1024);not null;default:'';uniqueIndex:idx_files_app_path,priority:2,where:path <> '' AND deleted_at IS NULL"` // empty until the file is placed in the folder tree
+	ExpiresAt   *time.Time     `gorm:"index"`                                                                                                                    // the file is soft deleted by the lifecycle scheduler once this passes
+	LegalHold   bool           `gorm:"not null;default:false"`                                                                                                   // the file cannot be deleted or overwritten until the hold is released
+	RetainUntil *time.Time     `gorm:"index"`                                                                                                                    // the file cannot be deleted or overwritten before this passes
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
 
 func (Files) TableName() string {
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// Folders arrange the files of an app into a tree. Path is the folder's absolute path, e.g. "/reports/2024",
+// and is kept in step with the parent chain so that a folder can be found by path in a single lookup.
+type Folders struct {
+	ID        string    `gorm:"type:varchar(36);not null;primaryKey"`
+	AppID     string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_folders_app_path,priority:1"`
+	ParentID  *string   `gorm:"type:varchar(36);index"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	Path      string    `gorm:"type:varchar(1024);not null;uniqueIndex:idx_folders_app_path,priority:2"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (Folders) TableName() string {
+	return "folders"
+}
@@ -7,17 +7,21 @@ import (
 )
 
 type Metadata struct {
-	ID        string         `gorm:"type:varchar(36);not null;primaryKey"`
-	FileID    string         `gorm:"type:varchar(36);index;not null;constraint:OnDelete:CASCADE"`
-	Hash      string         `gorm:"type:varchar(256);not null"`
-	EncHash   string         `gorm:"type:varchar(256);index;null"`
-	KeyUID    string         `gorm:"type:varchar(256);index;null"`
-	EncKey    string         `gorm:"type:text;not null"`
-	KeyAlgo   string         `gorm:"type:varchar(64);not null"`
-	VersionID string         `gorm:"type:varchar(64);null"`
-	CreatedAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID             string         `gorm:"type:varchar(36);not null;primaryKey"`
+	FileID         string         `gorm:"type:varchar(36);index;not null;constraint:OnDelete:CASCADE"`
+	Hash           string         `gorm:"type:varchar(256);not null"`
+	EncHash        string         `gorm:"type:varchar(256);index;null"`
+	KeyUID         string         `gorm:"type:varchar(256);index;null"`
+	EncKey         string         `gorm:"type:text;not null"`
+	KeyFingerprint string         `gorm:"type:varchar(128);null"` // set instead of KeyUID and EncKey when the client supplies the key
+	KeyAlgo        string         `gorm:"type:varchar(64);not null"`
+	HashAlgo       string         `gorm:"type:varchar(32);not null;default:''"` // algorithm Hash and EncHash were computed with
+	Format         string         `gorm:"type:varchar(32);not null;default:'aead'"`
+	HeaderVersion  int            `gorm:"not null;default:0"` // 0 marks headerless ciphertexts not bound to their file and app
+	VersionID      string         `gorm:"type:varchar(64);null"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
 
 	// Associations
 	File Files `gorm:"foreignKey:FileID;references:ID;constraint:OnDelete:CASCADE"`
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// ObjectReplicas tracks one object version written to the primary storage backend on one of its replicas.
+// A pending row is the repair queue entry for a replica write or delete that has not happened yet; a synced row
+// maps the primary version to the version the replica holds, which reads fail over to.
+type ObjectReplicas struct {
+	ID               string    `gorm:"type:varchar(36);not null;primaryKey"`
+	Replica          string    `gorm:"type:varchar(64);not null;index:idx_object_replicas_object,priority:1"`
+	BucketName       string    `gorm:"type:varchar(255);not null;index:idx_object_replicas_object,priority:2"`
+	ObjectName       string    `gorm:"type:varchar(255);not null;index:idx_object_replicas_object,priority:3"`
+	VersionID        string    `gorm:"type:varchar(64);not null;default:''"` // version on the primary; empty for the latest version
+	ReplicaVersionID string    `gorm:"type:varchar(64);not null;default:''"` // version on the replica once written
+	Operation        string    `gorm:"type:varchar(16);not null;check:operation IN ('copy', 'delete', 'mark-deleted', 'restore')"`
+	Status           string    `gorm:"type:varchar(16);not null;index;check:status IN ('pending', 'synced')"`
+	Attempts         int       `gorm:"not null;default:0"`
+	NextAttemptAt    time.Time `gorm:"index"`
+	LastError        string    `gorm:"type:text;null"`
+	CreatedAt        time.Time `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime"`
+}
+
+func (ObjectReplicas) TableName() string {
+	return "object_replicas"
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// ShareLinks records a time-limited public link to a file.
+// The token handed out is signed over the link ID and expiry; the link itself is never stored in the URL.
+type ShareLinks struct {
+	ID             string     `gorm:"type:varchar(36);not null;primaryKey"`
+	AppID          string     `gorm:"type:varchar(36);index;not null"`
+	FileID         string     `gorm:"type:varchar(36);index;not null"`
+	ExpiresAt      time.Time  `gorm:"index;not null"`
+	MaxDownloads   int        `gorm:"not null;default:0"` // 0 allows unlimited downloads until expiry
+	Downloads      int        `gorm:"not null;default:0"`
+	PassphraseHash string     `gorm:"type:text;null"`
+	AllowedCIDR    string     `gorm:"type:varchar(64);null"`
+	RevokedAt      *time.Time `gorm:"null"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+}
+
+func (ShareLinks) TableName() string {
+	return "share_links"
+}
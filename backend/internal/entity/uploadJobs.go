@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// UploadJobs is the outbox entry that tracks an object written to storage until its database rows are committed.
+// Payload holds the serialized file and metadata rows so a failed commit can be retried without re-uploading.
+type UploadJobs struct {
+	ID            string    `gorm:"type:varchar(36);not null;primaryKey"`
+	FileID        string    `gorm:"type:varchar(36);index;not null"`
+	AppID         string    `gorm:"type:varchar(36);index"`
+	Operation     string    `gorm:"type:varchar(16);not null;check:operation IN ('upload', 'update')"`
+	Status        string    `gorm:"type:varchar(16);not null;index;check:status IN ('pending', 'compensating', 'done', 'failed')"`
+	BucketName    string    `gorm:"type:varchar(255);not null"`
+	ObjectName    string    `gorm:"type:varchar(255);not null"`
+	VersionID     string    `gorm:"type:varchar(64);null"`
+	BaseVersionID string    `gorm:"type:varchar(64);null"` // version current before an update, kept when compensating
+	Payload       string    `gorm:"type:text;null"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string    `gorm:"type:text;null"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+func (UploadJobs) TableName() string {
+	return "upload_jobs"
+}
@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+// UploadSessions tracks a resumable upload whose parts are sent in separate requests.
+// The file key is generated when the session starts so every part is sealed with the same key.
+type UploadSessions struct {
+	ID              string    `gorm:"type:varchar(36);not null;primaryKey"`
+	AppID           string    `gorm:"type:varchar(36);index;not null"`
+	FileID          string    `gorm:"type:varchar(36);uniqueIndex;not null"`
+	FileName        string    `gorm:"not null"`
+	MimeType        string    `gorm:"type:varchar(255);null"`
+	BucketName      string    `gorm:"type:varchar(255);not null"`
+	ObjectName      string    `gorm:"type:varchar(255);not null"`
+	StorageUploadID string    `gorm:"type:text;not null"`
+	KeyUID          string    `gorm:"type:varchar(256);null"`
+	EncKey          string    `gorm:"type:text;null"`
+	HeaderVersion   int       `gorm:"not null;default:0"`                   // associated data the parts are bound to, see Metadata.HeaderVersion
+	HashAlgo        string    `gorm:"type:varchar(32);not null;default:''"` // algorithm every part is hashed with, fixed when the session starts
+	Status          string    `gorm:"type:varchar(16);not null;index;check:status IN ('open', 'completing', 'completed', 'aborted', 'expired', 'failed')"`
+	ExpiresAt       time.Time `gorm:"index"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+func (UploadSessions) TableName() string {
+	return "upload_sessions"
+}
+
+// UploadParts records one encrypted part of an upload session.
+// Parts are kept after completion because each one is an independent ciphertext that downloads decrypt in order.
+type UploadParts struct {
+	SessionID  string    `gorm:"type:varchar(36);primaryKey"`
+	PartNumber int       `gorm:"primaryKey;autoIncrement:false"`
+	FileID     string    `gorm:"type:varchar(36);index;not null"`
+	ETag       string    `gorm:"type:varchar(128);not null"`
+	Size       int64     `gorm:"not null"`
+	EncSize    int64     `gorm:"not null"`
+	Hash       string    `gorm:"type:varchar(256);not null"`
+	EncHash    string    `gorm:"type:varchar(256);null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (UploadParts) TableName() string {
+	return "upload_parts"
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// UploadTokens records a pre-signed token that lets a party without OAuth2 credentials upload files for an app.
+// Files uploaded with a token are owned by the issuing app.
+type UploadTokens struct {
+	ID               string     `gorm:"type:varchar(36);not null;primaryKey"`
+	AppID            string     `gorm:"type:varchar(36);index;not null"`
+	MaxSize          int64      `gorm:"not null"`
+	AllowedMimeTypes string     `gorm:"type:text;null"` // comma separated, empty allows any type
+	Folder           string     `gorm:"type:text;null"`
+	ExpiresAt        time.Time  `gorm:"index;not null"`
+	RevokedAt        *time.Time `gorm:"null"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime"`
+}
+
+func (UploadTokens) TableName() string {
+	return "upload_tokens"
+}
@@ -72,6 +72,29 @@ func GetFileBytesFromMultipart(file multipart.File) ([]byte, int64, string, erro
 	return buf, size, mimeType, nil
 }
 
+// GetMultipartFilePart returns the first file part named fieldName from a multipart request without buffering it
+// in memory or on disk. The part must be fully consumed before the request body is read any further.
+func GetMultipartFilePart(r *http.Request, fieldName string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("file not found in form")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == fieldName && part.FileName() != "" {
+			return part, nil
+		}
+		_ = part.Close()
+	}
+}
+
 // GetFileBytesFromFile reads all bytes from an os.File and returns the bytes and size.
 func GetFileBytesFromFile(file *os.File) ([]byte, int64, error) {
 	buf, err := io.ReadAll(file)
@@ -4,15 +4,43 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
+// RequestInfo describes the request an action is taken in, for the audit trail.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	TraceID   string
+	ActorID   string // the authenticated caller, empty before authentication
+	ActorType string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info.
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info carried by ctx, or nil outside a request.
+func RequestInfoFromContext(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
 // GetClientIP extracts the client's IP address from the request.
 func GetClientIP(ctx context.Context) string {
+	if info := RequestInfoFromContext(ctx); info != nil {
+		return info.IP
+	}
 	req, ok := ctx.Value("request").(*http.Request)
 	if !ok || req == nil {
 		return ""
@@ -41,6 +69,9 @@ func GetClientIP(ctx context.Context) string {
 
 // GetUserAgent extracts the User-Agent from the request.
 func GetUserAgent(ctx context.Context) string {
+	if info := RequestInfoFromContext(ctx); info != nil {
+		return info.UserAgent
+	}
 	req, ok := ctx.Value("request").(*http.Request)
 	if !ok || req == nil {
 		return ""
@@ -50,35 +81,23 @@ func GetUserAgent(ctx context.Context) string {
 
 // Create HTTPS Client
 func CreateHTTPSClient(certFile, keyFile, caCertFile string) *http.Client {
-	// Load client certificate and key
-	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		log.Fatalf("Failed to load client certificate and key: %v", err)
-		return nil
-	}
-
-	// Load system CA pool
-	caCertPool, err := x509.SystemCertPool()
-	if err != nil {
-		caCertPool = x509.NewCertPool() // Fallback to empty pool
-	}
-
 	// Load CA certificate if provided
+	var caCert []byte
 	if caCertFile != "" {
-		caCert, err := os.ReadFile(caCertFile)
+		var err error
+		caCert, err = os.ReadFile(caCertFile)
 		if err != nil {
 			log.Fatalf("Failed to read CA certificate: %v", err)
 			return nil
 		}
-		caCertPool.AppendCertsFromPEM(caCert)
 	}
 
-	// Configure TLS
-	tlsConfig := &tls.Config{
-		Certificates:       []tls.Certificate{clientCert},
-		RootCAs:            caCertPool,
-		InsecureSkipVerify: true,
+	tlsConfig, err := clientTLSConfig(certFile, keyFile, caCert)
+	if err != nil {
+		log.Fatalf("Failed to configure HTTPS client: %v", err)
+		return nil
 	}
+	tlsConfig.InsecureSkipVerify = true
 
 	// Create and return HTTPS client
 	return &http.Client{
@@ -87,3 +106,46 @@ func CreateHTTPSClient(certFile, keyFile, caCertFile string) *http.Client {
 		},
 	}
 }
+
+// CreateMTLSClient creates an HTTPS client that presents a client certificate and verifies the server
+// against the system roots plus caCertPEM. Unlike CreateHTTPSClient it is built at runtime from
+// per-tenant configuration, so it reports errors instead of exiting.
+func CreateMTLSClient(certFile, keyFile string, caCertPEM []byte, timeout time.Duration) (*http.Client, error) {
+	if len(caCertPEM) > 0 && !x509.NewCertPool().AppendCertsFromPEM(caCertPEM) {
+		return nil, errors.New("no valid CA certificate found in PEM data")
+	}
+
+	tlsConfig, err := clientTLSConfig(certFile, keyFile, caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.MinVersion = tls.VersionTLS12
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// clientTLSConfig loads a client certificate and a root pool of the system CAs plus the given PEM certificates
+func clientTLSConfig(certFile, keyFile string, caCertPEM []byte) (*tls.Config, error) {
+	// Load client certificate and key
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate and key: %w", err)
+	}
+
+	// Load system CA pool
+	caCertPool, err := x509.SystemCertPool()
+	if err != nil {
+		caCertPool = x509.NewCertPool() // Fallback to empty pool
+	}
+	caCertPool.AppendCertsFromPEM(caCertPEM)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      caCertPool,
+	}, nil
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
@@ -23,3 +25,63 @@ type AdminResponse struct {
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
+
+// AuditVerifyResponse reports the result of walking the audit chain from its retention anchor to its head.
+type AuditVerifyResponse struct {
+	Valid              bool        `json:"valid"`
+	EntriesChecked     int64       `json:"entries_checked"`
+	UnchainedEntries   int64       `json:"unchained_entries"` // entries written before the chain was introduced
+	CheckpointsChecked int         `json:"checkpoints_checked"`
+	SignaturesVerified bool        `json:"signatures_verified"` // false when no signing key is configured
+	AnchorLogID        uint        `json:"anchor_log_id,omitempty"`
+	HeadLogID          uint        `json:"head_log_id,omitempty"`
+	FirstBreak         *AuditBreak `json:"first_break,omitempty"`
+}
+
+// AuditBreak is the first point at which the audit chain fails to verify.
+type AuditBreak struct {
+	LogID        uint   `json:"log_id"`
+	CheckpointID uint   `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// ErasureReceiptVerifyResponse reports whether an erasure receipt is authentic and matches the audit log.
+type ErasureReceiptVerifyResponse struct {
+	Valid             bool   `json:"valid"`
+	SignatureVerified bool   `json:"signature_verified"`
+	AuditEntry        string `json:"audit_entry"` // "matches", "mismatch" or "missing" when retention removed it
+	Reason            string `json:"reason,omitempty"`
+}
+
+// LifecycleReport describes one pass of the lifecycle rules, or what the pass would do when it is a dry run.
+// Counts cover the whole pass; at most a limited number of actions are listed.
+type LifecycleReport struct {
+	DryRun       bool              `json:"dry_run"`
+	StartedAt    time.Time         `json:"started_at"`
+	FinishedAt   time.Time         `json:"finished_at"`
+	FilesExpired int               `json:"files_expired"`
+	FilesPurged  int               `json:"files_purged"`
+	LogsRemoved  int64             `json:"logs_removed"`
+	Failures     int               `json:"failures"`
+	Actions      []LifecycleAction `json:"actions"`
+	Truncated    bool              `json:"truncated"` // more actions were taken than are listed
+}
+
+// LifecycleAction is a file the lifecycle scheduler expired or purged, or failed to
+type LifecycleAction struct {
+	Action string `json:"action"` // expire or purge
+	Rule   string `json:"rule"`   // file_expiry, app_ttl or deleted_retention
+	AppID  string `json:"app_id"`
+	FileID string `json:"file_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditCheckpointResponse describes a signed checkpoint over the audit chain.
+type AuditCheckpointResponse struct {
+	ID        uint      `json:"id"`
+	LogID     uint      `json:"log_id"`
+	LogHash   string    `json:"log_hash"`
+	Kind      string    `json:"kind"`
+	KeyID     string    `json:"key_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type AddAppRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Uri         string `json:"uri" validate:"required,url"`
@@ -16,6 +18,91 @@ type ApplicationRequest struct {
 	Scopes       []string `json:"scopes" gorm:"size:255;unique;not null" validate:"required"`
 }
 
+// KeyProviderRequest selects who wraps an app's file keys.
+// The external provider needs an https endpoint and the ID of the tenant's key; ca_cert is an optional PEM CA.
+type KeyProviderRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	Endpoint string `json:"endpoint"`
+	KeyID    string `json:"key_id"`
+	CACert   string `json:"ca_cert"`
+}
+
+type KeyProviderResponse struct {
+	AppID     string `json:"app_id"`
+	Provider  string `json:"provider"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	HasCACert bool   `json:"has_ca_cert"`
+}
+
+// LifecyclePolicyRequest sets the lifecycle rules of an app; a field left out keeps its current value and 0 turns
+// the rule off.
+type LifecyclePolicyRequest struct {
+	FileTTLDays          *int `json:"file_ttl_days"`
+	DeletedRetentionDays *int `json:"deleted_retention_days"`
+}
+
+type LifecyclePolicyResponse struct {
+	AppID                string `json:"app_id"`
+	FileTTLDays          int    `json:"file_ttl_days"`
+	DeletedRetentionDays int    `json:"deleted_retention_days"`
+}
+
+// AppQuotaRequest sets the storage limits of an app; a field left out keeps its current value and 0 removes the limit.
+type AppQuotaRequest struct {
+	MaxStorageBytes *int64 `json:"max_storage_bytes"`
+	MaxFiles        *int64 `json:"max_files"`
+	MaxFileSize     *int64 `json:"max_file_size"`
+}
+
+// ClientConfigRequest sets the storage and crypto settings of an app's new files. A field left out keeps its
+// current value; an empty string returns a setting to the server default.
+type ClientConfigRequest struct {
+	BucketName        *string `json:"bucket_name"`
+	HashMethod        *string `json:"hash_method"`
+	EncryptionMethod  *string `json:"encryption_method"`
+	HashEncryptedFile *bool   `json:"hash_encrypted_file"`
+	Replicate         *bool   `json:"replicate"`
+}
+
+// ClientConfigResponse reports the settings of an app; an empty or null setting uses the server default.
+type ClientConfigResponse struct {
+	AppID             string `json:"app_id"`
+	BucketName        string `json:"bucket_name"`
+	HashMethod        string `json:"hash_method"`
+	EncryptionMethod  string `json:"encryption_method"`
+	HashEncryptedFile *bool  `json:"hash_encrypted_file"`
+	Replicate         *bool  `json:"replicate"`
+}
+
+// AppBucketResponse reports the bucket an app stores new files in and how far moving its older files there has got.
+type AppBucketResponse struct {
+	AppID                string     `json:"app_id"`
+	BucketName           string     `json:"bucket_name"`
+	Dedicated            bool       `json:"dedicated"` // the bucket was provisioned for this app alone
+	MigrationPending     bool       `json:"migration_pending"`
+	MigrationRequestedAt *time.Time `json:"migration_requested_at,omitempty"`
+	FilesOutsideBucket   int64      `json:"files_outside_bucket"` // stored files still in another bucket, held files included
+}
+
+// BucketMigrationReport counts what one pass of the bucket migration worker did.
+type BucketMigrationReport struct {
+	Apps       int `json:"apps"`
+	FilesMoved int `json:"files_moved"`
+	Failures   int `json:"failures"`
+}
+
+// AppUsageResponse reports what an app stores against its limits; a limit of 0 is unlimited.
+type AppUsageResponse struct {
+	AppID           string    `json:"app_id"`
+	StoredBytes     int64     `json:"stored_bytes"`
+	FileCount       int64     `json:"file_count"`
+	MaxStorageBytes int64     `json:"max_storage_bytes"`
+	MaxFiles        int64     `json:"max_files"`
+	MaxFileSize     int64     `json:"max_file_size"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
 type AppResponse struct {
 	ID       string `json:"id"`
 	AppName  string `json:"app_name"`
@@ -30,6 +117,7 @@ type AppDetailResponse struct {
 	IsActive     bool   `json:"is_active"`
 	Uri          string `gorm:"type:text; null"`
 	RedirectUri  string `gorm:"type:text; null"`
+	BucketName   string `json:"bucket_name"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
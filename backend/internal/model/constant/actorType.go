@@ -11,6 +11,18 @@ const (
 	ActionTypeRecover  ActionType = "recover"
 	ActionTypeReKey    ActionType = "re-key"
 	ActionTypeUpdate   ActionType = "update"
+
+	ActionTypeCreate         ActionType = "create"
+	ActionTypeLogin          ActionType = "login"
+	ActionTypeLogout         ActionType = "logout"
+	ActionTypeRotateSecret   ActionType = "rotate-secret"
+	ActionTypePasswordChange ActionType = "password-change"
+
+	ActionTypeLegalHold        ActionType = "legal-hold"
+	ActionTypeLegalHoldRelease ActionType = "legal-hold-release"
+	ActionTypeRetention        ActionType = "retention"
+
+	ActionTypeMigrate ActionType = "migrate"
 )
 
 const (
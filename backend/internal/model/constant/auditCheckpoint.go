@@ -0,0 +1,7 @@
+package constant
+
+// Kinds of signed checkpoints over the audit chain.
+const (
+	CheckpointKindPeriodic  string = "periodic"  // taken on a schedule over the head of the chain
+	CheckpointKindRetention string = "retention" // anchors the chain where retention removed older entries
+)
@@ -0,0 +1,15 @@
+package constant
+
+// Kinds of resource an audit entry is about.
+const (
+	ResourceTypeFile  string = "file"
+	ResourceTypeApp   string = "app"
+	ResourceTypeAdmin string = "admin"
+	ResourceTypeKey   string = "key"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess string = "success"
+	OutcomeFailure string = "failure"
+)
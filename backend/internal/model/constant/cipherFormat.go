@@ -0,0 +1,8 @@
+package constant
+
+// Ciphertext formats recorded on Metadata.Format so downloads know how an object was sealed.
+const (
+	CipherFormatAEAD      string = "aead"      // legacy single-shot Tink AEAD, whole file in one ciphertext
+	CipherFormatStream    string = "stream"    // Tink streaming AEAD (AES256-GCM-HKDF), authenticated per segment
+	CipherFormatMultipart string = "multipart" // one streaming AEAD ciphertext per upload part, concatenated in part order
+)
@@ -0,0 +1,7 @@
+package constant
+
+// Modes a client can delete a file in.
+const (
+	DeleteModeSoft  string = "soft"  // marks the file deleted; an admin can recover it
+	DeleteModePurge string = "purge" // destroys the keys, every stored version and the records for good
+)
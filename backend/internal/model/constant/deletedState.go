@@ -0,0 +1,8 @@
+package constant
+
+// Deleted states an admin can filter file listings by.
+const (
+	DeletedStateExclude string = "exclude"
+	DeletedStateInclude string = "include"
+	DeletedStateOnly    string = "only"
+)
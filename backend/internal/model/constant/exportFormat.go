@@ -0,0 +1,8 @@
+package constant
+
+// Formats audit logs can be exported in.
+const (
+	ExportFormatJSONL string = "jsonl" // one JSON object per line
+	ExportFormatCSV   string = "csv"   // header row followed by one row per entry
+	ExportFormatCEF   string = "cef"   // ArcSight Common Event Format, one event per line
+)
@@ -0,0 +1,7 @@
+package constant
+
+// Key providers recorded on Apps.KeyProvider, selecting who wraps an app's file keys.
+const (
+	KeyProviderInternal string = "internal" // KEK or the configured KMS
+	KeyProviderExternal string = "external" // tenant-run key manager called over mTLS (hold your own key)
+)
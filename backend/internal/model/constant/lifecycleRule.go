@@ -0,0 +1,14 @@
+package constant
+
+// Lifecycle rules the scheduler applies to files, as recorded in its reports and audit entries.
+const (
+	LifecycleRuleFileExpiry       string = "file_expiry"       // the file's own expiry passed
+	LifecycleRuleAppTTL           string = "app_ttl"           // the file outlived its app's file TTL
+	LifecycleRuleDeletedRetention string = "deleted_retention" // the file stayed soft deleted past its app's retention
+)
+
+// Actions the lifecycle scheduler takes on a file.
+const (
+	LifecycleActionExpire string = "expire" // soft delete
+	LifecycleActionPurge  string = "purge"  // crypto-shred and remove for good
+)
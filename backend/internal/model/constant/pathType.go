@@ -0,0 +1,7 @@
+package constant
+
+// Entry types reported for paths in the folder tree.
+const (
+	PathTypeFolder string = "folder"
+	PathTypeFile   string = "file"
+)
@@ -0,0 +1,46 @@
+package constant
+
+// File states tracked on Files.Status; only stored files are durable in storage and database.
+const (
+	FileStatusPending string = "pending"
+	FileStatusStored  string = "stored"
+	FileStatusFailed  string = "failed"
+)
+
+// Upload job states tracked on UploadJobs.Status.
+const (
+	JobStatusPending      string = "pending"      // object written or being written, database commit outstanding
+	JobStatusCompensating string = "compensating" // commit abandoned, orphaned object must be removed
+	JobStatusDone         string = "done"
+	JobStatusFailed       string = "failed"
+)
+
+// Operations recorded on UploadJobs.Operation.
+const (
+	JobOperationUpload string = "upload"
+	JobOperationUpdate string = "update"
+)
+
+// Upload session states tracked on UploadSessions.Status.
+const (
+	SessionStatusOpen       string = "open"       // accepting parts
+	SessionStatusCompleting string = "completing" // parts are being assembled into the object
+	SessionStatusCompleted  string = "completed"
+	SessionStatusAborted    string = "aborted"
+	SessionStatusExpired    string = "expired"
+	SessionStatusFailed     string = "failed"
+)
+
+// Object replica states tracked on ObjectReplicas.Status.
+const (
+	ReplicaStatusPending string = "pending" // the replica has not caught up with the primary yet
+	ReplicaStatusSynced  string = "synced"
+)
+
+// Operations recorded on ObjectReplicas.Operation.
+const (
+	ReplicaOperationCopy        string = "copy"         // write the primary version to the replica
+	ReplicaOperationDelete      string = "delete"       // remove the replica version for good
+	ReplicaOperationMarkDeleted string = "mark-deleted" // hide the file on the replica behind a delete marker
+	ReplicaOperationRestore     string = "restore"      // make the replica version current again
+)
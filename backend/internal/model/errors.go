@@ -38,6 +38,94 @@ var (
 	ErrFailedToReadFile       = errors.New("failed to read file")
 	ErrFileUploadFailed       = errors.New("file upload failed")
 	ErrFileDownloadFailed     = errors.New("file download failed")
+	ErrFileNotStored          = errors.New("file is not stored yet")
+	ErrUploadJobNotFound      = errors.New("upload job not found")
+	ErrRangeNotSatisfiable    = errors.New("requested range not satisfiable")
+	ErrFileVersionNotFound    = errors.New("file version not found")
+	ErrFileVersionIsCurrent   = errors.New("file version is already current")
+	ErrInvalidDeleteMode      = errors.New("invalid delete mode: use soft or purge")
+	ErrFileErasureIncomplete  = errors.New("file versions remain in storage after erasure")
+	ErrInvalidExpiry          = errors.New("expires_at must be in the future")
+)
+
+// Legal Hold Error
+var (
+	ErrFileOnLegalHold       = errors.New("file is under legal hold")
+	ErrFileUnderRetention    = errors.New("file is under retention")
+	ErrInvalidRetention      = errors.New("retain_until must be in the future")
+	ErrRetentionShortened    = errors.New("retention can only be extended")
+	ErrObjectLockUnavailable = errors.New("object lock is not enabled on the storage bucket")
+	ErrObjectLocked          = errors.New("object version is locked by a legal hold or retention")
+)
+
+// Upload Session Error
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadSessionClosed   = errors.New("upload session is no longer open")
+	ErrUploadSessionExpired  = errors.New("upload session has expired")
+	ErrInvalidPartNumber     = errors.New("invalid part number")
+	ErrUploadPartTooLarge    = errors.New("upload part is too large")
+	ErrUploadPartTooSmall    = errors.New("upload part is too small")
+	ErrUploadPartsMissing    = errors.New("upload parts are missing")
+	ErrMultipartNotFound     = errors.New("multipart upload not found in storage")
+)
+
+// Share Link Error
+var (
+	ErrShareLinkNotFound        = errors.New("share link not found")
+	ErrShareLinkExpired         = errors.New("share link has expired")
+	ErrShareLinkRevoked         = errors.New("share link has been revoked")
+	ErrShareLinkExhausted       = errors.New("share link download limit reached")
+	ErrShareLinkPassphrase      = errors.New("share link passphrase is missing or incorrect")
+	ErrShareLinkIPNotAllowed    = errors.New("share link cannot be used from this address")
+	ErrShareLinkTooManyAttempts = errors.New("too many failed share link attempts, try again later")
+	ErrInvalidShareLinkDuration = errors.New("share link expiry is out of range")
+	ErrInvalidShareLinkCIDR     = errors.New("invalid share link IP range")
+)
+
+// Upload Token Error
+var (
+	ErrUploadTokenNotFound        = errors.New("upload token not found")
+	ErrUploadTokenExpired         = errors.New("upload token has expired")
+	ErrUploadTokenRevoked         = errors.New("upload token has been revoked")
+	ErrUploadTooLarge             = errors.New("file exceeds the upload token size limit")
+	ErrMimeTypeNotAllowed         = errors.New("file type is not allowed by the upload token")
+	ErrInvalidUploadTokenDuration = errors.New("upload token expiry is out of range")
+)
+
+// Folder Error
+var (
+	ErrInvalidFolderPath = errors.New("invalid folder path")
+	ErrFolderNotFound    = errors.New("folder not found")
+	ErrPathNotFound      = errors.New("no file or folder at this path")
+	ErrPathConflict      = errors.New("a file or folder already exists at this path")
+	ErrFolderNotEmpty    = errors.New("folder is not empty")
+	ErrInvalidMove       = errors.New("a folder cannot be moved into itself")
+)
+
+// Listing Error
+var (
+	ErrInvalidFilter = errors.New("invalid listing filter")
+	ErrInvalidCursor = errors.New("invalid or expired cursor")
+)
+
+// Tag Error
+var (
+	ErrInvalidTag  = errors.New("invalid tag: keys are up to 64 lowercase letters, digits, '.', '_' or '-', values up to 256 characters")
+	ErrTooManyTags = errors.New("too many tags on file")
+)
+
+// Customer Key Error
+var (
+	ErrInvalidCustomerKey  = errors.New("customer key must be a base64 encoded 256-bit AES key")
+	ErrCustomerKeyRequired = errors.New("file is encrypted with a customer key that must be supplied")
+	ErrCustomerKeyMismatch = errors.New("customer key does not match the key the file was encrypted with")
+)
+
+// External Key Manager Error
+var (
+	ErrExternalKeyUnavailable = errors.New("external key manager refused or could not be reached")
+	ErrInvalidKeyProvider     = errors.New("invalid key provider configuration")
 )
 
 // KM Error
@@ -52,6 +140,41 @@ var (
 	ErrFailedToExportKeyToKMS     = errors.New("failed to export key to KMS")
 )
 
+// Audit Error
+var (
+	ErrAuditSigningKeyMissing = errors.New("no key configured to sign audit checkpoints")
+	ErrInvalidExportFormat    = errors.New("invalid export format: use jsonl, csv or cef")
+	ErrLogNotFound            = errors.New("log entry not found")
+)
+
+// Quota Error
+var (
+	ErrFileTooLarge           = errors.New("file exceeds the maximum file size of the app")
+	ErrStorageQuotaExceeded   = errors.New("app storage quota exceeded")
+	ErrFileCountQuotaExceeded = errors.New("app file count quota exceeded")
+	ErrInvalidQuota           = errors.New("invalid quota: limits cannot be negative")
+)
+
+// Client Config Error
+var (
+	ErrInvalidBucketName           = errors.New("invalid bucket name")
+	ErrUnsupportedHashMethod       = errors.New("unsupported hash method")
+	ErrUnsupportedEncryptionMethod = errors.New("unsupported encryption method")
+)
+
+// Bucket Error
+var (
+	ErrBucketNotEmpty   = errors.New("bucket still holds objects")
+	ErrBucketNotFound   = errors.New("bucket does not exist")
+	ErrAppBucketNotSet  = errors.New("app has no bucket of its own and per-app buckets are not enabled")
+	ErrFileMoveConflict = errors.New("file was changed while it was moved between buckets")
+)
+
+// Lifecycle Error
+var (
+	ErrInvalidLifecyclePolicy = errors.New("invalid lifecycle policy: days cannot be negative")
+)
+
 // APP error
 var (
 	ErrAppAlreadyExists = errors.New("app already exists")
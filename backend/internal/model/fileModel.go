@@ -1,6 +1,7 @@
 package model
 
 import (
+	"io"
 	"mime/multipart"
 	"time"
 )
@@ -35,6 +36,81 @@ type DownloadFileResponse struct {
 	Content  []byte `json:"content"`
 }
 
+// FileDownloadStream carries a decrypted file stream together with the details needed to serve it.
+// The caller is responsible for closing Content.
+type FileDownloadStream struct {
+	Content      io.ReadCloser
+	FileName     string
+	MimeType     string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+
+	// Set when Content holds a single byte range [RangeStart, RangeEnd] of a TotalSize file
+	Partial    bool
+	RangeStart int64
+	RangeEnd   int64
+	TotalSize  int64
+}
+
+// DownloadOptions carries the conditional and partial request headers of a download.
+type DownloadOptions struct {
+	Range       string
+	IfRange     string
+	CustomerKey []byte // required for files uploaded with a customer-supplied key
+}
+
+// UploadOptions carries the optional request headers of an upload.
+type UploadOptions struct {
+	CustomerKey []byte            // client-supplied AES-256 key used instead of a server-managed one; only its fingerprint is stored
+	Folder      string            // folder path the file is placed in, created if missing; empty leaves the file outside the folder tree
+	Tags        map[string]string // custom metadata to attach; on updates nil keeps the current tags and anything else replaces them
+}
+
+// FileFilter narrows a file listing; zero fields do not filter. A file must match every tag filter.
+// Date ranges include their start and exclude their end.
+type FileFilter struct {
+	NamePrefix    string
+	MimeType      string // exact type, or a family such as "image/*"
+	MinSize       *int64
+	MaxSize       *int64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Deleted       string // one of constant.DeletedState*, only honoured for admins
+	Tags          []TagFilter
+	Cursor        string // next_cursor of the previous page; the offset is ignored when set
+}
+
+// LogFilter narrows a file log listing; zero fields do not filter. The time window includes From and excludes To.
+type LogFilter struct {
+	AppID        string // entries of the app itself, about the app and about its files, whoever the actor was
+	ActorID      string
+	ActorType    string
+	FileID       string
+	ResourceType string
+	ResourceID   string
+	Action       string
+	Outcome      string
+	TraceID      string
+	IP           string
+	From         *time.Time
+	To           *time.Time
+	Cursor       string // next_cursor of the previous page; the offset is ignored when set
+}
+
+// TagFilter matches files carrying a tag, with the given value unless Value is nil.
+type TagFilter struct {
+	Key   string
+	Value *string
+}
+
+// FileTagsRequest represents the request body for replacing the tags of a file.
+type FileTagsRequest struct {
+	Tags map[string]string `json:"tags" binding:"required"`
+}
+
 // EncryptFileResponse represents the response body after encrypting a file.
 type EncryptFileResponse struct {
 	FileName      string `json:"file_name"`
@@ -48,8 +124,9 @@ type DecryptFileResponse struct {
 }
 
 type ListFilesResponse struct {
-	Files []FileResponse `json:"files"`
-	Count int64          `json:"count"`
+	Files      []FileResponse `json:"files"`
+	Count      int64          `json:"count"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 type FileResponse struct {
@@ -58,30 +135,203 @@ type FileResponse struct {
 	Size      int64  `json:"file_size"`
 	OwnerID   string `json:"app_id,omitempty"`
 	MimeType  string `json:"file_type"`
+	Status    string `json:"status,omitempty"`
+	Path      string `json:"path,omitempty"`
 	UpdatedAt string `json:"updated_at"`
 	Deleted   bool   `json:"deleted,omitempty"`
 }
 
 type FileLogResponse struct {
-	ID        string                 `json:"file_id"`
-	ActorID   string                 `json:"actor_id"`
-	ActorType string                 `json:"actor_type"`
-	Action    string                 `json:"action"`
-	IP        string                 `json:"ip"`
-	Timestamp time.Time              `json:"timestamp"`
-	UserAgent string                 `json:"user_agent"`
-	Metadata  map[string]interface{} `json:"metadata"`
+	ID           string                 `json:"file_id"`
+	ActorID      string                 `json:"actor_id"`
+	ActorType    string                 `json:"actor_type"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Action       string                 `json:"action"`
+	Outcome      string                 `json:"outcome"`
+	TraceID      string                 `json:"trace_id,omitempty"`
+	IP           string                 `json:"ip"`
+	Timestamp    time.Time              `json:"timestamp"`
+	UserAgent    string                 `json:"user_agent"`
+	Metadata     map[string]interface{} `json:"metadata"`
+}
+
+// FileStatusResponse reports whether a file is durable, together with its latest upload job.
+type FileStatusResponse struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"file_name"`
+	Status    string             `json:"status"`
+	UpdatedAt string             `json:"updated_at"`
+	Job       *UploadJobResponse `json:"job,omitempty"`
+}
+
+// UploadJobResponse describes the progress of an upload or update in the outbox.
+type UploadJobResponse struct {
+	Operation     string `json:"operation"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+}
+
+// InitiateUploadRequest represents the request body for starting a resumable upload.
+type InitiateUploadRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+}
+
+// UploadSessionResponse describes a resumable upload and the parts received so far.
+type UploadSessionResponse struct {
+	ID          string               `json:"upload_id"`
+	FileID      string               `json:"file_id"`
+	FileName    string               `json:"file_name"`
+	Status      string               `json:"status"`
+	ExpiresAt   string               `json:"expires_at"`
+	MinPartSize int64                `json:"min_part_size"`
+	MaxPartSize int64                `json:"max_part_size"`
+	Parts       []UploadPartResponse `json:"parts"`
+}
+
+// UploadPartResponse describes one part received for a resumable upload.
+type UploadPartResponse struct {
+	PartNumber int    `json:"part_number"`
+	Size       int64  `json:"size"`
+	Hash       string `json:"hash"`
+}
+
+// FileVersionResponse describes one committed version of a file.
+type FileVersionResponse struct {
+	Version   int    `json:"version"`
+	Name      string `json:"file_name"`
+	Size      int64  `json:"file_size"`
+	MimeType  string `json:"file_type"`
+	Hash      string `json:"hash"`
+	Current   bool   `json:"current"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateShareLinkRequest represents the request body for sharing a file through a public link.
+type CreateShareLinkRequest struct {
+	ExpiresIn    string `json:"expires_in"`    // Go duration such as "24h", defaults to the configured link TTL
+	MaxDownloads int    `json:"max_downloads"` // 0 allows unlimited downloads
+	Passphrase   string `json:"passphrase"`
+	AllowedCIDR  string `json:"allowed_cidr"` // e.g. "203.0.113.0/24" or a single address
+}
+
+// ShareLinkResponse describes a share link; the URL is only returned when the link is created.
+type ShareLinkResponse struct {
+	ID            string `json:"id"`
+	FileID        string `json:"file_id"`
+	URL           string `json:"url,omitempty"`
+	ExpiresAt     string `json:"expires_at"`
+	MaxDownloads  int    `json:"max_downloads"`
+	Downloads     int    `json:"downloads"`
+	HasPassphrase bool   `json:"has_passphrase"`
+	AllowedCIDR   string `json:"allowed_cidr,omitempty"`
+	Revoked       bool   `json:"revoked"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// CreateUploadTokenRequest represents the request body for minting a pre-signed upload token.
+type CreateUploadTokenRequest struct {
+	ExpiresIn        string   `json:"expires_in"` // Go duration such as "1h", defaults to the configured token TTL
+	MaxSize          int64    `json:"max_size" binding:"required,gt=0"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"` // e.g. "application/pdf" or "image/*", empty allows any type
+	Folder           string   `json:"folder"`
+}
+
+// UploadTokenResponse describes an upload token; the URL is only returned when the token is created.
+type UploadTokenResponse struct {
+	ID               string   `json:"id"`
+	URL              string   `json:"url,omitempty"`
+	ExpiresAt        string   `json:"expires_at"`
+	MaxSize          int64    `json:"max_size"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	Folder           string   `json:"folder,omitempty"`
+	Revoked          bool     `json:"revoked"`
+	CreatedAt        string   `json:"created_at"`
+}
+
+// PathEntryResponse describes a file or folder in the folder tree. Children are only listed for folders.
+type PathEntryResponse struct {
+	Type      string              `json:"type"` // folder or file
+	ID        string              `json:"id,omitempty"`
+	Name      string              `json:"name"`
+	Path      string              `json:"path"`
+	Size      int64               `json:"size,omitempty"`
+	MimeType  string              `json:"mime_type,omitempty"`
+	UpdatedAt string              `json:"updated_at,omitempty"`
+	Children  []PathEntryResponse `json:"children,omitempty"`
+}
+
+// MovePathRequest represents the request body for moving or renaming a file or folder.
+type MovePathRequest struct {
+	Destination string `json:"destination" binding:"required"`
+}
+
+// PlaceFileRequest represents the request body for placing a file at a path in the folder tree.
+type PlaceFileRequest struct {
+	Path string `json:"path" binding:"required"`
 }
 
 type FileMetadataResponse struct {
-	ID         string `json:"id,omitempty"`
-	Name       string `json:"file_name,omitempty"`
-	Size       int64  `json:"file_size,omitempty"`
-	MimeType   string `json:"file_type,omitempty"`
-	VersionID  string `json:"version_id,omitempty"`
-	Hash       string `json:"hash,omitempty"`
-	BucketName string `json:"bucket,omitempty"`
-	Location   string `json:"location,omitempty"`
-	CreatedAt  string `json:"created_at,omitempty"`
-	UpdatedAt  string `json:"updated_at,omitempty"`
+	ID          string            `json:"id,omitempty"`
+	Name        string            `json:"file_name,omitempty"`
+	Size        int64             `json:"file_size,omitempty"`
+	MimeType    string            `json:"file_type,omitempty"`
+	VersionID   string            `json:"version_id,omitempty"`
+	Hash        string            `json:"hash,omitempty"`
+	BucketName  string            `json:"bucket,omitempty"`
+	Location    string            `json:"location,omitempty"`
+	CustomerKey bool              `json:"customer_key"`
+	Path        string            `json:"path,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	LegalHold   bool              `json:"legal_hold"`
+	RetainUntil *time.Time        `json:"retain_until,omitempty"`
+	CreatedAt   string            `json:"created_at,omitempty"`
+	UpdatedAt   string            `json:"updated_at,omitempty"`
+}
+
+// FileExpiryRequest sets when a file expires; null clears the expiry so the app's file TTL applies again.
+type FileExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type FileExpiryResponse struct {
+	FileID    string     `json:"file_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// LegalHoldRequest gives the reason a legal hold is placed; it is kept in the audit trail.
+type LegalHoldRequest struct {
+	Reason string `json:"reason" validate:"required,max=512"`
+}
+
+// FileRetentionRequest sets the date before which a file cannot be deleted or overwritten. It can only be extended.
+type FileRetentionRequest struct {
+	RetainUntil time.Time `json:"retain_until" validate:"required"`
+}
+
+// FileHoldResponse reports the holds on a file; StorageLocked is set when storage enforces them with object lock too.
+type FileHoldResponse struct {
+	FileID        string     `json:"file_id"`
+	LegalHold     bool       `json:"legal_hold"`
+	RetainUntil   *time.Time `json:"retain_until,omitempty"`
+	StorageLocked bool       `json:"storage_locked"`
+}
+
+// ErasureReceipt records that a file was crypto-shredded: its keys destroyed or wiped, every stored version
+// removed and its records deleted. It is signed with a key derived from the KEK and names the audit log entry
+// of the erasure, so it can be verified later without any of the erased data.
+type ErasureReceipt struct {
+	ReceiptID        string    `json:"receipt_id"`
+	FileID           string    `json:"file_id"`
+	AppID            string    `json:"app_id"`
+	ErasedAt         time.Time `json:"erased_at"`
+	KeysDestroyed    []string  `json:"keys_destroyed"`     // key manager keys destroyed
+	WrappedKeysWiped int64     `json:"wrapped_keys_wiped"` // wrapped file keys blanked in the database
+	VersionsRemoved  int       `json:"versions_removed"`   // object versions removed from storage
+	LogID            uint      `json:"log_id"`
+	LogHash          string    `json:"log_hash"`
+	KeyID            string    `json:"key_id,omitempty"`
+	Signature        string    `json:"signature,omitempty"`
 }
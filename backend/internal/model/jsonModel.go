@@ -26,6 +26,21 @@ func JSONSuccessResponseWithCount(c *gin.Context, statusCode int, message string
 	})
 }
 
+// JSONSuccessResponseWithCursor sends a response with a count field and the cursor of the next page.
+// The next_cursor field is left out on the last page.
+func JSONSuccessResponseWithCursor(c *gin.Context, statusCode int, message string, count int64, nextCursor string, data interface{}) {
+	response := gin.H{
+		"success": true,
+		"message": message,
+		"count":   count,
+		"data":    data,
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+	c.JSON(statusCode, response)
+}
+
 // JSONErrorResponse sends a JSON response with a success status set to false.
 // The response will have three fields: "success", "message" and "error".
 // The "success" field will always be false.
@@ -63,11 +63,14 @@ type MetaDataDTO struct {
 	Size              int64  `json:"size"`
 	Hash              string `json:"hash"`
 	EncryptedFileHash string `json:"encryptedFileHash"`
+	WrappedKey        string `json:"wrappedKey"` // key wrapped by the app's external key manager, if it holds its own keys
 }
 
+// ClientConfig is the storage and crypto settings applied to the new files of an app:
+// its own settings where it has them and the server defaults otherwise
 type ClientConfig struct {
 	BucketName        string `json:"bucket_name"`
 	HashMethod        string `json:"hash_method"`
 	HashEncryptedFile bool   `json:"hash_encrypted_file"`
-	EncyptionMethod   string `json:"encyption_method"`
+	EncryptionMethod  string `json:"encryption_method"`
 }
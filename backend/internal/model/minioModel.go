@@ -15,6 +15,20 @@ type StorageTransactionResponse struct {
 	Expiration     string
 	Location       string
 	ChecksumSHA256 string
+	Size           int64
 	IsLatest       bool
 	IsDeleteMarker bool
 }
+
+// StoragePart identifies one uploaded part of a multipart upload.
+type StoragePart struct {
+	PartNumber int
+	ETag       string
+}
+
+// ReplicaRepairReport counts what one pass of the storage replica repair worker did.
+type ReplicaRepairReport struct {
+	Processed int `json:"processed"`
+	Repaired  int `json:"repaired"`
+	Failures  int `json:"failures"`
+}
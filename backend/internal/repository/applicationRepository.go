@@ -4,10 +4,13 @@ import (
 	"context"
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // appsRepository implements the AppsRepository interface for application data access operations.
@@ -149,6 +152,113 @@ func (r *appsRepository) GetAll(ctx context.Context) ([]entity.Apps, error) {
 	return apps, nil
 }
 
+// ListWithLifecycle retrieves the applications, active or not, with a file expiry or deleted file retention rule.
+func (r *appsRepository) ListWithLifecycle(ctx context.Context) ([]entity.Apps, error) {
+	var apps []entity.Apps
+	if err := r.db.WithContext(ctx).Unscoped().
+		Where("file_ttl_days > 0 OR deleted_retention_days > 0").
+		Order("id asc").
+		Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("failed to get apps with lifecycle rules: %w", err)
+	}
+	return apps, nil
+}
+
+// GetUsage retrieves the running usage totals of an application; an application that never stored a file has
+// zero usage.
+func (r *appsRepository) GetUsage(ctx context.Context, appID string) (*entity.AppUsage, error) {
+	usage := entity.AppUsage{AppID: appID}
+	if err := r.db.WithContext(ctx).Where("app_id = ?", appID).Limit(1).Find(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get app usage: %w", err)
+	}
+	return &usage, nil
+}
+
+// BackfillUsage counts the stored files of every application without usage totals yet, such as applications
+// created before usage was tracked, and returns how many applications it counted.
+// Totals already kept are left alone, so it is safe to run while files are being written.
+func (r *appsRepository) BackfillUsage(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`INSERT INTO app_usage (app_id, stored_bytes, file_count, updated_at)
+		SELECT apps.id, COALESCE(SUM(files.size), 0), COUNT(files.id), ?
+		FROM apps LEFT JOIN files ON files.app_id = apps.id AND files.status = ? AND files.deleted_at IS NULL
+		WHERE apps.id NOT IN (SELECT app_id FROM app_usage)
+		GROUP BY apps.id`, time.Now().UTC(), constant.FileStatusStored)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to backfill app usage: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ListPendingBucketMigrations retrieves the applications, active or not, whose files were asked to move into their bucket.
+func (r *appsRepository) ListPendingBucketMigrations(ctx context.Context) ([]entity.Apps, error) {
+	var apps []entity.Apps
+	if err := r.db.WithContext(ctx).Unscoped().
+		Where("bucket_migration_at IS NOT NULL AND bucket_name <> ''").
+		Order("id asc").
+		Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("failed to get apps with pending bucket migrations: %w", err)
+	}
+	return apps, nil
+}
+
+// FinishBucketMigration clears the pending bucket migration of an application, unless it was asked for again
+// after requestedAt so files written in the meantime are moved too.
+func (r *appsRepository) FinishBucketMigration(ctx context.Context, appID string, requestedAt time.Time) error {
+	if err := r.db.WithContext(ctx).Unscoped().Model(&entity.Apps{}).
+		Where("id = ? AND bucket_migration_at = ?", appID, requestedAt).
+		Update("bucket_migration_at", nil).Error; err != nil {
+		return fmt.Errorf("failed to finish bucket migration: %w", err)
+	}
+	return nil
+}
+
+// CountFilesOutsideBucket counts the stored files of an application, soft deleted or not, kept in any other bucket.
+// Files without a recorded bucket are in the server default bucket and are counted too.
+func (r *appsRepository) CountFilesOutsideBucket(ctx context.Context, appID, bucketName string) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&entity.Files{}).
+		Where("app_id = ? AND status = ? AND COALESCE(bucket_name, '') <> ?", appID, constant.FileStatusStored, bucketName).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count files outside bucket: %w", err)
+	}
+	return count, nil
+}
+
+// GetByFileID retrieves the application a file belongs to, including soft-deleted files and applications.
+func (r *appsRepository) GetByFileID(ctx context.Context, fileID string) (*entity.Apps, error) {
+	var app entity.Apps
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("id = (?)", r.db.Unscoped().Model(&entity.Files{}).Select("app_id").Where("id = ?", fileID)).
+		First(&app).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrAppNotFound
+		}
+		return nil, fmt.Errorf("failed to get app by file id: %w", err)
+	}
+	return &app, nil
+}
+
+// adjustAppUsage adds bytes and files, either of which may be negative, to the usage totals of an app.
+// It runs in the transaction that changes the files, so the totals never drift from what is committed.
+func adjustAppUsage(tx *gorm.DB, appID string, bytes, files int64) error {
+	if bytes == 0 && files == 0 {
+		return nil
+	}
+	usage := entity.AppUsage{AppID: appID, StoredBytes: bytes, FileCount: files}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "app_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"stored_bytes": gorm.Expr("app_usage.stored_bytes + ?", bytes),
+			"file_count":   gorm.Expr("app_usage.file_count + ?", files),
+			"updated_at":   time.Now().UTC(),
+		}),
+	}).Create(&usage).Error; err != nil {
+		return fmt.Errorf("failed to update app usage: %w", err)
+	}
+	return nil
+}
+
 // GetListApps retrieves a paginated list of applications with sorting options.
 // Includes soft-deleted records for admin visibility.
 func (r *appsRepository) GetListApps(ctx context.Context, offset, limit int, orderBy, sort string) (int64, *[]entity.Apps, error) {
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"crypsis-backend/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// pageCursor is the position of the last row of a page for keyset pagination.
+// It is handed to clients base64 encoded and is only valid for the sort it was issued for.
+type pageCursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	Value  string `json:"v"`
+	ID     string `json:"id"`
+}
+
+// encodeCursor turns the sort value and ID of the last row of a page into an opaque cursor
+func encodeCursor(sortBy, order string, value interface{}, id string) string {
+	cursor := pageCursor{SortBy: sortBy, Order: order, ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		cursor.Value = v.Format(time.RFC3339Nano)
+	default:
+		cursor.Value = fmt.Sprint(v)
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor reads a cursor issued by encodeCursor, failing with ErrInvalidCursor when it is
+// malformed or was issued for another sort.
+func decodeCursor(encoded, sortBy, order string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == "" {
+		return nil, model.ErrInvalidCursor
+	}
+	if cursor.SortBy != sortBy || cursor.Order != order {
+		return nil, model.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// applyKeyset continues a listing ordered by sortBy and then ID after the cursor row.
+// sortBy must already be checked against the allowed sort columns; timeColumns and intColumns
+// tell how the cursor values are typed, everything else compares as text.
+func applyKeyset(query *gorm.DB, cursor *pageCursor, timeColumns, intColumns []string) (*gorm.DB, error) {
+	parse := func(column, raw string) (interface{}, error) {
+		switch {
+		case slices.Contains(timeColumns, column):
+			return time.Parse(time.RFC3339Nano, raw)
+		case slices.Contains(intColumns, column):
+			return strconv.ParseInt(raw, 10, 64)
+		default:
+			return raw, nil
+		}
+	}
+
+	op := ">"
+	if cursor.Order == "desc" {
+		op = "<"
+	}
+	id, err := parse("id", cursor.ID)
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	if cursor.SortBy == "id" {
+		return query.Where("id "+op+" ?", id), nil
+	}
+	value, err := parse(cursor.SortBy, cursor.Value)
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	return query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", cursor.SortBy, op), value, value, id), nil
+}
+
+// prefixPattern returns a LIKE pattern matching every value starting with prefix, escaping wildcards in it
+func prefixPattern(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
@@ -3,13 +3,28 @@ package repository
 import (
 	"context"
 	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// auditChainLockKey is the Postgres advisory lock serializing appends to the audit chain across instances
+const auditChainLockKey = 7262975102
+
+// auditChainMu serializes appends to the audit chain within this process
+var auditChainMu sync.Mutex
+
+// CheckpointSigner signs a checkpoint over the audit chain, setting its KeyID and Signature.
+type CheckpointSigner func(checkpoint *entity.AuditCheckpoints) error
+
 // fileLogsRepository implements the FileLogsRepository interface for file log data access operations.
 type fileLogsRepository struct {
 	db *gorm.DB
@@ -20,9 +35,36 @@ func NewFileLogRepository(db *gorm.DB) FileLogsRepository {
 	return &fileLogsRepository{db: db}
 }
 
-// Create adds a new file log entry to the database.
+// Create appends a new file log entry to the audit chain, linking it to the hash of the entry before it.
 func (r *fileLogsRepository) Create(ctx context.Context, log *entity.FileLogs) error {
-	if err := r.db.WithContext(ctx).Create(log).Error; err != nil {
+	if log.Timestamp.IsZero() {
+		log.Timestamp = time.Now()
+	}
+	log.Timestamp = log.Timestamp.UTC().Truncate(time.Microsecond)
+	// Entries written without a resource or outcome are successful file actions
+	if log.ResourceType == "" {
+		log.ResourceType = constant.ResourceTypeFile
+	}
+	if log.ResourceType == constant.ResourceTypeFile && log.ResourceID == "" {
+		log.ResourceID = log.FileID
+	}
+	if log.Outcome == "" {
+		log.Outcome = constant.OutcomeSuccess
+	}
+
+	err := r.withChainLock(ctx, func(tx *gorm.DB) error {
+		last, err := lastChainEntry(tx)
+		if err != nil {
+			return err
+		}
+		log.PrevHash = ""
+		if last != nil {
+			log.PrevHash = last.Hash
+		}
+		log.Hash = log.ComputeHash()
+		return tx.Create(log).Error
+	})
+	if err != nil {
 		slog.Error("Failed to insert file log", slog.Any("error", err))
 		return fmt.Errorf("failed to insert file log: %w", err)
 	}
@@ -30,7 +72,8 @@ func (r *fileLogsRepository) Create(ctx context.Context, log *entity.FileLogs) e
 }
 
 // List retrieves a paginated list of file logs with sorting options.
-func (r *fileLogsRepository) List(ctx context.Context, offset, limit int, orderBy, sort string) (int64, *[]entity.FileLogs, error) {
+// Pages continue after filter.Cursor when set, and a next cursor is returned whenever the page is full.
+func (r *fileLogsRepository) List(ctx context.Context, filter model.LogFilter, offset, limit int, orderBy, sort string) (int64, *[]entity.FileLogs, string, error) {
 	var total int64
 	var logs []entity.FileLogs
 
@@ -53,23 +96,117 @@ func (r *fileLogsRepository) List(ctx context.Context, offset, limit int, orderB
 		orderBy = "timestamp" // or another sensible default
 	}
 
+	// Apply filters
+	query := applyLogFilter(r.db.WithContext(ctx).Model(&entity.FileLogs{}), filter)
+
 	// Count total
-	if err := r.db.WithContext(ctx).
-		Model(&entity.FileLogs{}).
-		Count(&total).Error; err != nil {
-		return 0, nil, fmt.Errorf("failed to count file logs: %w", err)
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, nil, "", fmt.Errorf("failed to count file logs: %w", err)
 	}
 
 	// Get paginated list
-	if err := r.db.WithContext(ctx).
-		Offset(offset).
+	query = query.Session(&gorm.Session{})
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor, orderBy, sort)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		if query, err = applyKeyset(query, cursor, []string{"timestamp"}, []string{"id"}); err != nil {
+			return 0, nil, "", err
+		}
+	} else {
+		query = query.Offset(offset)
+	}
+	if err := query.
 		Limit(limit).
-		Order(orderBy + " " + sort).
+		Order(fmt.Sprintf("%s %s, id %s", orderBy, sort, sort)).
 		Find(&logs).Error; err != nil {
 		slog.Error("Failed to get file logs", slog.Any("error", err))
-		return 0, nil, fmt.Errorf("failed to get file logs: %w", err)
+		return 0, nil, "", fmt.Errorf("failed to get file logs: %w", err)
 	}
-	return total, &logs, nil
+
+	var nextCursor string
+	if limit > 0 && len(logs) == limit {
+		last := logs[len(logs)-1]
+		values := map[string]interface{}{
+			"id":         last.ID,
+			"file_id":    last.FileID,
+			"action":     last.Action,
+			"timestamp":  last.Timestamp,
+			"ip":         last.IP,
+			"user_agent": last.UserAgent,
+		}
+		nextCursor = encodeCursor(orderBy, sort, values[orderBy], strconv.FormatUint(uint64(last.ID), 10))
+	}
+	return total, &logs, nextCursor, nil
+}
+
+// applyLogFilter narrows a file log query to the entries matching every set field of the filter.
+// File IDs are compared as text because file_logs.file_id is a uuid column on Postgres.
+func applyLogFilter(query *gorm.DB, filter model.LogFilter) *gorm.DB {
+	if filter.AppID != "" {
+		query = query.Where("(actor_id = ? OR (resource_type = ? AND resource_id = ?) OR CAST(file_id AS TEXT) IN (SELECT id FROM files WHERE app_id = ?))",
+			filter.AppID, constant.ResourceTypeApp, filter.AppID, filter.AppID)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ActorType != "" {
+		query = query.Where("actor_type = ?", filter.ActorType)
+	}
+	if filter.FileID != "" {
+		query = query.Where("CAST(file_id AS TEXT) = ?", filter.FileID)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.TraceID != "" {
+		query = query.Where("trace_id = ?", filter.TraceID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From != nil {
+		query = query.Where("timestamp >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("timestamp < ?", *filter.To)
+	}
+	return query
+}
+
+// Stream hands the log entries matching filter to fn in batches of batchSize, in chain order,
+// without loading every entry at once.
+func (r *fileLogsRepository) Stream(ctx context.Context, filter model.LogFilter, batchSize int, fn func(batch []entity.FileLogs) error) error {
+	var batch []entity.FileLogs
+	query := applyLogFilter(r.db.WithContext(ctx).Model(&entity.FileLogs{}), filter)
+	if err := query.FindInBatches(&batch, batchSize, func(_ *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error; err != nil {
+		return fmt.Errorf("failed to stream file logs: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single log entry by its ID.
+func (r *fileLogsRepository) GetByID(ctx context.Context, id uint) (*entity.FileLogs, error) {
+	var log entity.FileLogs
+	if err := r.db.WithContext(ctx).First(&log, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrLogNotFound
+		}
+		return nil, fmt.Errorf("failed to get log %d: %w", id, err)
+	}
+	return &log, nil
 }
 
 // GetByFileID retrieves all log entries for a specific file ID.
@@ -92,13 +229,221 @@ func (r *fileLogsRepository) GetByAction(ctx context.Context, action string) (*[
 	return logs, nil
 }
 
+// CountOldLogs counts the log entries DeleteOldLogs would remove for the same number of days.
+func (r *fileLogsRepository) CountOldLogs(ctx context.Context, days int) (int64, error) {
+	expiryDate := time.Now().UTC().AddDate(0, 0, -days)
+
+	var firstKept sql.NullInt64
+	if err := r.db.WithContext(ctx).Model(&entity.FileLogs{}).Select("MIN(id)").Where("timestamp >= ?", expiryDate).Row().Scan(&firstKept); err != nil {
+		return 0, fmt.Errorf("failed to count old logs: %w", err)
+	}
+	query := r.db.WithContext(ctx).Model(&entity.FileLogs{})
+	if firstKept.Valid {
+		query = query.Where("id < ?", firstKept.Int64)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count old logs: %w", err)
+	}
+	return count, nil
+}
+
 // DeleteOldLogs removes log entries older than the specified number of days.
-// This is useful for log retention management and database cleanup.
-func (r *fileLogsRepository) DeleteOldLogs(ctx context.Context, days int) error {
-	expiryDate := time.Now().AddDate(0, 0, -days)
-	if err := r.db.WithContext(ctx).Where("timestamp < ?", expiryDate).Delete(&entity.FileLogs{}).Error; err != nil {
+// Entries are only removed from the start of the chain, up to the first entry that is still young enough,
+// and a signed retention checkpoint anchors the remaining chain to the last removed entry.
+// Checkpoints over removed entries are dropped with them.
+func (r *fileLogsRepository) DeleteOldLogs(ctx context.Context, days int, sign CheckpointSigner) error {
+	if sign == nil {
+		return fmt.Errorf("a checkpoint signer is required to cut the audit chain")
+	}
+	expiryDate := time.Now().UTC().AddDate(0, 0, -days)
+
+	err := r.withChainLock(ctx, func(tx *gorm.DB) error {
+		// The cut ends right before the first entry that must be kept, or at the head when every entry is old
+		var firstKept sql.NullInt64
+		if err := tx.Model(&entity.FileLogs{}).Select("MIN(id)").Where("timestamp >= ?", expiryDate).Row().Scan(&firstKept); err != nil {
+			return err
+		}
+		query := tx.Order("id DESC").Limit(1)
+		if firstKept.Valid {
+			query = query.Where("id < ?", firstKept.Int64)
+		}
+		var cutoff entity.FileLogs
+		result := query.Find(&cutoff)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		anchor := &entity.AuditCheckpoints{
+			LogID:     cutoff.ID,
+			LogHash:   cutoff.Hash,
+			Kind:      constant.CheckpointKindRetention,
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		}
+		previous, err := lastCheckpoint(tx)
+		if err != nil {
+			return err
+		}
+		sequenceAfter(anchor, previous)
+		// Checkpoints over kept entries survive the cut, so the sequence now starts at the oldest of them
+		var firstSequence sql.NullInt64
+		if err := tx.Model(&entity.AuditCheckpoints{}).Select("MIN(sequence)").Where("log_id > ?", cutoff.ID).Row().Scan(&firstSequence); err != nil {
+			return err
+		}
+		anchor.FirstSequence = anchor.Sequence
+		if firstSequence.Valid {
+			anchor.FirstSequence = uint(firstSequence.Int64)
+		}
+		if err := sign(anchor); err != nil {
+			return err
+		}
+		if err := tx.Create(anchor).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("log_id < ? OR (log_id = ? AND id <> ?)", cutoff.ID, cutoff.ID, anchor.ID).Delete(&entity.AuditCheckpoints{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id <= ?", cutoff.ID).Delete(&entity.FileLogs{}).Error
+	})
+	if err != nil {
 		slog.Error("Failed to delete old logs", slog.Any("error", err))
 		return fmt.Errorf("failed to delete old logs: %w", err)
 	}
 	return nil
 }
+
+// CreateCheckpoint signs a checkpoint over the current head of the audit chain.
+// It returns nil when the chain is empty, or when it has not grown since the last checkpoint and that
+// checkpoint is younger than maxAge; an unchanged head is signed again once it is older.
+func (r *fileLogsRepository) CreateCheckpoint(ctx context.Context, maxAge time.Duration, sign CheckpointSigner) (*entity.AuditCheckpoints, error) {
+	var checkpoint *entity.AuditCheckpoints
+	err := r.withChainLock(ctx, func(tx *gorm.DB) error {
+		last, err := lastChainEntry(tx)
+		if err != nil || last == nil {
+			return err
+		}
+		var covered int64
+		if err := tx.Model(&entity.AuditCheckpoints{}).Where("log_id >= ?", last.ID).Count(&covered).Error; err != nil {
+			return err
+		}
+
+		previous, err := lastCheckpoint(tx)
+		if err != nil {
+			return err
+		}
+		if covered > 0 && previous != nil && time.Since(previous.CreatedAt) < maxAge {
+			return nil
+		}
+
+		checkpoint = &entity.AuditCheckpoints{
+			LogID:     last.ID,
+			LogHash:   last.Hash,
+			Kind:      constant.CheckpointKindPeriodic,
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		}
+		sequenceAfter(checkpoint, previous)
+		if err := sign(checkpoint); err != nil {
+			return err
+		}
+		return tx.Create(checkpoint).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// sequenceAfter numbers a checkpoint after the previous one signed, or first when there is none, and links it to
+// the previous signature
+func sequenceAfter(checkpoint, previous *entity.AuditCheckpoints) {
+	checkpoint.Sequence, checkpoint.PrevSignature = 1, ""
+	if previous != nil {
+		checkpoint.Sequence, checkpoint.PrevSignature = previous.Sequence+1, previous.Signature
+	}
+}
+
+// lastCheckpoint retrieves the checkpoint signed last, or nil when there is none
+func lastCheckpoint(tx *gorm.DB) (*entity.AuditCheckpoints, error) {
+	var last entity.AuditCheckpoints
+	result := tx.Order("sequence DESC, id DESC").Limit(1).Find(&last)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &last, nil
+}
+
+// ListCheckpoints retrieves every checkpoint over the audit chain, in chain order.
+func (r *fileLogsRepository) ListCheckpoints(ctx context.Context) ([]entity.AuditCheckpoints, error) {
+	var checkpoints []entity.AuditCheckpoints
+	if err := r.db.WithContext(ctx).Order("log_id ASC, id ASC").Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// ListChain retrieves up to limit entries of the audit chain following the entry afterID, in chain order.
+func (r *fileLogsRepository) ListChain(ctx context.Context, afterID uint, limit int) ([]entity.FileLogs, error) {
+	var logs []entity.FileLogs
+	if err := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit chain: %w", err)
+	}
+	return logs, nil
+}
+
+// withChainLock runs fn in a transaction holding the audit chain lock, so entries and checkpoints
+// are appended one at a time.
+func (r *fileLogsRepository) withChainLock(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	auditChainMu.Lock()
+	defer auditChainMu.Unlock()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+				return fmt.Errorf("failed to lock audit chain: %w", err)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// lastChainEntry retrieves the head of the audit chain, or nil when it is empty
+func lastChainEntry(tx *gorm.DB) (*entity.FileLogs, error) {
+	var last entity.FileLogs
+	result := tx.Select("id", "hash").Order("id DESC").Limit(1).Find(&last)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &last, nil
+}
+
+// FileLogObserver is told about every log entry once it is appended to the audit chain.
+type FileLogObserver func(log *entity.FileLogs)
+
+// observedFileLogsRepository passes every appended log entry on to an observer.
+type observedFileLogsRepository struct {
+	FileLogsRepository
+	observe FileLogObserver
+}
+
+// NewObservedFileLogRepository wraps a FileLogsRepository so observe sees every entry it appends.
+func NewObservedFileLogRepository(base FileLogsRepository, observe FileLogObserver) FileLogsRepository {
+	return &observedFileLogsRepository{FileLogsRepository: base, observe: observe}
+}
+
+// Create appends the entry and hands it to the observer once it is stored.
+func (r *observedFileLogsRepository) Create(ctx context.Context, log *entity.FileLogs) error {
+	if err := r.FileLogsRepository.Create(ctx, log); err != nil {
+		return err
+	}
+	r.observe(log)
+	return nil
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -64,14 +66,17 @@ func (r *fileRepository) GetByHash(ctx context.Context, hash string) (*entity.Fi
 	return &file, nil
 }
 
-// GetListFiles retrieves a paginated list of files for a specific application.
-func (r *fileRepository) GetListFiles(ctx context.Context, appID string, offset, limit int, orderBy, sort string) (int64, []entity.Files, error) {
-	return r.getListFiles(ctx, map[string]interface{}{"app_id": appID}, offset, limit, orderBy, sort)
+// GetListFiles retrieves a paginated list of files for a specific application, narrowed by filter.
+func (r *fileRepository) GetListFiles(ctx context.Context, appID string, filter model.FileFilter, offset, limit int, orderBy, sort string) (int64, []entity.Files, string, error) {
+	if appID == "" {
+		return 0, nil, "", errors.New("app ID cannot be empty")
+	}
+	return r.getListFiles(ctx, appID, filter, offset, limit, orderBy, sort)
 }
 
-// GetListFilesForAdmin retrieves a paginated list of all files for admin users.
-func (r *fileRepository) GetListFilesForAdmin(ctx context.Context, offset, limit int, orderBy, sort string) (int64, []entity.Files, error) {
-	return r.getListFiles(ctx, nil, offset, limit, orderBy, sort)
+// GetListFilesForAdmin retrieves a paginated list of the files of every app for admin users.
+func (r *fileRepository) GetListFilesForAdmin(ctx context.Context, filter model.FileFilter, offset, limit int, orderBy, sort string) (int64, []entity.Files, string, error) {
+	return r.getListFiles(ctx, "", filter, offset, limit, orderBy, sort)
 }
 
 // GetAll retrieves all files from the database.
@@ -103,6 +108,11 @@ func (r *fileRepository) Delete(ctx context.Context, fileID string) error {
 		return errors.New("file ID cannot be empty")
 	}
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		file, err := usageOf(tx, fileID)
+		if err != nil {
+			return err
+		}
+
 		// Soft delete metadata first
 		result := tx.Where("file_id = ?", fileID).Delete(&entity.Metadata{})
 		if result.Error != nil {
@@ -120,9 +130,273 @@ func (r *fileRepository) Delete(ctx context.Context, fileID string) error {
 		if result.RowsAffected == 0 {
 			return model.ErrFileNotFound
 		}
+		if file.Status == constant.FileStatusStored {
+			return adjustAppUsage(tx, file.AppID, -file.Size, -1)
+		}
+		return nil
+	})
+
+}
+
+// WipeKeys blanks every wrapped key held for a file, deleted rows included, and returns how many were wiped.
+// Without its wrapped key a ciphertext can only be decrypted by a key manager that still holds the key.
+func (r *fileRepository) WipeKeys(ctx context.Context, fileID string) (int64, error) {
+	if fileID == "" {
+		return 0, errors.New("file ID cannot be empty")
+	}
+	var wiped int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, table := range []interface{}{&entity.Metadata{}, &entity.FileVersions{}, &entity.UploadSessions{}} {
+			result := tx.Unscoped().Model(table).Where("file_id = ? AND enc_key <> ''", fileID).Update("enc_key", "")
+			if result.Error != nil {
+				return fmt.Errorf("failed to wipe file keys: %w", result.Error)
+			}
+			wiped += result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to wipe file keys", slog.String("fileID", fileID), slog.Any("error", err))
+		return 0, err
+	}
+	return wiped, nil
+}
+
+// Purge permanently removes a file, deleted or not, together with its metadata, versions, tags, share links
+// and upload records. Its audit log entries are kept.
+func (r *fileRepository) Purge(ctx context.Context, fileID string) error {
+	if fileID == "" {
+		return errors.New("file ID cannot be empty")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		file, err := usageOf(tx.Unscoped(), fileID)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range []interface{}{
+			&entity.UploadParts{},
+			&entity.UploadSessions{},
+			&entity.UploadJobs{},
+			&entity.ShareLinks{},
+			&entity.FileTags{},
+			&entity.FileVersions{},
+			&entity.Metadata{},
+		} {
+			if err := tx.Unscoped().Where("file_id = ?", fileID).Delete(table).Error; err != nil {
+				slog.Error("Failed to purge file records", slog.String("fileID", fileID), slog.Any("error", err))
+				return fmt.Errorf("failed to purge file records: %w", err)
+			}
+		}
+		result := tx.Unscoped().Where("id = ?", fileID).Delete(&entity.Files{})
+		if result.Error != nil {
+			slog.Error("Failed to purge file", slog.String("fileID", fileID), slog.Any("error", result.Error))
+			return fmt.Errorf("failed to purge file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFileNotFound
+		}
+		// A soft-deleted file stopped counting when it was deleted
+		if !file.DeletedAt.Valid && file.Status == constant.FileStatusStored {
+			return adjustAppUsage(tx, file.AppID, -file.Size, -1)
+		}
+		return nil
+	})
+}
+
+// usageOf reads what a file counts towards the usage of its app, or ErrFileNotFound when tx cannot see it
+func usageOf(tx *gorm.DB, fileID string) (*entity.Files, error) {
+	var file entity.Files
+	result := tx.Select("id", "app_id", "size", "status", "bucket_name", "deleted_at").Where("id = ?", fileID).Limit(1).Find(&file)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to read file usage: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, model.ErrFileNotFound
+	}
+	return &file, nil
+}
+
+// SetExpiry sets when a live file expires, or clears its expiry when expiresAt is nil.
+func (r *fileRepository) SetExpiry(ctx context.Context, fileID string, expiresAt *time.Time) error {
+	result := r.db.WithContext(ctx).Model(&entity.Files{}).Where("id = ?", fileID).Update("expires_at", expiresAt)
+	if result.Error != nil {
+		slog.Error("Failed to set file expiry", slog.String("fileID", fileID), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to set file expiry: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrFileNotFound
+	}
+	return nil
+}
+
+// GetHoldableByID retrieves a file by its ID, soft deleted files included, so holds can keep them from being purged.
+func (r *fileRepository) GetHoldableByID(ctx context.Context, id string) (*entity.Files, error) {
+	if id == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+	var file entity.Files
+	if err := r.db.WithContext(ctx).Unscoped().First(&file, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve file by ID: %w", err)
+	}
+	return &file, nil
+}
+
+// SetLegalHold places or releases the legal hold on a file, soft deleted or not.
+func (r *fileRepository) SetLegalHold(ctx context.Context, fileID string, hold bool) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&entity.Files{}).Where("id = ?", fileID).Update("legal_hold", hold)
+	if result.Error != nil {
+		slog.Error("Failed to set legal hold", slog.String("fileID", fileID), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to set legal hold: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrFileNotFound
+	}
+	return nil
+}
+
+// SetRetention sets the date before which a file, soft deleted or not, cannot be deleted or overwritten.
+func (r *fileRepository) SetRetention(ctx context.Context, fileID string, retainUntil time.Time) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&entity.Files{}).Where("id = ?", fileID).Update("retain_until", retainUntil)
+	if result.Error != nil {
+		slog.Error("Failed to set file retention", slog.String("fileID", fileID), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to set file retention: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrFileNotFound
+	}
+	return nil
+}
+
+// BackfillHashAlgo records hashMethod as the hash algorithm of every row hashed before the algorithm was recorded,
+// so that changing the server default later does not break them, and returns how many rows it updated.
+func (r *fileRepository) BackfillHashAlgo(ctx context.Context, hashMethod string) (int64, error) {
+	if hashMethod == "" {
+		return 0, nil
+	}
+	var updated int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, table := range []interface{}{&entity.Metadata{}, &entity.FileVersions{}, &entity.UploadSessions{}} {
+			result := tx.Unscoped().Model(table).Where("hash_algo = ?", "").Update("hash_algo", hashMethod)
+			if result.Error != nil {
+				return result.Error
+			}
+			updated += result.RowsAffected
+		}
 		return nil
 	})
+	if err != nil {
+		slog.Error("Failed to backfill hash algorithms", slog.Any("error", err))
+		return 0, fmt.Errorf("failed to backfill hash algorithms: %w", err)
+	}
+	return updated, nil
+}
+
+// ListExpiredFiles retrieves stored files whose own expiry passed at or before now, by ID after afterID.
+// Files on legal hold or under retention are left out until they may be deleted.
+func (r *fileRepository) ListExpiredFiles(ctx context.Context, now time.Time, afterID string, limit int) ([]entity.Files, error) {
+	return r.listLifecycleBatch(ctx, notHeld(r.db.WithContext(ctx), now).
+		Where("status = ?", constant.FileStatusStored).
+		Where("expires_at <= ?", now), afterID, limit)
+}
+
+// ListFilesCreatedBefore retrieves stored files of an app created before the given time that carry no expiry of
+// their own and are not held, by ID after afterID.
+func (r *fileRepository) ListFilesCreatedBefore(ctx context.Context, appID string, before time.Time, afterID string, limit int) ([]entity.Files, error) {
+	return r.listLifecycleBatch(ctx, notHeld(r.db.WithContext(ctx), time.Now().UTC()).
+		Where("app_id = ? AND status = ?", appID, constant.FileStatusStored).
+		Where("expires_at IS NULL AND created_at < ?", before), afterID, limit)
+}
+
+// ListDeletedFilesBefore retrieves files of an app soft deleted before the given time and not held, by ID after afterID.
+func (r *fileRepository) ListDeletedFilesBefore(ctx context.Context, appID string, before time.Time, afterID string, limit int) ([]entity.Files, error) {
+	return r.listLifecycleBatch(ctx, notHeld(r.db.WithContext(ctx).Unscoped(), time.Now().UTC()).
+		Where("app_id = ? AND deleted_at IS NOT NULL AND deleted_at < ?", appID, before), afterID, limit)
+}
 
+// notHeld narrows a query to files that are neither on legal hold nor retained past now
+func notHeld(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("legal_hold = ? AND (retain_until IS NULL OR retain_until <= ?)", false, now)
+}
+
+// ListFilesOutsideBucket retrieves stored files of an app, soft deleted or not, kept in any other bucket, by ID after
+// afterID. Files without a recorded bucket are in the server default bucket and are listed too; held files stay put.
+func (r *fileRepository) ListFilesOutsideBucket(ctx context.Context, appID, bucketName, afterID string, limit int) ([]entity.Files, error) {
+	return r.listLifecycleBatch(ctx, notHeld(r.db.WithContext(ctx).Unscoped(), time.Now().UTC()).
+		Where("app_id = ? AND status = ?", appID, constant.FileStatusStored).
+		Where("COALESCE(bucket_name, '') <> ?", bucketName), afterID, limit)
+}
+
+// MoveToBucket points a file, soft deleted or not, at the copies of its contents made in toBucket; versionIDs maps
+// each storage version its metadata and versions refer to onto the copy of that version.
+// It fails with ErrFileMoveConflict when the file changed after it was copied: it is no longer in fromBucket,
+// an upload job is still writing to it, or it refers to a version that was not copied.
+func (r *fileRepository) MoveToBucket(ctx context.Context, fileID, fromBucket, toBucket, location string, versionIDs map[string]string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var writing int64
+		if err := tx.Model(&entity.UploadJobs{}).
+			Where("file_id = ? AND status IN ?", fileID, []string{constant.JobStatusPending, constant.JobStatusCompensating}).
+			Count(&writing).Error; err != nil {
+			return fmt.Errorf("failed to check upload jobs: %w", err)
+		}
+		if writing > 0 {
+			return model.ErrFileMoveConflict
+		}
+
+		tables := []interface{}{&entity.Metadata{}, &entity.FileVersions{}}
+		for _, table := range tables {
+			var referenced []string
+			if err := tx.Unscoped().Model(table).Where("file_id = ?", fileID).
+				Select("COALESCE(version_id, '')").Scan(&referenced).Error; err != nil {
+				return fmt.Errorf("failed to read file versions: %w", err)
+			}
+			for _, versionID := range referenced {
+				if _, copied := versionIDs[versionID]; !copied {
+					return model.ErrFileMoveConflict
+				}
+			}
+		}
+
+		// The contents are unchanged, so the file keeps its modification time
+		result := tx.Unscoped().Model(&entity.Files{}).
+			Where("id = ? AND COALESCE(bucket_name, '') = ?", fileID, fromBucket).
+			UpdateColumns(map[string]interface{}{"bucket_name": toBucket, "location": location})
+		if result.Error != nil {
+			slog.Error("Failed to move file", slog.String("fileID", fileID), slog.Any("error", result.Error))
+			return fmt.Errorf("failed to move file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFileMoveConflict
+		}
+
+		for from, to := range versionIDs {
+			for _, table := range tables {
+				if err := tx.Unscoped().Model(table).
+					Where("file_id = ? AND COALESCE(version_id, '') = ?", fileID, from).
+					UpdateColumn("version_id", to).Error; err != nil {
+					return fmt.Errorf("failed to move file versions: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// listLifecycleBatch returns the next batch of query in ID order, so files left in place are passed over.
+// Files on legal hold or under retention are left out, as no lifecycle rule may remove them.
+func (r *fileRepository) listLifecycleBatch(ctx context.Context, query *gorm.DB, afterID string, limit int) ([]entity.Files, error) {
+	var files []entity.Files
+	if afterID != "" {
+		query = query.Where("id > ?", afterID)
+	}
+	if err := query.Order("id asc").Limit(limit).Find(&files).Error; err != nil {
+		slog.Error("Failed to list files for lifecycle rules", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to list files for lifecycle rules: %w", err)
+	}
+	return files, nil
 }
 
 // WithTransaction executes a function within a database transaction.
@@ -142,6 +416,10 @@ func (r *fileRepository) CreateFileWithMetadata(ctx context.Context, file *entit
 		if err := tx.Create(metadata).Error; err != nil {
 			return fmt.Errorf("failed to create metadata: %w", err)
 		}
+		// A file created without a status takes the column default, stored
+		if file.Status == "" || file.Status == constant.FileStatusStored {
+			return adjustAppUsage(tx, file.AppID, file.Size, 1)
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -234,29 +512,48 @@ func (r *fileRepository) GetAllMetadata(ctx context.Context) ([]entity.Metadata,
 	return metadata, nil
 }
 
-// RestoreFile restores a soft-deleted file and its metadata.
+// RestoreFile restores a soft-deleted file and its metadata, failing with ErrPathConflict when
+// a folder or another file has taken its path since it was deleted.
 func (r *fileRepository) RestoreFile(ctx context.Context, fileID string) error {
 	if fileID == "" {
 		return errors.New("file ID cannot be empty")
 	}
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deleted entity.Files
+		result := tx.Unscoped().Select("id", "app_id", "path").Where("id = ?", fileID).Limit(1).Find(&deleted)
+		if result.Error != nil {
+			return fmt.Errorf("failed to read file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFileNotFound
+		}
+		if deleted.Path != "" {
+			if err := ensurePathFree(tx, deleted.AppID, deleted.Path, fileID); err != nil {
+				return err
+			}
+		}
+
 		// Restore metadata first
-		result := tx.Model(&entity.Metadata{}).Unscoped().Where("file_id = ?", fileID).Update("deleted_at", nil)
+		result = tx.Model(&entity.Metadata{}).Unscoped().Where("file_id = ?", fileID).Update("deleted_at", nil)
 		if result.Error != nil {
 			slog.Error("Failed to restore metadata", slog.String("fileID", fileID), slog.Any("error", result.Error))
 			return fmt.Errorf("failed to restore metadata: %w", result.Error)
 		}
 
-		// Restore file
-		result = tx.Model(&entity.Files{}).Unscoped().Where("id = ?", fileID).Update("deleted_at", nil)
+		// Restore file; only a file that was deleted counts towards its app again
+		result = tx.Model(&entity.Files{}).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", fileID).Update("deleted_at", nil)
 		if result.Error != nil {
 			slog.Error("Failed to restore file", slog.String("fileID", fileID), slog.Any("error", result.Error))
 			return fmt.Errorf("failed to restore file: %w", result.Error)
 		}
 
-		if result.RowsAffected == 0 {
+		file, err := usageOf(tx, fileID)
+		if err != nil {
 			return errors.New("file not found or not deleted")
 		}
+		if result.RowsAffected > 0 && file.Status == constant.FileStatusStored {
+			return adjustAppUsage(tx, file.AppID, file.Size, 1)
+		}
 
 		return nil
 	})
@@ -313,16 +610,28 @@ func (r *fileRepository) GetAllKeyUIDs(ctx context.Context) ([]string, error) {
 
 // BatchUpdateEncKeys performs a batch update of encryption keys for multiple key UIDs.
 // Uses raw SQL for better performance compared to individual updates.
+// Older file versions sealed under the same keys are rewrapped in the same transaction.
 func (r *fileRepository) BatchUpdateEncKeys(ctx context.Context, updates map[string]string) error {
 	if len(updates) == 0 {
 		return nil // nothing to do
 	}
 
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"metadata", "file_versions"} {
+			if err := batchUpdateEncKeys(tx, table, updates); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func batchUpdateEncKeys(tx *gorm.DB, table string, updates map[string]string) error {
 	var caseSQL strings.Builder
 	var args []interface{}
 	var uids []interface{}
 
-	caseSQL.WriteString("UPDATE metadata SET enc_key = CASE key_uid\n")
+	caseSQL.WriteString("UPDATE " + table + " SET enc_key = CASE key_uid\n")
 
 	for uid, encKey := range updates {
 		caseSQL.WriteString("WHEN ? THEN ?\n")
@@ -343,7 +652,7 @@ func (r *fileRepository) BatchUpdateEncKeys(ctx context.Context, updates map[str
 	args = append(args, uids...)
 
 	// Execute the raw SQL
-	if err := r.db.WithContext(ctx).Exec(caseSQL.String(), args...).Error; err != nil {
+	if err := tx.Exec(caseSQL.String(), args...).Error; err != nil {
 		return fmt.Errorf("batch update failed: %w", err)
 	}
 
@@ -356,10 +665,16 @@ func (r *fileRepository) UpdateEncKeyByKeyUID(ctx context.Context, keyUID string
 		return fmt.Errorf("keyUID and newEncKey cannot be empty")
 	}
 
-	err := r.db.WithContext(ctx).
-		Model(&entity.Metadata{}).
-		Where("key_uid = ?", keyUID).
-		Update("enc_key", newEncKey).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.Metadata{}).
+			Where("key_uid = ?", keyUID).
+			Update("enc_key", newEncKey).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entity.FileVersions{}).
+			Where("key_uid = ?", keyUID).
+			Update("enc_key", newEncKey).Error
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to update enc_key for key_uid %s: %w", keyUID, err)
@@ -369,13 +684,15 @@ func (r *fileRepository) UpdateEncKeyByKeyUID(ctx context.Context, keyUID string
 }
 
 // getListFiles is a helper function that retrieves paginated file lists with optional filtering.
-// It supports both application-specific and admin queries.
+// It supports both application-specific and admin queries; an empty appID lists the files of every app.
+// Pages continue after filter.Cursor when set, and a next cursor is returned whenever the page is full.
 func (r *fileRepository) getListFiles(
 	ctx context.Context,
-	filter map[string]interface{},
+	appID string,
+	filter model.FileFilter,
 	offset, limit int,
 	orderBy, sort string,
-) (int64, []entity.Files, error) {
+) (int64, []entity.Files, string, error) {
 	var total int64
 	files := make([]entity.Files, 0)
 
@@ -396,36 +713,137 @@ func (r *fileRepository) getListFiles(
 	}
 
 	// Apply filters
-	query := r.db.WithContext(ctx).Model(&entity.Files{})
-	if filter != nil {
-		query = query.Where(filter)
+	query := applyFileFilter(r.db.WithContext(ctx).Model(&entity.Files{}), filter)
+	if appID != "" {
+		query = query.Where("app_id = ?", appID)
 	}
 
 	// Count total
-	if err := query.Count(&total).Error; err != nil {
-		return 0, nil, fmt.Errorf("failed to count files: %w", err)
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, nil, "", fmt.Errorf("failed to count files: %w", err)
 	}
 
-	if filter != nil {
-		if err := r.db.WithContext(ctx).
-			Model(&entity.Files{}).
-			Where("app_id = ?", filter["app_id"]).
-			Offset(offset).
-			Limit(limit).
-			Order(fmt.Sprintf("%s %s", orderBy, sort)).
-			Find(&files).Error; err != nil {
-			return 0, nil, fmt.Errorf("failed to get list of files: %w", err)
+	page := query.Session(&gorm.Session{})
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor, orderBy, sort)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		if page, err = applyKeyset(page, cursor, []string{"created_at"}, []string{"size"}); err != nil {
+			return 0, nil, "", err
 		}
 	} else {
-		if err := r.db.WithContext(ctx).
-			Model(&entity.Files{}).
-			Offset(offset).
-			Limit(limit).
-			Unscoped().
-			Order(fmt.Sprintf("%s %s", orderBy, sort)).
-			Find(&files).Error; err != nil {
-			return 0, nil, fmt.Errorf("failed to get list of files: %w", err)
+		page = page.Offset(offset)
+	}
+	if err := page.
+		Limit(limit).
+		Order(fmt.Sprintf("%s %s, id %s", orderBy, sort, sort)).
+		Find(&files).Error; err != nil {
+		return 0, nil, "", fmt.Errorf("failed to get list of files: %w", err)
+	}
+
+	var nextCursor string
+	if limit > 0 && len(files) == limit {
+		last := files[len(files)-1]
+		var value interface{}
+		switch orderBy {
+		case "name":
+			value = last.Name
+		case "size":
+			value = last.Size
+		default:
+			value = last.CreatedAt
 		}
+		nextCursor = encodeCursor(orderBy, sort, value, last.ID)
+	}
+	return total, files, nextCursor, nil
+}
+
+// applyFileFilter narrows a file query to the files matching every set field of the filter
+func applyFileFilter(query *gorm.DB, filter model.FileFilter) *gorm.DB {
+	switch filter.Deleted {
+	case constant.DeletedStateInclude:
+		query = query.Unscoped()
+	case constant.DeletedStateOnly:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.NamePrefix != "" {
+		query = query.Where("name LIKE ? ESCAPE '\\'", prefixPattern(filter.NamePrefix))
+	}
+	if family, ok := strings.CutSuffix(filter.MimeType, "/*"); ok {
+		query = query.Where("mime_type LIKE ? ESCAPE '\\'", prefixPattern(family+"/"))
+	} else if filter.MimeType != "" {
+		query = query.Where("mime_type = ?", filter.MimeType)
+	}
+	if filter.MinSize != nil {
+		query = query.Where("size >= ?", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		query = query.Where("size <= ?", *filter.MaxSize)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.UpdatedAfter != nil {
+		query = query.Where("updated_at >= ?", *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *filter.UpdatedBefore)
+	}
+	return applyTagFilters(query, filter.Tags)
+}
+
+// applyTagFilters keeps the files carrying every filtered tag, with the filtered value where one is given
+func applyTagFilters(query *gorm.DB, tags []model.TagFilter) *gorm.DB {
+	for _, tag := range tags {
+		if tag.Value == nil {
+			query = query.Where("EXISTS (SELECT 1 FROM file_tags WHERE file_tags.file_id = files.id AND file_tags.key = ?)", tag.Key)
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM file_tags WHERE file_tags.file_id = files.id AND file_tags.key = ? AND file_tags.value = ?)", tag.Key, *tag.Value)
+		}
+	}
+	return query
+}
+
+// SetTags replaces the tags of a file in a single transaction.
+func (r *fileRepository) SetTags(ctx context.Context, fileID string, tags map[string]string) error {
+	if fileID == "" {
+		return errors.New("file ID cannot be empty")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", fileID).Delete(&entity.FileTags{}).Error; err != nil {
+			return fmt.Errorf("failed to clear file tags: %w", err)
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]entity.FileTags, 0, len(tags))
+		for key, value := range tags {
+			rows = append(rows, entity.FileTags{FileID: fileID, Key: key, Value: value})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			slog.Error("Failed to save file tags", slog.String("fileID", fileID), slog.Any("error", err))
+			return fmt.Errorf("failed to save file tags: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetTags retrieves the tags of a file as a key/value map.
+func (r *fileRepository) GetTags(ctx context.Context, fileID string) (map[string]string, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+	var rows []entity.FileTags
+	if err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve file tags: %w", err)
+	}
+	tags := make(map[string]string, len(rows))
+	for _, row := range rows {
+		tags[row.Key] = row.Value
 	}
-	return total, files, nil
+	return tags, nil
 }
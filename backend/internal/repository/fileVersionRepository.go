@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// fileVersionRepository implements the FileVersionRepository interface.
+type fileVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewFileVersionRepository creates a new instance of FileVersionRepository.
+func NewFileVersionRepository(db *gorm.DB) FileVersionRepository {
+	return &fileVersionRepository{db: db}
+}
+
+// GetByFileID retrieves all versions of a file, newest first.
+func (r *fileVersionRepository) GetByFileID(ctx context.Context, fileID string) ([]entity.FileVersions, error) {
+	var versions []entity.FileVersions
+	if err := r.db.WithContext(ctx).
+		Where("file_id = ?", fileID).
+		Order("version desc").
+		Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get file versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetByVersion retrieves a single version of a file by its number.
+func (r *fileVersionRepository) GetByVersion(ctx context.Context, fileID string, version int) (*entity.FileVersions, error) {
+	var fileVersion entity.FileVersions
+	if err := r.db.WithContext(ctx).
+		Where("file_id = ? AND version = ?", fileID, version).
+		First(&fileVersion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrFileVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to get file version: %w", err)
+	}
+	return &fileVersion, nil
+}
+
+// recordFileVersion snapshots the current file and metadata rows as the next version of a file.
+// It runs inside the transaction that committed them, so every committed state has a version.
+func recordFileVersion(tx *gorm.DB, fileID string) error {
+	var file entity.Files
+	if err := tx.Where("id = ?", fileID).First(&file).Error; err != nil {
+		return fmt.Errorf("failed to get file for versioning: %w", err)
+	}
+	var metadata entity.Metadata
+	if err := tx.Where("file_id = ?", fileID).First(&metadata).Error; err != nil {
+		return fmt.Errorf("failed to get metadata for versioning: %w", err)
+	}
+
+	var latest int
+	if err := tx.Model(&entity.FileVersions{}).
+		Where("file_id = ?", fileID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return fmt.Errorf("failed to get latest file version: %w", err)
+	}
+
+	version := &entity.FileVersions{
+		ID:             helper.GenerateCustomUUID().String(),
+		FileID:         fileID,
+		Version:        latest + 1,
+		VersionID:      metadata.VersionID,
+		Name:           file.Name,
+		MimeType:       file.MimeType,
+		Size:           file.Size,
+		Hash:           metadata.Hash,
+		EncHash:        metadata.EncHash,
+		KeyUID:         metadata.KeyUID,
+		EncKey:         metadata.EncKey,
+		KeyFingerprint: metadata.KeyFingerprint,
+		KeyAlgo:        metadata.KeyAlgo,
+		HashAlgo:       metadata.HashAlgo,
+		Format:         metadata.Format,
+		HeaderVersion:  metadata.HeaderVersion,
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return fmt.Errorf("failed to create file version: %w", err)
+	}
+	return nil
+}
+
+// backfillFileVersion records the current state of a file committed before versions were tracked,
+// so that updating it does not lose the only copy of its key and hash.
+func backfillFileVersion(tx *gorm.DB, fileID string) error {
+	var count int64
+	if err := tx.Model(&entity.FileVersions{}).Where("file_id = ?", fileID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count file versions: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	return recordFileVersion(tx, fileID)
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// folderRepository implements the FolderRepository interface for the folder tree.
+type folderRepository struct {
+	db *gorm.DB
+}
+
+// NewFolderRepository creates a new instance of FolderRepository.
+func NewFolderRepository(db *gorm.DB) FolderRepository {
+	return &folderRepository{db: db}
+}
+
+// Create adds a new folder, failing with ErrPathConflict when a folder or file already has its path.
+func (r *folderRepository) Create(ctx context.Context, folder *entity.Folders) error {
+	if folder == nil {
+		return errors.New("folder cannot be nil")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensurePathFree(tx, folder.AppID, folder.Path, ""); err != nil {
+			return err
+		}
+		if err := tx.Create(folder).Error; err != nil {
+			slog.Error("Failed to create folder", slog.String("appID", folder.AppID), slog.String("path", folder.Path), slog.Any("error", err))
+			return fmt.Errorf("failed to create folder: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByPath retrieves a folder of an app by its absolute path.
+func (r *folderRepository) GetByPath(ctx context.Context, appID, folderPath string) (*entity.Folders, error) {
+	if appID == "" || folderPath == "" {
+		return nil, errors.New("app ID and path cannot be empty")
+	}
+	var folder entity.Folders
+	if err := r.db.WithContext(ctx).Where("app_id = ? AND path = ?", appID, folderPath).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrFolderNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve folder: %w", err)
+	}
+	return &folder, nil
+}
+
+// GetFileByPath retrieves a file of an app by its absolute path.
+func (r *folderRepository) GetFileByPath(ctx context.Context, appID, filePath string) (*entity.Files, error) {
+	if appID == "" || filePath == "" {
+		return nil, errors.New("app ID and path cannot be empty")
+	}
+	var file entity.Files
+	if err := r.db.WithContext(ctx).Where("app_id = ? AND path = ?", appID, filePath).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve file: %w", err)
+	}
+	return &file, nil
+}
+
+// PathTaken reports whether a folder or file of an app already has the given path.
+func (r *folderRepository) PathTaken(ctx context.Context, appID, entryPath string) (bool, error) {
+	err := ensurePathFree(r.db.WithContext(ctx), appID, entryPath, "")
+	if errors.Is(err, model.ErrPathConflict) {
+		return true, nil
+	}
+	return false, err
+}
+
+// ListChildren retrieves the folders and files directly inside a folder, ordered by name.
+// A nil folder ID lists the root; files that were never placed in the tree are not part of it.
+func (r *folderRepository) ListChildren(ctx context.Context, appID string, folderID *string) ([]entity.Folders, []entity.Files, error) {
+	if appID == "" {
+		return nil, nil, errors.New("app ID cannot be empty")
+	}
+
+	folderQuery := r.db.WithContext(ctx).Where("app_id = ?", appID)
+	fileQuery := r.db.WithContext(ctx).Where("app_id = ? AND path <> ''", appID)
+	if folderID == nil {
+		folderQuery = folderQuery.Where("parent_id IS NULL")
+		fileQuery = fileQuery.Where("folder_id IS NULL")
+	} else {
+		folderQuery = folderQuery.Where("parent_id = ?", *folderID)
+		fileQuery = fileQuery.Where("folder_id = ?", *folderID)
+	}
+
+	var folders []entity.Folders
+	if err := folderQuery.Order("name ASC").Find(&folders).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve folders: %w", err)
+	}
+	var files []entity.Files
+	if err := fileQuery.Order("path ASC").Find(&files).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve files: %w", err)
+	}
+	return folders, files, nil
+}
+
+// ListFilesUnder retrieves the files anywhere below a folder path.
+func (r *folderRepository) ListFilesUnder(ctx context.Context, appID, folderPath string) ([]entity.Files, error) {
+	if appID == "" || folderPath == "" {
+		return nil, errors.New("app ID and path cannot be empty")
+	}
+	var files []entity.Files
+	if err := r.db.WithContext(ctx).
+		Where("app_id = ? AND path LIKE ? ESCAPE '\\'", appID, descendantPattern(folderPath)).
+		Order("path ASC").
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve files: %w", err)
+	}
+	return files, nil
+}
+
+// PlaceFile moves a file to a path in the given folder and renames it after the last path element.
+func (r *folderRepository) PlaceFile(ctx context.Context, appID, fileID string, folderID *string, filePath string) error {
+	if appID == "" || fileID == "" || filePath == "" {
+		return errors.New("app ID, file ID and path cannot be empty")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensurePathFree(tx, appID, filePath, fileID); err != nil {
+			return err
+		}
+		result := tx.Model(&entity.Files{}).
+			Where("id = ? AND app_id = ?", fileID, appID).
+			Updates(map[string]interface{}{"folder_id": folderID, "path": filePath, "name": path.Base(filePath)})
+		if result.Error != nil {
+			slog.Error("Failed to place file", slog.String("fileID", fileID), slog.String("path", filePath), slog.Any("error", result.Error))
+			return fmt.Errorf("failed to place file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFileNotFound
+		}
+		return nil
+	})
+}
+
+// Move moves a folder to a new parent and path, rewriting the paths of every folder and file below it.
+// Deleted files are rewritten too, so that they keep pointing at their folder.
+func (r *folderRepository) Move(ctx context.Context, folder *entity.Folders, parentID *string, newPath string) error {
+	if folder == nil || newPath == "" {
+		return errors.New("folder and path cannot be empty")
+	}
+	oldPath := folder.Path
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensurePathFree(tx, folder.AppID, newPath, ""); err != nil {
+			return err
+		}
+
+		result := tx.Model(&entity.Folders{}).
+			Where("id = ?", folder.ID).
+			Updates(map[string]interface{}{"parent_id": parentID, "name": path.Base(newPath), "path": newPath})
+		if result.Error != nil {
+			return fmt.Errorf("failed to move folder: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFolderNotFound
+		}
+
+		// Swap the old prefix for the new one below the folder; substr counts characters from 1
+		pattern := descendantPattern(oldPath)
+		rewrite := gorm.Expr("? || substr(path, ?)", newPath, utf8.RuneCountInString(oldPath)+1)
+		if err := tx.Model(&entity.Folders{}).
+			Where("app_id = ? AND path LIKE ? ESCAPE '\\'", folder.AppID, pattern).
+			Update("path", rewrite).Error; err != nil {
+			return fmt.Errorf("failed to move subfolders: %w", err)
+		}
+		if err := tx.Unscoped().Model(&entity.Files{}).
+			Where("app_id = ? AND path LIKE ? ESCAPE '\\'", folder.AppID, pattern).
+			Update("path", rewrite).Error; err != nil {
+			return fmt.Errorf("failed to move files: %w", err)
+		}
+
+		folder.ParentID = parentID
+		folder.Name = path.Base(newPath)
+		folder.Path = newPath
+		return nil
+	})
+}
+
+// DeleteTree removes a folder and every folder below it. Files still below it must be deleted first;
+// deleted files left behind are taken out of the tree so the path can be reused.
+func (r *folderRepository) DeleteTree(ctx context.Context, appID, folderPath string) error {
+	if appID == "" || folderPath == "" {
+		return errors.New("app ID and path cannot be empty")
+	}
+	pattern := descendantPattern(folderPath)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var remaining int64
+		if err := tx.Model(&entity.Files{}).
+			Where("app_id = ? AND path LIKE ? ESCAPE '\\'", appID, pattern).
+			Count(&remaining).Error; err != nil {
+			return fmt.Errorf("failed to count files: %w", err)
+		}
+		if remaining > 0 {
+			return model.ErrFolderNotEmpty
+		}
+
+		if err := tx.Unscoped().Model(&entity.Files{}).
+			Where("app_id = ? AND path LIKE ? ESCAPE '\\'", appID, pattern).
+			Updates(map[string]interface{}{"folder_id": nil, "path": ""}).Error; err != nil {
+			return fmt.Errorf("failed to detach deleted files: %w", err)
+		}
+
+		result := tx.Where("app_id = ? AND (path = ? OR path LIKE ? ESCAPE '\\')", appID, folderPath, pattern).Delete(&entity.Folders{})
+		if result.Error != nil {
+			slog.Error("Failed to delete folder", slog.String("appID", appID), slog.String("path", folderPath), slog.Any("error", result.Error))
+			return fmt.Errorf("failed to delete folder: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFolderNotFound
+		}
+		return nil
+	})
+}
+
+// ensurePathFree fails with ErrPathConflict when a folder or a live file of the app has the path.
+// exceptFileID lets a file keep its own path when it is moved onto it.
+func ensurePathFree(tx *gorm.DB, appID, entryPath, exceptFileID string) error {
+	var count int64
+	if err := tx.Model(&entity.Folders{}).Where("app_id = ? AND path = ?", appID, entryPath).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check folder path: %w", err)
+	}
+	if count > 0 {
+		return model.ErrPathConflict
+	}
+	if err := tx.Model(&entity.Files{}).Where("app_id = ? AND path = ? AND id <> ?", appID, entryPath, exceptFileID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check file path: %w", err)
+	}
+	if count > 0 {
+		return model.ErrPathConflict
+	}
+	return nil
+}
+
+// descendantPattern returns a LIKE pattern matching every path below a folder, escaping wildcards in the path
+func descendantPattern(folderPath string) string {
+	return prefixPattern(folderPath + "/")
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model/constant"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// objectReplicaRepository implements the ObjectReplicaRepository interface for replicated storage.
+type objectReplicaRepository struct {
+	db *gorm.DB
+}
+
+// NewObjectReplicaRepository creates a new instance of ObjectReplicaRepository.
+func NewObjectReplicaRepository(db *gorm.DB) ObjectReplicaRepository {
+	return &objectReplicaRepository{db: db}
+}
+
+// Create adds a new object replica record.
+func (r *objectReplicaRepository) Create(ctx context.Context, replica *entity.ObjectReplicas) error {
+	if replica == nil {
+		return errors.New("object replica cannot be nil")
+	}
+	if err := r.db.WithContext(ctx).Create(replica).Error; err != nil {
+		slog.Error("Failed to create object replica", slog.String("replica", replica.Replica),
+			slog.String("object", replica.ObjectName), slog.Any("error", err))
+		return fmt.Errorf("failed to create object replica: %w", err)
+	}
+	return nil
+}
+
+// Update saves the state of an existing object replica record.
+func (r *objectReplicaRepository) Update(ctx context.Context, replica *entity.ObjectReplicas) error {
+	if replica == nil || replica.ID == "" {
+		return errors.New("object replica ID cannot be empty")
+	}
+	if err := r.db.WithContext(ctx).Save(replica).Error; err != nil {
+		slog.Error("Failed to update object replica", slog.String("id", replica.ID), slog.Any("error", err))
+		return fmt.Errorf("failed to update object replica: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an object replica record by its ID.
+func (r *objectReplicaRepository) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("object replica ID cannot be empty")
+	}
+	if err := r.db.WithContext(ctx).Delete(&entity.ObjectReplicas{}, "id = ?", id).Error; err != nil {
+		slog.Error("Failed to delete object replica", slog.String("id", id), slog.Any("error", err))
+		return fmt.Errorf("failed to delete object replica: %w", err)
+	}
+	return nil
+}
+
+// ListByObject retrieves the replica records of every version of an object on every replica, oldest first.
+func (r *objectReplicaRepository) ListByObject(ctx context.Context, bucketName, objectName string) ([]entity.ObjectReplicas, error) {
+	var replicas []entity.ObjectReplicas
+	if err := r.db.WithContext(ctx).
+		Where("bucket_name = ? AND object_name = ?", bucketName, objectName).
+		Order("created_at asc").
+		Find(&replicas).Error; err != nil {
+		return nil, fmt.Errorf("failed to list object replicas: %w", err)
+	}
+	return replicas, nil
+}
+
+// GetDue retrieves pending replica records whose next attempt is at or before now.
+func (r *objectReplicaRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]entity.ObjectReplicas, error) {
+	var replicas []entity.ObjectReplicas
+	if err := r.db.WithContext(ctx).
+		Where("status = ?", constant.ReplicaStatusPending).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at asc").
+		Limit(limit).
+		Find(&replicas).Error; err != nil {
+		slog.Error("Failed to get due object replicas", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to get due object replicas: %w", err)
+	}
+	return replicas, nil
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -16,18 +18,42 @@ type FileRepository interface {
 	GetByID(ctx context.Context, id string) (*entity.Files, error)
 	// GetByHash retrieves a file by its hash value.
 	GetByHash(ctx context.Context, hash string) (*entity.Files, error)
-	// GetListFiles returns a paginated list of files for an app.
-	GetListFiles(ctx context.Context, appID string, offset, limit int, orderBy, sort string) (int64, []entity.Files, error)
-	// GetListFilesForAdmin returns a paginated list of files for admin users.
-	GetListFilesForAdmin(ctx context.Context, offset, limit int, orderBy, sort string) (int64, []entity.Files, error)
+	// GetListFiles returns a page of the files of an app narrowed by filter, with the total count and the cursor of the next page.
+	GetListFiles(ctx context.Context, appID string, filter model.FileFilter, offset, limit int, orderBy, sort string) (int64, []entity.Files, string, error)
+	// GetListFilesForAdmin returns a page of the files of every app narrowed by filter, with the total count and the cursor of the next page.
+	GetListFilesForAdmin(ctx context.Context, filter model.FileFilter, offset, limit int, orderBy, sort string) (int64, []entity.Files, string, error)
 	// GetAll retrieves all files.
 	GetAll(ctx context.Context) ([]entity.Files, error)
 	// Update modifies an existing file record.
 	Update(ctx context.Context, file *entity.Files) error
 	// Delete removes a file by its ID.
 	Delete(ctx context.Context, id string) error
-	// RestoreFile restores a deleted file by its ID.
+	// RestoreFile restores a deleted file by its ID, failing with ErrPathConflict when its path is taken.
 	RestoreFile(ctx context.Context, fileID string) error
+	// WipeKeys blanks every wrapped key held for a file and returns how many were wiped.
+	WipeKeys(ctx context.Context, fileID string) (int64, error)
+	// Purge permanently removes a file with its metadata, versions, tags, share links and upload records.
+	Purge(ctx context.Context, fileID string) error
+	// SetExpiry sets when a file expires, or clears its expiry when expiresAt is nil.
+	SetExpiry(ctx context.Context, fileID string, expiresAt *time.Time) error
+	// GetHoldableByID retrieves a file by its ID whether or not it is soft deleted.
+	GetHoldableByID(ctx context.Context, id string) (*entity.Files, error)
+	// SetLegalHold places or releases the legal hold on a file, soft deleted or not.
+	SetLegalHold(ctx context.Context, fileID string, hold bool) error
+	// SetRetention sets the date before which a file, soft deleted or not, cannot be deleted or overwritten.
+	SetRetention(ctx context.Context, fileID string, retainUntil time.Time) error
+	// BackfillHashAlgo records hashMethod as the hash algorithm of every metadata, version and upload session row without one.
+	BackfillHashAlgo(ctx context.Context, hashMethod string) (int64, error)
+	// ListExpiredFiles retrieves a batch of stored files whose own expiry has passed.
+	ListExpiredFiles(ctx context.Context, now time.Time, afterID string, limit int) ([]entity.Files, error)
+	// ListFilesCreatedBefore retrieves a batch of stored files of an app created before a time and without their own expiry.
+	ListFilesCreatedBefore(ctx context.Context, appID string, before time.Time, afterID string, limit int) ([]entity.Files, error)
+	// ListDeletedFilesBefore retrieves a batch of files of an app soft deleted before a time.
+	ListDeletedFilesBefore(ctx context.Context, appID string, before time.Time, afterID string, limit int) ([]entity.Files, error)
+	// ListFilesOutsideBucket retrieves a batch of stored files of an app, soft deleted or not, kept in any other bucket.
+	ListFilesOutsideBucket(ctx context.Context, appID, bucketName, afterID string, limit int) ([]entity.Files, error)
+	// MoveToBucket points a file and the storage versions of its contents at the copies made in another bucket.
+	MoveToBucket(ctx context.Context, fileID, fromBucket, toBucket, location string, versionIDs map[string]string) error
 	// WithTransaction executes a function within a database transaction.
 	WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error
 	// CreateFileWithMetadata adds a new file and its metadata.
@@ -50,6 +76,10 @@ type FileRepository interface {
 	UpdateEncKeyByKeyUID(ctx context.Context, keyUID string, newEncKey string) error
 	// BatchUpdateEncKeys updates multiple encryption keys in batch.
 	BatchUpdateEncKeys(ctx context.Context, updates map[string]string) error
+	// SetTags replaces the tags of a file.
+	SetTags(ctx context.Context, fileID string, tags map[string]string) error
+	// GetTags retrieves the tags of a file.
+	GetTags(ctx context.Context, fileID string) (map[string]string, error)
 }
 
 // AdminRepository defines the contract for admin data access operations.
@@ -98,19 +128,158 @@ type ApplicationRepository interface {
 	GetListApps(ctx context.Context, offset, limit int, orderBy, sort string) (int64, *[]entity.Apps, error)
 	// GetAll retrieves all applications.
 	GetAll(ctx context.Context) ([]entity.Apps, error)
+	// ListWithLifecycle retrieves the applications with a file expiry or deleted file retention rule.
+	ListWithLifecycle(ctx context.Context) ([]entity.Apps, error)
+	// GetUsage retrieves the bytes and files an application stores.
+	GetUsage(ctx context.Context, appID string) (*entity.AppUsage, error)
+	// BackfillUsage counts the stored files of every application that has no usage totals yet.
+	BackfillUsage(ctx context.Context) (int64, error)
+	// ListPendingBucketMigrations retrieves the applications whose files were asked to move into their bucket.
+	ListPendingBucketMigrations(ctx context.Context) ([]entity.Apps, error)
+	// FinishBucketMigration clears the pending bucket migration of an application unless it was asked for again after requestedAt.
+	FinishBucketMigration(ctx context.Context, appID string, requestedAt time.Time) error
+	// CountFilesOutsideBucket counts the stored files of an application kept in any other bucket.
+	CountFilesOutsideBucket(ctx context.Context, appID, bucketName string) (int64, error)
+	// GetByFileID retrieves the application a file belongs to, including soft-deleted files and applications.
+	GetByFileID(ctx context.Context, fileID string) (*entity.Apps, error)
 }
 
 // FileLogsRepository defines the contract for file log data access operations.
 // It provides methods for creating, listing, retrieving, and deleting file logs.
 type FileLogsRepository interface {
-	// Create adds a new file log record.
+	// Create appends a new file log record to the audit chain.
 	Create(ctx context.Context, log *entity.FileLogs) error
-	// List returns a paginated list of file logs.
-	List(ctx context.Context, offset, limit int, orderBy, sort string) (int64, *[]entity.FileLogs, error)
+	// List returns a page of file logs narrowed by filter, with the total count and the cursor of the next page.
+	List(ctx context.Context, filter model.LogFilter, offset, limit int, orderBy, sort string) (int64, *[]entity.FileLogs, string, error)
+	// Stream hands the file logs matching filter to fn in batches, in chain order.
+	Stream(ctx context.Context, filter model.LogFilter, batchSize int, fn func(batch []entity.FileLogs) error) error
+	// GetByID retrieves a single file log by its ID.
+	GetByID(ctx context.Context, id uint) (*entity.FileLogs, error)
 	// GetByFileID retrieves file logs by file ID.
 	GetByFileID(ctx context.Context, fileID string) (*[]entity.FileLogs, error)
 	// GetByAction retrieves file logs by action type.
 	GetByAction(ctx context.Context, action string) (*[]entity.FileLogs, error)
-	// DeleteOldLogs deletes file logs older than the specified number of days.
-	DeleteOldLogs(ctx context.Context, days int) error
+	// DeleteOldLogs deletes file logs older than the specified number of days, anchoring the rest of the chain with a checkpoint signed by sign.
+	DeleteOldLogs(ctx context.Context, days int, sign CheckpointSigner) error
+	// CountOldLogs counts the file logs DeleteOldLogs would remove for the same number of days.
+	CountOldLogs(ctx context.Context, days int) (int64, error)
+	// CreateCheckpoint signs a checkpoint over the head of the audit chain; it returns nil when there is nothing new to cover
+	// and the last checkpoint is younger than maxAge.
+	CreateCheckpoint(ctx context.Context, maxAge time.Duration, sign CheckpointSigner) (*entity.AuditCheckpoints, error)
+	// ListCheckpoints retrieves every checkpoint over the audit chain, in chain order.
+	ListCheckpoints(ctx context.Context) ([]entity.AuditCheckpoints, error)
+	// ListChain retrieves up to limit entries of the audit chain following the entry afterID, in chain order.
+	ListChain(ctx context.Context, afterID uint, limit int) ([]entity.FileLogs, error)
+}
+
+// UploadJobRepository defines the contract for the upload outbox.
+// It tracks objects written to storage until their file and metadata rows are committed or compensated.
+type UploadJobRepository interface {
+	// Create adds a new upload job, together with the pending file row when file is not nil.
+	Create(ctx context.Context, job *entity.UploadJobs, file *entity.Files) error
+	// Update saves the state of an upload job that is still in status from.
+	Update(ctx context.Context, job *entity.UploadJobs, from string) error
+	// GetLatestByFileID retrieves the most recent upload job for a file.
+	GetLatestByFileID(ctx context.Context, fileID string) (*entity.UploadJobs, error)
+	// GetDue retrieves pending and compensating jobs whose next attempt is due.
+	GetDue(ctx context.Context, now time.Time, limit int) ([]entity.UploadJobs, error)
+	// Complete commits the job's file and metadata rows as a new file version and marks the job done.
+	Complete(ctx context.Context, job *entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error
+	// Fail marks the job failed and, for uploads, marks the file failed.
+	Fail(ctx context.Context, job *entity.UploadJobs) error
+}
+
+// ObjectReplicaRepository defines the contract for the object versions replicated storage keeps on its replicas.
+// Pending records form the repair queue; synced records map primary versions to replica versions.
+type ObjectReplicaRepository interface {
+	// Create adds a new object replica record.
+	Create(ctx context.Context, replica *entity.ObjectReplicas) error
+	// Update saves the state of an existing object replica record.
+	Update(ctx context.Context, replica *entity.ObjectReplicas) error
+	// Delete removes an object replica record by its ID.
+	Delete(ctx context.Context, id string) error
+	// ListByObject retrieves the replica records of an object on every replica, oldest first.
+	ListByObject(ctx context.Context, bucketName, objectName string) ([]entity.ObjectReplicas, error)
+	// GetDue retrieves pending replica records whose next attempt is due.
+	GetDue(ctx context.Context, now time.Time, limit int) ([]entity.ObjectReplicas, error)
+}
+
+// FileVersionRepository defines the contract for the version history of files.
+// Versions are recorded by the repositories that commit file contents; this interface only reads them.
+type FileVersionRepository interface {
+	// GetByFileID retrieves all versions of a file, newest first.
+	GetByFileID(ctx context.Context, fileID string) ([]entity.FileVersions, error)
+	// GetByVersion retrieves a single version of a file by its number.
+	GetByVersion(ctx context.Context, fileID string, version int) (*entity.FileVersions, error)
+}
+
+// UploadSessionRepository defines the contract for resumable upload sessions and their parts.
+type UploadSessionRepository interface {
+	// Create adds a new upload session together with its pending file row.
+	Create(ctx context.Context, session *entity.UploadSessions, file *entity.Files) error
+	// GetByID retrieves an upload session by its ID.
+	GetByID(ctx context.Context, id string) (*entity.UploadSessions, error)
+	// Update saves the MIME type, wrapped key and expiry of an existing upload session.
+	Update(ctx context.Context, session *entity.UploadSessions) error
+	// UpdateStatus moves a session to status if it is currently in one of the from states.
+	UpdateStatus(ctx context.Context, session *entity.UploadSessions, from []string, status string) error
+	// Close moves an open session to status and marks its pending file failed.
+	Close(ctx context.Context, session *entity.UploadSessions, status string) error
+	// GetExpired retrieves open and completing sessions that expired at or before now.
+	GetExpired(ctx context.Context, now time.Time, limit int) ([]entity.UploadSessions, error)
+	// SavePart creates or replaces an uploaded part of an open session and saves the session's MIME type and expiry.
+	SavePart(ctx context.Context, session *entity.UploadSessions, part *entity.UploadParts) error
+	// GetParts retrieves the parts of a session ordered by part number.
+	GetParts(ctx context.Context, sessionID string) ([]entity.UploadParts, error)
+	// GetPartsByFileID retrieves the parts a file was assembled from, ordered by part number.
+	GetPartsByFileID(ctx context.Context, fileID string) ([]entity.UploadParts, error)
+}
+
+// ShareLinkRepository defines the contract for time-limited public links to files.
+type ShareLinkRepository interface {
+	// Create adds a new share link.
+	Create(ctx context.Context, link *entity.ShareLinks) error
+	// GetByID retrieves a share link by its ID.
+	GetByID(ctx context.Context, id string) (*entity.ShareLinks, error)
+	// GetByFileID retrieves the share links of a file, newest first.
+	GetByFileID(ctx context.Context, fileID string) ([]entity.ShareLinks, error)
+	// Revoke marks a share link revoked so it can no longer be used.
+	Revoke(ctx context.Context, id string) error
+	// ConsumeDownload counts one download against a link, failing once its download limit is reached.
+	ConsumeDownload(ctx context.Context, id string) error
+}
+
+// UploadTokenRepository defines the contract for pre-signed upload tokens.
+type UploadTokenRepository interface {
+	// Create adds a new upload token.
+	Create(ctx context.Context, token *entity.UploadTokens) error
+	// GetByID retrieves an upload token by its ID.
+	GetByID(ctx context.Context, id string) (*entity.UploadTokens, error)
+	// GetByAppID retrieves the upload tokens issued by an app, newest first.
+	GetByAppID(ctx context.Context, appID string) ([]entity.UploadTokens, error)
+	// Revoke marks an upload token revoked so it can no longer be used.
+	Revoke(ctx context.Context, id string) error
+}
+
+// FolderRepository defines the contract for the folder tree of an app's files.
+// Paths are unique per app across folders and files; every method that creates or moves an entry enforces this.
+type FolderRepository interface {
+	// Create adds a new folder, failing with ErrPathConflict when its path is taken.
+	Create(ctx context.Context, folder *entity.Folders) error
+	// GetByPath retrieves a folder of an app by its absolute path.
+	GetByPath(ctx context.Context, appID, path string) (*entity.Folders, error)
+	// GetFileByPath retrieves a file of an app by its absolute path.
+	GetFileByPath(ctx context.Context, appID, path string) (*entity.Files, error)
+	// PathTaken reports whether a folder or file of an app already has the given path.
+	PathTaken(ctx context.Context, appID, path string) (bool, error)
+	// ListChildren retrieves the folders and files directly inside a folder; a nil folder ID lists the root.
+	ListChildren(ctx context.Context, appID string, folderID *string) ([]entity.Folders, []entity.Files, error)
+	// ListFilesUnder retrieves the files anywhere below a folder path.
+	ListFilesUnder(ctx context.Context, appID, path string) ([]entity.Files, error)
+	// PlaceFile moves a file to a path in the given folder, failing with ErrPathConflict when the path is taken.
+	PlaceFile(ctx context.Context, appID, fileID string, folderID *string, path string) error
+	// Move moves a folder and everything below it to a new parent and path, failing with ErrPathConflict when the path is taken.
+	Move(ctx context.Context, folder *entity.Folders, parentID *string, path string) error
+	// DeleteTree removes a folder and every folder below it, failing with ErrFolderNotEmpty while files remain below it.
+	DeleteTree(ctx context.Context, appID, path string) error
 }
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// shareLinkRepository implements the ShareLinkRepository interface for public file links.
+type shareLinkRepository struct {
+	db *gorm.DB
+}
+
+// NewShareLinkRepository creates a new instance of ShareLinkRepository.
+func NewShareLinkRepository(db *gorm.DB) ShareLinkRepository {
+	return &shareLinkRepository{db: db}
+}
+
+// Create adds a new share link.
+func (r *shareLinkRepository) Create(ctx context.Context, link *entity.ShareLinks) error {
+	if link == nil {
+		return errors.New("share link cannot be nil")
+	}
+	if err := r.db.WithContext(ctx).Create(link).Error; err != nil {
+		slog.Error("Failed to create share link", slog.String("fileID", link.FileID), slog.Any("error", err))
+		return fmt.Errorf("failed to create share link: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a share link by its ID.
+func (r *shareLinkRepository) GetByID(ctx context.Context, id string) (*entity.ShareLinks, error) {
+	if id == "" {
+		return nil, errors.New("share link ID cannot be empty")
+	}
+	var link entity.ShareLinks
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrShareLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve share link: %w", err)
+	}
+	return &link, nil
+}
+
+// GetByFileID retrieves the share links of a file, newest first.
+func (r *shareLinkRepository) GetByFileID(ctx context.Context, fileID string) ([]entity.ShareLinks, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+	var links []entity.ShareLinks
+	if err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve share links: %w", err)
+	}
+	return links, nil
+}
+
+// Revoke marks a share link revoked. Revoking a link twice keeps the original revocation time.
+func (r *shareLinkRepository) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("share link ID cannot be empty")
+	}
+	result := r.db.WithContext(ctx).Model(&entity.ShareLinks{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		slog.Error("Failed to revoke share link", slog.String("linkID", id), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to revoke share link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeDownload counts one download against a link.
+// The count is checked and incremented in one statement so concurrent downloads cannot exceed the limit.
+func (r *shareLinkRepository) ConsumeDownload(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("share link ID cannot be empty")
+	}
+	result := r.db.WithContext(ctx).Model(&entity.ShareLinks{}).
+		Where("id = ? AND revoked_at IS NULL AND (max_downloads = 0 OR downloads < max_downloads)", id).
+		UpdateColumn("downloads", gorm.Expr("downloads + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to count share link download: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrShareLinkExhausted
+	}
+	return nil
+}
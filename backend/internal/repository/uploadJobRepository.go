@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// uploadJobRepository implements the UploadJobRepository interface for the upload outbox.
+type uploadJobRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadJobRepository creates a new instance of UploadJobRepository.
+func NewUploadJobRepository(db *gorm.DB) UploadJobRepository {
+	return &uploadJobRepository{db: db}
+}
+
+// Create adds a new upload job, together with the pending file row when file is not nil, in a single transaction.
+func (r *uploadJobRepository) Create(ctx context.Context, job *entity.UploadJobs, file *entity.Files) error {
+	if job == nil {
+		return errors.New("upload job cannot be nil")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if file != nil {
+			if err := tx.Create(file).Error; err != nil {
+				slog.Error("Failed to create pending file", slog.String("fileID", file.ID), slog.Any("error", err))
+				return fmt.Errorf("failed to create file: %w", err)
+			}
+		}
+		if err := tx.Create(job).Error; err != nil {
+			slog.Error("Failed to create upload job", slog.String("fileID", job.FileID), slog.Any("error", err))
+			return fmt.Errorf("failed to create upload job: %w", err)
+		}
+		return nil
+	})
+}
+
+// Update saves the state of an upload job that is still in status from.
+// It returns ErrUploadJobNotFound when another caller has moved the job on, so a settled job is never reopened.
+func (r *uploadJobRepository) Update(ctx context.Context, job *entity.UploadJobs, from string) error {
+	if job == nil || job.ID == "" {
+		return errors.New("upload job ID cannot be empty")
+	}
+	result := r.db.WithContext(ctx).Model(&entity.UploadJobs{}).
+		Where("id = ? AND status = ?", job.ID, from).
+		Updates(map[string]interface{}{
+			"status":          job.Status,
+			"version_id":      job.VersionID,
+			"payload":         job.Payload,
+			"attempts":        job.Attempts,
+			"next_attempt_at": job.NextAttemptAt,
+			"last_error":      job.LastError,
+		})
+	if result.Error != nil {
+		slog.Error("Failed to update upload job", slog.String("jobID", job.ID), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to update upload job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrUploadJobNotFound
+	}
+	return nil
+}
+
+// GetLatestByFileID retrieves the most recent upload job for a file.
+func (r *uploadJobRepository) GetLatestByFileID(ctx context.Context, fileID string) (*entity.UploadJobs, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+	var job entity.UploadJobs
+	if err := r.db.WithContext(ctx).
+		Where("file_id = ?", fileID).
+		Order("created_at desc").
+		First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrUploadJobNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve upload job: %w", err)
+	}
+	return &job, nil
+}
+
+// GetDue retrieves pending and compensating jobs whose next attempt is at or before now.
+func (r *uploadJobRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]entity.UploadJobs, error) {
+	var jobs []entity.UploadJobs
+	if err := r.db.WithContext(ctx).
+		Where("status IN ?", []string{constant.JobStatusPending, constant.JobStatusCompensating}).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at asc").
+		Limit(limit).
+		Find(&jobs).Error; err != nil {
+		slog.Error("Failed to get due upload jobs", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to get due upload jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Complete commits the file and metadata rows described by a job, records them as a new file version
+// and marks the job done in a single transaction.
+// Uploads promote the pending file row and create its metadata; updates overwrite the existing rows.
+func (r *uploadJobRepository) Complete(ctx context.Context, job *entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error {
+	if job == nil || file == nil || metadata == nil {
+		return errors.New("upload job, file and metadata cannot be nil")
+	}
+	return settleUploadJob(r.db.WithContext(ctx), job, func(tx *gorm.DB) error {
+		// Claim the job first so a concurrent worker cannot commit or compensate it twice
+		if err := claimUploadJob(tx, job, constant.JobStatusDone); err != nil {
+			return err
+		}
+
+		// Keep the state being replaced as a version if it predates version tracking
+		if job.Operation == constant.JobOperationUpdate {
+			if err := backfillFileVersion(tx, file.ID); err != nil {
+				return err
+			}
+		}
+
+		current, err := usageOf(tx, file.ID)
+		if err != nil {
+			return err
+		}
+		// An update written to the bucket the file has since been moved out of cannot become its latest version
+		if job.Operation == constant.JobOperationUpdate && current.BucketName != "" && current.BucketName != job.BucketName {
+			return model.ErrFileMoveConflict
+		}
+
+		file.Status = constant.FileStatusStored
+		result := tx.Model(&entity.Files{}).Where("id = ?", file.ID).Updates(file)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrFileNotFound
+		}
+
+		switch job.Operation {
+		case constant.JobOperationUpload:
+			if err := tx.Create(metadata).Error; err != nil {
+				return fmt.Errorf("failed to create metadata: %w", err)
+			}
+		case constant.JobOperationUpdate:
+			// Every column describing the ciphertext is written, empty ones included, so no value of the
+			// version being replaced survives on the row
+			result = tx.Model(&entity.Metadata{}).Where("file_id = ?", metadata.FileID).
+				Select(metadataVersionColumns).Updates(metadata)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update metadata: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("metadata not found or no changes made")
+			}
+		default:
+			return fmt.Errorf("unknown upload job operation %q", job.Operation)
+		}
+
+		if err := recordFileVersion(tx, file.ID); err != nil {
+			return err
+		}
+
+		// The file counts towards its app from the upload commit on; an update only changes its size
+		switch {
+		case current.Status != constant.FileStatusStored:
+			if err := adjustAppUsage(tx, current.AppID, file.Size, 1); err != nil {
+				return err
+			}
+		case file.Size > 0:
+			if err := adjustAppUsage(tx, current.AppID, file.Size-current.Size, 0); err != nil {
+				return err
+			}
+		}
+
+		job.Payload = ""
+		job.LastError = ""
+		if err := tx.Save(job).Error; err != nil {
+			return fmt.Errorf("failed to update upload job: %w", err)
+		}
+		return nil
+	})
+}
+
+// Fail marks a job as failed and, for uploads, marks the pending file row as failed in a single transaction.
+func (r *uploadJobRepository) Fail(ctx context.Context, job *entity.UploadJobs) error {
+	if job == nil || job.ID == "" {
+		return errors.New("upload job ID cannot be empty")
+	}
+	return settleUploadJob(r.db.WithContext(ctx), job, func(tx *gorm.DB) error {
+		if err := claimUploadJob(tx, job, constant.JobStatusFailed); err != nil {
+			return err
+		}
+
+		if job.Operation == constant.JobOperationUpload {
+			if err := tx.Model(&entity.Files{}).
+				Where("id = ?", job.FileID).
+				Update("status", constant.FileStatusFailed).Error; err != nil {
+				return fmt.Errorf("failed to mark file as failed: %w", err)
+			}
+		}
+
+		job.Payload = ""
+		if err := tx.Save(job).Error; err != nil {
+			return fmt.Errorf("failed to update upload job: %w", err)
+		}
+		return nil
+	})
+}
+
+// metadataVersionColumns are the metadata columns that describe one stored version of a file
+var metadataVersionColumns = []string{"hash", "enc_hash", "key_uid", "enc_key", "key_fingerprint", "key_algo",
+	"hash_algo", "format", "header_version", "version_id"}
+
+// settleUploadJob runs fn in a transaction and puts the job's status back when the transaction is rolled back,
+// so a caller that goes on to update the job does so from the status still stored
+func settleUploadJob(db *gorm.DB, job *entity.UploadJobs, fn func(tx *gorm.DB) error) error {
+	status := job.Status
+	if err := db.Transaction(fn); err != nil {
+		job.Status = status
+		return err
+	}
+	return nil
+}
+
+// claimUploadJob moves a job that is still pending or compensating to its final status.
+// It returns ErrUploadJobNotFound when another caller has already settled the job.
+func claimUploadJob(tx *gorm.DB, job *entity.UploadJobs, status string) error {
+	result := tx.Model(&entity.UploadJobs{}).
+		Where("id = ? AND status IN ?", job.ID, []string{constant.JobStatusPending, constant.JobStatusCompensating}).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("failed to claim upload job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrUploadJobNotFound
+	}
+	job.Status = status
+	return nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// uploadSessionRepository implements the UploadSessionRepository interface for resumable uploads.
+type uploadSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadSessionRepository creates a new instance of UploadSessionRepository.
+func NewUploadSessionRepository(db *gorm.DB) UploadSessionRepository {
+	return &uploadSessionRepository{db: db}
+}
+
+// Create adds a new upload session together with its pending file row in a single transaction.
+func (r *uploadSessionRepository) Create(ctx context.Context, session *entity.UploadSessions, file *entity.Files) error {
+	if session == nil || file == nil {
+		return errors.New("upload session and file cannot be nil")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			slog.Error("Failed to create pending file", slog.String("fileID", file.ID), slog.Any("error", err))
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		if err := tx.Create(session).Error; err != nil {
+			slog.Error("Failed to create upload session", slog.String("fileID", file.ID), slog.Any("error", err))
+			return fmt.Errorf("failed to create upload session: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByID retrieves an upload session by its ID.
+func (r *uploadSessionRepository) GetByID(ctx context.Context, id string) (*entity.UploadSessions, error) {
+	if id == "" {
+		return nil, errors.New("upload session ID cannot be empty")
+	}
+	var session entity.UploadSessions
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// Update saves the MIME type, wrapped key and expiry of an existing upload session.
+// The status is left alone; it only changes through UpdateStatus and Close.
+func (r *uploadSessionRepository) Update(ctx context.Context, session *entity.UploadSessions) error {
+	if session == nil || session.ID == "" {
+		return errors.New("upload session ID cannot be empty")
+	}
+	if err := r.db.WithContext(ctx).Model(&entity.UploadSessions{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"mime_type":  session.MimeType,
+		"enc_key":    session.EncKey,
+		"expires_at": session.ExpiresAt,
+	}).Error; err != nil {
+		slog.Error("Failed to update upload session", slog.String("sessionID", session.ID), slog.Any("error", err))
+		return fmt.Errorf("failed to update upload session: %w", err)
+	}
+	return nil
+}
+
+// UpdateStatus moves a session to status if it is currently in one of the from states.
+// It returns ErrUploadSessionClosed when another request has already moved the session on.
+func (r *uploadSessionRepository) UpdateStatus(ctx context.Context, session *entity.UploadSessions, from []string, status string) error {
+	return transitionUploadSession(r.db.WithContext(ctx), session, from, status)
+}
+
+// Close moves an open session to status and marks its pending file failed in a single transaction.
+func (r *uploadSessionRepository) Close(ctx context.Context, session *entity.UploadSessions, status string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := transitionUploadSession(tx, session, []string{constant.SessionStatusOpen}, status); err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.Files{}).
+			Where("id = ? AND status = ?", session.FileID, constant.FileStatusPending).
+			Update("status", constant.FileStatusFailed).Error; err != nil {
+			return fmt.Errorf("failed to mark file as failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetExpired retrieves open and completing sessions that expired at or before now.
+func (r *uploadSessionRepository) GetExpired(ctx context.Context, now time.Time, limit int) ([]entity.UploadSessions, error) {
+	var sessions []entity.UploadSessions
+	if err := r.db.WithContext(ctx).
+		Where("status IN ?", []string{constant.SessionStatusOpen, constant.SessionStatusCompleting}).
+		Where("expires_at <= ?", now).
+		Order("expires_at asc").
+		Limit(limit).
+		Find(&sessions).Error; err != nil {
+		slog.Error("Failed to get expired upload sessions", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to get expired upload sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// SavePart creates or replaces an uploaded part, so a part can be re-sent after a dropped connection.
+// The session's MIME type and expiry are saved with it, and only while the session is open: the conditional
+// update locks the session row, so a part either lands before a completion reads the parts or is rejected with
+// ErrUploadSessionClosed.
+func (r *uploadSessionRepository) SavePart(ctx context.Context, session *entity.UploadSessions, part *entity.UploadParts) error {
+	if session == nil || session.ID == "" || part == nil {
+		return errors.New("upload session and part cannot be empty")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.UploadSessions{}).
+			Where("id = ? AND status = ?", session.ID, constant.SessionStatusOpen).
+			Updates(map[string]interface{}{
+				"mime_type":  session.MimeType,
+				"expires_at": session.ExpiresAt,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update upload session: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrUploadSessionClosed
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "session_id"}, {Name: "part_number"}},
+			DoUpdates: clause.AssignmentColumns([]string{"e_tag", "size", "enc_size", "hash", "enc_hash", "updated_at"}),
+		}).Create(part).Error; err != nil {
+			slog.Error("Failed to save upload part", slog.String("sessionID", part.SessionID), slog.Int("part", part.PartNumber), slog.Any("error", err))
+			return fmt.Errorf("failed to save upload part: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetParts retrieves the parts of a session ordered by part number.
+func (r *uploadSessionRepository) GetParts(ctx context.Context, sessionID string) ([]entity.UploadParts, error) {
+	var parts []entity.UploadParts
+	if err := r.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Order("part_number asc").
+		Find(&parts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload parts: %w", err)
+	}
+	return parts, nil
+}
+
+// GetPartsByFileID retrieves the parts a file was assembled from, ordered by part number.
+func (r *uploadSessionRepository) GetPartsByFileID(ctx context.Context, fileID string) ([]entity.UploadParts, error) {
+	var parts []entity.UploadParts
+	if err := r.db.WithContext(ctx).
+		Where("file_id = ?", fileID).
+		Order("part_number asc").
+		Find(&parts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload parts: %w", err)
+	}
+	return parts, nil
+}
+
+// transitionUploadSession performs a conditional status update so concurrent requests cannot both act on a session.
+func transitionUploadSession(tx *gorm.DB, session *entity.UploadSessions, from []string, status string) error {
+	if session == nil || session.ID == "" {
+		return errors.New("upload session ID cannot be empty")
+	}
+	result := tx.Model(&entity.UploadSessions{}).
+		Where("id = ? AND status IN ?", session.ID, from).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update upload session status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrUploadSessionClosed
+	}
+	session.Status = status
+	return nil
+}
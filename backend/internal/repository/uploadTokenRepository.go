@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// uploadTokenRepository implements the UploadTokenRepository interface for pre-signed uploads.
+type uploadTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadTokenRepository creates a new instance of UploadTokenRepository.
+func NewUploadTokenRepository(db *gorm.DB) UploadTokenRepository {
+	return &uploadTokenRepository{db: db}
+}
+
+// Create adds a new upload token.
+func (r *uploadTokenRepository) Create(ctx context.Context, token *entity.UploadTokens) error {
+	if token == nil {
+		return errors.New("upload token cannot be nil")
+	}
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		slog.Error("Failed to create upload token", slog.String("appID", token.AppID), slog.Any("error", err))
+		return fmt.Errorf("failed to create upload token: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an upload token by its ID.
+func (r *uploadTokenRepository) GetByID(ctx context.Context, id string) (*entity.UploadTokens, error) {
+	if id == "" {
+		return nil, errors.New("upload token ID cannot be empty")
+	}
+	var token entity.UploadTokens
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrUploadTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve upload token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetByAppID retrieves the upload tokens issued by an app, newest first.
+func (r *uploadTokenRepository) GetByAppID(ctx context.Context, appID string) ([]entity.UploadTokens, error) {
+	if appID == "" {
+		return nil, errors.New("app ID cannot be empty")
+	}
+	var tokens []entity.UploadTokens
+	if err := r.db.WithContext(ctx).Where("app_id = ?", appID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve upload tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Revoke marks an upload token revoked. Revoking a token twice keeps the original revocation time.
+func (r *uploadTokenRepository) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("upload token ID cannot be empty")
+	}
+	result := r.db.WithContext(ctx).Model(&entity.UploadTokens{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		slog.Error("Failed to revoke upload token", slog.String("tokenID", id), slog.Any("error", result.Error))
+		return fmt.Errorf("failed to revoke upload token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
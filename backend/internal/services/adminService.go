@@ -5,6 +5,7 @@ import (
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
 	"encoding/base64"
 	"log/slog"
@@ -27,15 +28,22 @@ func NewAdminService(oauth2 OAuth2Interface, adminRepo repository.AdminRepositor
 	}
 }
 
-func (a *AdminService) Login(ctx context.Context, username string, password string) (string, error) {
+func (a *AdminService) Login(ctx context.Context, username string, password string) (_ string, err error) {
 	if (username == "") || (password == "") {
 		return "", model.ErrInvalidInput
 	}
 
+	// Failed logins for unknown usernames are recorded against the username that was tried
+	actorID, adminID := username, ""
+	defer func() {
+		_ = a.audit(ctx, actorID, adminID, constant.ActionTypeLogin, err, map[string]interface{}{"username": username})
+	}()
+
 	admin, err := a.adminRepository.GetByUsername(ctx, username)
 	if err != nil {
 		return "", model.AdminErrWrongCredentials
 	}
+	actorID, adminID = admin.ClientID, admin.ID
 
 	// Add constant-time delay to prevent timing attacks
 	defer func() {
@@ -55,11 +63,16 @@ func (a *AdminService) Login(ctx context.Context, username string, password stri
 	return result.AccessToken, nil
 }
 
-func (a *AdminService) Register(ctx context.Context, username string, password string) (string, error) {
+func (a *AdminService) Register(ctx context.Context, username string, password string) (_ string, err error) {
 	if (username == "") || (password == "") {
 		return "", model.ErrInvalidInput
 	}
 
+	adminID := helper.GenerateCustomUUID().String()
+	defer func() {
+		_ = a.audit(ctx, "", adminID, constant.ActionTypeCreate, err, map[string]interface{}{"username": username})
+	}()
+
 	salt, err := a.cryptoUtil.GenerateKey()
 	if err != nil {
 		slog.Error("Salt generation failed", slog.Any("error", err))
@@ -83,25 +96,15 @@ func (a *AdminService) Register(ctx context.Context, username string, password s
 	secret, err := a.encryptSecret(input, salt, admin.ClientSecret)
 	if err != nil {
 		slog.Error("Hashing failed", slog.Any("error", err))
-		err = a.oauth2.DeleteClient(ctx, admin.ClientId)
-		if err != nil {
-			slog.Error("Failed to delete OAuth2 client after hashing failure", slog.Any("error", err))
-			// ADD AUDIT LOG HERE
-			_ = a.fileLogRepository.Create(context.Background(), &entity.FileLogs{
-				FileID:    "",
-				ActorType: "system",
-				ActorID:   admin.ClientId,
-				Action:    "delete",
-				IP:        helper.GetClientIP(ctx),
-				UserAgent: helper.GetUserAgent(ctx),
-				Metadata:  map[string]interface{}{"reason": "failed to delete OAuth2 client after hashing failure"},
-			})
+		if errDelete := a.oauth2.DeleteClient(ctx, admin.ClientId); errDelete != nil {
+			slog.Error("Failed to delete OAuth2 client after hashing failure", slog.Any("error", errDelete))
+			_ = a.auditCleanupFailure(ctx, admin.ClientId, adminID, errDelete, "failed to delete OAuth2 client after hashing failure")
 		}
 		return "", err
 	}
 
 	err = a.adminRepository.Create(ctx, &entity.Admins{
-		ID:       helper.GenerateCustomUUID().String(),
+		ID:       adminID,
 		Username: username,
 		ClientID: admin.ClientId,
 		Secret:   secret,
@@ -110,19 +113,9 @@ func (a *AdminService) Register(ctx context.Context, username string, password s
 
 	if err != nil {
 		slog.Error("Failed to create admin", slog.Any("error", err))
-		err = a.oauth2.DeleteClient(ctx, admin.ClientId)
-		if err != nil {
-			slog.Error("Failed to delete OAuth2 client after admin creation failure", slog.Any("error", err))
-			// ADD AUDIT LOG HERE
-			_ = a.fileLogRepository.Create(context.Background(), &entity.FileLogs{
-				FileID:    "",
-				ActorType: "system",
-				ActorID:   admin.ClientId,
-				Action:    "delete",
-				IP:        helper.GetClientIP(ctx),
-				UserAgent: helper.GetUserAgent(ctx),
-				Metadata:  map[string]interface{}{"reason": "failed to delete OAuth2 client after admin creation failure"},
-			})
+		if errDelete := a.oauth2.DeleteClient(ctx, admin.ClientId); errDelete != nil {
+			slog.Error("Failed to delete OAuth2 client after admin creation failure", slog.Any("error", errDelete))
+			_ = a.auditCleanupFailure(ctx, admin.ClientId, adminID, errDelete, "failed to delete OAuth2 client after admin creation failure")
 		}
 		return "", err
 	}
@@ -161,11 +154,14 @@ func (a *AdminService) RefreshToken(ctx context.Context, adminID, accessToken st
 	return result.AccessToken, nil
 }
 
-func (a *AdminService) RevokeToken(ctx context.Context, adminID, accessToken string) (string, error) {
+func (a *AdminService) RevokeToken(ctx context.Context, adminID, accessToken string) (_ string, err error) {
 	admin, err := a.adminRepository.GetByClientID(ctx, adminID)
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		_ = a.audit(ctx, "", admin.ID, constant.ActionTypeLogout, err, nil)
+	}()
 
 	salt := admin.Salt
 	input := admin.Username + ":" + admin.ClientID
@@ -183,11 +179,15 @@ func (a *AdminService) RevokeToken(ctx context.Context, adminID, accessToken str
 	return "Success revoking token", nil
 }
 
-func (a *AdminService) UpdateUsername(ctx context.Context, adminID, newUsername string) error {
+func (a *AdminService) UpdateUsername(ctx context.Context, adminID, newUsername string) (err error) {
 	admin, err := a.adminRepository.GetByClientID(ctx, adminID)
 	if err != nil {
 		return err
 	}
+	oldUsername := admin.Username
+	defer func() {
+		_ = a.audit(ctx, "", admin.ID, constant.ActionTypeUpdate, err, map[string]interface{}{"old_username": oldUsername, "username": newUsername})
+	}()
 
 	_, err = a.oauth2.UpdateClient(ctx, admin.ClientID, "replace", "client_name", newUsername)
 	if err != nil {
@@ -198,11 +198,14 @@ func (a *AdminService) UpdateUsername(ctx context.Context, adminID, newUsername
 	return a.adminRepository.Update(ctx, admin)
 }
 
-func (a *AdminService) UpdatePassword(ctx context.Context, adminID, newPassword string) error {
+func (a *AdminService) UpdatePassword(ctx context.Context, adminID, newPassword string) (err error) {
 	admin, err := a.adminRepository.GetByClientID(ctx, adminID)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = a.audit(ctx, "", admin.ID, constant.ActionTypePasswordChange, err, nil)
+	}()
 
 	salt := admin.Salt
 	input := admin.Username + ":" + admin.ClientID
@@ -220,7 +223,7 @@ func (a *AdminService) UpdatePassword(ctx context.Context, adminID, newPassword
 	return a.adminRepository.Update(ctx, admin)
 }
 
-func (a *AdminService) DeleteAdmin(ctx context.Context, request string) (string, error) {
+func (a *AdminService) DeleteAdmin(ctx context.Context, request string) (_ string, err error) {
 
 	if request == "" {
 		return "", model.ErrInvalidInput
@@ -229,6 +232,9 @@ func (a *AdminService) DeleteAdmin(ctx context.Context, request string) (string,
 	if err != nil {
 		return "", model.AdminErrNotFound
 	}
+	defer func() {
+		_ = a.audit(ctx, "", admin.ID, constant.ActionTypeDelete, err, map[string]interface{}{"username": admin.Username})
+	}()
 	err = a.adminRepository.Delete(ctx, admin.ID)
 	if err != nil {
 		return "", err
@@ -262,6 +268,36 @@ func (a *AdminService) GetAdminList(ctx context.Context, offset int, limit int,
 	return &adminResponses, nil
 }
 
+// audit records an action on the admin account adminID. The actor defaults to the admin calling the API.
+func (a *AdminService) audit(ctx context.Context, actorID, adminID string, action constant.ActionType, err error, metadata map[string]interface{}) error {
+	actorType := ""
+	if actorID != "" {
+		actorType = constant.ActorTypeAdmin
+	}
+	return recordAudit(ctx, a.fileLogRepository, auditEntry{
+		ActorID:      actorID,
+		ActorType:    actorType,
+		ResourceType: constant.ResourceTypeAdmin,
+		ResourceID:   adminID,
+		Action:       action,
+		Err:          err,
+		Metadata:     metadata,
+	})
+}
+
+// auditCleanupFailure records an OAuth2 client left behind because it could not be deleted after a failed step
+func (a *AdminService) auditCleanupFailure(ctx context.Context, clientID, adminID string, err error, reason string) error {
+	return recordAudit(ctx, a.fileLogRepository, auditEntry{
+		ActorID:      clientID,
+		ActorType:    constant.ActorTypeSystem,
+		ResourceType: constant.ResourceTypeAdmin,
+		ResourceID:   adminID,
+		Action:       constant.ActionTypeDelete,
+		Err:          err,
+		Metadata:     map[string]interface{}{"reason": reason},
+	})
+}
+
 func (a *AdminService) encryptSecret(input, salt, secret string) (string, error) {
 
 	key, err := a.cryptoUtil.KeyDerivationFunction(input, []byte(salt))
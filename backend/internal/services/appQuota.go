@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"io"
+	"math"
+)
+
+// uploadQuota is what an app may still write in one file: the largest file it may store and the bytes left
+// under its storage quota, each negative when unlimited
+type uploadQuota struct {
+	maxFileSize int64
+	remaining   int64
+}
+
+// checkQuota returns what an app may write in a file replacing the file given, or in a new file when it is nil.
+// It fails right away when the app has no files or bytes left; the size of the file itself is only known once it
+// has streamed in, so limit and check enforce the rest.
+func (c *FileService) checkQuota(ctx context.Context, appID string, replacing *entity.Files) (*uploadQuota, error) {
+	app, err := c.applicationRepository.GetByID(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := &uploadQuota{maxFileSize: -1, remaining: -1}
+	if app.MaxFileSize > 0 {
+		quota.maxFileSize = app.MaxFileSize
+	}
+	if app.MaxFiles <= 0 && app.MaxStorageBytes <= 0 {
+		return quota, nil
+	}
+
+	usage, err := c.applicationRepository.GetUsage(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	if replacing == nil && app.MaxFiles > 0 && usage.FileCount >= app.MaxFiles {
+		return nil, model.ErrFileCountQuotaExceeded
+	}
+	if app.MaxStorageBytes > 0 {
+		quota.remaining = app.MaxStorageBytes - usage.StoredBytes
+		if replacing != nil {
+			quota.remaining += replacing.Size
+		}
+		if quota.remaining <= 0 {
+			return nil, model.ErrStorageQuotaExceeded
+		}
+	}
+	return quota, nil
+}
+
+// check returns the quota error a file of size bytes would exceed, or nil when it fits
+func (q *uploadQuota) check(size int64) error {
+	if q.maxFileSize >= 0 && size > q.maxFileSize {
+		return model.ErrFileTooLarge
+	}
+	if q.remaining >= 0 && size > q.remaining {
+		return model.ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// limit wraps input so that reading past the quota fails with the error of the first limit it crosses
+func (q *uploadQuota) limit(input io.Reader) *sizeLimitedReader {
+	switch {
+	case q.maxFileSize >= 0 && (q.remaining < 0 || q.maxFileSize <= q.remaining):
+		return &sizeLimitedReader{reader: input, remaining: q.maxFileSize, err: model.ErrFileTooLarge}
+	case q.remaining >= 0:
+		return &sizeLimitedReader{reader: input, remaining: q.remaining, err: model.ErrStorageQuotaExceeded}
+	default:
+		return &sizeLimitedReader{reader: input, remaining: math.MaxInt64 - 1}
+	}
+}
+
+// GetUsage reports what the calling app stores against its limits
+func (c *FileService) GetUsage(ctx context.Context, clientID string) (*model.AppUsageResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	app, err := c.applicationRepository.GetByID(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := c.applicationRepository.GetUsage(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+	return appUsageResponse(app, usage), nil
+}
+
+func appUsageResponse(app *entity.Apps, usage *entity.AppUsage) *model.AppUsageResponse {
+	return &model.AppUsageResponse{
+		AppID:           app.ID,
+		StoredBytes:     usage.StoredBytes,
+		FileCount:       usage.FileCount,
+		MaxStorageBytes: app.MaxStorageBytes,
+		MaxFiles:        app.MaxFiles,
+		MaxFileSize:     app.MaxFileSize,
+		UpdatedAt:       usage.UpdatedAt,
+	}
+}
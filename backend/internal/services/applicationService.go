@@ -5,32 +5,55 @@ import (
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strings"
+	"time"
 )
 
 type ApplicationService struct {
-	oauth2        OAuth2Interface
-	appRepository repository.ApplicationRepository
+	oauth2         OAuth2Interface
+	appRepository  repository.ApplicationRepository
+	storageService StorageInterface
 
 	fileLogsRepository repository.FileLogsRepository
+
+	appBucketPrefix string // every app gets a bucket named this prefix and its ID; empty shares the default bucket
 }
 
-func NewApplicationService(oauth2 OAuth2Interface, appRepo repository.ApplicationRepository, fileLogsRepository repository.FileLogsRepository) ApplicationInterface {
+func NewApplicationService(params ApplicationServiceParams) ApplicationInterface {
 	return &ApplicationService{
-		oauth2:             oauth2,
-		appRepository:      appRepo,
-		fileLogsRepository: fileLogsRepository,
+		oauth2:             params.OAuth2Service,
+		appRepository:      params.ApplicationRepository,
+		storageService:     params.StorageService,
+		fileLogsRepository: params.FileLogsRepository,
+		appBucketPrefix:    params.AppBucketPrefix,
 	}
 }
 
-func (a *ApplicationService) AddApp(ctx context.Context, appName, appUri, redirectUri string) (*model.AppDetailResponse, error) {
+type ApplicationServiceParams struct {
+	OAuth2Service         OAuth2Interface
+	ApplicationRepository repository.ApplicationRepository
+	StorageService        StorageInterface
+	FileLogsRepository    repository.FileLogsRepository
+	AppBucketPrefix       string
+}
+
+func (a *ApplicationService) AddApp(ctx context.Context, appName, appUri, redirectUri string) (_ *model.AppDetailResponse, err error) {
 	if appName == "" || appUri == "" || redirectUri == "" {
 		return nil, model.ErrInvalidInput
 	}
 
+	appID := helper.GenerateCustomUUID().String()
+	defer func() {
+		_ = a.audit(ctx, appID, constant.ActionTypeCreate, err, map[string]interface{}{"app_name": appName})
+	}()
+
 	app, err := a.appRepository.GetByName(ctx, appName)
 	if err != nil && !(strings.Contains(err.Error(), "app not found")) {
 		return nil, err // handle real errors
@@ -52,30 +75,34 @@ func (a *ApplicationService) AddApp(ctx context.Context, appName, appUri, redire
 		return nil, err
 	}
 
+	// Provision the app's own bucket, with versioning enabled, when every app gets one
+	bucketName := a.appBucketName(appID)
+	if bucketName != "" {
+		if err = a.provisionBucket(ctx, bucketName); err != nil {
+			slog.Warn("Error provisioning app bucket , deleting client", slog.String("client_id", appCred.ClientId))
+			a.deleteClient(ctx, appID, appCred.ClientId)
+			return nil, err
+		}
+	}
+
 	// Create app in DB
 	err = a.appRepository.Create(ctx, &entity.Apps{
-		ID:           helper.GenerateCustomUUID().String(),
+		ID:           appID,
 		Name:         appName,
 		ClientID:     appCred.ClientId,
 		ClientSecret: appCred.ClientSecret,
 		Uri:          appUri,
 		RedirectUri:  redirectUri,
 		IsActive:     true,
+		BucketName:   bucketName,
 	})
 	if err != nil {
 		slog.Warn("Error creating app in DB , deleting client", slog.String("client_id", appCred.ClientId))
-		err = a.oauth2.DeleteClient(ctx, appCred.ClientId)
-		if err != nil {
-			slog.Error("Failed to delete OAuth2 client after app creation failure", slog.Any("error", err))
-			_ = a.fileLogsRepository.Create(context.Background(), &entity.FileLogs{
-				FileID:    "",
-				ActorType: "system",
-				ActorID:   appCred.ClientId,
-				Action:    "delete",
-				IP:        helper.GetClientIP(ctx),
-				UserAgent: helper.GetUserAgent(ctx),
-				Metadata:  map[string]interface{}{"reason": "failed to delete OAuth2 client after app creation failure"},
-			})
+		a.deleteClient(ctx, appID, appCred.ClientId)
+		if bucketName != "" {
+			if errRemove := a.storageService.RemoveBucket(ctx, bucketName); errRemove != nil {
+				slog.Error("Failed to remove app bucket after app creation failure", slog.String("bucket", bucketName), slog.Any("error", errRemove))
+			}
 		}
 		return nil, err
 	}
@@ -88,17 +115,62 @@ func (a *ApplicationService) AddApp(ctx context.Context, appName, appUri, redire
 		IsActive:     true,
 		Uri:          appUri,
 		RedirectUri:  redirectUri,
+		BucketName:   bucketName,
 		CreatedAt:    appCred.CreatedAt.String(),
 		UpdatedAt:    appCred.UpdatedAt.String(),
 	}, nil
 
 }
 
-func (a *ApplicationService) UpdateApp(ctx context.Context, appUID string, appName, appUri, redirectUri string) (*model.AppDetailResponse, error) {
+// deleteClient removes the OAuth2 client of an app that could not be created, recording a failure to remove it
+func (a *ApplicationService) deleteClient(ctx context.Context, appID, clientID string) {
+	if errDelete := a.oauth2.DeleteClient(ctx, clientID); errDelete != nil {
+		slog.Error("Failed to delete OAuth2 client after app creation failure", slog.Any("error", errDelete))
+		_ = recordAudit(ctx, a.fileLogsRepository, auditEntry{
+			ActorID:      clientID,
+			ActorType:    constant.ActorTypeSystem,
+			ResourceType: constant.ResourceTypeApp,
+			ResourceID:   appID,
+			Action:       constant.ActionTypeDelete,
+			Err:          errDelete,
+			Metadata:     map[string]interface{}{"reason": "failed to delete OAuth2 client after app creation failure"},
+		})
+	}
+}
+
+// UpdateApp renames an app and replaces its OAuth2 URIs, in the OAuth2 server first and then in the database.
+func (a *ApplicationService) UpdateApp(ctx context.Context, appUID string, appName, appUri, redirectUri string) (_ *model.AppDetailResponse, err error) {
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeUpdate, err, map[string]interface{}{"app_name": appName, "uri": appUri, "redirect_uri": redirectUri})
+	}()
+	if appName == "" || appUri == "" || redirectUri == "" {
+		return nil, model.ErrInvalidInput
+	}
+
 	app, err := a.checkAppExist(ctx, appUID)
 	if err != nil {
 		return nil, err
 	}
+	if appName != app.Name {
+		existing, err := a.appRepository.GetByName(ctx, appName)
+		if err != nil && !(strings.Contains(err.Error(), "app not found")) {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, model.ErrAppAlreadyExists
+		}
+	}
+
+	for path, value := range map[string]interface{}{
+		"client_name":   appName,
+		"client_uri":    appUri,
+		"redirect_uris": []string{redirectUri},
+	} {
+		if _, err := a.oauth2.UpdateClient(ctx, app.ClientID, "replace", path, value); err != nil {
+			return nil, err
+		}
+	}
+
 	app.Name = appName
 	app.Uri = appUri
 	app.RedirectUri = redirectUri
@@ -122,6 +194,7 @@ func (a *ApplicationService) GetInfo(ctx context.Context, appUID string) (*model
 		IsActive:     app.IsActive,
 		Uri:          app.Uri,
 		RedirectUri:  app.RedirectUri,
+		BucketName:   app.BucketName,
 		CreatedAt:    app.CreatedAt.String(),
 		UpdatedAt:    app.UpdatedAt.String(),
 	}, nil
@@ -146,7 +219,14 @@ func (a *ApplicationService) ListApps(ctx context.Context, limit, offset int, so
 	return count, &appResponse, nil
 }
 
-func (a *ApplicationService) DeleteApp(ctx context.Context, appUID string) error {
+// DeleteApp deactivates an app and revokes its grants. The bucket provisioned for the app is removed when it is
+// empty; one still holding files is kept so they can be recovered with the app.
+func (a *ApplicationService) DeleteApp(ctx context.Context, appUID string) (err error) {
+	var metadata map[string]interface{}
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeDelete, err, metadata)
+	}()
+
 	app, err := a.checkAppExist(ctx, appUID)
 	if err != nil {
 		return err
@@ -166,13 +246,20 @@ func (a *ApplicationService) DeleteApp(ctx context.Context, appUID string) error
 	if err != nil {
 		return err
 	}
+
+	if a.ownsBucket(app) {
+		metadata = map[string]interface{}{"bucket_name": app.BucketName, "bucket_removed": a.decommissionBucket(ctx, app.BucketName)}
+	}
 	return nil
 }
 
-func (a *ApplicationService) RecoverApp(ctx context.Context, appUID string) (*string, error) {
+func (a *ApplicationService) RecoverApp(ctx context.Context, appUID string) (_ *string, err error) {
 	if appUID == "" {
 		return nil, model.ErrInvalidInput
 	}
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeRecover, err, nil)
+	}()
 
 	app, err := a.appRepository.GetByID(ctx, appUID)
 	if err != nil {
@@ -188,6 +275,13 @@ func (a *ApplicationService) RecoverApp(ctx context.Context, appUID string) (*st
 		return nil, err
 	}
 
+	// The app's bucket was removed if it was empty when the app was deleted
+	if a.ownsBucket(app) {
+		if err = a.provisionBucket(ctx, app.BucketName); err != nil {
+			return nil, err
+		}
+	}
+
 	err = a.appRepository.Restore(ctx, appUID)
 	if err != nil {
 		return nil, err
@@ -202,7 +296,11 @@ func (a *ApplicationService) RecoverApp(ctx context.Context, appUID string) (*st
 	return &messsage, nil
 }
 
-func (a *ApplicationService) RotateSecret(ctx context.Context, appUID string) (*model.AppDetailResponse, error) {
+func (a *ApplicationService) RotateSecret(ctx context.Context, appUID string) (_ *model.AppDetailResponse, err error) {
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeRotateSecret, err, nil)
+	}()
+
 	app, err := a.checkAppExist(ctx, appUID)
 	if err != nil {
 		return nil, err
@@ -249,6 +347,355 @@ func (a *ApplicationService) RotateSecret(ctx context.Context, appUID string) (*
 
 }
 
+// SetKeyProvider selects who wraps the file keys of an app. Files keep the provider they were sealed with,
+// so the external endpoint settings are kept when an app switches back to the internal provider.
+func (a *ApplicationService) SetKeyProvider(ctx context.Context, appUID string, request model.KeyProviderRequest) (_ *model.KeyProviderResponse, err error) {
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeUpdate, err, map[string]interface{}{"key_provider": request.Provider})
+	}()
+
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch request.Provider {
+	case constant.KeyProviderInternal:
+		app.KeyProvider = constant.KeyProviderInternal
+	case constant.KeyProviderExternal:
+		endpoint, err := url.Parse(request.Endpoint)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return nil, fmt.Errorf("%w: endpoint must be an https URL", model.ErrInvalidKeyProvider)
+		}
+		if strings.TrimSpace(request.KeyID) == "" {
+			return nil, fmt.Errorf("%w: key_id is required", model.ErrInvalidKeyProvider)
+		}
+		if request.CACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(request.CACert)) {
+			return nil, fmt.Errorf("%w: ca_cert is not a PEM certificate", model.ErrInvalidKeyProvider)
+		}
+		app.KeyProvider = constant.KeyProviderExternal
+		app.KeyEndpoint = strings.TrimSuffix(endpoint.String(), "/")
+		app.KeyID = strings.TrimSpace(request.KeyID)
+		app.KeyCACert = request.CACert
+	default:
+		return nil, fmt.Errorf("%w: unknown provider %q", model.ErrInvalidKeyProvider, request.Provider)
+	}
+
+	if err := a.appRepository.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	slog.Info("App key provider updated", slog.String("app_id", app.ID), slog.String("provider", app.KeyProvider))
+
+	return &model.KeyProviderResponse{
+		AppID:     app.ID,
+		Provider:  app.KeyProvider,
+		Endpoint:  app.KeyEndpoint,
+		KeyID:     app.KeyID,
+		HasCACert: app.KeyCACert != "",
+	}, nil
+}
+
+// SetLifecyclePolicy sets how many days the files of an app live and how many days they are kept once soft
+// deleted before they are purged. Fields left out of the request keep their value; 0 turns a rule off.
+func (a *ApplicationService) SetLifecyclePolicy(ctx context.Context, appUID string, request model.LifecyclePolicyRequest) (_ *model.LifecyclePolicyResponse, err error) {
+	defer func() {
+		metadata := map[string]interface{}{}
+		if request.FileTTLDays != nil {
+			metadata["file_ttl_days"] = *request.FileTTLDays
+		}
+		if request.DeletedRetentionDays != nil {
+			metadata["deleted_retention_days"] = *request.DeletedRetentionDays
+		}
+		_ = a.audit(ctx, appUID, constant.ActionTypeUpdate, err, metadata)
+	}()
+
+	if (request.FileTTLDays != nil && *request.FileTTLDays < 0) ||
+		(request.DeletedRetentionDays != nil && *request.DeletedRetentionDays < 0) {
+		return nil, model.ErrInvalidLifecyclePolicy
+	}
+
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	if request.FileTTLDays != nil {
+		app.FileTTLDays = *request.FileTTLDays
+	}
+	if request.DeletedRetentionDays != nil {
+		app.DeletedRetentionDays = *request.DeletedRetentionDays
+	}
+
+	if err := a.appRepository.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	slog.Info("App lifecycle policy updated", slog.String("app_id", app.ID),
+		slog.Int("file_ttl_days", app.FileTTLDays), slog.Int("deleted_retention_days", app.DeletedRetentionDays))
+
+	return &model.LifecyclePolicyResponse{
+		AppID:                app.ID,
+		FileTTLDays:          app.FileTTLDays,
+		DeletedRetentionDays: app.DeletedRetentionDays,
+	}, nil
+}
+
+// SetQuota sets how many bytes and files an app may store and how large one file may be.
+// Fields left out of the request keep their value; 0 removes a limit. Lowering a limit below the current usage
+// keeps the files already stored but refuses new uploads until enough are deleted.
+func (a *ApplicationService) SetQuota(ctx context.Context, appUID string, request model.AppQuotaRequest) (_ *model.AppUsageResponse, err error) {
+	defer func() {
+		metadata := map[string]interface{}{}
+		if request.MaxStorageBytes != nil {
+			metadata["max_storage_bytes"] = *request.MaxStorageBytes
+		}
+		if request.MaxFiles != nil {
+			metadata["max_files"] = *request.MaxFiles
+		}
+		if request.MaxFileSize != nil {
+			metadata["max_file_size"] = *request.MaxFileSize
+		}
+		_ = a.audit(ctx, appUID, constant.ActionTypeUpdate, err, metadata)
+	}()
+
+	for _, limit := range []*int64{request.MaxStorageBytes, request.MaxFiles, request.MaxFileSize} {
+		if limit != nil && *limit < 0 {
+			return nil, model.ErrInvalidQuota
+		}
+	}
+
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	if request.MaxStorageBytes != nil {
+		app.MaxStorageBytes = *request.MaxStorageBytes
+	}
+	if request.MaxFiles != nil {
+		app.MaxFiles = *request.MaxFiles
+	}
+	if request.MaxFileSize != nil {
+		app.MaxFileSize = *request.MaxFileSize
+	}
+
+	if err := a.appRepository.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	slog.Info("App quota updated", slog.String("app_id", app.ID), slog.Int64("max_storage_bytes", app.MaxStorageBytes),
+		slog.Int64("max_files", app.MaxFiles), slog.Int64("max_file_size", app.MaxFileSize))
+
+	usage, err := a.appRepository.GetUsage(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	return appUsageResponse(app, usage), nil
+}
+
+// GetUsage reports what an app stores against its limits
+func (a *ApplicationService) GetUsage(ctx context.Context, appUID string) (*model.AppUsageResponse, error) {
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := a.appRepository.GetUsage(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	return appUsageResponse(app, usage), nil
+}
+
+// SetClientConfig sets the bucket, hash method and encryption method the new files of an app are stored with,
+// and whether they are copied to the storage replicas.
+// Fields left out of the request keep their value; an empty string returns a setting to the server default.
+// Files already stored keep the bucket and algorithms they were written with.
+func (a *ApplicationService) SetClientConfig(ctx context.Context, appUID string, request model.ClientConfigRequest) (_ *model.ClientConfigResponse, err error) {
+	defer func() {
+		metadata := map[string]interface{}{}
+		if request.BucketName != nil {
+			metadata["bucket_name"] = *request.BucketName
+		}
+		if request.HashMethod != nil {
+			metadata["hash_method"] = *request.HashMethod
+		}
+		if request.EncryptionMethod != nil {
+			metadata["encryption_method"] = *request.EncryptionMethod
+		}
+		if request.HashEncryptedFile != nil {
+			metadata["hash_encrypted_file"] = *request.HashEncryptedFile
+		}
+		if request.Replicate != nil {
+			metadata["replicate"] = *request.Replicate
+		}
+		_ = a.audit(ctx, appUID, constant.ActionTypeUpdate, err, metadata)
+	}()
+
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	if request.BucketName != nil {
+		app.BucketName = *request.BucketName
+	}
+	if request.HashMethod != nil {
+		app.HashMethod = *request.HashMethod
+	}
+	if request.EncryptionMethod != nil {
+		app.EncryptionMethod = *request.EncryptionMethod
+	}
+	if request.HashEncryptedFile != nil {
+		app.HashEncryptedFile = request.HashEncryptedFile
+	}
+	if request.Replicate != nil {
+		app.Replicate = request.Replicate
+	}
+	if err := validateClientConfig(app.BucketName, app.HashMethod, app.EncryptionMethod); err != nil {
+		return nil, err
+	}
+	if request.BucketName != nil && app.BucketName != "" {
+		if err := a.provisionBucket(ctx, app.BucketName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.appRepository.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	slog.Info("App client config updated", slog.String("app_id", app.ID), slog.String("bucket_name", app.BucketName),
+		slog.String("hash_method", app.HashMethod), slog.String("encryption_method", app.EncryptionMethod))
+	return clientConfigResponse(app), nil
+}
+
+// GetClientConfig reports the bucket, hash method and encryption method of an app
+func (a *ApplicationService) GetClientConfig(ctx context.Context, appUID string) (*model.ClientConfigResponse, error) {
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	return clientConfigResponse(app), nil
+}
+
+func clientConfigResponse(app *entity.Apps) *model.ClientConfigResponse {
+	return &model.ClientConfigResponse{
+		AppID:             app.ID,
+		BucketName:        app.BucketName,
+		HashMethod:        app.HashMethod,
+		EncryptionMethod:  app.EncryptionMethod,
+		HashEncryptedFile: app.HashEncryptedFile,
+		Replicate:         app.Replicate,
+	}
+}
+
+// MigrateAppBucket asks the bucket migration worker to move the files an app keeps in other buckets into the bucket
+// it stores new files in. An app still on the default bucket is given a bucket of its own first, which requires
+// per-app buckets to be enabled.
+func (a *ApplicationService) MigrateAppBucket(ctx context.Context, appUID string) (_ *model.AppBucketResponse, err error) {
+	var bucketName string
+	defer func() {
+		_ = a.audit(ctx, appUID, constant.ActionTypeMigrate, err, map[string]interface{}{"bucket_name": bucketName})
+	}()
+
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	if app.BucketName == "" {
+		app.BucketName = a.appBucketName(app.ID)
+		if app.BucketName == "" {
+			return nil, model.ErrAppBucketNotSet
+		}
+	}
+	bucketName = app.BucketName
+	if err := a.provisionBucket(ctx, app.BucketName); err != nil {
+		return nil, err
+	}
+
+	requestedAt := time.Now().UTC().Truncate(time.Microsecond)
+	app.BucketMigrationAt = &requestedAt
+	if err := a.appRepository.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	slog.Info("App bucket migration requested", slog.String("app_id", app.ID), slog.String("bucket_name", app.BucketName))
+	return a.appBucketResponse(ctx, app)
+}
+
+// GetAppBucket reports the bucket of an app and how many of its files are still kept elsewhere
+func (a *ApplicationService) GetAppBucket(ctx context.Context, appUID string) (*model.AppBucketResponse, error) {
+	app, err := a.checkAppExist(ctx, appUID)
+	if err != nil {
+		return nil, err
+	}
+	return a.appBucketResponse(ctx, app)
+}
+
+func (a *ApplicationService) appBucketResponse(ctx context.Context, app *entity.Apps) (*model.AppBucketResponse, error) {
+	response := &model.AppBucketResponse{
+		AppID:                app.ID,
+		BucketName:           app.BucketName,
+		Dedicated:            a.ownsBucket(app),
+		MigrationPending:     app.BucketMigrationAt != nil,
+		MigrationRequestedAt: app.BucketMigrationAt,
+	}
+	// Files of an app on the default bucket are where they belong
+	if app.BucketName != "" {
+		count, err := a.appRepository.CountFilesOutsideBucket(ctx, app.ID, app.BucketName)
+		if err != nil {
+			return nil, err
+		}
+		response.FilesOutsideBucket = count
+	}
+	return response, nil
+}
+
+// appBucketName returns the name of the bucket provisioned for an app, or "" when apps share the default bucket
+func (a *ApplicationService) appBucketName(appID string) string {
+	if a.appBucketPrefix == "" {
+		return ""
+	}
+	return a.appBucketPrefix + strings.ToLower(appID)
+}
+
+// ownsBucket reports whether an app stores its files in the bucket provisioned for it, rather than one it shares
+func (a *ApplicationService) ownsBucket(app *entity.Apps) bool {
+	return app.BucketName != "" && app.BucketName == a.appBucketName(app.ID)
+}
+
+// provisionBucket creates the bucket an app stores its files in, or enables versioning on it when it exists
+func (a *ApplicationService) provisionBucket(ctx context.Context, bucketName string) error {
+	if err := validateClientConfig(bucketName, "", ""); err != nil {
+		return err
+	}
+	if err := a.storageService.CreateBucket(ctx, bucketName); err != nil {
+		slog.Error("Failed to provision app bucket", slog.String("bucket", bucketName), slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// decommissionBucket removes the bucket of a deleted app if it is empty and reports whether it was removed.
+// A bucket still holding files is kept; failing to remove it does not fail the deletion.
+func (a *ApplicationService) decommissionBucket(ctx context.Context, bucketName string) bool {
+	err := a.storageService.RemoveBucket(ctx, bucketName)
+	switch {
+	case errors.Is(err, model.ErrBucketNotEmpty):
+		slog.Info("App bucket still holds files, keeping it", slog.String("bucket", bucketName))
+		return false
+	case err != nil:
+		slog.Error("Failed to remove app bucket", slog.String("bucket", bucketName), slog.Any("error", err))
+		return false
+	}
+	slog.Info("App bucket removed", slog.String("bucket", bucketName))
+	return true
+}
+
+// audit records an action on the app appUID by the admin calling the API
+func (a *ApplicationService) audit(ctx context.Context, appUID string, action constant.ActionType, err error, metadata map[string]interface{}) error {
+	return recordAudit(ctx, a.fileLogsRepository, auditEntry{
+		ResourceType: constant.ResourceTypeApp,
+		ResourceID:   appUID,
+		Action:       action,
+		Err:          err,
+		Metadata:     metadata,
+	})
+}
+
 func (a *ApplicationService) checkAppExist(ctx context.Context, appUID string) (*entity.Apps, error) {
 	if appUID == "" {
 		return nil, model.ErrInvalidInput
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// attemptLimiterSweepSize is the number of tracked keys above which expired windows are swept on the next failure
+const attemptLimiterSweepSize = 10000
+
+// attemptLimiter counts failed attempts per key in fixed windows and refuses a key once it reached max failures.
+// Counts are kept in memory, so every instance of the service limits the attempts it receives itself.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]attemptWindow
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{max: max, window: window, failures: make(map[string]attemptWindow)}
+}
+
+// allow reports whether key may make another attempt
+func (l *attemptLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	failures, ok := l.failures[key]
+	return !ok || time.Since(failures.start) >= l.window || failures.count < l.max
+}
+
+// fail counts a failed attempt of key
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.failures) >= attemptLimiterSweepSize {
+		for k, failures := range l.failures {
+			if now.Sub(failures.start) >= l.window {
+				delete(l.failures, k)
+			}
+		}
+	}
+
+	failures, ok := l.failures[key]
+	if !ok || now.Sub(failures.start) >= l.window {
+		failures = attemptWindow{start: now}
+	}
+	failures.count++
+	l.failures[key] = failures
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const auditExportBatchSize = 500
+
+// auditEvent is the form a log entry takes in JSON exports and forwarded syslog messages
+type auditEvent struct {
+	LogID        uint                   `json:"log_id"`
+	Timestamp    time.Time              `json:"timestamp"`
+	ActorID      string                 `json:"actor_id"`
+	ActorType    string                 `json:"actor_type"`
+	Action       string                 `json:"action"`
+	Outcome      string                 `json:"outcome,omitempty"`
+	ResourceType string                 `json:"resource_type,omitempty"`
+	ResourceID   string                 `json:"resource_id,omitempty"`
+	FileID       string                 `json:"file_id"`
+	TraceID      string                 `json:"trace_id,omitempty"`
+	IP           string                 `json:"ip,omitempty"`
+	UserAgent    string                 `json:"user_agent,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Hash         string                 `json:"hash,omitempty"`
+}
+
+func newAuditEvent(log *entity.FileLogs) auditEvent {
+	return auditEvent{
+		LogID:        log.ID,
+		Timestamp:    log.Timestamp.UTC(),
+		ActorID:      log.ActorID,
+		ActorType:    log.ActorType,
+		Action:       log.Action,
+		Outcome:      log.Outcome,
+		ResourceType: log.ResourceType,
+		ResourceID:   log.ResourceID,
+		FileID:       log.FileID,
+		TraceID:      log.TraceID,
+		IP:           log.IP,
+		UserAgent:    log.UserAgent,
+		Metadata:     log.Metadata,
+		Hash:         log.Hash,
+	}
+}
+
+// logExportEncoder writes log entries in one export format
+type logExportEncoder interface {
+	write(log *entity.FileLogs) error
+	flush() error
+}
+
+// ExportLogs streams every log entry matching filter to w in the given format, in chain order.
+// Entries are read in batches and w is flushed after each one, so the table is never held in memory.
+func (s *AuditService) ExportLogs(ctx context.Context, filter model.LogFilter, format string, w io.Writer) error {
+	if err := validateLogFilter(filter); err != nil {
+		return err
+	}
+	encoder, err := newLogExportEncoder(format, w, s.productVersion)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	err = s.fileLogsRepository.Stream(ctx, filter, auditExportBatchSize, func(batch []entity.FileLogs) error {
+		for i := range batch {
+			if err := encoder.write(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if err := encoder.flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export logs: %w", err)
+	}
+	return encoder.flush()
+}
+
+func newLogExportEncoder(format string, w io.Writer, productVersion string) (logExportEncoder, error) {
+	switch format {
+	case constant.ExportFormatJSONL:
+		buffered := bufio.NewWriter(w)
+		return &jsonlEncoder{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case constant.ExportFormatCSV:
+		encoder := &csvEncoder{writer: csv.NewWriter(w)}
+		return encoder, encoder.writer.Write(csvExportHeader)
+	case constant.ExportFormatCEF:
+		return &cefEncoder{buffered: bufio.NewWriter(w), productVersion: productVersion}, nil
+	default:
+		return nil, model.ErrInvalidExportFormat
+	}
+}
+
+type jsonlEncoder struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (e *jsonlEncoder) write(log *entity.FileLogs) error {
+	return e.encoder.Encode(newAuditEvent(log))
+}
+
+func (e *jsonlEncoder) flush() error {
+	return e.buffered.Flush()
+}
+
+// csvExportHeader names the CSV columns. Columns added later go at the end so existing imports keep their positions.
+var csvExportHeader = []string{"log_id", "timestamp", "actor_id", "actor_type", "action", "file_id", "ip", "user_agent", "metadata", "hash",
+	"resource_type", "resource_id", "outcome", "trace_id"}
+
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func (e *csvEncoder) write(log *entity.FileLogs) error {
+	metadata := ""
+	if len(log.Metadata) > 0 {
+		raw, err := json.Marshal(log.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(raw)
+	}
+	return e.writer.Write([]string{
+		strconv.FormatUint(uint64(log.ID), 10),
+		log.Timestamp.UTC().Format(time.RFC3339Nano),
+		log.ActorID,
+		log.ActorType,
+		log.Action,
+		log.FileID,
+		log.IP,
+		log.UserAgent,
+		metadata,
+		log.Hash,
+		log.ResourceType,
+		log.ResourceID,
+		log.Outcome,
+		log.TraceID,
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type cefEncoder struct {
+	buffered       *bufio.Writer
+	productVersion string
+}
+
+func (e *cefEncoder) write(log *entity.FileLogs) error {
+	line, err := formatCEF(log, e.productVersion)
+	if err != nil {
+		return err
+	}
+	_, err = e.buffered.WriteString(line + "\n")
+	return err
+}
+
+func (e *cefEncoder) flush() error {
+	return e.buffered.Flush()
+}
+
+// cefSeverity rates actions on the CEF scale of 0 to 10: failed logins, destructive actions and credential or
+// key changes above reads, sign-ins and writes
+func cefSeverity(action, outcome string) int {
+	switch constant.ActionType(action) {
+	case constant.ActionTypeLogin:
+		if outcome == constant.OutcomeFailure {
+			return 7
+		}
+		return 5
+	case constant.ActionTypeDelete, constant.ActionTypeReKey, constant.ActionTypeRotateSecret, constant.ActionTypePasswordChange,
+		constant.ActionTypeLegalHoldRelease:
+		return 7
+	case constant.ActionTypeDownload, constant.ActionTypeDecrypt, constant.ActionTypeRecover, constant.ActionTypeCreate,
+		constant.ActionTypeLogout:
+		return 5
+	default:
+		return 3
+	}
+}
+
+// cefResourceNames name the resource of an event in the CEF event name
+var cefResourceNames = map[string]string{
+	constant.ResourceTypeFile:  "File",
+	constant.ResourceTypeApp:   "App",
+	constant.ResourceTypeAdmin: "Admin",
+	constant.ResourceTypeKey:   "Key",
+}
+
+// formatCEF renders a log entry as a single CEF event
+func formatCEF(log *entity.FileLogs, productVersion string) (string, error) {
+	extension := []string{
+		"rt=" + strconv.FormatInt(log.Timestamp.UnixMilli(), 10),
+		"externalId=" + strconv.FormatUint(uint64(log.ID), 10),
+		"act=" + cefExtensionValue(log.Action),
+		"suid=" + cefExtensionValue(log.ActorID),
+		"cs1Label=actorType",
+		"cs1=" + cefExtensionValue(log.ActorType),
+		"cs2Label=fileId",
+		"cs2=" + cefExtensionValue(log.FileID),
+	}
+	if log.Outcome != "" {
+		extension = append(extension, "outcome="+cefExtensionValue(log.Outcome))
+	}
+	if log.ResourceType != "" {
+		extension = append(extension, "cs4Label=resourceType", "cs4="+cefExtensionValue(log.ResourceType))
+	}
+	if log.ResourceID != "" {
+		extension = append(extension, "cs5Label=resourceId", "cs5="+cefExtensionValue(log.ResourceID))
+	}
+	if log.TraceID != "" {
+		extension = append(extension, "cs6Label=traceId", "cs6="+cefExtensionValue(log.TraceID))
+	}
+	if log.IP != "" {
+		extension = append(extension, "src="+cefExtensionValue(log.IP))
+	}
+	if log.UserAgent != "" {
+		extension = append(extension, "requestClientApplication="+cefExtensionValue(log.UserAgent))
+	}
+	if log.Hash != "" {
+		extension = append(extension, "cs3Label=chainHash", "cs3="+cefExtensionValue(log.Hash))
+	}
+	if len(log.Metadata) > 0 {
+		raw, err := json.Marshal(log.Metadata)
+		if err != nil {
+			return "", err
+		}
+		extension = append(extension, "msg="+cefExtensionValue(string(raw)))
+	}
+
+	resourceName, ok := cefResourceNames[log.ResourceType]
+	if !ok {
+		resourceName = "File"
+	}
+	return fmt.Sprintf("CEF:0|Crypsis|crypsis-backend|%s|%s|%s|%d|%s",
+		cefHeaderValue(productVersion),
+		cefHeaderValue(log.Action),
+		cefHeaderValue(resourceName+" "+log.Action),
+		cefSeverity(log.Action, log.Outcome),
+		strings.Join(extension, " "),
+	), nil
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+func cefHeaderValue(value string) string {
+	return cefHeaderEscaper.Replace(value)
+}
+
+func cefExtensionValue(value string) string {
+	return cefExtensionEscaper.Replace(value)
+}
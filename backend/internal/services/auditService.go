@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditCheckpointInterval = time.Hour
+	auditVerifyBatchSize           = 500
+)
+
+// AuditService keeps the audit chain of file logs verifiable.
+// It signs periodic checkpoints over the head of the chain with a key derived from the KEK, cuts old entries
+// behind a signed retention anchor when the lifecycle scheduler asks it to, and walks the chain to find the
+// first entry that was altered or removed.
+type AuditService struct {
+	fileLogsRepository repository.FileLogsRepository
+	signingKey         []byte
+	keyID              string
+	receiptKey         []byte // verifies erasure receipts
+	receiptKeyID       string
+	interval           time.Duration
+	productVersion     string
+}
+
+func NewAuditService(params AuditServiceParams) AuditInterface {
+	interval := params.Interval
+	if interval <= 0 {
+		interval = defaultAuditCheckpointInterval
+	}
+
+	signingKey, keyID := kekDerivedKey(params.KeyConfig, auditCheckpointKeyLabel)
+	receiptKey, receiptKeyID := kekDerivedKey(params.KeyConfig, erasureReceiptKeyLabel)
+	if signingKey == nil {
+		slog.Warn("No KEK configured, audit checkpoints will not be signed and old logs will not be purged")
+	}
+
+	return &AuditService{
+		fileLogsRepository: params.FileLogsRepository,
+		signingKey:         signingKey,
+		keyID:              keyID,
+		receiptKey:         receiptKey,
+		receiptKeyID:       receiptKeyID,
+		interval:           interval,
+		productVersion:     params.ProductVersion,
+	}
+}
+
+const (
+	auditCheckpointKeyLabel = "crypsis audit checkpoints"
+	erasureReceiptKeyLabel  = "crypsis erasure receipts"
+)
+
+// kekDerivedKey derives a signing key for one purpose from the KEK, together with an ID naming the key it came from
+func kekDerivedKey(keyConfig *model.KeyConfig, label string) ([]byte, string) {
+	if keyConfig == nil || keyConfig.KEK == "" {
+		return nil, ""
+	}
+	mac := hmac.New(sha256.New, []byte(keyConfig.KEK))
+	mac.Write([]byte(label))
+	key := mac.Sum(nil)
+
+	if keyConfig.KMSEnable && keyConfig.UID != "" {
+		return key, "kms:" + keyConfig.UID
+	}
+	fingerprint := sha256.Sum256(key)
+	return key, "kek:" + hex.EncodeToString(fingerprint[:8])
+}
+
+// Run signs a checkpoint every interval until the context is cancelled
+func (s *AuditService) Run(ctx context.Context) {
+	if s.signingKey == nil {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Checkpoint(ctx); err != nil {
+			slog.Error("Failed to checkpoint audit chain", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checkpoint signs a checkpoint over the head of the audit chain, returning nil when there is nothing new to cover
+func (s *AuditService) Checkpoint(ctx context.Context) (*model.AuditCheckpointResponse, error) {
+	if s.signingKey == nil {
+		return nil, model.ErrAuditSigningKeyMissing
+	}
+	// An unchanged head is signed again every interval, so a verifier can tell a quiet chain from a cut one
+	checkpoint, err := s.fileLogsRepository.CreateCheckpoint(ctx, s.interval/2, s.signCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		return nil, nil
+	}
+
+	slog.Info("Audit checkpoint signed", slog.Uint64("log_id", uint64(checkpoint.LogID)), slog.String("key_id", checkpoint.KeyID))
+	return &model.AuditCheckpointResponse{
+		ID:        checkpoint.ID,
+		LogID:     checkpoint.LogID,
+		LogHash:   checkpoint.LogHash,
+		Kind:      checkpoint.Kind,
+		KeyID:     checkpoint.KeyID,
+		CreatedAt: checkpoint.CreatedAt,
+	}, nil
+}
+
+// PurgeOldLogs removes logs older than days from the start of the chain behind a signed retention anchor
+func (s *AuditService) PurgeOldLogs(ctx context.Context, days int) error {
+	if days <= 0 {
+		return model.ErrInvalidInput
+	}
+	if s.signingKey == nil {
+		return model.ErrAuditSigningKeyMissing
+	}
+	return s.fileLogsRepository.DeleteOldLogs(ctx, days, s.signCheckpoint)
+}
+
+// VerifyChain walks the audit chain from its newest retention anchor to its head and reports the first break.
+// Every entry must link to the hash of the entry before it and hash to its own Hash, every checkpoint must
+// carry a valid signature and match the entry it covers, and no covered entry may be missing.
+// With a signing key the checkpoints must also follow each other without a gap from the first one the anchor
+// kept, and the last one must have been signed within twice the checkpoint interval.
+func (s *AuditService) VerifyChain(ctx context.Context) (*model.AuditVerifyResponse, error) {
+	checkpoints, err := s.fileLogsRepository.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.AuditVerifyResponse{Valid: true, SignaturesVerified: s.signingKey != nil}
+	fail := func(brk model.AuditBreak) {
+		result.Valid = false
+		if result.FirstBreak == nil || brk.LogID < result.FirstBreak.LogID {
+			result.FirstBreak = &brk
+		}
+	}
+
+	// Checkpoints are listed in chain order, so the last retention checkpoint is where the chain now starts
+	var anchor *entity.AuditCheckpoints
+	signed := make([]*entity.AuditCheckpoints, 0, len(checkpoints))
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+		if s.signingKey != nil && !s.checkpointSignatureValid(checkpoint) {
+			fail(model.AuditBreak{LogID: checkpoint.LogID, CheckpointID: checkpoint.ID, Reason: "checkpoint signature does not verify"})
+			continue
+		}
+		signed = append(signed, checkpoint)
+		if checkpoint.Kind == constant.CheckpointKindRetention {
+			anchor = checkpoint
+		}
+	}
+	var head *entity.AuditCheckpoints
+	if s.signingKey != nil {
+		var brk *model.AuditBreak
+		head, brk = checkpointSequence(signed, anchor)
+		if brk != nil {
+			fail(*brk)
+		}
+	}
+
+	var afterID uint
+	prevHash := ""
+	chained := false
+	if anchor != nil {
+		afterID, prevHash = anchor.LogID, anchor.LogHash
+		chained = anchor.LogHash != ""
+		result.AnchorLogID = anchor.LogID
+	}
+	// Checkpoints past the anchor are matched against the entries they cover as the walk reaches them
+	next := 0
+	for next < len(checkpoints) && checkpoints[next].LogID <= afterID {
+		next++
+	}
+	missing := func(checkpoint entity.AuditCheckpoints) {
+		fail(model.AuditBreak{LogID: checkpoint.LogID, CheckpointID: checkpoint.ID, Reason: "entry covered by a signed checkpoint is missing, the chain was cut"})
+	}
+
+	for {
+		entries, err := s.fileLogsRepository.ListChain(ctx, afterID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for i := range entries {
+			entry := &entries[i]
+			afterID = entry.ID
+			result.HeadLogID = entry.ID
+
+			for ; next < len(checkpoints) && checkpoints[next].LogID <= entry.ID; next++ {
+				checkpoint := checkpoints[next]
+				result.CheckpointsChecked++
+				switch {
+				case checkpoint.LogID < entry.ID:
+					missing(checkpoint)
+				case checkpoint.LogHash != entry.Hash:
+					fail(model.AuditBreak{LogID: entry.ID, CheckpointID: checkpoint.ID, Reason: "entry does not match the checkpoint signed over it"})
+				}
+			}
+
+			// Entries written before the chain was introduced carry no hash and can only lead the chain
+			if entry.Hash == "" && !chained {
+				result.UnchainedEntries++
+				continue
+			}
+			chained = true
+
+			switch {
+			case entry.Hash == "":
+				fail(model.AuditBreak{LogID: entry.ID, Reason: "entry has no hash"})
+			case entry.PrevHash != prevHash:
+				fail(model.AuditBreak{LogID: entry.ID, Reason: "previous hash does not match the entry before it, entries were removed or reordered"})
+			case entry.ComputeHash() != entry.Hash:
+				fail(model.AuditBreak{LogID: entry.ID, Reason: "entry content does not match its hash"})
+			}
+			if result.FirstBreak != nil && result.FirstBreak.LogID <= entry.ID {
+				return result, nil
+			}
+			prevHash = entry.Hash
+			result.EntriesChecked++
+		}
+	}
+
+	// Checkpoints past the head cover entries that were cut from its end
+	for ; next < len(checkpoints); next++ {
+		result.CheckpointsChecked++
+		missing(checkpoints[next])
+	}
+
+	// The head is signed every interval, so an old last checkpoint means the newest ones were removed
+	if s.signingKey != nil && result.HeadLogID != 0 && (head == nil || time.Since(head.CreatedAt) > 2*s.interval) {
+		brk := model.AuditBreak{LogID: result.HeadLogID, Reason: "no checkpoint was signed within twice the checkpoint interval, the newest checkpoints were removed"}
+		if head != nil {
+			brk.CheckpointID = head.ID
+		}
+		fail(brk)
+	}
+	return result, nil
+}
+
+// checkpointSequence orders checkpoints as they were signed and returns the last one, together with the first
+// break in the sequence: it starts at the first checkpoint the retention anchor kept, or at the first one ever
+// signed, and every checkpoint must follow the one before it and carry its signature.
+func checkpointSequence(signed []*entity.AuditCheckpoints, anchor *entity.AuditCheckpoints) (*entity.AuditCheckpoints, *model.AuditBreak) {
+	sort.Slice(signed, func(i, j int) bool { return signed[i].Sequence < signed[j].Sequence })
+
+	expected := uint(1)
+	if anchor != nil {
+		expected = anchor.FirstSequence
+	}
+	var previous *entity.AuditCheckpoints
+	for _, checkpoint := range signed {
+		if checkpoint.Sequence != expected || (previous != nil && checkpoint.PrevSignature != previous.Signature) {
+			return signed[len(signed)-1], &model.AuditBreak{LogID: checkpoint.LogID, CheckpointID: checkpoint.ID, Reason: "checkpoint sequence has a gap, checkpoints were removed"}
+		}
+		previous = checkpoint
+		expected++
+	}
+	return previous, nil
+}
+
+// signCheckpoint sets the key ID and signature of a checkpoint
+func (s *AuditService) signCheckpoint(checkpoint *entity.AuditCheckpoints) error {
+	if s.signingKey == nil {
+		return model.ErrAuditSigningKeyMissing
+	}
+	checkpoint.KeyID = s.keyID
+	checkpoint.Signature = s.checkpointSignature(checkpoint)
+	return nil
+}
+
+// checkpointSignatureValid reports whether a checkpoint was signed with the current key and is unchanged since
+func (s *AuditService) checkpointSignatureValid(checkpoint *entity.AuditCheckpoints) bool {
+	expected := s.checkpointSignature(checkpoint)
+	return checkpoint.KeyID == s.keyID && hmac.Equal([]byte(expected), []byte(checkpoint.Signature))
+}
+
+// checkpointSignature returns the base64 HMAC-SHA256 over the signed fields of a checkpoint
+func (s *AuditService) checkpointSignature(checkpoint *entity.AuditCheckpoints) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s",
+		checkpoint.KeyID,
+		checkpoint.Kind,
+		strconv.FormatUint(uint64(checkpoint.Sequence), 10),
+		checkpoint.PrevSignature,
+		strconv.FormatUint(uint64(checkpoint.FirstSequence), 10),
+		strconv.FormatUint(uint64(checkpoint.LogID), 10),
+		checkpoint.LogHash,
+		checkpoint.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type AuditServiceParams struct {
+	FileLogsRepository repository.FileLogsRepository
+	KeyConfig          *model.KeyConfig
+	Interval           time.Duration
+	ProductVersion     string // reported as the device version of CEF exports
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+)
+
+// auditEntry is an action to record in the audit trail
+type auditEntry struct {
+	ActorID      string // defaults to the authenticated caller of the request
+	ActorType    string
+	ResourceType string
+	ResourceID   string
+	Action       constant.ActionType
+	Err          error // the action failed when set
+	Metadata     map[string]interface{}
+}
+
+// recordAudit writes entry to the audit trail together with the client address, user agent and trace ID
+// of the request in ctx. The entry is written even when ctx is already cancelled; callers ignore the error
+// so a failure to record never changes the outcome of the action itself.
+func recordAudit(ctx context.Context, repo repository.FileLogsRepository, entry auditEntry) error {
+	return repo.Create(context.Background(), newAuditLog(ctx, entry))
+}
+
+// newAuditLog builds the log entry recording entry in the request in ctx
+func newAuditLog(ctx context.Context, entry auditEntry) *entity.FileLogs {
+	log := &entity.FileLogs{
+		ActorID:      entry.ActorID,
+		ActorType:    entry.ActorType,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		Action:       string(entry.Action),
+		Outcome:      constant.OutcomeSuccess,
+		IP:           helper.GetClientIP(ctx),
+		UserAgent:    helper.GetUserAgent(ctx),
+		Metadata:     entry.Metadata,
+	}
+	if info := helper.RequestInfoFromContext(ctx); info != nil {
+		log.TraceID = info.TraceID
+		if log.ActorID == "" {
+			log.ActorID, log.ActorType = info.ActorID, info.ActorType
+		}
+	}
+	if log.ActorType == "" {
+		log.ActorType = constant.ActorTypeSystem
+	}
+	if log.ResourceType == constant.ResourceTypeFile {
+		log.FileID = entry.ResourceID
+	}
+	if entry.Err != nil {
+		log.Outcome = constant.OutcomeFailure
+		if log.Metadata == nil {
+			log.Metadata = map[string]interface{}{}
+		}
+		log.Metadata["error"] = entry.Err.Error()
+	}
+	return log
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultBucketMigrationInterval  = 5 * time.Minute
+	defaultBucketMigrationBatchSize = 100
+)
+
+// BucketMigrationService moves the files of apps into the bucket each app stores new files in, once an admin asks for it.
+// Every stored version of a file is copied, oldest first, the database is pointed at the copies in one transaction
+// and only then are the versions left behind removed, so a file is readable from one bucket or the other throughout.
+// Files on legal hold or under retention stay where they are, as their versions cannot be removed, and a file that
+// changes while it is copied is tried again on the next pass.
+type BucketMigrationService struct {
+	storageService        StorageInterface
+	fileRepository        repository.FileRepository
+	fileVersionRepository repository.FileVersionRepository
+	applicationRepository repository.ApplicationRepository
+	fileLogsRepository    repository.FileLogsRepository
+	defaultBucket         string
+	interval              time.Duration
+	batchSize             int
+}
+
+func NewBucketMigrationService(params BucketMigrationServiceParams) BucketMigrationInterface {
+	interval := params.Interval
+	if interval <= 0 {
+		interval = defaultBucketMigrationInterval
+	}
+	batchSize := params.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBucketMigrationBatchSize
+	}
+
+	return &BucketMigrationService{
+		storageService:        params.StorageService,
+		fileRepository:        params.FileRepository,
+		fileVersionRepository: params.FileVersionRepository,
+		applicationRepository: params.ApplicationRepository,
+		fileLogsRepository:    params.FileLogsRepository,
+		defaultBucket:         params.DefaultBucket,
+		interval:              interval,
+		batchSize:             batchSize,
+	}
+}
+
+// Run moves the files of apps with a pending migration every interval until the context is cancelled
+func (s *BucketMigrationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.MigratePending(ctx)
+		if err != nil {
+			slog.Error("Failed to move files into app buckets", slog.Any("error", err))
+		} else if report.FilesMoved+report.Failures > 0 {
+			slog.Info("Files moved into app buckets", slog.Int("apps", report.Apps),
+				slog.Int("files_moved", report.FilesMoved), slog.Int("failures", report.Failures))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MigratePending makes one pass over every app with a pending migration. An app's migration is finished once a
+// pass moves all of its movable files; a file that fails is counted and the app is taken up again on the next pass.
+func (s *BucketMigrationService) MigratePending(ctx context.Context) (*model.BucketMigrationReport, error) {
+	apps, err := s.applicationRepository.ListPendingBucketMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.BucketMigrationReport{}
+	for _, app := range apps {
+		report.Apps++
+		failures := report.Failures
+
+		afterID := ""
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			files, err := s.fileRepository.ListFilesOutsideBucket(ctx, app.ID, app.BucketName, afterID, s.batchSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				if err := s.moveFile(ctx, &app, &file); err != nil {
+					slog.Error("Failed to move file into app bucket", slog.String("app_id", app.ID),
+						slog.String("file_id", file.ID), slog.String("bucket", app.BucketName), slog.Any("error", err))
+					report.Failures++
+					continue
+				}
+				report.FilesMoved++
+			}
+			if len(files) < s.batchSize {
+				break
+			}
+			afterID = files[len(files)-1].ID
+		}
+
+		if report.Failures == failures {
+			if err := s.applicationRepository.FinishBucketMigration(ctx, app.ID, *app.BucketMigrationAt); err != nil {
+				return nil, err
+			}
+			slog.Info("App files moved into its bucket", slog.String("app_id", app.ID), slog.String("bucket", app.BucketName))
+		}
+	}
+	return report, nil
+}
+
+// moveFile copies every stored version of a file into the bucket of its app, points the database at the copies
+// and removes the versions left in the bucket it came from
+func (s *BucketMigrationService) moveFile(ctx context.Context, app *entity.Apps, file *entity.Files) (err error) {
+	fromBucket := file.BucketName
+	if fromBucket == "" {
+		fromBucket = s.defaultBucket
+	}
+	objectName := createFileName(file.ID)
+	defer func() {
+		// A file that changed while it was copied is moved on a later pass, which is not worth an audit entry
+		if errors.Is(err, model.ErrFileMoveConflict) {
+			return
+		}
+		_ = recordAudit(ctx, s.fileLogsRepository, auditEntry{
+			ActorType:    constant.ActorTypeSystem,
+			ResourceType: constant.ResourceTypeFile,
+			ResourceID:   file.ID,
+			Action:       constant.ActionTypeMigrate,
+			Err:          err,
+			Metadata:     map[string]interface{}{"app_id": app.ID, "from_bucket": fromBucket, "to_bucket": app.BucketName},
+		})
+	}()
+
+	versionIDs, err := s.storedVersions(ctx, app.ID, file)
+	if err != nil {
+		return err
+	}
+
+	// Files recorded without a bucket may already be in the app's bucket when it is the default one
+	if fromBucket == app.BucketName {
+		unchanged := make(map[string]string, len(versionIDs))
+		for _, versionID := range versionIDs {
+			unchanged[versionID] = versionID
+		}
+		return s.fileRepository.MoveToBucket(ctx, file.ID, file.BucketName, app.BucketName, file.Location, unchanged)
+	}
+
+	copies := make(map[string]string, len(versionIDs))
+	var location string
+	for _, versionID := range versionIDs {
+		resp, err := s.copyVersion(ctx, fromBucket, app.BucketName, objectName, versionID)
+		if err != nil {
+			s.removeCopies(app.BucketName, objectName, copies)
+			return err
+		}
+		copies[versionID] = resp.VersionID
+		location = resp.Location
+	}
+
+	if err := s.fileRepository.MoveToBucket(ctx, file.ID, file.BucketName, app.BucketName, location, copies); err != nil {
+		s.removeCopies(app.BucketName, objectName, copies)
+		return err
+	}
+
+	// The file is read from its new bucket from here on, so what is left behind is only removed on a best effort basis
+	left, err := s.storageService.ListFileVersion(ctx, fromBucket, objectName)
+	if err != nil {
+		slog.Warn("Failed to list file versions left in old bucket", slog.String("file_id", file.ID), slog.String("bucket", fromBucket), slog.Any("error", err))
+		return nil
+	}
+	for _, versionID := range left {
+		if err := s.storageService.DeleteFileVersion(ctx, fromBucket, objectName, versionID); err != nil {
+			slog.Warn("Failed to remove file version left in old bucket", slog.String("file_id", file.ID),
+				slog.String("bucket", fromBucket), slog.String("version_id", versionID), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// storedVersions lists the storage versions the versions and metadata of a file refer to, oldest first and
+// ending with the current contents, so the copy of the current contents is the latest version in the new bucket
+func (s *BucketMigrationService) storedVersions(ctx context.Context, appID string, file *entity.Files) ([]string, error) {
+	var metadata *entity.Metadata
+	var err error
+	if file.DeletedAt.Valid {
+		metadata, err = s.fileRepository.GetDeletedMetadataByAppIDAndFileID(ctx, appID, file.ID)
+	} else {
+		metadata, err = s.fileRepository.GetMetadataByFileID(ctx, file.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.fileVersionRepository.GetByFileID(ctx, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{metadata.VersionID: true}
+	var versionIDs []string
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versionID := versions[i].VersionID; !seen[versionID] {
+			seen[versionID] = true
+			versionIDs = append(versionIDs, versionID)
+		}
+	}
+	return append(versionIDs, metadata.VersionID), nil
+}
+
+// copyVersion copies one stored version of an object into another bucket as its latest version.
+// Contents recorded without a version are read from the latest version.
+func (s *BucketMigrationService) copyVersion(ctx context.Context, fromBucket, toBucket, objectName, versionID string) (*model.StorageTransactionResponse, error) {
+	var content io.ReadCloser
+	var err error
+	if versionID == "" {
+		content, err = s.storageService.DownloadFileStream(ctx, fromBucket, objectName)
+	} else {
+		content, err = s.storageService.DownloadFileVersionStream(ctx, fromBucket, objectName, versionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return s.storageService.UploadFile(ctx, toBucket, objectName, content, -1)
+}
+
+// removeCopies removes the versions copied into a bucket for a move that did not go through
+func (s *BucketMigrationService) removeCopies(bucketName, objectName string, copies map[string]string) {
+	for _, versionID := range copies {
+		if err := s.storageService.DeleteFileVersion(context.Background(), bucketName, objectName, versionID); err != nil {
+			slog.Warn("Failed to remove copy of file version", slog.String("bucket", bucketName),
+				slog.String("object", objectName), slog.String("version_id", versionID), slog.Any("error", err))
+		}
+	}
+}
+
+type BucketMigrationServiceParams struct {
+	StorageService        StorageInterface
+	FileRepository        repository.FileRepository
+	FileVersionRepository repository.FileVersionRepository
+	ApplicationRepository repository.ApplicationRepository
+	FileLogsRepository    repository.FileLogsRepository
+	DefaultBucket         string // bucket of files recorded without one
+	Interval              time.Duration
+	BatchSize             int
+}
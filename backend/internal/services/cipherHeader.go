@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Ciphertext header layout, written in front of every ciphertext produced since format version 1:
+//
+//	magic "CRYP" | version (1) | algorithm (1) | chunk size (4, big endian) | key ref length (1) | key ref
+//
+// The header is authenticated as part of the associated data, so none of its fields can be altered.
+// Ciphertexts without the magic prefix predate the header and are decrypted as before.
+const (
+	CipherHeaderVersion = 1
+
+	// CipherAlgorithmAEAD is single-shot Tink AES-256-GCM
+	CipherAlgorithmAEAD uint8 = 1
+	// CipherAlgorithmStream is Tink streaming AES-256-GCM-HKDF keyed with the data key's own key material
+	CipherAlgorithmStream uint8 = 2
+	// CipherAlgorithmStreamDerived is Tink streaming AES-256-GCM-HKDF keyed with a streaming key derived from the data key
+	CipherAlgorithmStreamDerived uint8 = 3
+
+	cipherHeaderFixedSize = 11
+	cipherHeaderMaxSize   = cipherHeaderFixedSize + 255
+)
+
+var cipherHeaderMagic = []byte("CRYP")
+
+// CipherHeader is the self-describing prefix of a ciphertext.
+type CipherHeader struct {
+	Version   uint8
+	Algorithm uint8
+	ChunkSize uint32 // ciphertext segment size for streams, 0 for single-shot ciphertexts
+	KeyRef    string // KMS key UID the data key belongs to, empty for locally wrapped keys
+}
+
+// MarshalBinary encodes the header in its wire format.
+func (h CipherHeader) MarshalBinary() ([]byte, error) {
+	if len(h.KeyRef) > 255 {
+		return nil, fmt.Errorf("key reference too long: %d bytes", len(h.KeyRef))
+	}
+
+	buf := make([]byte, 0, cipherHeaderFixedSize+len(h.KeyRef))
+	buf = append(buf, cipherHeaderMagic...)
+	buf = append(buf, h.Version, h.Algorithm)
+	buf = binary.BigEndian.AppendUint32(buf, h.ChunkSize)
+	buf = append(buf, uint8(len(h.KeyRef)))
+	buf = append(buf, h.KeyRef...)
+	return buf, nil
+}
+
+// HasCipherHeader reports whether data starts with a ciphertext header.
+func HasCipherHeader(data []byte) bool {
+	return bytes.HasPrefix(data, cipherHeaderMagic)
+}
+
+// ParseCipherHeader decodes the header at the start of data and returns it with its encoded length.
+func ParseCipherHeader(data []byte) (*CipherHeader, int, error) {
+	if !HasCipherHeader(data) {
+		return nil, 0, errors.New("ciphertext has no header")
+	}
+	if len(data) < cipherHeaderFixedSize {
+		return nil, 0, errors.New("ciphertext header is truncated")
+	}
+
+	header := &CipherHeader{
+		Version:   data[4],
+		Algorithm: data[5],
+		ChunkSize: binary.BigEndian.Uint32(data[6:10]),
+	}
+	if header.Version != CipherHeaderVersion {
+		return nil, 0, fmt.Errorf("unsupported ciphertext header version %d", header.Version)
+	}
+
+	size := cipherHeaderFixedSize + int(data[10])
+	if len(data) < size {
+		return nil, 0, errors.New("ciphertext header is truncated")
+	}
+	header.KeyRef = string(data[cipherHeaderFixedSize:size])
+	return header, size, nil
+}
+
+// isStreamAlgorithm reports whether a ciphertext header algorithm describes a stream
+func isStreamAlgorithm(algorithm uint8) bool {
+	return algorithm == CipherAlgorithmStream || algorithm == CipherAlgorithmStreamDerived
+}
+
+// headerAssociatedData binds the encoded header in front of the caller's associated data
+func headerAssociatedData(header, associatedData []byte) []byte {
+	bound := make([]byte, 0, len(header)+len(associatedData))
+	bound = append(bound, header...)
+	return append(bound, associatedData...)
+}
+
+// fileAssociatedData returns the associated data binding a ciphertext to its file and owning app,
+// so a blob copied onto another file or into another app fails authentication.
+// Ciphertexts sealed before header version 1 carry no associated data.
+func fileAssociatedData(headerVersion int, fileID, appID string) []byte {
+	if headerVersion < CipherHeaderVersion {
+		return nil
+	}
+	return []byte(fmt.Sprintf("file/%s/app/%s", fileID, appID))
+}
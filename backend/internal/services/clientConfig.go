@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"fmt"
+
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+)
+
+// validateClientConfig checks the settings an app asks for; empty settings fall back to the server defaults
+func validateClientConfig(bucketName, hashMethod, encryptionMethod string) error {
+	if bucketName != "" {
+		if err := s3utils.CheckValidBucketNameStrict(bucketName); err != nil {
+			return fmt.Errorf("%w: %s", model.ErrInvalidBucketName, err.Error())
+		}
+	}
+	switch hashMethod {
+	case "", HashSHA256, HashMD5:
+	default:
+		return fmt.Errorf("%w %q", model.ErrUnsupportedHashMethod, hashMethod)
+	}
+	switch encryptionMethod {
+	case "", EncryptionAES256GCM:
+	default:
+		return fmt.Errorf("%w %q", model.ErrUnsupportedEncryptionMethod, encryptionMethod)
+	}
+	return nil
+}
+
+// clientConfig returns the settings the new files of an app are stored with
+func (c *FileService) clientConfig(ctx context.Context, appID string) (*model.ClientConfig, error) {
+	app, err := c.applicationRepository.GetByID(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	return c.appClientConfig(app), nil
+}
+
+// appClientConfig applies the settings of an app over the server defaults
+func (c *FileService) appClientConfig(app *entity.Apps) *model.ClientConfig {
+	config := &model.ClientConfig{
+		BucketName:        c.bucketName,
+		HashMethod:        c.hashMethod,
+		HashEncryptedFile: c.hashEncryptedFile,
+		EncryptionMethod:  c.encryptionMethod,
+	}
+	if app.BucketName != "" {
+		config.BucketName = app.BucketName
+	}
+	if app.HashMethod != "" {
+		config.HashMethod = app.HashMethod
+	}
+	if app.EncryptionMethod != "" {
+		config.EncryptionMethod = app.EncryptionMethod
+	}
+	if app.HashEncryptedFile != nil {
+		config.HashEncryptedFile = *app.HashEncryptedFile
+	}
+	return config
+}
+
+// fileBucket returns the bucket a file is stored in, which stays where it was written when the app's bucket changes.
+// Files written before buckets were recorded are in the default bucket.
+func (c *FileService) fileBucket(file *entity.Files) string {
+	if file.BucketName != "" {
+		return file.BucketName
+	}
+	return c.bucketName
+}
+
+// fileHashMethod returns the algorithm a file's hashes were computed with, which stays with the file when the
+// app's hash method changes. Rows written before the algorithm was recorded used the server default.
+func (c *FileService) fileHashMethod(hashAlgo string) string {
+	if hashAlgo != "" {
+		return hashAlgo
+	}
+	return c.hashMethod
+}
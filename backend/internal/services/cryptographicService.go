@@ -1,12 +1,18 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log/slog"
 
 	"github.com/awnumar/memguard"
@@ -15,7 +21,12 @@ import (
 	"github.com/tink-crypto/tink-go/v2/keyset"
 	"github.com/tink-crypto/tink-go/v2/prf"
 	aeadpb "github.com/tink-crypto/tink-go/v2/proto/aes_gcm_go_proto"
+	streamingpb "github.com/tink-crypto/tink-go/v2/proto/aes_gcm_hkdf_streaming_go_proto"
+	commonpb "github.com/tink-crypto/tink-go/v2/proto/common_go_proto"
 	tinkpb "github.com/tink-crypto/tink-go/v2/proto/tink_go_proto"
+	"github.com/tink-crypto/tink-go/v2/streamingaead"
+	"github.com/tink-crypto/tink-go/v2/subtle"
+	"github.com/tink-crypto/tink-go/v2/tink"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -24,6 +35,41 @@ const (
 	HashMD5    = "MD5"
 )
 
+// EncryptionAES256GCM names the only cipher files are sealed with: Tink's AES-256-GCM, streamed as AES-256-GCM-HKDF
+const EncryptionAES256GCM = "AES-256-GCM"
+
+const (
+	aesGcmKeyTypeURL          = "type.googleapis.com/google.crypto.tink.AesGcmKey"
+	aesGcmHkdfStreamingKeyURL = "type.googleapis.com/google.crypto.tink.AesGcmHkdfStreamingKey"
+
+	// StreamSegmentSize is the ciphertext segment size used by streaming encryption (1 MiB)
+	StreamSegmentSize = 1 << 20
+
+	// Wire format constants of Tink's AES-GCM-HKDF streaming AEAD (tink-go v2.5.0, streamingaead/subtle/noncebased),
+	// needed to decrypt single segments. NewRangeDecryptingReader relies on this layout of a stream:
+	//
+	//	header length (1) | HKDF salt (derived key size) | nonce prefix (7) | segment 0 | segment 1 | ... | last segment
+	//
+	//   - The header length byte equals the header size, 1 + derived key size + 7, and is checked on every range.
+	//   - Every segment is ciphertextSegmentSize bytes except the last, which may be shorter but holds at least its tag.
+	//     The header shares the first segment, so segment 0 carries header size fewer bytes of ciphertext.
+	//   - Each segment is AES-GCM sealed on its own with no associated data, under the key
+	//     HKDF(key, salt, associated data), with the nonce: nonce prefix (7) | segment index (4, big endian) | last flag (1).
+	//   - Tink's writer only seals a segment once more plaintext follows it, so a plaintext that exactly fills its
+	//     segments ends on a full last segment, never an empty extra one: the segment count is always
+	//     ceil(ciphertext size / ciphertextSegmentSize).
+	//
+	// TestRangeDecryptingReader and TestRangeDecryptingReaderFullLastSegment pin these against the Tink version in go.mod.
+	streamNonceSize       = 12
+	streamNoncePrefixSize = 7
+	streamTagSize         = 16
+	streamHeaderMaxSize   = 1 + 32 + streamNoncePrefixSize
+
+	// streamingKeyInfo labels the HKDF derivation of a streaming key from an AES-GCM data key,
+	// so the data key's own key material is never used as the streaming HKDF input directly
+	streamingKeyInfo = "crypsis streaming key"
+)
+
 type CryptographicService struct{}
 
 // NewCryptographicService creates a new instance of CryptographicService
@@ -72,7 +118,7 @@ func (c *CryptographicService) KeysetFromRawAES256GCM(rawKey []byte) (*keyset.Ha
 
 	// 2. Wrap it in KeyData
 	keyData := &tinkpb.KeyData{
-		TypeUrl:         "type.googleapis.com/google.crypto.tink.AesGcmKey",
+		TypeUrl:         aesGcmKeyTypeURL,
 		Value:           serializedKey,
 		KeyMaterialType: tinkpb.KeyData_SYMMETRIC,
 	}
@@ -240,8 +286,10 @@ func (c *CryptographicService) CompareHash(hashMethod, text, hash string) bool {
 	return hashedText == hash
 }
 
-// EncryptFile encrypts a file using Tink AEAD
-func (c *CryptographicService) EncryptFile(key string, file []byte) ([]byte, error) {
+// EncryptFile encrypts a file using Tink AEAD.
+// The ciphertext is prefixed with a header naming keyRef; the header and associatedData are authenticated
+// but not encrypted, and the same associatedData must be given to decrypt.
+func (c *CryptographicService) EncryptFile(key, keyRef string, file, associatedData []byte) ([]byte, error) {
 	// Secure key string with memguard
 	memguardKey := memguard.NewBufferFromBytes([]byte(key))
 	defer memguardKey.Destroy()
@@ -270,16 +318,26 @@ func (c *CryptographicService) EncryptFile(key string, file []byte) ([]byte, err
 		return nil, fmt.Errorf("failed to get AEAD primitive: %w", err)
 	}
 
-	ciphertext, err := primitive.Encrypt(file, nil)
+	header, err := CipherHeader{
+		Version:   CipherHeaderVersion,
+		Algorithm: CipherAlgorithmAEAD,
+		KeyRef:    keyRef,
+	}.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := primitive.Encrypt(file, headerAssociatedData(header, associatedData))
 	if err != nil {
 		slog.Error("Failed to encrypt file", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to encrypt file: %w", err)
 	}
-	return ciphertext, nil
+	return append(header, ciphertext...), nil
 }
 
-// DecryptFile decrypts a file using Tink AEAD
-func (c *CryptographicService) DecryptFile(key string, encryptedFile []byte) ([]byte, error) {
+// DecryptFile decrypts a file using Tink AEAD.
+// A ciphertext sealed for different associated data, such as another file or app, is rejected.
+func (c *CryptographicService) DecryptFile(key string, encryptedFile, associatedData []byte) ([]byte, error) {
 	memguardKey := memguard.NewBufferFromBytes([]byte(key))
 	defer memguardKey.Destroy()
 
@@ -304,8 +362,21 @@ func (c *CryptographicService) DecryptFile(key string, encryptedFile []byte) ([]
 		return nil, fmt.Errorf("failed to get AEAD primitive: %w", err)
 	}
 
+	// Headerless ciphertexts predate the header and are opened with associatedData alone
+	if HasCipherHeader(encryptedFile) {
+		cipherHeader, headerSize, err := ParseCipherHeader(encryptedFile)
+		if err != nil {
+			return nil, err
+		}
+		if cipherHeader.Algorithm != CipherAlgorithmAEAD {
+			return nil, errors.New("ciphertext header does not describe a single-shot ciphertext")
+		}
+		associatedData = headerAssociatedData(encryptedFile[:headerSize], associatedData)
+		encryptedFile = encryptedFile[headerSize:]
+	}
+
 	// Decrypt the file
-	plaintext, err := primitive.Decrypt(encryptedFile, nil)
+	plaintext, err := primitive.Decrypt(encryptedFile, associatedData)
 	if err != nil {
 		slog.Error("Failed to decrypt file", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to decrypt file: %w", err)
@@ -315,6 +386,507 @@ func (c *CryptographicService) DecryptFile(key string, encryptedFile []byte) ([]
 	return plaintext, nil
 }
 
+// NewEncryptingWriter returns a writer that encrypts everything written to it into dst using
+// Tink streaming AEAD (AES256-GCM-HKDF, 1 MiB segments). The caller must Close the writer to
+// flush the final segment. The key is the same base64 Tink keyset used by EncryptFile.
+// The stream is prefixed with a ciphertext header naming keyRef; the header and associatedData are
+// authenticated but not encrypted, and the same associatedData must be given to decrypt.
+func (c *CryptographicService) NewEncryptingWriter(key, keyRef string, dst io.Writer, associatedData []byte) (io.WriteCloser, error) {
+	primitive, err := c.streamingPrimitive(key, CipherAlgorithmStreamDerived)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := CipherHeader{
+		Version:   CipherHeaderVersion,
+		Algorithm: CipherAlgorithmStreamDerived,
+		ChunkSize: StreamSegmentSize,
+		KeyRef:    keyRef,
+	}.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write ciphertext header: %w", err)
+	}
+
+	writer, err := primitive.NewEncryptingWriter(dst, headerAssociatedData(header, associatedData))
+	if err != nil {
+		slog.Error("Failed to create encrypting writer", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to create encrypting writer: %w", err)
+	}
+	return writer, nil
+}
+
+// NewDecryptingReader returns a reader that decrypts a stream produced by NewEncryptingWriter.
+// Every segment is authenticated as it is read, so tampering or truncation surfaces as a read error.
+// Headerless streams written before ciphertext headers existed are opened with associatedData alone.
+func (c *CryptographicService) NewDecryptingReader(key string, src io.Reader, associatedData []byte) (io.Reader, error) {
+	// A Tink stream starts with its header length byte, which never matches the ciphertext header magic
+	algorithm := CipherAlgorithmStream
+	buffered := bufio.NewReaderSize(src, cipherHeaderMaxSize)
+	if prefix, _ := buffered.Peek(len(cipherHeaderMagic)); HasCipherHeader(prefix) {
+		prefix, _ = buffered.Peek(cipherHeaderMaxSize)
+		cipherHeader, headerSize, err := ParseCipherHeader(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if !isStreamAlgorithm(cipherHeader.Algorithm) {
+			return nil, errors.New("ciphertext header does not describe a stream")
+		}
+		algorithm = cipherHeader.Algorithm
+		associatedData = headerAssociatedData(bytes.Clone(prefix[:headerSize]), associatedData)
+		if _, err := buffered.Discard(headerSize); err != nil {
+			return nil, fmt.Errorf("failed to read ciphertext header: %w", err)
+		}
+	}
+
+	primitive, err := c.streamingPrimitive(key, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := primitive.NewDecryptingReader(buffered, associatedData)
+	if err != nil {
+		slog.Error("Failed to create decrypting reader", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to create decrypting reader: %w", err)
+	}
+	return reader, nil
+}
+
+// streamingPrimitive builds a streaming AEAD primitive from a base64 Tink keyset for a stream of the given
+// ciphertext header algorithm, see streamingKey.
+func (c *CryptographicService) streamingPrimitive(key string, algorithm uint8) (tink.StreamingAEAD, error) {
+	streamingKey, err := c.streamingKey(key, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer memguard.WipeBytes(streamingKey.KeyValue)
+
+	handle, err := keysetFromStreamingKey(streamingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	primitive, err := streamingaead.New(handle)
+	if err != nil {
+		slog.Error("Failed to get streaming AEAD primitive", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to get streaming AEAD primitive: %w", err)
+	}
+	return primitive, nil
+}
+
+// streamingKey returns the AES-GCM-HKDF streaming key for a base64 Tink keyset, converting AES-GCM keysets
+// (the format produced by GenerateKey and ImportRawKeyAsBase64) so per-file keys work for both formats.
+// For CipherAlgorithmStreamDerived the streaming key is derived from the AES-GCM key with a labelled HKDF;
+// streams of the older CipherAlgorithmStream, and headerless ones, reused the AES-GCM key material as is.
+// The caller should wipe the returned KeyValue once done with it.
+func (c *CryptographicService) streamingKey(key string, algorithm uint8) (*streamingpb.AesGcmHkdfStreamingKey, error) {
+	memguardKey := memguard.NewBufferFromBytes([]byte(key))
+	defer memguardKey.Destroy()
+
+	keyBytes, err := base64.StdEncoding.DecodeString(memguardKey.String())
+	if err != nil {
+		slog.Error("Failed to decode base64 key", slog.Any("error", err))
+		return nil, fmt.Errorf("invalid base64 key: %w", err)
+	}
+
+	secureKeyBytes := memguard.NewBufferFromBytes(keyBytes)
+	defer secureKeyBytes.Destroy()
+
+	handle, err := insecurecleartextkeyset.Read(keyset.NewBinaryReader(bytes.NewReader(secureKeyBytes.Bytes())))
+	if err != nil {
+		slog.Error("Failed to read keyset", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to read keyset: %w", err)
+	}
+
+	ks := insecurecleartextkeyset.KeysetMaterial(handle)
+	if ks == nil || len(ks.Key) == 0 {
+		return nil, errors.New("keyset is empty")
+	}
+
+	var primary *tinkpb.Keyset_Key
+	for _, k := range ks.Key {
+		if k.KeyId == ks.PrimaryKeyId {
+			primary = k
+			break
+		}
+	}
+	if primary == nil || primary.KeyData == nil {
+		return nil, errors.New("primary key not found in keyset")
+	}
+
+	switch primary.KeyData.TypeUrl {
+	case aesGcmKeyTypeURL:
+		aesKey := &aeadpb.AesGcmKey{}
+		if err := proto.Unmarshal(primary.KeyData.Value, aesKey); err != nil {
+			slog.Error("Failed to unmarshal AES-GCM key", slog.Any("error", err))
+			return nil, fmt.Errorf("failed to unmarshal AES-GCM key: %w", err)
+		}
+		if len(aesKey.KeyValue) != 32 {
+			memguard.WipeBytes(aesKey.KeyValue)
+			return nil, fmt.Errorf("invalid key length: expected 32 bytes for AES-256, got %d bytes", len(aesKey.KeyValue))
+		}
+		if algorithm != CipherAlgorithmStreamDerived {
+			return newAES256GCMHKDFStreamingKey(aesKey.KeyValue), nil
+		}
+		derivedKey, err := subtle.ComputeHKDF("SHA256", aesKey.KeyValue, nil, []byte(streamingKeyInfo), 32)
+		memguard.WipeBytes(aesKey.KeyValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive streaming key: %w", err)
+		}
+		return newAES256GCMHKDFStreamingKey(derivedKey), nil
+	case aesGcmHkdfStreamingKeyURL:
+		streamingKey := &streamingpb.AesGcmHkdfStreamingKey{}
+		if err := proto.Unmarshal(primary.KeyData.Value, streamingKey); err != nil {
+			slog.Error("Failed to unmarshal streaming key", slog.Any("error", err))
+			return nil, fmt.Errorf("failed to unmarshal streaming key: %w", err)
+		}
+		return streamingKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type for streaming encryption: %s", primary.KeyData.TypeUrl)
+	}
+}
+
+// newAES256GCMHKDFStreamingKey wraps 32 bytes of key material in AES256-GCM-HKDF streaming parameters
+func newAES256GCMHKDFStreamingKey(rawKey []byte) *streamingpb.AesGcmHkdfStreamingKey {
+	return &streamingpb.AesGcmHkdfStreamingKey{
+		Version:  0,
+		KeyValue: rawKey,
+		Params: &streamingpb.AesGcmHkdfStreamingParams{
+			CiphertextSegmentSize: StreamSegmentSize,
+			DerivedKeySize:        32,
+			HkdfHashType:          commonpb.HashType_SHA256,
+		},
+	}
+}
+
+// keysetFromRawAES256GCMHKDF wraps 32 bytes of key material in an AES256-GCM-HKDF streaming keyset
+func keysetFromRawAES256GCMHKDF(rawKey []byte) (*keyset.Handle, error) {
+	if len(rawKey) != 32 {
+		return nil, fmt.Errorf("invalid key length: expected 32 bytes for AES-256, got %d bytes", len(rawKey))
+	}
+	return keysetFromStreamingKey(newAES256GCMHKDFStreamingKey(rawKey))
+}
+
+// keysetFromStreamingKey builds a single-key keyset handle around a streaming key
+func keysetFromStreamingKey(streamingKey *streamingpb.AesGcmHkdfStreamingKey) (*keyset.Handle, error) {
+	serializedKey, err := proto.Marshal(streamingKey)
+	if err != nil {
+		slog.Error("Failed to marshal streaming key", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to marshal streaming key: %w", err)
+	}
+	defer memguard.WipeBytes(serializedKey)
+
+	keyID := uint32(123456)
+	ks := &tinkpb.Keyset{
+		PrimaryKeyId: keyID,
+		Key: []*tinkpb.Keyset_Key{
+			{
+				KeyData: &tinkpb.KeyData{
+					TypeUrl:         aesGcmHkdfStreamingKeyURL,
+					Value:           serializedKey,
+					KeyMaterialType: tinkpb.KeyData_SYMMETRIC,
+				},
+				Status:           tinkpb.KeyStatusType_ENABLED,
+				KeyId:            keyID,
+				OutputPrefixType: tinkpb.OutputPrefixType_RAW,
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := keyset.NewBinaryWriter(buf).Write(ks); err != nil {
+		slog.Error("Failed to write streaming keyset", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to write streaming keyset: %w", err)
+	}
+	defer memguard.WipeBytes(buf.Bytes())
+
+	handle, err := insecurecleartextkeyset.Read(keyset.NewBinaryReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		slog.Error("Failed to read streaming keyset", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to read streaming keyset: %w", err)
+	}
+	return handle, nil
+}
+
+// NewRangeDecryptingReader returns plaintext bytes [offset, offset+length) of a stream produced by NewEncryptingWriter
+// without reading the whole ciphertext. fetch must return ciphertext bytes [off, off+n) of the stored object, whose
+// total size is ciphertextSize. Only the header and the segments covering the range are fetched, and each segment is
+// authenticated on its own against its position in the stream.
+func (c *CryptographicService) NewRangeDecryptingReader(key string, associatedData []byte, ciphertextSize, offset, length int64, fetch func(off, n int64) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	// Fetch the ciphertext header, if any, together with the stream header in one request
+	prefix := make([]byte, min(ciphertextSize, cipherHeaderMaxSize+streamHeaderMaxSize))
+	prefixReader, err := fetch(0, int64(len(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(prefixReader, prefix)
+	prefixReader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream header: %w", err)
+	}
+
+	algorithm := CipherAlgorithmStream
+	var chunkSize int64
+	if HasCipherHeader(prefix) {
+		cipherHeader, headerSize, err := ParseCipherHeader(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if !isStreamAlgorithm(cipherHeader.Algorithm) {
+			return nil, errors.New("ciphertext header does not describe a stream")
+		}
+		algorithm, chunkSize = cipherHeader.Algorithm, int64(cipherHeader.ChunkSize)
+		associatedData = headerAssociatedData(prefix[:headerSize], associatedData)
+
+		// The stream starts right after the ciphertext header
+		prefix = prefix[headerSize:]
+		ciphertextSize -= int64(headerSize)
+		base, fetchStream := int64(headerSize), fetch
+		fetch = func(off, n int64) (io.ReadCloser, error) {
+			return fetchStream(base+off, n)
+		}
+	}
+
+	streamingKey, err := c.streamingKey(key, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer memguard.WipeBytes(streamingKey.KeyValue)
+
+	layout, err := newStreamLayout(streamingKey.Params)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize != 0 && chunkSize != layout.ciphertextSegmentSize {
+		return nil, errors.New("ciphertext header does not describe this stream")
+	}
+
+	if !layout.validSize(ciphertextSize) {
+		return nil, errors.New("invalid stream length")
+	}
+	if offset < 0 || length < 0 || offset+length > layout.plaintextSize(ciphertextSize) {
+		return nil, errors.New("range is outside the plaintext")
+	}
+
+	// The stream header carries the HKDF salt and the nonce prefix shared by all segments
+	if int64(len(prefix)) < layout.headerSize || int64(prefix[0]) != layout.headerSize {
+		return nil, errors.New("invalid stream header")
+	}
+	header := prefix[:layout.headerSize]
+	salt := header[1 : 1+layout.keySize]
+	noncePrefix := bytes.Clone(header[1+layout.keySize:])
+
+	derivedKey, err := subtle.ComputeHKDF(layout.hkdfHash, streamingKey.KeyValue, salt, associatedData, uint32(layout.keySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive segment key: %w", err)
+	}
+	defer memguard.WipeBytes(derivedKey)
+
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment cipher: %w", err)
+	}
+	segmentCipher, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment cipher: %w", err)
+	}
+
+	reader := &rangeDecryptingReader{
+		cipher:       segmentCipher,
+		layout:       layout,
+		noncePrefix:  noncePrefix,
+		lastSegment:  layout.segmentCount(ciphertextSize) - 1,
+		remaining:    length,
+		segmentCount: layout.segmentCount(ciphertextSize),
+	}
+	if length == 0 {
+		reader.source = io.NopCloser(bytes.NewReader(nil))
+		return reader, nil
+	}
+
+	first := layout.segmentOf(offset)
+	last := layout.segmentOf(offset + length - 1)
+	start, _ := layout.segmentBounds(first, ciphertextSize)
+	_, end := layout.segmentBounds(last, ciphertextSize)
+
+	reader.segment = first
+	reader.skip = offset - layout.segmentPlaintextStart(first)
+	reader.ciphertextSize = ciphertextSize
+	reader.source, err = fetch(start, end-start)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// streamLayout describes where the segments of an AES-GCM-HKDF stream sit in the ciphertext
+type streamLayout struct {
+	hkdfHash                  string
+	keySize                   int64
+	headerSize                int64
+	ciphertextSegmentSize     int64
+	plaintextSegmentSize      int64
+	firstPlaintextSegmentSize int64
+}
+
+func newStreamLayout(params *streamingpb.AesGcmHkdfStreamingParams) (streamLayout, error) {
+	if params == nil {
+		return streamLayout{}, errors.New("streaming key has no parameters")
+	}
+
+	var hkdfHash string
+	switch params.HkdfHashType {
+	case commonpb.HashType_SHA1:
+		hkdfHash = "SHA1"
+	case commonpb.HashType_SHA256:
+		hkdfHash = "SHA256"
+	case commonpb.HashType_SHA512:
+		hkdfHash = "SHA512"
+	default:
+		return streamLayout{}, fmt.Errorf("unsupported HKDF hash: %s", params.HkdfHashType)
+	}
+
+	keySize := int64(params.DerivedKeySize)
+	headerSize := 1 + keySize + streamNoncePrefixSize
+	segmentSize := int64(params.CiphertextSegmentSize)
+	if segmentSize <= headerSize+streamTagSize {
+		return streamLayout{}, errors.New("ciphertext segment size too small")
+	}
+
+	return streamLayout{
+		hkdfHash:                  hkdfHash,
+		keySize:                   keySize,
+		headerSize:                headerSize,
+		ciphertextSegmentSize:     segmentSize,
+		plaintextSegmentSize:      segmentSize - streamTagSize,
+		firstPlaintextSegmentSize: segmentSize - headerSize - streamTagSize,
+	}, nil
+}
+
+// segmentCount returns the number of segments in a ciphertext; the header shares the first segment
+func (l streamLayout) segmentCount(ciphertextSize int64) int64 {
+	return (ciphertextSize + l.ciphertextSegmentSize - 1) / l.ciphertextSegmentSize
+}
+
+// validSize reports whether a ciphertext of this size can be a stream: it holds the header and a tag for its
+// first segment, and its last segment holds at least a tag
+func (l streamLayout) validSize(ciphertextSize int64) bool {
+	if ciphertextSize < l.headerSize+streamTagSize {
+		return false
+	}
+	last := ciphertextSize % l.ciphertextSegmentSize
+	return ciphertextSize <= l.ciphertextSegmentSize || last == 0 || last >= streamTagSize
+}
+
+func (l streamLayout) plaintextSize(ciphertextSize int64) int64 {
+	return ciphertextSize - l.headerSize - l.segmentCount(ciphertextSize)*streamTagSize
+}
+
+// segmentOf returns the segment holding a plaintext offset
+func (l streamLayout) segmentOf(plaintextOffset int64) int64 {
+	if plaintextOffset < l.firstPlaintextSegmentSize {
+		return 0
+	}
+	return 1 + (plaintextOffset-l.firstPlaintextSegmentSize)/l.plaintextSegmentSize
+}
+
+// segmentPlaintextStart returns the plaintext offset at which a segment starts
+func (l streamLayout) segmentPlaintextStart(segment int64) int64 {
+	if segment == 0 {
+		return 0
+	}
+	return l.firstPlaintextSegmentSize + (segment-1)*l.plaintextSegmentSize
+}
+
+// segmentBounds returns the ciphertext byte range [start, end) of a segment
+func (l streamLayout) segmentBounds(segment, ciphertextSize int64) (int64, int64) {
+	start := segment * l.ciphertextSegmentSize
+	if segment == 0 {
+		start = l.headerSize
+	}
+	return start, min((segment+1)*l.ciphertextSegmentSize, ciphertextSize)
+}
+
+// rangeDecryptingReader decrypts consecutive segments of a ranged ciphertext fetch and trims them to the range.
+// It follows the stream layout described with the wire format constants above: the segment index and the last
+// flag are part of each segment's nonce, so a segment moved, dropped or cut short fails authentication.
+type rangeDecryptingReader struct {
+	cipher         cipher.AEAD
+	layout         streamLayout
+	noncePrefix    []byte
+	source         io.ReadCloser
+	ciphertextSize int64
+	segment        int64
+	segmentCount   int64
+	lastSegment    int64
+	skip           int64
+	remaining      int64
+	pending        []byte
+	buf            []byte
+}
+
+func (r *rangeDecryptingReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if len(r.pending) == 0 {
+		if r.segment >= r.segmentCount {
+			return 0, io.ErrUnexpectedEOF
+		}
+		start, end := r.layout.segmentBounds(r.segment, r.ciphertextSize)
+		if cap(r.buf) < int(end-start) {
+			r.buf = make([]byte, r.layout.ciphertextSegmentSize)
+		}
+		segment := r.buf[:end-start]
+		if _, err := io.ReadFull(r.source, segment); err != nil {
+			return 0, fmt.Errorf("failed to read segment %d: %w", r.segment, err)
+		}
+
+		nonce := make([]byte, streamNonceSize)
+		copy(nonce, r.noncePrefix)
+		binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], uint32(r.segment))
+		if r.segment == r.lastSegment {
+			nonce[streamNonceSize-1] = 1
+		}
+
+		plaintext, err := r.cipher.Open(segment[:0], nonce, segment, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to authenticate segment %d: %w", r.segment, err)
+		}
+		if r.skip > int64(len(plaintext)) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.pending = plaintext[r.skip:]
+		r.skip = 0
+		r.segment++
+	}
+
+	n := copy(p, r.pending[:min(int64(len(r.pending)), r.remaining)])
+	r.pending = r.pending[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func (r *rangeDecryptingReader) Close() error {
+	return r.source.Close()
+}
+
+// NewHash returns a running hash for the given method so callers can hash data while it streams
+func (c *CryptographicService) NewHash(hashMethod string) (hash.Hash, error) {
+	switch hashMethod {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	default:
+		slog.Error("Unsupported hash algorithm", slog.Any("algorithm", hashMethod))
+		return nil, errors.New("unsupported hash algorithm")
+	}
+}
+
 // HashFile generates a hash of the file content
 func (c *CryptographicService) HashFile(hashMethod string, file []byte) (string, error) {
 	slog.Info("Hashing file content")
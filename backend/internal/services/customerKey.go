@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// customerKeySize is the length of a customer-supplied AES-256 key
+const customerKeySize = 32
+
+// customerKeyset converts a customer-supplied raw key into a Tink keyset.
+// Since the keyset ID is fixed, the same raw key always yields a keyset that decrypts its earlier ciphertexts.
+func (c *FileService) customerKeyset(customerKey []byte) (string, error) {
+	if len(customerKey) != customerKeySize {
+		return "", model.ErrInvalidCustomerKey
+	}
+	return c.cryptoService.ImportRawKeyAsBase64(customerKey)
+}
+
+// customerKeyFingerprint identifies a customer key without revealing it. It is keyed by the key itself and
+// salted with the file ID, so the same key used for two files cannot be linked through the stored fingerprints.
+func customerKeyFingerprint(customerKey []byte, fileID string) string {
+	mac := hmac.New(sha256.New, customerKey)
+	mac.Write([]byte("crypsis customer key/" + fileID))
+	return "hmac-sha256:" + base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resolveFileKey returns the Tink keyset for a stored file. Files sealed with a customer key only decrypt with
+// the key whose fingerprint was recorded, and a customer key sent for a server-keyed file is refused.
+func (c *FileService) resolveFileKey(ctx context.Context, fileMetaData *entity.Metadata, customerKey []byte) (string, error) {
+	if fileMetaData.KeyFingerprint == "" {
+		if customerKey != nil {
+			return "", model.ErrCustomerKeyMismatch
+		}
+		return c.unwrapFileKey(ctx, fileMetaData)
+	}
+
+	if customerKey == nil {
+		return "", model.ErrCustomerKeyRequired
+	}
+	if len(customerKey) != customerKeySize {
+		return "", model.ErrInvalidCustomerKey
+	}
+	if !hmac.Equal([]byte(customerKeyFingerprint(customerKey, fileMetaData.FileID)), []byte(fileMetaData.KeyFingerprint)) {
+		return "", model.ErrCustomerKeyMismatch
+	}
+	return c.customerKeyset(customerKey)
+}
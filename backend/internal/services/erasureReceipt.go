@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Audit entries a receipt can be checked against
+const (
+	receiptAuditMatches  = "matches"
+	receiptAuditMismatch = "mismatch"
+	receiptAuditMissing  = "missing"
+)
+
+// erasureReceiptSignature returns the base64 HMAC-SHA256 over every field of a receipt but its signature
+func erasureReceiptSignature(key []byte, receipt model.ErasureReceipt) string {
+	receipt.Signature = ""
+	receipt.ErasedAt = receipt.ErasedAt.UTC().Truncate(time.Microsecond)
+	content, _ := json.Marshal(receipt)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyErasureReceipt checks that a receipt was signed by this deployment and is unchanged since, then that the
+// audit entry it names still records the same erasure. An entry cut by log retention does not void the receipt.
+func (s *AuditService) VerifyErasureReceipt(ctx context.Context, receipt model.ErasureReceipt) (*model.ErasureReceiptVerifyResponse, error) {
+	if s.receiptKey == nil {
+		return nil, model.ErrAuditSigningKeyMissing
+	}
+	if receipt.ReceiptID == "" || receipt.FileID == "" || receipt.Signature == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	result := &model.ErasureReceiptVerifyResponse{}
+	expected := erasureReceiptSignature(s.receiptKey, receipt)
+	if receipt.KeyID != s.receiptKeyID {
+		result.Reason = fmt.Sprintf("receipt was signed with key %s, not the current key %s", receipt.KeyID, s.receiptKeyID)
+		return result, nil
+	}
+	if !hmac.Equal([]byte(expected), []byte(receipt.Signature)) {
+		result.Reason = "receipt signature does not match its content"
+		return result, nil
+	}
+	result.SignatureVerified = true
+
+	log, err := s.fileLogsRepository.GetByID(ctx, receipt.LogID)
+	if errors.Is(err, model.ErrLogNotFound) {
+		result.Valid = true
+		result.AuditEntry = receiptAuditMissing
+		result.Reason = "the audit entry is no longer kept, it may have been cut by log retention"
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.AuditEntry = receiptAuditMismatch
+	switch {
+	case log.Hash != receipt.LogHash:
+		result.Reason = "audit entry hash differs from the receipt"
+	case log.ComputeHash() != log.Hash:
+		result.Reason = "audit entry content was altered"
+	case log.ResourceID != receipt.FileID || log.Metadata["receipt_id"] != receipt.ReceiptID:
+		result.Reason = "audit entry records a different erasure"
+	default:
+		result.Valid = true
+		result.AuditEntry = receiptAuditMatches
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// externalKeyUIDPrefix marks key UIDs naming a key held by an app's external key manager.
+// The file key itself is stored in EncKey, wrapped by that key manager.
+const externalKeyUIDPrefix = "external:"
+
+// isExternalKeyUID reports whether a file key is wrapped by an app's external key manager
+func isExternalKeyUID(keyUID string) bool {
+	return strings.HasPrefix(keyUID, externalKeyUIDPrefix)
+}
+
+// externalKMSClient is a cached key manager client together with the app settings it was built from
+type externalKMSClient struct {
+	kms       KMSInterface
+	updatedAt time.Time
+}
+
+// appExternalKMS returns the external key manager client of an app, reusing it until the app's settings change
+func (c *FileService) appExternalKMS(app *entity.Apps) (KMSInterface, error) {
+	if app.KeyEndpoint == "" {
+		return nil, fmt.Errorf("%w: app has no key endpoint", model.ErrExternalKeyUnavailable)
+	}
+	if c.externalKMSFactory == nil {
+		return nil, fmt.Errorf("%w: external key managers are not configured", model.ErrExternalKeyUnavailable)
+	}
+
+	c.externalKMSMu.Lock()
+	defer c.externalKMSMu.Unlock()
+
+	if cached, ok := c.externalKMSClients[app.ID]; ok && cached.updatedAt.Equal(app.UpdatedAt) {
+		return cached.kms, nil
+	}
+	kms, err := c.externalKMSFactory(app)
+	if err != nil {
+		slog.Error("Failed to create external key manager client", slog.String("app_id", app.ID), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", model.ErrExternalKeyUnavailable, err)
+	}
+	c.externalKMSClients[app.ID] = externalKMSClient{kms: kms, updatedAt: app.UpdatedAt}
+	return kms, nil
+}
+
+// generateExternalKey creates a file key for an app holding its own keys and has the app's key manager wrap it
+func (c *FileService) generateExternalKey(ctx context.Context, app *entity.Apps) (key, keyUID, wrappedKey string, err error) {
+	kms, err := c.appExternalKMS(app)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key, err = c.cryptoService.GenerateKey()
+	if err != nil {
+		return "", "", "", model.ErrKeyGenerationFailed
+	}
+	wrappedKey, _, _, err = kms.Encrypt(ctx, app.KeyID, key)
+	if err != nil {
+		secureKeyString(key)()
+		return "", "", "", err
+	}
+	return key, externalKeyUIDPrefix + app.KeyID, wrappedKey, nil
+}
+
+// unwrapExternalKey asks the external key manager of the file's app to unwrap its file key
+func (c *FileService) unwrapExternalKey(ctx context.Context, fileMetaData *entity.Metadata) (string, error) {
+	app, err := c.applicationRepository.GetByID(ctx, fileMetaData.File.AppID)
+	if err != nil {
+		return "", err
+	}
+	kms, err := c.appExternalKMS(app)
+	if err != nil {
+		return "", err
+	}
+	return kms.Decrypt(ctx, strings.TrimPrefix(fileMetaData.KeyUID, externalKeyUIDPrefix), fileMetaData.EncKey, "", "")
+}
+
+// usesExternalKeys reports whether new file keys of an app are wrapped by its external key manager
+func usesExternalKeys(app *entity.Apps) bool {
+	return app.KeyProvider == constant.KeyProviderExternal
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrKMSOperationNotSupported is returned by key managers that only wrap and unwrap keys
+var ErrKMSOperationNotSupported = errors.New("operation not supported by this key manager")
+
+const externalKMSTimeout = 10 * time.Second
+
+// ExternalKmsService wraps and unwraps file keys with a tenant-run key manager (hold your own key).
+// The key material never leaves the tenant: Crypsis sends a data key to be wrapped when a file is sealed and
+// asks for it back on every access, so the tenant can cut off access to all of its files by refusing requests.
+//
+// The key manager exposes two JSON endpoints, called over mutual TLS:
+//
+//	POST {endpoint}/wrap    {"key_id": "...", "plaintext": "<data key>"}   -> {"ciphertext": "<wrapped key>"}
+//	POST {endpoint}/unwrap  {"key_id": "...", "ciphertext": "<wrapped key>"} -> {"plaintext": "<data key>"}
+type ExternalKmsService struct {
+	secureClient *http.Client
+	endpoint     string
+}
+
+// NewExternalKmsService creates a KMSInterface backed by the key manager at endpoint.
+// Only Encrypt and Decrypt are supported; the other operations return ErrKMSOperationNotSupported.
+func NewExternalKmsService(secureClient *http.Client, endpoint string) KMSInterface {
+	return &ExternalKmsService{
+		secureClient: secureClient,
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+	}
+}
+
+// ExternalKMSFactory builds the key manager client of an app that holds its own keys.
+type ExternalKMSFactory func(app *entity.Apps) (KMSInterface, error)
+
+// NewExternalKMSFactory returns a factory connecting to each app's key endpoint over mTLS,
+// authenticating with the given client certificate and trusting the app's CA certificate.
+func NewExternalKMSFactory(certFile, keyFile string) ExternalKMSFactory {
+	return func(app *entity.Apps) (KMSInterface, error) {
+		client, err := helper.CreateMTLSClient(certFile, keyFile, []byte(app.KeyCACert), externalKMSTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create external key manager client: %w", err)
+		}
+		return NewExternalKmsService(client, app.KeyEndpoint), nil
+	}
+}
+
+type externalWrapRequest struct {
+	KeyID      string `json:"key_id"`
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+type externalWrapResponse struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+// Encrypt asks the key manager to wrap text with keyUID. Only the wrapped text is returned;
+// the IV and tag results are empty since the key manager embeds them in its ciphertext.
+func (s *ExternalKmsService) Encrypt(ctx context.Context, keyUID string, text string) (string, string, string, error) {
+	if keyUID == "" || text == "" {
+		return "", "", "", fmt.Errorf("%w: key ID and text are required", ErrInvalidInput)
+	}
+
+	var resp externalWrapResponse
+	if err := s.post(ctx, "ExternalWrap", "/wrap", externalWrapRequest{KeyID: keyUID, Plaintext: text}, &resp); err != nil {
+		return "", "", "", err
+	}
+	if resp.Ciphertext == "" {
+		return "", "", "", fmt.Errorf("%w: empty ciphertext from external key manager", ErrKMSResponse)
+	}
+	return resp.Ciphertext, "", "", nil
+}
+
+// Decrypt asks the key manager to unwrap encryptedData with keyUID. The IV and tag are not used.
+func (s *ExternalKmsService) Decrypt(ctx context.Context, keyUID, encryptedData, ivCounterNonce, authTag string) (string, error) {
+	if keyUID == "" || encryptedData == "" {
+		return "", fmt.Errorf("%w: key ID and ciphertext are required", ErrInvalidInput)
+	}
+
+	var resp externalWrapResponse
+	if err := s.post(ctx, "ExternalUnwrap", "/unwrap", externalWrapRequest{KeyID: keyUID, Ciphertext: encryptedData}, &resp); err != nil {
+		return "", err
+	}
+	if resp.Plaintext == "" {
+		return "", fmt.Errorf("%w: empty plaintext from external key manager", ErrKMSResponse)
+	}
+	return resp.Plaintext, nil
+}
+
+func (s *ExternalKmsService) GenerateSymetricKey(ctx context.Context, name string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) GenerateKeyPair(ctx context.Context, name string) (string, string, error) {
+	return "", "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) ExportKey(ctx context.Context, keyUID string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) LocateKey(ctx context.Context, name string) ([]string, error) {
+	return nil, ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) DestroyKey(ctx context.Context, keyUID string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) RevokeKey(ctx context.Context, keyUID string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) ReKey(ctx context.Context, keyUID string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+func (s *ExternalKmsService) Covercrypt(ctx context.Context, keyUID string, text string) (string, error) {
+	return "", ErrKMSOperationNotSupported
+}
+
+// post sends a JSON request to the key manager and decodes its JSON response.
+// Any failure, including the key manager refusing the request, is reported as ErrExternalKeyUnavailable.
+func (s *ExternalKmsService) post(ctx context.Context, operation, path string, request externalWrapRequest, response *externalWrapResponse) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartKMSSpan(ctx, operation, request.KeyID)
+	defer span.End()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("failed to encode external key manager request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("%w: failed to create request: %w", model.ErrExternalKeyUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.secureClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reach external key manager", slog.String("endpoint", s.endpoint), slog.Any("error", err))
+		helper.RecordError(span, err)
+		return fmt.Errorf("%w: %w", model.ErrExternalKeyUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("%w: failed to read response: %w", model.ErrExternalKeyUnavailable, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "External key manager refused the request", slog.String("endpoint", s.endpoint), slog.Int("status_code", resp.StatusCode))
+		err := fmt.Errorf("%w: status=%d", model.ErrExternalKeyUnavailable, resp.StatusCode)
+		helper.RecordError(span, err)
+		return err
+	}
+
+	if err := json.Unmarshal(respBody, response); err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("%w: failed to parse JSON response: %v", ErrKMSResponse, err)
+	}
+	helper.RecordSuccess(span, "External key manager request succeeded")
+	return nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+)
+
+// GetFileHistory returns who accessed a file of the calling app and when, newest first unless order is "asc".
+// Deleted files keep their history, so it stays available until the file is purged.
+func (c *FileService) GetFileHistory(ctx context.Context, clientID, fileUID string, filter model.LogFilter, limit, offset int, order string) (int64, *[]model.FileLogResponse, string, error) {
+	if clientID == "" || fileUID == "" {
+		return 0, nil, "", model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return 0, nil, "", err
+	}
+
+	// Only the owning app may read the history; other apps see the file as missing
+	if _, err := c.fileRepository.GetDeletedMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID); err != nil {
+		return 0, nil, "", err
+	}
+
+	filter.AppID = ""
+	filter.FileID = fileUID
+	if err := validateLogFilter(filter); err != nil {
+		return 0, nil, "", err
+	}
+
+	_, order = helper.ValidateSortParams("timestamp", order, helper.AllowedLogSortFields)
+	count, logs, nextCursor, err := c.fileLogsRepository.List(ctx, filter, offset, limit, "timestamp", order)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	return count, fileLogResponses(*logs), nextCursor, nil
+}
+
+// validateLogFilter checks the actor type, action, resource type, outcome and time window of a log filter
+func validateLogFilter(filter model.LogFilter) error {
+	switch filter.ActorType {
+	case "", constant.ActorTypeUser, constant.ActorTypeClient, constant.ActorTypeSystem, constant.ActorTypeAdmin:
+	default:
+		return model.ErrInvalidFilter
+	}
+	switch constant.ActionType(filter.Action) {
+	case "", constant.ActionTypeUpload, constant.ActionTypeDownload, constant.ActionTypeEncrypt, constant.ActionTypeDecrypt,
+		constant.ActionTypeDelete, constant.ActionTypeRecover, constant.ActionTypeReKey, constant.ActionTypeUpdate,
+		constant.ActionTypeCreate, constant.ActionTypeLogin, constant.ActionTypeLogout, constant.ActionTypeRotateSecret,
+		constant.ActionTypePasswordChange, constant.ActionTypeLegalHold, constant.ActionTypeLegalHoldRelease,
+		constant.ActionTypeRetention, constant.ActionTypeMigrate:
+	default:
+		return model.ErrInvalidFilter
+	}
+	switch filter.ResourceType {
+	case "", constant.ResourceTypeFile, constant.ResourceTypeApp, constant.ResourceTypeAdmin, constant.ResourceTypeKey:
+	default:
+		return model.ErrInvalidFilter
+	}
+	switch filter.Outcome {
+	case "", constant.OutcomeSuccess, constant.OutcomeFailure:
+	default:
+		return model.ErrInvalidFilter
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return model.ErrInvalidFilter
+	}
+	return nil
+}
+
+func fileLogResponses(logs []entity.FileLogs) *[]model.FileLogResponse {
+	responses := make([]model.FileLogResponse, 0, len(logs))
+	for _, fileLog := range logs {
+		responses = append(responses, model.FileLogResponse{
+			ID:           fileLog.FileID,
+			ActorID:      fileLog.ActorID,
+			ActorType:    fileLog.ActorType,
+			ResourceType: fileLog.ResourceType,
+			ResourceID:   fileLog.ResourceID,
+			Action:       fileLog.Action,
+			Outcome:      fileLog.Outcome,
+			TraceID:      fileLog.TraceID,
+			Timestamp:    fileLog.Timestamp,
+			IP:           fileLog.IP,
+			UserAgent:    fileLog.UserAgent,
+			Metadata:     fileLog.Metadata,
+		})
+	}
+	return &responses
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// checkFileMutable returns an error when a file is on legal hold or under retention, which keeps it from being
+// deleted, overwritten or purged by anyone, the lifecycle scheduler included
+func checkFileMutable(file *entity.Files) error {
+	if file.LegalHold {
+		return model.ErrFileOnLegalHold
+	}
+	if file.RetainUntil != nil && time.Now().Before(*file.RetainUntil) {
+		return fmt.Errorf("%w until %s", model.ErrFileUnderRetention, file.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// PlaceLegalHold keeps a file, soft deleted or not, from being deleted or overwritten until the hold is released.
+// Where the bucket has object lock enabled, every stored version is put on legal hold in storage as well.
+func (c *FileService) PlaceLegalHold(ctx context.Context, fileUID, reason string) (_ *model.FileHoldResponse, err error) {
+	if fileUID == "" || reason == "" {
+		return nil, model.ErrInvalidInput
+	}
+	defer func() {
+		_ = c.auditHold(ctx, fileUID, constant.ActionTypeLegalHold, err, map[string]interface{}{"reason": reason})
+	}()
+
+	file, err := c.fileRepository.GetHoldableByID(ctx, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := c.lockInStorage(file, func(bucketName, fileName string) error {
+		return c.storageService.SetFileLegalHold(ctx, bucketName, fileName, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fileRepository.SetLegalHold(ctx, file.ID, true); err != nil {
+		return nil, err
+	}
+	slog.Info("Legal hold placed", slog.String("file_id", file.ID), slog.Bool("storage_locked", locked))
+
+	return &model.FileHoldResponse{FileID: file.ID, LegalHold: true, RetainUntil: file.RetainUntil, StorageLocked: locked}, nil
+}
+
+// ReleaseLegalHold releases the legal hold on a file. A retention date still set keeps the file in place until it passes.
+func (c *FileService) ReleaseLegalHold(ctx context.Context, fileUID, reason string) (_ *model.FileHoldResponse, err error) {
+	if fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+	defer func() {
+		_ = c.auditHold(ctx, fileUID, constant.ActionTypeLegalHoldRelease, err, map[string]interface{}{"reason": reason})
+	}()
+
+	file, err := c.fileRepository.GetHoldableByID(ctx, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	// Storage is released first, so a failure leaves the file held in both places
+	locked, err := c.lockInStorage(file, func(bucketName, fileName string) error {
+		return c.storageService.SetFileLegalHold(ctx, bucketName, fileName, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fileRepository.SetLegalHold(ctx, file.ID, false); err != nil {
+		return nil, err
+	}
+	slog.Info("Legal hold released", slog.String("file_id", file.ID), slog.Bool("storage_locked", locked))
+
+	return &model.FileHoldResponse{FileID: file.ID, LegalHold: false, RetainUntil: file.RetainUntil, StorageLocked: locked}, nil
+}
+
+// SetFileRetention keeps a file, soft deleted or not, from being deleted or overwritten before retainUntil.
+// Retention can only be extended. Where the bucket has object lock enabled, every stored version is locked in
+// COMPLIANCE mode as well, which storage will not let anyone shorten either.
+func (c *FileService) SetFileRetention(ctx context.Context, fileUID string, retainUntil time.Time) (_ *model.FileHoldResponse, err error) {
+	if fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+	retainUntil = retainUntil.UTC()
+	defer func() {
+		_ = c.auditHold(ctx, fileUID, constant.ActionTypeRetention, err, map[string]interface{}{
+			"retain_until": retainUntil.Format(time.RFC3339),
+		})
+	}()
+	if !retainUntil.After(time.Now()) {
+		return nil, model.ErrInvalidRetention
+	}
+
+	file, err := c.fileRepository.GetHoldableByID(ctx, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	if file.RetainUntil != nil && retainUntil.Before(*file.RetainUntil) {
+		return nil, fmt.Errorf("%w: the file is retained until %s", model.ErrRetentionShortened, file.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	locked, err := c.lockInStorage(file, func(bucketName, fileName string) error {
+		return c.storageService.SetFileRetention(ctx, bucketName, fileName, retainUntil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fileRepository.SetRetention(ctx, file.ID, retainUntil); err != nil {
+		return nil, err
+	}
+	slog.Info("File retention set", slog.String("file_id", file.ID), slog.Time("retain_until", retainUntil), slog.Bool("storage_locked", locked))
+
+	return &model.FileHoldResponse{FileID: file.ID, LegalHold: file.LegalHold, RetainUntil: &retainUntil, StorageLocked: locked}, nil
+}
+
+// lockInStorage applies a hold to the stored object of a file and reports whether storage enforces it.
+// A bucket without object lock is not an error: the hold is still enforced by the service.
+func (c *FileService) lockInStorage(file *entity.Files, apply func(bucketName, fileName string) error) (bool, error) {
+	bucketName := c.fileBucket(file)
+	err := apply(bucketName, createFileName(file.ID))
+	if errors.Is(err, model.ErrObjectLockUnavailable) {
+		slog.Warn("Bucket has no object lock, the hold is enforced by the service only",
+			slog.String("bucket", bucketName), slog.String("file_id", file.ID))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// auditHold records a change to the holds on a file by the admin calling the API
+func (c *FileService) auditHold(ctx context.Context, fileUID string, action constant.ActionType, err error, metadata map[string]interface{}) error {
+	return recordAudit(ctx, c.fileLogsRepository, auditEntry{
+		ResourceType: constant.ResourceTypeFile,
+		ResourceID:   fileUID,
+		Action:       action,
+		Err:          err,
+		Metadata:     metadata,
+	})
+}
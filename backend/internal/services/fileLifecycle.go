@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"log/slog"
+	"time"
+)
+
+// lifecycleActorID names the lifecycle scheduler as the actor of the entries it records
+const lifecycleActorID = "lifecycle"
+
+// SetFileExpiry sets when a file of the calling app expires, or clears its expiry when expiresAt is nil.
+// An expired file is soft deleted by the lifecycle scheduler; its own expiry takes precedence over the app's file TTL.
+func (c *FileService) SetFileExpiry(ctx context.Context, clientID, fileUID string, expiresAt *time.Time) (_ *model.FileExpiryResponse, err error) {
+	if fileUID == "" || clientID == "" {
+		return nil, model.ErrInvalidInput
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, model.ErrInvalidExpiry
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+	defer func() {
+		var expiry interface{}
+		if expiresAt != nil {
+			expiry = expiresAt.UTC().Format(time.RFC3339)
+		}
+		_ = recordAudit(ctx, c.fileLogsRepository, auditEntry{
+			ActorID:      validatedAppID,
+			ActorType:    constant.ActorTypeClient,
+			ResourceType: constant.ResourceTypeFile,
+			ResourceID:   fileUID,
+			Action:       constant.ActionTypeUpdate,
+			Err:          err,
+			Metadata:     map[string]interface{}{"expires_at": expiry},
+		})
+	}()
+
+	if _, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID); err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+	if err := c.fileRepository.SetExpiry(ctx, fileUID, expiresAt); err != nil {
+		return nil, err
+	}
+	return &model.FileExpiryResponse{FileID: fileUID, ExpiresAt: expiresAt}, nil
+}
+
+// ExpireFile soft deletes a file for the lifecycle scheduler, recording the rule that expired it
+func (c *FileService) ExpireFile(ctx context.Context, appID, fileUID, rule string) (err error) {
+	defer func() {
+		_ = recordAudit(ctx, c.fileLogsRepository, auditEntry{
+			ActorID:      lifecycleActorID,
+			ActorType:    constant.ActorTypeSystem,
+			ResourceType: constant.ResourceTypeFile,
+			ResourceID:   fileUID,
+			Action:       constant.ActionTypeDelete,
+			Err:          err,
+			Metadata:     map[string]interface{}{"mode": constant.DeleteModeSoft, "rule": rule},
+		})
+	}()
+
+	if _, err := c.removeFile(ctx, appID, fileUID); err != nil {
+		return err
+	}
+	slog.Info("File expired", slog.String("file_id", fileUID), slog.String("app_id", appID), slog.String("rule", rule))
+	return nil
+}
+
+// PurgeExpiredFile crypto-shreds a file for the lifecycle scheduler, recording the rule that purged it
+func (c *FileService) PurgeExpiredFile(ctx context.Context, appID, fileUID, rule string) (*model.ErasureReceipt, error) {
+	return c.purgeFile(ctx, appID, fileUID, auditEntry{
+		ActorID:   lifecycleActorID,
+		ActorType: constant.ActorTypeSystem,
+		Metadata:  map[string]interface{}{"rule": rule},
+	})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// PurgeFile erases a file of the calling app for good by crypto-shredding it, whether it was soft deleted or not.
+// The keys of every version are destroyed in the key manager and wiped from the database first, so the stored
+// ciphertexts are unreadable even when a later step fails; then every stored version is removed and the file's
+// records are deleted. The signed receipt names the audit log entry recording the erasure.
+func (c *FileService) PurgeFile(ctx context.Context, clientID, fileUID string) (_ *model.ErasureReceipt, err error) {
+	if fileUID == "" || clientID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	return c.purgeFile(ctx, validatedAppID, fileUID, auditEntry{ActorID: validatedAppID, ActorType: constant.ActorTypeClient})
+}
+
+// purgeFile erases a file of a validated app on behalf of actor, whose metadata is added to the audit entries
+func (c *FileService) purgeFile(ctx context.Context, validatedAppID, fileUID string, actor auditEntry) (_ *model.ErasureReceipt, err error) {
+	fileMetaData, err := c.fileRepository.GetDeletedMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	fileID := fileMetaData.FileID
+
+	receipt := &model.ErasureReceipt{
+		ReceiptID:     helper.GenerateCustomUUID().String(),
+		FileID:        fileID,
+		AppID:         validatedAppID,
+		KeysDestroyed: []string{},
+	}
+	defer func() {
+		if err != nil {
+			_ = recordAudit(ctx, c.fileLogsRepository, purgeAuditEntry(actor, receipt, err))
+		}
+	}()
+	if err := checkFileMutable(&fileMetaData.File); err != nil {
+		return nil, err
+	}
+
+	versions, err := c.fileVersionRepository.GetByFileID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 1: shred the keys. Keys whose wrapped form is already wiped were destroyed by an earlier attempt.
+	if c.keyConfig.KMSEnable {
+		for _, keyUID := range managedKeyUIDs(fileMetaData, versions) {
+			if err := c.destroyFileKey(ctx, keyUID); err != nil {
+				return nil, err
+			}
+			receipt.KeysDestroyed = append(receipt.KeysDestroyed, keyUID)
+		}
+	}
+	if receipt.WrappedKeysWiped, err = c.fileRepository.WipeKeys(ctx, fileID); err != nil {
+		return nil, err
+	}
+
+	// Step 2: remove every stored version, including ones storage no longer lists as current
+	bucketName, objectName := c.fileBucket(&fileMetaData.File), createFileName(fileID)
+	versionIDs, err := c.storageService.ListFileVersion(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, versionID := range uniqueVersionIDs(versionIDs, fileMetaData, versions) {
+		if err := c.storageService.DeleteFileVersion(ctx, bucketName, objectName, versionID); err != nil {
+			return nil, err
+		}
+		receipt.VersionsRemoved++
+	}
+	remaining, err := c.storageService.ListFileVersion(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("%w: %d left", model.ErrFileErasureIncomplete, len(remaining))
+	}
+
+	// Step 3: delete the records
+	if err := c.fileRepository.Purge(ctx, fileID); err != nil {
+		return nil, err
+	}
+
+	log := newAuditLog(ctx, purgeAuditEntry(actor, receipt, nil))
+	if err := c.fileLogsRepository.Create(context.Background(), log); err != nil {
+		// The file is gone either way; the receipt is still signed but names no audit entry
+		slog.Error("Failed to record file purge", slog.String("file_id", fileID), slog.Any("error", err))
+	}
+	receipt.ErasedAt = log.Timestamp
+	receipt.LogID = log.ID
+	receipt.LogHash = log.Hash
+	if c.receiptKey != nil {
+		receipt.KeyID = c.receiptKeyID
+		receipt.Signature = erasureReceiptSignature(c.receiptKey, *receipt)
+	}
+
+	slog.Info("File purged", slog.String("file_id", fileID), slog.String("receipt_id", receipt.ReceiptID),
+		slog.Int("keys_destroyed", len(receipt.KeysDestroyed)), slog.Int("versions_removed", receipt.VersionsRemoved))
+	return receipt, nil
+}
+
+// purgeAuditEntry records the erasure a receipt describes by actor, or the failure to complete it when err is set
+func purgeAuditEntry(actor auditEntry, receipt *model.ErasureReceipt, err error) auditEntry {
+	metadata := map[string]interface{}{
+		"mode":               constant.DeleteModePurge,
+		"receipt_id":         receipt.ReceiptID,
+		"keys_destroyed":     len(receipt.KeysDestroyed),
+		"wrapped_keys_wiped": receipt.WrappedKeysWiped,
+		"versions_removed":   receipt.VersionsRemoved,
+	}
+	for key, value := range actor.Metadata {
+		metadata[key] = value
+	}
+	return auditEntry{
+		ActorID:      actor.ActorID,
+		ActorType:    actor.ActorType,
+		ResourceType: constant.ResourceTypeFile,
+		ResourceID:   receipt.FileID,
+		Action:       constant.ActionTypeDelete,
+		Err:          err,
+		Metadata:     metadata,
+	}
+}
+
+// destroyFileKey revokes a file key and destroys it in the key manager, which only destroys revoked keys
+func (c *FileService) destroyFileKey(ctx context.Context, keyUID string) error {
+	if _, err := c.kmsService.RevokeKey(ctx, keyUID); err != nil {
+		// A key left revoked by an earlier attempt cannot be revoked again, so destroying it decides
+		slog.Warn("Failed to revoke file key", slog.String("key_uid", keyUID), slog.Any("error", err))
+	}
+	if _, err := c.kmsService.DestroyKey(ctx, keyUID); err != nil {
+		return fmt.Errorf("%w: %w", model.ErrKeyDeletionFailed, err)
+	}
+	return nil
+}
+
+// managedKeyUIDs lists the key manager keys of a file and its versions that still have a wrapped key.
+// Keys held by an app's own key manager are not ours to destroy; wiping their wrapped form shreds the file for us.
+func managedKeyUIDs(fileMetaData *entity.Metadata, versions []entity.FileVersions) []string {
+	seen := map[string]bool{}
+	add := func(keyUID, encKey string) {
+		if keyUID != "" && encKey != "" && !isExternalKeyUID(keyUID) {
+			seen[keyUID] = true
+		}
+	}
+	add(fileMetaData.KeyUID, fileMetaData.EncKey)
+	for _, version := range versions {
+		add(version.KeyUID, version.EncKey)
+	}
+
+	keyUIDs := make([]string, 0, len(seen))
+	for keyUID := range seen {
+		keyUIDs = append(keyUIDs, keyUID)
+	}
+	sort.Strings(keyUIDs)
+	return keyUIDs
+}
+
+// uniqueVersionIDs merges the versions storage lists with the versions the database recorded
+func uniqueVersionIDs(listed []string, fileMetaData *entity.Metadata, versions []entity.FileVersions) []string {
+	seen := map[string]bool{}
+	var versionIDs []string
+	add := func(versionID string) {
+		if versionID != "" && !seen[versionID] {
+			seen[versionID] = true
+			versionIDs = append(versionIDs, versionID)
+		}
+	}
+	for _, versionID := range listed {
+		add(versionID)
+	}
+	add(fileMetaData.VersionID)
+	for _, version := range versions {
+		add(version.VersionID)
+	}
+	return versionIDs
+}
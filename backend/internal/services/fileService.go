@@ -1,65 +1,149 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/model"
 	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log/slog"
 	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/awnumar/memguard"
 	"gorm.io/gorm"
 )
 
 type FileService struct {
-	cryptoService         CryptographicInterface
-	storageService        StorageInterface
-	kmsService            KMSInterface
-	fileRepository        repository.FileRepository
-	fileLogsRepository    repository.FileLogsRepository
-	applicationRepository repository.ApplicationRepository
-	adminRepository       repository.AdminRepository
-	db                    *gorm.DB
-	keyConfig             *model.KeyConfig
-	bucketName            string
-	hashMethod            string
-	hashEncryptedFile     bool
-	encryptionMethod      string
+	cryptoService           CryptographicInterface
+	storageService          StorageInterface
+	kmsService              KMSInterface
+	fileRepository          repository.FileRepository
+	fileLogsRepository      repository.FileLogsRepository
+	applicationRepository   repository.ApplicationRepository
+	adminRepository         repository.AdminRepository
+	uploadJobRepository     repository.UploadJobRepository
+	uploadSessionRepository repository.UploadSessionRepository
+	fileVersionRepository   repository.FileVersionRepository
+	shareLinkRepository     repository.ShareLinkRepository
+	uploadTokenRepository   repository.UploadTokenRepository
+	folderRepository        repository.FolderRepository
+	db                      *gorm.DB
+	keyConfig               *model.KeyConfig
+	bucketName              string
+	hashMethod              string
+	hashEncryptedFile       bool
+	encryptionMethod        string
+	uploadStaleAfter        time.Duration
+	uploadSessionTTL        time.Duration
+	linkTokenSecret         []byte
+	shareLinkTTL            time.Duration
+	shareLinkMaxTTL         time.Duration
+	uploadTokenTTL          time.Duration
+	uploadTokenMaxTTL       time.Duration
+	publicBaseURL           string
+	externalKMSFactory      ExternalKMSFactory
+	externalKMSMu           sync.Mutex
+	externalKMSClients      map[string]externalKMSClient
+	receiptKey              []byte // signs erasure receipts
+	receiptKeyID            string
+	shareLinkIPAttempts     *attemptLimiter // failed share link uses per client address
+	shareLinkPassAttempts   *attemptLimiter // wrong passphrases per share link
 
 	saveKey bool
 }
 
 func NewFileService(params FileServiceParams) FileInterface {
+	uploadStaleAfter := params.UploadStaleAfter
+	if uploadStaleAfter <= 0 {
+		uploadStaleAfter = defaultUploadStaleAfter
+	}
+	uploadSessionTTL := params.UploadSessionTTL
+	if uploadSessionTTL <= 0 {
+		uploadSessionTTL = defaultUploadSessionTTL
+	}
+	shareLinkTTL := params.ShareLinkTTL
+	if shareLinkTTL <= 0 {
+		shareLinkTTL = defaultShareLinkTTL
+	}
+	shareLinkMaxTTL := params.ShareLinkMaxTTL
+	if shareLinkMaxTTL <= 0 {
+		shareLinkMaxTTL = defaultShareLinkMaxTTL
+	}
+	uploadTokenTTL := params.UploadTokenTTL
+	if uploadTokenTTL <= 0 {
+		uploadTokenTTL = defaultUploadTokenTTL
+	}
+	uploadTokenMaxTTL := params.UploadTokenMaxTTL
+	if uploadTokenMaxTTL <= 0 {
+		uploadTokenMaxTTL = defaultUploadTokenMaxTTL
+	}
+	receiptKey, receiptKeyID := kekDerivedKey(params.KeyConfig, erasureReceiptKeyLabel)
+
 	return &FileService{
-		cryptoService:         params.CryptoService,
-		storageService:        params.StorageService,
-		kmsService:            params.KMSService,
-		fileRepository:        params.FileRepository,
-		fileLogsRepository:    params.FileLogsRepository,
-		applicationRepository: params.ApplicationRepository,
-		adminRepository:       params.AdminRepository,
-		db:                    params.DB,
-		keyConfig:             params.KeyConfig,
-		bucketName:            params.BucketName,
-		hashMethod:            params.HashMethod,
-		hashEncryptedFile:     params.HashEncryptedFile,
-		encryptionMethod:      params.EncryptionMethod,
-		saveKey:               false,
-	}
-}
-
-func (c *FileService) ListFiles(ctx context.Context, clientID string, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, error) {
+		cryptoService:           params.CryptoService,
+		storageService:          params.StorageService,
+		kmsService:              params.KMSService,
+		fileRepository:          params.FileRepository,
+		fileLogsRepository:      params.FileLogsRepository,
+		applicationRepository:   params.ApplicationRepository,
+		adminRepository:         params.AdminRepository,
+		uploadJobRepository:     params.UploadJobRepository,
+		uploadSessionRepository: params.UploadSessionRepository,
+		fileVersionRepository:   params.FileVersionRepository,
+		shareLinkRepository:     params.ShareLinkRepository,
+		uploadTokenRepository:   params.UploadTokenRepository,
+		folderRepository:        params.FolderRepository,
+		db:                      params.DB,
+		keyConfig:               params.KeyConfig,
+		bucketName:              params.BucketName,
+		hashMethod:              params.HashMethod,
+		hashEncryptedFile:       params.HashEncryptedFile,
+		encryptionMethod:        params.EncryptionMethod,
+		uploadStaleAfter:        uploadStaleAfter,
+		uploadSessionTTL:        uploadSessionTTL,
+		linkTokenSecret:         linkTokenSigningKey(params.ShareLinkSecret, params.KeyConfig),
+		shareLinkTTL:            shareLinkTTL,
+		shareLinkMaxTTL:         shareLinkMaxTTL,
+		uploadTokenTTL:          uploadTokenTTL,
+		uploadTokenMaxTTL:       uploadTokenMaxTTL,
+		publicBaseURL:           strings.TrimSuffix(params.PublicBaseURL, "/"),
+		externalKMSFactory:      params.ExternalKMSFactory,
+		externalKMSClients:      make(map[string]externalKMSClient),
+		receiptKey:              receiptKey,
+		receiptKeyID:            receiptKeyID,
+		shareLinkIPAttempts:     newAttemptLimiter(shareLinkMaxFailuresPerIP, shareLinkAttemptWindow),
+		shareLinkPassAttempts:   newAttemptLimiter(shareLinkMaxFailuresPerLink, shareLinkAttemptWindow),
+		saveKey:                 false,
+	}
+}
+
+func (c *FileService) ListFiles(ctx context.Context, clientID string, filter model.FileFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, string, error) {
 	if clientID == "" {
-		return 0, nil, model.ErrInvalidInput
+		return 0, nil, "", model.ErrInvalidInput
 	}
 
 	validatedAppID, err := c.checkClientID(ctx, clientID)
 	if validatedAppID == "" {
-		return 0, nil, err
+		return 0, nil, "", err
+	}
+
+	// Deleted files are only listed for admins
+	filter.Deleted = constant.DeletedStateExclude
+	if filter, err = validateFileFilter(filter); err != nil {
+		return 0, nil, "", err
 	}
 
 	// Validate sort parameters to prevent SQL injection
@@ -68,9 +152,9 @@ func (c *FileService) ListFiles(ctx context.Context, clientID string, limit, off
 	fmt.Println("Validated App ID:", validatedAppID)
 	fmt.Println("Offset:", offset, "Limit:", limit, "SortBy:", sortBy, "Order:", order)
 
-	count, files, err := c.fileRepository.GetListFiles(ctx, validatedAppID, offset, limit, sortBy, order)
+	count, files, nextCursor, err := c.fileRepository.GetListFiles(ctx, validatedAppID, filter, offset, limit, sortBy, order)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, "", err
 	}
 
 	var fileResponse []model.FileResponse
@@ -80,41 +164,55 @@ func (c *FileService) ListFiles(ctx context.Context, clientID string, limit, off
 			Name:      file.Name,
 			Size:      file.Size,
 			MimeType:  file.MimeType,
+			Status:    file.Status,
+			Path:      file.Path,
 			UpdatedAt: file.UpdatedAt.String(),
 		})
 	}
 
-	return count, &fileResponse, nil
+	return count, &fileResponse, nextCursor, nil
 }
 
-func (c *FileService) ListFilesForAdmin(ctx context.Context, adminID, appID string, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, error) {
+func (c *FileService) ListFilesForAdmin(ctx context.Context, adminID, appID string, filter model.FileFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, string, error) {
 	if adminID == "" {
-		return 0, nil, model.ErrInvalidInput
+		return 0, nil, "", model.ErrInvalidInput
 	}
 
 	// Validate sort parameters to prevent SQL injection
 	sortBy, order = helper.ValidateSortParams(sortBy, order, helper.AllowedFileSortFields)
 
+	// Listing every app includes deleted files unless asked otherwise, listing one app leaves them out
+	if filter.Deleted == "" {
+		filter.Deleted = constant.DeletedStateExclude
+		if appID == "" {
+			filter.Deleted = constant.DeletedStateInclude
+		}
+	}
+	filter, err := validateFileFilter(filter)
+	if err != nil {
+		return 0, nil, "", err
+	}
+
 	var count int64
 	var files []entity.Files
-	var err error
+	var nextCursor string
 
 	if appID == "" {
 		// Admin can list all files
-		count, files, err = c.fileRepository.GetListFilesForAdmin(ctx, offset, limit, sortBy, order)
+		count, files, nextCursor, err = c.fileRepository.GetListFilesForAdmin(ctx, filter, offset, limit, sortBy, order)
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, "", err
 		}
 	} else {
 		// Admin can list files for a specific client
-		count, files, err = c.fileRepository.GetListFiles(ctx, appID, offset, limit, sortBy, order)
+		count, files, nextCursor, err = c.fileRepository.GetListFiles(ctx, appID, filter, offset, limit, sortBy, order)
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, "", err
 		}
 	}
 
 	if !c.adminRepository.IsAdmin(ctx, adminID) {
-		return 0, nil, model.AdminErrNotFound
+		return 0, nil, "", model.AdminErrNotFound
 	}
 
 	var fileResponse []model.FileResponse
@@ -125,162 +223,291 @@ func (c *FileService) ListFilesForAdmin(ctx context.Context, adminID, appID stri
 			OwnerID:   file.AppID,
 			Size:      file.Size,
 			MimeType:  file.MimeType,
+			Status:    file.Status,
 			UpdatedAt: file.UpdatedAt.String(),
 			Deleted:   file.DeletedAt.Valid,
 		})
 	}
 
-	return count, &fileResponse, nil
+	return count, &fileResponse, nextCursor, nil
+}
+
+// validateFileFilter checks the ranges and deleted state of a listing filter and normalizes its tag filters
+func validateFileFilter(filter model.FileFilter) (model.FileFilter, error) {
+	switch filter.Deleted {
+	case "", constant.DeletedStateExclude, constant.DeletedStateInclude, constant.DeletedStateOnly:
+	default:
+		return filter, model.ErrInvalidFilter
+	}
+	if (filter.MinSize != nil && *filter.MinSize < 0) ||
+		(filter.MinSize != nil && filter.MaxSize != nil && *filter.MinSize > *filter.MaxSize) {
+		return filter, model.ErrInvalidFilter
+	}
+	if (filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore)) ||
+		(filter.UpdatedAfter != nil && filter.UpdatedBefore != nil && !filter.UpdatedAfter.Before(*filter.UpdatedBefore)) {
+		return filter, model.ErrInvalidFilter
+	}
+
+	tags, err := normalizeTagFilters(filter.Tags)
+	if err != nil {
+		return filter, err
+	}
+	filter.Tags = tags
+	return filter, nil
 }
 
-func (c *FileService) UploadFile(ctx context.Context, clientID, fileName string, input multipart.File) (fileUID string, err error) {
+func (c *FileService) UploadFile(ctx context.Context, clientID, fileName string, input io.Reader, opts model.UploadOptions) (fileUID string, err error) {
 	// Check Client ID
 	validatedAppID, err := c.checkClientID(ctx, clientID)
 	if validatedAppID == "" {
 		return "", err
 	}
 
-	// Generate file UID
-	fileUID = helper.GenerateCustomUUID().String()
-
-	// Generate Key and Encrypt file
-	encryptedFile, metaDataDTO, err := c.encryptFile(ctx, "", fileUID, input)
+	fileToBeSaved, err := c.uploadFile(ctx, validatedAppID, fileName, input, opts)
 	if err != nil {
 		return "", err
 	}
 
-	// File to be saved to db
+	// Save to log
+	_ = c.saveFileLog(ctx, validatedAppID, fileToBeSaved.ID, constant.ActorTypeClient, string(constant.ActionTypeUpload), fileName)
+	return fileToBeSaved.ID, nil
+}
+
+// uploadFile encrypts and stores a new file owned by appID and commits it through the upload outbox.
+// When a customer key is set it is used instead of a server-managed key and only its fingerprint is stored;
+// when a folder is set the file is placed in it once committed.
+func (c *FileService) uploadFile(ctx context.Context, validatedAppID, fileName string, input io.Reader, opts model.UploadOptions) (*entity.Files, error) {
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the file's place in the folder tree first, so a taken path fails before anything is stored
+	var placement *filePlacement
+	if opts.Folder != "" {
+		if placement, err = c.prepareFilePlacement(ctx, validatedAppID, opts.Folder, fileName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the app's quota before anything is stored; the size of the file is enforced as it streams in
+	quota, err := c.checkQuota(ctx, validatedAppID, nil)
+	if err != nil {
+		return nil, err
+	}
+	limited := quota.limit(input)
+	config, err := c.clientConfig(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate file UID
+	fileUID := helper.GenerateCustomUUID().String()
+
+	var fileKey string
+	if opts.CustomerKey != nil {
+		if fileKey, err = c.customerKeyset(opts.CustomerKey); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record the pending file and its outbox job before anything reaches storage
 	fileToBeSaved := &entity.Files{
-		ID:       fileUID,
-		Name:     fileName,
-		AppID:    validatedAppID,
-		UserID:   "Not Available", // TO BE ADDED
-		Size:     metaDataDTO.Size,
-		MimeType: metaDataDTO.MimeType,
+		ID:         fileUID,
+		Name:       fileName,
+		AppID:      validatedAppID,
+		UserID:     "Not Available", // TO BE ADDED
+		MimeType:   "application/octet-stream",
+		BucketName: config.BucketName,
+		Status:     constant.FileStatusPending,
+	}
+	job := &entity.UploadJobs{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        fileUID,
+		AppID:         validatedAppID,
+		Operation:     constant.JobOperationUpload,
+		Status:        constant.JobStatusPending,
+		BucketName:    config.BucketName,
+		ObjectName:    createFileName(fileUID),
+		NextAttemptAt: time.Now().Add(c.uploadStaleAfter),
+	}
+	if err := c.uploadJobRepository.Create(ctx, job, fileToBeSaved); err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		if err := c.fileRepository.SetTags(ctx, fileUID, tags); err != nil {
+			c.abandonUploadJob(job, err)
+			return nil, err
+		}
 	}
 
-	metadataToBeSaved := &entity.Metadata{
-		ID:      helper.GenerateCustomUUID().String(),
-		FileID:  fileToBeSaved.ID,
-		Hash:    metaDataDTO.Hash,
-		EncHash: metaDataDTO.EncryptedFileHash,
-		KeyUID:  metaDataDTO.KeyUID,
-		EncKey:  "", // Wrapped key to be set below
-		KeyAlgo: c.encryptionMethod,
+	// Generate Key, then encrypt and upload the file as it streams in
+	slog.Info("Uploading file", slog.String("file_id", fileUID), slog.String("file_name", fileName))
+	metaDataDTO, transactionResponse, err := c.encryptFileStream(ctx, config, fileKey, "", fileUID, validatedAppID, limited, func(encrypted io.Reader) (*model.StorageTransactionResponse, error) {
+		resp, err := c.storageService.UploadFile(ctx, job.BucketName, job.ObjectName, encrypted, -1)
+		if err == nil {
+			c.recordStoredVersion(ctx, job, resp)
+		}
+		return resp, err
+	})
+	if err != nil {
+		if limited.exceeded {
+			err = limited.limitErr()
+		}
+		c.abandonUploadJob(job, err)
+		return nil, err
 	}
 
-	// Only save key if enabled and kek is available
-	if c.saveKey && c.keyConfig.KEK != "" {
+	fileToBeSaved.Size = metaDataDTO.Size
+	fileToBeSaved.MimeType = metaDataDTO.MimeType
+	fileToBeSaved.Location = transactionResponse.Location
+
+	metadataToBeSaved := &entity.Metadata{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        fileToBeSaved.ID,
+		Hash:          metaDataDTO.Hash,
+		EncHash:       metaDataDTO.EncryptedFileHash,
+		KeyUID:        metaDataDTO.KeyUID,
+		EncKey:        "", // Wrapped key to be set below
+		KeyAlgo:       config.EncryptionMethod,
+		HashAlgo:      config.HashMethod,
+		Format:        constant.CipherFormatStream,
+		HeaderVersion: CipherHeaderVersion,
+		VersionID:     transactionResponse.VersionID,
+	}
+
+	// Only save key if enabled and kek is available; customer keys are never stored
+	if opts.CustomerKey != nil {
+		metadataToBeSaved.KeyFingerprint = customerKeyFingerprint(opts.CustomerKey, fileUID)
+	} else if metaDataDTO.WrappedKey != "" {
+		metadataToBeSaved.EncKey = metaDataDTO.WrappedKey
+	} else if c.saveKey && c.keyConfig.KEK != "" {
 		wrappedKey, err := c.cryptoService.EncryptString(c.keyConfig.KEK, metaDataDTO.Key)
 		if err != nil {
 			slog.Error("Failed to wrap key", slog.Any("error", err))
-			return "", err
+			c.abandonUploadJob(job, err)
+			return nil, err
 		}
 		metadataToBeSaved.EncKey = wrappedKey
 	}
 
-	// Create multipart file
-	toBeUploadedFile, tobeUploadSize, err := helper.CreateMultipartFileFromBytes(encryptedFile, fileName)
-	if err != nil {
-		return "", err
+	// Commit to DB, leaving the job for the worker to retry if the commit fails
+	if err := c.commitUploadJob(ctx, job, fileToBeSaved, metadataToBeSaved); err != nil {
+		return nil, err
 	}
-
-	// Upload file to storage and save metadata to DB asynchronously
-	go func() {
-		uploadCtx := context.Background()
-		slog.Info("Uploading file", slog.String("file_id", fileToBeSaved.ID), slog.String("file_name", fileToBeSaved.Name))
-		transcationResponse, err := c.storageService.UploadFile(uploadCtx, c.bucketName, createFileName(fileToBeSaved.ID), toBeUploadedFile, tobeUploadSize)
-		if err != nil {
-			slog.Error("Failed to upload file to storage", slog.Any("error", err))
-			// TODO DO SOMETHING TO ROLLBACK DB ENTRY
-		}
-		// Save to DB
-		metadataToBeSaved.VersionID = transcationResponse.VersionID
-		fileToBeSaved.Location = transcationResponse.Location
-		err = c.fileRepository.CreateFileWithMetadata(uploadCtx, fileToBeSaved, metadataToBeSaved)
-		if err != nil {
-			slog.Error("Failed to save file metadata to database", slog.Any("error", err))
+	if placement != nil {
+		if err := c.placeUploadedFile(ctx, fileToBeSaved, placement); err != nil {
+			return nil, err
 		}
-	}()
-
-	// Save to log
-	_ = c.saveFileLog(ctx, validatedAppID, fileToBeSaved.ID, constant.ActorTypeClient, string(constant.ActionTypeUpload), fileName)
-	return fileToBeSaved.ID, nil
+	}
+	return fileToBeSaved, nil
 }
 
-func (c *FileService) DownloadFile(ctx context.Context, clientID, fileUID string) ([]byte, string, error) {
+func (c *FileService) DownloadFile(ctx context.Context, clientID, fileUID string, opts model.DownloadOptions) (*model.FileDownloadStream, error) {
 	if fileUID == "" {
-		return nil, "", model.ErrInvalidInput
+		return nil, model.ErrInvalidInput
 	}
 
 	// Check Client ID
 	validatedAppID, err := c.checkClientID(ctx, clientID)
 	if validatedAppID == "" {
-		return nil, "", err
+		return nil, err
 	}
 
 	//check file existence
 	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	isExist, _, err := c.storageService.Exists(ctx, c.bucketName, createFileName(fileMetaData.FileID))
+
+	result, err := c.openFileDownload(ctx, fileMetaData, opts)
 	if err != nil {
-		return nil, "", err
-	}
-	if !isExist {
-		return nil, "", model.ErrFileNotFound
+		return nil, err
 	}
 
 	//save to log
 	_ = c.saveFileLog(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeDownload), fileMetaData.File.Name)
+	return result, nil
+}
 
-	//download file
-	encryptedFile, err := c.storageService.DownloadFile(ctx, c.bucketName, createFileName(fileMetaData.FileID))
+// openFileDownload checks that the current version of a file is in storage and returns a stream of its plaintext,
+// limited to the range in opts when one applies
+func (c *FileService) openFileDownload(ctx context.Context, fileMetaData *entity.Metadata, opts model.DownloadOptions) (*model.FileDownloadStream, error) {
+	isExist, object, err := c.storageService.Exists(ctx, c.fileBucket(&fileMetaData.File), createFileName(fileMetaData.FileID))
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	if !isExist {
+		return nil, model.ErrFileNotFound
 	}
 
-	var key string
-	if fileMetaData.EncKey == "" {
-		// import key from KMS
-		keyHex, err := c.kmsService.ExportKey(ctx, fileMetaData.KeyUID)
+	result := &model.FileDownloadStream{
+		FileName:     fileMetaData.File.Name,
+		MimeType:     fileMetaData.File.MimeType,
+		Size:         fileMetaData.File.Size,
+		ETag:         fileETag(fileMetaData),
+		LastModified: fileMetaData.File.UpdatedAt,
+	}
+
+	// A Range is only honoured while the If-Range validator still matches; otherwise the full file is sent
+	var requested *byteRange
+	if opts.Range != "" && ifRangeMatches(opts.IfRange, result.ETag, result.LastModified) {
+		requested, err = parseByteRange(opts.Range, fileMetaData.File.Size)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
+	}
 
-		// Securely wipe keyHex from memory
-		defer secureKeyString(keyHex)()
+	key, err := c.resolveFileKey(ctx, fileMetaData, opts.CustomerKey)
+	if err != nil {
+		return nil, err
+	}
 
-		// Convert hex string to bytes
-		keyBytes, err := helper.HexToBytes(keyHex)
+	// Securely handle the key
+	defer secureKeyString(key)()
+
+	// Legacy single-shot ciphertexts are decrypted in memory as before
+	if fileMetaData.Format != constant.CipherFormatStream && fileMetaData.Format != constant.CipherFormatMultipart {
+		encryptedFile, err := c.storageService.DownloadFile(ctx, c.fileBucket(&fileMetaData.File), createFileName(fileMetaData.FileID))
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to decode hex key: %w", err)
+			return nil, err
 		}
 
-		// Convert raw key bytes to Tink keyset format
-		key, err = c.cryptoService.ImportRawKeyAsBase64(keyBytes)
+		decryptedFile, err := c.decryptFile(key, c.fileHashMethod(fileMetaData.HashAlgo), fileMetaData.Hash, encryptedFile, fileAssociatedData(fileMetaData.HeaderVersion, fileMetaData.FileID, fileMetaData.File.AppID))
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
+			return nil, err
+		}
+		if requested != nil {
+			decryptedFile = decryptedFile[requested.start : requested.end+1]
+			setDownloadRange(result, requested)
 		}
+		result.Content = io.NopCloser(bytes.NewReader(decryptedFile))
+		result.Size = int64(len(decryptedFile))
+		return result, nil
+	}
 
-	} else {
-		// unwrap key
-		key, err = c.cryptoService.DecryptString(c.keyConfig.KEK, fileMetaData.EncKey)
+	if requested != nil {
+		content, err := c.openDecryptedRange(ctx, key, fileMetaData, object.Size, requested)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
+		setDownloadRange(result, requested)
+		result.Content = content
+		return result, nil
 	}
 
-	// Securely handle the key
-	defer secureKeyString(key)()
+	encrypted, err := c.storageService.DownloadFileStream(ctx, c.fileBucket(&fileMetaData.File), createFileName(fileMetaData.FileID))
+	if err != nil {
+		return nil, err
+	}
 
-	//decrypt file
-	decryptedFile, err := c.decryptFile(key, fileMetaData.Hash, encryptedFile)
+	content, err := c.openDecryptedStream(ctx, key, fileMetaData, encrypted)
 	if err != nil {
-		return nil, "", err
+		encrypted.Close()
+		return nil, err
 	}
-	return decryptedFile, fileMetaData.File.Name, nil
+	result.Content = content
+	return result, nil
 }
 
 func (c *FileService) EncryptFile(ctx context.Context, clientID, fileName string, input multipart.File) ([]byte, string, error) {
@@ -296,10 +523,15 @@ func (c *FileService) EncryptFile(ctx context.Context, clientID, fileName string
 	//save to log
 	_ = c.saveFileLog(ctx, validatedAppID, fileName, constant.ActorTypeClient, string(constant.ActionTypeEncrypt), fileName)
 
+	config, err := c.clientConfig(ctx, validatedAppID)
+	if err != nil {
+		return nil, "", err
+	}
+
 	// Generate file UID
 	fileUID := helper.GenerateCustomUUID().String()
 
-	encryptedFile, metadataDTO, err := c.encryptFile(ctx, "", fileUID, input)
+	encryptedFile, metadataDTO, err := c.encryptFile(ctx, config, "", fileUID, validatedAppID, input)
 	if err != nil {
 		return nil, "", err
 	}
@@ -315,17 +547,22 @@ func (c *FileService) EncryptFile(ctx context.Context, clientID, fileName string
 	}
 
 	metadataToBeSaved := &entity.Metadata{
-		ID:      helper.GenerateCustomUUID().String(),
-		FileID:  fileToBeSaved.ID,
-		Hash:    metadataDTO.Hash,
-		EncHash: metadataDTO.EncryptedFileHash,
-		KeyUID:  metadataDTO.KeyUID,
-		EncKey:  "", // Wrapped key to be set below
-		KeyAlgo: c.encryptionMethod,
-	}
-
-	// Only save key if enabled and kek is available
-	if c.saveKey && c.keyConfig.KEK != "" {
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        fileToBeSaved.ID,
+		Hash:          metadataDTO.Hash,
+		EncHash:       metadataDTO.EncryptedFileHash,
+		KeyUID:        metadataDTO.KeyUID,
+		EncKey:        "", // Wrapped key to be set below
+		KeyAlgo:       config.EncryptionMethod,
+		HashAlgo:      config.HashMethod,
+		Format:        constant.CipherFormatAEAD,
+		HeaderVersion: CipherHeaderVersion,
+	}
+
+	// Keys wrapped by the app's own key manager are always saved, others only if enabled and kek is available
+	if metadataDTO.WrappedKey != "" {
+		metadataToBeSaved.EncKey = metadataDTO.WrappedKey
+	} else if c.saveKey && c.keyConfig.KEK != "" {
 		wrappedKey, err := c.cryptoService.EncryptString(c.keyConfig.KEK, metadataDTO.Key)
 		if err != nil {
 			slog.Error("Failed to wrap key", slog.Any("error", err))
@@ -363,39 +600,22 @@ func (c *FileService) DecryptFile(ctx context.Context, clientID, fileUID string,
 	_ = c.saveFileLog(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeDecrypt), fileMetaData.File.Name)
 
 	//unwrap key
-	var key string
-	if fileMetaData.EncKey == "" {
-		// import key from KMS
-		keyHex, err := c.kmsService.ExportKey(ctx, fileMetaData.KeyUID)
-		if err != nil {
-			return nil, err
-		}
-
-		// Securely wipe keyHex from memory
-		defer secureKeyString(keyHex)()
+	key, err := c.unwrapFileKey(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
 
-		// Convert hex string to bytes
-		keyBytes, err := helper.HexToBytes(keyHex)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode hex key: %w", err)
-		}
+	// Securely handle the key
+	defer secureKeyString(key)()
 
-		// Convert raw key bytes to Tink keyset format
-		key, err = c.cryptoService.ImportRawKeyAsBase64(keyBytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
-		}
-	} else {
-		// unwrap key
-		key, err = c.cryptoService.DecryptString(c.keyConfig.KEK, fileMetaData.EncKey)
+	if fileMetaData.Format == constant.CipherFormatStream || fileMetaData.Format == constant.CipherFormatMultipart {
+		content, err := c.openDecryptedStream(ctx, key, fileMetaData, input)
 		if err != nil {
 			return nil, err
 		}
+		return io.ReadAll(content)
 	}
 
-	// Securely handle the key
-	defer secureKeyString(key)()
-
 	//read file
 	encryptedFile, _, _, err := helper.GetFileBytesFromMultipart(input)
 	if err != nil {
@@ -403,7 +623,7 @@ func (c *FileService) DecryptFile(ctx context.Context, clientID, fileUID string,
 	}
 
 	//decrypt file
-	decryptedFile, err := c.decryptFile(key, fileMetaData.Hash, encryptedFile)
+	decryptedFile, err := c.decryptFile(key, c.fileHashMethod(fileMetaData.HashAlgo), fileMetaData.Hash, encryptedFile, fileAssociatedData(fileMetaData.HeaderVersion, fileMetaData.FileID, fileMetaData.File.AppID))
 	if err != nil {
 		return nil, err
 	}
@@ -432,22 +652,32 @@ func (c *FileService) GetFileMetadata(ctx context.Context, clientID, fileUID str
 		return nil, model.ErrFileNotFound
 	}
 
+	tags, err := c.fileRepository.GetTags(ctx, result.FileID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &model.FileMetadataResponse{
-		ID:         result.ID,
-		Name:       result.File.Name,
-		Size:       result.File.Size,
-		MimeType:   result.File.MimeType,
-		VersionID:  result.VersionID,
-		Hash:       result.Hash,
-		BucketName: result.File.BucketName,
-		Location:   result.File.Location,
-		CreatedAt:  result.CreatedAt.Format("2006-01-02 15:04:05"),
-		UpdatedAt:  result.UpdatedAt.Format("2006-01-02 15:04:05"),
+		ID:          result.ID,
+		Name:        result.File.Name,
+		Size:        result.File.Size,
+		MimeType:    result.File.MimeType,
+		VersionID:   result.VersionID,
+		Hash:        result.Hash,
+		BucketName:  result.File.BucketName,
+		Location:    result.File.Location,
+		CustomerKey: result.KeyFingerprint != "",
+		Path:        result.File.Path,
+		Tags:        tags,
+		LegalHold:   result.File.LegalHold,
+		RetainUntil: result.File.RetainUntil,
+		CreatedAt:   result.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:   result.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}, nil
 
 }
 
-func (c *FileService) UpdateFile(ctx context.Context, clientID, fileUID, fileName string, input multipart.File) (string, error) {
+func (c *FileService) UpdateFile(ctx context.Context, clientID, fileUID, fileName string, input io.Reader, opts model.UploadOptions) (string, error) {
 	// Input validation
 	if clientID == "" || fileUID == "" || fileName == "" || input == nil {
 		return "", model.ErrInvalidInput
@@ -458,43 +688,68 @@ func (c *FileService) UpdateFile(ctx context.Context, clientID, fileUID, fileNam
 		return "", err
 	}
 
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return "", err
+	}
+
 	// check file existence
 	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
 	if err != nil {
 		return "", err
 	}
+	if err := checkFileMutable(&fileMetaData.File); err != nil {
+		return "", err
+	}
+	quota, err := c.checkQuota(ctx, validatedAppID, &fileMetaData.File)
+	if err != nil {
+		return "", err
+	}
+	limited := quota.limit(input)
+	config, err := c.clientConfig(ctx, validatedAppID)
+	if err != nil {
+		return "", err
+	}
 
-	// Unwrap Key
-	var key string
-	if fileMetaData.EncKey == "" {
-		// import key from KMS
-		keyHex, err := c.kmsService.ExportKey(ctx, fileMetaData.KeyUID)
-		if err != nil {
-			return "", err
-		}
-		// Convert hex string to bytes
-		keyBytes, err := helper.HexToBytes(keyHex)
-		if err != nil {
-			return "", fmt.Errorf("failed to decode hex key: %w", err)
-		}
-		// Convert raw key bytes to Tink keyset format
-		key, err = c.cryptoService.ImportRawKeyAsBase64(keyBytes)
-		if err != nil {
-			return "", fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
-		}
-	} else {
-		key, err = c.cryptoService.DecryptString(c.keyConfig.KEK, fileMetaData.EncKey)
-		if err != nil {
-			return "", err
-		}
+	// Unwrap Key, or check the customer key against the one the file was sealed with
+	key, err := c.resolveFileKey(ctx, fileMetaData, opts.CustomerKey)
+	if err != nil {
+		return "", err
 	}
 
 	// Securely handle the key
 	defer secureKeyString(key)()
 
-	// Encrypt File
-	encryptedFile, metaDataDTO, err := c.encryptFile(ctx, key, fileMetaData.FileID, input)
+	// Record the update in the outbox so a failed commit can roll storage back to the current version
+	job := &entity.UploadJobs{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        fileMetaData.FileID,
+		AppID:         validatedAppID,
+		Operation:     constant.JobOperationUpdate,
+		Status:        constant.JobStatusPending,
+		BucketName:    c.fileBucket(&fileMetaData.File),
+		ObjectName:    createFileName(fileMetaData.FileID),
+		BaseVersionID: fileMetaData.VersionID,
+		NextAttemptAt: time.Now().Add(c.uploadStaleAfter),
+	}
+	if err := c.uploadJobRepository.Create(ctx, job, nil); err != nil {
+		return "", err
+	}
+
+	// Encrypt the new content and stream it to storage as a new version of the object, in the bucket it is already in
+	metaDataDTO, resp, err := c.encryptFileStream(ctx, config, key, fileMetaData.KeyUID, fileMetaData.FileID, validatedAppID, limited, func(encrypted io.Reader) (*model.StorageTransactionResponse, error) {
+		resp, err := c.storageService.UpdateFile(ctx, job.BucketName, job.ObjectName, encrypted, -1)
+		if err == nil {
+			c.recordStoredVersion(ctx, job, resp)
+		}
+		return resp, err
+	})
 	if err != nil {
+		if limited.exceeded {
+			err = limited.limitErr()
+		}
+		slog.Error("Failed to update file to storage", slog.Any("error", err))
+		c.abandonUploadJob(job, err)
 		return "", err
 	}
 
@@ -505,42 +760,84 @@ func (c *FileService) UpdateFile(ctx context.Context, clientID, fileUID, fileNam
 		UserID:   "Not Available", // TO BE ADDED
 		Size:     metaDataDTO.Size,
 		MimeType: metaDataDTO.MimeType,
-	}
-
-	// Create multipart file
-	toBeUploadedFile, tobeUploadSize, err := helper.CreateMultipartFileFromBytes(encryptedFile, fileName)
-	if err != nil {
+		Location: resp.Location,
+	}
+
+	// UPDATING NEW METADADATA
+	// The new version is sealed with the key of the file, so the key columns carry over
+	metadataToBeUpdated := &entity.Metadata{
+		FileID:         fileMetaData.FileID,
+		Hash:           metaDataDTO.Hash,
+		EncHash:        metaDataDTO.EncryptedFileHash,
+		KeyUID:         fileMetaData.KeyUID,
+		EncKey:         fileMetaData.EncKey,
+		KeyFingerprint: fileMetaData.KeyFingerprint,
+		KeyAlgo:        fileMetaData.KeyAlgo,
+		HashAlgo:       config.HashMethod,
+		Format:         constant.CipherFormatStream,
+		HeaderVersion:  CipherHeaderVersion,
+		VersionID:      resp.VersionID,
+	}
+
+	// Update data IN DB, leaving the job for the worker to retry if the commit fails
+	if err := c.commitUploadJob(ctx, job, fileToBeUpdated, metadataToBeUpdated); err != nil {
 		return "", err
 	}
-
-	// Upload file to storage and update metadata to DB asynchronously
-	go func() {
-		context := context.Background()
-		// Update File to Storage
-		resp, err := c.storageService.UpdateFile(context, c.bucketName, createFileName(fileMetaData.FileID), toBeUploadedFile, tobeUploadSize)
-		if err != nil {
-			slog.Error("Failed to update file to storage", slog.Any("error", err))
-		}
-
-		// UPDATING NEW METADADATA
-		fileMetaData.Hash = metaDataDTO.Hash
-		fileMetaData.EncHash = metaDataDTO.EncryptedFileHash
-		fileMetaData.VersionID = resp.VersionID
-		fileToBeUpdated.Location = resp.Location
-
-		// Update data IN DB
-		err = c.fileRepository.UpdateFileAndMetadata(context, fileToBeUpdated, fileMetaData)
-		if err != nil {
-			slog.Error("Failed to update file metadata in database", slog.Any("error", err))
+	if tags != nil {
+		if err := c.fileRepository.SetTags(ctx, fileMetaData.FileID, tags); err != nil {
+			return "", err
 		}
-
-	}()
+	}
 
 	// save to log
 	_ = c.saveFileLog(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeUpdate), fileMetaData.File.Name)
 	return fmt.Sprintf("File %s updated successfully", fileMetaData.File.Name), nil
 }
 
+// GetFileStatus reports whether a file is durable in storage and database, including its latest upload job.
+func (c *FileService) GetFileStatus(ctx context.Context, clientID, fileUID string) (*model.FileStatusResponse, error) {
+	if clientID == "" || fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	file, err := c.fileRepository.GetByID(ctx, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	if file.AppID != validatedAppID {
+		return nil, model.ErrFileNotFound
+	}
+
+	result := &model.FileStatusResponse{
+		ID:        file.ID,
+		Name:      file.Name,
+		Status:    file.Status,
+		UpdatedAt: file.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+
+	job, err := c.uploadJobRepository.GetLatestByFileID(ctx, file.ID)
+	if err != nil && !errors.Is(err, model.ErrUploadJobNotFound) {
+		return nil, err
+	}
+	if job != nil {
+		result.Job = &model.UploadJobResponse{
+			Operation: job.Operation,
+			Status:    job.Status,
+			Attempts:  job.Attempts,
+			LastError: job.LastError,
+		}
+		if job.Status == constant.JobStatusPending || job.Status == constant.JobStatusCompensating {
+			result.Job.NextAttemptAt = job.NextAttemptAt.Format("2006-01-02 15:04:05")
+		}
+	}
+	return result, nil
+}
+
 func (c *FileService) DeleteFile(ctx context.Context, clientID, fileUID string) error {
 	if fileUID == "" || clientID == "" {
 		return model.ErrInvalidInput
@@ -551,29 +848,46 @@ func (c *FileService) DeleteFile(ctx context.Context, clientID, fileUID string)
 		return err
 	}
 
+	return c.deleteFile(ctx, validatedAppID, fileUID)
+}
+
+// deleteFile removes a file of a validated app from storage and soft deletes it in the database
+func (c *FileService) deleteFile(ctx context.Context, validatedAppID, fileUID string) error {
+	result, err := c.removeFile(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return err
+	}
+
+	_ = c.saveFileLog(ctx, validatedAppID, fileUID, constant.ActorTypeClient, string(constant.ActionTypeDelete), result.File.Name)
+	return nil
+}
+
+// removeFile deletes the current object of a file from storage and soft deletes the file, returning its metadata
+func (c *FileService) removeFile(ctx context.Context, validatedAppID, fileUID string) (*entity.Metadata, error) {
 	// check file existence
 	result, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if result == nil {
-		return fmt.Errorf("file %s does not exist", fileUID)
+		return nil, fmt.Errorf("file %s does not exist", fileUID)
+	}
+	if err := checkFileMutable(&result.File); err != nil {
+		return nil, err
 	}
 
 	// delete file in storage
-	err = c.storageService.DeleteFile(ctx, c.bucketName, createFileName(result.FileID))
+	err = c.storageService.DeleteFile(ctx, c.fileBucket(&result.File), createFileName(result.FileID))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// soft delete file in DB
 	err = c.fileRepository.Delete(ctx, result.FileID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	_ = c.saveFileLog(ctx, validatedAppID, fileUID, constant.ActorTypeClient, string(constant.ActionTypeDelete), result.File.Name)
-	return nil
+	return result, nil
 }
 
 func (c *FileService) RecoverFile(ctx context.Context, clientID, fileUID string) (string, error) {
@@ -597,20 +911,33 @@ func (c *FileService) RecoverFile(ctx context.Context, clientID, fileUID string)
 		return "", model.ErrFileAlreadyExists
 	}
 
-	err = c.storageService.RestoreFile(ctx, c.bucketName, createFileName(file.FileID), file.VersionID)
+	// A recovered file counts towards the quota of its app again, so it must fit like an upload of its size
+	quota, err := c.checkQuota(ctx, validatedAppID, nil)
 	if err != nil {
 		return "", err
 	}
+	if err := quota.check(file.File.Size); err != nil {
+		return "", err
+	}
+
+	// The row is restored first so that a taken path stops the recovery before storage is touched
 	err = c.fileRepository.RestoreFile(ctx, file.FileID)
 	if err != nil {
 		return "", err
 	}
+	err = c.storageService.RestoreFile(ctx, c.fileBucket(&file.File), createFileName(file.FileID), file.VersionID)
+	if err != nil {
+		if undoErr := c.fileRepository.Delete(context.WithoutCancel(ctx), file.FileID); undoErr != nil {
+			slog.Error("Failed to delete file again after its storage restore failed", slog.String("fileID", file.FileID), slog.Any("error", undoErr))
+		}
+		return "", err
+	}
 	_ = c.saveFileLog(ctx, validatedAppID, fileUID, constant.ActorTypeClient, string(constant.ActionTypeRecover), fileUID)
 	return fmt.Sprintf("File %s recovered successfully", file.File.Name), nil
 }
 
 // ADMIN ONLY
-func (c *FileService) ReKey(ctx context.Context, appID, keyUID string) (string, error) {
+func (c *FileService) ReKey(ctx context.Context, appID, keyUID string) (_ string, err error) {
 	if keyUID == "" {
 		return "", model.ErrInvalidInput
 	}
@@ -619,28 +946,45 @@ func (c *FileService) ReKey(ctx context.Context, appID, keyUID string) (string,
 		return "", fmt.Errorf("KMS is not enabled")
 	}
 
-	_, err := c.kmsService.ReKey(ctx, keyUID)
+	rewrapped, skipped := 0, 0
+	defer func() {
+		_ = recordAudit(ctx, c.fileLogsRepository, auditEntry{
+			ActorID:      appID,
+			ActorType:    constant.ActorTypeAdmin,
+			ResourceType: constant.ResourceTypeKey,
+			ResourceID:   keyUID,
+			Action:       constant.ActionTypeReKey,
+			Err:          err,
+			Metadata:     map[string]interface{}{"rewrapped_keys": rewrapped, "skipped_keys": skipped},
+		})
+	}()
+
+	_, err = c.kmsService.ReKey(ctx, keyUID)
 	if err != nil {
 		return "", err
 	}
 
 	toBeUpdates := map[string]string{}
 
-	_ = c.saveFileLog(ctx, appID, constant.ActorTypeAdmin, "REKEY", string(constant.ActionTypeReKey), "")
-
 	keyUIDs, errGetKeys := c.fileRepository.GetAllKeyUIDs(ctx)
 	if errGetKeys != nil {
 		return "", fmt.Errorf("failed to get key UIDs: %w", errGetKeys)
 	}
 
 	for _, fileKeyUID := range keyUIDs {
+		// Keys held by an app's own key manager are not ours to rewrap
+		if isExternalKeyUID(fileKeyUID) {
+			continue
+		}
 		key, err := c.kmsService.ExportKey(ctx, fileKeyUID)
 		if err != nil {
+			skipped++
 			continue
 		}
 		//key wrapping
 		wrappedKey, err := c.cryptoService.EncryptString(c.keyConfig.KEK, key)
 		if err != nil {
+			skipped++
 			continue
 		}
 
@@ -651,39 +995,28 @@ func (c *FileService) ReKey(ctx context.Context, appID, keyUID string) (string,
 	if err != nil {
 		return "", fmt.Errorf("failed to update keys: %w", err)
 	}
+	rewrapped = len(toBeUpdates)
 	return "", nil
 }
 
 // ADMIN ONLY
-func (c *FileService) ListLogs(ctx context.Context, limit, offset int, sortBy, order string) (int64, *[]model.FileLogResponse, error) {
+func (c *FileService) ListLogs(ctx context.Context, filter model.LogFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileLogResponse, string, error) {
 	// Validate sort parameters to prevent SQL injection
 	sortBy, order = helper.ValidateSortParams(sortBy, order, helper.AllowedLogSortFields)
 
-	count, result, err := c.fileLogsRepository.List(ctx, offset, limit, sortBy, order)
-	if err != nil {
-		return 0, nil, err
+	if err := validateLogFilter(filter); err != nil {
+		return 0, nil, "", err
 	}
 
-	var fileLogResponse []model.FileLogResponse
-	for _, fileLog := range *result {
-		fileLogResponse = append(fileLogResponse, model.FileLogResponse{
-			ID:        fileLog.FileID,
-			ActorID:   fileLog.ActorID,
-			ActorType: fileLog.ActorType,
-			Action:    fileLog.Action,
-			Timestamp: fileLog.Timestamp,
-			IP:        fileLog.IP,
-			UserAgent: fileLog.UserAgent,
-			Metadata:  fileLog.Metadata,
-		})
+	count, result, nextCursor, err := c.fileLogsRepository.List(ctx, filter, offset, limit, sortBy, order)
+	if err != nil {
+		return 0, nil, "", err
 	}
-
-	return count, &fileLogResponse, nil
+	return count, fileLogResponses(*result), nextCursor, nil
 }
 
-func (c *FileService) encryptFile(ctx context.Context, fileKey, fileUID string, file multipart.File) ([]byte, *model.MetaDataDTO, error) {
-	var key string
-	var keyUID string
+func (c *FileService) encryptFile(ctx context.Context, config *model.ClientConfig, fileKey, fileUID, appID string, file multipart.File) ([]byte, *model.MetaDataDTO, error) {
+	var key, keyUID, wrappedKey string
 
 	// Read file bytes
 	fileBytes, fileSize, mimeType, err := helper.GetFileBytesFromMultipart(file)
@@ -697,7 +1030,7 @@ func (c *FileService) encryptFile(ctx context.Context, fileKey, fileUID string,
 	}
 
 	// Calculate file hash
-	hashValue, err := c.cryptoService.HashFile(c.hashMethod, fileBytes)
+	hashValue, err := c.cryptoService.HashFile(config.HashMethod, fileBytes)
 	if err != nil {
 		slog.Error("Failed to hash file", slog.Any("error", err))
 		return nil, nil, model.ErrHashCalculationFailed
@@ -707,10 +1040,10 @@ func (c *FileService) encryptFile(ctx context.Context, fileKey, fileUID string,
 		key = fileKey
 		slog.Debug("Using provided key for encryption")
 	} else if fileUID != "" { // Generate encryption key form KMS
-		key, keyUID, err = c.getEncryptionKey(ctx, fileUID)
+		key, keyUID, wrappedKey, err = c.getEncryptionKey(ctx, appID, fileUID)
 		if err != nil {
 			slog.Error("Failed to generate key", slog.Any("error", err))
-			return nil, nil, model.ErrKeyGenerationFailed
+			return nil, nil, fmt.Errorf("%w: %w", model.ErrKeyGenerationFailed, err)
 		}
 		slog.Debug("Generated new key for encryption", slog.Int("key_length", len(key)))
 	} else {
@@ -721,29 +1054,41 @@ func (c *FileService) encryptFile(ctx context.Context, fileKey, fileUID string,
 	defer secureKeyString(key)()
 
 	// Encrypt file
-	encryptedFile, err := c.cryptoService.EncryptFile(key, fileBytes)
+	encryptedFile, err := c.cryptoService.EncryptFile(key, keyUID, fileBytes, fileAssociatedData(CipherHeaderVersion, fileUID, appID))
 	if err != nil {
 		slog.Error("Failed to encrypt file", slog.Any("error", err))
 		return nil, nil, model.ErrFileEncryptionFailed
 	}
 
 	// Prepare metadata (key still needed here for metadata DTO)
-	metadata := c.createMetadataDTO(keyUID, key, mimeType, fileSize, hashValue, encryptedFile)
+	metadata := c.createMetadataDTO(config, keyUID, key, mimeType, fileSize, hashValue, encryptedFile)
+	metadata.WrappedKey = wrappedKey
 	return encryptedFile, metadata, nil
 }
 
-// getEncryptionKey generates or retrieves an encryption key
-func (c *FileService) getEncryptionKey(ctx context.Context, fileUID string) (key, keyUID string, err error) {
+// getEncryptionKey generates or retrieves an encryption key.
+// For apps holding their own keys, wrappedKey is the key as wrapped by the app's key manager.
+func (c *FileService) getEncryptionKey(ctx context.Context, appID, fileUID string) (key, keyUID, wrappedKey string, err error) {
+	if appID != "" {
+		app, err := c.applicationRepository.GetByID(ctx, appID)
+		if err != nil {
+			return "", "", "", err
+		}
+		if usesExternalKeys(app) {
+			return c.generateExternalKey(ctx, app)
+		}
+	}
+
 	if c.keyConfig.KMSEnable {
 		slog.Info("KMS is enabled, generating key from KMS")
 		keyUID, err = c.kmsService.GenerateSymetricKey(ctx, fileUID)
 		if err != nil {
-			return "", "", model.ErrFailedToGenerateKeyFromKMS
+			return "", "", "", model.ErrFailedToGenerateKeyFromKMS
 		}
 
 		keyHex, err := c.kmsService.ExportKey(ctx, keyUID)
 		if err != nil {
-			return "", "", model.ErrFailedToImportKeyFromKMS
+			return "", "", "", model.ErrFailedToImportKeyFromKMS
 		}
 
 		// Securely wipe keyHex after use
@@ -752,26 +1097,261 @@ func (c *FileService) getEncryptionKey(ctx context.Context, fileUID string) (key
 		// Convert hex string to bytes
 		keyBytes, err := helper.HexToBytes(keyHex)
 		if err != nil {
-			return "", "", fmt.Errorf("failed to decode hex key: %w", err)
+			return "", "", "", fmt.Errorf("failed to decode hex key: %w", err)
 		}
 
 		// Convert raw key bytes to Tink keyset format
 		key, err = c.cryptoService.ImportRawKeyAsBase64(keyBytes)
 		if err != nil {
-			return "", "", fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
+			return "", "", "", fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
 		}
 	} else {
 		slog.Info("KMS is not enabled, generating local key")
 		key, err = c.cryptoService.GenerateKey()
 		if err != nil {
-			return "", "", model.ErrKeyGenerationFailed
+			return "", "", "", model.ErrKeyGenerationFailed
 		}
 	}
-	return key, keyUID, nil
+	return key, keyUID, "", nil
+}
+
+// encryptFileStream encrypts the input with streaming AEAD and hands the ciphertext to upload as it is produced.
+// Plaintext (and optionally ciphertext) hashes are computed on the fly, as config sets, so the file is never held in memory.
+// keyUID names the KMS key a provided fileKey was exported from, if any, and is recorded in the ciphertext header.
+func (c *FileService) encryptFileStream(ctx context.Context, config *model.ClientConfig, fileKey, keyUID, fileUID, appID string, input io.Reader, upload func(encrypted io.Reader) (*model.StorageTransactionResponse, error)) (*model.MetaDataDTO, *model.StorageTransactionResponse, error) {
+	var key, wrappedKey string
+	var err error
+
+	// Read first 512 bytes for MIME detection without consuming them
+	reader := bufio.NewReader(input)
+	header, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		slog.Error("Failed to read file", slog.Any("error", err))
+		return nil, nil, model.ErrFailedToReadFile
+	}
+	if len(header) == 0 {
+		slog.Error("File is empty")
+		return nil, nil, model.ErrFileIsEmpty
+	}
+	mimeType := http.DetectContentType(header)
+
+	plainHash, err := c.cryptoService.NewHash(config.HashMethod)
+	if err != nil {
+		slog.Error("Failed to hash file", slog.Any("error", err))
+		return nil, nil, model.ErrHashCalculationFailed
+	}
+
+	if fileKey != "" { // Use provided key
+		key = fileKey
+		slog.Debug("Using provided key for encryption")
+	} else if fileUID != "" { // Generate encryption key form KMS
+		key, keyUID, wrappedKey, err = c.getEncryptionKey(ctx, appID, fileUID)
+		if err != nil {
+			slog.Error("Failed to generate key", slog.Any("error", err))
+			return nil, nil, fmt.Errorf("%w: %w", model.ErrKeyGenerationFailed, err)
+		}
+		slog.Debug("Generated new key for encryption", slog.Int("key_length", len(key)))
+	} else {
+		return nil, nil, model.ErrFileUidOrKeyInvalid
+	}
+
+	// Securely handle key - will be destroyed when cleanup is called
+	defer secureKeyString(key)()
+
+	pipeReader, pipeWriter := io.Pipe()
+	var ciphertextSink io.Writer = pipeWriter
+	var encHash hash.Hash
+	if config.HashEncryptedFile {
+		if encHash, err = c.cryptoService.NewHash(config.HashMethod); err == nil {
+			ciphertextSink = io.MultiWriter(pipeWriter, encHash)
+		} else {
+			slog.Warn("Failed to calculate encrypted file hash", slog.Any("error", err))
+		}
+	}
+
+	// Encrypt in the background while storage consumes the ciphertext. The encryptor writes the ciphertext header
+	// as soon as it is created, so it is created there too rather than before storage starts reading the pipe.
+	type encryptResult struct {
+		size int64
+		err  error
+	}
+	done := make(chan encryptResult, 1)
+	go func() {
+		encryptor, err := c.cryptoService.NewEncryptingWriter(key, keyUID, ciphertextSink, fileAssociatedData(CipherHeaderVersion, fileUID, appID))
+		if err != nil {
+			slog.Error("Failed to create encrypting writer", slog.Any("error", err))
+			pipeWriter.CloseWithError(model.ErrFileEncryptionFailed)
+			done <- encryptResult{err: model.ErrFileEncryptionFailed}
+			return
+		}
+		size, err := io.Copy(encryptor, io.TeeReader(reader, plainHash))
+		if err == nil {
+			err = encryptor.Close()
+		}
+		pipeWriter.CloseWithError(err)
+		done <- encryptResult{size: size, err: err}
+	}()
+
+	transactionResponse, uploadErr := upload(pipeReader)
+	// Unblock the encryptor if storage stopped reading early
+	pipeReader.CloseWithError(uploadErr)
+	result := <-done
+
+	if result.err != nil && (result.err == model.ErrFileEncryptionFailed || !errors.Is(result.err, uploadErr)) {
+		slog.Error("Failed to encrypt file", slog.Any("error", result.err))
+		return nil, nil, model.ErrFileEncryptionFailed
+	}
+	if uploadErr != nil {
+		return nil, nil, fmt.Errorf("%w: %w", model.ErrFileUploadFailed, uploadErr)
+	}
+	if transactionResponse == nil {
+		return nil, nil, model.ErrFileUploadFailed
+	}
+
+	metadata := &model.MetaDataDTO{
+		KeyUID:   keyUID,
+		Key:      key,
+		MimeType: mimeType,
+		Size:     result.size,
+		Hash:     base64.StdEncoding.EncodeToString(plainHash.Sum(nil)),
+
+		WrappedKey: wrappedKey,
+	}
+	if encHash != nil {
+		metadata.EncryptedFileHash = base64.StdEncoding.EncodeToString(encHash.Sum(nil))
+	}
+	return metadata, transactionResponse, nil
+}
+
+// commitUploadJob persists the rows an upload job has to commit, then commits them.
+// When the commit fails but the payload was saved, the worker retries it and the caller still gets the file ID;
+// when even the payload could not be saved, the upload is reported as failed and the worker removes the object.
+func (c *FileService) commitUploadJob(ctx context.Context, job *entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error {
+	payload, err := json.Marshal(uploadJobPayload{File: *file, Metadata: *metadata})
+	if err != nil {
+		c.abandonUploadJob(job, err)
+		return fmt.Errorf("failed to encode upload job payload: %w", err)
+	}
+
+	job.Payload = string(payload)
+	job.VersionID = metadata.VersionID
+	job.NextAttemptAt = time.Now().Add(uploadJobBackoff(0))
+	payloadSaved := c.uploadJobRepository.Update(ctx, job, constant.JobStatusPending) == nil
+
+	err = c.uploadJobRepository.Complete(ctx, job, file, metadata)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, model.ErrUploadJobNotFound):
+		// The worker settled the job first
+		return c.settledUploadJob(ctx, job)
+	}
+
+	slog.Error("Failed to commit file to database", slog.String("file_id", job.FileID), slog.Any("error", err))
+	if !payloadSaved {
+		return fmt.Errorf("%w: %w", model.ErrFileUploadFailed, err)
+	}
+	slog.Warn("File commit deferred to upload worker", slog.String("file_id", job.FileID))
+	return nil
+}
+
+// settledUploadJob reports on a job whose commit lost the claim to the worker.
+// Only a commit by the worker keeps the file; otherwise the worker compensated the job, and the object or version
+// written for it is removed in case the worker could not yet tell which one that was.
+func (c *FileService) settledUploadJob(ctx context.Context, job *entity.UploadJobs) error {
+	latest, err := c.uploadJobRepository.GetLatestByFileID(ctx, job.FileID)
+	if err == nil && latest.ID == job.ID && latest.Status == constant.JobStatusDone {
+		return nil
+	}
+
+	slog.Error("Upload job was settled before it could be committed", slog.String("file_id", job.FileID))
+	if wroteVersion(job) {
+		if err := c.storageService.DeleteFileVersion(context.WithoutCancel(ctx), job.BucketName, job.ObjectName, job.VersionID); err != nil {
+			slog.Warn("Failed to remove object of settled upload job", slog.String("file_id", job.FileID), slog.Any("error", err))
+		}
+	}
+	return fmt.Errorf("%w: %w", model.ErrFileUploadFailed, model.ErrUploadJobNotFound)
+}
+
+// recordStoredVersion saves the version storage wrote for a job as soon as storage reports it, so the version is
+// removed again when a later step fails, by the request or by the worker
+func (c *FileService) recordStoredVersion(ctx context.Context, job *entity.UploadJobs, resp *model.StorageTransactionResponse) {
+	job.VersionID = resp.VersionID
+	// The version is in storage even if the client has gone away since
+	if err := c.uploadJobRepository.Update(context.WithoutCancel(ctx), job, constant.JobStatusPending); err != nil {
+		slog.Warn("Failed to record stored version of upload job", slog.String("file_id", job.FileID),
+			slog.String("version_id", job.VersionID), slog.Any("error", err))
+	}
+}
+
+// wroteVersion reports whether a job left an object or version in storage that a rollback has to remove.
+// An update only leaves something to remove once storage reported the version it wrote.
+func wroteVersion(job *entity.UploadJobs) bool {
+	if job.Operation == constant.JobOperationUpload {
+		return true
+	}
+	return job.VersionID != "" && job.VersionID != "null" && job.VersionID != job.BaseVersionID
+}
+
+// abandonUploadJob gives up on a job before its rows are committed.
+// Any object or version it wrote is removed right away; if that fails the job is left for the worker to compensate.
+func (c *FileService) abandonUploadJob(job *entity.UploadJobs, cause error) {
+	// The request context may already be cancelled when the client aborted the upload
+	ctx := context.Background()
+	job.LastError = cause.Error()
+
+	if wroteVersion(job) {
+		if err := c.storageService.DeleteFileVersion(ctx, job.BucketName, job.ObjectName, job.VersionID); err != nil {
+			slog.Warn("Failed to remove partial object, deferring to upload worker", slog.String("file_id", job.FileID), slog.Any("error", err))
+			job.Status = constant.JobStatusCompensating
+			job.NextAttemptAt = time.Now().Add(uploadJobBackoff(0))
+			_ = c.uploadJobRepository.Update(ctx, job, constant.JobStatusPending)
+			return
+		}
+	}
+
+	if err := c.uploadJobRepository.Fail(ctx, job); err != nil {
+		slog.Error("Failed to mark upload job as failed", slog.String("file_id", job.FileID), slog.Any("error", err))
+	}
+}
+
+// unwrapFileKey returns the Tink keyset for a stored file, exporting it from KMS or unwrapping it with the KEK
+func (c *FileService) unwrapFileKey(ctx context.Context, fileMetaData *entity.Metadata) (string, error) {
+	if fileMetaData.KeyFingerprint != "" {
+		return "", model.ErrCustomerKeyRequired
+	}
+	if isExternalKeyUID(fileMetaData.KeyUID) {
+		return c.unwrapExternalKey(ctx, fileMetaData)
+	}
+	if fileMetaData.EncKey != "" {
+		return c.cryptoService.DecryptString(c.keyConfig.KEK, fileMetaData.EncKey)
+	}
+
+	// import key from KMS
+	keyHex, err := c.kmsService.ExportKey(ctx, fileMetaData.KeyUID)
+	if err != nil {
+		return "", err
+	}
+
+	// Securely wipe keyHex from memory
+	defer secureKeyString(keyHex)()
+
+	// Convert hex string to bytes
+	keyBytes, err := helper.HexToBytes(keyHex)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode hex key: %w", err)
+	}
+
+	// Convert raw key bytes to Tink keyset format
+	key, err := c.cryptoService.ImportRawKeyAsBase64(keyBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert raw key to Tink keyset: %w", err)
+	}
+	return key, nil
 }
 
 // createMetadataDTO constructs metadata DTO
-func (c *FileService) createMetadataDTO(keyUID, key, mimeType string, size int64, hash string, encryptedFile []byte) *model.MetaDataDTO {
+func (c *FileService) createMetadataDTO(config *model.ClientConfig, keyUID, key, mimeType string, size int64, hash string, encryptedFile []byte) *model.MetaDataDTO {
 	metadata := &model.MetaDataDTO{
 		KeyUID:   keyUID,
 		Key:      key,
@@ -780,8 +1360,8 @@ func (c *FileService) createMetadataDTO(keyUID, key, mimeType string, size int64
 		Hash:     hash,
 	}
 
-	if c.hashEncryptedFile {
-		if encryptedFileHash, err := c.cryptoService.HashFile(c.hashMethod, encryptedFile); err == nil {
+	if config.HashEncryptedFile {
+		if encryptedFileHash, err := c.cryptoService.HashFile(config.HashMethod, encryptedFile); err == nil {
 			metadata.EncryptedFileHash = encryptedFileHash
 		} else {
 			slog.Warn("Failed to calculate encrypted file hash", slog.Any("error", err))
@@ -791,7 +1371,7 @@ func (c *FileService) createMetadataDTO(keyUID, key, mimeType string, size int64
 	return metadata
 }
 
-func (c *FileService) decryptFile(key, hashValue string, encryptedFile []byte) ([]byte, error) {
+func (c *FileService) decryptFile(key, hashMethod, hashValue string, encryptedFile, associatedData []byte) ([]byte, error) {
 	if encryptedFile == nil && len(encryptedFile) == 0 && len(hashValue) == 0 && len(key) == 0 {
 		return nil, model.ErrFileIsEmpty
 	}
@@ -800,18 +1380,68 @@ func (c *FileService) decryptFile(key, hashValue string, encryptedFile []byte) (
 	defer secureKeyString(key)()
 
 	//decrypt file
-	decryptedFile, err := c.cryptoService.DecryptFile(key, encryptedFile)
+	decryptedFile, err := c.cryptoService.DecryptFile(key, encryptedFile, associatedData)
 	if err != nil {
 		return nil, err
 	}
 
 	//compare hash
-	if !c.cryptoService.CompareHashFile(c.hashMethod, decryptedFile, hashValue) {
+	if !c.cryptoService.CompareHashFile(hashMethod, decryptedFile, hashValue) {
 		return nil, model.ErrHashNotMatch
 	}
 	return decryptedFile, nil
 }
 
+// decryptFileStream returns a reader over the plaintext of a streaming ciphertext.
+// Segments are authenticated as they are read; the whole-file hash is checked once the stream ends.
+func (c *FileService) decryptFileStream(key, hashMethod, hashValue string, encrypted io.ReadCloser, associatedData []byte) (io.ReadCloser, error) {
+	decrypted, err := c.cryptoService.NewDecryptingReader(key, encrypted, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, err := c.cryptoService.NewHash(hashMethod)
+	if err != nil {
+		return nil, model.ErrHashCalculationFailed
+	}
+
+	return &hashVerifyingReader{reader: decrypted, closer: encrypted, hasher: hasher, expected: hashValue}, nil
+}
+
+// openDecryptedStream picks the streaming decryption matching the format the file was sealed with
+func (c *FileService) openDecryptedStream(ctx context.Context, key string, fileMetaData *entity.Metadata, encrypted io.ReadCloser) (io.ReadCloser, error) {
+	if fileMetaData.Format != constant.CipherFormatMultipart {
+		return c.decryptFileStream(key, c.fileHashMethod(fileMetaData.HashAlgo), fileMetaData.Hash, encrypted, fileAssociatedData(fileMetaData.HeaderVersion, fileMetaData.FileID, fileMetaData.File.AppID))
+	}
+
+	parts, err := c.uploadSessionRepository.GetPartsByFileID(ctx, fileMetaData.FileID)
+	if err != nil {
+		return nil, err
+	}
+	return c.decryptMultipartStream(key, fileMetaData, parts, encrypted)
+}
+
+// hashVerifyingReader hashes plaintext as it is read and reports ErrHashNotMatch instead of io.EOF on mismatch
+type hashVerifyingReader struct {
+	reader   io.Reader
+	closer   io.Closer
+	hasher   hash.Hash
+	expected string
+}
+
+func (r *hashVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hasher.Write(p[:n])
+	if err == io.EOF && base64.StdEncoding.EncodeToString(r.hasher.Sum(nil)) != r.expected {
+		return n, model.ErrHashNotMatch
+	}
+	return n, err
+}
+
+func (r *hashVerifyingReader) Close() error {
+	return r.closer.Close()
+}
+
 func secureKeyString(key string) (cleanup func()) {
 	if key == "" {
 		return func() {}
@@ -827,18 +1457,20 @@ func createFileName(fileName string) string {
 }
 
 func (c *FileService) saveFileLog(ctx context.Context, appID, fileID, actorType, action string, fileName string) error {
-	log := &entity.FileLogs{
-		FileID:    fileID,
-		ActorID:   appID,
-		ActorType: actorType,
-		Action:    action,
-		IP:        helper.GetClientIP(ctx),
-		UserAgent: helper.GetUserAgent(ctx),
-		Metadata: map[string]interface{}{
-			"file_name": fileName,
-		},
-	}
-	return c.fileLogsRepository.Create(context.Background(), log)
+	return c.saveFileLogWithMetadata(ctx, appID, fileID, actorType, action, map[string]interface{}{
+		"file_name": fileName,
+	})
+}
+
+func (c *FileService) saveFileLogWithMetadata(ctx context.Context, appID, fileID, actorType, action string, metadata map[string]interface{}) error {
+	return recordAudit(ctx, c.fileLogsRepository, auditEntry{
+		ActorID:      appID,
+		ActorType:    actorType,
+		ResourceType: constant.ResourceTypeFile,
+		ResourceID:   fileID,
+		Action:       constant.ActionType(action),
+		Metadata:     metadata,
+	})
 }
 
 func (c *FileService) checkClientID(ctx context.Context, clientID string) (string, error) {
@@ -858,17 +1490,32 @@ func (c *FileService) checkClientID(ctx context.Context, clientID string) (strin
 }
 
 type FileServiceParams struct {
-	CryptoService         CryptographicInterface
-	StorageService        StorageInterface
-	KMSService            KMSInterface
-	FileRepository        repository.FileRepository
-	FileLogsRepository    repository.FileLogsRepository
-	ApplicationRepository repository.ApplicationRepository
-	AdminRepository       repository.AdminRepository
-	DB                    *gorm.DB
-	KeyConfig             *model.KeyConfig
-	BucketName            string
-	HashMethod            string
-	HashEncryptedFile     bool
-	EncryptionMethod      string
+	CryptoService           CryptographicInterface
+	StorageService          StorageInterface
+	KMSService              KMSInterface
+	FileRepository          repository.FileRepository
+	FileLogsRepository      repository.FileLogsRepository
+	ApplicationRepository   repository.ApplicationRepository
+	AdminRepository         repository.AdminRepository
+	UploadJobRepository     repository.UploadJobRepository
+	UploadSessionRepository repository.UploadSessionRepository
+	FileVersionRepository   repository.FileVersionRepository
+	ShareLinkRepository     repository.ShareLinkRepository
+	UploadTokenRepository   repository.UploadTokenRepository
+	FolderRepository        repository.FolderRepository
+	DB                      *gorm.DB
+	KeyConfig               *model.KeyConfig
+	BucketName              string
+	HashMethod              string
+	HashEncryptedFile       bool
+	EncryptionMethod        string
+	UploadStaleAfter        time.Duration      // how long an unfinished upload may run before its object is treated as orphaned
+	UploadSessionTTL        time.Duration      // how long a resumable upload session stays open after its last part
+	ShareLinkSecret         string             // signs share link and upload tokens; derived from the KEK when empty
+	ShareLinkTTL            time.Duration      // expiry of share links created without one
+	ShareLinkMaxTTL         time.Duration      // longest expiry a share link may be given
+	UploadTokenTTL          time.Duration      // expiry of upload tokens created without one
+	UploadTokenMaxTTL       time.Duration      // longest expiry an upload token may be given
+	PublicBaseURL           string             // prefix of the share link and upload token URLs handed out, e.g. https://files.example.com
+	ExternalKMSFactory      ExternalKMSFactory // connects to the key managers of apps holding their own keys; nil disables them
 }
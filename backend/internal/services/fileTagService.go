@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxTagsPerFile = 32
+	maxTagValueLen = 256
+)
+
+// tagKeyPattern restricts tag keys to names that survive as HTTP header suffixes and query parameters
+var tagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// SetFileTags replaces the custom metadata of a file; an empty map removes all tags.
+func (c *FileService) SetFileTags(ctx context.Context, clientID, fileUID string, tags map[string]string) (map[string]string, error) {
+	if clientID == "" || fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fileRepository.SetTags(ctx, fileMetaData.FileID, tags); err != nil {
+		return nil, err
+	}
+
+	slog.Info("File tags updated", slog.String("file_id", fileMetaData.FileID), slog.Int("tags", len(tags)))
+	_ = c.saveFileLogWithMetadata(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeUpdate), map[string]interface{}{
+		"file_name": fileMetaData.File.Name,
+		"tags":      tags,
+	})
+	return tags, nil
+}
+
+// normalizeTags lowercases tag keys and checks keys, values and the number of tags.
+// A nil map stays nil so callers can tell "no tags given" from "remove all tags".
+func normalizeTags(tags map[string]string) (map[string]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	if len(tags) > maxTagsPerFile {
+		return nil, model.ErrTooManyTags
+	}
+
+	normalized := make(map[string]string, len(tags))
+	for key, value := range tags {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !tagKeyPattern.MatchString(key) || !validTagValue(value) {
+			return nil, model.ErrInvalidTag
+		}
+		if _, duplicate := normalized[key]; duplicate {
+			return nil, model.ErrInvalidTag
+		}
+		normalized[key] = value
+	}
+	return normalized, nil
+}
+
+// normalizeTagFilters lowercases the keys of tag filters and checks them like tags
+func normalizeTagFilters(filters []model.TagFilter) ([]model.TagFilter, error) {
+	normalized := make([]model.TagFilter, 0, len(filters))
+	for _, filter := range filters {
+		filter.Key = strings.ToLower(strings.TrimSpace(filter.Key))
+		if !tagKeyPattern.MatchString(filter.Key) || (filter.Value != nil && !validTagValue(*filter.Value)) {
+			return nil, model.ErrInvalidTag
+		}
+		normalized = append(normalized, filter)
+	}
+	return normalized, nil
+}
+
+func validTagValue(value string) bool {
+	if len(value) > maxTagValueLen || !utf8.ValidString(value) {
+		return false
+	}
+	return strings.IndexFunc(value, unicode.IsControl) < 0
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// ListFileVersions returns the committed versions of a file, newest first.
+func (c *FileService) ListFileVersions(ctx context.Context, clientID, fileUID string) ([]model.FileVersionResponse, error) {
+	if clientID == "" || fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := c.getFileVersions(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.FileVersionResponse, 0, len(versions))
+	for i, version := range versions {
+		result = append(result, fileVersionResponse(&version, i == 0))
+	}
+	return result, nil
+}
+
+// DownloadFileVersion decrypts a specific version of a file with the key and hash recorded for that version.
+// Versions sealed with a customer key need that key in customerKey.
+func (c *FileService) DownloadFileVersion(ctx context.Context, clientID, fileUID string, version int, customerKey []byte) (*model.FileDownloadStream, error) {
+	if clientID == "" || fileUID == "" || version <= 0 {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := c.getFileVersions(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
+	target, current, err := findFileVersion(versions, version)
+	if err != nil {
+		return nil, err
+	}
+
+	objectName := createFileName(fileMetaData.FileID)
+	var encrypted io.ReadCloser
+	switch {
+	case current:
+		encrypted, err = c.storageService.DownloadFileStream(ctx, c.fileBucket(&fileMetaData.File), objectName)
+	case isStoredVersion(target.VersionID):
+		encrypted, err = c.storageService.DownloadFileVersionStream(ctx, c.fileBucket(&fileMetaData.File), objectName, target.VersionID)
+	default:
+		// Without bucket versioning the older ciphertext has been overwritten
+		return nil, model.ErrFileVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.saveFileLogWithMetadata(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeDownload), map[string]interface{}{
+		"file_name": target.Name,
+		"version":   target.Version,
+	})
+
+	versionMetaData := fileVersionMetadata(target, validatedAppID)
+	key, err := c.resolveFileKey(ctx, versionMetaData, customerKey)
+	if err != nil {
+		encrypted.Close()
+		return nil, err
+	}
+	defer secureKeyString(key)()
+
+	result := &model.FileDownloadStream{
+		FileName:     target.Name,
+		MimeType:     target.MimeType,
+		Size:         target.Size,
+		ETag:         fileETag(versionMetaData),
+		LastModified: target.CreatedAt,
+	}
+
+	// Legacy single-shot ciphertexts are decrypted in memory as before
+	if target.Format != constant.CipherFormatStream && target.Format != constant.CipherFormatMultipart {
+		encryptedFile, err := io.ReadAll(encrypted)
+		encrypted.Close()
+		if err != nil {
+			return nil, model.ErrFileDownloadFailed
+		}
+		decryptedFile, err := c.decryptFile(key, c.fileHashMethod(target.HashAlgo), target.Hash, encryptedFile, fileAssociatedData(target.HeaderVersion, target.FileID, validatedAppID))
+		if err != nil {
+			return nil, err
+		}
+		result.Content = io.NopCloser(bytes.NewReader(decryptedFile))
+		result.Size = int64(len(decryptedFile))
+		return result, nil
+	}
+
+	content, err := c.openDecryptedStream(ctx, key, versionMetaData, encrypted)
+	if err != nil {
+		encrypted.Close()
+		return nil, err
+	}
+	result.Content = content
+	return result, nil
+}
+
+// PromoteFileVersion makes an older version current again by copying its ciphertext to a new storage version.
+// The promotion is committed through the upload outbox like any other update and recorded as a new version.
+func (c *FileService) PromoteFileVersion(ctx context.Context, clientID, fileUID string, version int) (*model.FileVersionResponse, error) {
+	if clientID == "" || fileUID == "" || version <= 0 {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFileMutable(&fileMetaData.File); err != nil {
+		return nil, err
+	}
+
+	versions, err := c.getFileVersions(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
+	target, current, err := findFileVersion(versions, version)
+	if err != nil {
+		return nil, err
+	}
+	if current {
+		return nil, model.ErrFileVersionIsCurrent
+	}
+	if !isStoredVersion(target.VersionID) {
+		return nil, model.ErrFileVersionNotFound
+	}
+	// The promoted version replaces the current one, so only the difference in size counts against the quota
+	quota, err := c.checkQuota(ctx, validatedAppID, &fileMetaData.File)
+	if err != nil {
+		return nil, err
+	}
+	if err := quota.check(target.Size); err != nil {
+		return nil, err
+	}
+
+	// Record the promotion in the outbox so a failed commit removes the copied version again
+	job := &entity.UploadJobs{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        fileMetaData.FileID,
+		AppID:         validatedAppID,
+		Operation:     constant.JobOperationUpdate,
+		Status:        constant.JobStatusPending,
+		BucketName:    c.fileBucket(&fileMetaData.File),
+		ObjectName:    createFileName(fileMetaData.FileID),
+		BaseVersionID: fileMetaData.VersionID,
+		NextAttemptAt: time.Now().Add(c.uploadStaleAfter),
+	}
+	if err := c.uploadJobRepository.Create(ctx, job, nil); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.storageService.CopyFileVersion(ctx, job.BucketName, job.ObjectName, target.VersionID)
+	if err != nil {
+		slog.Error("Failed to copy file version in storage", slog.String("file_id", fileMetaData.FileID), slog.Any("error", err))
+		c.abandonUploadJob(job, err)
+		return nil, err
+	}
+	c.recordStoredVersion(ctx, job, resp)
+
+	fileToBeUpdated := &entity.Files{
+		ID:       fileMetaData.FileID,
+		Name:     target.Name,
+		AppID:    validatedAppID,
+		Size:     target.Size,
+		MimeType: target.MimeType,
+		Location: resp.Location,
+	}
+	metadataToBeUpdated := &entity.Metadata{
+		FileID:         fileMetaData.FileID,
+		Hash:           target.Hash,
+		EncHash:        target.EncHash,
+		KeyUID:         target.KeyUID,
+		EncKey:         target.EncKey,
+		KeyFingerprint: target.KeyFingerprint,
+		KeyAlgo:        target.KeyAlgo,
+		HashAlgo:       target.HashAlgo,
+		Format:         target.Format,
+		HeaderVersion:  target.HeaderVersion,
+		VersionID:      resp.VersionID,
+	}
+	if err := c.commitUploadJob(ctx, job, fileToBeUpdated, metadataToBeUpdated); err != nil {
+		return nil, err
+	}
+
+	_ = c.saveFileLogWithMetadata(ctx, validatedAppID, fileMetaData.FileID, constant.ActorTypeClient, string(constant.ActionTypeUpdate), map[string]interface{}{
+		"file_name":        target.Name,
+		"promoted_version": target.Version,
+	})
+
+	versions, err = c.fileVersionRepository.GetByFileID(ctx, fileMetaData.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[0].VersionID != resp.VersionID {
+		// The commit was deferred to the upload worker; report the version being promoted
+		response := fileVersionResponse(target, false)
+		return &response, nil
+	}
+	response := fileVersionResponse(&versions[0], true)
+	return &response, nil
+}
+
+// getFileVersions returns the versions of a file, newest first.
+// Files committed before versions were tracked report their current state as version 1.
+func (c *FileService) getFileVersions(ctx context.Context, fileMetaData *entity.Metadata) ([]entity.FileVersions, error) {
+	versions, err := c.fileVersionRepository.GetByFileID(ctx, fileMetaData.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		return versions, nil
+	}
+
+	return []entity.FileVersions{{
+		FileID:         fileMetaData.FileID,
+		Version:        1,
+		VersionID:      fileMetaData.VersionID,
+		Name:           fileMetaData.File.Name,
+		MimeType:       fileMetaData.File.MimeType,
+		Size:           fileMetaData.File.Size,
+		Hash:           fileMetaData.Hash,
+		EncHash:        fileMetaData.EncHash,
+		KeyUID:         fileMetaData.KeyUID,
+		EncKey:         fileMetaData.EncKey,
+		KeyFingerprint: fileMetaData.KeyFingerprint,
+		KeyAlgo:        fileMetaData.KeyAlgo,
+		HashAlgo:       fileMetaData.HashAlgo,
+		Format:         fileMetaData.Format,
+		HeaderVersion:  fileMetaData.HeaderVersion,
+		CreatedAt:      fileMetaData.UpdatedAt,
+	}}, nil
+}
+
+// findFileVersion picks a version out of a newest-first list and reports whether it is the current one
+func findFileVersion(versions []entity.FileVersions, version int) (*entity.FileVersions, bool, error) {
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i], i == 0, nil
+		}
+	}
+	return nil, false, model.ErrFileVersionNotFound
+}
+
+// isStoredVersion reports whether a storage version ID addresses a retained object version
+func isStoredVersion(versionID string) bool {
+	return versionID != "" && versionID != "null"
+}
+
+// fileVersionMetadata presents a version as metadata so the regular key unwrapping and decryption apply
+func fileVersionMetadata(version *entity.FileVersions, appID string) *entity.Metadata {
+	return &entity.Metadata{
+		FileID:         version.FileID,
+		Hash:           version.Hash,
+		EncHash:        version.EncHash,
+		KeyUID:         version.KeyUID,
+		EncKey:         version.EncKey,
+		KeyFingerprint: version.KeyFingerprint,
+		KeyAlgo:        version.KeyAlgo,
+		HashAlgo:       version.HashAlgo,
+		Format:         version.Format,
+		HeaderVersion:  version.HeaderVersion,
+		VersionID:      version.VersionID,
+		File:           entity.Files{ID: version.FileID, AppID: appID},
+	}
+}
+
+func fileVersionResponse(version *entity.FileVersions, current bool) model.FileVersionResponse {
+	return model.FileVersionResponse{
+		Version:   version.Version,
+		Name:      version.Name,
+		Size:      version.Size,
+		MimeType:  version.MimeType,
+		Hash:      version.Hash,
+		Current:   current,
+		CreatedAt: version.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
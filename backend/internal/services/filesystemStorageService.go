@@ -0,0 +1,842 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+)
+
+// FilesystemStorageService implements the StorageInterface on a local directory, versioning objects and enforcing
+// legal holds and retention the way a versioned MinIO bucket with object lock does. Files are laid out as
+//
+//	<root>/<bucket>/objects/<escaped object name>/<version ID>.data  contents of a version
+//	<root>/<bucket>/objects/<escaped object name>/<version ID>.json  sidecar describing the version
+//	<root>/<bucket>/uploads/<upload ID>/                             parts of an unfinished multipart upload
+//	<root>/<bucket>/tmp/                                              files being written
+//
+// A version exists once its sidecar does. Contents and sidecars are written to a temporary file, synced and renamed
+// into place, so a crash never leaves a partial version behind. Only one process should use a root directory.
+type FilesystemStorageService struct {
+	root  string
+	mu    sync.Mutex // serialises changes to the versions of objects
+	clock versionClock
+}
+
+// NewFilesystemStorageService creates a filesystem storage backend rooted at rootDir, creating the directory if needed.
+func NewFilesystemStorageService(rootDir string) (StorageInterface, error) {
+	if rootDir == "" {
+		return nil, fmt.Errorf("storage path cannot be empty")
+	}
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage path %s: %w", rootDir, err)
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage path %s: %w", root, err)
+	}
+	return &FilesystemStorageService{root: root}, nil
+}
+
+// UploadFile stores the contents of the reader as the latest version of a file.
+// A size of -1 reads the reader to its end.
+func (s *FilesystemStorageService) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "PutObject", bucketName, fileName)
+	defer span.End()
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"file.size": fileSize,
+	})
+
+	resp, err := s.put(ctx, bucketName, fileName, func(w io.Writer) error {
+		reader := io.Reader(contextReader{ctx: ctx, reader: file})
+		if fileSize >= 0 {
+			reader = io.LimitReader(reader, fileSize)
+		}
+		written, err := io.Copy(w, reader)
+		if err != nil {
+			return err
+		}
+		return checkedSize(fileName, fileSize, written)
+	})
+	if err != nil {
+		slog.Error("failed to upload file",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+		)
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("upload failed for file %s: %w", fileName, err)
+	}
+	return resp, nil
+}
+
+// put writes the contents produced by write as the latest version of a file
+func (s *FilesystemStorageService) put(ctx context.Context, bucketName, fileName string, write func(w io.Writer) error) (*model.StorageTransactionResponse, error) {
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath, sum, err := s.stage(bucketName, write)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commitLocked(bucketName, fileName, dir, tmpPath, sum)
+}
+
+// commitLocked moves staged contents into place as a new version of a file; the caller holds the lock
+func (s *FilesystemStorageService) commitLocked(bucketName, fileName, dir, tmpPath string, sum *checksumWriter) (*model.StorageTransactionResponse, error) {
+	versionID, err := s.clock.next()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+	dataPath := filepath.Join(dir, versionID+".data")
+	if err := os.Rename(tmpPath, dataPath); err != nil {
+		return nil, fmt.Errorf("failed to store version contents: %w", err)
+	}
+
+	version := storedObjectVersion{
+		VersionID:      versionID,
+		Size:           sum.size,
+		ChecksumSHA256: sum.checksum(),
+		LastModified:   time.Now().UTC(),
+	}
+	if err := s.writeSidecar(bucketName, dir, version); err != nil {
+		_ = os.Remove(dataPath)
+		return nil, err
+	}
+	return version.response(s.location(bucketName, fileName)), nil
+}
+
+// DownloadFile returns the contents of the latest version of a file.
+func (s *FilesystemStorageService) DownloadFile(ctx context.Context, bucketName, fileName string) ([]byte, error) {
+	object, err := s.DownloadFileStream(ctx, bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", fileName, err)
+	}
+	return content, nil
+}
+
+// DownloadFileStream opens the latest version of a file. The caller must close the returned reader.
+func (s *FilesystemStorageService) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	return s.DownloadFileVersionStream(ctx, bucketName, fileName, "")
+}
+
+// DownloadFileVersionStream opens a specific version of a file; an empty or "null" version ID opens the latest.
+// The caller must close the returned reader.
+func (s *FilesystemStorageService) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	object, _, err := s.open(bucketName, fileName, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("download failed for file %s version %s: %w", fileName, versionID, err)
+	}
+	return object, nil
+}
+
+// DownloadFileRange opens bytes [offset, offset+length) of the latest version of a file.
+// The caller must close the returned reader.
+func (s *FilesystemStorageService) DownloadFileRange(ctx context.Context, bucketName, fileName string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid range %d+%d for file %s", offset, length, fileName)
+	}
+	object, version, err := s.open(bucketName, fileName, "")
+	if err != nil {
+		return nil, fmt.Errorf("ranged download failed for file %s: %w", fileName, err)
+	}
+	if offset >= version.Size {
+		object.Close()
+		return nil, fmt.Errorf("%w: %d+%d for file %s", model.ErrRangeNotSatisfiable, offset, length, fileName)
+	}
+	if _, err := object.Seek(offset, io.SeekStart); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("ranged download failed for file %s: %w", fileName, err)
+	}
+	return limitedReadCloser{Reader: io.LimitReader(object, length), Closer: object}, nil
+}
+
+// open opens the contents of a version of a file; an empty or "null" version ID opens the latest
+func (s *FilesystemStorageService) open(bucketName, fileName, versionID string) (*os.File, *storedObjectVersion, error) {
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := readVersions(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	i, err := findVersion(versions, fileName, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	object, err := os.Open(filepath.Join(dir, versions[i].VersionID+".data"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The version was removed after it was listed
+			return nil, nil, fmt.Errorf("%w: %s version %s", model.ErrFileVersionNotFound, fileName, versions[i].VersionID)
+		}
+		return nil, nil, err
+	}
+	return object, &versions[i], nil
+}
+
+// UpdateFile stores the contents of the reader as a new version of a file.
+func (s *FilesystemStorageService) UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	return s.UploadFile(ctx, bucketName, fileName, file, fileSize)
+}
+
+// Exists reports whether a file has a latest version that is not a delete marker, and describes that version.
+func (s *FilesystemStorageService) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
+	if fileName == "" {
+		return false, nil, fmt.Errorf("object name cannot be empty")
+	}
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return false, nil, err
+	}
+	versions, err := readVersions(dir)
+	if err != nil {
+		return false, nil, fmt.Errorf("error checking file %s existence: %w", fileName, err)
+	}
+	latest := liveVersion(versions)
+	if latest == nil {
+		return false, nil, nil
+	}
+	return true, latest.response(s.location(bucketName, fileName)), nil
+}
+
+// ListFiles lists the files in a bucket whose latest version is not a delete marker.
+func (s *FilesystemStorageService) ListFiles(ctx context.Context, bucketName string) ([]string, error) {
+	bucketDir, err := s.existingBucketDir(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(bucketDir, "objects"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		fileName, err := url.PathUnescape(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		versions, err := readVersions(filepath.Join(bucketDir, "objects", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error listing files: %w", err)
+		}
+		if liveVersion(versions) != nil {
+			files = append(files, fileName)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// GetFileMetadata returns the content type and size of the latest version of a file.
+func (s *FilesystemStorageService) GetFileMetadata(ctx context.Context, bucketName, fileName string) (map[string]string, error) {
+	exists, version, err := s.Exists(ctx, bucketName, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting metadata for file %s: %w", fileName, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("error getting metadata for file %s: %w", fileName, model.ErrFileNotFound)
+	}
+	return map[string]string{
+		"content-type": "application/octet-stream",
+		"size":         fmt.Sprintf("%d", version.Size),
+	}, nil
+}
+
+// DeleteFile hides a file behind a delete marker; its versions are kept and can be restored.
+func (s *FilesystemStorageService) DeleteFile(ctx context.Context, bucketName, objectName string) error {
+	if _, err := s.existingBucketDir(bucketName); err != nil {
+		return fmt.Errorf("soft delete failed for object %s: %w", objectName, err)
+	}
+	dir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versionID, err := s.clock.next()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("soft delete failed for object %s: %w", objectName, err)
+	}
+	marker := storedObjectVersion{VersionID: versionID, LastModified: time.Now().UTC(), DeleteMarker: true}
+	if err := s.writeSidecar(bucketName, dir, marker); err != nil {
+		return fmt.Errorf("soft delete failed for object %s: %w", objectName, err)
+	}
+	return nil
+}
+
+// DeleteFileVersion permanently removes one version of a file, unless a legal hold or retention locks it.
+// Removing a version that does not exist succeeds; without a version ID the file is hidden behind a delete marker,
+// as in a versioned bucket.
+func (s *FilesystemStorageService) DeleteFileVersion(ctx context.Context, bucketName, objectName, versionID string) error {
+	if versionID == "" {
+		return s.DeleteFile(ctx, bucketName, objectName)
+	}
+	dir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := readVersions(dir)
+	if err != nil {
+		return fmt.Errorf("delete failed for object %s version %s: %w", objectName, versionID, err)
+	}
+	for i := range versions {
+		if versions[i].VersionID != versionID {
+			continue
+		}
+		if versions[i].locked(time.Now()) {
+			return fmt.Errorf("delete failed for object %s version %s: %w", objectName, versionID, model.ErrObjectLocked)
+		}
+		// The sidecar goes first, so a version is never listed without its contents
+		if err := os.Remove(filepath.Join(dir, versionID+".json")); err != nil {
+			return fmt.Errorf("delete failed for object %s version %s: %w", objectName, versionID, err)
+		}
+		if err := os.Remove(filepath.Join(dir, versionID+".data")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to remove contents of deleted version", "error", err, "bucket", bucketName, "object", objectName, "version", versionID)
+		}
+		if len(versions) == 1 {
+			_ = os.Remove(dir)
+		}
+		return nil
+	}
+	return nil
+}
+
+// CopyFileVersion copies an older version of a file as its latest version, leaving the older version in place.
+func (s *FilesystemStorageService) CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "CopyObject", bucketName, fileName)
+	defer span.End()
+
+	object, _, err := s.open(bucketName, fileName, versionID)
+	if err != nil {
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("copy failed for file %s version %s: %w", fileName, versionID, err)
+	}
+	defer object.Close()
+
+	resp, err := s.put(ctx, bucketName, fileName, func(w io.Writer) error {
+		_, err := io.Copy(w, contextReader{ctx: ctx, reader: object})
+		return err
+	})
+	if err != nil {
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("copy failed for file %s version %s: %w", fileName, versionID, err)
+	}
+	return resp, nil
+}
+
+// RestoreFile makes a version the current contents of a file again, removing the delete markers that hide it.
+func (s *FilesystemStorageService) RestoreFile(ctx context.Context, bucketName, fileName, versionID string) error {
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := readVersions(dir)
+	if err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	markers, copyVersion, err := restorePlan(versions, fileName, versionID)
+	if err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	for _, marker := range markers {
+		if err := os.Remove(filepath.Join(dir, marker+".json")); err != nil {
+			return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+		}
+	}
+	if !copyVersion {
+		return nil
+	}
+
+	object, err := os.Open(filepath.Join(dir, versionID+".data"))
+	if err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	defer object.Close()
+	tmpPath, sum, err := s.stage(bucketName, func(w io.Writer) error {
+		_, err := io.Copy(w, object)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	defer os.Remove(tmpPath)
+	if _, err := s.commitLocked(bucketName, fileName, dir, tmpPath, sum); err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// ListFileVersion lists the IDs of all versions of a file newest first, delete markers included.
+func (s *FilesystemStorageService) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := readVersions(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing object versions: %w", err)
+	}
+	return versionIDsNewestFirst(versions), nil
+}
+
+// SetFileRetention keeps every stored version of a file from being removed until retainUntil.
+// Retention can be extended but not shortened.
+func (s *FilesystemStorageService) SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error {
+	return s.lockVersions(bucketName, fileName, func(v *storedObjectVersion) error {
+		return extendRetention(v, fileName, retainUntil)
+	})
+}
+
+// SetFileLegalHold places or releases the legal hold on every stored version of a file.
+func (s *FilesystemStorageService) SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error {
+	return s.lockVersions(bucketName, fileName, func(v *storedObjectVersion) error {
+		v.LegalHold = hold
+		return nil
+	})
+}
+
+// lockVersions applies a lock change to the sidecar of every version of a file that is not a delete marker
+func (s *FilesystemStorageService) lockVersions(bucketName, fileName string, apply func(v *storedObjectVersion) error) error {
+	dir, err := s.objectDir(bucketName, fileName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := readVersions(dir)
+	if err != nil {
+		return err
+	}
+	for i := range versions {
+		if versions[i].DeleteMarker {
+			continue
+		}
+		if err := apply(&versions[i]); err != nil {
+			return err
+		}
+		if err := s.writeSidecar(bucketName, dir, versions[i]); err != nil {
+			return fmt.Errorf("failed to lock object %s version %s: %w", fileName, versions[i].VersionID, err)
+		}
+	}
+	return nil
+}
+
+// multipartUpload is the record of a multipart upload kept in its directory
+type multipartUpload struct {
+	FileName string `json:"file_name"`
+}
+
+// CreateMultipartUpload starts a multipart upload for a file and returns its upload ID.
+func (s *FilesystemStorageService) CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error) {
+	if _, err := s.objectDir(bucketName, fileName); err != nil {
+		return "", err
+	}
+	bucketDir, err := s.existingBucketDir(bucketName)
+	if err != nil {
+		return "", fmt.Errorf("create multipart upload failed for file %s: %w", fileName, err)
+	}
+
+	s.mu.Lock()
+	uploadID, err := s.clock.next()
+	s.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(bucketDir, "uploads", uploadID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create multipart upload failed for file %s: %w", fileName, err)
+	}
+	record, err := json.Marshal(multipartUpload{FileName: fileName})
+	if err != nil {
+		return "", err
+	}
+	if err := s.writeAtomic(bucketName, filepath.Join(dir, "upload.json"), func(w io.Writer) error {
+		_, err := w.Write(record)
+		return err
+	}); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("create multipart upload failed for file %s: %w", fileName, err)
+	}
+	return uploadID, nil
+}
+
+// UploadPart stores one part of a multipart upload and returns its ETag.
+func (s *FilesystemStorageService) UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "PutObjectPart", bucketName, fileName)
+	defer span.End()
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"part.number": partNumber,
+		"part.size":   partSize,
+	})
+
+	dir, err := s.uploadDir(bucketName, fileName, uploadID)
+	if err != nil {
+		helper.RecordError(span, err)
+		return "", err
+	}
+	etag := newPartHash()
+	err = s.writeAtomic(bucketName, filepath.Join(dir, partFileName(partNumber)), func(w io.Writer) error {
+		reader := io.Reader(contextReader{ctx: ctx, reader: part})
+		if partSize >= 0 {
+			reader = io.LimitReader(reader, partSize)
+		}
+		written, err := io.Copy(io.MultiWriter(w, etag), reader)
+		if err != nil {
+			return err
+		}
+		return checkedSize(fileName, partSize, written)
+	})
+	if err != nil {
+		helper.RecordError(span, err)
+		return "", fmt.Errorf("upload failed for part %d of file %s: %w", partNumber, fileName, err)
+	}
+	return partETag(etag), nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts, in the given order, into the latest version of the file.
+func (s *FilesystemStorageService) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "CompleteMultipartUpload", bucketName, fileName)
+	defer span.End()
+
+	dir, err := s.uploadDir(bucketName, fileName, uploadID)
+	if err != nil {
+		helper.RecordError(span, err)
+		return nil, err
+	}
+	resp, err := s.put(ctx, bucketName, fileName, func(w io.Writer) error {
+		for _, part := range parts {
+			if err := copyPart(ctx, w, filepath.Join(dir, partFileName(part.PartNumber)), part); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("complete multipart upload failed for file %s: %w", fileName, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("failed to remove parts of completed upload", "error", err, "bucket", bucketName, "file", fileName, "upload_id", uploadID)
+	}
+	return resp, nil
+}
+
+// copyPart appends an uploaded part to w, checking it is the part its ETag names
+func copyPart(ctx context.Context, w io.Writer, path string, part model.StoragePart) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: part %d", model.ErrUploadPartsMissing, part.PartNumber)
+		}
+		return err
+	}
+	defer file.Close()
+
+	etag := newPartHash()
+	if _, err := io.Copy(io.MultiWriter(w, etag), contextReader{ctx: ctx, reader: file}); err != nil {
+		return err
+	}
+	if partETag(etag) != part.ETag {
+		return fmt.Errorf("%w: part %d does not match its ETag", model.ErrUploadPartsMissing, part.PartNumber)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and any parts already uploaded.
+func (s *FilesystemStorageService) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	dir, err := s.uploadDir(bucketName, fileName, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("abort multipart upload failed for file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// uploadDir returns the directory of a multipart upload of a file
+func (s *FilesystemStorageService) uploadDir(bucketName, fileName, uploadID string) (string, error) {
+	bucketDir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", fmt.Errorf("%w: %s", model.ErrMultipartNotFound, uploadID)
+	}
+	dir := filepath.Join(bucketDir, "uploads", uploadID)
+	record, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", model.ErrMultipartNotFound, uploadID)
+		}
+		return "", err
+	}
+	var upload multipartUpload
+	if err := json.Unmarshal(record, &upload); err != nil {
+		return "", fmt.Errorf("failed to read multipart upload %s: %w", uploadID, err)
+	}
+	if upload.FileName != fileName {
+		return "", fmt.Errorf("%w: %s", model.ErrMultipartNotFound, uploadID)
+	}
+	return dir, nil
+}
+
+func partFileName(partNumber int) string {
+	return fmt.Sprintf("part-%05d", partNumber)
+}
+
+// CreateBucket creates the directory of a bucket, keeping an existing one as it is.
+func (s *FilesystemStorageService) CreateBucket(ctx context.Context, bucketName string) error {
+	tracer := helper.GetTracingHelper()
+	_, span := tracer.StartStorageSpan(ctx, "MakeBucket", bucketName, "")
+	defer span.End()
+
+	bucketDir, err := s.bucketDir(bucketName)
+	if err != nil {
+		helper.RecordError(span, err)
+		return err
+	}
+	for _, dir := range []string{"objects", "uploads", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(bucketDir, dir), 0o700); err != nil {
+			helper.RecordError(span, err)
+			return fmt.Errorf("create bucket failed for %s: %w", bucketName, err)
+		}
+	}
+	return nil
+}
+
+// RemoveBucket removes a bucket once no object version is left in it, along with its unfinished multipart uploads.
+// A bucket that does not exist is already removed.
+func (s *FilesystemStorageService) RemoveBucket(ctx context.Context, bucketName string) error {
+	tracer := helper.GetTracingHelper()
+	_, span := tracer.StartStorageSpan(ctx, "RemoveBucket", bucketName, "")
+	defer span.End()
+
+	bucketDir, err := s.bucketDir(bucketName)
+	if err != nil {
+		helper.RecordError(span, err)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(bucketDir, "objects"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		helper.RecordError(span, err)
+		return fmt.Errorf("remove bucket failed for %s: %w", bucketName, err)
+	}
+	for _, entry := range entries {
+		versions, err := readVersions(filepath.Join(bucketDir, "objects", entry.Name()))
+		if err != nil {
+			helper.RecordError(span, err)
+			return fmt.Errorf("remove bucket failed for %s: %w", bucketName, err)
+		}
+		if len(versions) > 0 {
+			return model.ErrBucketNotEmpty
+		}
+	}
+	if err := os.RemoveAll(bucketDir); err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("remove bucket failed for %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// bucketDir returns the directory of a bucket, refusing names that are not valid bucket names
+func (s *FilesystemStorageService) bucketDir(bucketName string) (string, error) {
+	if err := s3utils.CheckValidBucketName(bucketName); err != nil {
+		return "", fmt.Errorf("%w: %s", model.ErrInvalidBucketName, err.Error())
+	}
+	return filepath.Join(s.root, bucketName), nil
+}
+
+// existingBucketDir returns the directory of a bucket that has been created
+func (s *FilesystemStorageService) existingBucketDir(bucketName string) (string, error) {
+	dir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", model.ErrBucketNotFound, bucketName)
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// objectDir returns the directory holding the versions of an object. Object names are escaped into a single
+// path element, so no name reaches outside its bucket.
+func (s *FilesystemStorageService) objectDir(bucketName, fileName string) (string, error) {
+	bucketDir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return "", err
+	}
+	name := url.PathEscape(fileName)
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid object name %q", fileName)
+	}
+	return filepath.Join(bucketDir, "objects", name), nil
+}
+
+// stage writes contents to a synced temporary file in the bucket, ready to be renamed into place
+func (s *FilesystemStorageService) stage(bucketName string, write func(w io.Writer) error) (_ string, _ *checksumWriter, err error) {
+	bucketDir, err := s.existingBucketDir(bucketName)
+	if err != nil {
+		return "", nil, err
+	}
+	tmpDir := filepath.Join(bucketDir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0o700); err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "stage-*")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	sum := newChecksumWriter()
+	if err := write(io.MultiWriter(tmp, sum)); err != nil {
+		return "", nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", nil, err
+	}
+	return tmp.Name(), sum, nil
+}
+
+// writeAtomic replaces the file at path with the contents produced by write
+func (s *FilesystemStorageService) writeAtomic(bucketName, path string, write func(w io.Writer) error) error {
+	tmpPath, _, err := s.stage(bucketName, write)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeSidecar writes the sidecar describing a version, which makes the version visible
+func (s *FilesystemStorageService) writeSidecar(bucketName, dir string, version storedObjectVersion) error {
+	sidecar, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	return s.writeAtomic(bucketName, filepath.Join(dir, version.VersionID+".json"), func(w io.Writer) error {
+		_, err := w.Write(sidecar)
+		return err
+	})
+}
+
+func (s *FilesystemStorageService) location(bucketName, fileName string) string {
+	return fmt.Sprintf("file://%s/%s/%s", filepath.ToSlash(s.root), bucketName, fileName)
+}
+
+// readVersions reads the sidecars of an object oldest first; an object without a directory has no versions
+func readVersions(dir string) ([]storedObjectVersion, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var versions []storedObjectVersion
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		sidecar, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // removed while the directory was read
+			}
+			return nil, err
+		}
+		var version storedObjectVersion
+		if err := json.Unmarshal(sidecar, &version); err != nil {
+			return nil, fmt.Errorf("failed to read version sidecar %s: %w", entry.Name(), err)
+		}
+		versions = append(versions, version)
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// syncDir flushes a directory, so the files renamed into it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"errors"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// filePlacement is the folder and path a new file takes in the folder tree
+type filePlacement struct {
+	folderID *string
+	path     string
+}
+
+// CreateFolder creates a folder at an absolute path, creating missing parent folders along the way.
+func (c *FileService) CreateFolder(ctx context.Context, clientID, folderPath string) (*model.PathEntryResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	folderPath, err = normalizeFolderPath(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	if folderPath == "" {
+		return nil, model.ErrPathConflict
+	}
+	if taken, err := c.folderRepository.PathTaken(ctx, validatedAppID, folderPath); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, model.ErrPathConflict
+	}
+
+	folder, err := c.ensureFolder(ctx, validatedAppID, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Folder created", slog.String("app_id", validatedAppID), slog.String("path", folder.Path))
+	response := folderEntryResponse(folder)
+	return &response, nil
+}
+
+// GetPath looks up the file or folder at an absolute path. Folders are returned with their direct children;
+// the root folder is addressed by "/".
+func (c *FileService) GetPath(ctx context.Context, clientID, entryPath string) (*model.PathEntryResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	entryPath, err = normalizeFolderPath(entryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var folderID *string
+	response := model.PathEntryResponse{Type: constant.PathTypeFolder, Name: "/", Path: "/"}
+	if entryPath != "" {
+		folder, file, err := c.lookupPath(ctx, validatedAppID, entryPath)
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			response = fileEntryResponse(file)
+			return &response, nil
+		}
+		folderID = &folder.ID
+		response = folderEntryResponse(folder)
+	}
+
+	folders, files, err := c.folderRepository.ListChildren(ctx, validatedAppID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	response.Children = make([]model.PathEntryResponse, 0, len(folders)+len(files))
+	for i := range folders {
+		response.Children = append(response.Children, folderEntryResponse(&folders[i]))
+	}
+	for i := range files {
+		response.Children = append(response.Children, fileEntryResponse(&files[i]))
+	}
+	return &response, nil
+}
+
+// MovePath moves or renames the file or folder at source to destination. Folders are moved with everything
+// below them, and missing parent folders of the destination are created.
+func (c *FileService) MovePath(ctx context.Context, clientID, source, destination string) (*model.PathEntryResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	if source, err = normalizeFolderPath(source); err != nil {
+		return nil, err
+	}
+	if destination, err = normalizeFolderPath(destination); err != nil {
+		return nil, err
+	}
+	if source == "" || destination == "" {
+		return nil, model.ErrInvalidFolderPath
+	}
+
+	folder, file, err := c.lookupPath(ctx, validatedAppID, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if file != nil {
+		response, err := c.placeFile(ctx, validatedAppID, file, destination)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("File moved", slog.String("file_id", file.ID), slog.String("from", source), slog.String("to", destination))
+		return response, nil
+	}
+
+	if destination == source {
+		response := folderEntryResponse(folder)
+		return &response, nil
+	}
+	if strings.HasPrefix(destination, source+"/") {
+		return nil, model.ErrInvalidMove
+	}
+	parent, err := c.ensureFolder(ctx, validatedAppID, path.Dir(destination))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.folderRepository.Move(ctx, folder, folderEntityID(parent), destination); err != nil {
+		return nil, err
+	}
+	slog.Info("Folder moved", slog.String("folder_id", folder.ID), slog.String("from", source), slog.String("to", destination))
+	response := folderEntryResponse(folder)
+	return &response, nil
+}
+
+// PlaceFile puts a file into the folder tree at an absolute path, or moves it there if it already has one.
+func (c *FileService) PlaceFile(ctx context.Context, clientID, fileUID, filePath string) (*model.PathEntryResponse, error) {
+	if fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	if filePath, err = normalizeFolderPath(filePath); err != nil {
+		return nil, err
+	}
+	if filePath == "" {
+		return nil, model.ErrInvalidFolderPath
+	}
+
+	file, err := c.fileRepository.GetByID(ctx, fileUID)
+	if err != nil {
+		return nil, err
+	}
+	if file.AppID != validatedAppID || file.Status != constant.FileStatusStored {
+		return nil, model.ErrFileNotFound
+	}
+	return c.placeFile(ctx, validatedAppID, file, filePath)
+}
+
+// DeletePath deletes the file or folder at an absolute path. A folder that still holds anything is only
+// deleted when recursive is set, in which case every file below it is deleted the same way as DeleteFile.
+func (c *FileService) DeletePath(ctx context.Context, clientID, entryPath string, recursive bool) error {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return err
+	}
+
+	if entryPath, err = normalizeFolderPath(entryPath); err != nil {
+		return err
+	}
+	if entryPath == "" {
+		return model.ErrInvalidFolderPath
+	}
+
+	folder, file, err := c.lookupPath(ctx, validatedAppID, entryPath)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		return c.deleteFile(ctx, validatedAppID, file.ID)
+	}
+
+	if !recursive {
+		folders, files, err := c.folderRepository.ListChildren(ctx, validatedAppID, &folder.ID)
+		if err != nil {
+			return err
+		}
+		if len(folders) > 0 || len(files) > 0 {
+			return model.ErrFolderNotEmpty
+		}
+	} else {
+		files, err := c.folderRepository.ListFilesUnder(ctx, validatedAppID, folder.Path)
+		if err != nil {
+			return err
+		}
+		// Stop at the first failure; deleted files are gone from the tree, so repeating the call picks up the rest
+		for _, file := range files {
+			if err := c.deleteFile(ctx, validatedAppID, file.ID); err != nil {
+				slog.Error("Failed to delete file in folder", slog.String("file_id", file.ID), slog.String("path", file.Path), slog.Any("error", err))
+				return err
+			}
+		}
+	}
+
+	if err := c.folderRepository.DeleteTree(ctx, validatedAppID, folder.Path); err != nil {
+		return err
+	}
+	slog.Info("Folder deleted", slog.String("app_id", validatedAppID), slog.String("path", folder.Path), slog.Bool("recursive", recursive))
+	return nil
+}
+
+// lookupPath resolves a normalized, non-root path to either a folder or a file of the app
+func (c *FileService) lookupPath(ctx context.Context, appID, entryPath string) (*entity.Folders, *entity.Files, error) {
+	folder, err := c.folderRepository.GetByPath(ctx, appID, entryPath)
+	if err == nil {
+		return folder, nil, nil
+	}
+	if !errors.Is(err, model.ErrFolderNotFound) {
+		return nil, nil, err
+	}
+
+	file, err := c.folderRepository.GetFileByPath(ctx, appID, entryPath)
+	if errors.Is(err, model.ErrFileNotFound) {
+		return nil, nil, model.ErrPathNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, file, nil
+}
+
+// ensureFolder returns the folder at a normalized path, creating it and any missing parents.
+// The root is represented by a nil folder.
+func (c *FileService) ensureFolder(ctx context.Context, appID, folderPath string) (*entity.Folders, error) {
+	if folderPath == "" || folderPath == "/" {
+		return nil, nil
+	}
+
+	var parent *entity.Folders
+	current := ""
+	for _, name := range strings.Split(strings.TrimPrefix(folderPath, "/"), "/") {
+		current += "/" + name
+		folder, err := c.folderRepository.GetByPath(ctx, appID, current)
+		if errors.Is(err, model.ErrFolderNotFound) {
+			folder = &entity.Folders{
+				ID:       helper.GenerateCustomUUID().String(),
+				AppID:    appID,
+				ParentID: folderEntityID(parent),
+				Name:     name,
+				Path:     current,
+			}
+			err = c.folderRepository.Create(ctx, folder)
+			if errors.Is(err, model.ErrPathConflict) {
+				// Either another request created the folder first, or a file has the path
+				folder, err = c.folderRepository.GetByPath(ctx, appID, current)
+				if errors.Is(err, model.ErrFolderNotFound) {
+					err = model.ErrPathConflict
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent = folder
+	}
+	return parent, nil
+}
+
+// prepareFilePlacement creates the folder a new file goes into and checks that its path is still free
+func (c *FileService) prepareFilePlacement(ctx context.Context, appID, folderPath, fileName string) (*filePlacement, error) {
+	folderPath, err := normalizeFolderPath(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	if fileName == "" || fileName == "." || fileName == ".." || len(fileName) > maxPathNameLength || strings.ContainsAny(fileName, "/\\\x00") {
+		return nil, model.ErrInvalidFolderPath
+	}
+
+	filePath := folderPath + "/" + fileName
+	if len(filePath) > maxFolderPathLength {
+		return nil, model.ErrInvalidFolderPath
+	}
+	if taken, err := c.folderRepository.PathTaken(ctx, appID, filePath); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, model.ErrPathConflict
+	}
+
+	folder, err := c.ensureFolder(ctx, appID, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	return &filePlacement{folderID: folderEntityID(folder), path: filePath}, nil
+}
+
+// placeUploadedFile puts a freshly committed file at its prepared path. If another request took the path
+// in the meantime, the upload is deleted again so the caller is not left with a file it cannot see.
+func (c *FileService) placeUploadedFile(ctx context.Context, file *entity.Files, placement *filePlacement) error {
+	err := c.folderRepository.PlaceFile(ctx, file.AppID, file.ID, placement.folderID, placement.path)
+	if err != nil {
+		slog.Warn("Failed to place uploaded file, removing it", slog.String("file_id", file.ID), slog.String("path", placement.path), slog.Any("error", err))
+		if deleteErr := c.deleteFile(context.Background(), file.AppID, file.ID); deleteErr != nil {
+			slog.Error("Failed to remove unplaced file", slog.String("file_id", file.ID), slog.Any("error", deleteErr))
+		}
+		return err
+	}
+	file.FolderID = placement.folderID
+	file.Path = placement.path
+	return nil
+}
+
+// placeFile moves a file of the app to a normalized path, creating missing parent folders
+func (c *FileService) placeFile(ctx context.Context, appID string, file *entity.Files, filePath string) (*model.PathEntryResponse, error) {
+	if filePath == file.Path {
+		response := fileEntryResponse(file)
+		return &response, nil
+	}
+
+	folder, err := c.ensureFolder(ctx, appID, path.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.folderRepository.PlaceFile(ctx, appID, file.ID, folderEntityID(folder), filePath); err != nil {
+		return nil, err
+	}
+
+	file.FolderID = folderEntityID(folder)
+	file.Path = filePath
+	file.Name = path.Base(filePath)
+	response := fileEntryResponse(file)
+	return &response, nil
+}
+
+// folderEntityID returns the ID of a folder, or nil for the root
+func folderEntityID(folder *entity.Folders) *string {
+	if folder == nil {
+		return nil
+	}
+	return &folder.ID
+}
+
+func folderEntryResponse(folder *entity.Folders) model.PathEntryResponse {
+	return model.PathEntryResponse{
+		Type:      constant.PathTypeFolder,
+		ID:        folder.ID,
+		Name:      folder.Name,
+		Path:      folder.Path,
+		UpdatedAt: folder.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func fileEntryResponse(file *entity.Files) model.PathEntryResponse {
+	return model.PathEntryResponse{
+		Type:      constant.PathTypeFile,
+		ID:        file.ID,
+		Name:      file.Name,
+		Path:      file.Path,
+		Size:      file.Size,
+		MimeType:  file.MimeType,
+		UpdatedAt: file.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
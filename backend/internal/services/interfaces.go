@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/model"
+	"hash"
+	"io"
 	"mime/multipart"
+	"time"
 
 	"github.com/tink-crypto/tink-go/v2/keyset"
 )
@@ -25,6 +29,22 @@ type ApplicationInterface interface {
 	RecoverApp(ctx context.Context, appUID string) (*string, error)
 	// RotateSecret rotates the secret for the specified application UID.
 	RotateSecret(ctx context.Context, appUID string) (*model.AppDetailResponse, error)
+	// SetKeyProvider selects the internal or an external (hold your own key) key manager for the application.
+	SetKeyProvider(ctx context.Context, appUID string, request model.KeyProviderRequest) (*model.KeyProviderResponse, error)
+	// SetLifecyclePolicy sets the file TTL and the deleted file retention of the application.
+	SetLifecyclePolicy(ctx context.Context, appUID string, request model.LifecyclePolicyRequest) (*model.LifecyclePolicyResponse, error)
+	// SetQuota sets the storage, file count and file size limits of the application.
+	SetQuota(ctx context.Context, appUID string, request model.AppQuotaRequest) (*model.AppUsageResponse, error)
+	// GetUsage returns the bytes and files the application stores against its limits.
+	GetUsage(ctx context.Context, appUID string) (*model.AppUsageResponse, error)
+	// SetClientConfig sets the bucket, hash method and encryption method used for new files of the application.
+	SetClientConfig(ctx context.Context, appUID string, request model.ClientConfigRequest) (*model.ClientConfigResponse, error)
+	// GetClientConfig returns the bucket, hash method and encryption method of the application.
+	GetClientConfig(ctx context.Context, appUID string) (*model.ClientConfigResponse, error)
+	// MigrateAppBucket asks for the files of the application kept in other buckets to be moved into its own bucket.
+	MigrateAppBucket(ctx context.Context, appUID string) (*model.AppBucketResponse, error)
+	// GetAppBucket returns the bucket of the application and how many of its files are still kept elsewhere.
+	GetAppBucket(ctx context.Context, appUID string) (*model.AppBucketResponse, error)
 }
 
 // AdminInterface defines the contract for administrative user management operations.
@@ -51,30 +71,147 @@ type AdminInterface interface {
 // FileInterface defines the contract for file management operations.
 // It provides methods for uploading, downloading, encrypting, decrypting, updating, deleting, recovering files, managing file metadata, re-keying, and listing files and logs.
 type FileInterface interface {
-	// Uploads a file and returns a unique file UID that can be used to download the file
-	UploadFile(ctx context.Context, clientID, fileName string, input multipart.File) (fileUID string, err error)
-	// Downloads a file and returns its decrypted form and its name
-	DownloadFile(ctx context.Context, clientID, fileUID string) ([]byte, string, error)
+	// Uploads a file by streaming it through encryption into storage and returns a unique file UID that can be used to download the file
+	UploadFile(ctx context.Context, clientID, fileName string, input io.Reader, opts model.UploadOptions) (fileUID string, err error)
+	// Downloads a file, or the single byte range requested in opts, and returns a stream of its decrypted form along with its name, type and size
+	DownloadFile(ctx context.Context, clientID, fileUID string, opts model.DownloadOptions) (*model.FileDownloadStream, error)
 	// Encrypts a file and returns encrypted form and its name
 	EncryptFile(ctx context.Context, clientID, filename string, input multipart.File) ([]byte, string, error)
 	// Decrypts a file and returns decrypted form
 	DecryptFile(ctx context.Context, clientID, fileUID string, input multipart.File) ([]byte, error)
+	// Starts a resumable upload session for a file sent in parts
+	InitiateUpload(ctx context.Context, clientID, fileName string) (*model.UploadSessionResponse, error)
+	// Encrypts and stores one part of a resumable upload
+	UploadPart(ctx context.Context, clientID, sessionID string, partNumber int, input io.Reader) (*model.UploadPartResponse, error)
+	// Assembles the parts of a resumable upload into a stored file
+	CompleteUpload(ctx context.Context, clientID, sessionID string) (*model.UploadFileResponse, error)
+	// Cancels a resumable upload and discards its parts
+	AbortUpload(ctx context.Context, clientID, sessionID string) error
+	// Returns the state of a resumable upload and the parts received so far
+	GetUploadSession(ctx context.Context, clientID, sessionID string) (*model.UploadSessionResponse, error)
+	// Returns the durability status of a file and its latest upload job
+	GetFileStatus(ctx context.Context, clientID, fileUID string) (*model.FileStatusResponse, error)
 	// Returns metadata of a file
 	GetFileMetadata(ctx context.Context, clientID, fileUID string) (*model.FileMetadataResponse, error)
 	// Updates a file in storage
-	UpdateFile(ctx context.Context, appID, clientID, fileName string, input multipart.File) (string, error)
+	UpdateFile(ctx context.Context, clientID, fileUID, fileName string, input io.Reader, opts model.UploadOptions) (string, error)
+	// Returns the committed versions of a file, newest first
+	ListFileVersions(ctx context.Context, clientID, fileUID string) ([]model.FileVersionResponse, error)
+	// Downloads a specific version of a file and returns a stream of its decrypted form
+	DownloadFileVersion(ctx context.Context, clientID, fileUID string, version int, customerKey []byte) (*model.FileDownloadStream, error)
+	// Makes an older version of a file current again
+	PromoteFileVersion(ctx context.Context, clientID, fileUID string, version int) (*model.FileVersionResponse, error)
+	// Issues a signed, expiring public link to a file
+	CreateShareLink(ctx context.Context, clientID, fileUID string, request model.CreateShareLinkRequest) (*model.ShareLinkResponse, error)
+	// Returns the share links issued for a file
+	ListShareLinks(ctx context.Context, clientID, fileUID string) ([]model.ShareLinkResponse, error)
+	// Revokes a share link so it can no longer be used
+	RevokeShareLink(ctx context.Context, clientID, fileUID, linkID string) error
+	// Validates a share link token and returns a stream of the decrypted file it points to
+	DownloadSharedFile(ctx context.Context, token, passphrase, clientIP string) (*model.FileDownloadStream, error)
+	// Issues a pre-signed token that allows uploads to the app without credentials
+	CreateUploadToken(ctx context.Context, clientID string, request model.CreateUploadTokenRequest) (*model.UploadTokenResponse, error)
+	// Returns the upload tokens issued by the app
+	ListUploadTokens(ctx context.Context, clientID string) ([]model.UploadTokenResponse, error)
+	// Revokes an upload token so it can no longer be used
+	RevokeUploadToken(ctx context.Context, clientID, tokenID string) error
+	// Validates an upload token and stores the uploaded file for the app that issued it
+	UploadWithToken(ctx context.Context, token, fileName, clientIP string, input io.Reader) (*model.UploadFileResponse, error)
+	// Creates a folder at a path, creating missing parent folders
+	CreateFolder(ctx context.Context, clientID, folderPath string) (*model.PathEntryResponse, error)
+	// Looks up the file or folder at a path, listing the children of folders
+	GetPath(ctx context.Context, clientID, entryPath string) (*model.PathEntryResponse, error)
+	// Moves or renames the file or folder at a path
+	MovePath(ctx context.Context, clientID, source, destination string) (*model.PathEntryResponse, error)
+	// Places a file at a path in the folder tree
+	PlaceFile(ctx context.Context, clientID, fileUID, filePath string) (*model.PathEntryResponse, error)
+	// Deletes the file or folder at a path, optionally with everything below it
+	DeletePath(ctx context.Context, clientID, entryPath string, recursive bool) error
+	// Replaces the tags of a file
+	SetFileTags(ctx context.Context, clientID, fileUID string, tags map[string]string) (map[string]string, error)
 	// Deletes a file from storage
 	DeleteFile(ctx context.Context, clientID, fileUID string) error
+	// Erases a file for good by destroying its keys, stored versions and records, and returns a signed receipt
+	PurgeFile(ctx context.Context, clientID, fileUID string) (*model.ErasureReceipt, error)
+	// Sets when a file expires, or clears its expiry when expiresAt is nil
+	SetFileExpiry(ctx context.Context, clientID, fileUID string, expiresAt *time.Time) (*model.FileExpiryResponse, error)
+	// Soft deletes a file for the lifecycle scheduler under the given rule
+	ExpireFile(ctx context.Context, appID, fileUID, rule string) error
+	// Crypto-shreds a file for the lifecycle scheduler under the given rule
+	PurgeExpiredFile(ctx context.Context, appID, fileUID, rule string) (*model.ErasureReceipt, error)
+	// Returns the bytes and files the calling app stores against its limits
+	GetUsage(ctx context.Context, clientID string) (*model.AppUsageResponse, error)
+	// Places a legal hold that keeps a file from being deleted or overwritten until it is released
+	PlaceLegalHold(ctx context.Context, fileUID, reason string) (*model.FileHoldResponse, error)
+	// Releases the legal hold on a file
+	ReleaseLegalHold(ctx context.Context, fileUID, reason string) (*model.FileHoldResponse, error)
+	// Keeps a file from being deleted or overwritten before a date, which can only be extended
+	SetFileRetention(ctx context.Context, fileUID string, retainUntil time.Time) (*model.FileHoldResponse, error)
 	// Recovers a file from storage
 	RecoverFile(ctx context.Context, clientID, fileUID string) (string, error)
 	// Generates a new key and re-encrypts all files with the new key
 	ReKey(ctx context.Context, clientID, keyUID string) (string, error)
-	// Return a list of files
-	ListFiles(ctx context.Context, clientID string, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, error)
-	// Return a list of files for admin only
-	ListFilesForAdmin(ctx context.Context, adminID, appID string, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, error)
-	// Return a list of logs for admin only
-	ListLogs(ctx context.Context, limit, offset int, sortBy, order string) (int64, *[]model.FileLogResponse, error)
+	// Return a page of files and the cursor of the next page
+	ListFiles(ctx context.Context, clientID string, filter model.FileFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, string, error)
+	// Return a page of files and the cursor of the next page for admin only
+	ListFilesForAdmin(ctx context.Context, adminID, appID string, filter model.FileFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileResponse, string, error)
+	// Return a page of the access history of a file owned by the app and the cursor of the next page
+	GetFileHistory(ctx context.Context, clientID, fileUID string, filter model.LogFilter, limit, offset int, order string) (int64, *[]model.FileLogResponse, string, error)
+	// Return a page of logs and the cursor of the next page for admin only
+	ListLogs(ctx context.Context, filter model.LogFilter, limit, offset int, sortBy, order string) (int64, *[]model.FileLogResponse, string, error)
+}
+
+// UploadJobInterface defines the contract for the upload outbox worker.
+// It retries database commits for objects already in storage and removes orphaned objects when a commit is abandoned.
+type UploadJobInterface interface {
+	// ProcessDueJobs handles every job whose next attempt is due and returns how many were processed.
+	ProcessDueJobs(ctx context.Context) (int, error)
+	// ExpireUploadSessions closes resumable uploads past their expiry and returns how many were expired.
+	ExpireUploadSessions(ctx context.Context) (int, error)
+	// Run processes due jobs periodically until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// LifecycleInterface defines the contract for the scheduler applying retention and lifecycle rules.
+// It expires files past their TTL, purges files soft deleted past their app's retention and cuts old audit logs.
+type LifecycleInterface interface {
+	// Apply runs every rule once and reports what it did, or only what it would do when dryRun is set.
+	Apply(ctx context.Context, dryRun bool) (*model.LifecycleReport, error)
+	// Run applies the rules periodically until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// BucketMigrationInterface defines the contract for the worker moving the files of apps into their own buckets.
+type BucketMigrationInterface interface {
+	// MigratePending moves the files of every app whose migration was asked for and reports what it did.
+	MigratePending(ctx context.Context) (*model.BucketMigrationReport, error)
+	// Run moves files periodically until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// AuditInterface defines the contract for keeping the audit chain of file logs verifiable.
+// It signs checkpoints over the chain, cuts old entries behind a signed anchor and verifies the chain.
+type AuditInterface interface {
+	// VerifyChain walks the audit chain and reports whether it is intact, or where it first breaks.
+	VerifyChain(ctx context.Context) (*model.AuditVerifyResponse, error)
+	// Checkpoint signs a checkpoint over the head of the chain; it returns nil when there is nothing new to cover.
+	Checkpoint(ctx context.Context) (*model.AuditCheckpointResponse, error)
+	// ExportLogs streams the logs matching filter to w as JSON Lines, CSV or CEF.
+	ExportLogs(ctx context.Context, filter model.LogFilter, format string, w io.Writer) error
+	// PurgeOldLogs removes logs older than the given number of days behind a signed retention anchor.
+	PurgeOldLogs(ctx context.Context, days int) error
+	// VerifyErasureReceipt checks the signature of an erasure receipt and the audit entry it names.
+	VerifyErasureReceipt(ctx context.Context, receipt model.ErasureReceipt) (*model.ErasureReceiptVerifyResponse, error)
+	// Run signs checkpoints periodically until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// LogForwarderInterface defines the contract for shipping audit events to an external collector as they are written.
+type LogForwarderInterface interface {
+	// Publish hands an appended log entry to the forwarder without blocking.
+	Publish(log *entity.FileLogs)
+	// Run forwards published entries until the context is cancelled.
+	Run(ctx context.Context)
 }
 
 // KMSInterface defines the contract for Key Management Service operations.
@@ -126,14 +263,24 @@ type OAuth2Interface interface {
 // StorageInterface defines the contract for file storage operations.
 // It provides methods for uploading, downloading, updating, deleting, and managing files and their metadata.
 type StorageInterface interface {
-	// UploadFile uploads a file to the specified bucket with the given name and size.
-	UploadFile(ctx context.Context, bucketName string, fileName string, file multipart.File, fileSize int64) (*model.StorageTransactionResponse, error)
+	// UploadFile uploads a file to the specified bucket with the given name and size (-1 when the size is unknown).
+	UploadFile(ctx context.Context, bucketName string, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error)
 	// DownloadFile retrieves the contents of a file from the specified bucket.
 	DownloadFile(ctx context.Context, bucketName string, fileName string) ([]byte, error)
+	// DownloadFileStream opens the contents of a file in the specified bucket as a stream; the caller must close it.
+	DownloadFileStream(ctx context.Context, bucketName string, fileName string) (io.ReadCloser, error)
+	// DownloadFileRange opens bytes [offset, offset+length) of a file in the specified bucket as a stream; the caller must close it.
+	DownloadFileRange(ctx context.Context, bucketName string, fileName string, offset, length int64) (io.ReadCloser, error)
+	// DownloadFileVersionStream opens a specific version of a file in the specified bucket as a stream; the caller must close it.
+	DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error)
+	// CopyFileVersion makes a copy of an older version of a file its latest version.
+	CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error)
 	// DeleteFile removes a file from the specified bucket.
 	DeleteFile(ctx context.Context, bucketName string, fileName string) error
-	// UpdateFile replaces an existing file in the bucket with a new file and size.
-	UpdateFile(ctx context.Context, bucketName, fileName string, file multipart.File, fileSize int64) (*model.StorageTransactionResponse, error)
+	// DeleteFileVersion permanently removes a single version of a file from the specified bucket.
+	DeleteFileVersion(ctx context.Context, bucketName, fileName, versionID string) error
+	// UpdateFile replaces an existing file in the bucket with a new file and size (-1 when the size is unknown).
+	UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error)
 	// Exists checks if a file exists in the specified bucket and returns its metadata if present.
 	Exists(ctx context.Context, bucketName string, fileName string) (bool, *model.StorageTransactionResponse, error)
 	// ListFiles returns a list of all file names in the specified bucket.
@@ -144,6 +291,32 @@ type StorageInterface interface {
 	RestoreFile(ctx context.Context, bucketName, fileName, versionID string) error
 	// ListFileVersion lists all version IDs for a file in the specified bucket.
 	ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error)
+	// SetFileRetention keeps every stored version of a file from being removed until retainUntil.
+	SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error
+	// SetFileLegalHold places or releases the legal hold on every stored version of a file.
+	SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error
+	// CreateMultipartUpload starts a multipart upload for a file and returns its upload ID.
+	CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error)
+	// UploadPart uploads one part of a multipart upload and returns its ETag.
+	UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error)
+	// CompleteMultipartUpload assembles the uploaded parts into the file.
+	CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error)
+	// AbortMultipartUpload discards a multipart upload and any parts already uploaded.
+	AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error
+	// CreateBucket creates a bucket with versioning and object lock enabled, or enables versioning on it when it already exists.
+	CreateBucket(ctx context.Context, bucketName string) error
+	// RemoveBucket removes an empty bucket, failing with model.ErrBucketNotEmpty while any object version remains in it.
+	RemoveBucket(ctx context.Context, bucketName string) error
+}
+
+// ReplicatedStorageInterface is a StorageInterface that keeps copies of objects on secondary backends.
+// Reads fail over to the copies and a repair queue retries the copies that could not be written.
+type ReplicatedStorageInterface interface {
+	StorageInterface
+	// RepairPending retries the replica writes and deletes that are due and reports what it did.
+	RepairPending(ctx context.Context) (*model.ReplicaRepairReport, error)
+	// Run repairs the replicas periodically until the context is cancelled.
+	Run(ctx context.Context)
 }
 
 // CryptographicInterface defines the contract for cryptographic operations.
@@ -163,10 +336,18 @@ type CryptographicInterface interface {
 	HashString(hashMethod, text string) (string, error)
 	// CompareHash compares a hash with the hash of the given text using the specified method.
 	CompareHash(hashMethod, text, hash string) bool
-	// EncryptFile encrypts a file (as bytes) using the provided key.
-	EncryptFile(key string, file []byte) ([]byte, error)
-	// DecryptFile decrypts a file (as bytes) using the provided key.
-	DecryptFile(key string, file []byte) ([]byte, error)
+	// EncryptFile encrypts a file (as bytes) behind a ciphertext header naming keyRef, binding the header and associated data.
+	EncryptFile(key, keyRef string, file, associatedData []byte) ([]byte, error)
+	// DecryptFile decrypts a file (as bytes) using the provided key, rejecting ciphertexts sealed for other associated data.
+	DecryptFile(key string, file, associatedData []byte) ([]byte, error)
+	// NewEncryptingWriter returns a writer that stream-encrypts everything written to it into dst behind a ciphertext header naming keyRef.
+	NewEncryptingWriter(key, keyRef string, dst io.Writer, associatedData []byte) (io.WriteCloser, error)
+	// NewDecryptingReader returns a reader that stream-decrypts src using the provided key and associated data.
+	NewDecryptingReader(key string, src io.Reader, associatedData []byte) (io.Reader, error)
+	// NewRangeDecryptingReader decrypts plaintext bytes [offset, offset+length) of a stream, fetching only the ciphertext segments that cover them.
+	NewRangeDecryptingReader(key string, associatedData []byte, ciphertextSize, offset, length int64, fetch func(off, n int64) (io.ReadCloser, error)) (io.ReadCloser, error)
+	// NewHash returns a running hash for the specified hash method.
+	NewHash(hashMethod string) (hash.Hash, error)
 	// HashFile generates a hash of the file using the specified hash method.
 	HashFile(hashMethod string, file []byte) (string, error)
 	// CompareHashFile compares a hash with the hash of the given file using the specified method.
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultLifecycleInterval  = time.Hour
+	defaultLifecycleBatchSize = 100
+	lifecycleReportLimit      = 1000
+)
+
+// LifecycleService applies retention and lifecycle rules on a schedule.
+// Files past their own expiry or their app's file TTL are soft deleted, files soft deleted for longer than their
+// app's retention are crypto-shredded, and audit logs past the log retention are cut behind a signed anchor.
+// Files are walked in batches by ID so a file that cannot be handled is passed over instead of blocking the rest.
+type LifecycleService struct {
+	fileService           FileInterface
+	auditService          AuditInterface
+	fileRepository        repository.FileRepository
+	applicationRepository repository.ApplicationRepository
+	fileLogsRepository    repository.FileLogsRepository
+	interval              time.Duration
+	batchSize             int
+	logRetentionDays      int
+	dryRun                bool
+}
+
+func NewLifecycleService(params LifecycleServiceParams) LifecycleInterface {
+	interval := params.Interval
+	if interval <= 0 {
+		interval = defaultLifecycleInterval
+	}
+	batchSize := params.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLifecycleBatchSize
+	}
+
+	return &LifecycleService{
+		fileService:           params.FileService,
+		auditService:          params.AuditService,
+		fileRepository:        params.FileRepository,
+		applicationRepository: params.ApplicationRepository,
+		fileLogsRepository:    params.FileLogsRepository,
+		interval:              interval,
+		batchSize:             batchSize,
+		logRetentionDays:      params.LogRetentionDays,
+		dryRun:                params.DryRun,
+	}
+}
+
+// Run applies the rules every interval until the context is cancelled; in dry-run mode it only logs what it would do
+func (s *LifecycleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Apply(ctx, s.dryRun)
+		if err != nil {
+			slog.Error("Failed to apply lifecycle rules", slog.Any("error", err))
+		} else if report.FilesExpired+report.FilesPurged+report.Failures > 0 || report.LogsRemoved > 0 {
+			slog.Info("Lifecycle rules applied", slog.Bool("dry_run", report.DryRun),
+				slog.Int("files_expired", report.FilesExpired), slog.Int("files_purged", report.FilesPurged),
+				slog.Int64("logs_removed", report.LogsRemoved), slog.Int("failures", report.Failures))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply runs one pass of every rule and reports what it did; a dry run reports what it would do and changes nothing.
+// A file that fails is counted and listed with its error; only a failure to list the work ends the pass early.
+func (s *LifecycleService) Apply(ctx context.Context, dryRun bool) (*model.LifecycleReport, error) {
+	now := time.Now().UTC()
+	report := &model.LifecycleReport{DryRun: dryRun, StartedAt: now, Actions: []model.LifecycleAction{}}
+
+	apps, err := s.applicationRepository.ListWithLifecycle(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleted files are purged first so files expired in this pass get their full retention
+	for _, app := range apps {
+		if app.DeletedRetentionDays <= 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -app.DeletedRetentionDays)
+		if err := s.walk(ctx, func(afterID string) ([]entity.Files, error) {
+			return s.fileRepository.ListDeletedFilesBefore(ctx, app.ID, before, afterID, s.batchSize)
+		}, func(file entity.Files) {
+			s.apply(report, constant.LifecycleActionPurge, constant.LifecycleRuleDeletedRetention, file, func() error {
+				_, err := s.fileService.PurgeExpiredFile(ctx, file.AppID, file.ID, constant.LifecycleRuleDeletedRetention)
+				return err
+			})
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	expire := func(rule string) func(file entity.Files) {
+		return func(file entity.Files) {
+			s.apply(report, constant.LifecycleActionExpire, rule, file, func() error {
+				return s.fileService.ExpireFile(ctx, file.AppID, file.ID, rule)
+			})
+		}
+	}
+	if err := s.walk(ctx, func(afterID string) ([]entity.Files, error) {
+		return s.fileRepository.ListExpiredFiles(ctx, now, afterID, s.batchSize)
+	}, expire(constant.LifecycleRuleFileExpiry)); err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.FileTTLDays <= 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -app.FileTTLDays)
+		if err := s.walk(ctx, func(afterID string) ([]entity.Files, error) {
+			return s.fileRepository.ListFilesCreatedBefore(ctx, app.ID, before, afterID, s.batchSize)
+		}, expire(constant.LifecycleRuleAppTTL)); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.logRetentionDays > 0 {
+		if report.LogsRemoved, err = s.fileLogsRepository.CountOldLogs(ctx, s.logRetentionDays); err != nil {
+			return nil, err
+		}
+		if !dryRun && report.LogsRemoved > 0 {
+			if err := s.auditService.PurgeOldLogs(ctx, s.logRetentionDays); err != nil {
+				slog.Error("Failed to purge old audit logs", slog.Any("error", err))
+				report.LogsRemoved = 0
+				report.Failures++
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// walk hands every file list returns to fn, batch by batch in ID order
+func (s *LifecycleService) walk(ctx context.Context, list func(afterID string) ([]entity.Files, error), fn func(file entity.Files)) error {
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		files, err := list(afterID)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fn(file)
+		}
+		if len(files) < s.batchSize {
+			return nil
+		}
+		afterID = files[len(files)-1].ID
+	}
+}
+
+// apply takes one action on a file unless the report is a dry run, and adds it to the report
+func (s *LifecycleService) apply(report *model.LifecycleReport, action, rule string, file entity.Files, fn func() error) {
+	entry := model.LifecycleAction{Action: action, Rule: rule, AppID: file.AppID, FileID: file.ID}
+	if !report.DryRun {
+		if err := fn(); err != nil {
+			slog.Error("Failed to apply lifecycle rule", slog.String("file_id", file.ID), slog.String("rule", rule), slog.Any("error", err))
+			entry.Error = err.Error()
+		}
+	}
+
+	switch {
+	case entry.Error != "":
+		report.Failures++
+	case action == constant.LifecycleActionPurge:
+		report.FilesPurged++
+	default:
+		report.FilesExpired++
+	}
+	if len(report.Actions) < lifecycleReportLimit {
+		report.Actions = append(report.Actions, entry)
+	} else {
+		report.Truncated = true
+	}
+}
+
+type LifecycleServiceParams struct {
+	FileService           FileInterface
+	AuditService          AuditInterface
+	FileRepository        repository.FileRepository
+	ApplicationRepository repository.ApplicationRepository
+	FileLogsRepository    repository.FileLogsRepository
+	Interval              time.Duration
+	BatchSize             int
+	LogRetentionDays      int  // 0 keeps audit logs forever
+	DryRun                bool // the scheduler only reports what it would do
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"crypsis-backend/internal/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signLinkToken builds the public token of a link: its ID and expiry, signed so neither can be altered.
+// The purpose is part of the signature so a token minted for one kind of link cannot be replayed as another.
+func (c *FileService) signLinkToken(purpose, id string, expiresAt time.Time) string {
+	payload := id + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.linkTokenMAC(purpose, payload))
+}
+
+// verifyLinkToken checks a token's signature and expiry before the link is looked up and returns the link ID.
+// Tokens that do not verify are reported as notFound so their existence is not revealed. An expired token still
+// returns its link ID, so the refused use can be attributed to the link.
+func (c *FileService) verifyLinkToken(purpose, token string, notFound, expired error) (string, error) {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok {
+		return "", notFound
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.linkTokenMAC(purpose, payload)) {
+		return "", notFound
+	}
+
+	id, expiry, _ := cutLast(payload, ".")
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", notFound
+	}
+	if time.Now().Unix() >= expiresAt {
+		return id, expired
+	}
+	return id, nil
+}
+
+func (c *FileService) linkTokenMAC(purpose, payload string) []byte {
+	mac := hmac.New(sha256.New, c.linkTokenSecret)
+	mac.Write([]byte(purpose + ":" + payload))
+	return mac.Sum(nil)
+}
+
+// linkTokenSigningKey returns the configured signing secret, or one derived from the KEK so tokens survive restarts.
+// Without either, tokens are signed with a random key and stop working when the service restarts.
+func linkTokenSigningKey(secret string, keyConfig *model.KeyConfig) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	if keyConfig != nil && keyConfig.KEK != "" {
+		mac := hmac.New(sha256.New, []byte(keyConfig.KEK))
+		mac.Write([]byte("crypsis link tokens"))
+		return mac.Sum(nil)
+	}
+
+	slog.Warn("No link token secret or KEK configured, share links and upload tokens will not survive a restart")
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
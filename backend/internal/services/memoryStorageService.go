@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/model"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStorageService implements the StorageInterface in memory, versioning objects and enforcing legal holds
+// and retention the way a versioned MinIO bucket with object lock does. Nothing outlives the process, so it is
+// meant for tests and throwaway deployments.
+type MemoryStorageService struct {
+	mu      sync.RWMutex
+	clock   versionClock
+	buckets map[string]map[string][]memoryObjectVersion
+	uploads map[string]*memoryMultipartUpload
+}
+
+type memoryObjectVersion struct {
+	storedObjectVersion
+	content []byte
+}
+
+type memoryMultipartUpload struct {
+	bucketName string
+	fileName   string
+	parts      map[int][]byte
+}
+
+// NewMemoryStorageService creates an in-memory storage backend without any bucket.
+func NewMemoryStorageService() StorageInterface {
+	return &MemoryStorageService{
+		buckets: map[string]map[string][]memoryObjectVersion{},
+		uploads: map[string]*memoryMultipartUpload{},
+	}
+}
+
+// UploadFile stores the contents of the reader as the latest version of a file.
+func (s *MemoryStorageService) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	if fileName == "" {
+		return nil, fmt.Errorf("object name cannot be empty")
+	}
+	reader := io.Reader(contextReader{ctx: ctx, reader: file})
+	if fileSize >= 0 {
+		reader = io.LimitReader(reader, fileSize)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed for file %s: %w", fileName, err)
+	}
+	if err := checkedSize(fileName, fileSize, int64(len(content))); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.putLocked(bucketName, fileName, content)
+}
+
+// putLocked adds a version holding content to a file; the caller holds the write lock
+func (s *MemoryStorageService) putLocked(bucketName, fileName string, content []byte) (*model.StorageTransactionResponse, error) {
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, fmt.Errorf("upload failed for file %s: %w: %s", fileName, model.ErrBucketNotFound, bucketName)
+	}
+	versionID, err := s.clock.next()
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := newChecksumWriter()
+	_, _ = checksum.Write(content)
+	version := memoryObjectVersion{
+		storedObjectVersion: storedObjectVersion{
+			VersionID:      versionID,
+			Size:           int64(len(content)),
+			ChecksumSHA256: checksum.checksum(),
+			LastModified:   time.Now().UTC(),
+		},
+		content: content,
+	}
+	objects[fileName] = append(objects[fileName], version)
+	return version.response(s.location(bucketName, fileName)), nil
+}
+
+// DownloadFile returns the contents of the latest version of a file.
+func (s *MemoryStorageService) DownloadFile(ctx context.Context, bucketName, fileName string) ([]byte, error) {
+	object, err := s.DownloadFileStream(ctx, bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
+
+// DownloadFileStream opens the latest version of a file.
+func (s *MemoryStorageService) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	return s.DownloadFileVersionStream(ctx, bucketName, fileName, "")
+}
+
+// DownloadFileVersionStream opens a specific version of a file; an empty or "null" version ID opens the latest.
+func (s *MemoryStorageService) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	version, err := s.version(bucketName, fileName, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(version.content)), nil
+}
+
+// DownloadFileRange opens bytes [offset, offset+length) of the latest version of a file.
+func (s *MemoryStorageService) DownloadFileRange(ctx context.Context, bucketName, fileName string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid range %d+%d for file %s", offset, length, fileName)
+	}
+	version, err := s.version(bucketName, fileName, "")
+	if err != nil {
+		return nil, err
+	}
+	if offset >= version.Size {
+		return nil, fmt.Errorf("%w: %d+%d for file %s", model.ErrRangeNotSatisfiable, offset, length, fileName)
+	}
+	end := min(offset+length, version.Size)
+	return io.NopCloser(bytes.NewReader(version.content[offset:end])), nil
+}
+
+// version returns a version of a file; an empty or "null" version ID returns the latest
+func (s *MemoryStorageService) version(bucketName, fileName, versionID string) (*memoryObjectVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.buckets[bucketName][fileName]
+	i, err := findVersion(storedVersions(versions), fileName, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return &versions[i], nil
+}
+
+// UpdateFile stores the contents of the reader as a new version of a file.
+func (s *MemoryStorageService) UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	return s.UploadFile(ctx, bucketName, fileName, file, fileSize)
+}
+
+// Exists reports whether a file has a latest version that is not a delete marker, and describes that version.
+func (s *MemoryStorageService) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
+	if fileName == "" {
+		return false, nil, fmt.Errorf("object name cannot be empty")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := liveVersion(storedVersions(s.buckets[bucketName][fileName]))
+	if latest == nil {
+		return false, nil, nil
+	}
+	return true, latest.response(s.location(bucketName, fileName)), nil
+}
+
+// ListFiles lists the files in a bucket whose latest version is not a delete marker.
+func (s *MemoryStorageService) ListFiles(ctx context.Context, bucketName string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, fmt.Errorf("error listing files: %w: %s", model.ErrBucketNotFound, bucketName)
+	}
+	files := []string{}
+	for fileName, versions := range objects {
+		if liveVersion(storedVersions(versions)) != nil {
+			files = append(files, fileName)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// GetFileMetadata returns the content type and size of the latest version of a file.
+func (s *MemoryStorageService) GetFileMetadata(ctx context.Context, bucketName, fileName string) (map[string]string, error) {
+	version, err := s.version(bucketName, fileName, "")
+	if err != nil {
+		return nil, fmt.Errorf("error getting metadata for file %s: %w", fileName, err)
+	}
+	return map[string]string{
+		"content-type": "application/octet-stream",
+		"size":         fmt.Sprintf("%d", version.Size),
+	}, nil
+}
+
+// DeleteFile hides a file behind a delete marker; its versions are kept and can be restored.
+func (s *MemoryStorageService) DeleteFile(ctx context.Context, bucketName, objectName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("soft delete failed for object %s: %w: %s", objectName, model.ErrBucketNotFound, bucketName)
+	}
+	versionID, err := s.clock.next()
+	if err != nil {
+		return err
+	}
+	objects[objectName] = append(objects[objectName], memoryObjectVersion{storedObjectVersion: storedObjectVersion{
+		VersionID:    versionID,
+		LastModified: time.Now().UTC(),
+		DeleteMarker: true,
+	}})
+	return nil
+}
+
+// DeleteFileVersion permanently removes one version of a file, unless a legal hold or retention locks it.
+// Removing a version that does not exist succeeds; without a version ID the file is hidden behind a delete marker,
+// as in a versioned bucket.
+func (s *MemoryStorageService) DeleteFileVersion(ctx context.Context, bucketName, objectName, versionID string) error {
+	if versionID == "" {
+		return s.DeleteFile(ctx, bucketName, objectName)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.buckets[bucketName][objectName]
+	for i := range versions {
+		if versions[i].VersionID != versionID {
+			continue
+		}
+		if versions[i].locked(time.Now()) {
+			return fmt.Errorf("delete failed for object %s version %s: %w", objectName, versionID, model.ErrObjectLocked)
+		}
+		versions = append(versions[:i:i], versions[i+1:]...)
+		if len(versions) == 0 {
+			delete(s.buckets[bucketName], objectName)
+		} else {
+			s.buckets[bucketName][objectName] = versions
+		}
+		return nil
+	}
+	return nil
+}
+
+// CopyFileVersion copies an older version of a file as its latest version, leaving the older version in place.
+func (s *MemoryStorageService) CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.buckets[bucketName][fileName]
+	i, err := findVersion(storedVersions(versions), fileName, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("copy failed for file %s version %s: %w", fileName, versionID, err)
+	}
+	return s.putLocked(bucketName, fileName, versions[i].content)
+}
+
+// RestoreFile makes a version the current contents of a file again, removing the delete markers that hide it.
+func (s *MemoryStorageService) RestoreFile(ctx context.Context, bucketName, fileName, versionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.buckets[bucketName][fileName]
+	markers, copyVersion, err := restorePlan(storedVersions(versions), fileName, versionID)
+	if err != nil {
+		return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+	}
+	versions = versions[:len(versions)-len(markers)]
+	s.buckets[bucketName][fileName] = versions
+	if copyVersion {
+		i, err := findVersion(storedVersions(versions), fileName, versionID)
+		if err != nil {
+			return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+		}
+		if _, err := s.putLocked(bucketName, fileName, versions[i].content); err != nil {
+			return fmt.Errorf("restore failed for object %s: %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// ListFileVersion lists the IDs of all versions of a file newest first, delete markers included.
+func (s *MemoryStorageService) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return versionIDsNewestFirst(storedVersions(s.buckets[bucketName][fileName])), nil
+}
+
+// SetFileRetention keeps every stored version of a file from being removed until retainUntil.
+func (s *MemoryStorageService) SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error {
+	return s.lockVersions(bucketName, fileName, func(v *storedObjectVersion) error {
+		return extendRetention(v, fileName, retainUntil)
+	})
+}
+
+// SetFileLegalHold places or releases the legal hold on every stored version of a file.
+func (s *MemoryStorageService) SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error {
+	return s.lockVersions(bucketName, fileName, func(v *storedObjectVersion) error {
+		v.LegalHold = hold
+		return nil
+	})
+}
+
+// lockVersions applies a lock change to every version of a file that is not a delete marker
+func (s *MemoryStorageService) lockVersions(bucketName, fileName string, apply func(v *storedObjectVersion) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.buckets[bucketName][fileName]
+	for i := range versions {
+		if versions[i].DeleteMarker {
+			continue
+		}
+		if err := apply(&versions[i].storedObjectVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateMultipartUpload starts a multipart upload for a file and returns its upload ID.
+func (s *MemoryStorageService) CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucketName]; !ok {
+		return "", fmt.Errorf("create multipart upload failed for file %s: %w: %s", fileName, model.ErrBucketNotFound, bucketName)
+	}
+	uploadID, err := s.clock.next()
+	if err != nil {
+		return "", err
+	}
+	s.uploads[uploadID] = &memoryMultipartUpload{bucketName: bucketName, fileName: fileName, parts: map[int][]byte{}}
+	return uploadID, nil
+}
+
+// UploadPart stores one part of a multipart upload and returns its ETag.
+func (s *MemoryStorageService) UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error) {
+	reader := io.Reader(contextReader{ctx: ctx, reader: part})
+	if partSize >= 0 {
+		reader = io.LimitReader(reader, partSize)
+	}
+	etag := newPartHash()
+	content, err := io.ReadAll(io.TeeReader(reader, etag))
+	if err != nil {
+		return "", fmt.Errorf("upload failed for part %d of file %s: %w", partNumber, fileName, err)
+	}
+	if err := checkedSize(fileName, partSize, int64(len(content))); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, err := s.upload(bucketName, fileName, uploadID)
+	if err != nil {
+		return "", err
+	}
+	upload.parts[partNumber] = content
+	return partETag(etag), nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts, in the given order, into the latest version of the file.
+func (s *MemoryStorageService) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, err := s.upload(bucketName, fileName, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var content bytes.Buffer
+	for _, part := range parts {
+		data, ok := upload.parts[part.PartNumber]
+		etag := newPartHash()
+		_, _ = etag.Write(data)
+		if !ok || partETag(etag) != part.ETag {
+			return nil, fmt.Errorf("complete multipart upload failed for file %s: %w: part %d", fileName, model.ErrUploadPartsMissing, part.PartNumber)
+		}
+		content.Write(data)
+	}
+
+	resp, err := s.putLocked(bucketName, fileName, content.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	delete(s.uploads, uploadID)
+	return resp, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and any parts already uploaded.
+func (s *MemoryStorageService) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.upload(bucketName, fileName, uploadID); err != nil {
+		return err
+	}
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+// upload returns a multipart upload of a file; the caller holds the lock
+func (s *MemoryStorageService) upload(bucketName, fileName, uploadID string) (*memoryMultipartUpload, error) {
+	upload, ok := s.uploads[uploadID]
+	if !ok || upload.bucketName != bucketName || upload.fileName != fileName {
+		return nil, fmt.Errorf("%w: %s", model.ErrMultipartNotFound, uploadID)
+	}
+	return upload, nil
+}
+
+// CreateBucket creates a bucket, keeping an existing one as it is.
+func (s *MemoryStorageService) CreateBucket(ctx context.Context, bucketName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucketName]; !ok {
+		s.buckets[bucketName] = map[string][]memoryObjectVersion{}
+	}
+	return nil
+}
+
+// RemoveBucket removes a bucket once no object version is left in it, along with its unfinished multipart uploads.
+func (s *MemoryStorageService) RemoveBucket(ctx context.Context, bucketName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buckets[bucketName]) > 0 {
+		return model.ErrBucketNotEmpty
+	}
+	delete(s.buckets, bucketName)
+	for uploadID, upload := range s.uploads {
+		if upload.bucketName == bucketName {
+			delete(s.uploads, uploadID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorageService) location(bucketName, fileName string) string {
+	return fmt.Sprintf("memory://%s/%s", bucketName, fileName)
+}
+
+// storedVersions returns the descriptions of in-memory versions in the same order
+func storedVersions(versions []memoryObjectVersion) []storedObjectVersion {
+	stored := make([]storedObjectVersion, len(versions))
+	for i := range versions {
+		stored[i] = versions[i].storedObjectVersion
+	}
+	return stored
+}
@@ -9,13 +9,16 @@ import (
 	"io"
 	"log"
 	"log/slog"
-	"mime/multipart"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// streamPartSize bounds the memory MinIO buffers per part when uploading a stream of unknown size.
+const streamPartSize = 16 << 20
+
 // MinioService implements the StorageInterface for MinIO object storage.
 // It provides methods for file upload, download, update, deletion, restoration,
 // and metadata operations in a MinIO-compatible object storage system.
@@ -42,9 +45,10 @@ func NewMinioService(input model.MinIOConfig) StorageInterface {
 }
 
 // UploadFile uploads a file to the specified bucket with the given name and size.
+// A size of -1 streams the reader as a multipart upload in streamPartSize chunks.
 // Returns a StorageTransactionResponse containing transaction metadata including version ID, last modified time,
 // expiration, location, checksum, and latest status.
-func (s *MinioService) UploadFile(ctx context.Context, bucketName, fileName string, file multipart.File, fileSize int64) (*model.StorageTransactionResponse, error) {
+func (s *MinioService) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
 	// Start tracing span
 	tracer := helper.GetTracingHelper()
 	ctx, span := tracer.StartStorageSpan(ctx, "PutObject", bucketName, fileName)
@@ -54,9 +58,14 @@ func (s *MinioService) UploadFile(ctx context.Context, bucketName, fileName stri
 		"file.size": fileSize,
 	})
 
-	resp, err := s.client.PutObject(ctx, bucketName, fileName, file, fileSize, minio.PutObjectOptions{
+	opts := minio.PutObjectOptions{
 		ContentType: "application/octet-stream",
-	})
+	}
+	if fileSize < 0 {
+		opts.PartSize = streamPartSize
+	}
+
+	resp, err := s.client.PutObject(ctx, bucketName, fileName, file, fileSize, opts)
 	if err != nil {
 		slog.Error("failed to upload file",
 			"error", err,
@@ -97,6 +106,25 @@ func (s *MinioService) UploadFile(ctx context.Context, bucketName, fileName stri
 // DownloadFile downloads a file from the specified bucket and returns its contents as bytes.
 // Returns an error if the file does not exist or cannot be read.
 func (s *MinioService) DownloadFile(ctx context.Context, bucketName, fileName string) ([]byte, error) {
+	object, err := s.DownloadFileStream(ctx, bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	buf := new(bytes.Buffer)
+	_, err = io.Copy(buf, object)
+	if err != nil {
+		slog.Error("failed to read file", slog.Any("error", err))
+		return nil, fmt.Errorf("error reading file %s: %w", fileName, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DownloadFileStream opens a file in the specified bucket for streaming reads.
+// The caller must close the returned reader. Returns an error if the file does not exist.
+func (s *MinioService) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
 	isExist, _, err := s.Exists(ctx, bucketName, fileName)
 	if err != nil {
 		return nil, err
@@ -114,21 +142,58 @@ func (s *MinioService) DownloadFile(ctx context.Context, bucketName, fileName st
 		)
 		return nil, fmt.Errorf("download failed for file %s: %w", fileName, err)
 	}
-	defer object.Close()
+	return object, nil
+}
 
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, object)
+// DownloadFileVersionStream opens a specific version of a file in the specified bucket for streaming reads.
+// The caller must close the returned reader.
+func (s *MinioService) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	if versionID == "null" {
+		versionID = ""
+	}
+
+	object, err := s.client.GetObject(ctx, bucketName, fileName, minio.GetObjectOptions{VersionID: versionID})
 	if err != nil {
-		slog.Error("failed to read file", slog.Any("error", err))
-		return nil, fmt.Errorf("error reading file %s: %w", fileName, err)
+		slog.Error("failed to download file version",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+			"version", versionID,
+		)
+		return nil, fmt.Errorf("download failed for file %s version %s: %w", fileName, versionID, err)
 	}
+	return object, nil
+}
 
-	return buf.Bytes(), nil
+// DownloadFileRange opens bytes [offset, offset+length) of a file in the specified bucket for streaming reads.
+// The caller must close the returned reader.
+func (s *MinioService) DownloadFileRange(ctx context.Context, bucketName, fileName string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid range %d+%d for file %s", offset, length, fileName)
+	}
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, fmt.Errorf("invalid range for file %s: %w", fileName, err)
+	}
+
+	object, err := s.client.GetObject(ctx, bucketName, fileName, opts)
+	if err != nil {
+		slog.Error("failed to download file range",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+			"offset", offset,
+			"length", length,
+		)
+		return nil, fmt.Errorf("ranged download failed for file %s: %w", fileName, err)
+	}
+	return object, nil
 }
 
 // UpdateFile updates an existing file in the specified bucket by uploading a new version.
 // This is effectively an alias for UploadFile since MinIO handles versioning automatically.
-func (s *MinioService) UpdateFile(ctx context.Context, bucketName, fileName string, file multipart.File, fileSize int64) (*model.StorageTransactionResponse, error) {
+func (s *MinioService) UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
 	_, _, err := s.Exists(ctx, bucketName, fileName)
 	if err != nil {
 		return nil, err
@@ -178,6 +243,7 @@ func (s *MinioService) Exists(ctx context.Context, bucketName, fileName string)
 		LastModified:   resp.LastModified.String(),
 		Expiration:     resp.Expiration.String(),
 		ChecksumSHA256: resp.ChecksumSHA256,
+		Size:           resp.Size,
 		IsLatest:       resp.IsLatest,
 		IsDeleteMarker: resp.IsDeleteMarker,
 	}, nil
@@ -236,6 +302,68 @@ func (s *MinioService) DeleteFile(ctx context.Context, bucketName, objectName st
 	return nil
 }
 
+// DeleteFileVersion permanently removes a single version of a file in the specified bucket.
+// An empty or "null" version ID removes the object in buckets without versioning.
+func (s *MinioService) DeleteFileVersion(ctx context.Context, bucketName, objectName, versionID string) error {
+	if versionID == "null" {
+		versionID = ""
+	}
+	err := s.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{VersionID: versionID})
+	if err != nil {
+		slog.Error("failed to delete object version",
+			"error", err,
+			"bucket", bucketName,
+			"object", objectName,
+			"version", versionID,
+		)
+		return fmt.Errorf("delete failed for object %s version %s: %w", objectName, versionID, err)
+	}
+	return nil
+}
+
+// CopyFileVersion copies an older version of a file onto itself server-side, making the copy the latest version.
+// The older version is left in place.
+func (s *MinioService) CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "ComposeObject", bucketName, fileName)
+	defer span.End()
+
+	// ComposeObject copies objects beyond the 5 GiB single-copy limit part by part
+	resp, err := s.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: bucketName, Object: fileName},
+		minio.CopySrcOptions{Bucket: bucketName, Object: fileName, VersionID: versionID},
+	)
+	if err != nil {
+		slog.Error("failed to copy file version",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+			"version", versionID,
+		)
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("copy failed for file %s version %s: %w", fileName, versionID, err)
+	}
+
+	location := resp.Location
+	if location == "" {
+		location = fmt.Sprintf("%s/%s/%s", s.client.EndpointURL().String(), bucketName, fileName)
+	}
+	newVersionID := resp.VersionID
+	if newVersionID == "" {
+		newVersionID = "null"
+	}
+
+	return &model.StorageTransactionResponse{
+		VersionID:      newVersionID,
+		LastModified:   resp.LastModified.String(),
+		Expiration:     resp.Expiration.String(),
+		Location:       location,
+		ChecksumSHA256: resp.ChecksumSHA256,
+		IsLatest:       true,
+		IsDeleteMarker: false,
+	}, nil
+}
+
 // RestoreFile restores a soft-deleted file in the specified bucket using its version ID.
 // The file is restored for 30 days with Standard tier access.
 func (s *MinioService) RestoreFile(ctx context.Context, bucketName, fileName, versionID string) error {
@@ -260,12 +388,13 @@ func (s *MinioService) RestoreFile(ctx context.Context, bucketName, fileName, ve
 	return nil
 }
 
-// ListFileVersion lists all versions of a specific file in the specified bucket.
-// Returns a slice of version keys for the given file prefix.
+// ListFileVersion lists the IDs of all versions of a specific file in the specified bucket, delete markers included.
+// Objects in buckets without versioning are listed with the version ID "null".
 func (s *MinioService) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
 	versions := []string{}
 	objectCh := s.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
-		Prefix: fileName,
+		Prefix:       fileName,
+		WithVersions: true,
 	})
 	for object := range objectCh {
 		if object.Err != nil {
@@ -276,7 +405,276 @@ func (s *MinioService) ListFileVersion(ctx context.Context, bucketName, fileName
 			)
 			return nil, fmt.Errorf("error listing object versions: %w", object.Err)
 		}
-		versions = append(versions, object.Key)
+		// The prefix also matches longer names
+		if object.Key != fileName {
+			continue
+		}
+		versionID := object.VersionID
+		if versionID == "" {
+			versionID = "null"
+		}
+		versions = append(versions, versionID)
+	}
+	return versions, nil
+}
+
+// SetFileRetention locks every stored version of a file in COMPLIANCE mode until retainUntil, so not even the
+// bucket owner can remove it before then. Returns model.ErrObjectLockUnavailable when the bucket was not created
+// with object lock enabled.
+func (s *MinioService) SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "PutObjectRetention", bucketName, fileName)
+	defer span.End()
+
+	versions, err := s.lockableVersions(ctx, bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	mode := minio.Compliance
+	for _, versionID := range versions {
+		err := s.client.PutObjectRetention(ctx, bucketName, fileName, minio.PutObjectRetentionOptions{
+			Mode:            &mode,
+			RetainUntilDate: &retainUntil,
+			VersionID:       versionID,
+		})
+		if err != nil {
+			slog.Error("failed to set object retention",
+				"error", err,
+				"bucket", bucketName,
+				"file", fileName,
+				"version", versionID,
+			)
+			return fmt.Errorf("set retention failed for object %s version %s: %w", fileName, versionID, err)
+		}
+	}
+	return nil
+}
+
+// SetFileLegalHold places or releases the legal hold on every stored version of a file.
+// Returns model.ErrObjectLockUnavailable when the bucket was not created with object lock enabled.
+func (s *MinioService) SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "PutObjectLegalHold", bucketName, fileName)
+	defer span.End()
+
+	versions, err := s.lockableVersions(ctx, bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	status := minio.LegalHoldDisabled
+	if hold {
+		status = minio.LegalHoldEnabled
+	}
+	for _, versionID := range versions {
+		err := s.client.PutObjectLegalHold(ctx, bucketName, fileName, minio.PutObjectLegalHoldOptions{
+			Status:    &status,
+			VersionID: versionID,
+		})
+		if err != nil {
+			slog.Error("failed to set object legal hold",
+				"error", err,
+				"bucket", bucketName,
+				"file", fileName,
+				"version", versionID,
+			)
+			return fmt.Errorf("set legal hold failed for object %s version %s: %w", fileName, versionID, err)
+		}
+	}
+	return nil
+}
+
+// lockableVersions lists the versions of a file that object lock applies to, leaving out delete markers,
+// after checking the bucket was created with object lock enabled
+func (s *MinioService) lockableVersions(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	objectLock, _, _, _, err := s.client.GetObjectLockConfig(ctx, bucketName)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "ObjectLockConfigurationNotFoundError" {
+			return nil, model.ErrObjectLockUnavailable
+		}
+		return nil, fmt.Errorf("error reading object lock configuration of bucket %s: %w", bucketName, err)
+	}
+	if objectLock != "Enabled" {
+		return nil, model.ErrObjectLockUnavailable
+	}
+
+	versions := []string{}
+	objectCh := s.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:       fileName,
+		WithVersions: true,
+	})
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("error listing object versions: %w", object.Err)
+		}
+		if object.Key != fileName || object.IsDeleteMarker {
+			continue
+		}
+		versions = append(versions, object.VersionID)
 	}
 	return versions, nil
 }
+
+// CreateMultipartUpload starts a multipart upload for a file in the specified bucket.
+// Returns the upload ID that identifies the upload in later part, complete and abort calls.
+func (s *MinioService) CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "NewMultipartUpload", bucketName, fileName)
+	defer span.End()
+
+	core := minio.Core{Client: s.client}
+	uploadID, err := core.NewMultipartUpload(ctx, bucketName, fileName, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		slog.Error("failed to create multipart upload",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+		)
+		helper.RecordError(span, err)
+		return "", fmt.Errorf("create multipart upload failed for file %s: %w", fileName, err)
+	}
+	return uploadID, nil
+}
+
+// UploadPart uploads one part of a multipart upload. The part size must be known in advance.
+// Returns the ETag of the part, which is needed to complete the upload.
+func (s *MinioService) UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "PutObjectPart", bucketName, fileName)
+	defer span.End()
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"part.number": partNumber,
+		"part.size":   partSize,
+	})
+
+	core := minio.Core{Client: s.client}
+	objectPart, err := core.PutObjectPart(ctx, bucketName, fileName, uploadID, partNumber, part, partSize, minio.PutObjectPartOptions{})
+	if err != nil {
+		slog.Error("failed to upload part",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+			"part", partNumber,
+		)
+		helper.RecordError(span, err)
+		return "", fmt.Errorf("upload failed for part %d of file %s: %w", partNumber, fileName, err)
+	}
+	return objectPart.ETag, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts, in the given order, into the file.
+// Returns a StorageTransactionResponse describing the new object version.
+func (s *MinioService) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error) {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "CompleteMultipartUpload", bucketName, fileName)
+	defer span.End()
+
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	core := minio.Core{Client: s.client}
+	resp, err := core.CompleteMultipartUpload(ctx, bucketName, fileName, uploadID, completeParts, minio.PutObjectOptions{})
+	if err != nil {
+		slog.Error("failed to complete multipart upload",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+		)
+		helper.RecordError(span, err)
+		return nil, fmt.Errorf("complete multipart upload failed for file %s: %w", fileName, err)
+	}
+
+	location := resp.Location
+	if location == "" {
+		location = fmt.Sprintf("%s/%s/%s", s.client.EndpointURL().String(), bucketName, fileName)
+	}
+	versionID := resp.VersionID
+	if versionID == "" {
+		versionID = "null"
+	}
+
+	return &model.StorageTransactionResponse{
+		VersionID:      versionID,
+		LastModified:   resp.LastModified.String(),
+		Expiration:     resp.Expiration.String(),
+		Location:       location,
+		ChecksumSHA256: resp.ChecksumSHA256,
+		IsLatest:       true,
+		IsDeleteMarker: false,
+	}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and any parts already uploaded.
+func (s *MinioService) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	core := minio.Core{Client: s.client}
+	if err := core.AbortMultipartUpload(ctx, bucketName, fileName, uploadID); err != nil {
+		slog.Error("failed to abort multipart upload",
+			"error", err,
+			"bucket", bucketName,
+			"file", fileName,
+		)
+		return fmt.Errorf("abort multipart upload failed for file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// CreateBucket creates a bucket with object lock enabled, which also turns on versioning, so the files of an app
+// keep their version history and can be placed under legal hold or retention. An existing bucket is kept and
+// has versioning enabled, as object lock can only be turned on when a bucket is created.
+func (s *MinioService) CreateBucket(ctx context.Context, bucketName string) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "MakeBucket", bucketName, "")
+	defer span.End()
+
+	exists, err := s.client.BucketExists(ctx, bucketName)
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("error checking bucket %s: %w", bucketName, err)
+	}
+	if !exists {
+		if err := s.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{ObjectLocking: true}); err != nil {
+			slog.Error("failed to create bucket",
+				"error", err,
+				"bucket", bucketName,
+			)
+			helper.RecordError(span, err)
+			return fmt.Errorf("create bucket failed for %s: %w", bucketName, err)
+		}
+	}
+	if err := s.client.EnableVersioning(ctx, bucketName); err != nil {
+		slog.Error("failed to enable bucket versioning",
+			"error", err,
+			"bucket", bucketName,
+		)
+		helper.RecordError(span, err)
+		return fmt.Errorf("enable versioning failed for bucket %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// RemoveBucket removes a bucket once no object version is left in it. A bucket that does not exist is already removed.
+func (s *MinioService) RemoveBucket(ctx context.Context, bucketName string) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "RemoveBucket", bucketName, "")
+	defer span.End()
+
+	if err := s.client.RemoveBucket(ctx, bucketName); err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchBucket":
+			return nil
+		case "BucketNotEmpty":
+			return model.ErrBucketNotEmpty
+		}
+		slog.Error("failed to remove bucket",
+			"error", err,
+			"bucket", bucketName,
+		)
+		helper.RecordError(span, err)
+		return fmt.Errorf("remove bucket failed for %s: %w", bucketName, err)
+	}
+	return nil
+}
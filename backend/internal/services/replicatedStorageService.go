@@ -0,0 +1,778 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReplicaRepairInterval  = time.Minute
+	defaultReplicaRepairBatchSize = 100
+	replicaRepairBaseBackoff      = 5 * time.Second
+	replicaRepairMaxBackoff       = 30 * time.Minute
+)
+
+// StorageReplica is a secondary storage backend that keeps a copy of the objects written to the primary
+type StorageReplica struct {
+	Name    string
+	Storage StorageInterface
+}
+
+// ReplicationPolicy reports whether an object that has no replicas yet is copied to the replicas
+type ReplicationPolicy func(ctx context.Context, bucketName, objectName string) (bool, error)
+
+// AppReplicationPolicy replicates the objects of an app as its Replicate setting says, and objects whose app is
+// unknown or has no setting as replicateByDefault
+func AppReplicationPolicy(applicationRepository repository.ApplicationRepository, replicateByDefault bool) ReplicationPolicy {
+	return func(ctx context.Context, bucketName, objectName string) (bool, error) {
+		fileID, ok := strings.CutSuffix(objectName, createFileName(""))
+		if !ok {
+			return replicateByDefault, nil
+		}
+		app, err := applicationRepository.GetByFileID(ctx, fileID)
+		if err != nil {
+			if errors.Is(err, model.ErrAppNotFound) {
+				return replicateByDefault, nil
+			}
+			return replicateByDefault, err
+		}
+		if app.Replicate != nil {
+			return *app.Replicate, nil
+		}
+		return replicateByDefault, nil
+	}
+}
+
+// replicaRepairBackoff returns the exponential delay before the next attempt, capped at replicaRepairMaxBackoff
+func replicaRepairBackoff(attempts int) time.Duration {
+	delay := replicaRepairBaseBackoff
+	for i := 0; i < attempts && delay < replicaRepairMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > replicaRepairMaxBackoff {
+		delay = replicaRepairMaxBackoff
+	}
+	return delay
+}
+
+// ReplicatedStorageService decorates a primary storage backend with secondary backends in other sites.
+// Every version written to the primary is read back and copied to each replica, and deletes, restores, holds and
+// retention follow it there. A replica write that fails is left pending in the object replica table, which the
+// repair worker drains with backoff until the replica has caught up. Once an object has replicas it stays
+// replicated; whether a new object is replicated is up to the policy.
+//
+// Reads of the latest version fail over to a replica when the primary errors or is missing the object, as long as
+// the replica holds the object and nothing is pending for it there; reads of a version fail over to the replica
+// version it was copied to. Listings only come from the primary, as callers use the version IDs they return.
+type ReplicatedStorageService struct {
+	primary                 StorageInterface
+	replicas                []StorageReplica
+	objectReplicaRepository repository.ObjectReplicaRepository
+	policy                  ReplicationPolicy
+	interval                time.Duration
+	batchSize               int
+}
+
+func NewReplicatedStorageService(params ReplicatedStorageServiceParams) ReplicatedStorageInterface {
+	interval := params.Interval
+	if interval <= 0 {
+		interval = defaultReplicaRepairInterval
+	}
+	batchSize := params.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultReplicaRepairBatchSize
+	}
+
+	return &ReplicatedStorageService{
+		primary:                 params.Primary,
+		replicas:                params.Replicas,
+		objectReplicaRepository: params.ObjectReplicaRepository,
+		policy:                  params.Policy,
+		interval:                interval,
+		batchSize:               batchSize,
+	}
+}
+
+// Run repairs the replicas every interval until the context is cancelled
+func (s *ReplicatedStorageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.RepairPending(ctx)
+		if err != nil {
+			slog.Error("Failed to repair storage replicas", slog.Any("error", err))
+		} else if report.Processed > 0 {
+			slog.Info("Storage replicas repaired", slog.Int("processed", report.Processed),
+				slog.Int("repaired", report.Repaired), slog.Int("failures", report.Failures))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RepairPending retries every replica write and delete whose next attempt is due. The bucket is created on the
+// replica before an object is copied to it, so replicas added after buckets were provisioned catch up too.
+func (s *ReplicatedStorageService) RepairPending(ctx context.Context) (*model.ReplicaRepairReport, error) {
+	records, err := s.objectReplicaRepository.GetDue(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.ReplicaRepairReport{}
+	for i := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record := &records[i]
+		report.Processed++
+		if record.Operation == constant.ReplicaOperationCopy {
+			if replica, ok := s.replica(record.Replica); ok {
+				if err := replica.Storage.CreateBucket(ctx, record.BucketName); err != nil {
+					s.retryLater(ctx, record, fmt.Errorf("failed to create bucket on replica: %w", err))
+					report.Failures++
+					continue
+				}
+			}
+		}
+		if s.apply(ctx, record) {
+			report.Repaired++
+		} else {
+			report.Failures++
+		}
+	}
+	return report, nil
+}
+
+// UploadFile uploads a file to the primary and copies the new version to the replicas.
+func (s *ReplicatedStorageService) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	resp, err := s.primary.UploadFile(ctx, bucketName, fileName, file, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	s.replicate(ctx, bucketName, fileName, resp.VersionID)
+	return resp, nil
+}
+
+// UpdateFile replaces a file on the primary and copies the new version to the replicas.
+func (s *ReplicatedStorageService) UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	resp, err := s.primary.UpdateFile(ctx, bucketName, fileName, file, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	s.replicate(ctx, bucketName, fileName, resp.VersionID)
+	return resp, nil
+}
+
+// CopyFileVersion makes an older version of a file its latest version on the primary and copies it to the replicas.
+func (s *ReplicatedStorageService) CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error) {
+	resp, err := s.primary.CopyFileVersion(ctx, bucketName, fileName, versionID)
+	if err != nil {
+		return nil, err
+	}
+	s.replicate(ctx, bucketName, fileName, resp.VersionID)
+	return resp, nil
+}
+
+// CompleteMultipartUpload assembles a multipart upload on the primary and copies the file to the replicas.
+func (s *ReplicatedStorageService) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error) {
+	resp, err := s.primary.CompleteMultipartUpload(ctx, bucketName, fileName, uploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+	s.replicate(ctx, bucketName, fileName, resp.VersionID)
+	return resp, nil
+}
+
+// DownloadFile retrieves a file from the primary, or from a replica when the primary cannot serve it.
+func (s *ReplicatedStorageService) DownloadFile(ctx context.Context, bucketName, fileName string) ([]byte, error) {
+	content, err := s.primary.DownloadFile(ctx, bucketName, fileName)
+	if err == nil {
+		return content, nil
+	}
+	return readFromReplica(ctx, s, "DownloadFile", bucketName, fileName, "", err,
+		func(ctx context.Context, storage StorageInterface, _ *entity.ObjectReplicas) ([]byte, error) {
+			return storage.DownloadFile(ctx, bucketName, fileName)
+		})
+}
+
+// DownloadFileStream opens a file on the primary, or on a replica when the primary cannot serve it.
+func (s *ReplicatedStorageService) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	object, err := s.primary.DownloadFileStream(ctx, bucketName, fileName)
+	if err == nil {
+		return object, nil
+	}
+	return readFromReplica(ctx, s, "DownloadFileStream", bucketName, fileName, "", err,
+		func(ctx context.Context, storage StorageInterface, _ *entity.ObjectReplicas) (io.ReadCloser, error) {
+			return storage.DownloadFileStream(ctx, bucketName, fileName)
+		})
+}
+
+// DownloadFileRange opens a byte range of a file on the primary, or on a replica when the primary cannot serve it.
+func (s *ReplicatedStorageService) DownloadFileRange(ctx context.Context, bucketName, fileName string, offset, length int64) (io.ReadCloser, error) {
+	object, err := s.primary.DownloadFileRange(ctx, bucketName, fileName, offset, length)
+	if err == nil {
+		return object, nil
+	}
+	return readFromReplica(ctx, s, "DownloadFileRange", bucketName, fileName, "", err,
+		func(ctx context.Context, storage StorageInterface, _ *entity.ObjectReplicas) (io.ReadCloser, error) {
+			return storage.DownloadFileRange(ctx, bucketName, fileName, offset, length)
+		})
+}
+
+// DownloadFileVersionStream opens a version of a file on the primary, or the copy of that version on a replica
+// when the primary cannot serve it.
+func (s *ReplicatedStorageService) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	object, err := s.primary.DownloadFileVersionStream(ctx, bucketName, fileName, versionID)
+	if err == nil {
+		return object, nil
+	}
+	return readFromReplica(ctx, s, "DownloadFileVersionStream", bucketName, fileName, versionID, err,
+		func(ctx context.Context, storage StorageInterface, record *entity.ObjectReplicas) (io.ReadCloser, error) {
+			return storage.DownloadFileVersionStream(ctx, bucketName, fileName, record.ReplicaVersionID)
+		})
+}
+
+// Exists checks a file on the primary, or on a replica when the primary errors or is missing the file.
+// A replica only answers for a file whose latest version there is a copy of a known primary version, and the
+// version ID it reports is that primary version.
+func (s *ReplicatedStorageService) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
+	exists, resp, err := s.primary.Exists(ctx, bucketName, fileName)
+	if err == nil && exists {
+		return exists, resp, nil
+	}
+
+	primaryErr := err
+	if primaryErr == nil {
+		primaryErr = fmt.Errorf("%w: %s", model.ErrFileNotFound, fileName)
+	}
+	replicaResp, failoverErr := readFromReplica(ctx, s, "Exists", bucketName, fileName, "", primaryErr,
+		func(ctx context.Context, storage StorageInterface, record *entity.ObjectReplicas) (*model.StorageTransactionResponse, error) {
+			exists, current, err := storage.Exists(ctx, bucketName, fileName)
+			if err != nil {
+				return nil, err
+			}
+			if !exists || current == nil || current.IsDeleteMarker || current.VersionID != record.ReplicaVersionID {
+				return nil, fmt.Errorf("replica does not hold the latest copy of %s", fileName)
+			}
+			translated := *current
+			translated.VersionID = record.VersionID
+			return &translated, nil
+		})
+	if failoverErr != nil {
+		return exists, resp, err
+	}
+	return true, replicaResp, nil
+}
+
+// GetFileMetadata retrieves the metadata of a file from the primary, or from a replica when the primary cannot serve it.
+func (s *ReplicatedStorageService) GetFileMetadata(ctx context.Context, bucketName, fileName string) (map[string]string, error) {
+	metadata, err := s.primary.GetFileMetadata(ctx, bucketName, fileName)
+	if err == nil {
+		return metadata, nil
+	}
+	return readFromReplica(ctx, s, "GetFileMetadata", bucketName, fileName, "", err,
+		func(ctx context.Context, storage StorageInterface, _ *entity.ObjectReplicas) (map[string]string, error) {
+			return storage.GetFileMetadata(ctx, bucketName, fileName)
+		})
+}
+
+// ListFiles lists the files in a bucket of the primary.
+func (s *ReplicatedStorageService) ListFiles(ctx context.Context, bucketName string) ([]string, error) {
+	return s.primary.ListFiles(ctx, bucketName)
+}
+
+// ListFileVersion lists the versions of a file on the primary.
+func (s *ReplicatedStorageService) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	return s.primary.ListFileVersion(ctx, bucketName, fileName)
+}
+
+// DeleteFile places a delete marker on a file on the primary and on every replica that holds the file.
+func (s *ReplicatedStorageService) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	if err := s.primary.DeleteFile(ctx, bucketName, fileName); err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas of deleted file", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		return nil
+	}
+	for _, replica := range s.replicas {
+		if !holdsCopy(records, replica.Name) {
+			continue
+		}
+		s.enqueue(ctx, &entity.ObjectReplicas{
+			Replica:    replica.Name,
+			BucketName: bucketName,
+			ObjectName: fileName,
+			Operation:  constant.ReplicaOperationMarkDeleted,
+		})
+	}
+	return nil
+}
+
+// DeleteFileVersion removes a version of a file from the primary and its copy from every replica. A copy that was
+// never written is no longer owed.
+func (s *ReplicatedStorageService) DeleteFileVersion(ctx context.Context, bucketName, fileName, versionID string) error {
+	if err := s.primary.DeleteFileVersion(ctx, bucketName, fileName, versionID); err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas of deleted file version", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.String("version_id", versionID), slog.Any("error", err))
+		return nil
+	}
+	for i := range records {
+		record := &records[i]
+		if record.VersionID != versionID || record.Operation == constant.ReplicaOperationDelete {
+			continue
+		}
+		if record.Operation != constant.ReplicaOperationCopy || record.Status != constant.ReplicaStatusSynced {
+			if err := s.objectReplicaRepository.Delete(ctx, record.ID); err != nil {
+				slog.Error("Failed to cancel object replica", slog.String("id", record.ID), slog.Any("error", err))
+			}
+			continue
+		}
+		// The mapping to the replica version becomes the delete owed to the replica
+		record.Operation = constant.ReplicaOperationDelete
+		record.Status = constant.ReplicaStatusPending
+		record.Attempts = 0
+		record.LastError = ""
+		s.apply(ctx, record)
+	}
+	return nil
+}
+
+// RestoreFile makes a version of a file current again on the primary, then on every replica that holds the file.
+func (s *ReplicatedStorageService) RestoreFile(ctx context.Context, bucketName, fileName, versionID string) error {
+	if err := s.primary.RestoreFile(ctx, bucketName, fileName, versionID); err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	exists, current, err := s.primary.Exists(ctx, bucketName, fileName)
+	if err != nil || !exists || current == nil {
+		slog.Error("Failed to look up restored file on primary", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		return nil
+	}
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas of restored file", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		return nil
+	}
+
+	for _, replica := range s.replicas {
+		if !holdsCopy(records, replica.Name) {
+			continue
+		}
+		copied := copyRecord(records, replica.Name, current.VersionID)
+		switch {
+		case copied == nil:
+			// Restoring copied the version on the primary; the copy is replicated like any new version
+			s.enqueue(ctx, &entity.ObjectReplicas{
+				Replica:    replica.Name,
+				BucketName: bucketName,
+				ObjectName: fileName,
+				VersionID:  current.VersionID,
+				Operation:  constant.ReplicaOperationCopy,
+			})
+		case copied.Status == constant.ReplicaStatusSynced:
+			s.enqueue(ctx, &entity.ObjectReplicas{
+				Replica:          replica.Name,
+				BucketName:       bucketName,
+				ObjectName:       fileName,
+				VersionID:        current.VersionID,
+				ReplicaVersionID: copied.ReplicaVersionID,
+				Operation:        constant.ReplicaOperationRestore,
+			})
+		}
+		// A copy still pending lands on the replica as its latest version, which restores it there
+	}
+	return nil
+}
+
+// SetFileRetention extends the retention of a file on the primary, then on every replica that holds the file.
+func (s *ReplicatedStorageService) SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error {
+	if err := s.primary.SetFileRetention(ctx, bucketName, fileName, retainUntil); err != nil {
+		return err
+	}
+	s.forEachHolder(ctx, bucketName, fileName, "SetFileRetention", func(storage StorageInterface) error {
+		return storage.SetFileRetention(ctx, bucketName, fileName, retainUntil)
+	})
+	return nil
+}
+
+// SetFileLegalHold places or releases the legal hold of a file on the primary, then on every replica that holds the file.
+func (s *ReplicatedStorageService) SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error {
+	if err := s.primary.SetFileLegalHold(ctx, bucketName, fileName, hold); err != nil {
+		return err
+	}
+	s.forEachHolder(ctx, bucketName, fileName, "SetFileLegalHold", func(storage StorageInterface) error {
+		return storage.SetFileLegalHold(ctx, bucketName, fileName, hold)
+	})
+	return nil
+}
+
+// CreateMultipartUpload starts a multipart upload on the primary; the assembled file is replicated on completion.
+func (s *ReplicatedStorageService) CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error) {
+	return s.primary.CreateMultipartUpload(ctx, bucketName, fileName)
+}
+
+// UploadPart uploads one part of a multipart upload to the primary.
+func (s *ReplicatedStorageService) UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error) {
+	return s.primary.UploadPart(ctx, bucketName, fileName, uploadID, partNumber, part, partSize)
+}
+
+// AbortMultipartUpload discards a multipart upload on the primary.
+func (s *ReplicatedStorageService) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	return s.primary.AbortMultipartUpload(ctx, bucketName, fileName, uploadID)
+}
+
+// CreateBucket creates a bucket on the primary, then on the replicas. A replica that fails gets the bucket
+// when the repair worker next copies an object into it.
+func (s *ReplicatedStorageService) CreateBucket(ctx context.Context, bucketName string) error {
+	if err := s.primary.CreateBucket(ctx, bucketName); err != nil {
+		return err
+	}
+	for _, replica := range s.replicas {
+		if err := replica.Storage.CreateBucket(ctx, bucketName); err != nil {
+			slog.Warn("Failed to create bucket on storage replica", slog.String("replica", replica.Name),
+				slog.String("bucket", bucketName), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// RemoveBucket removes an empty bucket from the primary, then from the replicas.
+func (s *ReplicatedStorageService) RemoveBucket(ctx context.Context, bucketName string) error {
+	if err := s.primary.RemoveBucket(ctx, bucketName); err != nil {
+		return err
+	}
+	for _, replica := range s.replicas {
+		if err := replica.Storage.RemoveBucket(ctx, bucketName); err != nil {
+			slog.Warn("Failed to remove bucket from storage replica", slog.String("replica", replica.Name),
+				slog.String("bucket", bucketName), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// replicate copies a version just written to the primary to every replica, queueing the copies that fail.
+// It carries on when the request is cancelled, as the version is already stored on the primary.
+func (s *ReplicatedStorageService) replicate(ctx context.Context, bucketName, fileName, versionID string) {
+	ctx = context.WithoutCancel(ctx)
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas of stored file", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		return
+	}
+	if len(records) == 0 && !s.shouldReplicate(ctx, bucketName, fileName) {
+		return
+	}
+
+	for _, replica := range s.replicas {
+		s.enqueue(ctx, &entity.ObjectReplicas{
+			Replica:    replica.Name,
+			BucketName: bucketName,
+			ObjectName: fileName,
+			VersionID:  versionID,
+			Operation:  constant.ReplicaOperationCopy,
+		})
+	}
+}
+
+// shouldReplicate asks the policy whether a new object is replicated, replicating it when there is no policy
+func (s *ReplicatedStorageService) shouldReplicate(ctx context.Context, bucketName, fileName string) bool {
+	if s.policy == nil {
+		return true
+	}
+	replicate, err := s.policy(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Warn("Failed to look up replication policy, using the default", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Bool("replicate", replicate), slog.Any("error", err))
+	}
+	return replicate
+}
+
+// enqueue records an operation owed to a replica and tries it once straight away; the repair worker retries it
+// if that fails. The first retry is not due before the attempt made here has had time to settle it.
+func (s *ReplicatedStorageService) enqueue(ctx context.Context, record *entity.ObjectReplicas) {
+	record.ID = helper.GenerateCustomUUID().String()
+	record.Status = constant.ReplicaStatusPending
+	record.NextAttemptAt = time.Now().Add(replicaRepairBaseBackoff)
+	if err := s.objectReplicaRepository.Create(ctx, record); err != nil {
+		slog.Error("Failed to queue storage replica operation", slog.String("replica", record.Replica),
+			slog.String("operation", record.Operation), slog.String("object", record.ObjectName), slog.Any("error", err))
+		return
+	}
+	s.apply(ctx, record)
+}
+
+// apply carries out the operation a record owes its replica. A copy becomes the synced mapping to the replica
+// version, other operations are removed once done, and a failure is retried later. It reports whether it succeeded.
+func (s *ReplicatedStorageService) apply(ctx context.Context, record *entity.ObjectReplicas) bool {
+	replica, ok := s.replica(record.Replica)
+	if !ok {
+		s.retryLater(ctx, record, fmt.Errorf("storage replica %q is not configured", record.Replica))
+		return false
+	}
+
+	var err error
+	switch record.Operation {
+	case constant.ReplicaOperationCopy:
+		err = s.copyToReplica(ctx, replica, record)
+	case constant.ReplicaOperationDelete:
+		err = replica.Storage.DeleteFileVersion(ctx, record.BucketName, record.ObjectName, record.ReplicaVersionID)
+	case constant.ReplicaOperationMarkDeleted, constant.ReplicaOperationRestore:
+		// Copies still owed to the replica must land first, or they would undo the delete or restore
+		if err = s.awaitCopies(ctx, record); err != nil {
+			break
+		}
+		if record.Operation == constant.ReplicaOperationMarkDeleted {
+			err = replica.Storage.DeleteFile(ctx, record.BucketName, record.ObjectName)
+		} else {
+			err = replica.Storage.RestoreFile(ctx, record.BucketName, record.ObjectName, record.ReplicaVersionID)
+		}
+	default:
+		err = fmt.Errorf("unknown storage replica operation %q", record.Operation)
+	}
+	if err != nil {
+		s.retryLater(ctx, record, err)
+		return false
+	}
+
+	if record.Operation != constant.ReplicaOperationCopy {
+		if err := s.objectReplicaRepository.Delete(ctx, record.ID); err != nil {
+			slog.Error("Failed to remove finished storage replica operation", slog.String("id", record.ID), slog.Any("error", err))
+		}
+		return true
+	}
+	record.Status = constant.ReplicaStatusSynced
+	record.LastError = ""
+	if err := s.objectReplicaRepository.Update(ctx, record); err != nil {
+		slog.Error("Failed to record storage replica copy", slog.String("id", record.ID), slog.Any("error", err))
+	}
+	return true
+}
+
+// copyToReplica reads a version back from the primary and writes it to a replica as its latest version
+func (s *ReplicatedStorageService) copyToReplica(ctx context.Context, replica StorageReplica, record *entity.ObjectReplicas) error {
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "ReplicateObject", record.BucketName, record.ObjectName)
+	defer span.End()
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"storage.replica":    replica.Name,
+		"storage.version_id": record.VersionID,
+		"replica.attempt":    record.Attempts + 1,
+	})
+
+	object, err := s.primary.DownloadFileVersionStream(ctx, record.BucketName, record.ObjectName, record.VersionID)
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("failed to read version from primary: %w", err)
+	}
+	defer object.Close()
+
+	resp, err := replica.Storage.UploadFile(ctx, record.BucketName, record.ObjectName, object, -1)
+	if err != nil {
+		helper.RecordError(span, err)
+		return fmt.Errorf("failed to write version to replica: %w", err)
+	}
+	record.ReplicaVersionID = resp.VersionID
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"storage.replica_version_id": resp.VersionID,
+	})
+	helper.RecordSuccess(span, "Object replicated")
+	return nil
+}
+
+// awaitCopies fails while copies of the record's object are still owed to its replica
+func (s *ReplicatedStorageService) awaitCopies(ctx context.Context, record *entity.ObjectReplicas) error {
+	records, err := s.objectReplicaRepository.ListByObject(ctx, record.BucketName, record.ObjectName)
+	if err != nil {
+		return err
+	}
+	for _, other := range records {
+		if other.Replica == record.Replica && other.Operation == constant.ReplicaOperationCopy && other.Status == constant.ReplicaStatusPending {
+			return fmt.Errorf("waiting for pending copies of %s", record.ObjectName)
+		}
+	}
+	return nil
+}
+
+// retryLater records a failed attempt and schedules the next one
+func (s *ReplicatedStorageService) retryLater(ctx context.Context, record *entity.ObjectReplicas, cause error) {
+	record.Attempts++
+	record.LastError = cause.Error()
+	record.NextAttemptAt = time.Now().Add(replicaRepairBackoff(record.Attempts))
+	slog.Warn("Storage replica operation failed", slog.String("replica", record.Replica), slog.String("operation", record.Operation),
+		slog.String("bucket", record.BucketName), slog.String("object", record.ObjectName),
+		slog.Int("attempts", record.Attempts), slog.Any("error", cause))
+	if err := s.objectReplicaRepository.Update(ctx, record); err != nil {
+		slog.Error("Failed to reschedule storage replica operation", slog.String("id", record.ID), slog.Any("error", err))
+	}
+}
+
+// forEachHolder applies a change to every replica that holds a file, logging the replicas it fails on
+func (s *ReplicatedStorageService) forEachHolder(ctx context.Context, bucketName, fileName, operation string, change func(storage StorageInterface) error) {
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas of file", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		return
+	}
+	for _, replica := range s.replicas {
+		if !holdsCopy(records, replica.Name) {
+			continue
+		}
+		if err := change(replica.Storage); err != nil {
+			slog.Warn("Failed to apply change to storage replica", slog.String("replica", replica.Name),
+				slog.String("operation", operation), slog.String("bucket", bucketName),
+				slog.String("object", fileName), slog.Any("error", err))
+		}
+	}
+}
+
+func (s *ReplicatedStorageService) replica(name string) (StorageReplica, bool) {
+	for _, replica := range s.replicas {
+		if replica.Name == name {
+			return replica, true
+		}
+	}
+	return StorageReplica{}, false
+}
+
+// readFromReplica serves a read the primary failed with primaryErr from the first replica that can serve it,
+// returning primaryErr when none can. An empty versionID reads the latest version, which a replica only serves
+// while nothing is pending for the object there; otherwise the replica copy of that primary version is read.
+func readFromReplica[T any](ctx context.Context, s *ReplicatedStorageService, operation, bucketName, fileName, versionID string, primaryErr error,
+	read func(ctx context.Context, storage StorageInterface, record *entity.ObjectReplicas) (T, error)) (T, error) {
+	var zero T
+	if len(s.replicas) == 0 || errors.Is(primaryErr, context.Canceled) || errors.Is(primaryErr, context.DeadlineExceeded) ||
+		errors.Is(primaryErr, model.ErrRangeNotSatisfiable) {
+		return zero, primaryErr
+	}
+
+	tracer := helper.GetTracingHelper()
+	ctx, span := tracer.StartStorageSpan(ctx, "Failover", bucketName, fileName)
+	defer span.End()
+
+	helper.AddAttributes(span, map[string]interface{}{
+		"storage.failover":           true,
+		"storage.failover.operation": operation,
+		"storage.failover.reason":    primaryErr.Error(),
+	})
+
+	records, err := s.objectReplicaRepository.ListByObject(ctx, bucketName, fileName)
+	if err != nil {
+		slog.Error("Failed to look up replicas for failover", slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("error", err))
+		helper.RecordError(span, primaryErr)
+		return zero, primaryErr
+	}
+
+	for _, replica := range s.replicas {
+		record := servingRecord(records, replica.Name, versionID)
+		if record == nil {
+			continue
+		}
+		value, err := read(ctx, replica.Storage, record)
+		if err != nil {
+			slog.Warn("Storage replica could not serve failed over read", slog.String("replica", replica.Name),
+				slog.String("operation", operation), slog.String("object", fileName), slog.Any("error", err))
+			continue
+		}
+
+		helper.AddAttributes(span, map[string]interface{}{
+			"storage.replica": replica.Name,
+		})
+		helper.RecordSuccess(span, "Read served by replica")
+		slog.Warn("Storage read failed over to replica", slog.String("replica", replica.Name),
+			slog.String("operation", operation), slog.String("bucket", bucketName),
+			slog.String("object", fileName), slog.Any("primary_error", primaryErr))
+		return value, nil
+	}
+
+	helper.RecordError(span, primaryErr)
+	return zero, primaryErr
+}
+
+// servingRecord returns the synced copy a replica serves a read of versionID from, where an empty or "null" ID
+// names the latest version, or nil when the replica cannot serve it
+func servingRecord(records []entity.ObjectReplicas, replicaName, versionID string) *entity.ObjectReplicas {
+	if versionID != "" && versionID != "null" {
+		record := copyRecord(records, replicaName, versionID)
+		if record == nil || record.Status != constant.ReplicaStatusSynced {
+			return nil
+		}
+		return record
+	}
+
+	var latest *entity.ObjectReplicas
+	for i := range records {
+		if records[i].Replica != replicaName {
+			continue
+		}
+		if records[i].Status == constant.ReplicaStatusPending {
+			return nil
+		}
+		if records[i].Operation == constant.ReplicaOperationCopy {
+			latest = &records[i]
+		}
+	}
+	return latest
+}
+
+// copyRecord returns the copy of a primary version owed to or held by a replica
+func copyRecord(records []entity.ObjectReplicas, replicaName, versionID string) *entity.ObjectReplicas {
+	for i := range records {
+		if records[i].Replica == replicaName && records[i].VersionID == versionID && records[i].Operation == constant.ReplicaOperationCopy {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// holdsCopy reports whether a replica holds, or is owed, a copy of any version of an object
+func holdsCopy(records []entity.ObjectReplicas, replicaName string) bool {
+	for _, record := range records {
+		if record.Replica == replicaName && record.Operation == constant.ReplicaOperationCopy {
+			return true
+		}
+	}
+	return false
+}
+
+type ReplicatedStorageServiceParams struct {
+	Primary                 StorageInterface
+	Replicas                []StorageReplica
+	ObjectReplicaRepository repository.ObjectReplicaRepository
+	Policy                  ReplicationPolicy // decides whether new objects are replicated; nil replicates all
+	Interval                time.Duration
+	BatchSize               int
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultShareLinkTTL    = 24 * time.Hour
+	defaultShareLinkMaxTTL = 7 * 24 * time.Hour
+
+	shareLinkPassphraseIterations = 210000
+
+	shareLinkTokenPurpose = "share"
+
+	// Failed uses are limited per client address, and wrong passphrases per link, before a passphrase is hashed
+	shareLinkAttemptWindow      = 15 * time.Minute
+	shareLinkMaxFailuresPerIP   = 20
+	shareLinkMaxFailuresPerLink = 10
+)
+
+// CreateShareLink issues a signed, expiring public link to a file.
+// The link can be limited to a number of downloads, a passphrase and an IP range.
+func (c *FileService) CreateShareLink(ctx context.Context, clientID, fileUID string, request model.CreateShareLinkRequest) (*model.ShareLinkResponse, error) {
+	if clientID == "" || fileUID == "" || request.MaxDownloads < 0 {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server cannot decrypt files sealed with a customer key on its own
+	if fileMetaData.KeyFingerprint != "" {
+		return nil, model.ErrCustomerKeyRequired
+	}
+
+	ttl := c.shareLinkTTL
+	if request.ExpiresIn != "" {
+		if ttl, err = time.ParseDuration(request.ExpiresIn); err != nil {
+			return nil, model.ErrInvalidShareLinkDuration
+		}
+	}
+	if ttl <= 0 || ttl > c.shareLinkMaxTTL {
+		return nil, model.ErrInvalidShareLinkDuration
+	}
+
+	link := &entity.ShareLinks{
+		ID:           helper.GenerateCustomUUID().String(),
+		AppID:        validatedAppID,
+		FileID:       fileMetaData.FileID,
+		ExpiresAt:    time.Now().Add(ttl).Truncate(time.Second),
+		MaxDownloads: request.MaxDownloads,
+	}
+	if request.AllowedCIDR != "" {
+		prefix, err := parseAllowedCIDR(request.AllowedCIDR)
+		if err != nil {
+			return nil, err
+		}
+		link.AllowedCIDR = prefix.String()
+	}
+	if request.Passphrase != "" {
+		if link.PassphraseHash, err = hashSharePassphrase(request.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.shareLinkRepository.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Share link created", slog.String("link_id", link.ID), slog.String("file_id", link.FileID))
+	response := shareLinkResponse(link)
+	response.URL = c.publicBaseURL + "/s/" + c.signLinkToken(shareLinkTokenPurpose, link.ID, link.ExpiresAt)
+	return &response, nil
+}
+
+// ListShareLinks returns the share links issued for a file, newest first.
+func (c *FileService) ListShareLinks(ctx context.Context, clientID, fileUID string) ([]model.ShareLinkResponse, error) {
+	if clientID == "" || fileUID == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, validatedAppID, fileUID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := c.shareLinkRepository.GetByFileID(ctx, fileMetaData.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.ShareLinkResponse, 0, len(links))
+	for i := range links {
+		result = append(result, shareLinkResponse(&links[i]))
+	}
+	return result, nil
+}
+
+// RevokeShareLink stops a share link from being used again.
+func (c *FileService) RevokeShareLink(ctx context.Context, clientID, fileUID, linkID string) error {
+	if clientID == "" || fileUID == "" || linkID == "" {
+		return model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return err
+	}
+
+	link, err := c.shareLinkRepository.GetByID(ctx, linkID)
+	if err != nil {
+		return err
+	}
+	if link.AppID != validatedAppID || link.FileID != fileUID {
+		return model.ErrShareLinkNotFound
+	}
+
+	if err := c.shareLinkRepository.Revoke(ctx, link.ID); err != nil {
+		return err
+	}
+	slog.Info("Share link revoked", slog.String("link_id", link.ID), slog.String("file_id", link.FileID))
+	return nil
+}
+
+// DownloadSharedFile validates a share link token and returns a stream of the decrypted file it points to.
+// Each successful use counts against the link's download limit; every use, refused ones included, is recorded in
+// the audit trail with the link as the actor.
+func (c *FileService) DownloadSharedFile(ctx context.Context, token, passphrase, clientIP string) (*model.FileDownloadStream, error) {
+	if !c.shareLinkIPAttempts.allow(clientIP) {
+		slog.Warn("Share link attempts from address limited", slog.String("ip", clientIP))
+		return nil, model.ErrShareLinkTooManyAttempts
+	}
+
+	link, err := c.usableShareLink(ctx, token, passphrase, clientIP)
+	if err != nil {
+		if isShareLinkRefusal(err) {
+			c.shareLinkIPAttempts.fail(clientIP)
+		}
+		if link != nil {
+			_ = c.auditShareLinkUse(ctx, link, clientIP, err, nil)
+		}
+		return nil, err
+	}
+
+	app, err := c.applicationRepository.GetByID(ctx, link.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if !app.IsActive {
+		return nil, model.ErrAppNotActive
+	}
+
+	fileMetaData, err := c.fileRepository.GetMetadataByAppIDAndFileID(ctx, link.AppID, link.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.openFileDownload(ctx, fileMetaData, model.DownloadOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the download last so a failed open does not use up the link
+	if err := c.shareLinkRepository.ConsumeDownload(ctx, link.ID); err != nil {
+		result.Content.Close()
+		_ = c.auditShareLinkUse(ctx, link, clientIP, err, nil)
+		return nil, err
+	}
+
+	_ = c.auditShareLinkUse(ctx, link, clientIP, nil, map[string]interface{}{
+		"file_name": fileMetaData.File.Name,
+	})
+	return result, nil
+}
+
+// usableShareLink looks up the link a token points to and checks it may be used from clientIP with passphrase.
+// The link is returned with the error whenever it was found, so a refused use can be recorded against it.
+func (c *FileService) usableShareLink(ctx context.Context, token, passphrase, clientIP string) (*entity.ShareLinks, error) {
+	linkID, tokenErr := c.verifyLinkToken(shareLinkTokenPurpose, token, model.ErrShareLinkNotFound, model.ErrShareLinkExpired)
+	if linkID == "" {
+		return nil, tokenErr
+	}
+
+	link, err := c.shareLinkRepository.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tokenErr != nil:
+		return link, tokenErr
+	case link.RevokedAt != nil:
+		return link, model.ErrShareLinkRevoked
+	case !time.Now().Before(link.ExpiresAt):
+		return link, model.ErrShareLinkExpired
+	case link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads:
+		return link, model.ErrShareLinkExhausted
+	case link.AllowedCIDR != "" && !shareLinkAllowsIP(link.AllowedCIDR, clientIP):
+		slog.Warn("Share link used from a disallowed address", slog.String("link_id", link.ID), slog.String("ip", clientIP))
+		return link, model.ErrShareLinkIPNotAllowed
+	}
+
+	if link.PassphraseHash != "" {
+		if !c.shareLinkPassAttempts.allow(link.ID) {
+			slog.Warn("Share link passphrase attempts limited", slog.String("link_id", link.ID), slog.String("ip", clientIP))
+			return link, model.ErrShareLinkTooManyAttempts
+		}
+		if !verifySharePassphrase(link.PassphraseHash, passphrase) {
+			slog.Warn("Share link used with a wrong passphrase", slog.String("link_id", link.ID), slog.String("ip", clientIP))
+			c.shareLinkPassAttempts.fail(link.ID)
+			return link, model.ErrShareLinkPassphrase
+		}
+	}
+	return link, nil
+}
+
+// auditShareLinkUse records a download through a share link, failed when err is set
+func (c *FileService) auditShareLinkUse(ctx context.Context, link *entity.ShareLinks, clientIP string, err error, metadata map[string]interface{}) error {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["share_link"] = link.ID
+	metadata["app_id"] = link.AppID
+	metadata["client_ip"] = clientIP
+	return recordAudit(ctx, c.fileLogsRepository, auditEntry{
+		ActorID:      link.ID,
+		ActorType:    constant.ActorTypeUser,
+		ResourceType: constant.ResourceTypeFile,
+		ResourceID:   link.FileID,
+		Action:       constant.ActionTypeDownload,
+		Err:          err,
+		Metadata:     metadata,
+	})
+}
+
+// isShareLinkRefusal reports whether err refused the use of a share link, as opposed to failing to serve it
+func isShareLinkRefusal(err error) bool {
+	return errors.Is(err, model.ErrShareLinkNotFound) ||
+		errors.Is(err, model.ErrShareLinkExpired) ||
+		errors.Is(err, model.ErrShareLinkRevoked) ||
+		errors.Is(err, model.ErrShareLinkExhausted) ||
+		errors.Is(err, model.ErrShareLinkIPNotAllowed) ||
+		errors.Is(err, model.ErrShareLinkPassphrase)
+}
+
+// hashSharePassphrase derives a salted PBKDF2-SHA256 hash, encoded as "pbkdf2-sha256$iterations$salt$hash"
+func hashSharePassphrase(passphrase string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate passphrase salt: %w", err)
+	}
+	derived, err := pbkdf2.Key(sha256.New, passphrase, salt, shareLinkPassphraseIterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash passphrase: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", shareLinkPassphraseIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(derived)), nil
+}
+
+func verifySharePassphrase(encoded, passphrase string) bool {
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 4 || fields[0] != "pbkdf2-sha256" || passphrase == "" {
+		return false
+	}
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(fields[3])
+	if err != nil {
+		return false
+	}
+	derived, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(derived, expected) == 1
+}
+
+// parseAllowedCIDR accepts a CIDR range or a single address and returns it as a masked prefix
+func parseAllowedCIDR(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, model.ErrInvalidShareLinkCIDR
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, model.ErrInvalidShareLinkCIDR
+	}
+	return prefix.Masked(), nil
+}
+
+func shareLinkAllowsIP(allowedCIDR, clientIP string) bool {
+	prefix, err := netip.ParsePrefix(allowedCIDR)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	return prefix.Contains(addr.Unmap())
+}
+
+func shareLinkResponse(link *entity.ShareLinks) model.ShareLinkResponse {
+	return model.ShareLinkResponse{
+		ID:            link.ID,
+		FileID:        link.FileID,
+		ExpiresAt:     link.ExpiresAt.Format("2006-01-02 15:04:05"),
+		MaxDownloads:  link.MaxDownloads,
+		Downloads:     link.Downloads,
+		HasPassphrase: link.PassphraseHash != "",
+		AllowedCIDR:   link.AllowedCIDR,
+		Revoked:       link.RevokedAt != nil,
+		CreatedAt:     link.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"time"
+)
+
+// Storage backends files can be kept in, selected with STORAGE_BACKEND
+const (
+	StorageBackendMinIO      = "minio"
+	StorageBackendFilesystem = "filesystem"
+	StorageBackendMemory     = "memory"
+)
+
+// storedObjectVersion describes one version of an object kept by the filesystem and in-memory backends, which
+// version objects the way a versioned bucket with object lock does. The filesystem backend stores it as the
+// JSON sidecar next to the contents of the version.
+type storedObjectVersion struct {
+	VersionID      string     `json:"version_id"`
+	Size           int64      `json:"size"`
+	ChecksumSHA256 string     `json:"checksum_sha256,omitempty"`
+	LastModified   time.Time  `json:"last_modified"`
+	DeleteMarker   bool       `json:"delete_marker,omitempty"`
+	RetainUntil    *time.Time `json:"retain_until,omitempty"`
+	LegalHold      bool       `json:"legal_hold,omitempty"`
+}
+
+// locked reports whether a legal hold or retention keeps the version from being removed
+func (v *storedObjectVersion) locked(now time.Time) bool {
+	return v.LegalHold || (v.RetainUntil != nil && now.Before(*v.RetainUntil))
+}
+
+// response describes the version as the latest one written to location
+func (v *storedObjectVersion) response(location string) *model.StorageTransactionResponse {
+	return &model.StorageTransactionResponse{
+		VersionID:      v.VersionID,
+		LastModified:   v.LastModified.String(),
+		Location:       location,
+		ChecksumSHA256: v.ChecksumSHA256,
+		Size:           v.Size,
+		IsLatest:       true,
+		IsDeleteMarker: v.DeleteMarker,
+	}
+}
+
+// versionClock hands out version IDs that sort in the order the versions were written. Callers serialise access.
+type versionClock struct {
+	last int64
+}
+
+func (c *versionClock) next() (string, error) {
+	now := time.Now().UnixNano()
+	if now <= c.last {
+		now = c.last + 1
+	}
+	c.last = now
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate version ID: %w", err)
+	}
+	return fmt.Sprintf("%016x%s", now, hex.EncodeToString(suffix)), nil
+}
+
+// sortVersions orders versions oldest first
+func sortVersions(versions []storedObjectVersion) {
+	sort.Slice(versions, func(i, j int) bool { return versions[i].VersionID < versions[j].VersionID })
+}
+
+// liveVersion returns the latest version of an object, or nil when it has none or the latest is a delete marker
+func liveVersion(versions []storedObjectVersion) *storedObjectVersion {
+	if len(versions) == 0 || versions[len(versions)-1].DeleteMarker {
+		return nil
+	}
+	return &versions[len(versions)-1]
+}
+
+// findVersion returns the index of a version, where an empty or "null" ID names the latest version
+func findVersion(versions []storedObjectVersion, fileName, versionID string) (int, error) {
+	if versionID == "" || versionID == "null" {
+		if liveVersion(versions) == nil {
+			return -1, fmt.Errorf("%w: %s", model.ErrFileNotFound, fileName)
+		}
+		return len(versions) - 1, nil
+	}
+	for i := range versions {
+		if versions[i].VersionID == versionID {
+			if versions[i].DeleteMarker {
+				return -1, fmt.Errorf("%w: %s version %s is a delete marker", model.ErrFileVersionNotFound, fileName, versionID)
+			}
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: %s version %s", model.ErrFileVersionNotFound, fileName, versionID)
+}
+
+// versionIDsNewestFirst lists the IDs of versions newest first, delete markers included, as a versioned bucket does
+func versionIDsNewestFirst(versions []storedObjectVersion) []string {
+	ids := make([]string, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		ids = append(ids, versions[i].VersionID)
+	}
+	return ids
+}
+
+// restorePlan works out how to make a version the current contents of an object again: the delete markers
+// stacked on top of the latest version are removed and, when that does not leave the version current, it is copied
+func restorePlan(versions []storedObjectVersion, fileName, versionID string) (markers []string, copyVersion bool, err error) {
+	target := -1
+	for i := range versions {
+		if versions[i].VersionID == versionID && !versions[i].DeleteMarker {
+			target = i
+		}
+	}
+	if target < 0 {
+		return nil, false, fmt.Errorf("%w: %s version %s", model.ErrFileVersionNotFound, fileName, versionID)
+	}
+
+	latest := len(versions) - 1
+	for latest >= 0 && versions[latest].DeleteMarker {
+		markers = append(markers, versions[latest].VersionID)
+		latest--
+	}
+	return markers, latest != target, nil
+}
+
+// extendRetention applies a retention date to a version, which may be extended but never shortened
+func extendRetention(v *storedObjectVersion, fileName string, retainUntil time.Time) error {
+	if v.RetainUntil != nil && retainUntil.Before(*v.RetainUntil) {
+		return fmt.Errorf("%w: %s version %s", model.ErrRetentionShortened, fileName, v.VersionID)
+	}
+	until := retainUntil.UTC()
+	v.RetainUntil = &until
+	return nil
+}
+
+// checkedSize fails an upload that did not deliver the size it announced; -1 accepts any size
+func checkedSize(fileName string, expected, written int64) error {
+	if expected >= 0 && written != expected {
+		return fmt.Errorf("upload failed for file %s: read %d of %d bytes", fileName, written, expected)
+	}
+	return nil
+}
+
+// partETag is the ETag of a multipart upload part, the hex MD5 of its contents
+func partETag(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newPartHash returns the running hash ETags of multipart upload parts are computed with
+func newPartHash() hash.Hash {
+	return md5.New()
+}
+
+// checksumWriter computes the SHA-256 checksum and size of what is written through it
+type checksumWriter struct {
+	hash hash.Hash
+	size int64
+}
+
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{hash: sha256.New()}
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return w.hash.Write(p)
+}
+
+// checksum returns the base64 SHA-256 of the contents, as object storage reports it
+func (w *checksumWriter) checksum() string {
+	return base64.StdEncoding.EncodeToString(w.hash.Sum(nil))
+}
+
+// contextReader stops a copy once its context is cancelled
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// limitedReadCloser reads a limited part of a stream and closes the whole stream
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSyslogRetryInterval = 10 * time.Second
+	syslogQueueSize            = 1024
+	syslogDialTimeout          = 5 * time.Second
+	syslogWriteTimeout         = 10 * time.Second
+
+	// syslogFacilityAudit is the "log audit" facility of RFC 5424
+	syslogFacilityAudit = 13
+	// syslogStructuredDataID names the structured data element; 32473 is the enterprise number reserved for examples
+	syslogStructuredDataID = "crypsis@32473"
+)
+
+// SyslogForwarder sends audit events to a syslog collector as RFC 5424 messages over TCP or TLS,
+// framed by octet counting (RFC 6587). Events it cannot deliver, because the collector is unreachable or
+// the queue is full, are appended to a spool file on disk and replayed in order once the collector is back.
+type SyslogForwarder struct {
+	address       string
+	tlsConfig     *tls.Config
+	hostname      string
+	appName       string
+	spoolPath     string
+	retryInterval time.Duration
+	queue         chan string
+
+	conn net.Conn // only used by the Run goroutine
+
+	spoolMu sync.Mutex // guards the spool file and spooled
+	spooled bool       // whether undelivered events wait in the spool
+}
+
+func NewSyslogForwarder(params SyslogForwarderParams) (LogForwarderInterface, error) {
+	if params.Address == "" {
+		return nil, errors.New("syslog address is required")
+	}
+	if params.SpoolPath == "" {
+		return nil, errors.New("syslog spool path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(params.SpoolPath), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create syslog spool directory: %w", err)
+	}
+
+	var tlsConfig *tls.Config
+	if params.UseTLS {
+		var err error
+		if tlsConfig, err = syslogTLSConfig(params); err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	appName := params.AppName
+	if appName == "" {
+		appName = "crypsis-backend"
+	}
+	retryInterval := params.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultSyslogRetryInterval
+	}
+
+	forwarder := &SyslogForwarder{
+		address:       params.Address,
+		tlsConfig:     tlsConfig,
+		hostname:      hostname,
+		appName:       appName,
+		spoolPath:     params.SpoolPath,
+		retryInterval: retryInterval,
+		queue:         make(chan string, syslogQueueSize),
+	}
+	// Events spooled before a restart are replayed first
+	forwarder.spooled = fileHasData(params.SpoolPath) || fileHasData(forwarder.replayPath())
+	return forwarder, nil
+}
+
+// syslogTLSConfig trusts the system roots plus the configured CA, presenting a client certificate when one is set
+func syslogTLSConfig(params SyslogForwarderParams) (*tls.Config, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if params.CAPath != "" {
+		caPEM, err := os.ReadFile(params.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read syslog CA certificate: %w", err)
+		}
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no valid CA certificate found for syslog")
+		}
+	}
+
+	tlsConfig := &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	if params.CertPath != "" && params.KeyPath != "" {
+		clientCert, err := tls.LoadX509KeyPair(params.CertPath, params.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load syslog client certificate and key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return tlsConfig, nil
+}
+
+// Publish queues an event for forwarding without blocking; when the queue is full the event is spooled to disk
+func (f *SyslogForwarder) Publish(log *entity.FileLogs) {
+	message := f.formatMessage(log)
+	select {
+	case f.queue <- message:
+	default:
+		f.spool(message)
+	}
+}
+
+// Run forwards queued events and replays the spool every retry interval until the context is cancelled.
+// Events still queued on shutdown are spooled so they are sent after the next start.
+func (f *SyslogForwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.retryInterval)
+	defer ticker.Stop()
+	defer f.disconnect()
+
+	f.replaySpool()
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case message := <-f.queue:
+					f.spool(message)
+				default:
+					return
+				}
+			}
+		case message := <-f.queue:
+			// Later events wait behind spooled ones so the collector receives them in order
+			if f.hasSpooled() || f.send(message) != nil {
+				f.spool(message)
+			}
+		case <-ticker.C:
+			f.replaySpool()
+		}
+	}
+}
+
+// send writes one framed message to the collector, connecting first when needed
+func (f *SyslogForwarder) send(message string) error {
+	if f.conn == nil {
+		conn, err := f.dial()
+		if err != nil {
+			slog.Warn("Failed to connect to syslog collector", slog.String("address", f.address), slog.Any("error", err))
+			return err
+		}
+		f.conn = conn
+	}
+
+	_ = f.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if _, err := fmt.Fprintf(f.conn, "%d %s", len(message), message); err != nil {
+		slog.Warn("Failed to forward audit event to syslog", slog.String("address", f.address), slog.Any("error", err))
+		f.disconnect()
+		return err
+	}
+	return nil
+}
+
+func (f *SyslogForwarder) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if f.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", f.address, f.tlsConfig)
+	}
+	return dialer.Dial("tcp", f.address)
+}
+
+func (f *SyslogForwarder) disconnect() {
+	if f.conn != nil {
+		_ = f.conn.Close()
+		f.conn = nil
+	}
+}
+
+// spool appends a message to the spool file; messages never contain newlines, so each takes one line
+func (f *SyslogForwarder) spool(message string) {
+	f.spoolMu.Lock()
+	defer f.spoolMu.Unlock()
+
+	if err := appendLine(f.spoolPath, message); err != nil {
+		slog.Error("Failed to spool audit event, event is lost", slog.String("spool", f.spoolPath), slog.Any("error", err))
+		return
+	}
+	f.spooled = true
+}
+
+func (f *SyslogForwarder) hasSpooled() bool {
+	f.spoolMu.Lock()
+	defer f.spoolMu.Unlock()
+	return f.spooled
+}
+
+func (f *SyslogForwarder) replayPath() string {
+	return f.spoolPath + ".replay"
+}
+
+// replaySpool sends spooled messages in order. The spool is moved aside while it is replayed so new events
+// can keep being spooled; whatever could not be sent is put back in front of them.
+func (f *SyslogForwarder) replaySpool() {
+	if !f.hasSpooled() {
+		return
+	}
+
+	f.spoolMu.Lock()
+	// A replay file left by an interrupted replay is older than the spool and goes first
+	if !fileHasData(f.replayPath()) {
+		if err := os.Rename(f.spoolPath, f.replayPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			f.spoolMu.Unlock()
+			slog.Error("Failed to replay syslog spool", slog.String("spool", f.spoolPath), slog.Any("error", err))
+			return
+		}
+	}
+	f.spoolMu.Unlock()
+
+	replay, err := os.Open(f.replayPath())
+	if err != nil {
+		f.spoolMu.Lock()
+		f.spooled = fileHasData(f.spoolPath)
+		f.spoolMu.Unlock()
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read syslog spool", slog.String("spool", f.replayPath()), slog.Any("error", err))
+		}
+		return
+	}
+
+	// offset tracks the start of the first message not yet sent
+	var offset int64
+	sent := 0
+	failed := false
+	scanner := bufio.NewScanner(replay)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		message := scanner.Text()
+		if message != "" && f.send(message) != nil {
+			failed = true
+			break
+		}
+		offset += int64(len(scanner.Bytes())) + 1
+		sent++
+	}
+	_ = replay.Close()
+	if err := scanner.Err(); err != nil {
+		slog.Error("Failed to read syslog spool", slog.String("spool", f.replayPath()), slog.Any("error", err))
+		failed = true
+	}
+
+	f.spoolMu.Lock()
+	defer f.spoolMu.Unlock()
+	if failed {
+		if err := f.requeue(offset); err != nil {
+			slog.Error("Failed to return unsent events to the syslog spool", slog.String("spool", f.spoolPath), slog.Any("error", err))
+		}
+		return
+	}
+	if err := os.Remove(f.replayPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Failed to clear replayed syslog spool", slog.String("spool", f.replayPath()), slog.Any("error", err))
+		return
+	}
+	slog.Info("Replayed spooled audit events to syslog", slog.Int("events", sent))
+	// Events spooled during the replay are sent on the next tick
+	f.spooled = fileHasData(f.spoolPath)
+}
+
+// requeue puts the replay file from offset on in front of the events spooled since the replay started.
+// The caller holds spoolMu.
+func (f *SyslogForwarder) requeue(offset int64) error {
+	replay, err := os.Open(f.replayPath())
+	if err != nil {
+		return err
+	}
+	defer replay.Close()
+	if _, err := replay.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmpPath := f.spoolPath + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, replay); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if newer, err := os.Open(f.spoolPath); err == nil {
+		_, err = io.Copy(tmp, newer)
+		_ = newer.Close()
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, f.spoolPath); err != nil {
+		return err
+	}
+	return os.Remove(f.replayPath())
+}
+
+// formatMessage renders a log entry as an RFC 5424 message with the entry in structured data and as JSON
+func (f *SyslogForwarder) formatMessage(log *entity.FileLogs) string {
+	severity := 6 // informational
+	if cefSeverity(log.Action, log.Outcome) >= 7 {
+		severity = 5 // notice
+	}
+
+	params := [][2]string{
+		{"log_id", strconv.FormatUint(uint64(log.ID), 10)},
+		{"actor_id", log.ActorID},
+		{"actor_type", log.ActorType},
+		{"file_id", log.FileID},
+	}
+	if log.IP != "" {
+		params = append(params, [2]string{"ip", log.IP})
+	}
+	if log.Hash != "" {
+		params = append(params, [2]string{"hash", log.Hash})
+	}
+	for _, param := range [][2]string{
+		{"resource_type", log.ResourceType},
+		{"resource_id", log.ResourceID},
+		{"outcome", log.Outcome},
+		{"trace_id", log.TraceID},
+	} {
+		if param[1] != "" {
+			params = append(params, param)
+		}
+	}
+	var structuredData strings.Builder
+	structuredData.WriteString("[" + syslogStructuredDataID)
+	for _, param := range params {
+		fmt.Fprintf(&structuredData, ` %s="%s"`, param[0], syslogParamValue(param[1]))
+	}
+	structuredData.WriteString("]")
+
+	body, _ := json.Marshal(newAuditEvent(log))
+	return fmt.Sprintf("<%d>1 %s %s %s - %s %s %s",
+		syslogFacilityAudit*8+severity,
+		log.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderValue(f.hostname, 255),
+		syslogHeaderValue(f.appName, 48),
+		syslogHeaderValue(log.Action, 32),
+		structuredData.String(),
+		body,
+	)
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogParamValue escapes a structured data value and drops control characters so a message stays on one line
+func syslogParamValue(value string) string {
+	return syslogParamEscaper.Replace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value))
+}
+
+// syslogHeaderValue keeps the printable ASCII of a header field, up to max characters, or NILVALUE when none is left
+func syslogHeaderValue(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func fileHasData(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() > 0
+}
+
+// appendLine appends a line to a file and syncs it to disk
+func appendLine(path, line string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type SyslogForwarderParams struct {
+	Address       string // host:port of the collector
+	UseTLS        bool
+	CAPath        string
+	CertPath      string
+	KeyPath       string
+	AppName       string
+	SpoolPath     string
+	RetryInterval time.Duration
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultUploadJobInterval    = 30 * time.Second
+	defaultUploadJobMaxAttempts = 8
+	defaultUploadStaleAfter     = 6 * time.Hour
+	uploadJobBatchSize          = 50
+	uploadJobBaseBackoff        = 5 * time.Second
+	uploadJobMaxBackoff         = 10 * time.Minute
+)
+
+// uploadJobPayload is the serialized form of the rows an upload job commits
+type uploadJobPayload struct {
+	File     entity.Files    `json:"file"`
+	Metadata entity.Metadata `json:"metadata"`
+}
+
+// uploadJobBackoff returns the exponential delay before the next attempt, capped at uploadJobMaxBackoff
+func uploadJobBackoff(attempts int) time.Duration {
+	delay := uploadJobBaseBackoff
+	for i := 0; i < attempts && delay < uploadJobMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > uploadJobMaxBackoff {
+		delay = uploadJobMaxBackoff
+	}
+	return delay
+}
+
+// UploadJobService drains the upload outbox.
+// Pending jobs with a payload are committed to the database; jobs that keep failing, or that never received
+// a payload because the upload was interrupted, are compensated by removing the orphaned object from storage.
+// It also expires abandoned resumable upload sessions and discards their parts.
+type UploadJobService struct {
+	storageService          StorageInterface
+	uploadJobRepository     repository.UploadJobRepository
+	uploadSessionRepository repository.UploadSessionRepository
+	interval                time.Duration
+	maxAttempts             int
+}
+
+func NewUploadJobService(params UploadJobServiceParams) UploadJobInterface {
+	interval := params.Interval
+	if interval <= 0 {
+		interval = defaultUploadJobInterval
+	}
+	maxAttempts := params.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultUploadJobMaxAttempts
+	}
+
+	return &UploadJobService{
+		storageService:          params.StorageService,
+		uploadJobRepository:     params.UploadJobRepository,
+		uploadSessionRepository: params.UploadSessionRepository,
+		interval:                interval,
+		maxAttempts:             maxAttempts,
+	}
+}
+
+// Run processes due jobs and expired sessions every interval until the context is cancelled
+func (s *UploadJobService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ProcessDueJobs(ctx); err != nil {
+			slog.Error("Failed to process upload jobs", slog.Any("error", err))
+		}
+		if _, err := s.ExpireUploadSessions(ctx); err != nil {
+			slog.Error("Failed to expire upload sessions", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDueJobs handles every pending or compensating job whose next attempt is due
+func (s *UploadJobService) ProcessDueJobs(ctx context.Context) (int, error) {
+	jobs, err := s.uploadJobRepository.GetDue(ctx, time.Now(), uploadJobBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		switch {
+		case job.Status == constant.JobStatusCompensating:
+			s.compensate(ctx, job)
+		case job.Payload == "":
+			// The upload never reported back: treat whatever reached storage as orphaned
+			job.LastError = "upload did not complete"
+			s.compensate(ctx, job)
+		default:
+			s.commit(ctx, job)
+		}
+	}
+	return len(jobs), nil
+}
+
+// ExpireUploadSessions closes resumable uploads past their expiry and discards their parts from storage.
+// Sessions that were completing only lose their parts; the object itself is left to the upload job that owns it.
+func (s *UploadJobService) ExpireUploadSessions(ctx context.Context) (int, error) {
+	sessions, err := s.uploadSessionRepository.GetExpired(ctx, time.Now(), uploadJobBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range sessions {
+		session := &sessions[i]
+		if session.Status == constant.SessionStatusOpen {
+			err = s.uploadSessionRepository.Close(ctx, session, constant.SessionStatusExpired)
+		} else {
+			err = s.uploadSessionRepository.UpdateStatus(ctx, session, []string{constant.SessionStatusCompleting}, constant.SessionStatusExpired)
+		}
+		if err != nil {
+			if !errors.Is(err, model.ErrUploadSessionClosed) {
+				slog.Error("Failed to expire upload session", slog.String("upload_id", session.ID), slog.Any("error", err))
+			}
+			continue
+		}
+
+		if err := s.storageService.AbortMultipartUpload(ctx, session.BucketName, session.ObjectName, session.StorageUploadID); err != nil {
+			slog.Warn("Failed to discard expired upload parts", slog.String("upload_id", session.ID), slog.Any("error", err))
+		}
+		slog.Info("Expired upload session", slog.String("upload_id", session.ID), slog.String("file_id", session.FileID))
+		expired++
+	}
+	return expired, nil
+}
+
+// commit retries the database commit of an object already in storage
+func (s *UploadJobService) commit(ctx context.Context, job *entity.UploadJobs) {
+	var payload uploadJobPayload
+	err := json.Unmarshal([]byte(job.Payload), &payload)
+	if err == nil {
+		err = s.uploadJobRepository.Complete(ctx, job, &payload.File, &payload.Metadata)
+	}
+	if err == nil {
+		slog.Info("Committed deferred upload", slog.String("file_id", job.FileID), slog.Int("attempts", job.Attempts+1))
+		return
+	}
+	if errors.Is(err, model.ErrUploadJobNotFound) {
+		// Settled concurrently by the request that created it
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+	if job.Attempts >= s.maxAttempts {
+		slog.Error("Giving up on upload commit", slog.String("file_id", job.FileID), slog.Any("error", err))
+		s.compensate(ctx, job)
+		return
+	}
+
+	job.NextAttemptAt = time.Now().Add(uploadJobBackoff(job.Attempts))
+	if err := s.uploadJobRepository.Update(ctx, job, constant.JobStatusPending); err != nil {
+		slog.Error("Failed to reschedule upload job", slog.String("file_id", job.FileID), slog.Any("error", err))
+	}
+}
+
+// compensate removes the object written by an abandoned job and marks the job failed.
+// For updates only the new version is removed so the previously committed version stays current.
+func (s *UploadJobService) compensate(ctx context.Context, job *entity.UploadJobs) {
+	err := s.removeOrphan(ctx, job)
+	if err == nil {
+		if err := s.uploadJobRepository.Fail(ctx, job); err != nil && !errors.Is(err, model.ErrUploadJobNotFound) {
+			slog.Error("Failed to mark upload job as failed", slog.String("file_id", job.FileID), slog.Any("error", err))
+		}
+		return
+	}
+
+	slog.Warn("Failed to remove orphaned object", slog.String("file_id", job.FileID), slog.Any("error", err))
+	from := job.Status
+	job.Status = constant.JobStatusCompensating
+	job.Attempts++
+	job.NextAttemptAt = time.Now().Add(uploadJobBackoff(job.Attempts))
+	if err := s.uploadJobRepository.Update(ctx, job, from); err != nil {
+		slog.Error("Failed to reschedule upload job", slog.String("file_id", job.FileID), slog.Any("error", err))
+	}
+}
+
+func (s *UploadJobService) removeOrphan(ctx context.Context, job *entity.UploadJobs) error {
+	if job.Operation == constant.JobOperationUpload {
+		return s.storageService.DeleteFileVersion(ctx, job.BucketName, job.ObjectName, job.VersionID)
+	}
+
+	versionID := job.VersionID
+	if versionID == "" {
+		// The update was interrupted before storage answered; only a version newer than the base can be orphaned
+		exists, current, err := s.storageService.Exists(ctx, job.BucketName, job.ObjectName)
+		if err != nil {
+			return err
+		}
+		if !exists || current == nil || current.VersionID == job.BaseVersionID {
+			return nil
+		}
+		versionID = current.VersionID
+	}
+
+	if versionID == job.BaseVersionID {
+		return nil
+	}
+	if versionID == "" || versionID == "null" {
+		// Without versioning the previous content is already overwritten and cannot be restored
+		slog.Error("Cannot roll back update without bucket versioning", slog.String("file_id", job.FileID), slog.String("object", job.ObjectName))
+		return nil
+	}
+	return s.storageService.DeleteFileVersion(ctx, job.BucketName, job.ObjectName, versionID)
+}
+
+type UploadJobServiceParams struct {
+	StorageService          StorageInterface
+	UploadJobRepository     repository.UploadJobRepository
+	UploadSessionRepository repository.UploadSessionRepository
+	Interval                time.Duration
+	MaxAttempts             int
+}
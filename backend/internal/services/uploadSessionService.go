@@ -0,0 +1,567 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// minUploadPartSize is the smallest plaintext size accepted for every part but the last, matching the S3 minimum.
+	minUploadPartSize = 5 << 20
+	// maxUploadPartSize bounds the memory used to seal a single part before it is sent to storage.
+	maxUploadPartSize = 64 << 20
+	// maxUploadParts is the largest part number storage accepts.
+	maxUploadParts = 10000
+	// defaultUploadSessionTTL is how long a session stays open after its last activity.
+	defaultUploadSessionTTL = 24 * time.Hour
+)
+
+// InitiateUpload starts a resumable upload for the calling app.
+// The file key is generated up front and kept wrapped on the session so each part can be sealed independently.
+func (c *FileService) InitiateUpload(ctx context.Context, clientID, fileName string) (*model.UploadSessionResponse, error) {
+	if fileName == "" {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	config, err := c.clientConfig(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileUID := helper.GenerateCustomUUID().String()
+	key, keyUID, wrappedKey, err := c.getEncryptionKey(ctx, validatedAppID, fileUID)
+	if err != nil {
+		slog.Error("Failed to generate key", slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", model.ErrKeyGenerationFailed, err)
+	}
+	defer secureKeyString(key)()
+
+	// Without a KMS key to export later, the key only survives between requests wrapped with the KEK;
+	// keys of apps holding their own keys arrive already wrapped by the app's key manager
+	if wrappedKey != "" {
+		slog.Debug("Using key wrapped by the app's key manager", slog.String("key_uid", keyUID))
+	} else if c.keyConfig.KEK != "" {
+		wrappedKey, err = c.cryptoService.EncryptString(c.keyConfig.KEK, key)
+		if err != nil {
+			slog.Error("Failed to wrap key", slog.Any("error", err))
+			return nil, model.ErrKeyGenerationFailed
+		}
+	} else if keyUID == "" {
+		return nil, model.ErrKeyGenerationFailed
+	}
+
+	objectName := createFileName(fileUID)
+	storageUploadID, err := c.storageService.CreateMultipartUpload(ctx, config.BucketName, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrFileUploadFailed, err)
+	}
+
+	file := &entity.Files{
+		ID:         fileUID,
+		Name:       fileName,
+		AppID:      validatedAppID,
+		UserID:     "Not Available", // TO BE ADDED
+		MimeType:   "application/octet-stream",
+		BucketName: config.BucketName,
+		Status:     constant.FileStatusPending,
+	}
+	session := &entity.UploadSessions{
+		ID:              helper.GenerateCustomUUID().String(),
+		AppID:           validatedAppID,
+		FileID:          fileUID,
+		FileName:        fileName,
+		BucketName:      config.BucketName,
+		ObjectName:      objectName,
+		StorageUploadID: storageUploadID,
+		KeyUID:          keyUID,
+		EncKey:          wrappedKey,
+		HeaderVersion:   CipherHeaderVersion,
+		HashAlgo:        config.HashMethod,
+		Status:          constant.SessionStatusOpen,
+		ExpiresAt:       time.Now().Add(c.uploadSessionTTL),
+	}
+	if err := c.uploadSessionRepository.Create(ctx, session, file); err != nil {
+		_ = c.storageService.AbortMultipartUpload(context.Background(), config.BucketName, objectName, storageUploadID)
+		return nil, err
+	}
+
+	slog.Info("Upload session started", slog.String("upload_id", session.ID), slog.String("file_id", fileUID))
+	return uploadSessionResponse(session, nil), nil
+}
+
+// UploadPart encrypts one part of a resumable upload as an independent streaming AEAD ciphertext and stores it.
+// Sending the same part number again replaces the earlier part, so clients can simply retry after a dropped connection.
+func (c *FileService) UploadPart(ctx context.Context, clientID, sessionID string, partNumber int, input io.Reader) (*model.UploadPartResponse, error) {
+	if partNumber < 1 || partNumber > maxUploadParts {
+		return nil, model.ErrInvalidPartNumber
+	}
+	if input == nil {
+		return nil, model.ErrInvalidInput
+	}
+
+	session, err := c.getOpenUploadSession(ctx, clientID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := c.clientConfig(ctx, session.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.unwrapFileKey(ctx, &entity.Metadata{KeyUID: session.KeyUID, EncKey: session.EncKey, File: entity.Files{AppID: session.AppID}})
+	if err != nil {
+		return nil, err
+	}
+	defer secureKeyString(key)()
+
+	// Read first 512 bytes of the first part for MIME detection without consuming them
+	reader := bufio.NewReader(io.LimitReader(input, maxUploadPartSize+1))
+	header, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		slog.Error("Failed to read upload part", slog.Any("error", err))
+		return nil, model.ErrFailedToReadFile
+	}
+	if len(header) == 0 {
+		return nil, model.ErrFileIsEmpty
+	}
+
+	// Every part is hashed the same way, even when the app's hash method changes while the upload is open
+	hashMethod := c.fileHashMethod(session.HashAlgo)
+	plainHash, err := c.cryptoService.NewHash(hashMethod)
+	if err != nil {
+		return nil, model.ErrHashCalculationFailed
+	}
+
+	// Parts are bounded by maxUploadPartSize, so they are sealed in memory to learn the exact ciphertext size
+	var sealed bytes.Buffer
+	var ciphertextSink io.Writer = &sealed
+	var encHash hash.Hash
+	if config.HashEncryptedFile {
+		if encHash, err = c.cryptoService.NewHash(hashMethod); err == nil {
+			ciphertextSink = io.MultiWriter(&sealed, encHash)
+		} else {
+			slog.Warn("Failed to calculate encrypted part hash", slog.Any("error", err))
+		}
+	}
+
+	encryptor, err := c.cryptoService.NewEncryptingWriter(key, session.KeyUID, ciphertextSink, uploadPartAssociatedData(session.HeaderVersion, session.FileID, session.AppID, partNumber))
+	if err != nil {
+		slog.Error("Failed to encrypt upload part", slog.Any("error", err))
+		return nil, model.ErrFileEncryptionFailed
+	}
+	size, err := io.Copy(encryptor, io.TeeReader(reader, plainHash))
+	if err != nil {
+		slog.Error("Failed to read upload part", slog.Any("error", err))
+		return nil, model.ErrFailedToReadFile
+	}
+	if size > maxUploadPartSize {
+		return nil, model.ErrUploadPartTooLarge
+	}
+	if err := encryptor.Close(); err != nil {
+		slog.Error("Failed to encrypt upload part", slog.Any("error", err))
+		return nil, model.ErrFileEncryptionFailed
+	}
+
+	encSize := int64(sealed.Len())
+	etag, err := c.storageService.UploadPart(ctx, session.BucketName, session.ObjectName, session.StorageUploadID, partNumber, &sealed, encSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrFileUploadFailed, err)
+	}
+
+	part := &entity.UploadParts{
+		SessionID:  session.ID,
+		PartNumber: partNumber,
+		FileID:     session.FileID,
+		ETag:       etag,
+		Size:       size,
+		EncSize:    encSize,
+		Hash:       base64.StdEncoding.EncodeToString(plainHash.Sum(nil)),
+	}
+	if encHash != nil {
+		part.EncHash = base64.StdEncoding.EncodeToString(encHash.Sum(nil))
+	}
+
+	// Every part received keeps the session alive; a session completed meanwhile rejects the part
+	if partNumber == 1 {
+		session.MimeType = http.DetectContentType(header)
+	}
+	session.ExpiresAt = time.Now().Add(c.uploadSessionTTL)
+	if err := c.uploadSessionRepository.SavePart(ctx, session, part); err != nil {
+		return nil, err
+	}
+
+	return &model.UploadPartResponse{PartNumber: part.PartNumber, Size: part.Size, Hash: part.Hash}, nil
+}
+
+// CompleteUpload assembles the received parts into the stored file and commits it through the upload outbox.
+// Completing an already completed session returns the same file again, so the call is safe to retry.
+func (c *FileService) CompleteUpload(ctx context.Context, clientID, sessionID string) (*model.UploadFileResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	session, err := c.getOwnedUploadSession(ctx, validatedAppID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == constant.SessionStatusCompleted {
+		return &model.UploadFileResponse{FileName: session.FileName, FileID: session.FileID}, nil
+	}
+	if err := checkUploadSessionOpen(session); err != nil {
+		return nil, err
+	}
+	config, err := c.clientConfig(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Give the completion as long as any other upload before the sweeper may expire it
+	session.ExpiresAt = time.Now().Add(c.uploadStaleAfter)
+	if err := c.uploadSessionRepository.Update(ctx, session); err != nil {
+		return nil, err
+	}
+	// Claim the session before reading its parts, so no part can be saved after they are read
+	if err := c.uploadSessionRepository.UpdateStatus(ctx, session, []string{constant.SessionStatusOpen}, constant.SessionStatusCompleting); err != nil {
+		return nil, err
+	}
+
+	parts, err := c.checkUploadParts(ctx, validatedAppID, session)
+	if err != nil {
+		c.reopenUploadSession(session)
+		return nil, err
+	}
+
+	job := &entity.UploadJobs{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        session.FileID,
+		AppID:         validatedAppID,
+		Operation:     constant.JobOperationUpload,
+		Status:        constant.JobStatusPending,
+		BucketName:    session.BucketName,
+		ObjectName:    session.ObjectName,
+		NextAttemptAt: time.Now().Add(c.uploadStaleAfter),
+	}
+	if err := c.uploadJobRepository.Create(ctx, job, nil); err != nil {
+		c.reopenUploadSession(session)
+		return nil, err
+	}
+
+	storageParts := make([]model.StoragePart, 0, len(parts))
+	partHashes := make([]string, 0, len(parts))
+	encHashes := make([]string, 0, len(parts))
+	var size int64
+	for _, part := range parts {
+		storageParts = append(storageParts, model.StoragePart{PartNumber: part.PartNumber, ETag: part.ETag})
+		partHashes = append(partHashes, part.Hash)
+		encHashes = append(encHashes, part.EncHash)
+		size += part.Size
+	}
+
+	transactionResponse, err := c.storageService.CompleteMultipartUpload(ctx, session.BucketName, session.ObjectName, session.StorageUploadID, storageParts)
+	if err != nil {
+		c.abandonUploadJob(job, err)
+		c.reopenUploadSession(session)
+		return nil, fmt.Errorf("%w: %w", model.ErrFileUploadFailed, err)
+	}
+	c.recordStoredVersion(ctx, job, transactionResponse)
+
+	mimeType := session.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	file := &entity.Files{
+		ID:       session.FileID,
+		Name:     session.FileName,
+		MimeType: mimeType,
+		Size:     size,
+		Location: transactionResponse.Location,
+	}
+	hashMethod := c.fileHashMethod(session.HashAlgo)
+	metadata := &entity.Metadata{
+		ID:            helper.GenerateCustomUUID().String(),
+		FileID:        session.FileID,
+		Hash:          c.compositeHash(hashMethod, partHashes),
+		KeyUID:        session.KeyUID,
+		KeyAlgo:       config.EncryptionMethod,
+		HashAlgo:      hashMethod,
+		Format:        constant.CipherFormatMultipart,
+		HeaderVersion: session.HeaderVersion,
+		VersionID:     transactionResponse.VersionID,
+	}
+	if config.HashEncryptedFile {
+		metadata.EncHash = c.compositeHash(hashMethod, encHashes)
+	}
+	// Keep the wrapped key when there is no KMS key to export it from again
+	if c.saveKey || session.KeyUID == "" || isExternalKeyUID(session.KeyUID) {
+		metadata.EncKey = session.EncKey
+	}
+
+	// The multipart upload is consumed at this point, so a failed commit cannot be retried by the client
+	if err := c.commitUploadJob(ctx, job, file, metadata); err != nil {
+		_ = c.uploadSessionRepository.UpdateStatus(context.Background(), session, []string{constant.SessionStatusCompleting}, constant.SessionStatusFailed)
+		return nil, err
+	}
+
+	session.EncKey = ""
+	if err := c.uploadSessionRepository.Update(ctx, session); err != nil {
+		slog.Warn("Failed to clear upload session key", slog.String("upload_id", session.ID), slog.Any("error", err))
+	}
+	if err := c.uploadSessionRepository.UpdateStatus(ctx, session, []string{constant.SessionStatusCompleting}, constant.SessionStatusCompleted); err != nil {
+		slog.Warn("Failed to mark upload session completed", slog.String("upload_id", session.ID), slog.Any("error", err))
+	}
+
+	_ = c.saveFileLog(ctx, validatedAppID, session.FileID, constant.ActorTypeClient, string(constant.ActionTypeUpload), session.FileName)
+	return &model.UploadFileResponse{FileName: session.FileName, FileID: session.FileID}, nil
+}
+
+// AbortUpload cancels an open resumable upload and discards the parts already stored.
+func (c *FileService) AbortUpload(ctx context.Context, clientID, sessionID string) error {
+	session, err := c.getOpenUploadSession(ctx, clientID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.uploadSessionRepository.Close(ctx, session, constant.SessionStatusAborted); err != nil {
+		return err
+	}
+	if err := c.storageService.AbortMultipartUpload(ctx, session.BucketName, session.ObjectName, session.StorageUploadID); err != nil {
+		slog.Warn("Failed to discard aborted upload parts", slog.String("upload_id", session.ID), slog.Any("error", err))
+	}
+	return nil
+}
+
+// GetUploadSession returns the state of a resumable upload so a client can tell which parts to send again.
+func (c *FileService) GetUploadSession(ctx context.Context, clientID, sessionID string) (*model.UploadSessionResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	session, err := c.getOwnedUploadSession(ctx, validatedAppID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := c.uploadSessionRepository.GetParts(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return uploadSessionResponse(session, parts), nil
+}
+
+// checkUploadParts reads the parts of a session and checks they form a complete file the app has room for
+func (c *FileService) checkUploadParts(ctx context.Context, appID string, session *entity.UploadSessions) ([]entity.UploadParts, error) {
+	parts, err := c.uploadSessionRepository.GetParts(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUploadParts(parts); err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, part := range parts {
+		total += part.Size
+	}
+	quota, err := c.checkQuota(ctx, appID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := quota.check(total); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// getOwnedUploadSession loads a session and hides sessions of other apps behind ErrUploadSessionNotFound.
+func (c *FileService) getOwnedUploadSession(ctx context.Context, appID, sessionID string) (*entity.UploadSessions, error) {
+	if sessionID == "" {
+		return nil, model.ErrInvalidInput
+	}
+	session, err := c.uploadSessionRepository.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.AppID != appID {
+		return nil, model.ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// getOpenUploadSession checks the calling app and returns its session if it still accepts parts.
+func (c *FileService) getOpenUploadSession(ctx context.Context, clientID, sessionID string) (*entity.UploadSessions, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	session, err := c.getOwnedUploadSession(ctx, validatedAppID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUploadSessionOpen(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// reopenUploadSession lets the client retry completion after a failure before anything was committed.
+func (c *FileService) reopenUploadSession(session *entity.UploadSessions) {
+	if err := c.uploadSessionRepository.UpdateStatus(context.Background(), session, []string{constant.SessionStatusCompleting}, constant.SessionStatusOpen); err != nil {
+		slog.Error("Failed to reopen upload session", slog.String("upload_id", session.ID), slog.Any("error", err))
+	}
+}
+
+// compositeHash hashes the concatenated part hashes, giving a single value for a file assembled from parts
+func (c *FileService) compositeHash(hashMethod string, partHashes []string) string {
+	hasher, err := c.cryptoService.NewHash(hashMethod)
+	if err != nil {
+		slog.Warn("Failed to calculate composite hash", slog.Any("error", err))
+		return ""
+	}
+	for _, partHash := range partHashes {
+		hasher.Write([]byte(partHash))
+	}
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+}
+
+// decryptMultipartStream returns a reader over the plaintext of an object assembled from independently sealed parts.
+// Each part is authenticated against its own part number and checked against its own hash as it is read.
+func (c *FileService) decryptMultipartStream(key string, fileMetaData *entity.Metadata, parts []entity.UploadParts, encrypted io.ReadCloser) (io.ReadCloser, error) {
+	if len(parts) == 0 {
+		return nil, model.ErrUploadPartsMissing
+	}
+	return &multipartDecryptingReader{
+		service:       c,
+		key:           key,
+		hashMethod:    c.fileHashMethod(fileMetaData.HashAlgo),
+		fileID:        fileMetaData.FileID,
+		appID:         fileMetaData.File.AppID,
+		headerVersion: fileMetaData.HeaderVersion,
+		parts:         parts,
+		source:        encrypted,
+	}, nil
+}
+
+// multipartDecryptingReader decrypts the parts of a multipart object one after another
+type multipartDecryptingReader struct {
+	service       *FileService
+	key           string
+	hashMethod    string
+	fileID        string
+	appID         string
+	headerVersion int
+	parts         []entity.UploadParts
+	source        io.ReadCloser
+	current       io.Reader
+}
+
+func (r *multipartDecryptingReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			part := r.parts[0]
+			r.parts = r.parts[1:]
+
+			decrypted, err := r.service.cryptoService.NewDecryptingReader(r.key, io.LimitReader(r.source, part.EncSize), uploadPartAssociatedData(r.headerVersion, r.fileID, r.appID, part.PartNumber))
+			if err != nil {
+				return 0, err
+			}
+			hasher, err := r.service.cryptoService.NewHash(r.hashMethod)
+			if err != nil {
+				return 0, model.ErrHashCalculationFailed
+			}
+			r.current = &hashVerifyingReader{reader: decrypted, closer: r.source, hasher: hasher, expected: part.Hash}
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *multipartDecryptingReader) Close() error {
+	return r.source.Close()
+}
+
+// uploadPartAssociatedData binds a part ciphertext to its file and position so parts cannot be swapped or reordered.
+// Since header version 1 the owning app is bound as well.
+func uploadPartAssociatedData(headerVersion int, fileID, appID string, partNumber int) []byte {
+	if headerVersion < CipherHeaderVersion {
+		return []byte(fmt.Sprintf("%s/part/%d", fileID, partNumber))
+	}
+	return []byte(fmt.Sprintf("%s/part/%d", fileAssociatedData(headerVersion, fileID, appID), partNumber))
+}
+
+// checkUploadSessionOpen reports why a session no longer accepts parts or completion
+func checkUploadSessionOpen(session *entity.UploadSessions) error {
+	if session.Status != constant.SessionStatusOpen {
+		return model.ErrUploadSessionClosed
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return model.ErrUploadSessionExpired
+	}
+	return nil
+}
+
+// validateUploadParts checks that parts are numbered 1..n without gaps and that only the last part is small
+func validateUploadParts(parts []entity.UploadParts) error {
+	if len(parts) == 0 {
+		return model.ErrUploadPartsMissing
+	}
+	for i, part := range parts {
+		if part.PartNumber != i+1 {
+			return fmt.Errorf("%w: part %d", model.ErrUploadPartsMissing, i+1)
+		}
+		if i < len(parts)-1 && part.Size < minUploadPartSize {
+			return fmt.Errorf("%w: part %d", model.ErrUploadPartTooSmall, part.PartNumber)
+		}
+	}
+	return nil
+}
+
+func uploadSessionResponse(session *entity.UploadSessions, parts []entity.UploadParts) *model.UploadSessionResponse {
+	response := &model.UploadSessionResponse{
+		ID:          session.ID,
+		FileID:      session.FileID,
+		FileName:    session.FileName,
+		Status:      session.Status,
+		ExpiresAt:   session.ExpiresAt.Format(time.RFC3339),
+		MinPartSize: minUploadPartSize,
+		MaxPartSize: maxUploadPartSize,
+		Parts:       []model.UploadPartResponse{},
+	}
+	for _, part := range parts {
+		response.Parts = append(response.Parts, model.UploadPartResponse{
+			PartNumber: part.PartNumber,
+			Size:       part.Size,
+			Hash:       part.Hash,
+		})
+	}
+	return response
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUploadTokenTTL    = time.Hour
+	defaultUploadTokenMaxTTL = 7 * 24 * time.Hour
+
+	uploadTokenPurpose  = "upload"
+	maxFolderPathLength = 1024
+	maxPathNameLength   = 255
+)
+
+// CreateUploadToken mints a pre-signed token that lets a browser or external party upload files for the calling app
+// without OAuth2 credentials, within the given size, type and expiry limits.
+func (c *FileService) CreateUploadToken(ctx context.Context, clientID string, request model.CreateUploadTokenRequest) (*model.UploadTokenResponse, error) {
+	if clientID == "" || request.MaxSize <= 0 {
+		return nil, model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	ttl := c.uploadTokenTTL
+	if request.ExpiresIn != "" {
+		if ttl, err = time.ParseDuration(request.ExpiresIn); err != nil {
+			return nil, model.ErrInvalidUploadTokenDuration
+		}
+	}
+	if ttl <= 0 || ttl > c.uploadTokenMaxTTL {
+		return nil, model.ErrInvalidUploadTokenDuration
+	}
+
+	mimeTypes, err := normalizeMimeTypes(request.AllowedMimeTypes)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := normalizeFolderPath(request.Folder)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &entity.UploadTokens{
+		ID:               helper.GenerateCustomUUID().String(),
+		AppID:            validatedAppID,
+		MaxSize:          request.MaxSize,
+		AllowedMimeTypes: strings.Join(mimeTypes, ","),
+		Folder:           folder,
+		ExpiresAt:        time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if err := c.uploadTokenRepository.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Upload token created", slog.String("token_id", token.ID), slog.String("app_id", validatedAppID))
+	response := uploadTokenResponse(token)
+	response.URL = c.publicBaseURL + "/u/" + c.signLinkToken(uploadTokenPurpose, token.ID, token.ExpiresAt)
+	return &response, nil
+}
+
+// ListUploadTokens returns the upload tokens issued by the calling app, newest first.
+func (c *FileService) ListUploadTokens(ctx context.Context, clientID string) ([]model.UploadTokenResponse, error) {
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return nil, err
+	}
+
+	tokens, err := c.uploadTokenRepository.GetByAppID(ctx, validatedAppID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.UploadTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, uploadTokenResponse(&tokens[i]))
+	}
+	return result, nil
+}
+
+// RevokeUploadToken stops an upload token from being used again.
+func (c *FileService) RevokeUploadToken(ctx context.Context, clientID, tokenID string) error {
+	if clientID == "" || tokenID == "" {
+		return model.ErrInvalidInput
+	}
+
+	validatedAppID, err := c.checkClientID(ctx, clientID)
+	if validatedAppID == "" {
+		return err
+	}
+
+	token, err := c.uploadTokenRepository.GetByID(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if token.AppID != validatedAppID {
+		return model.ErrUploadTokenNotFound
+	}
+
+	if err := c.uploadTokenRepository.Revoke(ctx, token.ID); err != nil {
+		return err
+	}
+	slog.Info("Upload token revoked", slog.String("token_id", token.ID), slog.String("app_id", token.AppID))
+	return nil
+}
+
+// UploadWithToken validates a pre-signed upload token and stores the file for the app that issued it.
+// The file is encrypted like any other upload; its type is sniffed before anything is stored and its size is
+// enforced while it streams, and the upload is logged with the token as the actor.
+func (c *FileService) UploadWithToken(ctx context.Context, token, fileName, clientIP string, input io.Reader) (*model.UploadFileResponse, error) {
+	if fileName == "" || input == nil {
+		return nil, model.ErrInvalidInput
+	}
+
+	tokenID, err := c.verifyLinkToken(uploadTokenPurpose, token, model.ErrUploadTokenNotFound, model.ErrUploadTokenExpired)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadToken, err := c.uploadTokenRepository.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if uploadToken.RevokedAt != nil {
+		return nil, model.ErrUploadTokenRevoked
+	}
+	if !time.Now().Before(uploadToken.ExpiresAt) {
+		return nil, model.ErrUploadTokenExpired
+	}
+
+	app, err := c.applicationRepository.GetByID(ctx, uploadToken.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if !app.IsActive {
+		return nil, model.ErrAppNotActive
+	}
+
+	// Check the type from the content itself before anything reaches storage
+	reader := bufio.NewReader(input)
+	head, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, model.ErrFailedToReadFile
+	}
+	mimeType := http.DetectContentType(head)
+	if !mimeTypeAllowed(uploadToken.AllowedMimeTypes, mimeType) {
+		slog.Warn("Upload token used with a disallowed file type", slog.String("token_id", uploadToken.ID), slog.String("mime_type", mimeType))
+		return nil, model.ErrMimeTypeNotAllowed
+	}
+
+	limited := &sizeLimitedReader{reader: reader, remaining: uploadToken.MaxSize}
+	file, err := c.uploadFile(ctx, uploadToken.AppID, path.Base(fileName), limited, model.UploadOptions{Folder: uploadToken.Folder})
+	if limited.exceeded {
+		return nil, model.ErrUploadTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.saveFileLogWithMetadata(ctx, uploadToken.ID, file.ID, constant.ActorTypeUser, string(constant.ActionTypeUpload), map[string]interface{}{
+		"file_name":    file.Name,
+		"upload_token": uploadToken.ID,
+		"app_id":       uploadToken.AppID,
+		"folder":       uploadToken.Folder,
+		"client_ip":    clientIP,
+	})
+	return &model.UploadFileResponse{FileName: file.Name, FileID: file.ID}, nil
+}
+
+// sizeLimitedReader fails with err, or ErrUploadTooLarge when it is nil, once more than remaining bytes have been read
+type sizeLimitedReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+	err       error
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit so a file of exactly the limit is accepted
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		r.exceeded = true
+		return n, r.limitErr()
+	}
+	return n, err
+}
+
+// limitErr is the error reading past the limit fails with
+func (r *sizeLimitedReader) limitErr() error {
+	if r.err != nil {
+		return r.err
+	}
+	return model.ErrUploadTooLarge
+}
+
+// normalizeMimeTypes lowercases media types such as "application/pdf" or "image/*" and drops parameters
+func normalizeMimeTypes(mimeTypes []string) ([]string, error) {
+	normalized := make([]string, 0, len(mimeTypes))
+	for _, value := range mimeTypes {
+		mediaType, _, err := mime.ParseMediaType(value)
+		if err != nil || !strings.Contains(mediaType, "/") || strings.Contains(mediaType, ",") {
+			return nil, model.ErrInvalidInput
+		}
+		normalized = append(normalized, mediaType)
+	}
+	return normalized, nil
+}
+
+// mimeTypeAllowed matches a sniffed content type against a comma separated list; an empty list allows any type
+func mimeTypeAllowed(allowed, contentType string) bool {
+	if allowed == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range strings.Split(allowed, ",") {
+		if candidate == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(candidate, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFolderPath cleans a folder path into its absolute form, e.g. "reports//2024/" becomes "/reports/2024".
+// The root folder is represented by an empty path.
+func normalizeFolderPath(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return "", nil
+	}
+	if len(folder) > maxFolderPathLength || strings.ContainsAny(folder, "\x00\\") {
+		return "", model.ErrInvalidFolderPath
+	}
+	cleaned := path.Clean("/" + folder)
+	if cleaned == "/" {
+		return "", nil
+	}
+	for _, name := range strings.Split(cleaned[1:], "/") {
+		if len(name) > maxPathNameLength {
+			return "", model.ErrInvalidFolderPath
+		}
+	}
+	return cleaned, nil
+}
+
+func uploadTokenResponse(token *entity.UploadTokens) model.UploadTokenResponse {
+	mimeTypes := []string{}
+	if token.AllowedMimeTypes != "" {
+		mimeTypes = strings.Split(token.AllowedMimeTypes, ",")
+	}
+	return model.UploadTokenResponse{
+		ID:               token.ID,
+		ExpiresAt:        token.ExpiresAt.Format("2006-01-02 15:04:05"),
+		MaxSize:          token.MaxSize,
+		AllowedMimeTypes: mimeTypes,
+		Folder:           token.Folder,
+		Revoked:          token.RevokedAt != nil,
+		CreatedAt:        token.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
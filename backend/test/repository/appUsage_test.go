@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func requireUsage(t *testing.T, repo repository.ApplicationRepository, appID string, bytes, files int64) {
+	t.Helper()
+	usage, err := repo.GetUsage(context.Background(), appID)
+	require.NoError(t, err)
+	assert.Equal(t, bytes, usage.StoredBytes, "stored bytes")
+	assert.Equal(t, files, usage.FileCount, "file count")
+}
+
+func TestApplicationRepository_Usage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("usage follows files through create, delete, restore and purge", func(t *testing.T) {
+		db := setupTestDB(t)
+		apps := repository.NewAppsRepository(db)
+		files := repository.NewFileRepository(db)
+		app := createTestApp(t, db)
+		requireUsage(t, apps, app.ID, 0, 0)
+
+		file := &entity.Files{ID: "usage-file", AppID: app.ID, Name: "a.txt", Size: 100}
+		require.NoError(t, files.CreateFileWithMetadata(ctx, file, &entity.Metadata{FileID: file.ID, KeyUID: "k", EncKey: "e"}))
+		requireUsage(t, apps, app.ID, 100, 1)
+
+		require.NoError(t, files.Delete(ctx, file.ID))
+		requireUsage(t, apps, app.ID, 0, 0)
+
+		require.NoError(t, files.RestoreFile(ctx, file.ID))
+		require.NoError(t, files.RestoreFile(ctx, file.ID))
+		requireUsage(t, apps, app.ID, 100, 1)
+
+		require.NoError(t, files.Purge(ctx, file.ID))
+		requireUsage(t, apps, app.ID, 0, 0)
+	})
+
+	t.Run("completed uploads count once and updates apply the change in size", func(t *testing.T) {
+		db := setupTestDB(t)
+		apps := repository.NewAppsRepository(db)
+		jobs := repository.NewUploadJobRepository(db)
+
+		job := createPendingUpload(t, db, jobs, "usage-upload", time.Now())
+		requireUsage(t, apps, job.AppID, 0, 0)
+
+		file := &entity.Files{ID: job.FileID, Name: "done.txt", Size: 40, Location: job.FileID}
+		require.NoError(t, jobs.Complete(ctx, job, file, &entity.Metadata{ID: "usage-meta-1", FileID: job.FileID, KeyUID: "k", EncKey: "e"}))
+		requireUsage(t, apps, job.AppID, 40, 1)
+
+		update := &entity.UploadJobs{
+			ID: "job-usage-update", FileID: job.FileID, AppID: job.AppID, Operation: constant.JobOperationUpdate,
+			Status: constant.JobStatusPending, BucketName: "test-bucket", ObjectName: job.FileID, NextAttemptAt: time.Now(),
+		}
+		require.NoError(t, jobs.Create(ctx, update, nil))
+		file = &entity.Files{ID: job.FileID, Name: "done.txt", Size: 25, Location: job.FileID}
+		require.NoError(t, jobs.Complete(ctx, update, file, &entity.Metadata{ID: "usage-meta-2", FileID: job.FileID, KeyUID: "k", EncKey: "e"}))
+		requireUsage(t, apps, job.AppID, 25, 1)
+	})
+
+	t.Run("backfill counts stored files of apps without totals", func(t *testing.T) {
+		db := setupTestDB(t)
+		apps := repository.NewAppsRepository(db)
+		app := createTestApp(t, db)
+		require.NoError(t, db.Create(&[]entity.Files{
+			{ID: "old-1", AppID: app.ID, Name: "1.txt", Size: 10, Status: constant.FileStatusStored},
+			{ID: "old-2", AppID: app.ID, Name: "2.txt", Size: 20, Status: constant.FileStatusStored},
+			{ID: "old-3", AppID: app.ID, Name: "3.txt", Size: 30, Status: constant.FileStatusPending},
+			{ID: "old-4", AppID: app.ID, Name: "4.txt", Size: 40, Status: constant.FileStatusStored, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+		}).Error)
+
+		backfilled, err := apps.BackfillUsage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), backfilled)
+		requireUsage(t, apps, app.ID, 30, 2)
+
+		backfilled, err = apps.BackfillUsage(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, backfilled, "apps with totals are left alone")
+	})
+}
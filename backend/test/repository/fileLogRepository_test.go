@@ -3,6 +3,8 @@ package repository_test
 import (
 	"context"
 	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
 	"fmt"
 	"os"
@@ -22,7 +24,7 @@ func setupFileLogTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// Auto migrate the schema
-	err = db.AutoMigrate(&entity.FileLogs{})
+	err = db.AutoMigrate(&entity.FileLogs{}, &entity.AuditCheckpoints{})
 	require.NoError(t, err)
 
 	return db
@@ -44,6 +46,13 @@ func createTestFileLog(t *testing.T, db *gorm.DB, fileID, action string) *entity
 	return log
 }
 
+// signTestCheckpoint stands in for the audit service signing checkpoints with a KEK derived key
+func signTestCheckpoint(checkpoint *entity.AuditCheckpoints) error {
+	checkpoint.KeyID = "test-key"
+	checkpoint.Signature = "test-signature"
+	return nil
+}
+
 func TestFileLogRepository_Create(t *testing.T) {
 	db := setupFileLogTestDB(t)
 	repo := repository.NewFileLogRepository(db)
@@ -117,46 +126,65 @@ func TestFileLogRepository_List(t *testing.T) {
 	}
 
 	t.Run("successfully get paginated list", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 5, "timestamp", "desc")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 5, "timestamp", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 5)
 	})
 
 	t.Run("successfully get with offset", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 5, 5, "timestamp", "desc")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 5, 5, "timestamp", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 5)
 	})
 
 	t.Run("successfully sort by different fields", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 10, "file_id", "asc")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 10, "file_id", "asc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 10)
 	})
 
 	t.Run("handle default ordering", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 5, "", "")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 5, "", "")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 5)
 	})
 
 	t.Run("handle invalid order field", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 5, "invalid_field", "desc")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 5, "invalid_field", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 5)
 	})
 
 	t.Run("handle invalid sort direction", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 5, "timestamp", "invalid")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 5, "timestamp", "invalid")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(10), total)
 		assert.Len(t, *logs, 5)
 	})
+
+	t.Run("successfully page with cursor", func(t *testing.T) {
+		seen := map[uint]bool{}
+		filter := model.LogFilter{}
+		for {
+			total, logs, next, err := repo.List(ctx, filter, 0, 3, "timestamp", "desc")
+			require.NoError(t, err)
+			assert.Equal(t, int64(10), total)
+			for _, log := range *logs {
+				assert.False(t, seen[log.ID], "log %d listed twice", log.ID)
+				seen[log.ID] = true
+			}
+			if next == "" {
+				break
+			}
+			filter.Cursor = next
+		}
+		assert.Len(t, seen, 10)
+	})
 }
 
 func TestFileLogRepository_GetByFileID(t *testing.T) {
@@ -285,7 +313,7 @@ func TestFileLogRepository_DeleteOldLogs(t *testing.T) {
 	}
 
 	t.Run("delete logs older than 30 days", func(t *testing.T) {
-		err := repo.DeleteOldLogs(ctx, 30)
+		err := repo.DeleteOldLogs(ctx, 30, signTestCheckpoint)
 		assert.NoError(t, err)
 
 		// Verify old logs were deleted
@@ -321,7 +349,7 @@ func TestFileLogRepository_DeleteOldLogs(t *testing.T) {
 	}
 
 	t.Run("delete logs older than 10 days", func(t *testing.T) {
-		err := repo.DeleteOldLogs(ctx, 10)
+		err := repo.DeleteOldLogs(ctx, 10, signTestCheckpoint)
 		assert.NoError(t, err)
 
 		// Verify old logs were deleted
@@ -397,13 +425,66 @@ func TestFileLogRepository_ConcurrentWrites(t *testing.T) {
 	})
 }
 
+func TestFileLogRepository_ListFilters(t *testing.T) {
+	db := setupFileLogTestDB(t)
+	require.NoError(t, db.AutoMigrate(&entity.Files{}))
+	repo := repository.NewFileLogRepository(db)
+	ctx := context.Background()
+
+	// file-1 belongs to app-1 and was later deleted; file-2 belongs to app-2
+	require.NoError(t, db.Create(&entity.Files{ID: "file-1", AppID: "app-1", Name: "a.txt", MimeType: "text/plain"}).Error)
+	require.NoError(t, db.Create(&entity.Files{ID: "file-2", AppID: "app-2", Name: "b.txt", MimeType: "text/plain"}).Error)
+	require.NoError(t, db.Delete(&entity.Files{}, "id = ?", "file-1").Error)
+
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []entity.FileLogs{
+		{ActorID: "app-1", ActorType: "client", FileID: "file-1", Action: "upload", IP: "10.0.0.1", Timestamp: base},
+		{ActorID: "link-1", ActorType: "user", FileID: "file-1", Action: "download", IP: "10.0.0.2", Timestamp: base.Add(time.Hour)},
+		{ActorID: "app-1", ActorType: "client", FileID: "file-1", Action: "delete", IP: "10.0.0.1", Timestamp: base.Add(2 * time.Hour)},
+		{ActorID: "app-2", ActorType: "client", FileID: "file-2", Action: "upload", IP: "10.0.0.3", Timestamp: base.Add(3 * time.Hour)},
+	}
+	for i := range entries {
+		require.NoError(t, db.Create(&entries[i]).Error)
+	}
+
+	list := func(filter model.LogFilter) []string {
+		total, logs, _, err := repo.List(ctx, filter, 0, 10, "timestamp", "asc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(*logs)), total)
+		actions := make([]string, 0, len(*logs))
+		for _, log := range *logs {
+			actions = append(actions, log.ActorID+":"+log.Action)
+		}
+		return actions
+	}
+	at := func(d time.Duration) *time.Time { v := base.Add(d); return &v }
+
+	t.Run("filters by app including other actors on its files", func(t *testing.T) {
+		assert.Equal(t, []string{"app-1:upload", "link-1:download", "app-1:delete"}, list(model.LogFilter{AppID: "app-1"}))
+		assert.Equal(t, []string{"app-2:upload"}, list(model.LogFilter{AppID: "app-2"}))
+	})
+
+	t.Run("filters by actor, file, action and IP", func(t *testing.T) {
+		assert.Equal(t, []string{"link-1:download"}, list(model.LogFilter{ActorType: "user"}))
+		assert.Equal(t, []string{"app-1:upload", "app-1:delete"}, list(model.LogFilter{ActorID: "app-1"}))
+		assert.Equal(t, []string{"app-2:upload"}, list(model.LogFilter{FileID: "file-2"}))
+		assert.Equal(t, []string{"app-1:upload", "app-2:upload"}, list(model.LogFilter{Action: "upload"}))
+		assert.Equal(t, []string{"app-1:upload", "app-1:delete"}, list(model.LogFilter{IP: "10.0.0.1"}))
+	})
+
+	t.Run("filters by time window", func(t *testing.T) {
+		assert.Equal(t, []string{"link-1:download", "app-1:delete"}, list(model.LogFilter{From: at(time.Hour), To: at(3 * time.Hour)}))
+		assert.Equal(t, []string{"app-1:delete"}, list(model.LogFilter{FileID: "file-1", From: at(90 * time.Minute)}))
+	})
+}
+
 func TestFileLogRepository_EmptyDatabase(t *testing.T) {
 	db := setupFileLogTestDB(t)
 	repo := repository.NewFileLogRepository(db)
 	ctx := context.Background()
 
 	t.Run("list on empty database", func(t *testing.T) {
-		total, logs, err := repo.List(ctx, 0, 10, "timestamp", "desc")
+		total, logs, _, err := repo.List(ctx, model.LogFilter{}, 0, 10, "timestamp", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(0), total)
 		assert.Empty(t, *logs)
@@ -422,7 +503,136 @@ func TestFileLogRepository_EmptyDatabase(t *testing.T) {
 	})
 
 	t.Run("delete old logs on empty database", func(t *testing.T) {
-		err := repo.DeleteOldLogs(ctx, 30)
+		err := repo.DeleteOldLogs(ctx, 30, signTestCheckpoint)
 		assert.NoError(t, err)
 	})
 }
+
+func TestFileLogRepository_AuditChain(t *testing.T) {
+	db := setupFileLogTestDB(t)
+	repo := repository.NewFileLogRepository(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, age := range []int{40, 35, 5, 1} {
+		require.NoError(t, repo.Create(ctx, &entity.FileLogs{
+			ActorID:   "app-1",
+			ActorType: "client",
+			FileID:    fmt.Sprintf("file-%d", i),
+			Action:    "upload",
+			IP:        "10.0.0.1",
+			Timestamp: now.AddDate(0, 0, -age),
+			Metadata:  entity.JSONB{"size": 42, "tags": map[string]string{"team": "a"}},
+		}))
+	}
+
+	t.Run("entries link to the hash of the entry before them", func(t *testing.T) {
+		chain, err := repo.ListChain(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, chain, 4)
+
+		assert.Empty(t, chain[0].PrevHash)
+		for i, entry := range chain {
+			assert.Equal(t, entry.ComputeHash(), entry.Hash, "entry read back hashes the same as when written")
+			if i > 0 {
+				assert.Equal(t, chain[i-1].Hash, entry.PrevHash)
+			}
+		}
+
+		rest, err := repo.ListChain(ctx, chain[1].ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, rest, 2)
+	})
+
+	t.Run("checkpoints cover the head once", func(t *testing.T) {
+		checkpoint, err := repo.CreateCheckpoint(ctx, time.Hour, signTestCheckpoint)
+		require.NoError(t, err)
+		require.NotNil(t, checkpoint)
+		assert.Equal(t, constant.CheckpointKindPeriodic, checkpoint.Kind)
+		assert.Equal(t, "test-signature", checkpoint.Signature)
+		assert.Equal(t, uint(1), checkpoint.Sequence)
+
+		again, err := repo.CreateCheckpoint(ctx, time.Hour, signTestCheckpoint)
+		require.NoError(t, err)
+		assert.Nil(t, again, "nothing new to cover")
+	})
+
+	t.Run("an unchanged head is signed again once its checkpoint is old", func(t *testing.T) {
+		previous, err := repo.ListCheckpoints(ctx)
+		require.NoError(t, err)
+		require.Len(t, previous, 1)
+
+		heartbeat, err := repo.CreateCheckpoint(ctx, 0, signTestCheckpoint)
+		require.NoError(t, err)
+		require.NotNil(t, heartbeat)
+		assert.Equal(t, previous[0].LogID, heartbeat.LogID)
+		assert.Equal(t, uint(2), heartbeat.Sequence)
+		assert.Equal(t, previous[0].Signature, heartbeat.PrevSignature, "each checkpoint signs the one before it")
+	})
+
+	t.Run("retention cuts the chain behind a signed anchor", func(t *testing.T) {
+		chain, err := repo.ListChain(ctx, 0, 10)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.DeleteOldLogs(ctx, 30, signTestCheckpoint))
+
+		remaining, err := repo.ListChain(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, remaining, 2)
+		assert.Equal(t, chain[2].ID, remaining[0].ID)
+
+		checkpoints, err := repo.ListCheckpoints(ctx)
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 3)
+		anchor := checkpoints[0]
+		assert.Equal(t, constant.CheckpointKindRetention, anchor.Kind)
+		assert.Equal(t, chain[1].ID, anchor.LogID)
+		assert.Equal(t, remaining[0].PrevHash, anchor.LogHash)
+		assert.Equal(t, chain[3].ID, checkpoints[1].LogID, "checkpoints over kept entries survive")
+		assert.Equal(t, uint(3), anchor.Sequence)
+		assert.Equal(t, uint(1), anchor.FirstSequence, "the anchor records the oldest checkpoint it kept")
+	})
+
+	t.Run("a failing signer leaves the chain untouched", func(t *testing.T) {
+		failing := func(*entity.AuditCheckpoints) error { return fmt.Errorf("kms unavailable") }
+		assert.Error(t, repo.DeleteOldLogs(ctx, 3, failing))
+
+		remaining, err := repo.ListChain(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, remaining, 2)
+	})
+}
+
+func TestFileLogRepository_StreamAndObserve(t *testing.T) {
+	db := setupFileLogTestDB(t)
+	var observed []uint
+	repo := repository.NewObservedFileLogRepository(repository.NewFileLogRepository(db), func(log *entity.FileLogs) {
+		observed = append(observed, log.ID)
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		action := "upload"
+		if i%2 == 1 {
+			action = "download"
+		}
+		require.NoError(t, repo.Create(ctx, &entity.FileLogs{ActorID: "app-1", ActorType: "client", FileID: fmt.Sprintf("file-%d", i), Action: action}))
+	}
+	assert.Equal(t, []uint{1, 2, 3, 4, 5}, observed, "appended entries reach the observer once stored")
+
+	var batches [][]uint
+	err := repo.Stream(ctx, model.LogFilter{Action: "upload"}, 2, func(batch []entity.FileLogs) error {
+		ids := make([]uint, 0, len(batch))
+		for _, log := range batch {
+			ids = append(ids, log.ID)
+		}
+		batches = append(batches, ids)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]uint{{1, 3}, {5}}, batches)
+
+	stop := fmt.Errorf("client went away")
+	err = repo.Stream(ctx, model.LogFilter{}, 2, func([]entity.FileLogs) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
 	"crypsis-backend/internal/repository"
 	"testing"
 	"time"
@@ -20,7 +21,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// Auto migrate the schema
-	err = db.AutoMigrate(&entity.Files{}, &entity.Metadata{}, &entity.Apps{})
+	err = db.AutoMigrate(&entity.Files{}, &entity.Metadata{}, &entity.Apps{}, &entity.UploadJobs{}, &entity.UploadSessions{}, &entity.UploadParts{}, &entity.FileVersions{}, &entity.ShareLinks{}, &entity.UploadTokens{}, &entity.Folders{}, &entity.FileTags{}, &entity.AppUsage{})
 	require.NoError(t, err)
 
 	return db
@@ -194,20 +195,65 @@ func TestFileRepository_GetListFiles(t *testing.T) {
 	}
 
 	t.Run("successfully get paginated list", func(t *testing.T) {
-		total, files, err := repo.GetListFiles(ctx, app.ID, 0, 3, "created_at", "desc")
+		total, files, _, err := repo.GetListFiles(ctx, app.ID, model.FileFilter{}, 0, 3, "created_at", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(5), total)
 		assert.Len(t, files, 3)
 	})
 
 	t.Run("successfully get with offset", func(t *testing.T) {
-		total, files, err := repo.GetListFiles(ctx, app.ID, 3, 3, "created_at", "desc")
+		total, files, _, err := repo.GetListFiles(ctx, app.ID, model.FileFilter{}, 3, 3, "created_at", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(5), total)
 		assert.Len(t, files, 2)
 	})
 }
 
+func TestFileRepository_Tags(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewFileRepository(db)
+	ctx := context.Background()
+
+	app := createTestApp(t, db)
+	for _, id := range []string{"file-a", "file-b", "file-c"} {
+		require.NoError(t, db.Create(&entity.Files{ID: id, AppID: app.ID, Name: id + ".txt"}).Error)
+	}
+	require.NoError(t, repo.SetTags(ctx, "file-a", map[string]string{"project": "apollo", "stage": "draft"}))
+	require.NoError(t, repo.SetTags(ctx, "file-b", map[string]string{"project": "gemini"}))
+
+	listIDs := func(filter model.FileFilter) []string {
+		total, files, _, err := repo.GetListFiles(ctx, app.ID, filter, 0, 10, "name", "asc")
+		require.NoError(t, err)
+		ids := make([]string, 0, len(files))
+		for _, file := range files {
+			ids = append(ids, file.ID)
+		}
+		assert.Equal(t, int64(len(ids)), total)
+		return ids
+	}
+	value := func(v string) *string { return &v }
+
+	t.Run("filters by tag existence and equality", func(t *testing.T) {
+		assert.Equal(t, []string{"file-a", "file-b"}, listIDs(model.FileFilter{Tags: []model.TagFilter{{Key: "project"}}}))
+		assert.Equal(t, []string{"file-b"}, listIDs(model.FileFilter{Tags: []model.TagFilter{{Key: "project", Value: value("gemini")}}}))
+		assert.Equal(t, []string{"file-a"}, listIDs(model.FileFilter{Tags: []model.TagFilter{{Key: "project"}, {Key: "stage", Value: value("draft")}}}))
+		assert.Empty(t, listIDs(model.FileFilter{Tags: []model.TagFilter{{Key: "stage", Value: value("final")}}}))
+		assert.Len(t, listIDs(model.FileFilter{}), 3)
+	})
+
+	t.Run("setting tags replaces the previous ones", func(t *testing.T) {
+		require.NoError(t, repo.SetTags(ctx, "file-a", map[string]string{"stage": "final"}))
+		tags, err := repo.GetTags(ctx, "file-a")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"stage": "final"}, tags)
+
+		require.NoError(t, repo.SetTags(ctx, "file-a", map[string]string{}))
+		tags, err = repo.GetTags(ctx, "file-a")
+		require.NoError(t, err)
+		assert.Empty(t, tags)
+	})
+}
+
 func TestFileRepository_GetListFilesForAdmin(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repository.NewFileRepository(db)
@@ -239,13 +285,103 @@ func TestFileRepository_GetListFilesForAdmin(t *testing.T) {
 	}
 
 	t.Run("successfully get all files for admin", func(t *testing.T) {
-		total, files, err := repo.GetListFilesForAdmin(ctx, 0, 10, "created_at", "desc")
+		total, files, _, err := repo.GetListFilesForAdmin(ctx, model.FileFilter{}, 0, 10, "created_at", "desc")
 		assert.NoError(t, err)
 		assert.Equal(t, int64(6), total)
 		assert.Len(t, files, 6)
 	})
 }
 
+func TestFileRepository_ListFilters(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewFileRepository(db)
+	ctx := context.Background()
+
+	app := createTestApp(t, db)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []entity.Files{
+		{ID: "file-a", Name: "report_1.pdf", MimeType: "application/pdf", Size: 100},
+		{ID: "file-b", Name: "report-2.pdf", MimeType: "application/pdf", Size: 2000},
+		{ID: "file-c", Name: "photo.png", MimeType: "image/png", Size: 2000},
+		{ID: "file-d", Name: "photo.jpg", MimeType: "image/jpeg", Size: 2000},
+		{ID: "file-e", Name: "notes.txt", MimeType: "text/plain", Size: 50},
+	}
+	for i := range files {
+		files[i].AppID = app.ID
+		files[i].CreatedAt = base.AddDate(0, 0, i)
+		files[i].UpdatedAt = base.AddDate(0, 0, i)
+		require.NoError(t, db.Create(&files[i]).Error)
+	}
+	require.NoError(t, db.Delete(&entity.Files{}, "id = ?", "file-e").Error)
+
+	listIDs := func(filter model.FileFilter) []string {
+		total, files, _, err := repo.GetListFiles(ctx, app.ID, filter, 0, 10, "name", "asc")
+		require.NoError(t, err)
+		ids := make([]string, 0, len(files))
+		for _, file := range files {
+			ids = append(ids, file.ID)
+		}
+		assert.Equal(t, int64(len(ids)), total)
+		return ids
+	}
+	size := func(v int64) *int64 { return &v }
+	at := func(days int) *time.Time { v := base.AddDate(0, 0, days); return &v }
+
+	t.Run("filters by name prefix and MIME type", func(t *testing.T) {
+		assert.Equal(t, []string{"file-a"}, listIDs(model.FileFilter{NamePrefix: "report_"}), "wildcards in the prefix only match themselves")
+		assert.Equal(t, []string{"file-d", "file-c"}, listIDs(model.FileFilter{NamePrefix: "photo"}))
+		assert.Equal(t, []string{"file-c"}, listIDs(model.FileFilter{MimeType: "image/png"}))
+		assert.Equal(t, []string{"file-d", "file-c"}, listIDs(model.FileFilter{MimeType: "image/*"}))
+	})
+
+	t.Run("filters by size and date ranges", func(t *testing.T) {
+		assert.Equal(t, []string{"file-a"}, listIDs(model.FileFilter{MaxSize: size(1000)}))
+		assert.Equal(t, []string{"file-d", "file-c", "file-b"}, listIDs(model.FileFilter{MinSize: size(1000), MaxSize: size(2000)}))
+		assert.Equal(t, []string{"file-c", "file-b"}, listIDs(model.FileFilter{CreatedAfter: at(1), CreatedBefore: at(3)}))
+		assert.Equal(t, []string{"file-d"}, listIDs(model.FileFilter{UpdatedAfter: at(3)}))
+	})
+
+	t.Run("filters by deleted state", func(t *testing.T) {
+		assert.Len(t, listIDs(model.FileFilter{}), 4)
+		assert.Len(t, listIDs(model.FileFilter{Deleted: constant.DeletedStateInclude}), 5)
+		assert.Equal(t, []string{"file-e"}, listIDs(model.FileFilter{Deleted: constant.DeletedStateOnly}))
+	})
+
+	t.Run("cursor pages through ties without gaps or repeats", func(t *testing.T) {
+		for _, sortBy := range []string{"size", "created_at", "name"} {
+			for _, order := range []string{"asc", "desc"} {
+				var seen []string
+				filter := model.FileFilter{}
+				for page := 0; page < 5; page++ {
+					total, files, next, err := repo.GetListFiles(ctx, app.ID, filter, 0, 2, sortBy, order)
+					require.NoError(t, err)
+					assert.Equal(t, int64(4), total)
+					for _, file := range files {
+						seen = append(seen, file.ID)
+					}
+					if next == "" {
+						break
+					}
+					filter.Cursor = next
+				}
+				assert.ElementsMatch(t, []string{"file-a", "file-b", "file-c", "file-d"}, seen, "%s %s", sortBy, order)
+				assert.Len(t, seen, 4, "%s %s", sortBy, order)
+			}
+		}
+	})
+
+	t.Run("rejects cursors from another sort", func(t *testing.T) {
+		_, _, next, err := repo.GetListFiles(ctx, app.ID, model.FileFilter{}, 0, 2, "size", "asc")
+		require.NoError(t, err)
+		require.NotEmpty(t, next)
+
+		_, _, _, err = repo.GetListFiles(ctx, app.ID, model.FileFilter{Cursor: next}, 0, 2, "name", "asc")
+		assert.ErrorIs(t, err, model.ErrInvalidCursor)
+		_, _, _, err = repo.GetListFiles(ctx, app.ID, model.FileFilter{Cursor: "not-a-cursor"}, 0, 2, "size", "asc")
+		assert.ErrorIs(t, err, model.ErrInvalidCursor)
+	})
+}
+
 func TestFileRepository_GetAll(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repository.NewFileRepository(db)
@@ -473,6 +609,21 @@ func TestFileRepository_RestoreFile(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("fail when another file took its path", func(t *testing.T) {
+		app := createTestApp(t, db)
+		deleted := &entity.Files{ID: "restore-deleted", AppID: app.ID, Name: "a.txt", Path: "/a.txt", Status: constant.FileStatusStored}
+		require.NoError(t, db.Create(deleted).Error)
+		require.NoError(t, repo.Delete(ctx, deleted.ID))
+		require.NoError(t, db.Create(&entity.Files{ID: "restore-taker", AppID: app.ID, Name: "a.txt", Path: "/a.txt"}).Error)
+
+		err := repo.RestoreFile(ctx, deleted.ID)
+		assert.ErrorIs(t, err, model.ErrPathConflict)
+
+		var count int64
+		require.NoError(t, db.Model(&entity.Files{}).Where("id = ?", deleted.ID).Count(&count).Error)
+		assert.Zero(t, count, "the file stays deleted")
+	})
+
 	t.Run("fail with empty file ID", func(t *testing.T) {
 		err := repo.RestoreFile(ctx, "")
 		assert.Error(t, err)
@@ -480,6 +631,49 @@ func TestFileRepository_RestoreFile(t *testing.T) {
 	})
 }
 
+func TestFileRepository_LifecycleListings(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewFileRepository(db)
+	ctx := context.Background()
+	app := createTestApp(t, db)
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	require.NoError(t, db.Create(&[]entity.Files{
+		{ID: "file-free", AppID: app.ID, Name: "free.txt", CreatedAt: past.Add(-time.Hour)},
+		{ID: "file-held", AppID: app.ID, Name: "held.txt", LegalHold: true, CreatedAt: past.Add(-time.Hour)},
+		{ID: "file-lapsed", AppID: app.ID, Name: "lapsed.txt", RetainUntil: &past, CreatedAt: past.Add(-time.Hour)},
+		{ID: "file-retained", AppID: app.ID, Name: "retained.txt", RetainUntil: &future, CreatedAt: past.Add(-time.Hour)},
+	}).Error)
+	ids := func(files []entity.Files) []string {
+		result := make([]string, 0, len(files))
+		for _, file := range files {
+			result = append(result, file.ID)
+		}
+		return result
+	}
+	expected := []string{"file-free", "file-lapsed"}
+
+	t.Run("held and retained files are not picked up by any rule", func(t *testing.T) {
+		files, err := repo.ListFilesCreatedBefore(ctx, app.ID, time.Now(), "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ids(files))
+
+		files, err = repo.ListFilesOutsideBucket(ctx, app.ID, "app-bucket", "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ids(files))
+
+		require.NoError(t, db.Model(&entity.Files{}).Where("app_id = ?", app.ID).Update("expires_at", past).Error)
+		files, err = repo.ListExpiredFiles(ctx, time.Now(), "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ids(files))
+
+		require.NoError(t, db.Where("app_id = ?", app.ID).Delete(&entity.Files{}).Error)
+		files, err = repo.ListDeletedFilesBefore(ctx, app.ID, future, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ids(files))
+	})
+}
+
 func TestFileRepository_UpdateFileAndMetadata(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repository.NewFileRepository(db)
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileVersionRepository_RecordedOnCommit(t *testing.T) {
+	db := setupTestDB(t)
+	jobRepo := repository.NewUploadJobRepository(db)
+	fileRepo := repository.NewFileRepository(db)
+	versionRepo := repository.NewFileVersionRepository(db)
+	ctx := context.Background()
+
+	job := createPendingUpload(t, db, jobRepo, "file-versions", time.Now())
+	file := &entity.Files{ID: job.FileID, Name: "v1.txt", Size: 10, MimeType: "text/plain"}
+	metadata := &entity.Metadata{ID: "metadata-versions", FileID: job.FileID, Hash: "hash-1", KeyUID: "key-uid", EncKey: "enc-1", KeyAlgo: "AES", Format: constant.CipherFormatStream, VersionID: "v1"}
+	require.NoError(t, jobRepo.Complete(ctx, job, file, metadata))
+
+	t.Run("upload records version 1", func(t *testing.T) {
+		versions, err := versionRepo.GetByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, "v1", versions[0].VersionID)
+		assert.Equal(t, "hash-1", versions[0].Hash)
+		assert.Equal(t, "enc-1", versions[0].EncKey)
+		assert.Equal(t, "v1.txt", versions[0].Name)
+	})
+
+	t.Run("update records the next version newest first", func(t *testing.T) {
+		update := &entity.UploadJobs{
+			ID:            "job-update-versions",
+			FileID:        job.FileID,
+			AppID:         job.AppID,
+			Operation:     constant.JobOperationUpdate,
+			Status:        constant.JobStatusPending,
+			BucketName:    "test-bucket",
+			ObjectName:    job.FileID,
+			BaseVersionID: "v1",
+			NextAttemptAt: time.Now(),
+		}
+		require.NoError(t, jobRepo.Create(ctx, update, nil))
+		require.NoError(t, jobRepo.Complete(ctx, update,
+			&entity.Files{ID: job.FileID, Name: "v2.txt", Size: 20},
+			&entity.Metadata{FileID: job.FileID, Hash: "hash-2", KeyUID: "key-uid", EncKey: "enc-1", KeyAlgo: "AES", Format: constant.CipherFormatStream, VersionID: "v2"}))
+
+		versions, err := versionRepo.GetByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, 2, versions[0].Version)
+		assert.Equal(t, "hash-2", versions[0].Hash)
+		assert.Equal(t, "enc-1", versions[0].EncKey, "the key the update was sealed with is recorded")
+
+		first, err := versionRepo.GetByVersion(ctx, job.FileID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "hash-1", first.Hash)
+		assert.Equal(t, "v1.txt", first.Name)
+	})
+
+	t.Run("rewrapping keys covers older versions", func(t *testing.T) {
+		require.NoError(t, fileRepo.BatchUpdateEncKeys(ctx, map[string]string{"key-uid": "enc-rewrapped"}))
+
+		versions, err := versionRepo.GetByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		for _, version := range versions {
+			assert.Equal(t, "enc-rewrapped", version.EncKey)
+		}
+	})
+
+	t.Run("version not found", func(t *testing.T) {
+		_, err := versionRepo.GetByVersion(ctx, job.FileID, 9)
+		assert.ErrorIs(t, err, model.ErrFileVersionNotFound)
+	})
+}
+
+func TestFileVersionRepository_BackfillOnUpdate(t *testing.T) {
+	db := setupTestDB(t)
+	jobRepo := repository.NewUploadJobRepository(db)
+	versionRepo := repository.NewFileVersionRepository(db)
+	ctx := context.Background()
+
+	// A file committed before versions were tracked
+	app := createTestApp(t, db)
+	require.NoError(t, db.Create(&entity.Files{ID: "file-legacy", AppID: app.ID, Name: "old.txt", MimeType: "text/plain", Status: constant.FileStatusStored}).Error)
+	require.NoError(t, db.Create(&entity.Metadata{ID: "metadata-legacy", FileID: "file-legacy", Hash: "hash-old", EncKey: "enc-old", KeyAlgo: "AES", VersionID: "v-old"}).Error)
+
+	update := &entity.UploadJobs{
+		ID:            "job-legacy-update",
+		FileID:        "file-legacy",
+		AppID:         app.ID,
+		Operation:     constant.JobOperationUpdate,
+		Status:        constant.JobStatusPending,
+		BucketName:    "test-bucket",
+		ObjectName:    "file-legacy",
+		NextAttemptAt: time.Now(),
+	}
+	require.NoError(t, jobRepo.Create(ctx, update, nil))
+	require.NoError(t, jobRepo.Complete(ctx, update,
+		&entity.Files{ID: "file-legacy", Name: "new.txt", Size: 5},
+		&entity.Metadata{FileID: "file-legacy", Hash: "hash-new", VersionID: "v-new"}))
+
+	versions, err := versionRepo.GetByFileID(ctx, "file-legacy")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "hash-new", versions[0].Hash)
+	assert.Equal(t, "hash-old", versions[1].Hash)
+	assert.Equal(t, "v-old", versions[1].VersionID)
+	assert.Equal(t, "old.txt", versions[1].Name)
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderRepository(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewFolderRepository(db)
+	ctx := context.Background()
+	appID := createTestApp(t, db).ID
+
+	createFolder := func(id string, parentID *string, name, folderPath string) *entity.Folders {
+		folder := &entity.Folders{ID: id, AppID: appID, ParentID: parentID, Name: name, Path: folderPath}
+		require.NoError(t, repo.Create(ctx, folder))
+		return folder
+	}
+	createFile := func(id string) *entity.Files {
+		file := &entity.Files{ID: id, AppID: appID, Name: id, MimeType: "text/plain", Size: 1}
+		require.NoError(t, db.Create(file).Error)
+		return file
+	}
+
+	docs := createFolder("folder-docs", nil, "docs", "/docs")
+	reports := createFolder("folder-reports", &docs.ID, "reports", "/docs/reports")
+	createFile("file-a")
+	createFile("file-b")
+	require.NoError(t, repo.PlaceFile(ctx, appID, "file-a", &reports.ID, "/docs/reports/a.txt"))
+
+	t.Run("paths are unique across folders and files", func(t *testing.T) {
+		err := repo.Create(ctx, &entity.Folders{ID: "dup", AppID: appID, Name: "a.txt", Path: "/docs/reports/a.txt"})
+		assert.ErrorIs(t, err, model.ErrPathConflict)
+
+		assert.ErrorIs(t, repo.PlaceFile(ctx, appID, "file-b", &docs.ID, "/docs/reports"), model.ErrPathConflict)
+		assert.ErrorIs(t, repo.PlaceFile(ctx, appID, "file-b", &reports.ID, "/docs/reports/a.txt"), model.ErrPathConflict)
+
+		// A file may be placed onto its own path again, and other apps have their own namespace
+		require.NoError(t, repo.PlaceFile(ctx, appID, "file-a", &reports.ID, "/docs/reports/a.txt"))
+		require.NoError(t, repo.Create(ctx, &entity.Folders{ID: "other-docs", AppID: "other-app", Name: "docs", Path: "/docs"}))
+	})
+
+	t.Run("looks up entries by path and lists children", func(t *testing.T) {
+		file, err := repo.GetFileByPath(ctx, appID, "/docs/reports/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "file-a", file.ID)
+		assert.Equal(t, "a.txt", file.Name)
+
+		_, err = repo.GetByPath(ctx, appID, "/missing")
+		assert.ErrorIs(t, err, model.ErrFolderNotFound)
+
+		folders, files, err := repo.ListChildren(ctx, appID, nil)
+		require.NoError(t, err)
+		require.Len(t, folders, 1)
+		assert.Equal(t, "docs", folders[0].Name)
+		assert.Empty(t, files, "files outside the tree are not listed at the root")
+
+		folders, files, err = repo.ListChildren(ctx, appID, &reports.ID)
+		require.NoError(t, err)
+		assert.Empty(t, folders)
+		require.Len(t, files, 1)
+		assert.Equal(t, "file-a", files[0].ID)
+	})
+
+	t.Run("moving a folder rewrites the paths below it", func(t *testing.T) {
+		archive := createFolder("folder-archive", nil, "archive", "/archive")
+		require.NoError(t, repo.Move(ctx, docs, &archive.ID, "/archive/docs_2024"))
+		assert.Equal(t, "docs_2024", docs.Name)
+
+		moved, err := repo.GetByPath(ctx, appID, "/archive/docs_2024/reports")
+		require.NoError(t, err)
+		assert.Equal(t, reports.ID, moved.ID)
+
+		file, err := repo.GetFileByPath(ctx, appID, "/archive/docs_2024/reports/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "file-a", file.ID)
+
+		files, err := repo.ListFilesUnder(ctx, appID, "/archive")
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+
+		// Wildcards in a path only match themselves
+		files, err = repo.ListFilesUnder(ctx, appID, "/archive/docs%")
+		require.NoError(t, err)
+		assert.Empty(t, files)
+
+		assert.ErrorIs(t, repo.Move(ctx, docs, nil, "/archive"), model.ErrPathConflict)
+	})
+
+	t.Run("a folder tree is only deleted once its files are gone", func(t *testing.T) {
+		assert.ErrorIs(t, repo.DeleteTree(ctx, appID, "/archive"), model.ErrFolderNotEmpty)
+
+		require.NoError(t, db.Delete(&entity.Files{}, "id = ?", "file-a").Error)
+		require.NoError(t, repo.DeleteTree(ctx, appID, "/archive"))
+
+		_, err := repo.GetByPath(ctx, appID, "/archive/docs_2024/reports")
+		assert.ErrorIs(t, err, model.ErrFolderNotFound)
+
+		var deleted entity.Files
+		require.NoError(t, db.Unscoped().Where("id = ?", "file-a").First(&deleted).Error)
+		assert.Empty(t, deleted.Path)
+		assert.Nil(t, deleted.FolderID)
+
+		assert.ErrorIs(t, repo.DeleteTree(ctx, appID, "/archive"), model.ErrFolderNotFound)
+	})
+
+	t.Run("deleted files free their path", func(t *testing.T) {
+		createFile("file-c")
+		require.NoError(t, repo.PlaceFile(ctx, appID, "file-c", nil, "/c.txt"))
+		require.NoError(t, db.Delete(&entity.Files{}, "id = ?", "file-c").Error)
+
+		taken, err := repo.PathTaken(ctx, appID, "/c.txt")
+		require.NoError(t, err)
+		assert.False(t, taken)
+		require.NoError(t, repo.PlaceFile(ctx, appID, "file-b", nil, "/c.txt"))
+
+		var count int64
+		require.NoError(t, db.Model(&entity.Files{}).Where("path = ?", "/c.txt").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestShareLink(t *testing.T, repo repository.ShareLinkRepository, id string, maxDownloads int) *entity.ShareLinks {
+	link := &entity.ShareLinks{
+		ID:           id,
+		AppID:        "test-app-id",
+		FileID:       "shared-file",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		MaxDownloads: maxDownloads,
+	}
+	require.NoError(t, repo.Create(context.Background(), link))
+	return link
+}
+
+func TestShareLinkRepository_ConsumeDownload(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewShareLinkRepository(db)
+	ctx := context.Background()
+
+	t.Run("limited link stops at its limit", func(t *testing.T) {
+		link := createTestShareLink(t, repo, "link-limited", 2)
+
+		require.NoError(t, repo.ConsumeDownload(ctx, link.ID))
+		require.NoError(t, repo.ConsumeDownload(ctx, link.ID))
+		assert.ErrorIs(t, repo.ConsumeDownload(ctx, link.ID), model.ErrShareLinkExhausted)
+
+		stored, err := repo.GetByID(ctx, link.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.Downloads)
+	})
+
+	t.Run("unlimited link keeps counting", func(t *testing.T) {
+		link := createTestShareLink(t, repo, "link-unlimited", 0)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, repo.ConsumeDownload(ctx, link.ID))
+		}
+
+		stored, err := repo.GetByID(ctx, link.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, stored.Downloads)
+	})
+
+	t.Run("concurrent downloads do not exceed the limit", func(t *testing.T) {
+		link := createTestShareLink(t, repo, "link-concurrent", 3)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if repo.ConsumeDownload(ctx, link.ID) == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 3, succeeded)
+	})
+
+	t.Run("revoked link cannot be used", func(t *testing.T) {
+		link := createTestShareLink(t, repo, "link-revoked", 0)
+		require.NoError(t, repo.Revoke(ctx, link.ID))
+		assert.ErrorIs(t, repo.ConsumeDownload(ctx, link.ID), model.ErrShareLinkExhausted)
+	})
+}
+
+func TestShareLinkRepository_Revoke(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewShareLinkRepository(db)
+	ctx := context.Background()
+
+	link := createTestShareLink(t, repo, "link-to-revoke", 0)
+	require.NoError(t, repo.Revoke(ctx, link.ID))
+
+	stored, err := repo.GetByID(ctx, link.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.RevokedAt)
+	revokedAt := *stored.RevokedAt
+
+	t.Run("revoking again keeps the first revocation", func(t *testing.T) {
+		require.NoError(t, repo.Revoke(ctx, link.ID))
+		stored, err := repo.GetByID(ctx, link.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.RevokedAt.Equal(revokedAt))
+	})
+
+	t.Run("unknown link", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Revoke(ctx, "missing-link"), model.ErrShareLinkNotFound)
+		_, err := repo.GetByID(ctx, "missing-link")
+		assert.ErrorIs(t, err, model.ErrShareLinkNotFound)
+	})
+
+	t.Run("links of a file newest first", func(t *testing.T) {
+		later := createTestShareLink(t, repo, "link-later", 0)
+		require.NoError(t, db.Model(later).Update("created_at", time.Now().Add(time.Minute)).Error)
+
+		links, err := repo.GetByFileID(ctx, "shared-file")
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+		assert.Equal(t, later.ID, links[0].ID)
+	})
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createPendingUpload creates a pending file row and its upload job
+func createPendingUpload(t *testing.T, db *gorm.DB, repo repository.UploadJobRepository, fileID string, nextAttemptAt time.Time) *entity.UploadJobs {
+	app := createTestApp(t, db)
+	file := &entity.Files{
+		ID:         fileID,
+		AppID:      app.ID,
+		Name:       "pending.txt",
+		BucketName: "test-bucket",
+		Status:     constant.FileStatusPending,
+	}
+	job := &entity.UploadJobs{
+		ID:            "job-" + fileID,
+		FileID:        fileID,
+		AppID:         app.ID,
+		Operation:     constant.JobOperationUpload,
+		Status:        constant.JobStatusPending,
+		BucketName:    "test-bucket",
+		ObjectName:    fileID,
+		NextAttemptAt: nextAttemptAt,
+	}
+	require.NoError(t, repo.Create(context.Background(), job, file))
+	return job
+}
+
+func TestUploadJobRepository_Create(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadJobRepository(db)
+	ctx := context.Background()
+
+	t.Run("successfully create job with pending file", func(t *testing.T) {
+		job := createPendingUpload(t, db, repo, "file-create", time.Now())
+
+		var file entity.Files
+		require.NoError(t, db.First(&file, "id = ?", job.FileID).Error)
+		assert.Equal(t, constant.FileStatusPending, file.Status)
+
+		found, err := repo.GetLatestByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		assert.Equal(t, job.ID, found.ID)
+	})
+
+	t.Run("fail with nil job", func(t *testing.T) {
+		err := repo.Create(ctx, nil, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("job not found", func(t *testing.T) {
+		_, err := repo.GetLatestByFileID(ctx, "missing-file")
+		assert.ErrorIs(t, err, model.ErrUploadJobNotFound)
+	})
+}
+
+func TestUploadJobRepository_GetDue(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadJobRepository(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	due := createPendingUpload(t, db, repo, "file-due", now.Add(-time.Minute))
+	createPendingUpload(t, db, repo, "file-later", now.Add(time.Hour))
+
+	jobs, err := repo.GetDue(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, due.ID, jobs[0].ID)
+}
+
+func TestUploadJobRepository_Update(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadJobRepository(db)
+	ctx := context.Background()
+
+	t.Run("save the changed columns of a job still in its status", func(t *testing.T) {
+		job := createPendingUpload(t, db, repo, "file-update", time.Now())
+		job.Payload = "payload"
+		job.VersionID = "v1"
+		job.Attempts = 2
+		require.NoError(t, repo.Update(ctx, job, constant.JobStatusPending))
+
+		found, err := repo.GetLatestByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		assert.Equal(t, "payload", found.Payload)
+		assert.Equal(t, "v1", found.VersionID)
+		assert.Equal(t, 2, found.Attempts)
+		assert.Equal(t, constant.JobStatusPending, found.Status)
+	})
+
+	t.Run("a settled job is not reopened", func(t *testing.T) {
+		job := createPendingUpload(t, db, repo, "file-settled", time.Now())
+		stale := *job
+		require.NoError(t, repo.Fail(ctx, job))
+
+		stale.Status = constant.JobStatusCompensating
+		err := repo.Update(ctx, &stale, constant.JobStatusPending)
+		assert.ErrorIs(t, err, model.ErrUploadJobNotFound)
+
+		found, err := repo.GetLatestByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		assert.Equal(t, constant.JobStatusFailed, found.Status)
+	})
+
+	t.Run("a failed commit leaves the job in its stored status", func(t *testing.T) {
+		job := createPendingUpload(t, db, repo, "file-rolled-back", time.Now())
+		// The file row is missing, so the commit rolls back after the job was claimed
+		err := repo.Complete(ctx, job, &entity.Files{ID: "missing-file"}, &entity.Metadata{FileID: "missing-file"})
+		require.ErrorIs(t, err, model.ErrFileNotFound)
+		assert.Equal(t, constant.JobStatusPending, job.Status)
+
+		job.Attempts++
+		require.NoError(t, repo.Update(ctx, job, constant.JobStatusPending))
+	})
+}
+
+func TestUploadJobRepository_Complete(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadJobRepository(db)
+	ctx := context.Background()
+
+	job := createPendingUpload(t, db, repo, "file-complete", time.Now())
+	file := &entity.Files{ID: job.FileID, Name: "done.txt", Size: 42, Location: "file-complete"}
+	metadata := &entity.Metadata{ID: "metadata-complete", FileID: job.FileID, KeyUID: "key", EncKey: "enc", EncHash: "hash"}
+
+	t.Run("commit file and metadata", func(t *testing.T) {
+		require.NoError(t, repo.Complete(ctx, job, file, metadata))
+
+		var stored entity.Files
+		require.NoError(t, db.First(&stored, "id = ?", job.FileID).Error)
+		assert.Equal(t, constant.FileStatusStored, stored.Status)
+		assert.Equal(t, "done.txt", stored.Name)
+
+		var meta entity.Metadata
+		require.NoError(t, db.First(&meta, "file_id = ?", job.FileID).Error)
+
+		found, err := repo.GetLatestByFileID(ctx, job.FileID)
+		require.NoError(t, err)
+		assert.Equal(t, constant.JobStatusDone, found.Status)
+		assert.Empty(t, found.Payload)
+	})
+
+	t.Run("settled job cannot be claimed again", func(t *testing.T) {
+		err := repo.Fail(ctx, job)
+		assert.ErrorIs(t, err, model.ErrUploadJobNotFound)
+	})
+
+	t.Run("update replaces every column of the version, empty ones included", func(t *testing.T) {
+		update := &entity.UploadJobs{
+			ID: "job-update-complete", FileID: job.FileID, AppID: job.AppID, Operation: constant.JobOperationUpdate,
+			Status: constant.JobStatusPending, BucketName: "test-bucket", ObjectName: job.ObjectName, NextAttemptAt: time.Now(),
+		}
+		require.NoError(t, repo.Create(ctx, update, nil))
+		promoted := &entity.Metadata{FileID: job.FileID, Hash: "plain-2", KeyFingerprint: "customer", KeyAlgo: "aes", VersionID: "v2"}
+		require.NoError(t, repo.Complete(ctx, update, &entity.Files{ID: job.FileID, Name: "done.txt", Size: 7}, promoted))
+
+		var meta entity.Metadata
+		require.NoError(t, db.First(&meta, "file_id = ?", job.FileID).Error)
+		assert.Equal(t, "plain-2", meta.Hash)
+		assert.Empty(t, meta.EncHash, "a version stored without a ciphertext hash does not keep the previous one")
+		assert.Empty(t, meta.KeyUID)
+		assert.Empty(t, meta.EncKey)
+		assert.Equal(t, "customer", meta.KeyFingerprint)
+		assert.Equal(t, "v2", meta.VersionID)
+	})
+}
+
+func TestUploadJobRepository_Fail(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadJobRepository(db)
+	ctx := context.Background()
+
+	job := createPendingUpload(t, db, repo, "file-fail", time.Now())
+	require.NoError(t, repo.Fail(ctx, job))
+
+	var file entity.Files
+	require.NoError(t, db.First(&file, "id = ?", job.FileID).Error)
+	assert.Equal(t, constant.FileStatusFailed, file.Status)
+
+	found, err := repo.GetLatestByFileID(ctx, job.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, constant.JobStatusFailed, found.Status)
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createOpenSession creates an open upload session and its pending file
+func createOpenSession(t *testing.T, db *gorm.DB, repo repository.UploadSessionRepository, id string, expiresAt time.Time) *entity.UploadSessions {
+	app := createTestApp(t, db)
+	file := &entity.Files{
+		ID:         "file-" + id,
+		AppID:      app.ID,
+		Name:       "large.bin",
+		BucketName: "test-bucket",
+		Status:     constant.FileStatusPending,
+	}
+	session := &entity.UploadSessions{
+		ID:              id,
+		AppID:           app.ID,
+		FileID:          file.ID,
+		FileName:        file.Name,
+		BucketName:      "test-bucket",
+		ObjectName:      file.ID + ".enc",
+		StorageUploadID: "storage-upload-id",
+		EncKey:          "wrapped-key",
+		Status:          constant.SessionStatusOpen,
+		ExpiresAt:       expiresAt,
+	}
+	require.NoError(t, repo.Create(context.Background(), session, file))
+	return session
+}
+
+func TestUploadSessionRepository_CreateAndGet(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadSessionRepository(db)
+	ctx := context.Background()
+
+	session := createOpenSession(t, db, repo, "session-1", time.Now().Add(time.Hour))
+
+	found, err := repo.GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, session.FileID, found.FileID)
+	assert.Equal(t, constant.SessionStatusOpen, found.Status)
+
+	_, err = repo.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, model.ErrUploadSessionNotFound)
+}
+
+func TestUploadSessionRepository_SavePart(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadSessionRepository(db)
+	ctx := context.Background()
+
+	session := createOpenSession(t, db, repo, "session-parts", time.Now().Add(time.Hour))
+
+	for _, n := range []int{2, 1} {
+		require.NoError(t, repo.SavePart(ctx, session, &entity.UploadParts{
+			SessionID: session.ID, PartNumber: n, FileID: session.FileID, ETag: "etag", Size: 10, EncSize: 50, Hash: "hash",
+		}))
+	}
+
+	t.Run("re-sent part replaces the earlier one", func(t *testing.T) {
+		require.NoError(t, repo.SavePart(ctx, session, &entity.UploadParts{
+			SessionID: session.ID, PartNumber: 2, FileID: session.FileID, ETag: "etag-retry", Size: 20, EncSize: 60, Hash: "hash-retry",
+		}))
+
+		parts, err := repo.GetParts(ctx, session.ID)
+		require.NoError(t, err)
+		require.Len(t, parts, 2)
+		assert.Equal(t, 1, parts[0].PartNumber)
+		assert.Equal(t, "etag-retry", parts[1].ETag)
+		assert.Equal(t, int64(20), parts[1].Size)
+	})
+
+	t.Run("parts are found by file", func(t *testing.T) {
+		parts, err := repo.GetPartsByFileID(ctx, session.FileID)
+		require.NoError(t, err)
+		assert.Len(t, parts, 2)
+	})
+
+	t.Run("a session that is no longer open rejects parts", func(t *testing.T) {
+		require.NoError(t, repo.UpdateStatus(ctx, session, []string{constant.SessionStatusOpen}, constant.SessionStatusCompleting))
+
+		err := repo.SavePart(ctx, session, &entity.UploadParts{
+			SessionID: session.ID, PartNumber: 3, FileID: session.FileID, ETag: "etag-late", Size: 10, EncSize: 50, Hash: "hash",
+		})
+		assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+		parts, err := repo.GetParts(ctx, session.ID)
+		require.NoError(t, err)
+		assert.Len(t, parts, 2)
+	})
+}
+
+func TestUploadSessionRepository_UpdateStatus(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadSessionRepository(db)
+	ctx := context.Background()
+
+	session := createOpenSession(t, db, repo, "session-status", time.Now().Add(time.Hour))
+
+	require.NoError(t, repo.UpdateStatus(ctx, session, []string{constant.SessionStatusOpen}, constant.SessionStatusCompleting))
+	assert.Equal(t, constant.SessionStatusCompleting, session.Status)
+
+	// A second completion cannot claim the session again
+	err := repo.UpdateStatus(ctx, &entity.UploadSessions{ID: session.ID}, []string{constant.SessionStatusOpen}, constant.SessionStatusCompleting)
+	assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+
+	// Update leaves the status alone
+	session.ExpiresAt = time.Now().Add(2 * time.Hour)
+	session.Status = constant.SessionStatusOpen
+	require.NoError(t, repo.Update(ctx, session))
+	found, err := repo.GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, constant.SessionStatusCompleting, found.Status)
+}
+
+func TestUploadSessionRepository_CloseAndExpire(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadSessionRepository(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	expired := createOpenSession(t, db, repo, "session-expired", now.Add(-time.Minute))
+	createOpenSession(t, db, repo, "session-active", now.Add(time.Hour))
+
+	sessions, err := repo.GetExpired(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, expired.ID, sessions[0].ID)
+
+	require.NoError(t, repo.Close(ctx, &sessions[0], constant.SessionStatusExpired))
+
+	var file entity.Files
+	require.NoError(t, db.First(&file, "id = ?", expired.FileID).Error)
+	assert.Equal(t, constant.FileStatusFailed, file.Status)
+
+	err = repo.Close(ctx, &sessions[0], constant.SessionStatusAborted)
+	assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+
+	sessions, err = repo.GetExpired(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadTokenRepository(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewUploadTokenRepository(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, id := range []string{"token-old", "token-new"} {
+		require.NoError(t, repo.Create(ctx, &entity.UploadTokens{
+			ID:               id,
+			AppID:            "test-app-id",
+			MaxSize:          1024,
+			AllowedMimeTypes: "image/*,application/pdf",
+			Folder:           "/drops",
+			ExpiresAt:        now.Add(time.Hour),
+			CreatedAt:        now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	t.Run("lists tokens of an app newest first", func(t *testing.T) {
+		tokens, err := repo.GetByAppID(ctx, "test-app-id")
+		require.NoError(t, err)
+		require.Len(t, tokens, 2)
+		assert.Equal(t, "token-new", tokens[0].ID)
+		assert.Equal(t, "token-old", tokens[1].ID)
+
+		tokens, err = repo.GetByAppID(ctx, "other-app")
+		require.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+
+	t.Run("revoke is idempotent", func(t *testing.T) {
+		require.NoError(t, repo.Revoke(ctx, "token-old"))
+		first, err := repo.GetByID(ctx, "token-old")
+		require.NoError(t, err)
+		require.NotNil(t, first.RevokedAt)
+
+		require.NoError(t, repo.Revoke(ctx, "token-old"))
+		second, err := repo.GetByID(ctx, "token-old")
+		require.NoError(t, err)
+		assert.True(t, first.RevokedAt.Equal(*second.RevokedAt))
+
+		untouched, err := repo.GetByID(ctx, "token-new")
+		require.NoError(t, err)
+		assert.Nil(t, untouched.RevokedAt)
+	})
+
+	t.Run("unknown token is not found", func(t *testing.T) {
+		_, err := repo.GetByID(ctx, "missing")
+		assert.ErrorIs(t, err, model.ErrUploadTokenNotFound)
+		assert.ErrorIs(t, repo.Revoke(ctx, "missing"), model.ErrUploadTokenNotFound)
+	})
+}
@@ -0,0 +1,224 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubManagedOAuth2 also answers the grant changes made when apps are deleted and recovered
+type stubManagedOAuth2 struct {
+	stubOAuth2
+}
+
+func (s *stubManagedOAuth2) CreateClient(ctx context.Context, input *model.ApplicationRequest) (*model.OAuth2ClientResponse, error) {
+	now := time.Now()
+	return &model.OAuth2ClientResponse{ClientId: "reports-client", ClientSecret: "secret", ClientName: input.ClientName, CreatedAt: &now, UpdatedAt: &now}, nil
+}
+
+func (s *stubManagedOAuth2) UpdateClient(ctx context.Context, clientName string, operations string, path string, value interface{}) (*model.OAuth2ClientResponse, error) {
+	return &model.OAuth2ClientResponse{ClientId: clientName}, nil
+}
+
+type storedVersion struct {
+	id      string
+	content string
+}
+
+// stubBucketVersions keeps the versions of objects in memory by bucket and name, oldest first
+type stubBucketVersions struct {
+	services.StorageInterface
+	objects map[string][]storedVersion
+	buckets map[string]bool
+	written int
+}
+
+func (s *stubBucketVersions) CreateBucket(ctx context.Context, bucketName string) error {
+	s.buckets[bucketName] = true
+	return nil
+}
+
+func (s *stubBucketVersions) RemoveBucket(ctx context.Context, bucketName string) error {
+	for key, versions := range s.objects {
+		if len(versions) > 0 && key[:len(bucketName)+1] == bucketName+"/" {
+			return model.ErrBucketNotEmpty
+		}
+	}
+	delete(s.buckets, bucketName)
+	return nil
+}
+
+func (s *stubBucketVersions) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	s.written++
+	version := storedVersion{id: fmt.Sprintf("copy-%d", s.written), content: string(content)}
+	s.objects[bucketName+"/"+fileName] = append(s.objects[bucketName+"/"+fileName], version)
+	return &model.StorageTransactionResponse{Location: bucketName + "/" + fileName, VersionID: version.id}, nil
+}
+
+func (s *stubBucketVersions) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	for _, version := range s.objects[bucketName+"/"+fileName] {
+		if version.id == versionID {
+			return io.NopCloser(bytes.NewReader([]byte(version.content))), nil
+		}
+	}
+	return nil, model.ErrFileNotFound
+}
+
+func (s *stubBucketVersions) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	var ids []string
+	for _, version := range s.objects[bucketName+"/"+fileName] {
+		ids = append(ids, version.id)
+	}
+	return ids, nil
+}
+
+func (s *stubBucketVersions) DeleteFileVersion(ctx context.Context, bucketName, fileName, versionID string) error {
+	var kept []storedVersion
+	for _, version := range s.objects[bucketName+"/"+fileName] {
+		if version.id != versionID {
+			kept = append(kept, version)
+		}
+	}
+	s.objects[bucketName+"/"+fileName] = kept
+	return nil
+}
+
+func (s *stubBucketVersions) contents(key string) []string {
+	var contents []string
+	for _, version := range s.objects[key] {
+		contents = append(contents, version.content)
+	}
+	return contents
+}
+
+func TestAppBuckets(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("apps get a bucket of their own that is removed with them once empty", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubBucketVersions{objects: map[string][]storedVersion{}, buckets: map[string]bool{}}
+		appService := services.NewApplicationService(services.ApplicationServiceParams{
+			OAuth2Service: &stubManagedOAuth2{}, ApplicationRepository: params.ApplicationRepository,
+			StorageService: storage, FileLogsRepository: params.FileLogsRepository, AppBucketPrefix: "crypsis-app-",
+		})
+
+		empty, err := appService.AddApp(ctx, "reports", "https://reports.example.com", "https://reports.example.com/callback")
+		require.NoError(t, err)
+		var app entity.Apps
+		require.NoError(t, db.Where("name = ?", "reports").First(&app).Error)
+		assert.Equal(t, "crypsis-app-"+app.ID, app.BucketName)
+		assert.Equal(t, app.BucketName, empty.BucketName)
+		assert.True(t, storage.buckets[app.BucketName])
+
+		require.NoError(t, appService.DeleteApp(ctx, app.ID))
+		assert.False(t, storage.buckets[app.BucketName], "an empty bucket is decommissioned")
+
+		_, err = appService.RecoverApp(ctx, app.ID)
+		require.NoError(t, err)
+		assert.True(t, storage.buckets[app.BucketName], "a recovered app gets its bucket back")
+
+		storage.objects[app.BucketName+"/file.enc"] = []storedVersion{{id: "v1", content: "sealed"}}
+		require.NoError(t, appService.DeleteApp(ctx, app.ID))
+		assert.True(t, storage.buckets[app.BucketName], "a bucket still holding files is kept")
+	})
+
+	t.Run("migration moves every version of a file and leaves held and busy files for later", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubBucketVersions{objects: map[string][]storedVersion{
+			"files/file-1.enc": {{id: "v1", content: "first"}, {id: "v2", content: "second"}},
+			"files/file-2.enc": {{id: "h1", content: "held"}},
+			"files/file-3.enc": {{id: "b1", content: "busy"}},
+		}, buckets: map[string]bool{}}
+		require.NoError(t, db.Create(&[]entity.Files{
+			{ID: "file-2", Name: "held.pdf", AppID: "app-1", MimeType: "application/pdf", Size: 4, LegalHold: true},
+			{ID: "file-3", Name: "busy.pdf", AppID: "app-1", MimeType: "application/pdf", Size: 4, BucketName: "files"},
+		}).Error)
+		require.NoError(t, db.Create(&[]entity.Metadata{
+			{ID: "meta-2", FileID: "file-2", Hash: "h", KeyUID: "k", EncKey: "e", KeyAlgo: "aes", VersionID: "h1"},
+			{ID: "meta-3", FileID: "file-3", Hash: "h", KeyUID: "k", EncKey: "e", KeyAlgo: "aes", VersionID: "b1"},
+		}).Error)
+		busy := &entity.UploadJobs{ID: "job-busy", FileID: "file-3", AppID: "app-1", Operation: constant.JobOperationUpdate,
+			Status: constant.JobStatusPending, BucketName: "files", ObjectName: "file-3.enc", NextAttemptAt: time.Now()}
+		require.NoError(t, db.Create(busy).Error)
+
+		appService := services.NewApplicationService(services.ApplicationServiceParams{
+			ApplicationRepository: params.ApplicationRepository, StorageService: storage,
+			FileLogsRepository: params.FileLogsRepository, AppBucketPrefix: "crypsis-app-",
+		})
+		migration := services.NewBucketMigrationService(services.BucketMigrationServiceParams{
+			StorageService: storage, FileRepository: params.FileRepository, FileVersionRepository: params.FileVersionRepository,
+			ApplicationRepository: params.ApplicationRepository, FileLogsRepository: params.FileLogsRepository,
+			DefaultBucket: "files", BatchSize: 1,
+		})
+
+		status, err := appService.MigrateAppBucket(ctx, "app-1")
+		require.NoError(t, err)
+		assert.Equal(t, "crypsis-app-app-1", status.BucketName)
+		assert.True(t, status.Dedicated)
+		assert.True(t, status.MigrationPending)
+		assert.Equal(t, int64(3), status.FilesOutsideBucket)
+		assert.True(t, storage.buckets["crypsis-app-app-1"], "an app without a bucket is given its own first")
+
+		report, err := migration.MigratePending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.FilesMoved)
+		assert.Equal(t, 1, report.Failures, "a file with an update in flight is retried")
+		assert.Equal(t, []string{"first", "second"}, storage.contents("crypsis-app-app-1/file-1.enc"), "versions are copied oldest first")
+		assert.Empty(t, storage.contents("files/file-1.enc"))
+		assert.Empty(t, storage.contents("crypsis-app-app-1/file-3.enc"), "copies of a file that could not move are removed")
+		assert.Equal(t, []string{"held"}, storage.contents("files/file-2.enc"))
+
+		var moved entity.Files
+		require.NoError(t, db.Unscoped().First(&moved, "id = ?", "file-1").Error)
+		assert.Equal(t, "crypsis-app-app-1", moved.BucketName)
+		var versions []entity.FileVersions
+		require.NoError(t, db.Where("file_id = ?", "file-1").Order("version").Find(&versions).Error)
+		copied := storage.objects["crypsis-app-app-1/file-1.enc"]
+		assert.Equal(t, copied[0].id, versions[0].VersionID)
+		assert.Equal(t, copied[1].id, versions[1].VersionID)
+		var metadata entity.Metadata
+		require.NoError(t, db.Unscoped().First(&metadata, "file_id = ?", "file-1").Error)
+		assert.Equal(t, copied[1].id, metadata.VersionID, "the current contents are the latest copy")
+
+		status, err = appService.GetAppBucket(ctx, "app-1")
+		require.NoError(t, err)
+		assert.True(t, status.MigrationPending)
+
+		require.NoError(t, db.Model(busy).Update("status", constant.JobStatusDone).Error)
+		report, err = migration.MigratePending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.FilesMoved)
+		assert.Zero(t, report.Failures)
+
+		status, err = appService.GetAppBucket(ctx, "app-1")
+		require.NoError(t, err)
+		assert.False(t, status.MigrationPending, "the migration finishes once only held files remain")
+		assert.Equal(t, int64(1), status.FilesOutsideBucket)
+
+		var audited int64
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("action = ? AND resource_type = ?", "migrate", "file").Count(&audited).Error)
+		assert.Equal(t, int64(2), audited, "each file moved is audited")
+
+		late := &entity.UploadJobs{ID: "job-late", FileID: "file-3", AppID: "app-1", Operation: constant.JobOperationUpdate,
+			Status: constant.JobStatusPending, BucketName: "files", ObjectName: "file-3.enc", NextAttemptAt: time.Now()}
+		require.NoError(t, db.Create(late).Error)
+		err = repository.NewUploadJobRepository(db).Complete(ctx, late,
+			&entity.Files{ID: "file-3", Size: 4}, &entity.Metadata{FileID: "file-3", VersionID: "late"})
+		assert.ErrorIs(t, err, model.ErrFileMoveConflict, "an update written to the old bucket cannot land after the move")
+	})
+}
@@ -0,0 +1,129 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppQuota(t *testing.T) {
+	ctx := context.Background()
+	int64Ptr := func(v int64) *int64 { return &v }
+
+	t.Run("admins set limits and see usage against them", func(t *testing.T) {
+		db, params := setupPurge(t)
+		require.NoError(t, db.Create(&entity.AppUsage{AppID: "app-1", StoredBytes: 600, FileCount: 3}).Error)
+		appService := services.NewApplicationService(services.ApplicationServiceParams{
+			ApplicationRepository: params.ApplicationRepository, FileLogsRepository: params.FileLogsRepository,
+		})
+
+		usage, err := appService.SetQuota(ctx, "app-1", model.AppQuotaRequest{MaxStorageBytes: int64Ptr(1000), MaxFiles: int64Ptr(3)})
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), usage.StoredBytes)
+		assert.Equal(t, int64(3), usage.FileCount)
+		assert.Equal(t, int64(1000), usage.MaxStorageBytes)
+		assert.Zero(t, usage.MaxFileSize, "limits left out of the request are unchanged")
+
+		_, err = appService.SetQuota(ctx, "app-1", model.AppQuotaRequest{MaxFiles: int64Ptr(-1)})
+		assert.ErrorIs(t, err, model.ErrInvalidQuota)
+
+		var logged int64
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("resource_type = ? AND resource_id = ?", "app", "app-1").Count(&logged).Error)
+		assert.Equal(t, int64(2), logged, "quota changes are audited, failed ones included")
+	})
+
+	t.Run("uploads over quota are refused before anything is stored", func(t *testing.T) {
+		db, params := setupPurge(t)
+		require.NoError(t, db.Create(&entity.AppUsage{AppID: "app-1", StoredBytes: 600, FileCount: 3}).Error)
+		// The stub panics on uploads, so reaching storage fails the test
+		params.StorageService = &stubVersionedStorage{versions: map[string][]string{}}
+		fileService := services.NewFileService(params)
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Update("max_files", 3).Error)
+		_, err := fileService.UploadFile(ctx, "billing-client", "a.txt", strings.NewReader("hello"), model.UploadOptions{})
+		assert.ErrorIs(t, err, model.ErrFileCountQuotaExceeded)
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Updates(map[string]interface{}{
+			"max_files": 0, "max_storage_bytes": 600,
+		}).Error)
+		_, err = fileService.UploadFile(ctx, "billing-client", "a.txt", strings.NewReader("hello"), model.UploadOptions{})
+		assert.ErrorIs(t, err, model.ErrStorageQuotaExceeded)
+
+		usage, err := fileService.GetUsage(ctx, "billing-client")
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), usage.StoredBytes)
+		assert.Equal(t, int64(600), usage.MaxStorageBytes)
+	})
+
+	t.Run("recovering a file that no longer fits is refused before anything is restored", func(t *testing.T) {
+		db, params := setupPurge(t)
+		require.NoError(t, db.Where("file_id = ?", "file-1").Delete(&entity.Metadata{}).Error)
+		require.NoError(t, db.Create(&entity.AppUsage{AppID: "app-1", StoredBytes: 600, FileCount: 3}).Error)
+		// The stub panics on restores, so reaching storage fails the test
+		params.StorageService = &stubVersionedStorage{versions: map[string][]string{}}
+		fileService := services.NewFileService(params)
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Update("max_files", 3).Error)
+		_, err := fileService.RecoverFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrFileCountQuotaExceeded)
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Updates(map[string]interface{}{
+			"max_files": 0, "max_storage_bytes": 605,
+		}).Error)
+		_, err = fileService.RecoverFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrStorageQuotaExceeded, "the file is larger than what is left")
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Updates(map[string]interface{}{
+			"max_storage_bytes": 0, "max_file_size": 5,
+		}).Error)
+		_, err = fileService.RecoverFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrFileTooLarge)
+
+		var live int64
+		require.NoError(t, db.Model(&entity.Files{}).Where("id = ?", "file-1").Count(&live).Error)
+		assert.Zero(t, live, "the file stays deleted")
+	})
+
+	t.Run("promoting a version that no longer fits is refused before anything is copied", func(t *testing.T) {
+		db, params, storage := setupMemoryFiles(t)
+		fileService := services.NewFileService(params)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "data.csv", strings.NewReader(strings.Repeat("x", 100)), model.UploadOptions{})
+		require.NoError(t, err)
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "data.csv", strings.NewReader(strings.Repeat("y", 10)), model.UploadOptions{})
+		require.NoError(t, err)
+		versions, err := storage.ListFileVersion(ctx, "files", fileID+".enc")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+
+		// 10 bytes are stored, and replacing them leaves 50 for the promoted version
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Update("max_storage_bytes", 50).Error)
+		_, err = fileService.PromoteFileVersion(ctx, "billing-client", fileID, 1)
+		assert.ErrorIs(t, err, model.ErrStorageQuotaExceeded)
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Updates(map[string]interface{}{
+			"max_storage_bytes": 0, "max_file_size": 50,
+		}).Error)
+		_, err = fileService.PromoteFileVersion(ctx, "billing-client", fileID, 1)
+		assert.ErrorIs(t, err, model.ErrFileTooLarge)
+
+		versions, err = storage.ListFileVersion(ctx, "files", fileID+".enc")
+		require.NoError(t, err)
+		assert.Len(t, versions, 2, "no version was copied")
+
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Update("max_storage_bytes", 110).Error)
+		require.NoError(t, db.Model(&entity.Apps{}).Where("id = ?", "app-1").Update("max_file_size", 0).Error)
+		promoted, err := fileService.PromoteFileVersion(ctx, "billing-client", fileID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), promoted.Size)
+		usage, err := fileService.GetUsage(ctx, "billing-client")
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), usage.StoredBytes, "usage follows the promoted version")
+	})
+}
@@ -0,0 +1,295 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupAuditChain writes a chain of count entries, the first half older than 30 days, and returns the database
+// together with an audit service signing with a test KEK
+func setupAuditChain(t *testing.T, count int) (*gorm.DB, services.AuditInterface) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.FileLogs{}, &entity.AuditCheckpoints{}))
+
+	repo := repository.NewFileLogRepository(db)
+	for i := 0; i < count; i++ {
+		age := 1
+		if i < count/2 {
+			age = 60
+		}
+		require.NoError(t, repo.Create(context.Background(), &entity.FileLogs{
+			ActorID:   "app-1",
+			ActorType: "client",
+			FileID:    fmt.Sprintf("file-%d", i),
+			Action:    "download",
+			IP:        "192.168.0.10",
+			Timestamp: time.Now().AddDate(0, 0, -age).Add(time.Duration(i) * time.Second),
+			Metadata:  entity.JSONB{"file_name": fmt.Sprintf("report-%d.pdf", i)},
+		}))
+	}
+
+	auditService := services.NewAuditService(services.AuditServiceParams{
+		FileLogsRepository: repo,
+		KeyConfig:          &model.KeyConfig{KEK: "dGVzdC1rZWs="},
+	})
+	return db, auditService
+}
+
+func TestAuditService_VerifyChain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("an untouched chain verifies", func(t *testing.T) {
+		_, auditService := setupAuditChain(t, 6)
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, auditService.PurgeOldLogs(ctx, 30))
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Nil(t, result.FirstBreak)
+		assert.True(t, result.SignaturesVerified)
+		assert.Equal(t, int64(3), result.EntriesChecked)
+		assert.Equal(t, uint(3), result.AnchorLogID)
+		assert.Equal(t, uint(6), result.HeadLogID)
+		assert.Equal(t, 1, result.CheckpointsChecked)
+	})
+
+	t.Run("an altered entry is reported", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("id = ?", 4).Update("action", "upload").Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(4), result.FirstBreak.LogID)
+		assert.Contains(t, result.FirstBreak.Reason, "content")
+	})
+
+	t.Run("a removed entry is reported at the entry after it", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		require.NoError(t, db.Delete(&entity.FileLogs{}, 2).Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(3), result.FirstBreak.LogID)
+	})
+
+	t.Run("a truncated tail is caught by the checkpoint over it", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, db.Where("id >= ?", 5).Delete(&entity.FileLogs{}).Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(6), result.FirstBreak.LogID)
+		assert.Contains(t, result.FirstBreak.Reason, "missing")
+	})
+
+	t.Run("a forged retention anchor is rejected", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		require.NoError(t, auditService.PurgeOldLogs(ctx, 30))
+		require.NoError(t, db.Model(&entity.AuditCheckpoints{}).Where("kind = ?", "retention").Update("log_hash", "forged").Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(3), result.FirstBreak.LogID)
+		assert.Contains(t, result.FirstBreak.Reason, "signature")
+	})
+
+	t.Run("a removed checkpoint leaves a gap in their sequence", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, repository.NewFileLogRepository(db).Create(ctx, &entity.FileLogs{ActorID: "app-1", ActorType: "client", FileID: "file-6", Action: "download"}))
+		_, err = auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, db.Where("sequence = ?", 1).Delete(&entity.AuditCheckpoints{}).Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(7), result.FirstBreak.LogID)
+		assert.Contains(t, result.FirstBreak.Reason, "sequence")
+	})
+
+	t.Run("a rewritten chain without its checkpoints is reported", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 6)
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, auditService.PurgeOldLogs(ctx, 30))
+
+		// Rewrite the kept entries as the start of a new chain and drop every checkpoint, the anchor included
+		prevHash := ""
+		for id := 4; id <= 6; id++ {
+			var entry entity.FileLogs
+			require.NoError(t, db.First(&entry, id).Error)
+			entry.PrevHash = prevHash
+			if id == 5 {
+				entry.Action = "upload"
+			}
+			entry.Hash = entry.ComputeHash()
+			require.NoError(t, db.Model(&entry).Updates(map[string]interface{}{"prev_hash": entry.PrevHash, "action": entry.Action, "hash": entry.Hash}).Error)
+			prevHash = entry.Hash
+		}
+		require.NoError(t, db.Where("1 = 1").Delete(&entity.AuditCheckpoints{}).Error)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Equal(t, uint(6), result.FirstBreak.LogID)
+		assert.Contains(t, result.FirstBreak.Reason, "checkpoint interval")
+	})
+
+	t.Run("a head checkpoint older than twice the interval is reported", func(t *testing.T) {
+		db, _ := setupAuditChain(t, 2)
+		auditService := services.NewAuditService(services.AuditServiceParams{
+			FileLogsRepository: repository.NewFileLogRepository(db),
+			KeyConfig:          &model.KeyConfig{KEK: "dGVzdC1rZWs="},
+			Interval:           10 * time.Millisecond,
+		})
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "a fresh checkpoint covers the head")
+
+		time.Sleep(30 * time.Millisecond)
+		result, err = auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBreak)
+		assert.Contains(t, result.FirstBreak.Reason, "checkpoint interval")
+
+		checkpoint, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, checkpoint, "an unchanged head is signed again")
+		result, err = auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+	})
+
+	t.Run("entries from before the chain lead it unchained", func(t *testing.T) {
+		db, auditService := setupAuditChain(t, 2)
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("id = ?", 1).Updates(map[string]interface{}{"hash": "", "prev_hash": ""}).Error)
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("id = ?", 2).Update("prev_hash", "").Error)
+		var second entity.FileLogs
+		require.NoError(t, db.First(&second, 2).Error)
+		require.NoError(t, db.Model(&second).Update("hash", second.ComputeHash()).Error)
+		_, err := auditService.Checkpoint(ctx)
+		require.NoError(t, err)
+
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, int64(1), result.UnchainedEntries)
+		assert.Equal(t, int64(1), result.EntriesChecked)
+	})
+
+	t.Run("checkpoints need a signing key", func(t *testing.T) {
+		db, _ := setupAuditChain(t, 1)
+		unsigned := services.NewAuditService(services.AuditServiceParams{FileLogsRepository: repository.NewFileLogRepository(db)})
+
+		_, err := unsigned.Checkpoint(ctx)
+		assert.ErrorIs(t, err, model.ErrAuditSigningKeyMissing)
+		assert.ErrorIs(t, unsigned.PurgeOldLogs(ctx, 30), model.ErrAuditSigningKeyMissing)
+
+		result, err := unsigned.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.False(t, result.SignaturesVerified)
+	})
+}
+
+func TestAuditService_ExportLogs(t *testing.T) {
+	ctx := context.Background()
+	db, auditService := setupAuditChain(t, 4)
+	require.NoError(t, db.Model(&entity.FileLogs{}).Where("id = ?", 2).Update("user_agent", "curl/8.0 a=b|c").Error)
+
+	t.Run("JSON Lines carry one event per line", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, auditService.ExportLogs(ctx, model.LogFilter{}, "jsonl", &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 4)
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+		assert.Equal(t, float64(1), event["log_id"])
+		assert.Equal(t, "download", event["action"])
+		assert.Equal(t, "report-0.pdf", event["metadata"].(map[string]interface{})["file_name"])
+		assert.NotEmpty(t, event["hash"])
+	})
+
+	t.Run("CSV applies the listing filters", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, auditService.ExportLogs(ctx, model.LogFilter{FileID: "file-2"}, "csv", &out))
+
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "log_id", records[0][0])
+		assert.Equal(t, "3", records[1][0])
+		assert.Equal(t, "file-2", records[1][5])
+		assert.JSONEq(t, `{"file_name":"report-2.pdf"}`, records[1][8])
+	})
+
+	t.Run("CEF escapes header and extension values", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, auditService.ExportLogs(ctx, model.LogFilter{ActorID: "app-1"}, "cef", &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.True(t, strings.HasPrefix(lines[1], "CEF:0|Crypsis|crypsis-backend|"))
+		assert.Contains(t, lines[1], "|download|File download|5|")
+		assert.Contains(t, lines[1], "externalId=2 ")
+		assert.Contains(t, lines[1], `requestClientApplication=curl/8.0 a\=b|c`)
+		assert.Contains(t, lines[1], "src=192.168.0.10")
+	})
+
+	t.Run("bucket migrations can be filtered on", func(t *testing.T) {
+		require.NoError(t, repository.NewFileLogRepository(db).Create(ctx, &entity.FileLogs{
+			ActorID: "system", ActorType: "system", FileID: "file-1", Action: "migrate", ResourceType: "file", ResourceID: "file-1",
+		}))
+
+		var out bytes.Buffer
+		require.NoError(t, auditService.ExportLogs(ctx, model.LogFilter{Action: "migrate"}, "jsonl", &out))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"action":"migrate"`)
+	})
+
+	t.Run("unknown formats and filters are rejected before anything is written", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.ErrorIs(t, auditService.ExportLogs(ctx, model.LogFilter{}, "xml", &out), model.ErrInvalidExportFormat)
+		assert.ErrorIs(t, auditService.ExportLogs(ctx, model.LogFilter{Action: "steal"}, "csv", &out), model.ErrInvalidFilter)
+		assert.Zero(t, out.Len())
+	})
+}
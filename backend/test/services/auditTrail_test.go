@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// stubOAuth2 answers the client calls of app management; calls it does not override panic on the nil interface
+type stubOAuth2 struct {
+	services.OAuth2Interface
+	created int
+}
+
+func (s *stubOAuth2) GetClient(ctx context.Context, clientId string) (*model.OAuth2ClientResponse, error) {
+	return &model.OAuth2ClientResponse{ClientId: clientId}, nil
+}
+
+func (s *stubOAuth2) DeleteClient(ctx context.Context, clientId string) error {
+	return nil
+}
+
+func (s *stubOAuth2) CreateClient(ctx context.Context, input *model.ApplicationRequest) (*model.OAuth2ClientResponse, error) {
+	s.created++
+	return &model.OAuth2ClientResponse{ClientId: "rotated-client", ClientSecret: "rotated-secret", ClientName: input.ClientName}, nil
+}
+
+func TestAuditTrail_ManagementActions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Apps{}, &entity.Admins{}, &entity.Files{}, &entity.FileLogs{}, &entity.AuditCheckpoints{}))
+
+	logRepo := repository.NewFileLogRepository(db)
+	appRepo := repository.NewAppsRepository(db)
+	require.NoError(t, appRepo.Create(context.Background(), &entity.Apps{
+		ID: "app-1", Name: "billing", ClientID: "billing-client", ClientSecret: "secret", IsActive: true,
+		Uri: "https://billing.example.com", RedirectUri: "https://billing.example.com/callback",
+	}))
+
+	appService := services.NewApplicationService(services.ApplicationServiceParams{
+		OAuth2Service: &stubOAuth2{}, ApplicationRepository: appRepo, FileLogsRepository: logRepo,
+	})
+	adminService := services.NewAdminService(nil, repository.NewAdminRepository(db), logRepo, nil)
+
+	ctx := helper.WithRequestInfo(context.Background(), &helper.RequestInfo{
+		IP:        "203.0.113.7",
+		UserAgent: "admin-console/1.0",
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		ActorID:   "admin-client",
+		ActorType: "admin",
+	})
+
+	_, err = appService.RotateSecret(ctx, "app-1")
+	require.NoError(t, err)
+	_, err = appService.RotateSecret(ctx, "missing-app")
+	require.Error(t, err)
+	_, err = adminService.Login(ctx, "mallory", "guess")
+	require.ErrorIs(t, err, model.AdminErrWrongCredentials)
+
+	var logs []entity.FileLogs
+	require.NoError(t, db.Order("id").Find(&logs).Error)
+	require.Len(t, logs, 3)
+
+	t.Run("actions carry the actor, resource, outcome and trace of the request", func(t *testing.T) {
+		rotated := logs[0]
+		assert.Equal(t, "rotate-secret", rotated.Action)
+		assert.Equal(t, "success", rotated.Outcome)
+		assert.Equal(t, "app", rotated.ResourceType)
+		assert.Equal(t, "app-1", rotated.ResourceID)
+		assert.Equal(t, "admin-client", rotated.ActorID)
+		assert.Equal(t, "admin", rotated.ActorType)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rotated.TraceID)
+		assert.Equal(t, "203.0.113.7", rotated.IP)
+		assert.Empty(t, rotated.FileID)
+
+		assert.Equal(t, "failure", logs[1].Outcome)
+		assert.Equal(t, "missing-app", logs[1].ResourceID)
+		assert.NotEmpty(t, logs[1].Metadata["error"])
+	})
+
+	t.Run("failed logins are recorded against the username that was tried", func(t *testing.T) {
+		login := logs[2]
+		assert.Equal(t, "login", login.Action)
+		assert.Equal(t, "failure", login.Outcome)
+		assert.Equal(t, "mallory", login.ActorID)
+		assert.Equal(t, "admin", login.ResourceType)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", login.TraceID)
+	})
+
+	t.Run("the listing filters on resource and outcome", func(t *testing.T) {
+		count, _, _, err := logRepo.List(ctx, model.LogFilter{AppID: "app-1"}, 0, 10, "timestamp", "asc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count, "entries about an app are listed with the app")
+
+		count, result, _, err := logRepo.List(ctx, model.LogFilter{Outcome: "failure", ResourceType: "admin"}, 0, 10, "timestamp", "asc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		assert.Equal(t, "login", (*result)[0].Action)
+	})
+
+	t.Run("management entries are part of the verifiable chain", func(t *testing.T) {
+		auditService := services.NewAuditService(services.AuditServiceParams{FileLogsRepository: logRepo})
+		result, err := auditService.VerifyChain(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, int64(3), result.EntriesChecked)
+	})
+}
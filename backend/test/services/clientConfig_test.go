@@ -0,0 +1,129 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubBucketStorage keeps objects in memory by bucket and name; calls it does not override panic on the nil interface
+type stubBucketStorage struct {
+	services.StorageInterface
+	objects map[string][]byte
+	buckets []string
+}
+
+func (s *stubBucketStorage) CreateBucket(ctx context.Context, bucketName string) error {
+	s.buckets = append(s.buckets, bucketName)
+	return nil
+}
+
+func (s *stubBucketStorage) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	s.objects[bucketName+"/"+fileName] = content
+	return &model.StorageTransactionResponse{Location: bucketName + "/" + fileName, Size: int64(len(content)), VersionID: "v1"}, nil
+}
+
+func (s *stubBucketStorage) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
+	content, ok := s.objects[bucketName+"/"+fileName]
+	if !ok {
+		return false, nil, nil
+	}
+	return true, &model.StorageTransactionResponse{Size: int64(len(content))}, nil
+}
+
+func (s *stubBucketStorage) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	content, ok := s.objects[bucketName+"/"+fileName]
+	if !ok {
+		return nil, model.ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// stubExportingKMS hands out one fixed AES-256 key under the name it is asked to generate
+type stubExportingKMS struct {
+	services.KMSInterface
+}
+
+func (s *stubExportingKMS) GenerateSymetricKey(ctx context.Context, name string) (string, error) {
+	return "key-" + name, nil
+}
+
+func (s *stubExportingKMS) ExportKey(ctx context.Context, keyUID string) (string, error) {
+	return strings.Repeat("ab", 32), nil
+}
+
+func TestClientConfig(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("settings apply to new files while stored files keep their own", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubBucketStorage{objects: map[string][]byte{}}
+		params.StorageService, params.KMSService = storage, &stubExportingKMS{}
+		params.CryptoService = services.NewCryptographicService()
+		params.UploadJobRepository = repository.NewUploadJobRepository(db)
+		params.HashMethod, params.EncryptionMethod = services.HashSHA256, services.EncryptionAES256GCM
+		fileService := services.NewFileService(params)
+		appService := services.NewApplicationService(services.ApplicationServiceParams{
+			ApplicationRepository: params.ApplicationRepository, StorageService: storage, FileLogsRepository: params.FileLogsRepository,
+		})
+
+		bucket, hashMethod := "billing-files", services.HashMD5
+		settings, err := appService.SetClientConfig(ctx, "app-1", model.ClientConfigRequest{BucketName: &bucket, HashMethod: &hashMethod})
+		require.NoError(t, err)
+		assert.Equal(t, "billing-files", settings.BucketName)
+		assert.Empty(t, settings.EncryptionMethod, "settings left out stay on the server default")
+		assert.Equal(t, []string{"billing-files"}, storage.buckets, "the bucket is provisioned when it is set")
+
+		oldID, err := fileService.UploadFile(ctx, "billing-client", "old.txt", strings.NewReader("sealed before the change"), model.UploadOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, storage.objects, "billing-files/"+oldID+".enc")
+		var stored entity.Metadata
+		require.NoError(t, db.Where("file_id = ?", oldID).First(&stored).Error)
+		assert.Equal(t, services.HashMD5, stored.HashAlgo)
+		assert.Equal(t, services.EncryptionAES256GCM, stored.KeyAlgo)
+
+		bucket, hashMethod = "", services.HashSHA256
+		_, err = appService.SetClientConfig(ctx, "app-1", model.ClientConfigRequest{BucketName: &bucket, HashMethod: &hashMethod})
+		require.NoError(t, err)
+
+		newID, err := fileService.UploadFile(ctx, "billing-client", "new.txt", strings.NewReader("sealed after the change"), model.UploadOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, storage.objects, "files/"+newID+".enc", "an empty bucket returns to the server default")
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", oldID, model.DownloadOptions{})
+		require.NoError(t, err)
+		content, err := io.ReadAll(download.Content)
+		require.NoError(t, err, "the old file is read from its own bucket and checked with its own hash")
+		assert.Equal(t, "sealed before the change", string(content))
+	})
+
+	t.Run("unknown settings are refused", func(t *testing.T) {
+		_, params := setupPurge(t)
+		appService := services.NewApplicationService(services.ApplicationServiceParams{
+			ApplicationRepository: params.ApplicationRepository, FileLogsRepository: params.FileLogsRepository,
+		})
+
+		bucket := "Billing_Files"
+		_, err := appService.SetClientConfig(ctx, "app-1", model.ClientConfigRequest{BucketName: &bucket})
+		assert.ErrorIs(t, err, model.ErrInvalidBucketName)
+		hashMethod := "SHA-1"
+		_, err = appService.SetClientConfig(ctx, "app-1", model.ClientConfigRequest{HashMethod: &hashMethod})
+		assert.ErrorIs(t, err, model.ErrUnsupportedHashMethod)
+		encryptionMethod := "DES"
+		_, err = appService.SetClientConfig(ctx, "app-1", model.ClientConfigRequest{EncryptionMethod: &encryptionMethod})
+		assert.ErrorIs(t, err, model.ErrUnsupportedEncryptionMethod)
+	})
+}
@@ -1,8 +1,20 @@
 package services_test
 
 import (
+	"bytes"
 	"crypsis-backend/internal/services"
+	"io"
 	"testing"
+
+	"github.com/tink-crypto/tink-go/v2/aead"
+	"github.com/tink-crypto/tink-go/v2/insecurecleartextkeyset"
+	"github.com/tink-crypto/tink-go/v2/keyset"
+	streamingpb "github.com/tink-crypto/tink-go/v2/proto/aes_gcm_hkdf_streaming_go_proto"
+	commonpb "github.com/tink-crypto/tink-go/v2/proto/common_go_proto"
+	tinkpb "github.com/tink-crypto/tink-go/v2/proto/tink_go_proto"
+	"github.com/tink-crypto/tink-go/v2/streamingaead"
+	"github.com/tink-crypto/tink-go/v2/tink"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestGenerateKey(t *testing.T) {
@@ -153,7 +165,7 @@ func TestEncryptDecryptFile(t *testing.T) {
 	fileContent := []byte("This is the content of a test file.\nIt has multiple lines.\nAnd some data: 12345")
 
 	// Encrypt file
-	encryptedFile, err := cryptoService.EncryptFile(key, fileContent)
+	encryptedFile, err := cryptoService.EncryptFile(key, "", fileContent, nil)
 	if err != nil {
 		t.Fatalf("Failed to encrypt file: %v", err)
 	}
@@ -166,7 +178,7 @@ func TestEncryptDecryptFile(t *testing.T) {
 	t.Logf("Encrypted file size: %d bytes", len(encryptedFile))
 
 	// Decrypt file
-	decryptedFile, err := cryptoService.DecryptFile(key, encryptedFile)
+	decryptedFile, err := cryptoService.DecryptFile(key, encryptedFile, nil)
 	if err != nil {
 		t.Fatalf("Failed to decrypt file: %v", err)
 	}
@@ -303,13 +315,13 @@ func TestLargeDataEncryption(t *testing.T) {
 	}
 
 	// Encrypt
-	encrypted, err := cryptoService.EncryptFile(key, largeData)
+	encrypted, err := cryptoService.EncryptFile(key, "", largeData, nil)
 	if err != nil {
 		t.Fatalf("Failed to encrypt large file: %v", err)
 	}
 
 	// Decrypt
-	decrypted, err := cryptoService.DecryptFile(key, encrypted)
+	decrypted, err := cryptoService.DecryptFile(key, encrypted, nil)
 	if err != nil {
 		t.Fatalf("Failed to decrypt large file: %v", err)
 	}
@@ -328,6 +340,505 @@ func TestLargeDataEncryption(t *testing.T) {
 	t.Logf("Large file encryption/decryption successful (1MB)")
 }
 
+func TestEncryptDecryptFileStream(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	key, err := cryptoService.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// Span several ciphertext segments
+	data := make([]byte, 3*services.StreamSegmentSize+12345)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := cryptoService.NewEncryptingWriter(key, "", &encrypted, nil)
+	if err != nil {
+		t.Fatalf("Failed to create encrypting writer: %v", err)
+	}
+	if _, err := io.Copy(writer, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to stream-encrypt: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close encrypting writer: %v", err)
+	}
+
+	reader, err := cryptoService.NewDecryptingReader(key, bytes.NewReader(encrypted.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("Failed to create decrypting reader: %v", err)
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to stream-decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatal("Decrypted stream does not match original")
+	}
+
+	// Tampering with any segment must be detected
+	tampered := bytes.Clone(encrypted.Bytes())
+	tampered[len(tampered)/2] ^= 0xFF
+	reader, err = cryptoService.NewDecryptingReader(key, bytes.NewReader(tampered), nil)
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting tampered stream")
+	}
+
+	// Truncation must be detected
+	reader, err = cryptoService.NewDecryptingReader(key, bytes.NewReader(encrypted.Bytes()[:encrypted.Len()-100]), nil)
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting truncated stream")
+	}
+
+	// A ciphertext is bound to the associated data it was sealed with
+	reader, err = cryptoService.NewDecryptingReader(key, bytes.NewReader(encrypted.Bytes()), []byte("other context"))
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting with different associated data")
+	}
+
+	t.Logf("Streaming encryption/decryption successful (%d bytes)", len(data))
+}
+
+func TestStreamWithImportedRawKey(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	rawKey := make([]byte, 32)
+	for i := range rawKey {
+		rawKey[i] = byte(i)
+	}
+	key, err := cryptoService.ImportRawKeyAsBase64(rawKey)
+	if err != nil {
+		t.Fatalf("Failed to import raw key: %v", err)
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := cryptoService.NewEncryptingWriter(key, "", &encrypted, nil)
+	if err != nil {
+		t.Fatalf("Failed to create encrypting writer: %v", err)
+	}
+	_, _ = writer.Write([]byte("kms managed content"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close encrypting writer: %v", err)
+	}
+
+	reader, err := cryptoService.NewDecryptingReader(key, &encrypted, nil)
+	if err != nil {
+		t.Fatalf("Failed to create decrypting reader: %v", err)
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to stream-decrypt: %v", err)
+	}
+	if string(decrypted) != "kms managed content" {
+		t.Fatal("Decrypted stream does not match original")
+	}
+}
+
+func TestCipherHeaderBinding(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	rawKey := make([]byte, 32)
+	for i := range rawKey {
+		rawKey[i] = byte(i)
+	}
+	key, err := cryptoService.ImportRawKeyAsBase64(rawKey)
+	if err != nil {
+		t.Fatalf("Failed to import raw key: %v", err)
+	}
+	plaintext := []byte("bound to its file and app")
+	associatedData := []byte("file/file-a/app/app-a")
+
+	encrypted, err := cryptoService.EncryptFile(key, "kms-key-uid", plaintext, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+
+	header, size, err := services.ParseCipherHeader(encrypted)
+	if err != nil {
+		t.Fatalf("Failed to parse ciphertext header: %v", err)
+	}
+	if header.Version != services.CipherHeaderVersion || header.Algorithm != services.CipherAlgorithmAEAD || header.KeyRef != "kms-key-uid" {
+		t.Fatalf("Unexpected ciphertext header: %+v", header)
+	}
+
+	decrypted, err := cryptoService.DecryptFile(key, encrypted, associatedData)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Failed to decrypt bound ciphertext: %v", err)
+	}
+
+	// A blob transplanted onto another file or app fails authentication
+	if _, err := cryptoService.DecryptFile(key, encrypted, []byte("file/file-b/app/app-a")); err == nil {
+		t.Fatal("Expected error when decrypting with another file's associated data")
+	}
+
+	// The header is authenticated, so editing or stripping it is detected
+	tampered := bytes.Clone(encrypted)
+	tampered[size-1] ^= 0xFF
+	if _, err := cryptoService.DecryptFile(key, tampered, associatedData); err == nil {
+		t.Fatal("Expected error when decrypting with an altered header")
+	}
+	if _, err := cryptoService.DecryptFile(key, encrypted[size:], associatedData); err == nil {
+		t.Fatal("Expected error when decrypting with the header stripped")
+	}
+
+	// Headerless ciphertexts sealed before the header was introduced still decrypt
+	handle, err := cryptoService.KeysetFromRawAES256GCM(rawKey)
+	if err != nil {
+		t.Fatalf("Failed to build keyset: %v", err)
+	}
+	primitive, err := aead.New(handle)
+	if err != nil {
+		t.Fatalf("Failed to create AEAD primitive: %v", err)
+	}
+	legacy, err := primitive.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt legacy ciphertext: %v", err)
+	}
+	decrypted, err = cryptoService.DecryptFile(key, legacy, nil)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Failed to decrypt legacy ciphertext: %v", err)
+	}
+
+	// Streams carry the same header and binding
+	var stream bytes.Buffer
+	writer, err := cryptoService.NewEncryptingWriter(key, "kms-key-uid", &stream, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to create encrypting writer: %v", err)
+	}
+	_, _ = writer.Write(plaintext)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close encrypting writer: %v", err)
+	}
+	header, _, err = services.ParseCipherHeader(stream.Bytes())
+	if err != nil || header.Algorithm != services.CipherAlgorithmStreamDerived || header.ChunkSize != services.StreamSegmentSize {
+		t.Fatalf("Unexpected stream header: %+v, %v", header, err)
+	}
+
+	reader, err := cryptoService.NewDecryptingReader(key, bytes.NewReader(stream.Bytes()), []byte("file/file-a/app/app-b"))
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting a stream with another app's associated data")
+	}
+}
+
+// rawStreamingPrimitive builds the streaming primitive older streams were sealed with: the AES-GCM key material
+// used as the AES-GCM-HKDF input key as is
+func rawStreamingPrimitive(t *testing.T, rawKey []byte) tink.StreamingAEAD {
+	t.Helper()
+	serializedKey, err := proto.Marshal(&streamingpb.AesGcmHkdfStreamingKey{
+		KeyValue: rawKey,
+		Params: &streamingpb.AesGcmHkdfStreamingParams{
+			CiphertextSegmentSize: services.StreamSegmentSize,
+			DerivedKeySize:        32,
+			HkdfHashType:          commonpb.HashType_SHA256,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal streaming key: %v", err)
+	}
+	serializedKeyset, err := proto.Marshal(&tinkpb.Keyset{
+		PrimaryKeyId: 1,
+		Key: []*tinkpb.Keyset_Key{{
+			KeyData: &tinkpb.KeyData{
+				TypeUrl:         "type.googleapis.com/google.crypto.tink.AesGcmHkdfStreamingKey",
+				Value:           serializedKey,
+				KeyMaterialType: tinkpb.KeyData_SYMMETRIC,
+			},
+			Status:           tinkpb.KeyStatusType_ENABLED,
+			KeyId:            1,
+			OutputPrefixType: tinkpb.OutputPrefixType_RAW,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal keyset: %v", err)
+	}
+	handle, err := insecurecleartextkeyset.Read(keyset.NewBinaryReader(bytes.NewReader(serializedKeyset)))
+	if err != nil {
+		t.Fatalf("Failed to read keyset: %v", err)
+	}
+	primitive, err := streamingaead.New(handle)
+	if err != nil {
+		t.Fatalf("Failed to create streaming primitive: %v", err)
+	}
+	return primitive
+}
+
+func TestStreamKeyDerivation(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	rawKey := make([]byte, 32)
+	for i := range rawKey {
+		rawKey[i] = byte(i)
+	}
+	key, err := cryptoService.ImportRawKeyAsBase64(rawKey)
+	if err != nil {
+		t.Fatalf("Failed to import raw key: %v", err)
+	}
+	legacyPrimitive := rawStreamingPrimitive(t, rawKey)
+	plaintext := []byte("sealed before streaming keys were derived")
+	associatedData := []byte("file/file-a/app/app-a")
+
+	seal := func(header []byte) []byte {
+		var sealed bytes.Buffer
+		sealed.Write(header)
+		writer, err := legacyPrimitive.NewEncryptingWriter(&sealed, append(bytes.Clone(header), associatedData...))
+		if err != nil {
+			t.Fatalf("Failed to create legacy encrypting writer: %v", err)
+		}
+		_, _ = writer.Write(plaintext)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Failed to close legacy encrypting writer: %v", err)
+		}
+		return sealed.Bytes()
+	}
+	legacyHeader, err := services.CipherHeader{
+		Version:   services.CipherHeaderVersion,
+		Algorithm: services.CipherAlgorithmStream,
+		ChunkSize: services.StreamSegmentSize,
+	}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal ciphertext header: %v", err)
+	}
+
+	// Streams sealed with the key material as is, with or without a ciphertext header, still decrypt
+	for _, legacy := range [][]byte{seal(legacyHeader), seal(nil)} {
+		reader, err := cryptoService.NewDecryptingReader(key, bytes.NewReader(legacy), associatedData)
+		if err != nil {
+			t.Fatalf("Failed to create decrypting reader: %v", err)
+		}
+		decrypted, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("Failed to decrypt legacy stream: %v", err)
+		}
+
+		fetch := func(off, n int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(legacy[off : off+n])), nil
+		}
+		ranged, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(legacy)), 7, 10, fetch)
+		if err != nil {
+			t.Fatalf("Failed to create range decrypting reader: %v", err)
+		}
+		decrypted, err = io.ReadAll(ranged)
+		if err != nil || !bytes.Equal(decrypted, plaintext[7:17]) {
+			t.Fatalf("Failed to decrypt a range of a legacy stream: %v", err)
+		}
+	}
+
+	// New streams are keyed with a derived streaming key, so the key material as is no longer opens them
+	var stream bytes.Buffer
+	writer, err := cryptoService.NewEncryptingWriter(key, "", &stream, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to create encrypting writer: %v", err)
+	}
+	_, _ = writer.Write(plaintext)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close encrypting writer: %v", err)
+	}
+	header, size, err := services.ParseCipherHeader(stream.Bytes())
+	if err != nil || header.Algorithm != services.CipherAlgorithmStreamDerived {
+		t.Fatalf("Unexpected stream header: %+v, %v", header, err)
+	}
+	reader, err := legacyPrimitive.NewDecryptingReader(bytes.NewReader(stream.Bytes()[size:]), append(bytes.Clone(stream.Bytes()[:size]), associatedData...))
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected the AES-GCM key material not to open a stream sealed with the derived key")
+	}
+}
+
+func TestRangeDecryptingReader(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	key, err := cryptoService.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	associatedData := []byte("file-id")
+
+	// Segment payloads: the first segment also carries the 40-byte header, every segment a 16-byte tag
+	firstSegment := int64(services.StreamSegmentSize - 40 - 16)
+	segment := int64(services.StreamSegmentSize - 16)
+
+	for _, size := range []int64{1, 1000, firstSegment, firstSegment + 2*segment, 3*services.StreamSegmentSize + 12345} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+
+		var encrypted bytes.Buffer
+		writer, err := cryptoService.NewEncryptingWriter(key, "", &encrypted, associatedData)
+		if err != nil {
+			t.Fatalf("Failed to create encrypting writer: %v", err)
+		}
+		if _, err := io.Copy(writer, bytes.NewReader(data)); err != nil {
+			t.Fatalf("Failed to stream-encrypt: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Failed to close encrypting writer: %v", err)
+		}
+		ciphertext := encrypted.Bytes()
+
+		var fetched int64
+		fetch := func(off, n int64) (io.ReadCloser, error) {
+			fetched += n
+			return io.NopCloser(bytes.NewReader(ciphertext[off : off+n])), nil
+		}
+
+		ranges := [][2]int64{{0, size}, {0, 1}, {size - 1, 1}, {size / 2, size / 4}}
+		if size > firstSegment+10 {
+			// Straddle the boundary between the first and second segment
+			ranges = append(ranges, [2]int64{firstSegment - 10, 20}, [2]int64{firstSegment, 1})
+		}
+		for _, r := range ranges {
+			fetched = 0
+			reader, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(ciphertext)), r[0], r[1], fetch)
+			if err != nil {
+				t.Fatalf("Failed to create range decrypting reader for %d+%d of %d: %v", r[0], r[1], size, err)
+			}
+			decrypted, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("Failed to decrypt range %d+%d of %d: %v", r[0], r[1], size, err)
+			}
+			if !bytes.Equal(decrypted, data[r[0]:r[0]+r[1]]) {
+				t.Fatalf("Decrypted range %d+%d of %d does not match original", r[0], r[1], size)
+			}
+			// The headers are read once up front: at most 266 bytes of ciphertext header plus Tink's 40
+			if r[1] == 1 && fetched > 266+40+services.StreamSegmentSize {
+				t.Fatalf("Expected a single segment to be fetched for a one-byte range, fetched %d bytes", fetched)
+			}
+		}
+	}
+
+	data := make([]byte, 2*services.StreamSegmentSize)
+	var encrypted bytes.Buffer
+	writer, _ := cryptoService.NewEncryptingWriter(key, "", &encrypted, associatedData)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+
+	// Tampering inside a covering segment must be detected
+	tampered := bytes.Clone(encrypted.Bytes())
+	tampered[services.StreamSegmentSize+100] ^= 0xFF
+	fetch := func(off, n int64) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(tampered[off : off+n])), nil
+	}
+	reader, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(tampered)), segment, 10, fetch)
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting a tampered segment")
+	}
+
+	// Ranges are bound to the associated data of the stream
+	fetch = func(off, n int64) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(encrypted.Bytes()[off : off+n])), nil
+	}
+	reader, err = cryptoService.NewRangeDecryptingReader(key, []byte("other context"), int64(encrypted.Len()), 0, 10, fetch)
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting a range with different associated data")
+	}
+
+	// A range beyond the plaintext is rejected
+	if _, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(encrypted.Len()), int64(len(data)), 1, fetch); err == nil {
+		t.Fatal("Expected error for a range outside the plaintext")
+	}
+}
+
+func TestRangeDecryptingReaderFullLastSegment(t *testing.T) {
+	cryptoService := services.NewCryptographicService()
+
+	key, err := cryptoService.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	associatedData := []byte("file-id")
+
+	// A plaintext exactly filling three segments: the first also carries the 40-byte header, every segment a 16-byte tag
+	firstSegment := int64(services.StreamSegmentSize - 40 - 16)
+	segment := int64(services.StreamSegmentSize - 16)
+	data := make([]byte, firstSegment+2*segment)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := cryptoService.NewEncryptingWriter(key, "", &encrypted, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to create encrypting writer: %v", err)
+	}
+	if _, err := io.Copy(writer, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to stream-encrypt: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close encrypting writer: %v", err)
+	}
+	ciphertext := encrypted.Bytes()
+
+	// Tink ends the stream on the full last segment rather than an empty extra one
+	_, headerSize, err := services.ParseCipherHeader(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to parse ciphertext header: %v", err)
+	}
+	if got := len(ciphertext) - headerSize; got != 3*services.StreamSegmentSize {
+		t.Fatalf("Expected a stream of exactly three segments, got %d bytes", got)
+	}
+
+	fetchFrom := func(ciphertext []byte) func(off, n int64) (io.ReadCloser, error) {
+		return func(off, n int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(ciphertext[off : off+n])), nil
+		}
+	}
+
+	lastStart := firstSegment + segment
+	for _, r := range [][2]int64{{lastStart - 10, 20}, {lastStart - 1, segment + 1}, {lastStart, segment}, {int64(len(data)) - 1, 1}} {
+		reader, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(ciphertext)), r[0], r[1], fetchFrom(ciphertext))
+		if err != nil {
+			t.Fatalf("Failed to create range decrypting reader for %d+%d: %v", r[0], r[1], err)
+		}
+		decrypted, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to decrypt range %d+%d: %v", r[0], r[1], err)
+		}
+		if !bytes.Equal(decrypted, data[r[0]:r[0]+r[1]]) {
+			t.Fatalf("Decrypted range %d+%d does not match original", r[0], r[1])
+		}
+	}
+
+	// Dropping the last segment leaves a stream of valid length whose new last segment was not sealed as last
+	truncated := ciphertext[:len(ciphertext)-services.StreamSegmentSize]
+	reader, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(truncated)), lastStart-10, 10, fetchFrom(truncated))
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		t.Fatal("Expected error when decrypting a stream missing its last segment")
+	}
+
+	// A stream whose last segment is too short to hold a tag is rejected
+	if _, err := cryptoService.NewRangeDecryptingReader(key, associatedData, int64(len(ciphertext)+8), 0, 1, fetchFrom(append(bytes.Clone(ciphertext), make([]byte, 8)...))); err == nil {
+		t.Fatal("Expected error for a stream whose last segment cannot hold a tag")
+	}
+}
+
 func BenchmarkGenerateKey(b *testing.B) {
 	cryptoService := services.NewCryptographicService()
 	b.ResetTimer()
@@ -0,0 +1,90 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileService_CustomerKey(t *testing.T) {
+	ctx := context.Background()
+	read := objectReader(t)
+	customerKey := bytes.Repeat([]byte{0x5a}, 32)
+	otherKey := bytes.Repeat([]byte{0xa5}, 32)
+
+	t.Run("a file sealed with a customer key only opens with that key", func(t *testing.T) {
+		db, params, _ := setupMemoryFiles(t)
+		fileService := services.NewFileService(params)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "secret.txt", strings.NewReader("for your eyes only"), model.UploadOptions{CustomerKey: customerKey})
+		require.NoError(t, err)
+
+		var metadata entity.Metadata
+		require.NoError(t, db.First(&metadata, "file_id = ?", fileID).Error)
+		assert.NotEmpty(t, metadata.KeyFingerprint)
+		assert.Empty(t, metadata.EncKey, "the customer key is never stored")
+
+		_, err = fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+		assert.ErrorIs(t, err, model.ErrCustomerKeyRequired)
+		_, err = fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{CustomerKey: otherKey})
+		assert.ErrorIs(t, err, model.ErrCustomerKeyMismatch)
+		_, err = fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{CustomerKey: customerKey[:16]})
+		assert.ErrorIs(t, err, model.ErrInvalidCustomerKey)
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{CustomerKey: customerKey})
+		require.NoError(t, err)
+		assert.Equal(t, "for your eyes only", read(download.Content, nil))
+	})
+
+	t.Run("an update needs the key the file was sealed with", func(t *testing.T) {
+		_, params, _ := setupMemoryFiles(t)
+		fileService := services.NewFileService(params)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "secret.txt", strings.NewReader("first draft"), model.UploadOptions{CustomerKey: customerKey})
+		require.NoError(t, err)
+
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "secret.txt", strings.NewReader("unkeyed"), model.UploadOptions{})
+		assert.ErrorIs(t, err, model.ErrCustomerKeyRequired)
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "secret.txt", strings.NewReader("rekeyed"), model.UploadOptions{CustomerKey: otherKey})
+		assert.ErrorIs(t, err, model.ErrCustomerKeyMismatch)
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "secret.txt", strings.NewReader("second draft"), model.UploadOptions{CustomerKey: customerKey})
+		require.NoError(t, err)
+
+		first, err := fileService.DownloadFileVersion(ctx, "billing-client", fileID, 1, customerKey)
+		require.NoError(t, err)
+		assert.Equal(t, "first draft", read(first.Content, nil))
+		_, err = fileService.DownloadFileVersion(ctx, "billing-client", fileID, 1, nil)
+		assert.ErrorIs(t, err, model.ErrCustomerKeyRequired, "older versions are sealed with the customer key too")
+	})
+
+	t.Run("a customer key sent for a server-keyed file is refused", func(t *testing.T) {
+		_, params, _ := setupMemoryFiles(t)
+		fileService := services.NewFileService(params)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "plain.txt", strings.NewReader("server keyed"), model.UploadOptions{})
+		require.NoError(t, err)
+
+		_, err = fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{CustomerKey: customerKey})
+		assert.ErrorIs(t, err, model.ErrCustomerKeyMismatch)
+		download, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "server keyed", read(download.Content, nil))
+	})
+
+	t.Run("a customer key of the wrong size is refused before anything is stored", func(t *testing.T) {
+		_, params, storage := setupMemoryFiles(t)
+
+		_, err := services.NewFileService(params).UploadFile(ctx, "billing-client", "short.txt", strings.NewReader("short key"), model.UploadOptions{CustomerKey: customerKey[:16]})
+		assert.ErrorIs(t, err, model.ErrInvalidCustomerKey)
+		objects, err := storage.ListFiles(ctx, "files")
+		require.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+}
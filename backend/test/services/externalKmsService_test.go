@@ -0,0 +1,105 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/helper"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMockExternalKeyManager simulates a tenant key manager that wraps keys by prefixing them with the key ID
+func newMockExternalKeyManager(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req["key_id"] == "revoked-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/wrap":
+			json.NewEncoder(w).Encode(map[string]string{"ciphertext": req["key_id"] + ":" + req["plaintext"]})
+		case "/unwrap":
+			json.NewEncoder(w).Encode(map[string]string{"plaintext": strings.TrimPrefix(req["ciphertext"], req["key_id"]+":")})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestExternalKmsServiceWrapUnwrap(t *testing.T) {
+	server := newMockExternalKeyManager(t)
+	defer server.Close()
+
+	service := services.NewExternalKmsService(&http.Client{}, server.URL+"/")
+	ctx := context.Background()
+
+	t.Run("wraps and unwraps a key", func(t *testing.T) {
+		wrapped, iv, tag, err := service.Encrypt(ctx, "tenant-key", "data-key")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if wrapped != "tenant-key:data-key" || iv != "" || tag != "" {
+			t.Errorf("Unexpected wrap result: %q %q %q", wrapped, iv, tag)
+		}
+
+		plain, err := service.Decrypt(ctx, "tenant-key", wrapped, "", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if plain != "data-key" {
+			t.Errorf("Expected 'data-key', got: %s", plain)
+		}
+	})
+
+	t.Run("refused request is reported as unavailable", func(t *testing.T) {
+		_, _, _, err := service.Encrypt(ctx, "revoked-key", "data-key")
+		if !errors.Is(err, model.ErrExternalKeyUnavailable) {
+			t.Errorf("Expected ErrExternalKeyUnavailable, got: %v", err)
+		}
+		_, err = service.Decrypt(ctx, "revoked-key", "revoked-key:data-key", "", "")
+		if !errors.Is(err, model.ErrExternalKeyUnavailable) {
+			t.Errorf("Expected ErrExternalKeyUnavailable, got: %v", err)
+		}
+	})
+
+	t.Run("unreachable key manager is reported as unavailable", func(t *testing.T) {
+		offline := services.NewExternalKmsService(&http.Client{Timeout: time.Second}, "http://127.0.0.1:1")
+		_, err := offline.Decrypt(ctx, "tenant-key", "tenant-key:data-key", "", "")
+		if !errors.Is(err, model.ErrExternalKeyUnavailable) {
+			t.Errorf("Expected ErrExternalKeyUnavailable, got: %v", err)
+		}
+	})
+
+	t.Run("missing key ID", func(t *testing.T) {
+		if _, _, _, err := service.Encrypt(ctx, "", "data-key"); err == nil {
+			t.Error("Expected error for empty key ID")
+		}
+	})
+
+	t.Run("key management operations are not supported", func(t *testing.T) {
+		if _, err := service.ExportKey(ctx, "tenant-key"); !errors.Is(err, services.ErrKMSOperationNotSupported) {
+			t.Errorf("Expected ErrKMSOperationNotSupported, got: %v", err)
+		}
+	})
+}
+
+func TestCreateMTLSClientRejectsInvalidCA(t *testing.T) {
+	_, err := helper.CreateMTLSClient("missing.crt", "missing.key", []byte("not a certificate"), time.Second)
+	if err == nil {
+		t.Error("Expected error for invalid CA certificate")
+	}
+}
@@ -0,0 +1,132 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/services"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLockingStorage records the object locks it is asked for; without object lock it refuses them like MinIO
+type stubLockingStorage struct {
+	stubVersionedStorage
+	objectLock  bool
+	legalHold   map[string]bool
+	retainUntil map[string]time.Time
+}
+
+func (s *stubLockingStorage) SetFileLegalHold(ctx context.Context, bucketName, fileName string, hold bool) error {
+	if !s.objectLock {
+		return model.ErrObjectLockUnavailable
+	}
+	s.legalHold[fileName] = hold
+	return nil
+}
+
+func (s *stubLockingStorage) SetFileRetention(ctx context.Context, bucketName, fileName string, retainUntil time.Time) error {
+	if !s.objectLock {
+		return model.ErrObjectLockUnavailable
+	}
+	s.retainUntil[fileName] = retainUntil
+	return nil
+}
+
+func TestFileService_LegalHold(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("a held file cannot be purged by the app or the lifecycle scheduler until the hold is released", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubLockingStorage{
+			stubVersionedStorage: stubVersionedStorage{versions: map[string][]string{"file-1.enc": {"v2"}}},
+			objectLock:           true,
+			legalHold:            map[string]bool{},
+			retainUntil:          map[string]time.Time{},
+		}
+		params.StorageService, params.KMSService = storage, &stubKeyManager{}
+		fileService := services.NewFileService(params)
+
+		result, err := fileService.PlaceLegalHold(ctx, "file-1", "litigation 2026-114")
+		require.NoError(t, err)
+		assert.True(t, result.LegalHold)
+		assert.True(t, result.StorageLocked)
+		assert.True(t, storage.legalHold["file-1.enc"])
+
+		_, err = fileService.PurgeFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrFileOnLegalHold)
+		_, err = fileService.PurgeExpiredFile(ctx, "app-1", "file-1", "deleted_retention")
+		assert.ErrorIs(t, err, model.ErrFileOnLegalHold)
+		assert.Empty(t, storage.deleted)
+
+		held, err := params.FileRepository.ListDeletedFilesBefore(ctx, "app-1", time.Now().Add(time.Hour), "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, held, "the lifecycle scheduler does not pick up held files")
+
+		var placed entity.FileLogs
+		require.NoError(t, db.Where("file_id = ? AND action = ?", "file-1", "legal-hold").First(&placed).Error)
+		assert.Equal(t, "litigation 2026-114", placed.Metadata["reason"])
+
+		result, err = fileService.ReleaseLegalHold(ctx, "file-1", "case closed")
+		require.NoError(t, err)
+		assert.False(t, result.LegalHold)
+		assert.False(t, storage.legalHold["file-1.enc"])
+		var released entity.FileLogs
+		require.NoError(t, db.Where("file_id = ? AND action = ?", "file-1", "legal-hold-release").First(&released).Error)
+
+		_, err = fileService.PurgeFile(ctx, "billing-client", "file-1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("a held file can still be read, old versions included", func(t *testing.T) {
+		_, params, _ := setupMemoryFiles(t)
+		fileService, read := services.NewFileService(params), objectReader(t)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "minutes.txt", strings.NewReader("first draft"), model.UploadOptions{})
+		require.NoError(t, err)
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "minutes.txt", strings.NewReader("signed copy"), model.UploadOptions{})
+		require.NoError(t, err)
+		_, err = fileService.PlaceLegalHold(ctx, fileID, "litigation 2026-114")
+		require.NoError(t, err)
+
+		first, err := fileService.DownloadFileVersion(ctx, "billing-client", fileID, 1, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "first draft", read(io.NopCloser(first.Content), nil))
+		_, err = fileService.PromoteFileVersion(ctx, "billing-client", fileID, 1)
+		assert.ErrorIs(t, err, model.ErrFileOnLegalHold, "only changes are blocked")
+	})
+
+	t.Run("retention blocks deletes and expiry until it passes and can only be extended", func(t *testing.T) {
+		db, params := setupPurge(t)
+		require.NoError(t, db.Unscoped().Model(&entity.Files{}).Where("id = ?", "file-1").Update("deleted_at", nil).Error)
+		storage := &stubLockingStorage{stubVersionedStorage: stubVersionedStorage{versions: map[string][]string{}}}
+		params.StorageService = storage
+		fileService := services.NewFileService(params)
+
+		retainUntil := time.Now().Add(30 * 24 * time.Hour)
+		result, err := fileService.SetFileRetention(ctx, "file-1", retainUntil)
+		require.NoError(t, err)
+		assert.False(t, result.StorageLocked, "a bucket without object lock leaves enforcement to the service")
+		assert.WithinDuration(t, retainUntil, *result.RetainUntil, time.Second)
+
+		_, err = fileService.SetFileRetention(ctx, "file-1", retainUntil.Add(-time.Hour))
+		assert.ErrorIs(t, err, model.ErrRetentionShortened)
+		_, err = fileService.SetFileRetention(ctx, "file-1", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, model.ErrInvalidRetention)
+
+		err = fileService.DeleteFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrFileUnderRetention)
+		assert.Empty(t, storage.removed)
+		err = fileService.ExpireFile(ctx, "app-1", "file-1", "file_expiry")
+		assert.ErrorIs(t, err, model.ErrFileUnderRetention)
+
+		metadata, err := fileService.GetFileMetadata(ctx, "billing-client", "file-1")
+		require.NoError(t, err)
+		assert.NotNil(t, metadata.RetainUntil)
+	})
+}
@@ -0,0 +1,161 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// stubVersionedStorage holds the stored versions of objects; calls it does not override panic on the nil interface
+type stubVersionedStorage struct {
+	services.StorageInterface
+	versions map[string][]string
+	deleted  []string // versions removed for good
+	removed  []string // objects hidden behind a delete marker
+}
+
+func (s *stubVersionedStorage) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
+	return s.versions[fileName], nil
+}
+
+func (s *stubVersionedStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	s.removed = append(s.removed, fileName)
+	return nil
+}
+
+func (s *stubVersionedStorage) DeleteFileVersion(ctx context.Context, bucketName, fileName, versionID string) error {
+	s.deleted = append(s.deleted, versionID)
+	var kept []string
+	for _, stored := range s.versions[fileName] {
+		if stored != versionID {
+			kept = append(kept, stored)
+		}
+	}
+	s.versions[fileName] = kept
+	return nil
+}
+
+// stubKeyManager destroys keys unless it is set to fail
+type stubKeyManager struct {
+	services.KMSInterface
+	destroyed []string
+	failWith  error
+}
+
+func (s *stubKeyManager) RevokeKey(ctx context.Context, keyUID string) (string, error) {
+	return keyUID, nil
+}
+
+func (s *stubKeyManager) DestroyKey(ctx context.Context, keyUID string) (string, error) {
+	if s.failWith != nil {
+		return "", s.failWith
+	}
+	s.destroyed = append(s.destroyed, keyUID)
+	return keyUID, nil
+}
+
+// setupPurge stores a soft-deleted file with two versions, each under its own KMS key
+func setupPurge(t *testing.T) (*gorm.DB, services.FileServiceParams) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Apps{}, &entity.Files{}, &entity.FileLogs{}, &entity.Metadata{},
+		&entity.FileVersions{}, &entity.UploadJobs{}, &entity.UploadSessions{}, &entity.UploadParts{},
+		&entity.ShareLinks{}, &entity.FileTags{}, &entity.AuditCheckpoints{}, &entity.AppUsage{}))
+
+	ctx := context.Background()
+	require.NoError(t, repository.NewAppsRepository(db).Create(ctx, &entity.Apps{
+		ID: "app-1", Name: "billing", ClientID: "billing-client", ClientSecret: "secret", IsActive: true,
+		Uri: "https://billing.example.com", RedirectUri: "https://billing.example.com/callback",
+	}))
+	require.NoError(t, db.Create(&entity.Files{ID: "file-1", Name: "invoice.pdf", AppID: "app-1", MimeType: "application/pdf", Size: 10}).Error)
+	require.NoError(t, db.Create(&entity.Metadata{ID: "meta-1", FileID: "file-1", Hash: "h2", KeyUID: "key-2", EncKey: "wrapped-2", KeyAlgo: "aes", VersionID: "v2"}).Error)
+	require.NoError(t, db.Create(&[]entity.FileVersions{
+		{ID: "ver-1", FileID: "file-1", Version: 1, VersionID: "v1", Name: "invoice.pdf", MimeType: "application/pdf", Hash: "h1", KeyUID: "key-1", EncKey: "wrapped-1", KeyAlgo: "aes"},
+		{ID: "ver-2", FileID: "file-1", Version: 2, VersionID: "v2", Name: "invoice.pdf", MimeType: "application/pdf", Hash: "h2", KeyUID: "key-2", EncKey: "wrapped-2", KeyAlgo: "aes"},
+	}).Error)
+	require.NoError(t, db.Delete(&entity.Files{ID: "file-1"}).Error)
+
+	return db, services.FileServiceParams{
+		FileRepository:        repository.NewFileRepository(db),
+		FileLogsRepository:    repository.NewFileLogRepository(db),
+		ApplicationRepository: repository.NewAppsRepository(db),
+		FileVersionRepository: repository.NewFileVersionRepository(db),
+		KeyConfig:             &model.KeyConfig{KEK: "dGVzdC1rZWs=", KMSEnable: true},
+		BucketName:            "files",
+	}
+}
+
+func TestFileService_PurgeFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("shreds keys, removes every version and returns a receipt the audit trail confirms", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubVersionedStorage{versions: map[string][]string{"file-1.enc": {"v2", "v0"}}}
+		kms := &stubKeyManager{}
+		params.StorageService, params.KMSService = storage, kms
+
+		receipt, err := services.NewFileService(params).PurgeFile(ctx, "billing-client", "file-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key-1", "key-2"}, receipt.KeysDestroyed)
+		assert.Equal(t, int64(3), receipt.WrappedKeysWiped)
+		assert.Equal(t, 3, receipt.VersionsRemoved, "versions storage lists and versions the database recorded are removed")
+		assert.NotEmpty(t, receipt.Signature)
+		sort.Strings(storage.deleted)
+		assert.Equal(t, []string{"v0", "v1", "v2"}, storage.deleted)
+		assert.Equal(t, []string{"key-1", "key-2"}, kms.destroyed)
+
+		var remaining int64
+		require.NoError(t, db.Unscoped().Model(&entity.FileVersions{}).Where("file_id = ?", "file-1").Count(&remaining).Error)
+		assert.Zero(t, remaining)
+		require.NoError(t, db.Unscoped().Model(&entity.Files{}).Where("id = ?", "file-1").Count(&remaining).Error)
+		assert.Zero(t, remaining)
+
+		auditService := services.NewAuditService(services.AuditServiceParams{
+			FileLogsRepository: params.FileLogsRepository,
+			KeyConfig:          params.KeyConfig,
+		})
+		result, err := auditService.VerifyErasureReceipt(ctx, *receipt)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, "matches", result.AuditEntry)
+
+		tampered := *receipt
+		tampered.VersionsRemoved = 1
+		result, err = auditService.VerifyErasureReceipt(ctx, tampered)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.False(t, result.SignatureVerified)
+
+		require.NoError(t, db.Model(&entity.FileLogs{}).Where("id = ?", receipt.LogID).Update("action", "download").Error)
+		result, err = auditService.VerifyErasureReceipt(ctx, *receipt)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, "mismatch", result.AuditEntry)
+	})
+
+	t.Run("a key that cannot be destroyed stops the purge before anything is removed", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &stubVersionedStorage{versions: map[string][]string{"file-1.enc": {"v2"}}}
+		kms := &stubKeyManager{failWith: errors.New("kms unreachable")}
+		params.StorageService, params.KMSService = storage, kms
+
+		_, err := services.NewFileService(params).PurgeFile(ctx, "billing-client", "file-1")
+		assert.ErrorIs(t, err, model.ErrKeyDeletionFailed)
+		assert.Empty(t, storage.deleted)
+
+		var failure entity.FileLogs
+		require.NoError(t, db.Where("file_id = ? AND outcome = ?", "file-1", "failure").First(&failure).Error)
+		assert.Equal(t, "purge", failure.Metadata["mode"])
+	})
+}
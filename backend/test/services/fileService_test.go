@@ -4,7 +4,8 @@ import (
 	"context"
 	"crypsis-backend/internal/entity"
 	"crypsis-backend/internal/model"
-	"mime/multipart"
+	"hash"
+	"io"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/tink-crypto/tink-go/v2/keyset"
@@ -38,16 +39,16 @@ func (m *MockCryptographicService) DecryptString(key, cipherText string) (string
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockCryptographicService) EncryptFile(key string, fileBytes []byte) ([]byte, error) {
-	args := m.Called(key, fileBytes)
+func (m *MockCryptographicService) EncryptFile(key, keyRef string, fileBytes, associatedData []byte) ([]byte, error) {
+	args := m.Called(key, keyRef, fileBytes, associatedData)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]byte), args.Error(1)
 }
 
-func (m *MockCryptographicService) DecryptFile(key string, encryptedFileBytes []byte) ([]byte, error) {
-	args := m.Called(key, encryptedFileBytes)
+func (m *MockCryptographicService) DecryptFile(key string, encryptedFileBytes, associatedData []byte) ([]byte, error) {
+	args := m.Called(key, encryptedFileBytes, associatedData)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -87,11 +88,43 @@ func (m *MockCryptographicService) CompareHashFile(hashMethod string, file []byt
 	return args.Bool(0)
 }
 
+func (m *MockCryptographicService) NewEncryptingWriter(key, keyRef string, dst io.Writer, associatedData []byte) (io.WriteCloser, error) {
+	args := m.Called(key, keyRef, dst, associatedData)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.WriteCloser), args.Error(1)
+}
+
+func (m *MockCryptographicService) NewDecryptingReader(key string, src io.Reader, associatedData []byte) (io.Reader, error) {
+	args := m.Called(key, src, associatedData)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.Reader), args.Error(1)
+}
+
+func (m *MockCryptographicService) NewRangeDecryptingReader(key string, associatedData []byte, ciphertextSize, offset, length int64, fetch func(off, n int64) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	args := m.Called(key, associatedData, ciphertextSize, offset, length, fetch)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockCryptographicService) NewHash(hashMethod string) (hash.Hash, error) {
+	args := m.Called(hashMethod)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(hash.Hash), args.Error(1)
+}
+
 type MockStorageService struct {
 	mock.Mock
 }
 
-func (m *MockStorageService) UploadFile(ctx context.Context, bucketName, fileName string, file multipart.File, size int64) (*model.StorageTransactionResponse, error) {
+func (m *MockStorageService) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, size int64) (*model.StorageTransactionResponse, error) {
 	args := m.Called(ctx, bucketName, fileName, file, size)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -107,6 +140,38 @@ func (m *MockStorageService) DownloadFile(ctx context.Context, bucketName, fileN
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockStorageService) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	args := m.Called(ctx, bucketName, fileName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockStorageService) DownloadFileRange(ctx context.Context, bucketName, fileName string, offset, length int64) (io.ReadCloser, error) {
+	args := m.Called(ctx, bucketName, fileName, offset, length)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockStorageService) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	args := m.Called(ctx, bucketName, fileName, versionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockStorageService) CopyFileVersion(ctx context.Context, bucketName, fileName, versionID string) (*model.StorageTransactionResponse, error) {
+	args := m.Called(ctx, bucketName, fileName, versionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StorageTransactionResponse), args.Error(1)
+}
+
 func (m *MockStorageService) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
 	args := m.Called(ctx, bucketName, fileName)
 	if args.Get(1) == nil {
@@ -120,7 +185,7 @@ func (m *MockStorageService) DeleteFile(ctx context.Context, bucketName, fileNam
 	return args.Error(0)
 }
 
-func (m *MockStorageService) UpdateFile(ctx context.Context, bucketName, fileName string, file multipart.File, size int64) (*model.StorageTransactionResponse, error) {
+func (m *MockStorageService) UpdateFile(ctx context.Context, bucketName, fileName string, file io.Reader, size int64) (*model.StorageTransactionResponse, error) {
 	args := m.Called(ctx, bucketName, fileName, file, size)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -149,6 +214,11 @@ func (m *MockStorageService) RestoreFile(ctx context.Context, bucketName, fileNa
 	return args.Error(0)
 }
 
+func (m *MockStorageService) DeleteFileVersion(ctx context.Context, bucketName, fileName, versionID string) error {
+	args := m.Called(ctx, bucketName, fileName, versionID)
+	return args.Error(0)
+}
+
 func (m *MockStorageService) ListFileVersion(ctx context.Context, bucketName, fileName string) ([]string, error) {
 	args := m.Called(ctx, bucketName, fileName)
 	if args.Get(0) == nil {
@@ -157,6 +227,29 @@ func (m *MockStorageService) ListFileVersion(ctx context.Context, bucketName, fi
 	return args.Get(0).([]string), args.Error(1)
 }
 
+func (m *MockStorageService) CreateMultipartUpload(ctx context.Context, bucketName, fileName string) (string, error) {
+	args := m.Called(ctx, bucketName, fileName)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorageService) UploadPart(ctx context.Context, bucketName, fileName, uploadID string, partNumber int, part io.Reader, partSize int64) (string, error) {
+	args := m.Called(ctx, bucketName, fileName, uploadID, partNumber, part, partSize)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorageService) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, parts []model.StoragePart) (*model.StorageTransactionResponse, error) {
+	args := m.Called(ctx, bucketName, fileName, uploadID, parts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StorageTransactionResponse), args.Error(1)
+}
+
+func (m *MockStorageService) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	args := m.Called(ctx, bucketName, fileName, uploadID)
+	return args.Error(0)
+}
+
 type MockKMSService struct {
 	mock.Mock
 }
@@ -218,20 +311,20 @@ type MockFileRepository struct {
 	mock.Mock
 }
 
-func (m *MockFileRepository) GetListFiles(ctx context.Context, appID string, offset, limit int, sortBy, order string) (int64, []entity.Files, error) {
-	args := m.Called(ctx, appID, offset, limit, sortBy, order)
+func (m *MockFileRepository) GetListFiles(ctx context.Context, appID string, filter model.FileFilter, offset, limit int, sortBy, order string) (int64, []entity.Files, string, error) {
+	args := m.Called(ctx, appID, filter, offset, limit, sortBy, order)
 	if args.Get(1) == nil {
-		return args.Get(0).(int64), nil, args.Error(2)
+		return args.Get(0).(int64), nil, args.String(2), args.Error(3)
 	}
-	return args.Get(0).(int64), args.Get(1).([]entity.Files), args.Error(2)
+	return args.Get(0).(int64), args.Get(1).([]entity.Files), args.String(2), args.Error(3)
 }
 
-func (m *MockFileRepository) GetListFilesForAdmin(ctx context.Context, offset, limit int, sortBy, order string) (int64, []entity.Files, error) {
-	args := m.Called(ctx, offset, limit, sortBy, order)
+func (m *MockFileRepository) GetListFilesForAdmin(ctx context.Context, filter model.FileFilter, offset, limit int, sortBy, order string) (int64, []entity.Files, string, error) {
+	args := m.Called(ctx, filter, offset, limit, sortBy, order)
 	if args.Get(1) == nil {
-		return args.Get(0).(int64), nil, args.Error(2)
+		return args.Get(0).(int64), nil, args.String(2), args.Error(3)
 	}
-	return args.Get(0).(int64), args.Get(1).([]entity.Files), args.Error(2)
+	return args.Get(0).(int64), args.Get(1).([]entity.Files), args.String(2), args.Error(3)
 }
 
 func (m *MockFileRepository) GetMetadataByAppIDAndFileID(ctx context.Context, appID, fileID string) (*entity.Metadata, error) {
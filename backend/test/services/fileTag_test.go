@@ -0,0 +1,79 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileService_Tags(t *testing.T) {
+	ctx := context.Background()
+	value := func(v string) *string { return &v }
+
+	_, params, _ := setupMemoryFiles(t)
+	fileService := services.NewFileService(params)
+	upload := func(name string, tags map[string]string) string {
+		fileID, err := fileService.UploadFile(ctx, "billing-client", name, strings.NewReader(name), model.UploadOptions{Tags: tags})
+		require.NoError(t, err)
+		return fileID
+	}
+	invoice := upload("invoice.txt", map[string]string{"Project": "apollo", "kind": "invoice"})
+	receipt := upload("receipt.txt", map[string]string{"project": "apollo", "kind": "receipt"})
+	contract := upload("contract.txt", map[string]string{"project": "gemini"})
+	untagged := upload("notes.txt", nil)
+
+	list := func(t *testing.T, filters ...model.TagFilter) []string {
+		t.Helper()
+		_, files, _, err := fileService.ListFiles(ctx, "billing-client", model.FileFilter{Tags: filters}, 10, 0, "name", "asc")
+		require.NoError(t, err)
+		ids := []string{}
+		for _, file := range *files {
+			ids = append(ids, file.ID)
+		}
+		return ids
+	}
+
+	t.Run("files are filtered by tag value, tag presence and several tags at once", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{invoice, receipt}, list(t, model.TagFilter{Key: "project", Value: value("apollo")}))
+		assert.ElementsMatch(t, []string{invoice, receipt, contract}, list(t, model.TagFilter{Key: "project"}))
+		assert.Equal(t, []string{receipt}, list(t,
+			model.TagFilter{Key: "PROJECT", Value: value("apollo")},
+			model.TagFilter{Key: "kind", Value: value("receipt")}), "keys match whatever their case, and every filter must match")
+		assert.Empty(t, list(t, model.TagFilter{Key: "project", Value: value("Apollo")}), "values match exactly")
+		assert.ElementsMatch(t, []string{invoice, receipt, contract, untagged}, list(t))
+	})
+
+	t.Run("an invalid tag filter is refused", func(t *testing.T) {
+		_, _, _, err := fileService.ListFiles(ctx, "billing-client", model.FileFilter{Tags: []model.TagFilter{{Key: "no spaces"}}}, 10, 0, "name", "asc")
+		assert.ErrorIs(t, err, model.ErrInvalidTag)
+	})
+
+	t.Run("replaced tags are what the file is found by", func(t *testing.T) {
+		tags, err := fileService.SetFileTags(ctx, "billing-client", contract, map[string]string{"Project": "apollo"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"project": "apollo"}, tags)
+		assert.ElementsMatch(t, []string{invoice, receipt, contract}, list(t, model.TagFilter{Key: "project", Value: value("apollo")}))
+
+		_, err = fileService.UpdateFile(ctx, "billing-client", contract, "contract.txt", strings.NewReader("signed"), model.UploadOptions{})
+		require.NoError(t, err)
+		metadata, err := fileService.GetFileMetadata(ctx, "billing-client", contract)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"project": "apollo"}, metadata.Tags, "an update without tags keeps them")
+
+		_, err = fileService.SetFileTags(ctx, "billing-client", contract, map[string]string{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{invoice, receipt}, list(t, model.TagFilter{Key: "project"}))
+	})
+
+	t.Run("invalid tags are refused on upload", func(t *testing.T) {
+		_, err := fileService.UploadFile(ctx, "billing-client", "bad.txt", strings.NewReader("bad"), model.UploadOptions{Tags: map[string]string{"bad key": "x"}})
+		assert.ErrorIs(t, err, model.ErrInvalidTag)
+		_, err = fileService.SetFileTags(ctx, "billing-client", invoice, map[string]string{"kind": "line\nbreak"})
+		assert.ErrorIs(t, err, model.ErrInvalidTag)
+	})
+}
@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupFolders returns a file service on the in-memory backend with a folder tree
+func setupFolders(t *testing.T) (services.FileInterface, services.StorageInterface) {
+	t.Helper()
+	db, params, storage := setupMemoryFiles(t)
+	require.NoError(t, db.AutoMigrate(&entity.Folders{}))
+	params.FolderRepository = repository.NewFolderRepository(db)
+	return services.NewFileService(params), storage
+}
+
+func TestFileService_Folders(t *testing.T) {
+	ctx := context.Background()
+	read := objectReader(t)
+
+	upload := func(t *testing.T, fileService services.FileInterface, folder, name, content string) string {
+		t.Helper()
+		fileID, err := fileService.UploadFile(ctx, "billing-client", name, strings.NewReader(content), model.UploadOptions{Folder: folder})
+		require.NoError(t, err)
+		return fileID
+	}
+
+	t.Run("a folder moves with everything below it", func(t *testing.T) {
+		fileService, _ := setupFolders(t)
+		fileID := upload(t, fileService, "/reports/2026", "q1.txt", "first quarter")
+		upload(t, fileService, "/reports", "summary.txt", "the year so far")
+
+		moved, err := fileService.MovePath(ctx, "billing-client", "/reports", "/archive/reports")
+		require.NoError(t, err)
+		assert.Equal(t, "/archive/reports", moved.Path)
+
+		entry, err := fileService.GetPath(ctx, "billing-client", "/archive/reports/2026/q1.txt")
+		require.NoError(t, err)
+		assert.Equal(t, constant.PathTypeFile, entry.Type)
+		assert.Equal(t, fileID, entry.ID)
+		_, err = fileService.GetPath(ctx, "billing-client", "/reports/2026/q1.txt")
+		assert.ErrorIs(t, err, model.ErrPathNotFound)
+
+		archive, err := fileService.GetPath(ctx, "billing-client", "/archive/reports")
+		require.NoError(t, err)
+		names := []string{}
+		for _, child := range archive.Children {
+			names = append(names, child.Name)
+		}
+		assert.ElementsMatch(t, []string{"2026", "summary.txt"}, names)
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "first quarter", read(download.Content, nil), "moving only changes the path")
+	})
+
+	t.Run("a folder cannot move into itself or onto a taken path", func(t *testing.T) {
+		fileService, _ := setupFolders(t)
+		upload(t, fileService, "/reports", "q1.txt", "first quarter")
+		upload(t, fileService, "/drafts", "q1.txt", "rough figures")
+
+		_, err := fileService.MovePath(ctx, "billing-client", "/reports", "/reports/old")
+		assert.ErrorIs(t, err, model.ErrInvalidMove)
+		_, err = fileService.MovePath(ctx, "billing-client", "/drafts/q1.txt", "/reports/q1.txt")
+		assert.ErrorIs(t, err, model.ErrPathConflict)
+	})
+
+	t.Run("a folder holding files is only deleted recursively", func(t *testing.T) {
+		fileService, storage := setupFolders(t)
+		nested := upload(t, fileService, "/reports/2026", "q1.txt", "first quarter")
+		top := upload(t, fileService, "/reports", "summary.txt", "the year so far")
+		kept := upload(t, fileService, "/drafts", "notes.txt", "keep me")
+
+		err := fileService.DeletePath(ctx, "billing-client", "/reports", false)
+		assert.ErrorIs(t, err, model.ErrFolderNotEmpty)
+
+		require.NoError(t, fileService.DeletePath(ctx, "billing-client", "/reports", true))
+		for _, fileID := range []string{nested, top} {
+			_, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+			assert.ErrorIs(t, err, model.ErrFileNotFound)
+			exists, _, err := storage.Exists(ctx, "files", fileID+".enc")
+			require.NoError(t, err)
+			assert.False(t, exists)
+		}
+		_, err = fileService.GetPath(ctx, "billing-client", "/reports/2026")
+		assert.ErrorIs(t, err, model.ErrPathNotFound)
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", kept, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "keep me", read(download.Content, nil), "files outside the folder are left alone")
+	})
+
+	t.Run("an empty folder is deleted without recursion", func(t *testing.T) {
+		fileService, _ := setupFolders(t)
+		_, err := fileService.CreateFolder(ctx, "billing-client", "/empty")
+		require.NoError(t, err)
+
+		require.NoError(t, fileService.DeletePath(ctx, "billing-client", "/empty", false))
+		_, err = fileService.GetPath(ctx, "billing-client", "/empty")
+		assert.ErrorIs(t, err, model.ErrPathNotFound)
+	})
+}
@@ -0,0 +1,138 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupLifecycle stores files of an app expiring files after 30 days and purging them 7 days after deletion
+func setupLifecycle(t *testing.T) (*gorm.DB, *stubVersionedStorage, services.LifecycleInterface) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Apps{}, &entity.Files{}, &entity.FileLogs{}, &entity.Metadata{},
+		&entity.FileVersions{}, &entity.UploadJobs{}, &entity.UploadSessions{}, &entity.UploadParts{},
+		&entity.ShareLinks{}, &entity.FileTags{}, &entity.AuditCheckpoints{}, &entity.AppUsage{}))
+
+	ctx := context.Background()
+	require.NoError(t, repository.NewAppsRepository(db).Create(ctx, &entity.Apps{
+		ID: "app-1", Name: "billing", ClientID: "billing-client", ClientSecret: "secret", IsActive: true,
+		Uri: "https://billing.example.com", RedirectUri: "https://billing.example.com/callback",
+		FileTTLDays: 30, DeletedRetentionDays: 7,
+	}))
+
+	now := time.Now().UTC()
+	future, past := now.Add(24*time.Hour), now.Add(-time.Hour)
+	for _, file := range []entity.Files{
+		{ID: "file-old", CreatedAt: now.AddDate(0, 0, -40)},
+		{ID: "file-pinned", CreatedAt: now.AddDate(0, 0, -40), ExpiresAt: &future},
+		{ID: "file-due", CreatedAt: now, ExpiresAt: &past},
+		{ID: "file-fresh", CreatedAt: now},
+		{ID: "file-deleted", CreatedAt: now.AddDate(0, 0, -20)},
+		{ID: "file-just-deleted", CreatedAt: now.AddDate(0, 0, -20)},
+	} {
+		file.Name, file.AppID, file.MimeType, file.Size = file.ID+".pdf", "app-1", "application/pdf", 10
+		require.NoError(t, db.Create(&file).Error)
+		require.NoError(t, db.Create(&entity.Metadata{ID: "meta-" + file.ID, FileID: file.ID, Hash: "h", EncKey: "wrapped", KeyAlgo: "aes", VersionID: "v1"}).Error)
+	}
+	require.NoError(t, db.Unscoped().Model(&entity.Files{}).Where("id = ?", "file-deleted").Update("deleted_at", now.AddDate(0, 0, -10)).Error)
+	require.NoError(t, db.Unscoped().Model(&entity.Files{}).Where("id = ?", "file-just-deleted").Update("deleted_at", now.AddDate(0, 0, -1)).Error)
+
+	logRepo := repository.NewFileLogRepository(db)
+	require.NoError(t, logRepo.Create(ctx, &entity.FileLogs{ActorID: "app-1", ActorType: "client", FileID: "file-old", Action: "upload", Timestamp: now.AddDate(0, 0, -60)}))
+
+	storage := &stubVersionedStorage{versions: map[string][]string{}}
+	keyConfig := &model.KeyConfig{KEK: "dGVzdC1rZWs="}
+	fileRepo := repository.NewFileRepository(db)
+	fileService := services.NewFileService(services.FileServiceParams{
+		StorageService:        storage,
+		FileRepository:        fileRepo,
+		FileLogsRepository:    logRepo,
+		ApplicationRepository: repository.NewAppsRepository(db),
+		FileVersionRepository: repository.NewFileVersionRepository(db),
+		KeyConfig:             keyConfig,
+		BucketName:            "files",
+	})
+	lifecycle := services.NewLifecycleService(services.LifecycleServiceParams{
+		FileService:           fileService,
+		AuditService:          services.NewAuditService(services.AuditServiceParams{FileLogsRepository: logRepo, KeyConfig: keyConfig}),
+		FileRepository:        fileRepo,
+		ApplicationRepository: repository.NewAppsRepository(db),
+		FileLogsRepository:    logRepo,
+		BatchSize:             2,
+		LogRetentionDays:      30,
+	})
+	return db, storage, lifecycle
+}
+
+func TestLifecycleService_Apply(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("a dry run reports every rule without changing anything", func(t *testing.T) {
+		db, storage, lifecycle := setupLifecycle(t)
+
+		report, err := lifecycle.Apply(ctx, true)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 2, report.FilesExpired)
+		assert.Equal(t, 1, report.FilesPurged)
+		assert.Equal(t, int64(1), report.LogsRemoved)
+		assert.Zero(t, report.Failures)
+
+		rules := map[string]string{}
+		for _, action := range report.Actions {
+			rules[action.FileID] = action.Rule
+		}
+		assert.Equal(t, map[string]string{"file-old": "app_ttl", "file-due": "file_expiry", "file-deleted": "deleted_retention"}, rules)
+
+		var live int64
+		require.NoError(t, db.Model(&entity.Files{}).Count(&live).Error)
+		assert.Equal(t, int64(4), live)
+		assert.Empty(t, storage.removed)
+	})
+
+	t.Run("applying expires and purges files and records each action as the system", func(t *testing.T) {
+		db, storage, lifecycle := setupLifecycle(t)
+
+		report, err := lifecycle.Apply(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.FilesExpired)
+		assert.Equal(t, 1, report.FilesPurged)
+		assert.Equal(t, int64(1), report.LogsRemoved)
+		assert.Zero(t, report.Failures)
+		assert.ElementsMatch(t, []string{"file-old.enc", "file-due.enc"}, storage.removed)
+
+		var remaining []entity.Files
+		require.NoError(t, db.Unscoped().Order("id").Find(&remaining).Error)
+		var ids []string
+		for _, file := range remaining {
+			ids = append(ids, file.ID)
+		}
+		assert.Equal(t, []string{"file-due", "file-fresh", "file-just-deleted", "file-old", "file-pinned"}, ids, "the purged file is gone for good")
+
+		var entries []entity.FileLogs
+		require.NoError(t, db.Where("actor_type = ?", "system").Order("id").Find(&entries).Error)
+		require.Len(t, entries, 3)
+		for _, entry := range entries {
+			assert.Equal(t, "lifecycle", entry.ActorID)
+			assert.Equal(t, "delete", entry.Action)
+			assert.Equal(t, "success", entry.Outcome)
+		}
+		assert.Equal(t, "deleted_retention", entries[0].Metadata["rule"])
+		assert.Equal(t, "purge", entries[0].Metadata["mode"])
+
+		report, err = lifecycle.Apply(ctx, false)
+		require.NoError(t, err)
+		assert.Zero(t, report.FilesExpired+report.FilesPurged, "a second pass finds nothing left to do")
+	})
+}
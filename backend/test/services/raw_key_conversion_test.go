@@ -1,11 +1,13 @@
 package services
 
 import (
+	"bytes"
 	"crypsis-backend/internal/helper"
 	"crypsis-backend/internal/services"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -140,7 +142,7 @@ func TestRawKeyEncryptionDecryption(t *testing.T) {
 		plaintext := []byte("Hello, this is a test file content!")
 
 		// Encrypt
-		ciphertext, err := service.EncryptFile(keyBase64, plaintext)
+		ciphertext, err := service.EncryptFile(keyBase64, "", plaintext, nil)
 		if err != nil {
 			t.Fatalf("EncryptFile failed: %v", err)
 		}
@@ -152,7 +154,7 @@ func TestRawKeyEncryptionDecryption(t *testing.T) {
 		t.Logf("Encrypted %d bytes to %d bytes", len(plaintext), len(ciphertext))
 
 		// Decrypt
-		decrypted, err := service.DecryptFile(keyBase64, ciphertext)
+		decrypted, err := service.DecryptFile(keyBase64, ciphertext, nil)
 		if err != nil {
 			t.Fatalf("DecryptFile failed: %v", err)
 		}
@@ -180,13 +182,13 @@ func TestRawKeyEncryptionDecryption(t *testing.T) {
 		plaintext := []byte("Secret message")
 
 		// Encrypt with key1
-		ciphertext, err := service.EncryptFile(key1Base64, plaintext)
+		ciphertext, err := service.EncryptFile(key1Base64, "", plaintext, nil)
 		if err != nil {
 			t.Fatalf("EncryptFile failed: %v", err)
 		}
 
 		// Try to decrypt with key2 (should fail)
-		_, err = service.DecryptFile(key2Base64, ciphertext)
+		_, err = service.DecryptFile(key2Base64, ciphertext, nil)
 		if err == nil {
 			t.Fatal("Expected decryption to fail with wrong key, but it succeeded")
 		}
@@ -218,12 +220,12 @@ func TestRawKeyVsTinkKeyCompatibility(t *testing.T) {
 
 		// Both keys should be able to encrypt/decrypt
 		// Test Tink key
-		ciphertext1, err := service.EncryptFile(tinkKey, plaintext)
+		ciphertext1, err := service.EncryptFile(tinkKey, "", plaintext, nil)
 		if err != nil {
 			t.Fatalf("Failed to encrypt with Tink key: %v", err)
 		}
 
-		decrypted1, err := service.DecryptFile(tinkKey, ciphertext1)
+		decrypted1, err := service.DecryptFile(tinkKey, ciphertext1, nil)
 		if err != nil {
 			t.Fatalf("Failed to decrypt with Tink key: %v", err)
 		}
@@ -233,12 +235,12 @@ func TestRawKeyVsTinkKeyCompatibility(t *testing.T) {
 		}
 
 		// Test converted raw key
-		ciphertext2, err := service.EncryptFile(rawKeyConverted, plaintext)
+		ciphertext2, err := service.EncryptFile(rawKeyConverted, "", plaintext, nil)
 		if err != nil {
 			t.Fatalf("Failed to encrypt with converted raw key: %v", err)
 		}
 
-		decrypted2, err := service.DecryptFile(rawKeyConverted, ciphertext2)
+		decrypted2, err := service.DecryptFile(rawKeyConverted, ciphertext2, nil)
 		if err != nil {
 			t.Fatalf("Failed to decrypt with converted raw key: %v", err)
 		}
@@ -303,11 +305,11 @@ func TestHexKeyFromKMS(t *testing.T) {
 	assert.NoError(t, err, "ImportRawKeyAsBase64 should not return an error")
 	assert.NotEmpty(t, rawkey, "Imported raw key should not be empty")
 
-	encrypted, err := service.EncryptFile(rawkey, []byte("Test Data"))
+	encrypted, err := service.EncryptFile(rawkey, "", []byte("Test Data"), nil)
 	assert.NoError(t, err, "EncryptFile should not return an error")
 	assert.NotEmpty(t, encrypted, "Encrypted data should not be empty")
 
-	decrypted, err := service.DecryptFile(rawkey, encrypted)
+	decrypted, err := service.DecryptFile(rawkey, encrypted, nil)
 	assert.NoError(t, err, "DecryptFile should not return an error")
 	assert.Equal(t, []byte("Test Data"), decrypted, "Decrypted data should match original")
 }
@@ -352,12 +354,12 @@ func TestKMSKeyWrappingFlow(t *testing.T) {
 
 		// Use the unwrapped DEK to encrypt file data
 		plaintext := []byte("Sensitive file data that needs encryption")
-		encrypted, err := service.EncryptFile(unwrappedDEK, plaintext)
+		encrypted, err := service.EncryptFile(unwrappedDEK, "", plaintext, nil)
 		assert.NoError(t, err, "EncryptFile should not fail")
 		assert.NotEmpty(t, encrypted, "Encrypted data should not be empty")
 
 		// Decrypt the file data
-		decrypted, err := service.DecryptFile(unwrappedDEK, encrypted)
+		decrypted, err := service.DecryptFile(unwrappedDEK, encrypted, nil)
 		assert.NoError(t, err, "DecryptFile should not fail")
 		assert.Equal(t, plaintext, decrypted, "Decrypted data should match original")
 
@@ -452,3 +454,44 @@ func TestKEKValidation(t *testing.T) {
 		t.Logf("Expected error: %v", err)
 	})
 }
+
+// Customer-supplied keys are imported again on every request, so the same raw key has to
+// produce a keyset that decrypts streams sealed with an earlier import.
+func TestImportRawKeyAsBase64_StableAcrossImports(t *testing.T) {
+	service := services.NewCryptographicService()
+
+	rawKey := make([]byte, 32)
+	_, err := rand.Read(rawKey)
+	require.NoError(t, err)
+
+	sealKey, err := service.ImportRawKeyAsBase64(rawKey)
+	require.NoError(t, err)
+	openKey, err := service.ImportRawKeyAsBase64(rawKey)
+	require.NoError(t, err)
+
+	plaintext := bytes.Repeat([]byte("customer key "), 10000)
+	var encrypted bytes.Buffer
+	writer, err := service.NewEncryptingWriter(sealKey, "", &encrypted, []byte("file/a/app/b"))
+	require.NoError(t, err)
+	_, err = writer.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := service.NewDecryptingReader(openKey, bytes.NewReader(encrypted.Bytes()), []byte("file/a/app/b"))
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	otherRawKey := make([]byte, 32)
+	_, err = rand.Read(otherRawKey)
+	require.NoError(t, err)
+	otherKey, err := service.ImportRawKeyAsBase64(otherRawKey)
+	require.NoError(t, err)
+
+	reader, err = service.NewDecryptingReader(otherKey, bytes.NewReader(encrypted.Bytes()), []byte("file/a/app/b"))
+	if err == nil {
+		_, err = io.ReadAll(reader)
+	}
+	assert.Error(t, err, "a different customer key must not decrypt the stream")
+}
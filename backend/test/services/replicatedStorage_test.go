@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var errSiteDown = errors.New("storage site unreachable")
+
+// switchableStorage is a storage backend whose site can be taken down
+type switchableStorage struct {
+	services.StorageInterface
+	down bool
+}
+
+func (s *switchableStorage) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	if s.down {
+		return nil, errSiteDown
+	}
+	return s.StorageInterface.UploadFile(ctx, bucketName, fileName, file, fileSize)
+}
+
+func (s *switchableStorage) DownloadFileStream(ctx context.Context, bucketName, fileName string) (io.ReadCloser, error) {
+	if s.down {
+		return nil, errSiteDown
+	}
+	return s.StorageInterface.DownloadFileStream(ctx, bucketName, fileName)
+}
+
+func (s *switchableStorage) DownloadFileVersionStream(ctx context.Context, bucketName, fileName, versionID string) (io.ReadCloser, error) {
+	if s.down {
+		return nil, errSiteDown
+	}
+	return s.StorageInterface.DownloadFileVersionStream(ctx, bucketName, fileName, versionID)
+}
+
+func (s *switchableStorage) Exists(ctx context.Context, bucketName, fileName string) (bool, *model.StorageTransactionResponse, error) {
+	if s.down {
+		return false, nil, errSiteDown
+	}
+	return s.StorageInterface.Exists(ctx, bucketName, fileName)
+}
+
+func (s *switchableStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	if s.down {
+		return errSiteDown
+	}
+	return s.StorageInterface.DeleteFile(ctx, bucketName, fileName)
+}
+
+// setupReplication returns replicated storage over a primary and one replica, both holding the "files" bucket
+func setupReplication(t *testing.T, policy services.ReplicationPolicy) (*gorm.DB, services.ReplicatedStorageInterface, *switchableStorage, *switchableStorage) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Apps{}, &entity.Files{}, &entity.ObjectReplicas{}))
+
+	primary := &switchableStorage{StorageInterface: services.NewMemoryStorageService()}
+	replica := &switchableStorage{StorageInterface: services.NewMemoryStorageService()}
+	storage := services.NewReplicatedStorageService(services.ReplicatedStorageServiceParams{
+		Primary:                 primary,
+		Replicas:                []services.StorageReplica{{Name: "dr-site", Storage: replica}},
+		ObjectReplicaRepository: repository.NewObjectReplicaRepository(db),
+		Policy:                  policy,
+	})
+	require.NoError(t, storage.CreateBucket(context.Background(), "files"))
+	return db, storage, primary, replica
+}
+
+// makeRepairsDue brings the next attempt of every queued replica operation forward to now
+func makeRepairsDue(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	require.NoError(t, db.Model(&entity.ObjectReplicas{}).Where("1 = 1").Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+}
+
+func TestReplicatedStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("copies every version to the replica and fails reads over to it", func(t *testing.T) {
+		_, storage, primary, replica := setupReplication(t, nil)
+		read := objectReader(t)
+
+		v1, err := storage.UploadFile(ctx, "files", "doc.enc", strings.NewReader("first"), 5)
+		require.NoError(t, err)
+		v2, err := storage.UpdateFile(ctx, "files", "doc.enc", strings.NewReader("second"), -1)
+		require.NoError(t, err)
+		assert.Equal(t, "second", read(replica.DownloadFileStream(ctx, "files", "doc.enc")))
+
+		primary.down = true
+		assert.Equal(t, "second", read(storage.DownloadFileStream(ctx, "files", "doc.enc")))
+		assert.Equal(t, "first", read(storage.DownloadFileVersionStream(ctx, "files", "doc.enc", v1.VersionID)),
+			"a version is read from the replica version it was copied to")
+		exists, current, err := storage.Exists(ctx, "files", "doc.enc")
+		require.NoError(t, err)
+		require.True(t, exists)
+		assert.Equal(t, v2.VersionID, current.VersionID, "the replica answers with the primary version ID")
+
+		primary.down = false
+		require.NoError(t, primary.DeleteFileVersion(ctx, "files", "doc.enc", v2.VersionID))
+		require.NoError(t, primary.DeleteFileVersion(ctx, "files", "doc.enc", v1.VersionID))
+		assert.Equal(t, "second", read(storage.DownloadFileStream(ctx, "files", "doc.enc")), "an object missing on the primary is read from the replica")
+
+		require.NoError(t, storage.DeleteFileVersion(ctx, "files", "doc.enc", v1.VersionID))
+		versions, err := replica.ListFileVersion(ctx, "files", "doc.enc")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1, "removing a version removes its copy")
+	})
+
+	t.Run("queues replica writes that fail and repairs them later", func(t *testing.T) {
+		db, storage, primary, replica := setupReplication(t, nil)
+		read := objectReader(t)
+
+		replica.down = true
+		_, err := storage.UploadFile(ctx, "files", "doc.enc", strings.NewReader("queued"), 6)
+		require.NoError(t, err, "the write succeeds once the primary holds it")
+
+		primary.down = true
+		_, err = storage.DownloadFileStream(ctx, "files", "doc.enc")
+		assert.ErrorIs(t, err, errSiteDown, "a replica that has not caught up does not serve reads")
+
+		primary.down, replica.down = false, false
+		report, err := storage.RepairPending(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, report.Processed, "a failed write is retried after a backoff")
+
+		makeRepairsDue(t, db)
+		report, err = storage.RepairPending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReplicaRepairReport{Processed: 1, Repaired: 1}, *report)
+		assert.Equal(t, "queued", read(replica.DownloadFileStream(ctx, "files", "doc.enc")))
+
+		replica.down = true
+		require.NoError(t, storage.DeleteFile(ctx, "files", "doc.enc"))
+		replica.down = false
+		makeRepairsDue(t, db)
+		report, err = storage.RepairPending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Repaired)
+		exists, _, err := replica.Exists(ctx, "files", "doc.enc")
+		require.NoError(t, err)
+		assert.False(t, exists, "the delete reaches the replica")
+	})
+
+	t.Run("leaves the files of apps that opt out on the primary", func(t *testing.T) {
+		optOut := false
+		var appRepository repository.ApplicationRepository
+		db, storage, _, replica := setupReplication(t, func(ctx context.Context, bucketName, objectName string) (bool, error) {
+			return services.AppReplicationPolicy(appRepository, true)(ctx, bucketName, objectName)
+		})
+		appRepository = repository.NewAppsRepository(db)
+		require.NoError(t, db.Create(&entity.Apps{ID: "app-local", Name: "local", ClientID: "local-client", ClientSecret: "secret", IsActive: true, Replicate: &optOut}).Error)
+		require.NoError(t, db.Create(&entity.Apps{ID: "app-dr", Name: "dr", ClientID: "dr-client", ClientSecret: "secret", IsActive: true}).Error)
+		require.NoError(t, db.Create(&entity.Files{ID: "file-local", Name: "a.txt", AppID: "app-local", MimeType: "text/plain"}).Error)
+		require.NoError(t, db.Create(&entity.Files{ID: "file-dr", Name: "b.txt", AppID: "app-dr", MimeType: "text/plain"}).Error)
+
+		_, err := storage.UploadFile(ctx, "files", "file-local.enc", strings.NewReader("local"), 5)
+		require.NoError(t, err)
+		_, err = storage.UploadFile(ctx, "files", "file-dr.enc", strings.NewReader("remote"), 6)
+		require.NoError(t, err)
+
+		files, err := replica.ListFiles(ctx, "files")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"file-dr.enc"}, files, "an app without a setting follows the server default")
+	})
+}
@@ -0,0 +1,171 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupShareLinks uploads a file to the in-memory backend and returns a file service able to share it
+func setupShareLinks(t *testing.T) (*gorm.DB, services.FileInterface, string) {
+	t.Helper()
+	db, params, _ := setupMemoryFiles(t)
+	params.ShareLinkRepository = repository.NewShareLinkRepository(db)
+	params.PublicBaseURL = "https://files.example.com"
+	fileService := services.NewFileService(params)
+
+	fileID, err := fileService.UploadFile(context.Background(), "billing-client", "report.txt", strings.NewReader("quarterly figures"), model.UploadOptions{})
+	require.NoError(t, err)
+	return db, fileService, fileID
+}
+
+// shareFile creates a share link and returns the token of its URL
+func shareFile(t *testing.T, fileService services.FileInterface, fileID string, request model.CreateShareLinkRequest) string {
+	t.Helper()
+	link, err := fileService.CreateShareLink(context.Background(), "billing-client", fileID, request)
+	require.NoError(t, err)
+	_, token, found := strings.Cut(link.URL, "/s/")
+	require.True(t, found)
+	return token
+}
+
+func TestFileService_ShareLinkAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refused uses are recorded as failures in the audit trail", func(t *testing.T) {
+		db, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{Passphrase: "open sesame"})
+
+		_, err := fileService.DownloadSharedFile(ctx, token, "wrong", "203.0.113.7")
+		require.ErrorIs(t, err, model.ErrShareLinkPassphrase)
+		download, err := fileService.DownloadSharedFile(ctx, token, "open sesame", "203.0.113.7")
+		require.NoError(t, err)
+		download.Content.Close()
+
+		var logs []entity.FileLogs
+		require.NoError(t, db.Where("file_id = ? AND action = ?", fileID, constant.ActionTypeDownload).Order("id").Find(&logs).Error)
+		require.Len(t, logs, 2)
+		assert.Equal(t, constant.OutcomeFailure, logs[0].Outcome)
+		assert.Equal(t, model.ErrShareLinkPassphrase.Error(), logs[0].Metadata["error"])
+		assert.Equal(t, "203.0.113.7", logs[0].Metadata["client_ip"])
+		assert.Equal(t, constant.OutcomeSuccess, logs[1].Outcome)
+		assert.Equal(t, logs[0].ActorID, logs[1].ActorID, "both uses are attributed to the link")
+	})
+
+	t.Run("wrong passphrases lock the link before the passphrase is checked again", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{Passphrase: "open sesame"})
+
+		for i := 0; i < 10; i++ {
+			_, err := fileService.DownloadSharedFile(ctx, token, "guess", "203.0.113.1")
+			require.ErrorIs(t, err, model.ErrShareLinkPassphrase)
+		}
+		_, err := fileService.DownloadSharedFile(ctx, token, "open sesame", "198.51.100.2")
+		assert.ErrorIs(t, err, model.ErrShareLinkTooManyAttempts, "the limit holds for the link whatever address guesses")
+	})
+
+	t.Run("an address making too many refused uses is turned away", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{})
+
+		for i := 0; i < 20; i++ {
+			_, err := fileService.DownloadSharedFile(ctx, "forged.token", "", "203.0.113.9")
+			require.ErrorIs(t, err, model.ErrShareLinkNotFound)
+		}
+		_, err := fileService.DownloadSharedFile(ctx, token, "", "203.0.113.9")
+		assert.ErrorIs(t, err, model.ErrShareLinkTooManyAttempts)
+
+		download, err := fileService.DownloadSharedFile(ctx, token, "", "198.51.100.2")
+		require.NoError(t, err)
+		download.Content.Close()
+	})
+}
+
+func TestFileService_DownloadSharedFile(t *testing.T) {
+	ctx := context.Background()
+	read := objectReader(t)
+
+	t.Run("a link stops working once it expires", func(t *testing.T) {
+		db, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{ExpiresIn: "1h"})
+
+		download, err := fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+		require.NoError(t, err)
+		assert.Equal(t, "quarterly figures", read(download.Content, nil))
+
+		require.NoError(t, db.Model(&entity.ShareLinks{}).Where("file_id = ?", fileID).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		_, err = fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkExpired)
+	})
+
+	t.Run("a link is used up after its maximum downloads", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{MaxDownloads: 2})
+
+		for i := 0; i < 2; i++ {
+			download, err := fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+			require.NoError(t, err)
+			assert.Equal(t, "quarterly figures", read(download.Content, nil))
+		}
+		_, err := fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkExhausted)
+
+		links, err := fileService.ListShareLinks(ctx, "billing-client", fileID)
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, 2, links[0].Downloads)
+	})
+
+	t.Run("a refused use does not count as a download", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{MaxDownloads: 1, Passphrase: "open sesame"})
+
+		_, err := fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkPassphrase, "a missing passphrase is refused")
+		_, err = fileService.DownloadSharedFile(ctx, token, "open sesame!", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkPassphrase)
+
+		download, err := fileService.DownloadSharedFile(ctx, token, "open sesame", "203.0.113.7")
+		require.NoError(t, err)
+		assert.Equal(t, "quarterly figures", read(download.Content, nil))
+	})
+
+	t.Run("a link limited to a range only serves addresses inside it", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{AllowedCIDR: "203.0.113.0/24"})
+
+		_, err := fileService.DownloadSharedFile(ctx, token, "", "198.51.100.2")
+		assert.ErrorIs(t, err, model.ErrShareLinkIPNotAllowed)
+		_, err = fileService.DownloadSharedFile(ctx, token, "", "not an address")
+		assert.ErrorIs(t, err, model.ErrShareLinkIPNotAllowed)
+
+		download, err := fileService.DownloadSharedFile(ctx, token, "", "::ffff:203.0.113.40")
+		require.NoError(t, err, "IPv4-mapped addresses match the IPv4 range")
+		assert.Equal(t, "quarterly figures", read(download.Content, nil))
+	})
+
+	t.Run("a revoked link and a tampered token are refused", func(t *testing.T) {
+		_, fileService, fileID := setupShareLinks(t)
+		token := shareFile(t, fileService, fileID, model.CreateShareLinkRequest{})
+
+		_, err := fileService.DownloadSharedFile(ctx, token+"x", "", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkNotFound)
+
+		links, err := fileService.ListShareLinks(ctx, "billing-client", fileID)
+		require.NoError(t, err)
+		require.NoError(t, fileService.RevokeShareLink(ctx, "billing-client", fileID, links[0].ID))
+		_, err = fileService.DownloadSharedFile(ctx, token, "", "203.0.113.7")
+		assert.ErrorIs(t, err, model.ErrShareLinkRevoked)
+	})
+}
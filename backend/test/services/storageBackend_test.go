@@ -0,0 +1,277 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// objectReader returns a function reading the whole of an opened object, which takes the results of the open call
+func objectReader(t *testing.T) func(object io.ReadCloser, err error) string {
+	return func(object io.ReadCloser, err error) string {
+		t.Helper()
+		require.NoError(t, err)
+		defer object.Close()
+		content, err := io.ReadAll(object)
+		require.NoError(t, err)
+		return string(content)
+	}
+}
+
+// truncatingStorage stores only the first bytes of each upload and reports success, as a backend that stops
+// reading early would
+type truncatingStorage struct {
+	services.StorageInterface
+	written []string
+}
+
+func (s *truncatingStorage) UploadFile(ctx context.Context, bucketName, fileName string, file io.Reader, fileSize int64) (*model.StorageTransactionResponse, error) {
+	s.written = append(s.written, fileName)
+	return s.StorageInterface.UploadFile(ctx, bucketName, fileName, io.LimitReader(file, 16), -1)
+}
+
+// racingJobs settles every upload job the way the worker would just before the request commits it
+type racingJobs struct {
+	repository.UploadJobRepository
+	settle func(ctx context.Context, job entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error
+}
+
+func (r *racingJobs) Complete(ctx context.Context, job *entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error {
+	if err := r.settle(ctx, *job, file, metadata); err != nil {
+		return err
+	}
+	return r.UploadJobRepository.Complete(ctx, job, file, metadata)
+}
+
+func TestStorageBackends(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) services.StorageInterface{
+		"memory": func(t *testing.T) services.StorageInterface { return services.NewMemoryStorageService() },
+		"filesystem": func(t *testing.T) services.StorageInterface {
+			storage, err := services.NewFilesystemStorageService(t.TempDir())
+			require.NoError(t, err)
+			return storage
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name+" keeps versions the way a versioned bucket does", func(t *testing.T) {
+			storage, read := newStorage(t), objectReader(t)
+			_, err := storage.UploadFile(ctx, "files", "a.enc", strings.NewReader("first"), 5)
+			assert.ErrorIs(t, err, model.ErrBucketNotFound)
+			require.NoError(t, storage.CreateBucket(ctx, "files"))
+
+			v1, err := storage.UploadFile(ctx, "files", "a.enc", strings.NewReader("first"), 5)
+			require.NoError(t, err)
+			v2, err := storage.UpdateFile(ctx, "files", "a.enc", strings.NewReader("second"), -1)
+			require.NoError(t, err)
+			_, err = storage.UploadFile(ctx, "files", "short.enc", strings.NewReader("abc"), 10)
+			assert.Error(t, err, "an upload shorter than its announced size fails")
+
+			exists, current, err := storage.Exists(ctx, "files", "a.enc")
+			require.NoError(t, err)
+			require.True(t, exists)
+			assert.Equal(t, v2.VersionID, current.VersionID)
+			assert.Equal(t, int64(6), current.Size)
+			assert.Equal(t, "second", read(storage.DownloadFileStream(ctx, "files", "a.enc")))
+			assert.Equal(t, "first", read(storage.DownloadFileVersionStream(ctx, "files", "a.enc", v1.VersionID)))
+			assert.Equal(t, "eco", read(storage.DownloadFileRange(ctx, "files", "a.enc", 1, 3)))
+			_, err = storage.DownloadFileRange(ctx, "files", "a.enc", 6, 1)
+			assert.ErrorIs(t, err, model.ErrRangeNotSatisfiable)
+
+			versions, err := storage.ListFileVersion(ctx, "files", "a.enc")
+			require.NoError(t, err)
+			assert.Equal(t, []string{v2.VersionID, v1.VersionID}, versions, "versions are listed newest first")
+
+			v3, err := storage.CopyFileVersion(ctx, "files", "a.enc", v1.VersionID)
+			require.NoError(t, err)
+			assert.Equal(t, "first", read(storage.DownloadFileStream(ctx, "files", "a.enc")))
+
+			require.NoError(t, storage.DeleteFile(ctx, "files", "a.enc"))
+			exists, _, err = storage.Exists(ctx, "files", "a.enc")
+			require.NoError(t, err)
+			assert.False(t, exists, "a delete marker hides the file")
+			files, err := storage.ListFiles(ctx, "files")
+			require.NoError(t, err)
+			assert.Empty(t, files)
+			versions, err = storage.ListFileVersion(ctx, "files", "a.enc")
+			require.NoError(t, err)
+			assert.Len(t, versions, 4, "the delete marker is listed as a version")
+
+			require.NoError(t, storage.RestoreFile(ctx, "files", "a.enc", v3.VersionID))
+			assert.Equal(t, "first", read(storage.DownloadFileStream(ctx, "files", "a.enc")))
+			versions, err = storage.ListFileVersion(ctx, "files", "a.enc")
+			require.NoError(t, err)
+			assert.Equal(t, []string{v3.VersionID, v2.VersionID, v1.VersionID}, versions, "restoring the latest version removes the marker")
+
+			require.NoError(t, storage.RestoreFile(ctx, "files", "a.enc", v2.VersionID))
+			assert.Equal(t, "second", read(storage.DownloadFileStream(ctx, "files", "a.enc")), "an older version is copied back")
+		})
+
+		t.Run(name+" keeps locked versions and buckets that hold files", func(t *testing.T) {
+			storage := newStorage(t)
+			require.NoError(t, storage.CreateBucket(ctx, "files"))
+			v1, err := storage.UploadFile(ctx, "files", "a.enc", strings.NewReader("sealed"), 6)
+			require.NoError(t, err)
+
+			require.NoError(t, storage.SetFileLegalHold(ctx, "files", "a.enc", true))
+			assert.ErrorIs(t, storage.DeleteFileVersion(ctx, "files", "a.enc", v1.VersionID), model.ErrObjectLocked)
+			require.NoError(t, storage.SetFileLegalHold(ctx, "files", "a.enc", false))
+
+			retainUntil := time.Now().Add(time.Hour)
+			require.NoError(t, storage.SetFileRetention(ctx, "files", "a.enc", retainUntil))
+			assert.ErrorIs(t, storage.SetFileRetention(ctx, "files", "a.enc", retainUntil.Add(-time.Minute)), model.ErrRetentionShortened)
+			assert.ErrorIs(t, storage.DeleteFileVersion(ctx, "files", "a.enc", v1.VersionID), model.ErrObjectLocked)
+			assert.ErrorIs(t, storage.RemoveBucket(ctx, "files"), model.ErrBucketNotEmpty)
+
+			v2, err := storage.UploadFile(ctx, "files", "b.enc", strings.NewReader("open"), 4)
+			require.NoError(t, err)
+			require.NoError(t, storage.DeleteFileVersion(ctx, "files", "b.enc", v2.VersionID))
+			require.NoError(t, storage.DeleteFileVersion(ctx, "files", "b.enc", v2.VersionID), "removing a removed version succeeds")
+			versions, err := storage.ListFileVersion(ctx, "files", "b.enc")
+			require.NoError(t, err)
+			assert.Empty(t, versions)
+
+			require.NoError(t, storage.CreateBucket(ctx, "empty"))
+			require.NoError(t, storage.RemoveBucket(ctx, "empty"))
+			require.NoError(t, storage.RemoveBucket(ctx, "empty"), "a removed bucket is already gone")
+			_, err = storage.ListFiles(ctx, "empty")
+			assert.ErrorIs(t, err, model.ErrBucketNotFound)
+		})
+
+		t.Run(name+" assembles multipart uploads in the order given", func(t *testing.T) {
+			storage, read := newStorage(t), objectReader(t)
+			require.NoError(t, storage.CreateBucket(ctx, "files"))
+			uploadID, err := storage.CreateMultipartUpload(ctx, "files", "big.enc")
+			require.NoError(t, err)
+
+			etag2, err := storage.UploadPart(ctx, "files", "big.enc", uploadID, 2, strings.NewReader("world"), 5)
+			require.NoError(t, err)
+			etag1, err := storage.UploadPart(ctx, "files", "big.enc", uploadID, 1, strings.NewReader("hello "), 6)
+			require.NoError(t, err)
+
+			_, err = storage.CompleteMultipartUpload(ctx, "files", "big.enc", uploadID, []model.StoragePart{{PartNumber: 1, ETag: etag2}})
+			assert.ErrorIs(t, err, model.ErrUploadPartsMissing, "a part is only used under its own ETag")
+
+			_, err = storage.CompleteMultipartUpload(ctx, "files", "big.enc", uploadID, []model.StoragePart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", read(storage.DownloadFileStream(ctx, "files", "big.enc")))
+			assert.ErrorIs(t, storage.AbortMultipartUpload(ctx, "files", "big.enc", uploadID), model.ErrMultipartNotFound)
+		})
+	}
+
+	t.Run("filesystem versions survive a restart and leave no partial writes behind", func(t *testing.T) {
+		root := t.TempDir()
+		storage, err := services.NewFilesystemStorageService(root)
+		require.NoError(t, err)
+		require.NoError(t, storage.CreateBucket(ctx, "files"))
+		_, err = storage.UploadFile(ctx, "files", "nested/name.enc", strings.NewReader("kept"), 4)
+		require.NoError(t, err)
+		_, err = storage.UploadFile(ctx, "files", "nested/name.enc", strings.NewReader("cut short"), 100)
+		require.Error(t, err)
+		_, err = storage.UploadFile(ctx, "files", "..", strings.NewReader("escape"), 6)
+		assert.Error(t, err, "object names cannot reach outside their bucket")
+
+		reopened, err := services.NewFilesystemStorageService(root)
+		require.NoError(t, err)
+		read := objectReader(t)
+		assert.Equal(t, "kept", read(reopened.DownloadFileStream(ctx, "files", "nested/name.enc")))
+		files, err := reopened.ListFiles(ctx, "files")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"nested/name.enc"}, files)
+
+		staged, err := os.ReadDir(filepath.Join(root, "files", "tmp"))
+		require.NoError(t, err)
+		assert.Empty(t, staged, "failed writes are cleaned up")
+	})
+
+	t.Run("the file service runs on the in-memory backend", func(t *testing.T) {
+		_, params, storage := setupMemoryFiles(t)
+		fileService, read := services.NewFileService(params), objectReader(t)
+
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "report.txt", strings.NewReader("quarterly figures"), model.UploadOptions{})
+		require.NoError(t, err)
+		_, err = fileService.UpdateFile(ctx, "billing-client", fileID, "report.txt", strings.NewReader("revised figures"), model.UploadOptions{})
+		require.NoError(t, err)
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "revised figures", read(io.NopCloser(download.Content), nil))
+		first, err := fileService.DownloadFileVersion(ctx, "billing-client", fileID, 1, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "quarterly figures", read(io.NopCloser(first.Content), nil))
+
+		require.NoError(t, fileService.DeleteFile(ctx, "billing-client", fileID))
+		exists, _, err := storage.Exists(ctx, "files", fileID+".enc")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("an upload that fails after storage wrote it leaves no version behind", func(t *testing.T) {
+		db, params := setupPurge(t)
+		storage := &truncatingStorage{StorageInterface: services.NewMemoryStorageService()}
+		require.NoError(t, storage.CreateBucket(ctx, "files"))
+		params.StorageService, params.KMSService = storage, &stubExportingKMS{}
+		params.CryptoService = services.NewCryptographicService()
+		params.UploadJobRepository = repository.NewUploadJobRepository(db)
+		params.HashMethod, params.EncryptionMethod = services.HashSHA256, services.EncryptionAES256GCM
+
+		_, err := services.NewFileService(params).UploadFile(ctx, "billing-client", "big.bin", strings.NewReader(strings.Repeat("x", 1<<16)), model.UploadOptions{})
+		require.Error(t, err)
+		require.Len(t, storage.written, 1)
+		versions, err := storage.ListFileVersion(ctx, "files", storage.written[0])
+		require.NoError(t, err)
+		assert.Empty(t, versions, "the stored version is removed rather than hidden behind a delete marker")
+	})
+
+	t.Run("an upload the worker settled first is only kept when the worker committed it", func(t *testing.T) {
+		_, params, storage := setupMemoryFiles(t)
+		jobs := params.UploadJobRepository
+		params.UploadJobRepository = &racingJobs{UploadJobRepository: jobs,
+			settle: func(ctx context.Context, job entity.UploadJobs, _ *entity.Files, _ *entity.Metadata) error {
+				return jobs.Fail(ctx, &job)
+			}}
+
+		_, err := services.NewFileService(params).UploadFile(ctx, "billing-client", "late.txt", strings.NewReader("too late"), model.UploadOptions{})
+		assert.ErrorIs(t, err, model.ErrFileUploadFailed)
+		objects, err := storage.ListFiles(ctx, "files")
+		require.NoError(t, err)
+		assert.Empty(t, objects, "the object of a compensated upload is removed")
+
+		params.UploadJobRepository = &racingJobs{UploadJobRepository: jobs,
+			settle: func(ctx context.Context, job entity.UploadJobs, file *entity.Files, metadata *entity.Metadata) error {
+				return jobs.Complete(ctx, &job, file, metadata)
+			}}
+		fileService, read := services.NewFileService(params), objectReader(t)
+		fileID, err := fileService.UploadFile(ctx, "billing-client", "early.txt", strings.NewReader("committed"), model.UploadOptions{})
+		require.NoError(t, err)
+		download, err := fileService.DownloadFile(ctx, "billing-client", fileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "committed", read(io.NopCloser(download.Content), nil))
+	})
+}
+
+// setupMemoryFiles returns the purge fixtures wired to encrypt into the in-memory backend, which holds the "files" bucket
+func setupMemoryFiles(t *testing.T) (*gorm.DB, services.FileServiceParams, services.StorageInterface) {
+	t.Helper()
+	db, params := setupPurge(t)
+	storage := services.NewMemoryStorageService()
+	require.NoError(t, storage.CreateBucket(context.Background(), "files"))
+	params.StorageService, params.KMSService = storage, &stubExportingKMS{}
+	params.CryptoService = services.NewCryptographicService()
+	params.UploadJobRepository = repository.NewUploadJobRepository(db)
+	params.HashMethod, params.EncryptionMethod = services.HashSHA256, services.EncryptionAES256GCM
+	return db, params, storage
+}
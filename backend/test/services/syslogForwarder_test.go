@@ -0,0 +1,163 @@
+package services_test
+
+import (
+	"bufio"
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/services"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectSyslog accepts connections on listener and sends every octet-counted message it receives to the channel
+func collectSyslog(t *testing.T, listener net.Listener) <-chan string {
+	t.Helper()
+	messages := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					length, err := reader.ReadString(' ')
+					if err != nil {
+						return
+					}
+					size, err := strconv.Atoi(strings.TrimSpace(length))
+					if err != nil {
+						return
+					}
+					message := make([]byte, size)
+					if _, err := io.ReadFull(reader, message); err != nil {
+						return
+					}
+					messages <- string(message)
+				}
+			}(conn)
+		}
+	}()
+	return messages
+}
+
+func receiveSyslog(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no syslog message received")
+		return ""
+	}
+}
+
+func testAuditEntry(id uint, action string) *entity.FileLogs {
+	return &entity.FileLogs{
+		ID:        id,
+		ActorID:   "app-1",
+		ActorType: "client",
+		FileID:    fmt.Sprintf("file-%d", id),
+		Action:    action,
+		IP:        "10.0.0.1",
+		UserAgent: "agent\nwith \"quotes\"",
+		Timestamp: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Hash:      "abc123",
+	}
+}
+
+func TestSyslogForwarder(t *testing.T) {
+	t.Run("forwards events as RFC 5424 messages", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		messages := collectSyslog(t, listener)
+
+		forwarder, err := services.NewSyslogForwarder(services.SyslogForwarderParams{
+			Address:   listener.Addr().String(),
+			AppName:   "crypsis-test",
+			SpoolPath: filepath.Join(t.TempDir(), "syslog.spool"),
+		})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go forwarder.Run(ctx)
+
+		forwarder.Publish(testAuditEntry(1, "download"))
+		forwarder.Publish(testAuditEntry(2, "delete"))
+
+		first := receiveSyslog(t, messages)
+		assert.True(t, strings.HasPrefix(first, "<110>1 2024-06-01T12:00:00.000000Z "), first)
+		assert.Contains(t, first, " crypsis-test - download [crypsis@32473 log_id=\"1\" actor_id=\"app-1\" actor_type=\"client\" file_id=\"file-1\" ip=\"10.0.0.1\" hash=\"abc123\"] {")
+		assert.NotContains(t, first, "\n")
+
+		second := receiveSyslog(t, messages)
+		assert.True(t, strings.HasPrefix(second, "<109>1 "), "deletes are sent as notices")
+	})
+
+	t.Run("spools events while the collector is down and replays them in order", func(t *testing.T) {
+		// Reserve an address nobody listens on yet
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		spoolPath := filepath.Join(t.TempDir(), "syslog.spool")
+		forwarder, err := services.NewSyslogForwarder(services.SyslogForwarderParams{
+			Address:       address,
+			SpoolPath:     spoolPath,
+			RetryInterval: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go forwarder.Run(ctx)
+
+		forwarder.Publish(testAuditEntry(1, "upload"))
+		forwarder.Publish(testAuditEntry(2, "download"))
+		require.Eventually(t, func() bool {
+			spooled, _ := os.ReadFile(spoolPath)
+			return strings.Count(string(spooled), "\n") == 2
+		}, 5*time.Second, 10*time.Millisecond, "undelivered events are spooled to disk")
+
+		listener, err = net.Listen("tcp", address)
+		require.NoError(t, err)
+		defer listener.Close()
+		messages := collectSyslog(t, listener)
+
+		forwarder.Publish(testAuditEntry(3, "update"))
+		assert.Contains(t, receiveSyslog(t, messages), `log_id="1"`)
+		assert.Contains(t, receiveSyslog(t, messages), `log_id="2"`)
+		assert.Contains(t, receiveSyslog(t, messages), `log_id="3"`)
+	})
+
+	t.Run("events queued at shutdown are spooled for the next start", func(t *testing.T) {
+		spoolPath := filepath.Join(t.TempDir(), "syslog.spool")
+		forwarder, err := services.NewSyslogForwarder(services.SyslogForwarderParams{
+			Address:   "127.0.0.1:1",
+			SpoolPath: spoolPath,
+		})
+		require.NoError(t, err)
+
+		forwarder.Publish(testAuditEntry(1, "upload"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		forwarder.Run(ctx)
+
+		spooled, err := os.ReadFile(spoolPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(spooled), `log_id="1"`)
+	})
+}
@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/model/constant"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupUploadSessions returns a file service on the in-memory backend able to take resumable uploads
+func setupUploadSessions(t *testing.T) services.FileInterface {
+	t.Helper()
+	db, params, _ := setupMemoryFiles(t)
+	params.UploadSessionRepository = repository.NewUploadSessionRepository(db)
+	// Without a KEK to wrap it with, the session key is exported from KMS again for every part
+	params.KeyConfig = &model.KeyConfig{KMSEnable: true}
+	return services.NewFileService(params)
+}
+
+func TestFileService_UploadSession(t *testing.T) {
+	ctx := context.Background()
+	read := objectReader(t)
+	// Every part but the last has to be at least the storage minimum of 5 MiB
+	first, second := strings.Repeat("a", 5<<20), "the tail"
+
+	t.Run("parts sent in any order complete into one file", func(t *testing.T) {
+		fileService := setupUploadSessions(t)
+		session, err := fileService.InitiateUpload(ctx, "billing-client", "backup.tar")
+		require.NoError(t, err)
+
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 2, strings.NewReader("an early tail"))
+		require.NoError(t, err)
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 1, strings.NewReader(first))
+		require.NoError(t, err)
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 2, strings.NewReader(second))
+		require.NoError(t, err, "a part sent again replaces the earlier one")
+
+		completed, err := fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, session.FileID, completed.FileID)
+		again, err := fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, completed.FileID, again.FileID, "completing twice returns the same file")
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", completed.FileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, first+second, read(download.Content, nil))
+
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 3, strings.NewReader("too late"))
+		assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+		state, err := fileService.GetUploadSession(ctx, "billing-client", session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, constant.SessionStatusCompleted, state.Status)
+		assert.Len(t, state.Parts, 2)
+	})
+
+	t.Run("a completion refused for its parts leaves the session open", func(t *testing.T) {
+		fileService := setupUploadSessions(t)
+		session, err := fileService.InitiateUpload(ctx, "billing-client", "backup.tar")
+		require.NoError(t, err)
+
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 2, strings.NewReader(second))
+		require.NoError(t, err)
+		_, err = fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		assert.ErrorIs(t, err, model.ErrUploadPartsMissing)
+
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 1, strings.NewReader("too small"))
+		require.NoError(t, err)
+		_, err = fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		assert.ErrorIs(t, err, model.ErrUploadPartTooSmall)
+
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 1, strings.NewReader(first))
+		require.NoError(t, err, "the session still takes parts")
+		completed, err := fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		require.NoError(t, err)
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", completed.FileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, first+second, read(download.Content, nil))
+	})
+
+	t.Run("an aborted session takes no more parts and cannot complete", func(t *testing.T) {
+		fileService := setupUploadSessions(t)
+		session, err := fileService.InitiateUpload(ctx, "billing-client", "backup.tar")
+		require.NoError(t, err)
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 1, strings.NewReader(second))
+		require.NoError(t, err)
+
+		require.NoError(t, fileService.AbortUpload(ctx, "billing-client", session.ID))
+		_, err = fileService.UploadPart(ctx, "billing-client", session.ID, 2, strings.NewReader(second))
+		assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+		_, err = fileService.CompleteUpload(ctx, "billing-client", session.ID)
+		assert.ErrorIs(t, err, model.ErrUploadSessionClosed)
+		_, err = fileService.DownloadFile(ctx, "billing-client", session.FileID, model.DownloadOptions{})
+		assert.Error(t, err)
+	})
+}
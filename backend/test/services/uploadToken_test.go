@@ -0,0 +1,107 @@
+package services_test
+
+import (
+	"context"
+	"crypsis-backend/internal/entity"
+	"crypsis-backend/internal/model"
+	"crypsis-backend/internal/repository"
+	"crypsis-backend/internal/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupUploadTokens returns a file service on the in-memory backend able to mint upload tokens
+func setupUploadTokens(t *testing.T) (*gorm.DB, services.FileInterface, services.StorageInterface) {
+	t.Helper()
+	db, params, storage := setupMemoryFiles(t)
+	require.NoError(t, db.AutoMigrate(&entity.UploadTokens{}, &entity.Folders{}))
+	params.UploadTokenRepository = repository.NewUploadTokenRepository(db)
+	params.FolderRepository = repository.NewFolderRepository(db)
+	params.PublicBaseURL = "https://files.example.com"
+	return db, services.NewFileService(params), storage
+}
+
+// mintUploadToken creates an upload token and returns the token of its URL
+func mintUploadToken(t *testing.T, fileService services.FileInterface, request model.CreateUploadTokenRequest) string {
+	t.Helper()
+	uploadToken, err := fileService.CreateUploadToken(context.Background(), "billing-client", request)
+	require.NoError(t, err)
+	_, token, found := strings.Cut(uploadToken.URL, "/u/")
+	require.True(t, found)
+	return token
+}
+
+func TestFileService_UploadWithToken(t *testing.T) {
+	ctx := context.Background()
+	read := objectReader(t)
+
+	t.Run("an upload through a token is stored for the app that issued it", func(t *testing.T) {
+		_, fileService, _ := setupUploadTokens(t)
+		token := mintUploadToken(t, fileService, model.CreateUploadTokenRequest{MaxSize: 64})
+
+		uploaded, err := fileService.UploadWithToken(ctx, token, "../../scan.txt", "203.0.113.7", strings.NewReader("signed contract"))
+		require.NoError(t, err)
+		assert.Equal(t, "scan.txt", uploaded.FileName, "only the base name of the file is kept")
+
+		download, err := fileService.DownloadFile(ctx, "billing-client", uploaded.FileID, model.DownloadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "signed contract", read(download.Content, nil))
+	})
+
+	t.Run("a token stops working once it expires", func(t *testing.T) {
+		db, fileService, _ := setupUploadTokens(t)
+		token := mintUploadToken(t, fileService, model.CreateUploadTokenRequest{MaxSize: 64, ExpiresIn: "1h"})
+
+		require.NoError(t, db.Model(&entity.UploadTokens{}).Where("app_id = ?", "app-1").
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		_, err := fileService.UploadWithToken(ctx, token, "late.txt", "203.0.113.7", strings.NewReader("too late"))
+		assert.ErrorIs(t, err, model.ErrUploadTokenExpired)
+
+		_, err = fileService.CreateUploadToken(ctx, "billing-client", model.CreateUploadTokenRequest{MaxSize: 64, ExpiresIn: "720h"})
+		assert.ErrorIs(t, err, model.ErrInvalidUploadTokenDuration, "tokens cannot outlive the maximum TTL")
+	})
+
+	t.Run("a revoked or tampered token is refused", func(t *testing.T) {
+		_, fileService, _ := setupUploadTokens(t)
+		token := mintUploadToken(t, fileService, model.CreateUploadTokenRequest{MaxSize: 64})
+
+		_, err := fileService.UploadWithToken(ctx, token+"x", "scan.txt", "203.0.113.7", strings.NewReader("forged"))
+		assert.ErrorIs(t, err, model.ErrUploadTokenNotFound)
+
+		tokens, err := fileService.ListUploadTokens(ctx, "billing-client")
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		require.NoError(t, fileService.RevokeUploadToken(ctx, "billing-client", tokens[0].ID))
+		_, err = fileService.UploadWithToken(ctx, token, "scan.txt", "203.0.113.7", strings.NewReader("revoked"))
+		assert.ErrorIs(t, err, model.ErrUploadTokenRevoked)
+	})
+
+	t.Run("a file over the size limit is refused and leaves nothing behind", func(t *testing.T) {
+		_, fileService, storage := setupUploadTokens(t)
+		token := mintUploadToken(t, fileService, model.CreateUploadTokenRequest{MaxSize: 16})
+
+		_, err := fileService.UploadWithToken(ctx, token, "exact.txt", "203.0.113.7", strings.NewReader(strings.Repeat("a", 16)))
+		require.NoError(t, err, "a file of exactly the limit is accepted")
+		_, err = fileService.UploadWithToken(ctx, token, "large.txt", "203.0.113.7", strings.NewReader(strings.Repeat("a", 17)))
+		assert.ErrorIs(t, err, model.ErrUploadTooLarge)
+
+		objects, err := storage.ListFiles(ctx, "files")
+		require.NoError(t, err)
+		assert.Len(t, objects, 1, "the refused upload is removed from storage")
+	})
+
+	t.Run("a file of a type the token does not allow is refused", func(t *testing.T) {
+		_, fileService, _ := setupUploadTokens(t)
+		token := mintUploadToken(t, fileService, model.CreateUploadTokenRequest{MaxSize: 1024, AllowedMimeTypes: []string{"image/*"}})
+
+		_, err := fileService.UploadWithToken(ctx, token, "photo.png", "203.0.113.7", strings.NewReader("plain text, whatever the name says"))
+		assert.ErrorIs(t, err, model.ErrMimeTypeNotAllowed)
+		_, err = fileService.UploadWithToken(ctx, token, "photo.png", "203.0.113.7", strings.NewReader("\x89PNG\r\n\x1a\n"))
+		assert.NoError(t, err)
+	})
+}
@@ -9,6 +9,7 @@ CREATE TABLE admins (
     deleted_at TIMESTAMPTZ,
     salt VARCHAR(255) NOT NULL
 );
+CREATE INDEX idx_admins_client_id ON admins (client_id);
 CREATE INDEX idx_admins_deleted_at ON admins (deleted_at);
 
 -- 2. Apps table (independent)
@@ -20,12 +21,29 @@ CREATE TABLE apps (
     is_active BOOLEAN NOT NULL,
     uri TEXT,
     redirect_uri TEXT,
+    key_provider VARCHAR(32) NOT NULL DEFAULT 'internal',
+    key_endpoint TEXT,
+    key_id VARCHAR(255),
+    key_ca_cert TEXT,
+    file_ttl_days BIGINT NOT NULL DEFAULT 0,
+    deleted_retention_days BIGINT NOT NULL DEFAULT 0,
+    max_storage_bytes BIGINT NOT NULL DEFAULT 0,
+    max_files BIGINT NOT NULL DEFAULT 0,
+    max_file_size BIGINT NOT NULL DEFAULT 0,
+    bucket_name VARCHAR(63) NOT NULL DEFAULT '',
+    hash_method VARCHAR(32) NOT NULL DEFAULT '',
+    encryption_method VARCHAR(32) NOT NULL DEFAULT '',
+    hash_encrypted_file BOOLEAN,
+    replicate BOOLEAN,
+    bucket_migration_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ,
     updated_at TIMESTAMPTZ,
     deleted_at TIMESTAMPTZ
 );
+CREATE INDEX idx_apps_name ON apps (name);
 CREATE UNIQUE INDEX idx_apps_client_id ON apps (client_id);
 CREATE INDEX idx_apps_is_active ON apps (is_active);
+CREATE INDEX idx_apps_bucket_migration_at ON apps (bucket_migration_at);
 CREATE INDEX idx_apps_deleted_at ON apps (deleted_at);
 
 -- 3. Files table (independent, referenced by metadata)
@@ -38,6 +56,12 @@ CREATE TABLE files (
     size BIGINT NOT NULL,
     bucket_name VARCHAR(255),
     location TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'stored',
+    folder_id VARCHAR(36),
+    path VARCHAR(1024) NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ,
+    legal_hold BOOLEAN NOT NULL DEFAULT false,
+    retain_until TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT now(),
     updated_at TIMESTAMPTZ DEFAULT now(),
     deleted_at TIMESTAMPTZ
@@ -45,21 +69,36 @@ CREATE TABLE files (
 CREATE INDEX idx_files_name ON files (name);
 CREATE INDEX idx_files_app_id ON files (app_id);
 CREATE INDEX idx_files_user_id ON files (user_id);
+CREATE INDEX idx_files_status ON files (status);
+CREATE INDEX idx_files_folder_id ON files (folder_id);
+CREATE UNIQUE INDEX idx_files_app_path ON files (app_id, path) WHERE path <> '' AND deleted_at IS NULL;
+CREATE INDEX idx_files_expires_at ON files (expires_at);
+CREATE INDEX idx_files_retain_until ON files (retain_until);
 CREATE INDEX idx_files_deleted_at ON files (deleted_at);
 
--- 4. FileLogs table (can reference files via file_id, if needed)
+-- 4. FileLogs table, the hash-chained audit trail of files, apps, admin accounts and keys
 CREATE TABLE file_logs (
     id SERIAL PRIMARY KEY,
     actor_id TEXT NOT NULL,
-    actor_type TEXT NOT NULL CHECK (actor_type IN ('user', 'client', 'system', 'admin')),
-    file_id UUID NOT NULL,
-    action TEXT NOT NULL CHECK (action IN ('upload', 'download', 'update', 'delete', 'recover', 'encrypt', 'decrypt', 're-key')),
+    actor_type TEXT NOT NULL CONSTRAINT chk_file_logs_actor_type CHECK (actor_type IN ('user', 'client', 'system', 'admin')),
+    file_id TEXT NOT NULL,
+    resource_type TEXT NOT NULL DEFAULT 'file' CONSTRAINT chk_file_logs_resource_type CHECK (resource_type IN ('file', 'app', 'admin', 'key')),
+    resource_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL CONSTRAINT chk_file_logs_action CHECK (action IN ('upload', 'download', 'update', 'delete', 'recover', 'encrypt', 'decrypt', 're-key', 'create', 'login', 'logout', 'rotate-secret', 'password-change', 'legal-hold', 'legal-hold-release', 'retention', 'migrate')),
+    outcome TEXT NOT NULL DEFAULT 'success' CONSTRAINT chk_file_logs_outcome CHECK (outcome IN ('success', 'failure')),
+    trace_id VARCHAR(32) NOT NULL DEFAULT '',
     timestamp TIMESTAMPTZ DEFAULT now(),
-    ip INET,
+    ip TEXT,
     user_agent TEXT,
-    metadata JSONB
+    metadata JSONB,
+    prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT ''
 );
 CREATE INDEX idx_file_logs_file_id ON file_logs (file_id);
+CREATE INDEX idx_file_logs_resource_type ON file_logs (resource_type);
+CREATE INDEX idx_file_logs_resource_id ON file_logs (resource_id);
+CREATE INDEX idx_file_logs_trace_id ON file_logs (trace_id);
+CREATE INDEX idx_file_logs_hash ON file_logs (hash);
 
 -- 5. Metadata table (depends on files)
 CREATE TABLE metadata (
@@ -69,7 +108,11 @@ CREATE TABLE metadata (
     enc_hash VARCHAR(256),
     key_uid VARCHAR(256),
     enc_key TEXT NOT NULL,
+    key_fingerprint VARCHAR(128),
     key_algo VARCHAR(64) NOT NULL,
+    hash_algo VARCHAR(32) NOT NULL DEFAULT '',
+    format VARCHAR(32) NOT NULL DEFAULT 'aead',
+    header_version BIGINT NOT NULL DEFAULT 0,
     version_id VARCHAR(64),
     created_at TIMESTAMPTZ DEFAULT now(),
     updated_at TIMESTAMPTZ DEFAULT now(),
@@ -81,3 +124,186 @@ CREATE INDEX idx_metadata_file_id ON metadata (file_id);
 CREATE INDEX idx_metadata_enc_hash ON metadata (enc_hash);
 CREATE INDEX idx_metadata_key_uid ON metadata (key_uid);
 CREATE INDEX idx_metadata_deleted_at ON metadata (deleted_at);
+
+-- 6. FileVersions table, every stored version of a file (depends on files)
+CREATE TABLE file_versions (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    version BIGINT NOT NULL,
+    version_id VARCHAR(64),
+    name TEXT NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    hash VARCHAR(256) NOT NULL,
+    enc_hash VARCHAR(256),
+    key_uid VARCHAR(256),
+    enc_key TEXT NOT NULL,
+    key_fingerprint VARCHAR(128),
+    key_algo VARCHAR(64) NOT NULL,
+    hash_algo VARCHAR(32) NOT NULL DEFAULT '',
+    format VARCHAR(32) NOT NULL DEFAULT 'aead',
+    header_version BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_file_versions_file_version ON file_versions (file_id, version);
+CREATE INDEX idx_file_versions_key_uid ON file_versions (key_uid);
+
+-- 7. UploadJobs table, the outbox of storage writes awaiting their database commit
+CREATE TABLE upload_jobs (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    app_id VARCHAR(36),
+    operation VARCHAR(16) NOT NULL CONSTRAINT chk_upload_jobs_operation CHECK (operation IN ('upload', 'update')),
+    status VARCHAR(16) NOT NULL CONSTRAINT chk_upload_jobs_status CHECK (status IN ('pending', 'compensating', 'done', 'failed')),
+    bucket_name VARCHAR(255) NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    version_id VARCHAR(64),
+    base_version_id VARCHAR(64),
+    payload TEXT,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX idx_upload_jobs_file_id ON upload_jobs (file_id);
+CREATE INDEX idx_upload_jobs_app_id ON upload_jobs (app_id);
+CREATE INDEX idx_upload_jobs_status ON upload_jobs (status);
+CREATE INDEX idx_upload_jobs_next_attempt_at ON upload_jobs (next_attempt_at);
+
+-- 8. UploadSessions and UploadParts tables, resumable multipart uploads
+CREATE TABLE upload_sessions (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    app_id VARCHAR(36) NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    file_name TEXT NOT NULL,
+    mime_type VARCHAR(255),
+    bucket_name VARCHAR(255) NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    key_uid VARCHAR(256),
+    enc_key TEXT,
+    header_version BIGINT NOT NULL DEFAULT 0,
+    hash_algo VARCHAR(32) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL CONSTRAINT chk_upload_sessions_status CHECK (status IN ('open', 'completing', 'completed', 'aborted', 'expired', 'failed')),
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX idx_upload_sessions_app_id ON upload_sessions (app_id);
+CREATE UNIQUE INDEX idx_upload_sessions_file_id ON upload_sessions (file_id);
+CREATE INDEX idx_upload_sessions_status ON upload_sessions (status);
+CREATE INDEX idx_upload_sessions_expires_at ON upload_sessions (expires_at);
+
+CREATE TABLE upload_parts (
+    session_id VARCHAR(36) NOT NULL,
+    part_number BIGINT NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    e_tag VARCHAR(128) NOT NULL,
+    size BIGINT NOT NULL,
+    enc_size BIGINT NOT NULL,
+    hash VARCHAR(256) NOT NULL,
+    enc_hash VARCHAR(256),
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY (session_id, part_number)
+);
+CREATE INDEX idx_upload_parts_file_id ON upload_parts (file_id);
+
+-- 9. ShareLinks table, links that let anyone holding them download a file
+CREATE TABLE share_links (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    app_id VARCHAR(36) NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    max_downloads BIGINT NOT NULL DEFAULT 0,
+    downloads BIGINT NOT NULL DEFAULT 0,
+    passphrase_hash TEXT,
+    allowed_cidr VARCHAR(64),
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX idx_share_links_app_id ON share_links (app_id);
+CREATE INDEX idx_share_links_file_id ON share_links (file_id);
+CREATE INDEX idx_share_links_expires_at ON share_links (expires_at);
+
+-- 10. UploadTokens table, tokens that let anyone holding them upload into an app
+CREATE TABLE upload_tokens (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    app_id VARCHAR(36) NOT NULL,
+    max_size BIGINT NOT NULL,
+    allowed_mime_types TEXT,
+    folder TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX idx_upload_tokens_app_id ON upload_tokens (app_id);
+CREATE INDEX idx_upload_tokens_expires_at ON upload_tokens (expires_at);
+
+-- 11. Folders and FileTags tables, the folder tree and tags of files
+CREATE TABLE folders (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    app_id VARCHAR(36) NOT NULL,
+    parent_id VARCHAR(36),
+    name VARCHAR(255) NOT NULL,
+    path VARCHAR(1024) NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_folders_app_path ON folders (app_id, path);
+CREATE INDEX idx_folders_parent_id ON folders (parent_id);
+
+CREATE TABLE file_tags (
+    file_id VARCHAR(36) NOT NULL,
+    key VARCHAR(64) NOT NULL,
+    value VARCHAR(256) NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (file_id, key)
+);
+CREATE INDEX idx_file_tags_key_value ON file_tags (key, value);
+
+-- 12. AuditCheckpoints table, signed checkpoints over the head of the audit chain
+CREATE TABLE audit_checkpoints (
+    id SERIAL PRIMARY KEY,
+    sequence BIGINT NOT NULL DEFAULT 0,
+    prev_signature VARCHAR(128) NOT NULL DEFAULT '',
+    first_sequence BIGINT NOT NULL DEFAULT 0,
+    log_id BIGINT NOT NULL,
+    log_hash VARCHAR(64) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_checkpoints_sequence ON audit_checkpoints (sequence);
+CREATE INDEX idx_audit_checkpoints_log_id ON audit_checkpoints (log_id);
+CREATE INDEX idx_audit_checkpoints_kind ON audit_checkpoints (kind);
+
+-- 13. AppUsage table, what every app stores against its quota
+CREATE TABLE app_usage (
+    app_id VARCHAR(36) PRIMARY KEY NOT NULL,
+    stored_bytes BIGINT NOT NULL DEFAULT 0,
+    file_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ
+);
+
+-- 14. ObjectReplicas table, copies of stored objects on the storage replicas and the repair queue
+CREATE TABLE object_replicas (
+    id VARCHAR(36) PRIMARY KEY NOT NULL,
+    replica VARCHAR(64) NOT NULL,
+    bucket_name VARCHAR(255) NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    version_id VARCHAR(64) NOT NULL DEFAULT '',
+    replica_version_id VARCHAR(64) NOT NULL DEFAULT '',
+    operation VARCHAR(16) NOT NULL CONSTRAINT chk_object_replicas_operation CHECK (operation IN ('copy', 'delete', 'mark-deleted', 'restore')),
+    status VARCHAR(16) NOT NULL CONSTRAINT chk_object_replicas_status CHECK (status IN ('pending', 'synced')),
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX idx_object_replicas_object ON object_replicas (replica, bucket_name, object_name);
+CREATE INDEX idx_object_replicas_status ON object_replicas (status);
+CREATE INDEX idx_object_replicas_next_attempt_at ON object_replicas (next_attempt_at);